@@ -2,160 +2,1613 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/base64"
 	"errors"
+	"expvar"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/accesslog"
+	"github.com/immxrtalbeast/api-gateway/internal/acl"
+	"github.com/immxrtalbeast/api-gateway/internal/analytics"
+	"github.com/immxrtalbeast/api-gateway/internal/apierror"
+	"github.com/immxrtalbeast/api-gateway/internal/apiversion"
+	"github.com/immxrtalbeast/api-gateway/internal/audit"
+	"github.com/immxrtalbeast/api-gateway/internal/changefeed"
+	"github.com/immxrtalbeast/api-gateway/internal/clienterrors"
+	"github.com/immxrtalbeast/api-gateway/internal/clients/auth"
+	"github.com/immxrtalbeast/api-gateway/internal/clients/captcha"
+	"github.com/immxrtalbeast/api-gateway/internal/clients/egress"
+	"github.com/immxrtalbeast/api-gateway/internal/clients/entitlements"
+	"github.com/immxrtalbeast/api-gateway/internal/clients/grpcconn"
+	"github.com/immxrtalbeast/api-gateway/internal/clients/jwks"
+	"github.com/immxrtalbeast/api-gateway/internal/clients/ofrep"
+	"github.com/immxrtalbeast/api-gateway/internal/clients/oidc"
 	"github.com/immxrtalbeast/api-gateway/internal/clients/scripts"
 	"github.com/immxrtalbeast/api-gateway/internal/clients/videos"
 	"github.com/immxrtalbeast/api-gateway/internal/config"
+	"github.com/immxrtalbeast/api-gateway/internal/credits"
+	"github.com/immxrtalbeast/api-gateway/internal/errreport"
 	"github.com/immxrtalbeast/api-gateway/internal/events"
+	"github.com/immxrtalbeast/api-gateway/internal/flags"
+	"github.com/immxrtalbeast/api-gateway/internal/health"
 	"github.com/immxrtalbeast/api-gateway/internal/http/handlers"
 	"github.com/immxrtalbeast/api-gateway/internal/http/middleware"
+	"github.com/immxrtalbeast/api-gateway/internal/journal"
+	"github.com/immxrtalbeast/api-gateway/internal/maintenance"
+	"github.com/immxrtalbeast/api-gateway/internal/masking"
+	"github.com/immxrtalbeast/api-gateway/internal/metrics"
+	"github.com/immxrtalbeast/api-gateway/internal/openapi"
+	"github.com/immxrtalbeast/api-gateway/internal/reload"
+	"github.com/immxrtalbeast/api-gateway/internal/replicas"
+	"github.com/immxrtalbeast/api-gateway/internal/revocation"
+	"github.com/immxrtalbeast/api-gateway/internal/store"
+	"github.com/immxrtalbeast/api-gateway/internal/timing"
+	"github.com/immxrtalbeast/api-gateway/internal/usage"
+	"github.com/immxrtalbeast/api-gateway/internal/webhooks"
 	"github.com/immxrtalbeast/api-gateway/lib/logger/slogpretty"
-	authv1 "github.com/immxrtalbeast/protos/gen/go/auth/v1"
 	"github.com/joho/godotenv"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+	"google.golang.org/grpc"
+)
+
+func main() {
+	processEnv := environKeys()
+	dotenvErr := godotenv.Load(".env")
+	cfg := config.MustLoad()
+	log := setupLogger(cfg.Env)
+	log.Info("starting api gateway")
+	if config.Path() == "" {
+		log.Info("no config file given, configured from the environment")
+	}
+	if cfg.Mocks.Enabled {
+		if cfg.Env == envProd {
+			log.Warn("upstream mocks are ignored in prod")
+		} else {
+			log.Warn("upstream mocks enabled", slog.Int("routes", len(cfg.Mocks.Routes)))
+		}
+	}
+	if dotenvErr != nil {
+		log.Warn(".env not loaded", slog.String("err", dotenvErr.Error()))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var upstreamDial egress.DialFunc
+	if cfg.Resolver.Enabled() {
+		resolver, err := egress.NewResolver(egress.ResolverConfig{
+			Servers:      cfg.Resolver.Servers,
+			IPPreference: cfg.Resolver.IPPreference,
+			CacheTTL:     cfg.Resolver.CacheTTL,
+			Timeout:      cfg.Resolver.Timeout,
+		})
+		if err != nil {
+			log.Error("invalid resolver config", slog.String("err", err.Error()))
+			os.Exit(1)
+		}
+		upstreamDial = resolver.DialContext
+		log.Info("custom resolver enabled",
+			slog.Any("servers", cfg.Resolver.Servers),
+			slog.String("ip_preference", cfg.Resolver.IPPreference),
+			slog.Duration("cache_ttl", cfg.Resolver.CacheTTL),
+		)
+	}
+
+	var authClient auth.Client
+	var authConn *grpc.ClientConn
+	var standaloneAuth *auth.Fake
+	if cfg.AuthGRPC.Standalone {
+		if cfg.Env == envProd {
+			log.Error("standalone auth is not allowed in prod")
+			os.Exit(1)
+		}
+		standaloneAuth = auth.NewFake(cfg.AppSecret, cfg.TokenTTL, cfg.AuthGRPC.StandaloneAdmins)
+		authClient = standaloneAuth
+		log.Warn("auth service disabled, using in-memory standalone auth")
+	} else {
+		var err error
+		authConn, err = grpcconn.Dial(ctx, cfg.AuthGRPC.Address, grpcconn.Config{
+			TLS: grpcconn.TLSConfig{
+				Enabled:    cfg.AuthGRPC.TLS.Enabled,
+				CAFile:     cfg.AuthGRPC.TLS.CAFile,
+				CertFile:   cfg.AuthGRPC.TLS.CertFile,
+				KeyFile:    cfg.AuthGRPC.TLS.KeyFile,
+				ServerName: cfg.AuthGRPC.TLS.ServerName,
+			},
+			Keepalive: grpcconn.KeepaliveConfig{
+				Time:                cfg.AuthGRPC.Keepalive.Time,
+				Timeout:             cfg.AuthGRPC.Keepalive.Timeout,
+				PermitWithoutStream: cfg.AuthGRPC.Keepalive.PermitWithoutStream,
+			},
+			Retry: grpcconn.RetryConfig{
+				MaxAttempts:    cfg.AuthGRPC.Retry.MaxAttempts,
+				InitialBackoff: cfg.AuthGRPC.Retry.InitialBackoff,
+				MaxBackoff:     cfg.AuthGRPC.Retry.MaxBackoff,
+				Multiplier:     cfg.AuthGRPC.Retry.Multiplier,
+				Codes:          cfg.AuthGRPC.Retry.Codes,
+			},
+			WaitForReady:      cfg.AuthGRPC.WaitForReady,
+			Dial:              upstreamDial,
+			UnaryInterceptors: []grpc.UnaryClientInterceptor{timing.UnaryClientInterceptor(upstreamAuth)},
+		})
+		if err != nil {
+			log.Error("failed to connect auth grpc", slog.String("err", err.Error()))
+			os.Exit(1)
+		}
+		defer authConn.Close()
+		go grpcconn.WatchState(ctx, authConn, upstreamAuth, log)
+		authClient = auth.New(authConn)
+	}
+	var authCache *auth.Cached
+	if cfg.AuthCache.TTL > 0 {
+		authCache = auth.NewCached(authClient, cfg.AuthCache.TTL, cfg.AuthCache.MaxEntries)
+		authClient = authCache
+	}
+
+	egressProxy := egress.ProxyConfig{URL: cfg.Egress.ProxyURL, NoProxy: cfg.Egress.NoProxy}
+	upstreamTransport, err := egress.HTTPTransport(egressProxy, egress.PoolConfig{
+		MaxIdleConns:        cfg.HTTPClient.MaxIdleConns,
+		MaxIdleConnsPerHost: cfg.HTTPClient.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     cfg.HTTPClient.MaxConnsPerHost,
+		IdleConnTimeout:     cfg.HTTPClient.IdleConnTimeout,
+		TLSHandshakeTimeout: cfg.HTTPClient.TLSHandshakeTimeout,
+		DisableCompression:  cfg.HTTPClient.DisableCompression,
+	}, upstreamDial)
+	if err != nil {
+		log.Error("invalid egress proxy", slog.String("err", err.Error()))
+		os.Exit(1)
+	}
+	if cfg.Egress.ProxyURL != "" {
+		log.Info("egress proxy enabled", slog.Bool("kafka", cfg.Egress.Kafka))
+	}
+
+	scriptUpstream, err := egress.ForUpstream(upstreamTransport, cfg.ScriptService.BaseURL, upstreamConfig(cfg.ScriptService.HTTP))
+	if err != nil {
+		log.Error("invalid script service http config", slog.String("err", err.Error()))
+		os.Exit(1)
+	}
+	videoUpstream, err := egress.ForUpstream(upstreamTransport, cfg.VideoService.BaseURL, upstreamConfig(cfg.VideoService.HTTP))
+	if err != nil {
+		log.Error("invalid video service http config", slog.String("err", err.Error()))
+		os.Exit(1)
+	}
+
+	if cfg.ScriptService.Discovery.Mode != "" && len(cfg.ScriptService.Regions) > 0 {
+		log.Error("script service discovery can't be combined with regions")
+		os.Exit(1)
+	}
+	if cfg.VideoService.Discovery.Mode != "" && (len(cfg.VideoService.Regions) > 0 || cfg.VideoService.StandbyURL != "") {
+		log.Error("video service discovery can't be combined with regions or standby_url")
+		os.Exit(1)
+	}
+	scriptBalanced, err := withDiscovery(ctx, upstreamScripts, cfg.ScriptService.BaseURL, cfg.ScriptService.Discovery, cfg.Discovery, scriptUpstream, upstreamTransport, log)
+	if err != nil {
+		log.Error("invalid script service discovery", slog.String("err", err.Error()))
+		os.Exit(1)
+	}
+
+	scriptTransport, err := withCanary(upstreamScripts, cfg.ScriptService.BaseURL, cfg.ScriptService.Canary, scriptBalanced, log)
+	if err != nil {
+		log.Error("invalid script service canary", slog.String("err", err.Error()))
+		os.Exit(1)
+	}
+
+	scriptTransport, err = withRegions(ctx, upstreamScripts, cfg.ScriptService.BaseURL, cfg.ScriptService.HealthPath, cfg.ScriptService.Regions, cfg.Regions, scriptTransport, log)
+	if err != nil {
+		log.Error("invalid script service regions", slog.String("err", err.Error()))
+		os.Exit(1)
+	}
+
+	scriptTransport, err = withMocks(cfg, upstreamScripts, cfg.ScriptService.BaseURL, cfg.ScriptService.HealthPath, scriptTransport)
+	if err != nil {
+		log.Error("invalid script service mocks", slog.String("err", err.Error()))
+		os.Exit(1)
+	}
+
+	scriptTransport, err = egress.Rewrite(scriptTransport, cfg.ScriptService.BaseURL, rewriteRules(cfg.ScriptService.Rewrites))
+	if err != nil {
+		log.Error("invalid script service rewrites", slog.String("err", err.Error()))
+		os.Exit(1)
+	}
+
+	scriptClient, err := scripts.New(cfg.ScriptService.BaseURL, clientTimeout(cfg.ScriptService.Timeout, cfg.Routes.Timeouts), timing.Transport(upstreamScripts, egress.Deadline(scriptTransport)))
+	if err != nil {
+		log.Error("failed to init script client", slog.String("err", err.Error()))
+		os.Exit(1)
+	}
+
+	videoBalanced, err := withDiscovery(ctx, upstreamVideos, cfg.VideoService.BaseURL, cfg.VideoService.Discovery, cfg.Discovery, videoUpstream, upstreamTransport, log)
+	if err != nil {
+		log.Error("invalid video service discovery", slog.String("err", err.Error()))
+		os.Exit(1)
+	}
+
+	videoTransport := videoBalanced
+	if cfg.VideoService.StandbyURL != "" {
+		videoTransport, err = egress.WithFailover(videoUpstream, cfg.VideoService.BaseURL, cfg.VideoService.StandbyURL, cfg.VideoService.FailoverDelay)
+		if err != nil {
+			log.Error("invalid video service standby", slog.String("err", err.Error()))
+			os.Exit(1)
+		}
+	}
+
+	videoTransport, err = withCanary(upstreamVideos, cfg.VideoService.BaseURL, cfg.VideoService.Canary, videoTransport, log)
+	if err != nil {
+		log.Error("invalid video service canary", slog.String("err", err.Error()))
+		os.Exit(1)
+	}
+
+	videoRegions, err := withRegions(ctx, upstreamVideos, cfg.VideoService.BaseURL, cfg.VideoService.HealthPath, cfg.VideoService.Regions, cfg.Regions, videoTransport, log)
+	if err != nil {
+		log.Error("invalid video service regions", slog.String("err", err.Error()))
+		os.Exit(1)
+	}
+
+	videoRegions, err = withMocks(cfg, upstreamVideos, cfg.VideoService.BaseURL, cfg.VideoService.HealthPath, videoRegions)
+	if err != nil {
+		log.Error("invalid video service mocks", slog.String("err", err.Error()))
+		os.Exit(1)
+	}
+
+	videoRegions, err = egress.Rewrite(videoRegions, cfg.VideoService.BaseURL, rewriteRules(cfg.VideoService.Rewrites))
+	if err != nil {
+		log.Error("invalid video service rewrites", slog.String("err", err.Error()))
+		os.Exit(1)
+	}
+
+	if cfg.VideoService.Hedging.Enabled {
+		videoRegions = egress.Hedge(upstreamVideos, egress.HedgeConfig{
+			Percentile:   cfg.VideoService.Hedging.Percentile,
+			InitialDelay: cfg.VideoService.Hedging.InitialDelay,
+			MinDelay:     cfg.VideoService.Hedging.MinDelay,
+			MaxDelay:     cfg.VideoService.Hedging.MaxDelay,
+		}, videoRegions)
+	}
+
+	videoClient, err := videos.New(cfg.VideoService.BaseURL, clientTimeout(cfg.VideoService.Timeout, cfg.Routes.Timeouts), timing.Transport(upstreamVideos, egress.Deadline(videoRegions)))
+	if err != nil {
+		log.Error("failed to init video client", slog.String("err", err.Error()))
+		os.Exit(1)
+	}
+
+	if cfg.AppSecret == "" {
+		log.Error("APP_SECRET is not configured (set app_secret in config or APP_SECRET env)")
+		os.Exit(1)
+	}
+	if cfg.TokenTTL <= 0 {
+		log.Error("token_ttl must be greater than zero")
+		os.Exit(1)
+	}
+
+	kafkaAuth, err := newKafkaCredentials(cfg.Kafka.SASL)
+	if err != nil {
+		log.Error("invalid kafka sasl config", slog.String("err", err.Error()))
+		os.Exit(1)
+	}
+
+	revoked, err := newRevocationList(cfg, log)
+	if err != nil {
+		log.Error("failed to init token revocation", slog.String("bus", cfg.Revocation.Bus), slog.String("err", err.Error()))
+		os.Exit(1)
+	}
+	// Revocations end the streams of the user or session on every replica.
+	logouts := events.NewLogouts()
+	revoked.OnRevoke(logouts.End)
+	if authCache != nil {
+		// Revoking all sessions of a user, as the auth service does when it
+		// changes their role, also drops their cached lookups.
+		revoked.OnRevoke(func(userID, sessionID string) {
+			if sessionID == "" {
+				authCache.Invalidate(userID)
+			}
+		})
+	}
+	revoked.Run(ctx)
+	defer revoked.Close()
+
+	authHandler := handlers.NewAuthHandler(log, authClient, cfg.AuthGRPC.Timeout, cfg.TokenTTL, cfg.Recovery.MinResponseTime, revoked)
+	videoMasker := masking.New(maskingRules(cfg.Masking.Videos))
+	scriptMasker := masking.New(maskingRules(cfg.Masking.Scripts))
+	scriptHandler := handlers.NewScriptHandler(log, scriptClient, cfg.ScriptService.Timeout, scriptMasker)
+	if cfg.Stream.SchemaEndpoint != "" {
+		loadStageSchema(ctx, videoClient, cfg, log)
+	}
+
+	gatewayStore, err := newStore(cfg)
+	if err != nil {
+		log.Error("failed to init store", slog.String("driver", cfg.Store.Driver), slog.String("err", err.Error()))
+		os.Exit(1)
+	}
+	defer gatewayStore.Close()
+
+	var secretStore *store.Encrypted
+	if len(cfg.Encryption.Keys) > 0 {
+		masterKeys, err := newMasterKeys(cfg.Encryption)
+		if err != nil {
+			log.Error("invalid encryption keys", slog.String("err", err.Error()))
+			os.Exit(1)
+		}
+		secretStore = store.NewEncrypted(store.WithPrefix(gatewayStore, "secrets:"), masterKeys)
+		log.Info("secret encryption enabled", slog.String("primary_key", cfg.Encryption.PrimaryKey), slog.Int("keys", len(cfg.Encryption.Keys)))
+	}
+
+	var webhookStore *webhooks.Store
+	var webhookDispatcher *webhooks.Dispatcher
+	var webhookEvents []string
+	if cfg.Webhooks.Enabled {
+		var webhookSecrets store.Store
+		switch {
+		case secretStore != nil:
+			if cfg.Webhooks.Path != "" && cfg.Store.Driver == storeMemory {
+				log.Error("webhooks.path with encryption needs a persistent store for the signing secrets")
+				os.Exit(1)
+			}
+			webhookSecrets = secretStore
+		case cfg.Webhooks.Path != "":
+			log.Warn("encryption is not configured, webhook signing secrets are stored in plain text", slog.String("path", cfg.Webhooks.Path))
+		}
+		webhookStore, err = webhooks.Open(ctx, cfg.Webhooks.Path, cfg.Webhooks.MaxPerUser, webhookSecrets)
+		if err != nil {
+			log.Error("failed to open webhooks store", slog.String("err", err.Error()))
+			os.Exit(1)
+		}
+		stages := cfg.Stream.TerminalStages
+		if len(stages) == 0 {
+			stages = []string{"ready", "failed"}
+		}
+		stagePath := cfg.Stream.StagePath
+		if stagePath == "" {
+			stagePath = "job.stage"
+		}
+		for _, stage := range stages {
+			webhookEvents = append(webhookEvents, webhooks.EventName(stage))
+		}
+		webhookDispatcher = webhooks.NewDispatcher(webhookStore, webhooks.DispatcherConfig{
+			Workers:        cfg.Webhooks.Workers,
+			MaxAttempts:    cfg.Webhooks.MaxAttempts,
+			InitialBackoff: cfg.Webhooks.InitialBackoff,
+			MaxBackoff:     cfg.Webhooks.MaxBackoff,
+			Timeout:        cfg.Webhooks.Timeout,
+			StagePath:      stagePath,
+			Stages:         stages,
+			Transform:      videoMasker.Apply,
+			History:        cfg.Webhooks.History,
+		}, webhooks.Transport(cfg.Webhooks.Timeout, cfg.Webhooks.AllowPrivate), log)
+		webhookDispatcher.Run(ctx)
+	}
+
+	usageQuotas := usage.Quotas{DefaultPlan: cfg.Usage.DefaultPlan, Plans: cfg.Usage.Plans}
+	var usageStore usage.Store
+	var usageMeter *middleware.UsageMeter
+	if cfg.Usage.Store != "" {
+		usageStore, err = newUsageStore(cfg, gatewayStore)
+		if err != nil {
+			log.Error("failed to init usage store", slog.String("store", cfg.Usage.Store), slog.String("err", err.Error()))
+			os.Exit(1)
+		}
+		defer usageStore.Close()
+		usageMeter = middleware.NewUsageMeter(usageStore, usageQuotas, log)
+		log.Info("usage metering enabled", slog.String("store", cfg.Usage.Store))
+	}
+	usageHandler := handlers.NewUsageHandler(log, usageStore, usageQuotas, time.Second)
+
+	var streamHub *events.Hub
+	var streamLag func() int64
+	var changeLog *changefeed.Log
+	var creditsLedger *credits.Ledger
+	if backend := eventsBackend(cfg); backend != "" {
+		streamHub = events.NewHub(events.HubConfig{
+			ReplaySize: cfg.Stream.ReplaySize,
+			ReplayTTL:  cfg.Stream.ReplayTTL,
+			JobIDPath:  cfg.Stream.JobIDPath,
+			UserIDPath: cfg.Stream.UserIDPath,
+		})
+		var membership *replicas.Membership
+		if backend == eventsKafka && cfg.Kafka.Mode == kafkaBroadcast {
+			if cfg.Store.Driver == storeMemory {
+				log.Warn("kafka broadcast mode with the memory store, replicas can't see each other and side effects will repeat on every replica")
+			}
+			membership = replicas.NewMembership(store.WithPrefix(gatewayStore, "replicas:"), replicaID(cfg), cfg.Replica.Heartbeat, log)
+			membership.Run(ctx)
+		}
+		changeLog = changefeed.New(cfg.Sync.EventsPerUser)
+		streamHub.Listen(changeLog.Record)
+		if webhookDispatcher != nil {
+			streamHub.Listen(events.Owned(membership.Owns, webhookDispatcher.Observe))
+		}
+		if cfg.Credits.Enabled {
+			if usageStore == nil {
+				log.Error("credits accounting requires a usage store (set usage.store)")
+				os.Exit(1)
+			}
+			creditsLedger = credits.NewLedger(usageStore, usageQuotas, streamHub, credits.Config{
+				StagePath:   cfg.Stream.StagePath,
+				ReadyStages: cfg.Credits.ReadyStages,
+				CostPath:    cfg.Credits.CostPath,
+				CreditsPath: cfg.Credits.CreditsPath,
+				PlanPath:    cfg.Credits.PlanPath,
+				LowBalance:  cfg.Credits.LowBalance,
+				History:     cfg.Credits.History,
+				Buffer:      cfg.Credits.Buffer,
+			}, log)
+			defer creditsLedger.Close()
+			streamHub.Listen(events.Owned(membership.Owns, creditsLedger.Observe))
+		}
+		source, err := newEventSource(backend, cfg, streamHub, upstreamDial, kafkaAuth, log)
+		if err != nil {
+			log.Error("failed to init events source", slog.String("backend", backend), slog.String("err", err.Error()))
+			os.Exit(1)
+		}
+		source.Run(ctx)
+		if lagged, ok := source.(interface{ Lag() int64 }); ok {
+			streamLag = lagged.Lag
+		}
+		defer source.Close()
+		log.Info("realtime job updates enabled", slog.String("backend", backend))
+	} else if webhookDispatcher != nil {
+		log.Warn("webhooks enabled without an events backend, no deliveries will be made")
+	}
+	var mediaHub *events.Hub
+	if cfg.MediaStream.Topic != "" {
+		mediaHub = events.NewHub(events.HubConfig{
+			ReplaySize: cfg.Stream.ReplaySize,
+			ReplayTTL:  cfg.Stream.ReplayTTL,
+			JobIDPath:  cfg.MediaStream.IDPath,
+			UserIDPath: cfg.MediaStream.UserIDPath,
+		})
+		mediaSource, err := newKafkaConsumer(cfg, events.KafkaConsumerConfig{
+			Topic:   cfg.MediaStream.Topic,
+			GroupID: cfg.MediaStream.GroupID,
+			Metrics: metrics.MediaConsumer,
+		}, mediaHub, upstreamDial, kafkaAuth, log)
+		if err != nil {
+			log.Error("failed to init media events consumer", slog.String("err", err.Error()))
+			os.Exit(1)
+		}
+		mediaSource.Run(ctx)
+		defer mediaSource.Close()
+		log.Info("realtime media updates enabled", slog.String("topic", cfg.MediaStream.Topic))
+	}
+
+	var creditsHandler *handlers.CreditsHandler
+	if creditsLedger != nil {
+		creditsHandler = handlers.NewCreditsHandler(log, usageStore, usageQuotas, creditsLedger, time.Second)
+	} else if cfg.Credits.Enabled {
+		log.Warn("credits accounting enabled without an events backend, no renders will be charged")
+	}
+
+	var monitor *health.Monitor
+	if cfg.Health.Enabled {
+		// Mocked services report healthy; their routes were checked above.
+		scriptProbe, _ := withMocks(cfg, upstreamScripts, cfg.ScriptService.BaseURL, cfg.ScriptService.HealthPath, scriptBalanced)
+		videoProbe, _ := withMocks(cfg, upstreamVideos, cfg.VideoService.BaseURL, cfg.VideoService.HealthPath, videoBalanced)
+		checkers := []health.Checker{
+			health.NewHTTPChecker(upstreamScripts, strings.TrimRight(cfg.ScriptService.BaseURL, "/")+cfg.ScriptService.HealthPath, scriptProbe),
+			health.NewHTTPChecker(upstreamVideos, strings.TrimRight(cfg.VideoService.BaseURL, "/")+cfg.VideoService.HealthPath, videoProbe),
+		}
+		if authConn != nil {
+			checkers = append(checkers, health.NewGRPCChecker(upstreamAuth, authConn))
+		}
+		monitor = health.NewMonitor(
+			health.Config{
+				Interval:         cfg.Health.Interval,
+				Timeout:          cfg.Health.Timeout,
+				FailureThreshold: cfg.Health.FailureThreshold,
+			},
+			log,
+			checkers...,
+		)
+		monitor.Run(ctx)
+	}
+
+	var requestJournal *journal.Journal
+	if cfg.Journal.Enabled {
+		requestJournal, err = journal.Open(cfg.Journal.Path, cfg.Journal.MaxEntries)
+		if err != nil {
+			log.Error("failed to open request journal", slog.String("err", err.Error()))
+			os.Exit(1)
+		}
+		log.Info("request journal enabled", slog.String("path", cfg.Journal.Path), slog.Int("pending", requestJournal.Len()))
+	}
+
+	analyticsPublisher, err := newAnalyticsPublisher(cfg, upstreamDial, kafkaAuth, log)
+	if err != nil {
+		log.Error("failed to init analytics publisher", slog.String("err", err.Error()))
+		os.Exit(1)
+	}
+	defer analyticsPublisher.Close()
+
+	origins := reload.NewValue(allowedOrigins(cfg))
+	videoHandler := handlers.NewVideoHandler(log, videoClient, cfg.VideoService.Timeout, streamHub, handlers.StreamOptions{
+		SnapshotTimeout: cfg.Stream.SnapshotTimeout,
+		PollInterval:    cfg.Stream.PollInterval,
+		TerminalStages:  cfg.Stream.TerminalStages,
+		StagePath:       cfg.Stream.StagePath,
+		Lag:             streamLag,
+		LagThreshold:    cfg.Stream.LagThreshold,
+		AllowedOrigins:  origins.Load,
+		Media: handlers.MediaStreamOptions{
+			Hub:              mediaHub,
+			UserIDPath:       cfg.MediaStream.UserIDPath,
+			StatusPath:       cfg.MediaStream.StatusPath,
+			TerminalStatuses: cfg.MediaStream.TerminalStatuses,
+		},
+		Logouts: logouts,
+	}, videoMasker, requestJournal, analyticsPublisher)
+	collaborators, err := acl.Open(cfg.Collaborators.Path)
+	if err != nil {
+		log.Error("failed to open collaborators store", slog.String("err", err.Error()))
+		os.Exit(1)
+	}
+	collaboratorHandler := handlers.NewCollaboratorHandler(log, collaborators, videoClient, cfg.VideoService.Timeout)
+	videoBatchHandler := handlers.NewVideoBatchHandler(log, videoClient, collaborators, videoMasker, cfg.VideoService.Timeout, cfg.BatchGet.MaxIDs, cfg.BatchGet.Concurrency)
+	overviewHandler := handlers.NewOverviewHandler(log, authClient, videoClient, scriptClient, videoMasker, scriptMasker, cfg.AuthGRPC.Timeout, cfg.VideoService.Timeout, cfg.ScriptService.Timeout)
+	var graphqlHandler *handlers.GraphQLHandler
+	if cfg.GraphQL.Enabled {
+		graphqlHandler = handlers.NewGraphQLHandler(log, authClient, videoClient, scriptClient, videoMasker, scriptMasker, cfg.GraphQL.MaxDepth, cfg.GraphQL.MaxRootFields, cfg.AuthGRPC.Timeout, cfg.VideoService.Timeout, cfg.ScriptService.Timeout)
+	}
+	passthroughHandler, err := handlers.NewPassthroughHandler(log, scriptClient, videoClient, scriptMasker, videoMasker, passthroughRoutes(cfg), cfg.Passthrough.MaxBody, cfg.ScriptService.Timeout, cfg.VideoService.Timeout)
+	if err != nil {
+		log.Error("invalid passthrough routes", slog.String("err", err.Error()))
+		os.Exit(1)
+	}
+	adminHandler := handlers.NewAdminHandler(log, authClient, videoClient, scriptClient, cfg.AuthGRPC.Timeout, videoMasker, scriptMasker, cfg.AppSecret, cfg.Impersonation.TTL, secretStore, handlers.ExportOptions{
+		PageSize: cfg.Exports.PageSize,
+		MaxPages: cfg.Exports.MaxPages,
+		Prefetch: cfg.Exports.Prefetch,
+	}, revoked)
+	searchHandler := handlers.NewSearchHandler(log, videoClient, handlers.SuggestOptions{
+		Timeout:   cfg.Search.Timeout,
+		Debounce:  cfg.Search.Debounce,
+		CacheTTL:  cfg.Search.CacheTTL,
+		MinLength: cfg.Search.MinLength,
+		Limit:     cfg.Search.Limit,
+	}, videoMasker)
+	syncHandler := handlers.NewSyncHandler(log, videoClient, scriptClient, changeLog, cfg.Sync.Timeout, videoMasker, scriptMasker)
+	var webhookHandler *handlers.WebhookHandler
+	if webhookStore != nil {
+		webhookHandler = handlers.NewWebhookHandler(log, webhookStore, webhookDispatcher, webhookEvents, cfg.Webhooks.AllowInsecure, cfg.Webhooks.AllowPrivate)
+	}
+	jwksURL := cfg.JWKS.URL
+	var discovery *oidc.Discovery
+	if cfg.OIDC.Issuer != "" {
+		discovery, err = oidc.New(oidc.Config{
+			Issuer:          cfg.OIDC.Issuer,
+			RefreshInterval: cfg.OIDC.RefreshInterval,
+			Timeout:         cfg.OIDC.Timeout,
+		}, upstreamTransport, log)
+		if err != nil {
+			log.Error("failed to init oidc discovery", slog.String("err", err.Error()))
+			os.Exit(1)
+		}
+		doc, err := discovery.Fetch(ctx)
+		if err != nil {
+			log.Error("oidc discovery failed", slog.String("issuer", cfg.OIDC.Issuer), slog.String("err", err.Error()))
+		} else if jwksURL == "" {
+			jwksURL = doc.JWKSURI
+		}
+		discovery.Run(ctx)
+	}
+	var keySet *jwks.KeySet
+	if jwksURL != "" && cfg.AuthGRPC.Standalone {
+		// The fake signs user tokens with APP_SECRET, which keys would reject.
+		log.Warn("jwks ignored in standalone auth mode", slog.String("url", jwksURL))
+		jwksURL = ""
+	}
+	if jwksURL != "" {
+		keySet, err = jwks.New(jwks.Config{
+			URL:                jwksURL,
+			RefreshInterval:    cfg.JWKS.RefreshInterval,
+			MinRefreshInterval: cfg.JWKS.MinRefreshInterval,
+			Timeout:            cfg.JWKS.Timeout,
+		}, upstreamTransport, log)
+		if err != nil {
+			log.Error("failed to init jwks", slog.String("err", err.Error()))
+			os.Exit(1)
+		}
+		keySet.Run(ctx)
+	}
+	appSecret := reload.NewValue(cfg.AppSecret)
+	authMiddleware := middleware.AuthMiddleware(appSecret, keySet, revoked)
+	authIdentify := middleware.Identify(appSecret, keySet, revoked)
+	authConfigHandler := handlers.NewAuthConfigHandler(authConfigOptions(cfg, keySet != nil, discovery))
+	adminMiddleware := middleware.AdminOnly(authClient, cfg.AuthGRPC.Timeout)
+	uploadLimiter := middleware.NewUploadLimiter(middleware.UploadLimitConfig{
+		MaxConcurrent: cfg.Uploads.MaxConcurrentPerUser,
+		MaxQueued:     cfg.Uploads.MaxQueuedPerUser,
+		QueueTimeout:  cfg.Uploads.QueueTimeout,
+	})
+
+	var billingClient *entitlements.Client
+	if cfg.Entitlements.BaseURL != "" {
+		billingClient, err = entitlements.New(cfg.Entitlements.BaseURL, cfg.Entitlements.Timeout, timing.Transport("entitlements", upstreamTransport))
+		if err != nil {
+			log.Error("failed to init entitlements client", slog.String("err", err.Error()))
+			os.Exit(1)
+		}
+	}
+	planEntitlements := middleware.NewEntitlements(billingClient, middleware.EntitlementsConfig{
+		DefaultPlan: cfg.Entitlements.DefaultPlan,
+		Plans:       cfg.Entitlements.Plans,
+		Gates:       cfg.Entitlements.Gates,
+		CacheTTL:    cfg.Entitlements.CacheTTL,
+		Timeout:     cfg.Entitlements.Timeout,
+	}, log)
+	var flagProvider flags.Provider
+	switch cfg.FeatureFlags.Provider {
+	case flagsConfig:
+	case flagsOFREP:
+		client, err := ofrep.New(cfg.FeatureFlags.URL, cfg.FeatureFlags.APIKey, cfg.FeatureFlags.Timeout, timing.Transport("flags", upstreamTransport))
+		if err != nil {
+			log.Error("failed to init feature flag provider", slog.String("err", err.Error()))
+			os.Exit(1)
+		}
+		flagProvider = client
+	default:
+		log.Error("unknown feature flag provider", slog.String("provider", cfg.FeatureFlags.Provider))
+		os.Exit(1)
+	}
+	featureFlags := flags.New(flagDefinitions(cfg), flagProvider, flags.Options{
+		Timeout:  cfg.FeatureFlags.Timeout,
+		CacheTTL: cfg.FeatureFlags.CacheTTL,
+	}, log)
+	flagsHandler := handlers.NewFlagsHandler(featureFlags)
+
+	requestPriority := middleware.Priority(middleware.PriorityConfig{
+		Default: cfg.Priority.Default,
+		Routes:  cfg.Priority.Routes,
+		Plans:   cfg.Priority.Plans,
+	})
+
+	ideaQueue := middleware.NewRequestQueue(middleware.RequestQueueConfig{
+		MaxConcurrent: cfg.IdeaQueue.MaxConcurrent,
+		MaxQueued:     cfg.IdeaQueue.MaxQueued,
+		MaxWait:       cfg.IdeaQueue.MaxWait,
+	})
+
+	llmBudget := middleware.NewDailyBudget(middleware.BudgetConfig{
+		DefaultPlan: cfg.LLMBudget.DefaultPlan,
+		Plans:       cfg.LLMBudget.Plans,
+	})
+
+	videoLimits, err := middleware.VideoLimits(videoLimitsConfig(cfg.VideoLimits))
+	if err != nil {
+		log.Error("invalid video limits", slog.String("err", err.Error()))
+		os.Exit(1)
+	}
+
+	validator, err := middleware.NewJSONValidator(cfg.Validation.Schemas)
+	if err != nil {
+		log.Error("failed to load request schemas", slog.String("err", err.Error()))
+		os.Exit(1)
+	}
+
+	apiVersions, err := apiversion.New(apiVersionSchedules(cfg), cfg.APIVersions.Default)
+	if err != nil {
+		log.Error("invalid api versions", slog.String("err", err.Error()))
+		os.Exit(1)
+	}
+
+	var openapiInfo *openapi.Info
+	if cfg.OpenAPI.Enabled {
+		requestSchemas, err := openapi.LoadSchemas(cfg.Validation.Schemas)
+		if err != nil {
+			log.Error("failed to load request schemas", slog.String("err", err.Error()))
+			os.Exit(1)
+		}
+		openapiInfo = &openapi.Info{
+			Title:          cfg.OpenAPI.Title,
+			Version:        cfg.OpenAPI.Version,
+			ServerURL:      cfg.OpenAPI.ServerURL,
+			RequestSchemas: requestSchemas,
+			Prefixes:       []string{"/api/auth", "/api/scripts", "/api/videos", "/api/ideas"},
+		}
+	}
+
+	var auditLog *audit.Logger
+	if cfg.Audit.Sink != "" {
+		sink, err := newAuditSink(cfg, upstreamDial, kafkaAuth, upstreamTransport)
+		if err != nil {
+			log.Error("failed to init audit sink", slog.String("sink", cfg.Audit.Sink), slog.String("err", err.Error()))
+			os.Exit(1)
+		}
+		auditLog = audit.NewLogger(sink, cfg.Audit.Buffer, log)
+		defer auditLog.Close()
+		log.Info("audit log enabled", slog.String("sink", cfg.Audit.Sink))
+	}
+
+	receiptKey := cfg.Receipts.SigningKey
+	if receiptKey == "" {
+		receiptKey = cfg.AppSecret
+	}
+	downloadReceipts := audit.NewReceipts(store.WithPrefix(gatewayStore, "receipts:"), cfg.Receipts.KeyID, []byte(receiptKey), cfg.Receipts.Retention, auditLog)
+	receiptHandler := handlers.NewReceiptHandler(log, downloadReceipts, collaboratorHandler)
+
+	var errorReporter *errreport.Reporter
+	if cfg.Sentry.DSN != "" {
+		environment := cfg.Sentry.Environment
+		if environment == "" {
+			environment = cfg.Env
+		}
+		errorReporter, err = errreport.New(errreport.Config{
+			DSN:         cfg.Sentry.DSN,
+			Environment: environment,
+			Release:     cfg.Sentry.Release,
+			Timeout:     cfg.Sentry.Timeout,
+			Buffer:      cfg.Sentry.Buffer,
+		}, upstreamTransport, log)
+		if err != nil {
+			log.Error("failed to init error reporting", slog.String("err", err.Error()))
+			os.Exit(1)
+		}
+		defer errorReporter.Close()
+		log.Info("error reporting enabled")
+	}
+
+	var accessLog *accesslog.Logger
+	if cfg.AccessLog.Enabled {
+		accessLog, err = newAccessLog(cfg.AccessLog)
+		if err != nil {
+			log.Error("failed to init access log", slog.String("output", cfg.AccessLog.Output), slog.String("err", err.Error()))
+			os.Exit(1)
+		}
+		defer accessLog.Close()
+		log.Info("access log enabled", slog.String("output", cfg.AccessLog.Output), slog.String("format", cfg.AccessLog.Format))
+	}
+
+	var clientErrorHandler *handlers.ClientErrorHandler
+	if cfg.ClientErrors.Enabled {
+		var tracker clienterrors.Tracker = clienterrors.NewLogTracker(log)
+		if cfg.ClientErrors.TrackerURL != "" {
+			tracker, err = clienterrors.NewHTTPTracker(cfg.ClientErrors.TrackerURL, cfg.ClientErrors.TrackerToken, cfg.ClientErrors.Timeout, upstreamTransport)
+			if err != nil {
+				log.Error("failed to init client errors tracker", slog.String("err", err.Error()))
+				os.Exit(1)
+			}
+		}
+		forwarder := clienterrors.NewForwarder(tracker, cfg.ClientErrors.Buffer, log)
+		defer forwarder.Close()
+		clientErrorHandler = handlers.NewClientErrorHandler(forwarder, cfg.ClientErrors.MaxBodyBytes, cfg.ClientErrors.MaxReports)
+	}
+
+	var demoHandler *handlers.DemoHandler
+	if cfg.Demo.Enabled {
+		secret := cfg.Demo.Secret
+		if secret == "" {
+			secret = cfg.AppSecret
+		}
+		if secret == "" {
+			// Device cookies signed with an empty key could be forged.
+			log.Error("demo enabled without demo.secret or app_secret")
+			os.Exit(1)
+		}
+		demoHandler = handlers.NewDemoHandler(log, videoClient, videoMasker, handlers.DemoOptions{
+			Secret:        secret,
+			CookieName:    cfg.Demo.CookieName,
+			TTL:           cfg.Demo.TTL,
+			JobsPerDevice: cfg.Demo.JobsPerDevice,
+			JobsPerIP:     cfg.Demo.JobsPerIP,
+			IPWindow:      cfg.Demo.IPWindow,
+			UserID:        cfg.Demo.UserID,
+			SecureCookie:  cfg.Demo.SecureCookie,
+			Timeout:       cfg.VideoService.Timeout,
+		})
+	}
+	var loginGuard *middleware.LoginGuard
+	if cfg.LoginGuard.Enabled {
+		var verifier middleware.CaptchaVerifier
+		if cfg.LoginGuard.Captcha.VerifyURL != "" {
+			verifier, err = captcha.New(cfg.LoginGuard.Captcha.VerifyURL, cfg.LoginGuard.Captcha.Secret, cfg.LoginGuard.Captcha.Timeout, upstreamTransport)
+			if err != nil {
+				log.Error("failed to init captcha client", slog.String("err", err.Error()))
+				os.Exit(1)
+			}
+		}
+		loginGuard = middleware.NewLoginGuard(store.WithPrefix(gatewayStore, "login_guard:"), verifier, middleware.LoginGuardConfig{
+			FreeAttempts:      cfg.LoginGuard.FreeAttempts,
+			EmailFreeAttempts: cfg.LoginGuard.EmailFreeAttempts,
+			BaseLockout:       cfg.LoginGuard.BaseLockout,
+			MaxLockout:        cfg.LoginGuard.MaxLockout,
+			Window:            cfg.LoginGuard.Window,
+			CaptchaAfter:      cfg.LoginGuard.CaptchaAfter,
+		}, log)
+	}
+
+	var idempotency *middleware.Idempotency
+	if cfg.Idempotency.Enabled {
+		idempotency = middleware.NewIdempotency(store.WithPrefix(gatewayStore, "idempotency:"), middleware.IdempotencyConfig{
+			TTL:         cfg.Idempotency.TTL,
+			LockTimeout: cfg.Idempotency.LockTimeout,
+		}, log)
+	}
+
+	maintenanceSwitch := maintenance.New(store.WithPrefix(gatewayStore, "maintenance:"), maintenanceConfig(cfg), log)
+	maintenanceSwitch.Run(ctx, cfg.Maintenance.RefreshInterval)
+	maintenanceHandler := handlers.NewMaintenanceHandler(log, maintenanceSwitch)
+
+	errorRates := health.NewErrorRates(cfg.StatusPage.ErrorWindow)
+	statusComponents := make([]handlers.StatusComponent, len(cfg.StatusPage.Components))
+	for i, component := range cfg.StatusPage.Components {
+		statusComponents[i] = handlers.StatusComponent(component)
+	}
+	statusHandler := handlers.NewStatusHandler(monitor, errorRates, maintenanceSwitch, handlers.StatusPageOptions{
+		Components:        statusComponents,
+		CacheTTL:          cfg.StatusPage.CacheTTL,
+		MinRequests:       cfg.StatusPage.MinRequests,
+		DegradedErrorRate: cfg.StatusPage.DegradedErrorRate,
+		OutageErrorRate:   cfg.StatusPage.OutageErrorRate,
+	})
+
+	reloader := reload.New(cfg, func() (*config.Config, error) {
+		if err := reloadDotenv(processEnv); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("read .env: %w", err)
+		}
+		return config.Reload()
+	})
+	reloader.OnReload(func(next *config.Config) {
+		origins.Store(allowedOrigins(next))
+		authHandler.SetTimeout(next.AuthGRPC.Timeout)
+		adminHandler.SetTimeout(next.AuthGRPC.Timeout)
+		// Tokens signed with the previous secret stop verifying right away.
+		if next.AppSecret == "" {
+			log.Warn("app_secret is empty after reload, keeping the current one")
+		} else {
+			appSecret.Store(next.AppSecret)
+			adminHandler.SetSecret(next.AppSecret)
+			if standaloneAuth != nil {
+				standaloneAuth.SetSecret(next.AppSecret)
+			}
+		}
+		scriptHandler.SetTimeout(next.ScriptService.Timeout)
+		scriptClient.SetTimeout(clientTimeout(next.ScriptService.Timeout, next.Routes.Timeouts))
+		videoHandler.SetTimeout(next.VideoService.Timeout)
+		collaboratorHandler.SetTimeout(next.VideoService.Timeout)
+		videoClient.SetTimeout(clientTimeout(next.VideoService.Timeout, next.Routes.Timeouts))
+		syncHandler.SetTimeout(next.Sync.Timeout)
+		maintenanceSwitch.Set(maintenanceConfig(next))
+		analyticsPublisher.SetEnabled(next.Analytics.Enabled)
+		featureFlags.Set(flagDefinitions(next))
+		passthroughHandler.SetTimeouts(next.ScriptService.Timeout, next.VideoService.Timeout)
+		videoBatchHandler.SetTimeout(next.VideoService.Timeout)
+		overviewHandler.SetTimeouts(next.AuthGRPC.Timeout, next.VideoService.Timeout, next.ScriptService.Timeout)
+		if graphqlHandler != nil {
+			graphqlHandler.SetTimeouts(next.AuthGRPC.Timeout, next.VideoService.Timeout, next.ScriptService.Timeout)
+		}
+		if err := passthroughHandler.SetRoutes(passthroughRoutes(next)); err != nil {
+			log.Warn("passthrough routes not reloaded", slog.String("err", err.Error()))
+		}
+		if err := kafkaAuth.Set(next.Kafka.SASL); err != nil {
+			log.Warn("kafka credentials not rotated", slog.String("err", err.Error()))
+		}
+	})
+
+	router, err := setupRouter(cfg, authHandler, authConfigHandler, scriptHandler, videoHandler, searchHandler, usageHandler, creditsHandler, syncHandler, webhookHandler, clientErrorHandler, demoHandler, statusHandler, adminHandler, collaboratorHandler, maintenanceHandler, flagsHandler, receiptHandler, passthroughHandler, graphqlHandler, overviewHandler, videoBatchHandler, monitor, authMiddleware, authIdentify, planEntitlements.Middleware(), middleware.FeatureFlags(featureFlags), requestPriority, adminMiddleware, middleware.CollaboratorAccess(collaborators), uploadLimiter.Middleware(), ideaQueue.Middleware(), loginGuard.Middleware(), idempotency.Middleware(), llmBudget, videoLimits, usageMeter, validator, auditLog, middleware.AccessLog(accessLog, log), errorReporter, origins, reloader, middleware.Maintenance(maintenanceSwitch), middleware.TrackErrorRates(errorRates), middleware.DownloadReceipt(downloadReceipts, log), openapiInfo, apiVersions)
+	if err != nil {
+		log.Error("invalid http.trusted_proxies", slog.String("err", err.Error()))
+		os.Exit(1)
+	}
+
+	if cfg.Reload.Enabled {
+		watched := []string{".env"}
+		if config.Path() != "" {
+			watched = append(watched, config.Path())
+		}
+		go reload.Watch(ctx, watched, cfg.Reload.Interval, func() {
+			applyReload(reloader, auditLog, log)
+		})
+		log.Info("config hot reload enabled", slog.String("path", config.Path()), slog.Duration("interval", cfg.Reload.Interval))
+	}
+	if cfg.Secrets.RefreshInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(cfg.Secrets.RefreshInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					applyReload(reloader, auditLog, log)
+				}
+			}
+		}()
+	}
+
+	srv := &http.Server{
+		Addr:         fmt.Sprintf("%s:%d", cfg.HTTP.Host, cfg.HTTP.Port),
+		Handler:      router,
+		ReadTimeout:  cfg.HTTP.ReadTimeout,
+		WriteTimeout: cfg.HTTP.WriteTimeout,
+		IdleTimeout:  cfg.HTTP.IdleTimeout,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Error("server shutdown error", slog.String("err", err.Error()))
+		}
+	}()
+
+	log.Info("http server listening", slog.String("addr", srv.Addr))
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Error("server stopped", slog.String("err", err.Error()))
+	}
+}
+
+const (
+	usageMemory = "memory"
+	usageRedis  = "redis"
+	usageShared = "store"
+)
+
+const (
+	storeMemory = "memory"
+	storeRedis  = "redis"
+	storeSQLite = "sqlite"
+)
+
+func newMasterKeys(cfg config.EncryptionConfig) (*store.MasterKeys, error) {
+	keys := make(map[string][]byte, len(cfg.Keys))
+	for id, encoded := range cfg.Keys {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("master key %q is not base64: %w", id, err)
+		}
+		keys[id] = key
+	}
+	return store.NewMasterKeys(cfg.PrimaryKey, keys)
+}
+
+// newStore opens the store shared by the stateful gateway features.
+func newStore(cfg *config.Config) (store.Store, error) {
+	switch cfg.Store.Driver {
+	case storeMemory:
+		return store.NewMemory(), nil
+	case storeRedis:
+		return store.NewRedis(store.RedisConfig{
+			Addr:     cfg.Redis.Addr,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+			Prefix:   cfg.Store.Prefix,
+		})
+	case storeSQLite:
+		return store.NewSQLite(cfg.Store.Path)
+	default:
+		return nil, fmt.Errorf("unknown store driver %q", cfg.Store.Driver)
+	}
+}
+
+const revocationRedis = "redis"
+
+// newRevocationList shares revocations through Redis, or keeps them local
+// when no bus is configured.
+func newRevocationList(cfg *config.Config, log *slog.Logger) (*revocation.List, error) {
+	ttl := max(cfg.Revocation.TTL, cfg.TokenTTL)
+	switch cfg.Revocation.Bus {
+	case "":
+		return revocation.New(ttl, nil, log), nil
+	case revocationRedis:
+		bus, err := revocation.NewRedisBus(revocation.RedisBusConfig{
+			Addr:     cfg.Redis.Addr,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+			Channel:  cfg.Revocation.Channel,
+			TTL:      ttl,
+		}, log)
+		if err != nil {
+			return nil, err
+		}
+		return revocation.New(ttl, bus, log), nil
+	default:
+		return nil, fmt.Errorf("unknown revocation bus %q", cfg.Revocation.Bus)
+	}
+}
+
+const (
+	auditFile    = "file"
+	auditKafka   = "kafka"
+	auditWebhook = "webhook"
+)
+
+const (
+	flagsConfig = "config"
+	flagsOFREP  = "ofrep"
+)
+
+const (
+	eventsKafka = "kafka"
+	eventsNATS  = "nats"
+	eventsRedis = "redis"
+)
+
+// Kafka consumption modes: replicas share one consumer group and each sees
+// part of the updates, or each replica has its own group and sees them all.
+const (
+	kafkaGroup     = "group"
+	kafkaBroadcast = "broadcast"
+)
+
+// replicaID names this gateway instance, the host name by default.
+func replicaID(cfg *config.Config) string {
+	if cfg.Replica.ID != "" {
+		return cfg.Replica.ID
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		return "gateway"
+	}
+	return host
+}
+
+func eventsBackend(cfg *config.Config) string {
+	if cfg.Events.Backend != "" {
+		return cfg.Events.Backend
+	}
+	if cfg.Kafka.Enabled {
+		return eventsKafka
+	}
+	return ""
+}
+
+// upstreamConfig is the protocol and TLS identity of the connections to an
+// upstream.
+func upstreamConfig(cfg config.UpstreamHTTPConfig) egress.UpstreamConfig {
+	return egress.UpstreamConfig{
+		Protocol:   cfg.Protocol,
+		CAFile:     cfg.CAFile,
+		CertFile:   cfg.CertFile,
+		KeyFile:    cfg.KeyFile,
+		ServerName: cfg.ServerName,
+	}
+}
+
+func rewriteRules(rewrites []config.RewriteConfig) []egress.RewriteRule {
+	rules := make([]egress.RewriteRule, 0, len(rewrites))
+	for _, r := range rewrites {
+		rules = append(rules, egress.RewriteRule{Prefix: r.Prefix, Regex: r.Regex, Replace: r.Replace})
+	}
+	return rules
+}
+
+// withDiscovery balances the requests to baseURL over the instances found
+// by the upstream's discovery mode. consul carries the Consul API requests.
+func withDiscovery(ctx context.Context, name, baseURL string, cfg config.UpstreamDiscoveryConfig, discovery config.DiscoveryConfig, transport, consul http.RoundTripper, log *slog.Logger) (http.RoundTripper, error) {
+	var source egress.Source
+	switch cfg.Mode {
+	case "":
+		return transport, nil
+	case "static":
+		if len(cfg.Endpoints) == 0 {
+			return nil, fmt.Errorf("static discovery needs endpoints")
+		}
+		source = egress.StaticSource(cfg.Endpoints)
+	case "dns_srv":
+		if cfg.Name == "" {
+			return nil, fmt.Errorf("dns_srv discovery needs the record name")
+		}
+		source = egress.SRVSource{Name: cfg.Name, Scheme: cfg.Scheme}
+	case "consul":
+		if cfg.Name == "" {
+			return nil, fmt.Errorf("consul discovery needs the service name")
+		}
+		source = egress.ConsulSource{
+			Client: &http.Client{Timeout: discovery.Timeout, Transport: consul},
+			Consul: egress.ConsulConfig{
+				Address:    discovery.ConsulAddress,
+				Token:      discovery.ConsulToken,
+				Datacenter: discovery.ConsulDatacenter,
+			},
+			Service: cfg.Name,
+			Scheme:  cfg.Scheme,
+		}
+	default:
+		return nil, fmt.Errorf("unknown discovery mode %q (want static, dns_srv or consul)", cfg.Mode)
+	}
+	b, err := egress.NewBalancer(name, baseURL, source, egress.BalancerConfig{
+		Policy:          cfg.Policy,
+		RefreshInterval: cfg.RefreshInterval,
+		RefreshTimeout:  discovery.Timeout,
+		MaxFailures:     cfg.MaxFailures,
+		EjectDuration:   cfg.EjectDuration,
+	}, transport, log)
+	if err != nil {
+		return nil, err
+	}
+	b.Run(ctx)
+	log.Info("upstream discovery enabled", slog.String("upstream", name), slog.String("mode", cfg.Mode), slog.String("policy", cfg.Policy))
+	return b, nil
+}
 
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+func withCanary(name, baseURL string, cfg config.UpstreamCanaryConfig, transport http.RoundTripper, log *slog.Logger) (http.RoundTripper, error) {
+	if cfg.BaseURL == "" {
+		return transport, nil
+	}
+	canary, err := egress.NewCanary(name, baseURL, cfg.BaseURL, cfg.Weight, transport)
+	if err != nil {
+		return nil, err
+	}
+	log.Info("upstream canary enabled", slog.String("upstream", name), slog.Float64("weight", cfg.Weight))
+	return canary, nil
+}
+
+// withRegions spreads the calls to baseURL over the upstream's regions and
+// starts probing them; without regions, transport is returned unchanged.
+func withRegions(ctx context.Context, name, baseURL, healthPath string, regions []config.RegionConfig, cfg config.RegionsConfig, transport http.RoundTripper, log *slog.Logger) (http.RoundTripper, error) {
+	if len(regions) == 0 {
+		return transport, nil
+	}
+	tagged := make([]egress.Region, 0, len(regions))
+	for _, region := range regions {
+		tagged = append(tagged, egress.Region{Name: region.Name, BaseURL: region.BaseURL})
+	}
+	r, err := egress.NewRegions(name, baseURL, tagged, egress.RegionsConfig{
+		HealthPath:    healthPath,
+		ProbeInterval: cfg.ProbeInterval,
+		ProbeTimeout:  cfg.ProbeTimeout,
+		Preferred:     cfg.Preferred,
+		HintHeader:    cfg.HintHeader,
+	}, transport, log)
+	if err != nil {
+		return nil, err
+	}
+	r.Run(ctx)
+	log.Info("multi-region upstream", slog.String("upstream", name), slog.Int("regions", len(regions)))
+	return r, nil
+}
+
+// kafkaDialer returns the egress proxy dialer for broker connections when
+// egress.kafka is set, nil otherwise.
+func kafkaDialer(cfg *config.Config, dial egress.DialFunc) (egress.DialFunc, error) {
+	if !cfg.Egress.Kafka || cfg.Egress.ProxyURL == "" {
+		return nil, nil
+	}
+	return egress.Dialer(egress.ProxyConfig{URL: cfg.Egress.ProxyURL, NoProxy: cfg.Egress.NoProxy}, dial)
+}
+
+// withMocks answers the mocked routes of service instead of transport. A
+// mocked service's health path always reports healthy.
+func withMocks(cfg *config.Config, service, baseURL, healthPath string, transport http.RoundTripper) (http.RoundTripper, error) {
+	if !cfg.Mocks.Enabled || cfg.Env == envProd {
+		return transport, nil
+	}
+	var routes []egress.MockRoute
+	for _, route := range cfg.Mocks.Routes {
+		if route.Service != service {
+			continue
+		}
+		body := []byte(route.Body)
+		if route.BodyFile != "" {
+			var err error
+			if body, err = os.ReadFile(route.BodyFile); err != nil {
+				return nil, fmt.Errorf("mock %s %s: %w", route.Method, route.Path, err)
+			}
+		}
+		routes = append(routes, egress.MockRoute{
+			Method:  route.Method,
+			Path:    route.Path,
+			Status:  route.Status,
+			Latency: route.Latency,
+			Headers: route.Headers,
+			Body:    body,
+		})
+	}
+	if len(routes) == 0 {
+		return transport, nil
+	}
+	if healthPath != "" {
+		routes = append(routes, egress.MockRoute{Method: http.MethodGet, Path: healthPath, Body: []byte(`{"status":"ok"}`)})
+	}
+	return egress.NewMocks(baseURL, routes, transport)
+}
+
+func kafkaTLS(cfg *config.Config) *tls.Config {
+	if !cfg.Kafka.TLS {
+		return nil
+	}
+	return &tls.Config{MinVersion: tls.VersionTLS12}
+}
+
+const (
+	saslPlain       = "plain"
+	saslSCRAMSHA256 = "scram-sha-256"
+	saslSCRAMSHA512 = "scram-sha-512"
 )
 
-func main() {
-	dotenvErr := godotenv.Load(".env")
-	cfg := config.MustLoad()
-	log := setupLogger(cfg.Env)
-	log.Info("starting api gateway")
-	if dotenvErr != nil {
-		log.Warn(".env not loaded", slog.String("err", dotenvErr.Error()))
+// kafkaCredentials is the SASL mechanism of the broker connections. It is
+// rebuilt from every reloaded config, so rotated credentials are used by the
+// connections opened afterwards.
+type kafkaCredentials struct {
+	name    string
+	current reload.Value[sasl.Mechanism]
+}
+
+// newKafkaCredentials returns nil when SASL isn't configured.
+func newKafkaCredentials(cfg config.KafkaSASLConfig) (*kafkaCredentials, error) {
+	if cfg.Mechanism == "" {
+		return nil, nil
+	}
+	k := &kafkaCredentials{}
+	if err := k.Set(cfg); err != nil {
+		return nil, err
 	}
+	k.name = k.current.Load().Name()
+	return k, nil
+}
 
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-	defer stop()
+// Set switches to new credentials; the mechanism itself can't change.
+func (k *kafkaCredentials) Set(cfg config.KafkaSASLConfig) error {
+	if k == nil {
+		return nil
+	}
+	var mechanism sasl.Mechanism
+	switch strings.ToLower(cfg.Mechanism) {
+	case saslPlain:
+		mechanism = plain.Mechanism{Username: cfg.Username, Password: cfg.Password}
+	case saslSCRAMSHA256, saslSCRAMSHA512:
+		algorithm := scram.SHA256
+		if strings.EqualFold(cfg.Mechanism, saslSCRAMSHA512) {
+			algorithm = scram.SHA512
+		}
+		var err error
+		if mechanism, err = scram.Mechanism(algorithm, cfg.Username, cfg.Password); err != nil {
+			return fmt.Errorf("kafka sasl: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown kafka sasl mechanism %q", cfg.Mechanism)
+	}
+	if k.name != "" && mechanism.Name() != k.name {
+		return fmt.Errorf("kafka sasl mechanism can't change from %s to %s without a restart", k.name, mechanism.Name())
+	}
+	k.current.Store(mechanism)
+	return nil
+}
 
-	authConn, err := grpc.DialContext(ctx, cfg.AuthGRPC.Address, grpc.WithTransportCredentials(insecure.NewCredentials()))
-	if err != nil {
-		log.Error("failed to connect auth grpc", slog.String("err", err.Error()))
-		os.Exit(1)
+func (k *kafkaCredentials) Name() string {
+	return k.name
+}
+
+func (k *kafkaCredentials) Start(ctx context.Context) (sasl.StateMachine, []byte, error) {
+	return k.current.Load().Start(ctx)
+}
+
+// mechanism returns k as a sasl.Mechanism, nil when SASL is off.
+func (k *kafkaCredentials) mechanism() sasl.Mechanism {
+	if k == nil {
+		return nil
+	}
+	return k
+}
+
+func videoLimitsConfig(cfg config.VideoLimitsConfig) middleware.VideoLimitsConfig {
+	plans := make(map[string]middleware.VideoLimit, len(cfg.Plans))
+	for plan, limit := range cfg.Plans {
+		plans[plan] = middleware.VideoLimit{MaxDuration: limit.MaxDuration, MaxResolution: limit.MaxResolution}
+	}
+	return middleware.VideoLimitsConfig{
+		DefaultPlan:    cfg.DefaultPlan,
+		DurationPath:   cfg.DurationPath,
+		ResolutionPath: cfg.ResolutionPath,
+		Plans:          plans,
+	}
+}
+
+func uploadRule(cfg config.UploadRuleConfig) middleware.UploadRule {
+	return middleware.UploadRule{Types: cfg.Types, Extensions: cfg.Extensions, MaxBytes: cfg.MaxBytes}
+}
+
+func newUsageStore(cfg *config.Config, shared store.Store) (usage.Store, error) {
+	switch cfg.Usage.Store {
+	case usageShared:
+		return usage.NewKVStore(store.WithPrefix(shared, "usage:")), nil
+	case usageMemory:
+		return usage.NewMemoryStore(), nil
+	case usageRedis:
+		return usage.NewRedisStore(usage.RedisStoreConfig{
+			Addr:     cfg.Redis.Addr,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+		})
+	default:
+		return nil, fmt.Errorf("unknown usage store %q", cfg.Usage.Store)
 	}
-	defer authConn.Close()
+}
 
-	authClient := authv1.NewAuthServiceClient(authConn)
+const (
+	accessLogFile   = "file"
+	accessLogStdout = "stdout"
+	accessLogSyslog = "syslog"
+	accessLogSocket = "socket"
+)
 
-	scriptClient, err := scripts.New(cfg.ScriptService.BaseURL, cfg.ScriptService.Timeout)
+func newAccessLog(cfg config.AccessLogConfig) (*accesslog.Logger, error) {
+	var out io.WriteCloser
+	switch cfg.Output {
+	case accessLogFile:
+		file, err := accesslog.NewRotatingFile(cfg.Path, accesslog.RotationConfig{
+			MaxSize:    cfg.MaxSizeMB << 20,
+			MaxAge:     cfg.MaxAge,
+			MaxBackups: cfg.MaxBackups,
+		})
+		if err != nil {
+			return nil, err
+		}
+		out = file
+	case accessLogStdout:
+		out = accesslog.Stdout()
+	case accessLogSyslog:
+		syslog, err := accesslog.NewSyslog(cfg.SyslogNetwork, cfg.SyslogAddr, cfg.SyslogTag)
+		if err != nil {
+			return nil, err
+		}
+		out = syslog
+	case accessLogSocket:
+		socket, err := accesslog.NewSocket(cfg.SocketNetwork, cfg.SocketAddr)
+		if err != nil {
+			return nil, err
+		}
+		out = socket
+	default:
+		return nil, fmt.Errorf("unknown access log output %q", cfg.Output)
+	}
+	logger, err := accesslog.New(out, cfg.Format, accesslog.Options{Fields: cfg.Fields, Buffer: cfg.Buffer})
 	if err != nil {
-		log.Error("failed to init script client", slog.String("err", err.Error()))
-		os.Exit(1)
+		out.Close()
+		return nil, err
+	}
+	return logger, nil
+}
+
+func newAuditSink(cfg *config.Config, dial egress.DialFunc, kafkaAuth *kafkaCredentials, transport http.RoundTripper) (audit.Sink, error) {
+	switch cfg.Audit.Sink {
+	case auditFile:
+		return audit.NewFileSink(cfg.Audit.Path)
+	case auditKafka:
+		kafkaDial, err := kafkaDialer(cfg, dial)
+		if err != nil {
+			return nil, err
+		}
+		return audit.NewKafkaSink(audit.KafkaSinkConfig{
+			Brokers: cfg.Kafka.Brokers,
+			Topic:   cfg.Audit.Topic,
+			Dial:    kafkaDial,
+			SASL:    kafkaAuth.mechanism(),
+			TLS:     kafkaTLS(cfg),
+		})
+	case auditWebhook:
+		return audit.NewWebhookSink(cfg.Audit.WebhookURL, cfg.Audit.WebhookTimeout, transport)
+	default:
+		return nil, fmt.Errorf("unknown audit sink %q", cfg.Audit.Sink)
 	}
+}
 
-	videoClient, err := videos.New(cfg.VideoService.BaseURL, cfg.VideoService.Timeout)
+// newAnalyticsPublisher publishes to the analytics topic whenever brokers are
+// configured, so analytics.enabled can be turned on by a reload; it returns
+// nil without brokers.
+func newAnalyticsPublisher(cfg *config.Config, dial egress.DialFunc, kafkaAuth *kafkaCredentials, log *slog.Logger) (*analytics.Publisher, error) {
+	if len(cfg.Kafka.Brokers) == 0 {
+		if cfg.Analytics.Enabled {
+			return nil, errors.New("analytics requires kafka.brokers")
+		}
+		return nil, nil
+	}
+	kafkaDial, err := kafkaDialer(cfg, dial)
 	if err != nil {
-		log.Error("failed to init video client", slog.String("err", err.Error()))
-		os.Exit(1)
+		return nil, err
 	}
+	publisher, err := analytics.NewPublisher(analytics.Config{
+		Brokers:   cfg.Kafka.Brokers,
+		Topic:     cfg.Analytics.Topic,
+		JobIDPath: cfg.Stream.JobIDPath,
+		Buffer:    cfg.Analytics.Buffer,
+		Dial:      kafkaDial,
+		SASL:      kafkaAuth.mechanism(),
+		TLS:       kafkaTLS(cfg),
+	}, cfg.Analytics.Enabled, log)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Analytics.Enabled {
+		log.Info("analytics publishing enabled", slog.String("topic", cfg.Analytics.Topic))
+	}
+	return publisher, nil
+}
 
-	if cfg.AppSecret == "" {
-		log.Error("APP_SECRET is not configured (set app_secret in config or APP_SECRET env)")
-		os.Exit(1)
+// newKafkaConsumer reads topicCfg's topic from the brokers of the kafka
+// section, in its consumption mode.
+func newKafkaConsumer(cfg *config.Config, topicCfg events.KafkaConsumerConfig, hub *events.Hub, dial egress.DialFunc, kafkaAuth *kafkaCredentials, log *slog.Logger) (*events.KafkaConsumer, error) {
+	if len(cfg.Kafka.Brokers) == 0 {
+		return nil, errors.New("kafka brokers are not configured")
 	}
-	if cfg.TokenTTL <= 0 {
-		log.Error("token_ttl must be greater than zero")
-		os.Exit(1)
+	switch cfg.Kafka.Mode {
+	case "", kafkaGroup:
+	case kafkaBroadcast:
+		topicCfg.GroupID += "-" + replicaID(cfg)
+	default:
+		return nil, fmt.Errorf("unknown kafka mode %q", cfg.Kafka.Mode)
+	}
+	topicCfg.Brokers = cfg.Kafka.Brokers
+	topicCfg.MaxWait = cfg.Kafka.MaxWait
+	topicCfg.StartOffset = cfg.Kafka.StartOffset
+	topicCfg.ManualCommit = cfg.Kafka.ManualCommit
+	topicCfg.SASL = kafkaAuth.mechanism()
+	topicCfg.TLS = kafkaTLS(cfg)
+	kafkaDial, err := kafkaDialer(cfg, dial)
+	if err != nil {
+		return nil, err
 	}
+	topicCfg.Dial = kafkaDial
+	return events.NewKafkaConsumer(topicCfg, hub, log)
+}
 
-	authHandler := handlers.NewAuthHandler(log, authClient, cfg.AuthGRPC.Timeout, cfg.TokenTTL)
-	scriptHandler := handlers.NewScriptHandler(log, scriptClient, cfg.ScriptService.Timeout)
-	var (
-		streamHub     *events.Hub
-		kafkaConsumer *events.KafkaConsumer
-	)
-	if cfg.Kafka.Enabled {
-		if len(cfg.Kafka.Brokers) == 0 {
-			log.Error("kafka brokers are not configured")
-			os.Exit(1)
-		}
-		streamHub = events.NewHub()
-		consumer, err := events.NewKafkaConsumer(
-			events.KafkaConsumerConfig{
-				Brokers: cfg.Kafka.Brokers,
-				Topic:   cfg.Kafka.UpdatesTopic,
-				GroupID: cfg.Kafka.GroupID,
-				MaxWait: cfg.Kafka.MaxWait,
+func newEventSource(backend string, cfg *config.Config, hub *events.Hub, dial egress.DialFunc, kafkaAuth *kafkaCredentials, log *slog.Logger) (events.Source, error) {
+	switch backend {
+	case eventsKafka:
+		return newKafkaConsumer(cfg, events.KafkaConsumerConfig{
+			Topic:           cfg.Kafka.UpdatesTopic,
+			GroupID:         cfg.Kafka.GroupID,
+			DeadLetterTopic: cfg.Kafka.DeadLetterTopic,
+		}, hub, dial, kafkaAuth, log)
+	case eventsNATS:
+		return events.NewNATSSource(
+			events.NATSSourceConfig{
+				URL:     cfg.NATS.URL,
+				Stream:  cfg.NATS.Stream,
+				Subject: cfg.NATS.Subject,
+				Durable: cfg.NATS.Durable,
 			},
-			streamHub,
+			hub,
 			log,
 		)
-		if err != nil {
-			log.Error("failed to init kafka consumer", slog.String("err", err.Error()))
-			os.Exit(1)
-		}
-		kafkaConsumer = consumer
-		kafkaConsumer.Run(ctx)
-		defer kafkaConsumer.Close()
+	case eventsRedis:
+		return events.NewRedisSource(
+			events.RedisSourceConfig{
+				Addr:     cfg.Redis.Addr,
+				Password: cfg.Redis.Password,
+				DB:       cfg.Redis.DB,
+				Channel:  cfg.Redis.UpdatesChannel,
+			},
+			hub,
+			log,
+		)
+	default:
+		return nil, fmt.Errorf("unknown events backend %q", backend)
 	}
+}
 
-	videoHandler := handlers.NewVideoHandler(log, videoClient, cfg.VideoService.Timeout, streamHub)
-	authMiddleware := middleware.AuthMiddleware(cfg.AppSecret)
-
-	router := setupRouter(cfg.Env, authHandler, scriptHandler, videoHandler, authMiddleware)
+func maskingRules(rules []config.MaskingRule) []masking.Rule {
+	res := make([]masking.Rule, 0, len(rules))
+	for _, rule := range rules {
+		res = append(res, masking.Rule{Path: rule.Path, Action: rule.Action})
+	}
+	return res
+}
 
-	srv := &http.Server{
-		Addr:         fmt.Sprintf("%s:%d", cfg.HTTP.Host, cfg.HTTP.Port),
-		Handler:      router,
-		ReadTimeout:  cfg.HTTP.ReadTimeout,
-		WriteTimeout: cfg.HTTP.WriteTimeout,
-		IdleTimeout:  cfg.HTTP.IdleTimeout,
+func authConfigOptions(cfg *config.Config, jwksEnabled bool, discovery *oidc.Discovery) handlers.AuthConfigOptions {
+	opts := handlers.AuthConfigOptions{
+		Mode:              handlers.AuthModeService,
+		TokenVerification: handlers.TokenVerificationSecret,
+		AccessTokenTTL:    cfg.TokenTTL,
+		ImpersonationTTL:  cfg.Impersonation.TTL,
+		OIDC:              discovery,
+		ClientID:          cfg.OIDC.ClientID,
+		Scopes:            cfg.OIDC.Scopes,
+	}
+	if cfg.AuthGRPC.Standalone {
+		opts.Mode = handlers.AuthModeStandalone
 	}
+	if jwksEnabled {
+		opts.TokenVerification = handlers.TokenVerificationJWKS
+	}
+	return opts
+}
 
-	go func() {
-		<-ctx.Done()
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		if err := srv.Shutdown(shutdownCtx); err != nil {
-			log.Error("server shutdown error", slog.String("err", err.Error()))
-		}
-	}()
+func compatOptions(cfg config.CompatConfig) handlers.CompatOptions {
+	opts := handlers.CompatOptions{
+		CompatVersions: handlers.CompatVersions{MinVersion: cfg.MinVersion, LatestVersion: cfg.LatestVersion},
+		Platforms:      make(map[string]handlers.CompatVersions, len(cfg.Platforms)),
+		Capabilities:   cfg.Capabilities,
+	}
+	for name, p := range cfg.Platforms {
+		opts.Platforms[strings.ToLower(name)] = handlers.CompatVersions{MinVersion: p.MinVersion, LatestVersion: p.LatestVersion}
+	}
+	for _, d := range cfg.Deprecations {
+		opts.Notices = append(opts.Notices, handlers.CompatNotice{
+			ID:           d.ID,
+			Message:      d.Message,
+			Routes:       d.Routes,
+			Sunset:       d.Sunset,
+			UntilVersion: d.UntilVersion,
+		})
+	}
+	return opts
+}
 
-	log.Info("http server listening", slog.String("addr", srv.Addr))
-	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-		log.Error("server stopped", slog.String("err", err.Error()))
+// loadStageSchema overrides the configured stage schema with the one published
+// by the video service. Failures keep the static configuration.
+func loadStageSchema(ctx context.Context, client *videos.Client, cfg *config.Config, log *slog.Logger) {
+	reqCtx, cancel := context.WithTimeout(ctx, cfg.VideoService.Timeout)
+	defer cancel()
+	schema, err := client.GetStageSchema(reqCtx, cfg.Stream.SchemaEndpoint)
+	if err != nil {
+		log.Warn("failed to fetch stage schema, using config", slog.String("err", err.Error()))
+		return
+	}
+	if len(schema.TerminalStages) > 0 {
+		cfg.Stream.TerminalStages = schema.TerminalStages
+	}
+	if schema.StagePath != "" {
+		cfg.Stream.StagePath = schema.StagePath
 	}
+	if schema.JobIDPath != "" {
+		cfg.Stream.JobIDPath = schema.JobIDPath
+	}
+	if schema.UserIDPath != "" {
+		cfg.Stream.UserIDPath = schema.UserIDPath
+	}
+	log.Info("stage schema loaded",
+		slog.Any("terminal_stages", cfg.Stream.TerminalStages),
+		slog.String("stage_path", cfg.Stream.StagePath),
+		slog.String("job_id_path", cfg.Stream.JobIDPath),
+		slog.String("user_id_path", cfg.Stream.UserIDPath),
+	)
 }
 
-func requestLogger(log *slog.Logger) gin.HandlerFunc {
+// requestLogger writes one line per request. Its timing group breaks the
+// duration down into the upstream calls and serialization recorded during the
+// request, with the remainder attributed to the gateway itself.
+func requestLogger(log *slog.Logger, bodies *middleware.BodyLogger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
+		ctx, breakdown := timing.NewContext(c.Request.Context())
+		c.Request = c.Request.WithContext(ctx)
+		bodyAttrs := bodies.Start(c)
 		c.Next()
 		duration := time.Since(start)
 		status := c.Writer.Status()
-		msg := "request completed"
-		if status >= http.StatusBadRequest {
-			log.Warn(msg,
-				slog.String("method", c.Request.Method),
-				slog.String("path", c.Request.URL.Path),
-				slog.Int("status", status),
-				slog.Duration("duration", duration),
-				slog.String("client", c.ClientIP()),
-				slog.String("error", c.Errors.String()),
-			)
-			return
-		}
-		log.Info(msg,
+		attrs := []any{
 			slog.String("method", c.Request.Method),
 			slog.String("path", c.Request.URL.Path),
+			slog.String("route", c.FullPath()),
 			slog.Int("status", status),
 			slog.Duration("duration", duration),
 			slog.String("client", c.ClientIP()),
-		)
+			slog.String("request_id", c.GetString("requestID")),
+		}
+		if userID := c.GetString("userID"); userID != "" {
+			attrs = append(attrs, slog.String("user_id", userID))
+		}
+		attrs = append(attrs, slog.Group("timing", breakdown.Attrs(duration)...))
+		attrs = append(attrs, bodyAttrs()...)
+		msg := "request completed"
+		if status >= http.StatusBadRequest {
+			log.Warn(msg, append(attrs, slog.String("error", c.Errors.String()))...)
+			return
+		}
+		log.Info(msg, attrs...)
 	}
 }
 
+const (
+	upstreamAuth    = "auth"
+	upstreamScripts = "scripts"
+	upstreamVideos  = "videos"
+)
+
 const (
 	envLocal = "local"
 	envDev   = "dev"
@@ -197,13 +1650,198 @@ func setupPrettySlog() *slog.Logger {
 	return slog.New(handler)
 }
 
+// hotReloadKeys are the config keys applied by a reload; changes to the
+// others are logged and take effect after a restart.
+var hotReloadKeys = []string{
+	"cors.allow_origins",
+	"demo.origins",
+	"recovery.ip_limit",
+	"recovery.ip_window",
+	"recovery.email_limit",
+	"recovery.email_window",
+	"recovery.challenge_limit",
+	"recovery.challenge_window",
+	"client_errors.rate_limit",
+	"client_errors.rate_window",
+	"auth_grpc.timeout",
+	"script_service.timeout",
+	"video_service.timeout",
+	"sync.timeout",
+	"routes.disabled",
+	"routes.timeouts",
+	"maintenance.groups",
+	"maintenance.active",
+	"maintenance.message",
+	"maintenance.retry_after",
+	"analytics.enabled",
+	"feature_flags.flags",
+	"passthrough.routes",
+	"kafka.sasl.username",
+	"kafka.sasl.password",
+	"app_secret",
+}
+
+func flagDefinitions(cfg *config.Config) []flags.Flag {
+	defs := make([]flags.Flag, len(cfg.FeatureFlags.Flags))
+	for i, flag := range cfg.FeatureFlags.Flags {
+		defs[i] = flags.Flag(flag)
+	}
+	return defs
+}
+
+func passthroughRoutes(cfg *config.Config) []handlers.PassthroughRoute {
+	routes := make([]handlers.PassthroughRoute, len(cfg.Passthrough.Routes))
+	for i, route := range cfg.Passthrough.Routes {
+		routes[i] = handlers.PassthroughRoute(route)
+	}
+	return routes
+}
+
+func apiVersionSchedules(cfg *config.Config) map[string]apiversion.Schedule {
+	schedules := make(map[string]apiversion.Schedule, len(cfg.APIVersions.Versions))
+	for name, v := range cfg.APIVersions.Versions {
+		schedules[name] = apiversion.Schedule(v)
+	}
+	return schedules
+}
+
+func maintenanceConfig(cfg *config.Config) maintenance.Config {
+	return maintenance.Config{
+		Groups:     cfg.Maintenance.Groups,
+		Active:     cfg.Maintenance.Active,
+		Message:    cfg.Maintenance.Message,
+		RetryAfter: cfg.Maintenance.RetryAfter,
+	}
+}
+
+// clientTimeout is the HTTP client timeout of an upstream: its service timeout
+// or, when longer, a route override, so the client never cuts a call its
+// route allows.
+func clientTimeout(service time.Duration, routes map[string]time.Duration) time.Duration {
+	for _, timeout := range routes {
+		service = max(service, timeout)
+	}
+	return service
+}
+
+// applyReload re-reads the configuration and records what changed.
+func applyReload(reloader *reload.Reloader[*config.Config], auditLog *audit.Logger, log *slog.Logger) {
+	changes, err := reloader.Reload()
+	if err != nil {
+		log.Error("config reload failed, keeping the current config", slog.String("path", config.Path()), slog.String("err", err.Error()))
+		return
+	}
+	if len(changes) == 0 {
+		return
+	}
+	var applied, restart []string
+	for _, key := range changes {
+		if slices.Contains(hotReloadKeys, key) {
+			applied = append(applied, key)
+		} else {
+			restart = append(restart, key)
+		}
+	}
+	log.Info("config_reloaded",
+		slog.String("path", config.Path()),
+		slog.Any("applied", applied),
+		slog.Any("restart_required", restart),
+	)
+	auditLog.Record(audit.Event{
+		Action:  audit.ActionConfigReload,
+		Outcome: audit.OutcomeSuccess,
+		Target:  config.Path(),
+		Changes: changes,
+	})
+}
+
+// environKeys returns the names of the variables set in the process
+// environment.
+func environKeys() map[string]bool {
+	keys := make(map[string]bool)
+	for _, kv := range os.Environ() {
+		key, _, _ := strings.Cut(kv, "=")
+		keys[key] = true
+	}
+	return keys
+}
+
+// reloadDotenv applies the current .env like godotenv.Load did at startup:
+// variables set in the process environment (processEnv) keep their values.
+func reloadDotenv(processEnv map[string]bool) error {
+	values, err := godotenv.Read(".env")
+	if err != nil {
+		return err
+	}
+	for key, value := range values {
+		if !processEnv[key] {
+			os.Setenv(key, value)
+		}
+	}
+	return nil
+}
+
+// allowedOrigins lists the browser origins allowed by CORS and for websocket
+// upgrades: the configured ones and, with the demo enabled, its origins.
+func allowedOrigins(cfg *config.Config) []string {
+	origins := append([]string(nil), cfg.CORS.AllowOrigins...)
+	if cfg.Demo.Enabled {
+		origins = append(origins, cfg.Demo.Origins...)
+	}
+	return origins
+}
+
 func setupRouter(
-	env string,
+	cfg *config.Config,
 	authHandler *handlers.AuthHandler,
+	authConfigHandler *handlers.AuthConfigHandler,
 	scriptHandler *handlers.ScriptHandler,
 	videoHandler *handlers.VideoHandler,
+	searchHandler *handlers.SearchHandler,
+	usageHandler *handlers.UsageHandler,
+	creditsHandler *handlers.CreditsHandler,
+	syncHandler *handlers.SyncHandler,
+	webhookHandler *handlers.WebhookHandler,
+	clientErrorHandler *handlers.ClientErrorHandler,
+	demoHandler *handlers.DemoHandler,
+	statusHandler *handlers.StatusHandler,
+	adminHandler *handlers.AdminHandler,
+	collaboratorHandler *handlers.CollaboratorHandler,
+	maintenanceHandler *handlers.MaintenanceHandler,
+	flagsHandler *handlers.FlagsHandler,
+	receiptHandler *handlers.ReceiptHandler,
+	passthroughHandler *handlers.PassthroughHandler,
+	graphqlHandler *handlers.GraphQLHandler,
+	overviewHandler *handlers.OverviewHandler,
+	videoBatchHandler *handlers.VideoBatchHandler,
+	monitor *health.Monitor,
 	authMiddleware gin.HandlerFunc,
-) *gin.Engine {
+	authIdentify gin.HandlerFunc,
+	entitlementsMiddleware gin.HandlerFunc,
+	flagsMiddleware gin.HandlerFunc,
+	priorityMiddleware gin.HandlerFunc,
+	adminMiddleware gin.HandlerFunc,
+	collaboratorAccess gin.HandlerFunc,
+	uploadLimit gin.HandlerFunc,
+	ideaQueue gin.HandlerFunc,
+	loginGuard gin.HandlerFunc,
+	idempotency gin.HandlerFunc,
+	llmBudget *middleware.DailyBudget,
+	videoLimits gin.HandlerFunc,
+	usageMeter *middleware.UsageMeter,
+	validator *middleware.JSONValidator,
+	auditLog *audit.Logger,
+	accessLog gin.HandlerFunc,
+	errorReporter *errreport.Reporter,
+	origins *reload.Value[[]string],
+	reloader *reload.Reloader[*config.Config],
+	maintenanceMiddleware gin.HandlerFunc,
+	errorRates gin.HandlerFunc,
+	downloadReceipt gin.HandlerFunc,
+	openapiInfo *openapi.Info,
+	apiVersions *apiversion.Set,
+) (*gin.Engine, error) {
+	env := cfg.Env
 	mode := gin.ReleaseMode
 	if env == envLocal {
 		mode = gin.DebugMode
@@ -211,73 +1849,339 @@ func setupRouter(
 	gin.SetMode(mode)
 
 	router := gin.New()
-	config := cors.DefaultConfig()
-	config.AllowOrigins = []string{
-		"http://localhost:3000",
-		"http://87.228.89.123:3000",
+	if err := router.SetTrustedProxies(cfg.HTTP.TrustedProxies); err != nil {
+		return nil, err
+	}
+	router.HandleMethodNotAllowed = true
+	router.NoRoute(func(c *gin.Context) {
+		apierror.Abort(c, http.StatusNotFound, apierror.CodeNotFound, "route not found", nil)
+	})
+	router.NoMethod(func(c *gin.Context) {
+		apierror.Abort(c, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed", nil)
+	})
+	// Before any middleware: versioned requests are routed again as /api/*.
+	for _, v := range apiVersions.Versions() {
+		router.Any("/api/"+v.Name+"/*path", middleware.VersionPrefix(router, v))
+	}
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowOriginFunc = func(origin string) bool {
+		for _, allowed := range origins.Load() {
+			if allowed == "*" || strings.EqualFold(allowed, origin) {
+				return true
+			}
+		}
+		return false
 	}
-	config.AllowCredentials = true
-	config.AllowHeaders = []string{
+	corsConfig.AllowCredentials = true
+	corsConfig.AllowHeaders = []string{
 		"Authorization",
 		"Content-Type",
 		"Origin",
 		"Accept",
+		"If-None-Match",
+		"X-Request-ID",
+		"X-Operation-ID",
+		middleware.CaptchaTokenHeader,
+		middleware.IdempotencyKeyHeader,
 	}
-	config.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"}
-	config.ExposeHeaders = []string{"Set-Cookie"}
-	router.Use(cors.New(config))
+	if cfg.ServerTiming.Header != "" {
+		corsConfig.AllowHeaders = append(corsConfig.AllowHeaders, cfg.ServerTiming.Header)
+	}
+	if cfg.Canary.Header != "" {
+		corsConfig.AllowHeaders = append(corsConfig.AllowHeaders, cfg.Canary.Header)
+	}
+	corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"}
+	corsConfig.ExposeHeaders = []string{
+		"Set-Cookie",
+		"ETag",
+		"Retry-After",
+		"X-Budget-Limit",
+		"X-Budget-Remaining",
+		"X-Budget-Reset",
+		"X-Queue-Wait",
+		"X-Request-ID",
+		"X-Operation-ID",
+		"X-Cache",
+		"X-Suggest-Superseded",
+		middleware.CaptchaRequiredHeader,
+		middleware.IdempotentReplayedHeader,
+		middleware.APIVersionHeader,
+		"Deprecation",
+		"Sunset",
+		"Link",
+	}
+	router.Use(middleware.APIVersion(apiVersions))
+	router.Use(cors.New(corsConfig))
+	router.Use(middleware.RequestID())
+	router.Use(errorRates)
+	routeSwitch := middleware.NewRouteSwitch(cfg.Routes.Disabled)
+	routeTimeouts := middleware.NewRouteTimeouts(cfg.Routes.Timeouts)
+	reloader.OnReload(func(next *config.Config) {
+		routeSwitch.Set(next.Routes.Disabled)
+		routeTimeouts.Set(next.Routes.Timeouts)
+	})
+	router.Use(accessLog)
 	if env == envLocal {
 		router.Use(gin.Logger())
 	}
-	router.Use(gin.Recovery())
-	router.Use(requestLogger(setupLogger(env)))
+	router.Use(middleware.ReportErrors(errorReporter))
+	router.Use(middleware.Recovery(errorReporter))
+	router.Use(routeSwitch.Middleware())
+	router.Use(maintenanceMiddleware)
+	router.Use(routeTimeouts.Middleware())
+	var bodyLogger *middleware.BodyLogger
+	if cfg.BodyLog.Enabled {
+		bodyLogger = middleware.NewBodyLogger(middleware.BodyLogConfig{
+			SampleRate: cfg.BodyLog.SampleRate,
+			MaxBytes:   cfg.BodyLog.MaxBytes,
+			Routes:     cfg.BodyLog.Routes,
+			Redact:     cfg.BodyLog.Redact,
+		})
+	}
+	router.Use(requestLogger(setupLogger(env), bodyLogger))
+	router.Use(middleware.ServerTiming(middleware.ServerTimingConfig{
+		Enabled: cfg.ServerTiming.Enabled,
+		Header:  cfg.ServerTiming.Header,
+		Token:   cfg.ServerTiming.Token,
+	}))
+	if cfg.Regions.ClientHeader != "" {
+		router.Use(middleware.ClientRegion(cfg.Regions.ClientHeader))
+	}
+	if cfg.Canary.Header != "" {
+		router.Use(middleware.CanaryOverride(cfg.Canary.Header))
+	}
+	router.Use(middleware.BodyLimits(middleware.BodyLimitsConfig{
+		MaxJSONBytes:    cfg.RequestBody.MaxJSONBytes,
+		MaxJSONDepth:    cfg.RequestBody.MaxJSONDepth,
+		MaxDecodedBytes: cfg.RequestBody.MaxDecodedBytes,
+		ExcludePaths:    cfg.RequestBody.ExcludePaths,
+	}))
+	if cfg.Compression.Enabled {
+		router.Use(middleware.Compression(middleware.CompressionConfig{
+			MinSize:      cfg.Compression.MinSize,
+			Level:        cfg.Compression.Level,
+			Algorithms:   cfg.Compression.Algorithms,
+			ExcludePaths: cfg.Compression.ExcludePaths,
+		}))
+	}
 
 	router.GET("/healthz", func(c *gin.Context) {
 		c.String(http.StatusOK, "ok")
 	})
+	router.GET("/debug/vars", authMiddleware, adminMiddleware, gin.WrapH(expvar.Handler()))
 
+	router.GET("/api/status", statusHandler.Status)
+	router.GET("/api/compat", handlers.NewCompatHandler(compatOptions(cfg.Compat)).Check)
+
+	recoveryIPLimit := middleware.NewRateLimiter(cfg.Recovery.IPLimit, cfg.Recovery.IPWindow)
+	recoveryEmailLimit := middleware.NewRateLimiter(cfg.Recovery.EmailLimit, cfg.Recovery.EmailWindow)
+	recoveryChallengeLimit := middleware.NewRateLimiter(cfg.Recovery.ChallengeLimit, cfg.Recovery.ChallengeWindow)
+	reloader.OnReload(func(next *config.Config) {
+		recoveryIPLimit.SetLimit(next.Recovery.IPLimit, next.Recovery.IPWindow)
+		recoveryEmailLimit.SetLimit(next.Recovery.EmailLimit, next.Recovery.EmailWindow)
+		recoveryChallengeLimit.SetLimit(next.Recovery.ChallengeLimit, next.Recovery.ChallengeWindow)
+	})
+	recoveryByIP := recoveryIPLimit.Middleware()
+	forgotByEmail := recoveryEmailLimit.KeyedBy(middleware.JSONFieldKey("email"))
+	// A few codes per challenge, however many IPs they come from.
+	challengeByToken := recoveryChallengeLimit.KeyedBy(middleware.JSONFieldKey("challenge_token"))
+	// Impersonation tokens may read the account but not take it over.
+	noImpersonation := middleware.NoImpersonation()
 	auth := router.Group("/api/auth")
+	auth.Use(middleware.DegradedUpstream(monitor, upstreamAuth))
 	{
-		auth.POST("/register", authHandler.Register)
-		auth.POST("/login", authHandler.Login)
+		auth.GET("/config", authConfigHandler.Config)
+		auth.POST("/register", middleware.Audit(auditLog, audit.ActionRegister), loginGuard, authHandler.Register)
+		auth.POST("/login", middleware.Audit(auditLog, audit.ActionLogin), loginGuard, authHandler.Login)
 		auth.POST("/refresh", authHandler.RefreshToken)
-		auth.POST("/logout", authHandler.Logout)
+		auth.POST("/logout", authIdentify, middleware.Audit(auditLog, audit.ActionLogout), authHandler.Logout)
+		auth.POST("/password/forgot", recoveryByIP, forgotByEmail, authHandler.ForgotPassword)
+		auth.POST("/password/reset", recoveryByIP, middleware.Audit(auditLog, audit.ActionPasswordReset), authHandler.ResetPassword)
+		auth.POST("/verify-email", recoveryByIP, authHandler.VerifyEmail)
+		auth.POST("/password", authMiddleware, middleware.Audit(auditLog, audit.ActionPasswordChange), noImpersonation, authHandler.ChangePassword)
+		auth.POST("/email", authMiddleware, middleware.Audit(auditLog, audit.ActionEmailChange), noImpersonation, authHandler.ChangeEmail)
+		auth.GET("/me", authMiddleware, authHandler.Me)
+		auth.GET("/sessions", authMiddleware, noImpersonation, authHandler.Sessions)
+		auth.POST("/2fa/setup", authMiddleware, noImpersonation, authHandler.TwoFactorSetup)
+		auth.POST("/2fa/verify", authMiddleware, middleware.Audit(auditLog, audit.ActionTwoFactorOn), noImpersonation, authHandler.TwoFactorVerify)
+		auth.POST("/2fa/disable", authMiddleware, middleware.Audit(auditLog, audit.ActionTwoFactorOff), noImpersonation, authHandler.TwoFactorDisable)
+		auth.POST("/2fa/challenge", recoveryByIP, challengeByToken, middleware.Audit(auditLog, audit.ActionLogin), authHandler.TwoFactorChallenge)
+		auth.DELETE("/sessions/:id", authMiddleware, middleware.Audit(auditLog, audit.ActionSessionRevoke), noImpersonation, authHandler.RevokeSession)
 		auth.GET("/users/:id", authMiddleware, authHandler.GetUser)
 		auth.GET("/users/:id/is_admin", authMiddleware, authHandler.IsAdmin)
 	}
 
 	scripts := router.Group("/api/scripts")
-	scripts.Use(authMiddleware)
+	scripts.Use(authMiddleware, entitlementsMiddleware, flagsMiddleware, priorityMiddleware, middleware.DegradedUpstream(monitor, upstreamScripts))
 	{
-		scripts.POST("", scriptHandler.CreateScript)
+		scripts.POST("", validator.Route(middleware.SchemaCreateScript), idempotency, llmBudget.Limit(middleware.BudgetScripts), scriptHandler.CreateScript)
 		scripts.GET("", scriptHandler.ListScripts)
 	}
 
+	catalogCache := middleware.NewResponseCache()
+	var coalescer *middleware.Coalescer
+	if cfg.Cache.Coalesce {
+		coalescer = middleware.NewCoalescer()
+	}
+	coalesce := coalescer.Handler()
+	orgAdmin := middleware.RequireOrgRole(middleware.OrgRoleAdmin)
+	invalidateShared := func(c *gin.Context) {
+		c.Next()
+		if c.Writer.Status() < http.StatusBadRequest {
+			catalogCache.InvalidatePrefix("/api/videos/media/shared")
+		}
+	}
+
+	uploadRules := middleware.NewUploadRules(middleware.UploadRulesConfig{
+		Rules: map[string]middleware.UploadRule{
+			middleware.UploadImage: uploadRule(cfg.Uploads.Image),
+			middleware.UploadAudio: uploadRule(cfg.Uploads.Audio),
+			middleware.UploadVideo: uploadRule(cfg.Uploads.Video),
+		},
+		TypePath: cfg.Uploads.TypePath,
+		NamePath: cfg.Uploads.NamePath,
+	})
+	mediaUpload := uploadRules.Allow(middleware.UploadImage, middleware.UploadAudio)
+	videoUpload := uploadRules.Allow(middleware.UploadVideo)
+	auditUpload := middleware.Audit(auditLog, audit.ActionMediaUpload)
+	auditMediaDelete := middleware.Audit(auditLog, audit.ActionMediaDelete)
+	meterUpload := usageMeter.CountBytes()
+
 	videos := router.Group("/api/videos")
-	videos.Use(authMiddleware)
+	videos.Use(authMiddleware, entitlementsMiddleware, flagsMiddleware, priorityMiddleware, collaboratorAccess, middleware.DegradedUpstream(monitor, upstreamVideos))
 	{
-		videos.POST("", videoHandler.CreateVideo)
+		videos.POST("", validator.Route(middleware.SchemaCreateVideo), videoLimits, idempotency, usageMeter.Count(usage.Videos), videoHandler.CreateVideo)
 		videos.GET("", videoHandler.ListVideos)
-		videos.GET("/:id", videoHandler.GetVideo)
+		videos.GET("/:id", coalesce, videoHandler.GetVideo)
+		videos.PATCH("/:id", videoHandler.UpdateVideo)
+		videos.DELETE("/:id", middleware.Audit(auditLog, audit.ActionVideoDelete), videoHandler.DeleteVideo)
+		videos.GET("/:id/diagnostics", videoHandler.Diagnostics)
+		videos.POST("/:id/collaborators", collaboratorHandler.Grant)
+		videos.GET("/:id/collaborators", collaboratorHandler.List)
+		videos.DELETE("/:id/collaborators/:user_id", collaboratorHandler.Revoke)
+		videos.GET("/:id/receipts", receiptHandler.List)
+		videos.GET("/:id/content", downloadReceipt, videoHandler.Content)
 		videos.POST("/:id/draft:approve", videoHandler.ApproveDraft)
 		videos.POST("/:id/subtitles:approve", videoHandler.ApproveSubtitles)
-		videos.POST("/media", videoHandler.UploadMedia)
+		videos.POST("/:id/subtitles/translations", videoHandler.RequestSubtitleTranslations)
+		videos.GET("/:id/subtitles/translations", videoHandler.ListSubtitleTranslations)
+		videos.POST("/:id/subtitles/translations/:lang/approve", videoHandler.ApproveSubtitleTranslation)
+		videos.POST("/media", auditUpload, meterUpload, uploadLimit, mediaUpload, videoHandler.UploadMedia)
 		videos.GET("/media", videoHandler.ListMedia)
-		videos.GET("/media/shared", videoHandler.ListSharedMedia)
-		videos.POST("/media/videos", videoHandler.UploadVideoMedia)
-		videos.POST("/media/videos:upload", videoHandler.UploadVideoBinary)
+		videos.DELETE("/media/:id", auditMediaDelete, videoHandler.DeleteMedia)
+		videos.GET("/media/shared", catalogCache.Handler(cfg.Cache.SharedMedia, "orgID"), videoHandler.ListSharedMedia)
+		videos.POST("/media/shared", auditUpload, orgAdmin, invalidateShared, meterUpload, uploadLimit, mediaUpload, videoHandler.UploadSharedMedia)
+		videos.DELETE("/media/shared/:id", auditMediaDelete, orgAdmin, invalidateShared, videoHandler.DeleteSharedMedia)
+		videos.POST("/media/videos", auditUpload, meterUpload, uploadLimit, videoUpload, videoHandler.UploadVideoMedia)
+		videos.POST("/media/videos:upload", auditUpload, meterUpload, uploadLimit, videoUpload, videoHandler.UploadVideoBinary)
 		videos.GET("/media/videos", videoHandler.ListVideoMedia)
 		videos.GET("/media/shared/videos", videoHandler.ListSharedVideoMedia)
-		videos.GET("/voices", videoHandler.ListVoices)
-		videos.GET("/music", videoHandler.ListMusic)
+		videos.GET("/media/:id/stream", videoHandler.StreamMedia)
+		videos.GET("/voices", catalogCache.Handler(cfg.Cache.Voices), coalesce, videoHandler.ListVoices)
+		videos.GET("/music", catalogCache.Handler(cfg.Cache.Music), coalesce, videoHandler.ListMusic)
 		videos.GET("/:id/stream", videoHandler.StreamVideo)
 	}
 
 	ideas := router.Group("/api/ideas")
-	ideas.Use(authMiddleware)
+	ideas.Use(authMiddleware, entitlementsMiddleware, flagsMiddleware, priorityMiddleware, middleware.DegradedUpstream(monitor, upstreamVideos))
+	{
+		ideas.POST("/expand", validator.Route(middleware.SchemaExpandIdea), llmBudget.Limit(middleware.BudgetIdeas), usageMeter.Count(usage.Ideas), ideaQueue, videoHandler.ExpandIdea)
+	}
+
+	ext := router.Group("/api/ext")
+	ext.Use(authMiddleware, entitlementsMiddleware, flagsMiddleware, priorityMiddleware)
+	{
+		ext.Any("/"+handlers.PassthroughScripts+"/*path", middleware.DegradedUpstream(monitor, upstreamScripts), passthroughHandler.Scripts)
+		ext.Any("/"+handlers.PassthroughVideos+"/*path", middleware.DegradedUpstream(monitor, upstreamVideos), passthroughHandler.Videos)
+	}
+
+	router.GET("/api/flags", authMiddleware, entitlementsMiddleware, flagsMiddleware, flagsHandler.List)
+	router.GET("/api/usage", authMiddleware, entitlementsMiddleware, usageHandler.Usage)
+	if creditsHandler != nil {
+		router.GET("/api/users/:id/credits", authMiddleware, entitlementsMiddleware, creditsHandler.Credits)
+	}
+	router.GET("/api/sync", authMiddleware, entitlementsMiddleware, flagsMiddleware, priorityMiddleware, syncHandler.Sync)
+	router.GET("/api/overview", authMiddleware, entitlementsMiddleware, flagsMiddleware, priorityMiddleware, overviewHandler.Overview)
+	router.GET(handlers.VideoBatchRoute, authMiddleware, entitlementsMiddleware, flagsMiddleware, priorityMiddleware, middleware.DegradedUpstream(monitor, upstreamVideos), videoBatchHandler.BatchGet)
+	if graphqlHandler != nil {
+		router.GET("/api/graphql", authMiddleware, entitlementsMiddleware, flagsMiddleware, priorityMiddleware, graphqlHandler.Query)
+		router.POST("/api/graphql", authMiddleware, entitlementsMiddleware, flagsMiddleware, priorityMiddleware, graphqlHandler.Query)
+	}
+
+	if webhookHandler != nil {
+		hooks := router.Group("/api/webhooks")
+		hooks.Use(authMiddleware)
+		{
+			hooks.POST("", webhookHandler.Create)
+			hooks.GET("", webhookHandler.List)
+			hooks.DELETE("/:id", webhookHandler.Delete)
+			hooks.GET("/:id/deliveries", webhookHandler.Deliveries)
+			hooks.POST("/:id/deliveries/:delivery_id/redeliver", webhookHandler.Redeliver)
+		}
+	}
+
+	if clientErrorHandler != nil {
+		clientErrorLimit := middleware.NewRateLimiter(cfg.ClientErrors.RateLimit, cfg.ClientErrors.RateWindow)
+		reloader.OnReload(func(next *config.Config) {
+			clientErrorLimit.SetLimit(next.ClientErrors.RateLimit, next.ClientErrors.RateWindow)
+		})
+		router.POST("/api/client-errors", middleware.OptionalAuth(authMiddleware), clientErrorLimit.Middleware(), clientErrorHandler.Report)
+	}
+
+	if demoHandler != nil {
+		demo := router.Group("/api/demo")
+		demo.Use(middleware.DegradedUpstream(monitor, upstreamVideos))
+		{
+			demo.POST("/videos", validator.Route(middleware.SchemaCreateVideo), demoHandler.CreateVideo)
+			demo.GET("/videos/:id", demoHandler.GetVideo)
+		}
+	}
+
+	search := router.Group("/api/search")
+	search.Use(authMiddleware, entitlementsMiddleware, flagsMiddleware, priorityMiddleware, middleware.DegradedUpstream(monitor, upstreamVideos))
+	{
+		search.GET("/suggest", searchHandler.Suggest)
+	}
+
+	router.GET("/api/events", authMiddleware, videoHandler.StreamEvents)
+
+	admin := router.Group("/api/admin")
+	admin.Use(authMiddleware, adminMiddleware)
 	{
-		ideas.POST("/expand", videoHandler.ExpandIdea)
+		admin.GET("/users", adminHandler.ListUsers)
+		admin.GET("/users/:id", adminHandler.GetUser)
+		admin.PATCH("/users/:id/role", middleware.Audit(auditLog, audit.ActionRoleChange), adminHandler.SetUserRole)
+		admin.POST("/users/:id/disable", middleware.Audit(auditLog, audit.ActionUserDisable), adminHandler.DisableUser)
+		admin.GET("/users/:id/videos", adminHandler.ListUserVideos)
+		admin.GET("/users/:id/scripts", adminHandler.ListUserScripts)
+		admin.POST("/impersonate/:user_id", middleware.Audit(auditLog, audit.ActionImpersonate), adminHandler.Impersonate)
+		admin.POST("/secrets/reencrypt", middleware.Audit(auditLog, audit.ActionReencrypt), adminHandler.ReencryptSecrets)
+		admin.POST("/jobs/:id/replay", videoHandler.ReplayJob)
+		admin.GET("/journal", videoHandler.ListJournal)
+		admin.POST("/journal/replay", videoHandler.ReplayJournal)
+		admin.DELETE("/journal/:id", videoHandler.DeleteJournalEntry)
+		admin.GET("/upstreams", statusHandler.Upstreams)
+		admin.GET("/maintenance", maintenanceHandler.List)
+		admin.PUT("/maintenance/:group", middleware.Audit(auditLog, audit.ActionMaintenance), maintenanceHandler.Start)
+		admin.DELETE("/maintenance/:group", middleware.Audit(auditLog, audit.ActionMaintenance), maintenanceHandler.Stop)
+	}
+
+	// Last, so the document lists every route registered above.
+	if openapiInfo != nil {
+		var routes []openapi.Route
+		for _, route := range router.Routes() {
+			routes = append(routes, openapi.Route{Method: route.Method, Path: route.Path})
+		}
+		var assets string
+		if cfg.OpenAPI.SwaggerUI {
+			assets = strings.TrimRight(cfg.OpenAPI.SwaggerUIAssets, "/")
+		}
+		openapiHandler := handlers.NewOpenAPIHandler(openapi.Build(*openapiInfo, handlers.OpenAPIOperations(), routes), assets)
+		router.GET("/api/openapi.json", openapiHandler.Spec)
+		if assets != "" {
+			router.GET("/api/docs", openapiHandler.SwaggerUI)
+		}
 	}
 
-	return router
+	return router, nil
 }
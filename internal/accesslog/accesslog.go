@@ -0,0 +1,196 @@
+// Package accesslog writes one line per served request, apart from the
+// application log, in a format standard log pipelines and SIEMs understand:
+// JSON Lines, the Common Log Format or the Combined Log Format.
+package accesslog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/immxrtalbeast/api-gateway/internal/metrics"
+)
+
+const (
+	FormatJSON     = "json"
+	FormatCommon   = "common"
+	FormatCombined = "combined"
+)
+
+type Entry struct {
+	Time      time.Time     `json:"time"`
+	ClientIP  string        `json:"client_ip"`
+	UserID    string        `json:"user_id,omitempty"`
+	Method    string        `json:"method"`
+	Host      string        `json:"host,omitempty"`
+	URI       string        `json:"uri"`
+	Route     string        `json:"route,omitempty"`
+	Proto     string        `json:"proto"`
+	Status    int           `json:"status"`
+	Bytes     int           `json:"bytes"`
+	Referer   string        `json:"referer,omitempty"`
+	UserAgent string        `json:"user_agent,omitempty"`
+	Duration  time.Duration `json:"-"`
+	RequestID string        `json:"request_id,omitempty"`
+}
+
+// fieldDuration is the JSON key of Entry.Duration, in milliseconds.
+const fieldDuration = "duration_ms"
+
+type Options struct {
+	// Fields limits JSON lines to these keys, in this order: the JSON names of
+	// the Entry fields and "duration_ms". Empty writes them all.
+	Fields []string
+	// Buffer queues up to this many lines for a background writer so a slow
+	// output doesn't hold up requests; lines are dropped while the queue is
+	// full. 0 writes each line before the request completes.
+	Buffer int
+}
+
+// Logger formats entries onto a writer, one per line.
+type Logger struct {
+	mu     sync.Mutex
+	out    io.WriteCloser
+	format string
+	fields []string
+
+	lines chan []byte
+	wg    sync.WaitGroup
+	once  sync.Once
+}
+
+func New(out io.WriteCloser, format string, opts Options) (*Logger, error) {
+	switch format {
+	case FormatJSON, FormatCommon, FormatCombined:
+	default:
+		return nil, fmt.Errorf("unknown access log format %q", format)
+	}
+	for _, field := range opts.Fields {
+		if !knownField(field) {
+			return nil, fmt.Errorf("unknown access log field %q", field)
+		}
+	}
+	l := &Logger{out: out, format: format, fields: opts.Fields}
+	if opts.Buffer > 0 {
+		l.lines = make(chan []byte, opts.Buffer)
+		l.wg.Add(1)
+		go l.run()
+	}
+	return l, nil
+}
+
+// Write writes the line of e, or queues it when the logger is buffered.
+func (l *Logger) Write(e Entry) error {
+	line := l.line(e)
+	if l.lines != nil {
+		select {
+		case l.lines <- line:
+		default:
+			metrics.AccessLog.Add("dropped", 1)
+		}
+		return nil
+	}
+	return l.write(line)
+}
+
+// Close writes the queued lines and closes the output. Write must not be
+// called afterwards.
+func (l *Logger) Close() error {
+	if l.lines != nil {
+		l.once.Do(func() { close(l.lines) })
+		l.wg.Wait()
+	}
+	return l.out.Close()
+}
+
+func (l *Logger) run() {
+	defer l.wg.Done()
+	for line := range l.lines {
+		if err := l.write(line); err != nil {
+			metrics.AccessLog.Add("write_errors", 1)
+		}
+	}
+}
+
+func (l *Logger) write(line []byte) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err := l.out.Write(line)
+	return err
+}
+
+func (l *Logger) line(e Entry) []byte {
+	if l.format == FormatJSON {
+		return append(l.jsonLine(e), '\n')
+	}
+	var b strings.Builder
+	b.WriteString(dash(e.ClientIP))
+	b.WriteString(" - ")
+	b.WriteString(dash(e.UserID))
+	b.WriteString(e.Time.Format(" [02/Jan/2006:15:04:05 -0700] "))
+	b.WriteString(strconv.Quote(e.Method + " " + e.URI + " " + e.Proto))
+	b.WriteString(" " + strconv.Itoa(e.Status) + " ")
+	if e.Bytes > 0 {
+		b.WriteString(strconv.Itoa(e.Bytes))
+	} else {
+		b.WriteString("-")
+	}
+	if l.format == FormatCombined {
+		b.WriteString(" " + strconv.Quote(dash(e.Referer)) + " " + strconv.Quote(dash(e.UserAgent)))
+	}
+	b.WriteByte('\n')
+	return []byte(b.String())
+}
+
+func (l *Logger) jsonLine(e Entry) []byte {
+	type entry Entry
+	line, _ := json.Marshal(struct {
+		entry
+		DurationMS float64 `json:"duration_ms"`
+	}{entry(e), float64(e.Duration) / float64(time.Millisecond)})
+	if len(l.fields) == 0 {
+		return line
+	}
+	var all map[string]json.RawMessage
+	json.Unmarshal(line, &all)
+	var b strings.Builder
+	b.WriteByte('{')
+	for _, field := range l.fields {
+		value, ok := all[field]
+		if !ok {
+			continue
+		}
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.Quote(field) + ":")
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return []byte(b.String())
+}
+
+func knownField(name string) bool {
+	if name == fieldDuration {
+		return true
+	}
+	t := reflect.TypeOf(Entry{})
+	for i := 0; i < t.NumField(); i++ {
+		if tag, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); tag == name && tag != "-" {
+			return true
+		}
+	}
+	return false
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
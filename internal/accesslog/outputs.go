@@ -0,0 +1,229 @@
+package accesslog
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stdout writes to the process output and isn't closed with the logger.
+func Stdout() io.WriteCloser {
+	return nopCloser{os.Stdout}
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+type RotationConfig struct {
+	// MaxSize rotates the file before it grows past this many bytes; 0 never
+	// rotates on size.
+	MaxSize int64
+	// MaxAge rotates a file this long after it was started; 0 never rotates on
+	// age.
+	MaxAge time.Duration
+	// MaxBackups is the number of rotated files kept; 0 keeps them all.
+	MaxBackups int
+}
+
+// RotatingFile appends to path and moves it aside to path.<timestamp> when
+// it gets too large or too old.
+type RotatingFile struct {
+	path string
+	cfg  RotationConfig
+
+	mu      sync.Mutex
+	file    *os.File
+	size    int64
+	started time.Time
+}
+
+func NewRotatingFile(path string, cfg RotationConfig) (*RotatingFile, error) {
+	if path == "" {
+		return nil, fmt.Errorf("access log path is required")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create access log dir: %w", err)
+	}
+	f := &RotatingFile{path: path, cfg: cfg}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.size > 0 && f.due(len(p)) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
+
+func (f *RotatingFile) due(next int) bool {
+	return (f.cfg.MaxSize > 0 && f.size+int64(next) > f.cfg.MaxSize) ||
+		(f.cfg.MaxAge > 0 && time.Since(f.started) >= f.cfg.MaxAge)
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+	if err != nil {
+		return fmt.Errorf("open access log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("stat access log: %w", err)
+	}
+	f.file, f.size, f.started = file, info.Size(), time.Now()
+	return nil
+}
+
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("close access log: %w", err)
+	}
+	backup := f.path + "." + time.Now().UTC().Format("20060102T150405.000")
+	if err := os.Rename(f.path, backup); err != nil {
+		return fmt.Errorf("rotate access log: %w", err)
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	f.prune()
+	return nil
+}
+
+// prune removes the oldest backups beyond MaxBackups. The timestamp suffix
+// makes name order the rotation order.
+func (f *RotatingFile) prune() {
+	if f.cfg.MaxBackups <= 0 {
+		return
+	}
+	backups, err := filepath.Glob(f.path + ".*")
+	if err != nil || len(backups) <= f.cfg.MaxBackups {
+		return
+	}
+	sort.Strings(backups)
+	for _, backup := range backups[:len(backups)-f.cfg.MaxBackups] {
+		os.Remove(backup)
+	}
+}
+
+// Socket sends lines as they are to a TCP, UDP or unix socket, e.g. the raw
+// input of a SIEM collector, one line per datagram on packet sockets.
+// Connections are reopened after a failed write.
+type Socket struct {
+	network string
+	addr    string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func NewSocket(network, addr string) (*Socket, error) {
+	if addr == "" {
+		return nil, fmt.Errorf("socket address is required")
+	}
+	s := &Socket{network: network, addr: addr}
+	conn, err := net.DialTimeout(network, addr, 5*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("connect to %s %s: %w", network, addr, err)
+	}
+	s.conn = conn
+	return s, nil
+}
+
+func (s *Socket) Write(p []byte) (int, error) {
+	if err := s.send(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (s *Socket) send(msg []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		conn, err := net.DialTimeout(s.network, s.addr, 5*time.Second)
+		if err != nil {
+			return fmt.Errorf("connect to %s %s: %w", s.network, s.addr, err)
+		}
+		s.conn = conn
+	}
+	if _, err := s.conn.Write(msg); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (s *Socket) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
+
+// Syslog ships lines to a syslog daemon as RFC 3164 messages with the local0
+// facility and informational severity.
+type Syslog struct {
+	socket *Socket
+	tag    string
+	host   string
+}
+
+// syslogPriority is local0 (16) * 8 + info (6).
+const syslogPriority = 16*8 + 6
+
+func NewSyslog(network, addr, tag string) (*Syslog, error) {
+	if addr == "" {
+		return nil, fmt.Errorf("syslog address is required")
+	}
+	socket, err := NewSocket(network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("syslog: %w", err)
+	}
+	host, _ := os.Hostname()
+	return &Syslog{socket: socket, tag: tag, host: dash(host)}, nil
+}
+
+func (s *Syslog) Write(p []byte) (int, error) {
+	msg := "<" + strconv.Itoa(syslogPriority) + ">" + time.Now().Format(time.Stamp) + " " + s.host + " " + s.tag + ": " + strings.TrimRight(string(p), "\n")
+	if s.socket.network != "udp" && s.socket.network != "unixgram" {
+		msg += "\n"
+	}
+	if err := s.socket.send([]byte(msg)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (s *Syslog) Close() error {
+	return s.socket.Close()
+}
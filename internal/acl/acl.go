@@ -0,0 +1,137 @@
+// Package acl keeps per-video collaborator grants enforced by the gateway.
+// The video service only knows the owner, so collaborators are proxied as
+// the owner once their grant has been checked.
+package acl
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/immxrtalbeast/api-gateway/lib/jsonfile"
+)
+
+type Role string
+
+const (
+	RoleView Role = "view"
+	RoleEdit Role = "edit"
+)
+
+func (r Role) Valid() bool {
+	return r == RoleView || r == RoleEdit
+}
+
+type Video struct {
+	Owner         string          `json:"owner"`
+	Collaborators map[string]Role `json:"collaborators"`
+}
+
+// Store holds the grants in memory and, when path is set, mirrors them to a
+// JSON file.
+type Store struct {
+	mu     sync.RWMutex
+	path   string
+	videos map[string]*Video
+}
+
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, videos: make(map[string]*Video)}
+	if path == "" {
+		return s, nil
+	}
+	if err := jsonfile.Read(path, &s.videos); err != nil {
+		return nil, fmt.Errorf("open acl: %w", err)
+	}
+	return s, nil
+}
+
+// Access returns the owner of videoID and the role granted to userID. ok is
+// false when the video has no grants or userID has none.
+func (s *Store) Access(videoID, userID string) (owner string, role Role, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, exists := s.videos[videoID]
+	if !exists {
+		return "", "", false
+	}
+	role, ok = v.Collaborators[userID]
+	return v.Owner, role, ok
+}
+
+// Owner returns the recorded owner of videoID, if any grant was made.
+func (s *Store) Owner(videoID string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.videos[videoID]
+	if !ok {
+		return "", false
+	}
+	return v.Owner, true
+}
+
+func (s *Store) Get(videoID string) (Video, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.videos[videoID]
+	if !ok {
+		return Video{}, false
+	}
+	res := Video{Owner: v.Owner, Collaborators: make(map[string]Role, len(v.Collaborators))}
+	for user, role := range v.Collaborators {
+		res.Collaborators[user] = role
+	}
+	return res, true
+}
+
+func (s *Store) Grant(videoID, owner, userID string, role Role) error {
+	if !role.Valid() {
+		return fmt.Errorf("unknown role %q", role)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.videos[videoID]
+	if !ok {
+		v = &Video{Owner: owner, Collaborators: make(map[string]Role)}
+		s.videos[videoID] = v
+	}
+	if v.Owner != owner {
+		return fmt.Errorf("video %s belongs to another user", videoID)
+	}
+	v.Collaborators[userID] = role
+	return s.persistLocked()
+}
+
+func (s *Store) Revoke(videoID, userID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.videos[videoID]
+	if !ok {
+		return false, nil
+	}
+	if _, ok := v.Collaborators[userID]; !ok {
+		return false, nil
+	}
+	delete(v.Collaborators, userID)
+	if len(v.Collaborators) == 0 {
+		delete(s.videos, videoID)
+	}
+	return true, s.persistLocked()
+}
+
+// Forget drops every grant of a deleted video.
+func (s *Store) Forget(videoID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.videos[videoID]; !ok {
+		return nil
+	}
+	delete(s.videos, videoID)
+	return s.persistLocked()
+}
+
+func (s *Store) persistLocked() error {
+	if s.path == "" {
+		return nil
+	}
+	return jsonfile.Write(s.path, s.videos)
+}
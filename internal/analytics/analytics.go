@@ -0,0 +1,187 @@
+// Package analytics publishes normalized records of what users ask the
+// gateway for to a Kafka topic read by the data team, so creation intent is
+// visible without reading the video service database. Publishing happens in
+// the background and never delays or fails the request it describes.
+package analytics
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/immxrtalbeast/api-gateway/internal/metrics"
+	"github.com/immxrtalbeast/api-gateway/lib/jsonpath"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+)
+
+// EventJobRequested is the event of a video job accepted by the video
+// service.
+const EventJobRequested = "job_requested"
+
+const (
+	defaultBuffer = 1024
+	// maxBatch records are written to the brokers at once.
+	maxBatch     = 100
+	writeTimeout = 10 * time.Second
+)
+
+// JobRequested describes a created video job. Parameters is the request body
+// without Preset and the fields the gateway adds, such as priority.
+type JobRequested struct {
+	Event          string          `json:"event"`
+	Time           time.Time       `json:"time"`
+	JobID          string          `json:"job_id,omitempty"`
+	UserID         string          `json:"user_id"`
+	CollaboratorID string          `json:"collaborator_id,omitempty"`
+	ImpersonatedBy string          `json:"impersonated_by,omitempty"`
+	OrgID          string          `json:"org_id,omitempty"`
+	Plan           string          `json:"plan,omitempty"`
+	Priority       string          `json:"priority,omitempty"`
+	Preset         string          `json:"preset,omitempty"`
+	Parameters     json.RawMessage `json:"parameters,omitempty"`
+	RequestID      string          `json:"request_id,omitempty"`
+}
+
+type Config struct {
+	Brokers []string
+	Topic   string
+	// JobIDPath locates the job ID in the video service's answer to
+	// CreateVideo, "job.id" by default.
+	JobIDPath string
+	// Buffer records wait for the brokers; when full, new ones are dropped.
+	Buffer int
+	// Dial overrides how broker connections are opened, e.g. through an
+	// egress proxy.
+	Dial func(ctx context.Context, network, address string) (net.Conn, error)
+	// SASL and TLS secure the broker connections when set.
+	SASL sasl.Mechanism
+	TLS  *tls.Config
+}
+
+// Publisher writes records to the topic keyed by user, so one user's records
+// stay ordered within a partition. A nil Publisher and a disabled one drop
+// every record.
+type Publisher struct {
+	jobIDPath string
+	writer    *kafka.Writer
+	log       *slog.Logger
+	enabled   atomic.Bool
+	records   chan kafka.Message
+	wg        sync.WaitGroup
+	once      sync.Once
+}
+
+func NewPublisher(cfg Config, enabled bool, log *slog.Logger) (*Publisher, error) {
+	if len(cfg.Brokers) == 0 {
+		return nil, fmt.Errorf("kafka brokers are not configured")
+	}
+	if cfg.Topic == "" {
+		return nil, fmt.Errorf("analytics topic is required")
+	}
+	if cfg.Buffer <= 0 {
+		cfg.Buffer = defaultBuffer
+	}
+	if cfg.JobIDPath == "" {
+		cfg.JobIDPath = "job.id"
+	}
+	writer := &kafka.Writer{
+		Addr:                   kafka.TCP(cfg.Brokers...),
+		Topic:                  cfg.Topic,
+		Balancer:               &kafka.Hash{},
+		RequiredAcks:           kafka.RequireAll,
+		BatchSize:              maxBatch,
+		BatchTimeout:           10 * time.Millisecond,
+		AllowAutoTopicCreation: true,
+	}
+	if cfg.Dial != nil || cfg.SASL != nil || cfg.TLS != nil {
+		writer.Transport = &kafka.Transport{Dial: cfg.Dial, SASL: cfg.SASL, TLS: cfg.TLS}
+	}
+	p := &Publisher{
+		jobIDPath: cfg.JobIDPath,
+		writer:    writer,
+		log:       log,
+		records:   make(chan kafka.Message, cfg.Buffer),
+	}
+	p.enabled.Store(enabled)
+	p.wg.Add(1)
+	go p.run()
+	return p, nil
+}
+
+// SetEnabled turns publishing on or off for the following records.
+func (p *Publisher) SetEnabled(enabled bool) {
+	if p != nil {
+		p.enabled.Store(enabled)
+	}
+}
+
+// JobRequested queues rec for publishing, with the job ID read from created,
+// the video service's answer.
+func (p *Publisher) JobRequested(rec JobRequested, created []byte) {
+	if p == nil || !p.enabled.Load() {
+		return
+	}
+	rec.Event = EventJobRequested
+	rec.JobID, _ = jsonpath.String(created, p.jobIDPath)
+	if rec.Time.IsZero() {
+		rec.Time = time.Now().UTC()
+	}
+	value, err := json.Marshal(rec)
+	if err != nil {
+		metrics.Analytics.Add("errors", 1)
+		return
+	}
+	select {
+	case p.records <- kafka.Message{Key: []byte(rec.UserID), Value: value}:
+	default:
+		metrics.Analytics.Add("dropped", 1)
+	}
+}
+
+// Close flushes queued records and closes the writer. JobRequested must not
+// be called afterwards.
+func (p *Publisher) Close() error {
+	if p == nil {
+		return nil
+	}
+	p.once.Do(func() { close(p.records) })
+	p.wg.Wait()
+	return p.writer.Close()
+}
+
+func (p *Publisher) run() {
+	defer p.wg.Done()
+	batch := make([]kafka.Message, 0, maxBatch)
+	for msg := range p.records {
+		batch = append(batch[:0], msg)
+		// Take whatever else is queued, so a burst is one write.
+	fill:
+		for len(batch) < maxBatch {
+			select {
+			case msg, ok := <-p.records:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, msg)
+			default:
+				break fill
+			}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+		err := p.writer.WriteMessages(ctx, batch...)
+		cancel()
+		if err != nil {
+			metrics.Analytics.Add("errors", int64(len(batch)))
+			p.log.Error("analytics records not published", slog.Int("records", len(batch)), slog.String("err", err.Error()))
+			continue
+		}
+		metrics.Analytics.Add("published", int64(len(batch)))
+	}
+}
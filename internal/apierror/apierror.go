@@ -0,0 +1,178 @@
+// Package apierror defines the error envelope returned by every gateway
+// endpoint and the stable codes clients can switch on:
+//
+//	{"error": {"code": "not_found", "message": "...", "details": {...}, "request_id": "..."}}
+//
+// From API v2 errors are RFC 9457 problem details with the same code:
+//
+//	{"type": "about:blank", "title": "Not Found", "status": 404, "detail": "...", "code": "not_found", "request_id": "..."}
+package apierror
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/apiversion"
+	"google.golang.org/grpc/codes"
+)
+
+type Code string
+
+const (
+	CodeInvalidRequest       Code = "invalid_request"
+	CodeUnauthenticated      Code = "unauthenticated"
+	CodePermissionDenied     Code = "permission_denied"
+	CodeNotFound             Code = "not_found"
+	CodeMethodNotAllowed     Code = "method_not_allowed"
+	CodeConflict             Code = "conflict"
+	CodeAlreadyExists        Code = "already_exists"
+	CodePayloadTooLarge      Code = "payload_too_large"
+	CodeUnsupportedMediaType Code = "unsupported_media_type"
+	CodeUnprocessable        Code = "unprocessable_entity"
+	CodeValidationFailed     Code = "validation_failed"
+	CodeRateLimited          Code = "rate_limited"
+	CodeCaptchaRequired      Code = "captcha_required"
+	CodeBudgetExceeded       Code = "budget_exceeded"
+	CodeQuotaExceeded        Code = "quota_exceeded"
+	CodePlanLimitExceeded    Code = "plan_limit_exceeded"
+	CodeTooManyUploads       Code = "too_many_uploads"
+	CodeInternal             Code = "internal"
+	CodeNotImplemented       Code = "not_implemented"
+	CodeRouteDisabled        Code = "route_disabled"
+	CodeMaintenance          Code = "maintenance"
+	CodeUpstreamError        Code = "upstream_error"
+	CodeUpstreamUnavailable  Code = "upstream_unavailable"
+	CodeUpstreamUnreachable  Code = "upstream_unreachable"
+	CodeUpstreamDegraded     Code = "upstream_degraded"
+	CodeUpstreamTimeout      Code = "upstream_timeout"
+)
+
+// RequestIDHeader carries the request ID set by middleware.RequestID.
+const RequestIDHeader = "X-Request-ID"
+
+type Error struct {
+	Code      Code           `json:"code"`
+	Message   string         `json:"message"`
+	Details   map[string]any `json:"details,omitempty"`
+	RequestID string         `json:"request_id,omitempty"`
+}
+
+// FieldError describes one invalid request field, addressed by JSON Pointer.
+// A list of them goes to details.fields of validation_failed errors.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type Envelope struct {
+	Error Error `json:"error"`
+}
+
+// Problem is the RFC 9457 form of Error, answered to API versions with
+// apiversion.ProblemDetails. The type is always about:blank, so the code
+// tells problems apart.
+type Problem struct {
+	Type      string         `json:"type"`
+	Title     string         `json:"title"`
+	Status    int            `json:"status"`
+	Detail    string         `json:"detail"`
+	Code      Code           `json:"code"`
+	Details   map[string]any `json:"details,omitempty"`
+	RequestID string         `json:"request_id,omitempty"`
+}
+
+// ProblemContentType is the media type of Problem.
+const ProblemContentType = "application/problem+json"
+
+// Abort writes the envelope, or the problem details for the API versions
+// expecting them, and stops the handler chain.
+func Abort(c *gin.Context, status int, code Code, message string, details map[string]any) {
+	requestID := c.Writer.Header().Get(RequestIDHeader)
+	if apiversion.Has(c.Request.Context(), apiversion.ProblemDetails) {
+		c.Header("Content-Type", ProblemContentType)
+		c.AbortWithStatusJSON(status, Problem{
+			Type:      "about:blank",
+			Title:     http.StatusText(status),
+			Status:    status,
+			Detail:    message,
+			Code:      code,
+			Details:   details,
+			RequestID: requestID,
+		})
+		return
+	}
+	c.AbortWithStatusJSON(status, Envelope{Error: Error{
+		Code:      code,
+		Message:   message,
+		Details:   details,
+		RequestID: requestID,
+	}})
+}
+
+// FromHTTPStatus picks the default code for a gateway or upstream status.
+func FromHTTPStatus(status int) Code {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeInvalidRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthenticated
+	case http.StatusForbidden:
+		return CodePermissionDenied
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusRequestEntityTooLarge:
+		return CodePayloadTooLarge
+	case http.StatusUnsupportedMediaType:
+		return CodeUnsupportedMediaType
+	case http.StatusUnprocessableEntity:
+		return CodeUnprocessable
+	case http.StatusPaymentRequired:
+		return CodeQuotaExceeded
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusNotImplemented:
+		return CodeNotImplemented
+	case http.StatusBadGateway:
+		return CodeUpstreamError
+	case http.StatusServiceUnavailable:
+		return CodeUpstreamUnavailable
+	case http.StatusGatewayTimeout:
+		return CodeUpstreamTimeout
+	}
+	if status >= http.StatusInternalServerError {
+		return CodeInternal
+	}
+	return CodeInvalidRequest
+}
+
+// FromGRPC maps an upstream gRPC status code to the HTTP status and code the
+// gateway responds with.
+func FromGRPC(code codes.Code) (int, Code) {
+	switch code {
+	case codes.InvalidArgument, codes.OutOfRange, codes.FailedPrecondition:
+		return http.StatusBadRequest, CodeInvalidRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized, CodeUnauthenticated
+	case codes.PermissionDenied:
+		return http.StatusForbidden, CodePermissionDenied
+	case codes.NotFound:
+		return http.StatusNotFound, CodeNotFound
+	case codes.AlreadyExists:
+		return http.StatusConflict, CodeAlreadyExists
+	case codes.Aborted:
+		return http.StatusConflict, CodeConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests, CodeRateLimited
+	case codes.Unimplemented:
+		return http.StatusNotImplemented, CodeNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable, CodeUpstreamUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout, CodeUpstreamTimeout
+	}
+	return http.StatusInternalServerError, CodeUpstreamError
+}
@@ -0,0 +1,145 @@
+// Package apiversion names the versions of the public API and the breaking
+// changes each one brings, so a change ships under a new /api/vN prefix while
+// clients of the older versions keep the old behavior. Code that changes
+// behavior asks Has for its Change instead of comparing version names.
+package apiversion
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Change is a breaking change of the API, on from the version introducing
+// it.
+type Change string
+
+const (
+	// ProblemDetails answers errors with RFC 9457 application/problem+json
+	// instead of the {"error": {...}} envelope.
+	ProblemDetails Change = "problem_details"
+)
+
+// known lists the versions in order with the changes each introduces; a
+// version has the changes of every earlier one.
+var known = []struct {
+	name    string
+	changes []Change
+}{
+	{name: "v1"},
+	{name: "v2", changes: []Change{ProblemDetails}},
+}
+
+// Version is a served version. Deprecated and Sunset are zero unless
+// scheduled; Link documents the migration away from it.
+type Version struct {
+	Name       string
+	Deprecated time.Time
+	Sunset     time.Time
+	Link       string
+	changes    []Change
+}
+
+// Has reports whether v includes change.
+func (v Version) Has(change Change) bool {
+	return slices.Contains(v.changes, change)
+}
+
+// Schedule is the configured deprecation of a version; dates are RFC 3339
+// timestamps or plain dates ("2027-01-31").
+type Schedule struct {
+	Deprecated string
+	Sunset     string
+	Link       string
+}
+
+// Set is the served versions.
+type Set struct {
+	versions []Version
+	def      Version
+}
+
+// New serves the versions in schedules, or every known version when it is
+// empty. Requests without a version prefix get def.
+func New(schedules map[string]Schedule, def string) (*Set, error) {
+	s := &Set{}
+	var changes []Change
+	for _, k := range known {
+		changes = append(slices.Clone(changes), k.changes...)
+		schedule, ok := schedules[k.name]
+		if !ok && len(schedules) > 0 {
+			continue
+		}
+		v := Version{Name: k.name, Link: schedule.Link, changes: changes}
+		var err error
+		if v.Deprecated, err = parseDate(schedule.Deprecated); err != nil {
+			return nil, fmt.Errorf("api version %s: deprecated: %w", k.name, err)
+		}
+		if v.Sunset, err = parseDate(schedule.Sunset); err != nil {
+			return nil, fmt.Errorf("api version %s: sunset: %w", k.name, err)
+		}
+		s.versions = append(s.versions, v)
+	}
+	for name := range schedules {
+		if _, ok := s.Lookup(name); !ok {
+			return nil, fmt.Errorf("unknown api version %q", name)
+		}
+	}
+	if def == "" {
+		def = known[0].name
+	}
+	var ok bool
+	if s.def, ok = s.Lookup(def); !ok {
+		return nil, fmt.Errorf("default api version %q is not served", def)
+	}
+	return s, nil
+}
+
+// Versions lists the served versions, oldest first.
+func (s *Set) Versions() []Version {
+	return s.versions
+}
+
+func (s *Set) Default() Version {
+	return s.def
+}
+
+func (s *Set) Lookup(name string) (Version, bool) {
+	for _, v := range s.versions {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return Version{}, false
+}
+
+func parseDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+type contextKey struct{}
+
+// NewContext attaches the version a request was made against to ctx.
+func NewContext(ctx context.Context, v Version) context.Context {
+	return context.WithValue(ctx, contextKey{}, v)
+}
+
+// FromContext returns the version attached by NewContext.
+func FromContext(ctx context.Context) (Version, bool) {
+	v, ok := ctx.Value(contextKey{}).(Version)
+	return v, ok
+}
+
+// Has reports whether the request's version includes change; requests
+// without a version have none.
+func Has(ctx context.Context, change Change) bool {
+	v, _ := FromContext(ctx)
+	return v.Has(change)
+}
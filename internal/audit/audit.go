@@ -0,0 +1,140 @@
+package audit
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/immxrtalbeast/api-gateway/internal/metrics"
+)
+
+// Actions recorded by the gateway.
+const (
+	ActionLogin          = "auth.login"
+	ActionLogout         = "auth.logout"
+	ActionRegister       = "auth.register"
+	ActionPasswordReset  = "auth.password_reset"
+	ActionPasswordChange = "auth.password_change"
+	ActionEmailChange    = "auth.email_change"
+	ActionSessionRevoke  = "auth.session_revoke"
+	ActionTwoFactorOn    = "auth.2fa_enable"
+	ActionTwoFactorOff   = "auth.2fa_disable"
+	ActionRoleChange     = "admin.role_change"
+	ActionUserDisable    = "admin.user_disable"
+	ActionImpersonate    = "admin.impersonate"
+	ActionReencrypt      = "admin.secrets_reencrypt"
+	ActionMaintenance    = "admin.maintenance"
+	ActionVideoDelete    = "video.delete"
+	ActionVideoDownload  = "video.download"
+	ActionMediaUpload    = "media.upload"
+	ActionMediaDelete    = "media.delete"
+	ActionConfigReload   = "gateway.config_reloaded"
+)
+
+const (
+	OutcomeSuccess = "success"
+	// OutcomeDenied marks requests rejected with 401 or 403.
+	OutcomeDenied  = "denied"
+	OutcomeFailure = "failure"
+)
+
+const (
+	defaultBuffer       = 1024
+	defaultWriteTimeout = 5 * time.Second
+)
+
+// Event is a single audited action.
+type Event struct {
+	Time           time.Time `json:"time"`
+	Action         string    `json:"action"`
+	Outcome        string    `json:"outcome"`
+	Status         int       `json:"status"`
+	ActorID        string    `json:"actor_id,omitempty"`
+	ImpersonatedBy string    `json:"impersonated_by,omitempty"`
+	Target         string    `json:"target,omitempty"`
+	IP             string    `json:"ip"`
+	UserAgent      string    `json:"user_agent,omitempty"`
+	Method         string    `json:"method"`
+	Path           string    `json:"path"`
+	RequestID      string    `json:"request_id,omitempty"`
+	// Changes lists the config keys changed by a reload.
+	Changes []string `json:"changes,omitempty"`
+	// Receipt is the signed receipt of a video.download.
+	Receipt *Receipt `json:"receipt,omitempty"`
+}
+
+// Sink stores audit events.
+type Sink interface {
+	Write(ctx context.Context, ev Event) error
+	Close() error
+}
+
+// Logger hands events to a Sink in the background so audited requests never
+// wait on it. When the buffer is full new events are dropped and counted in
+// gateway_audit. A nil Logger records nothing.
+type Logger struct {
+	sink   Sink
+	log    *slog.Logger
+	events chan Event
+	wg     sync.WaitGroup
+	once   sync.Once
+}
+
+func NewLogger(sink Sink, buffer int, log *slog.Logger) *Logger {
+	if buffer <= 0 {
+		buffer = defaultBuffer
+	}
+	l := &Logger{
+		sink:   sink,
+		log:    log,
+		events: make(chan Event, buffer),
+	}
+	l.wg.Add(1)
+	go l.run()
+	return l
+}
+
+func (l *Logger) Record(ev Event) {
+	if l == nil {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+	select {
+	case l.events <- ev:
+	default:
+		metrics.Audit.Add("dropped", 1)
+	}
+}
+
+// Close flushes buffered events and closes the sink. Record must not be
+// called afterwards.
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.once.Do(func() { close(l.events) })
+	l.wg.Wait()
+	return l.sink.Close()
+}
+
+func (l *Logger) run() {
+	defer l.wg.Done()
+	for ev := range l.events {
+		ctx, cancel := context.WithTimeout(context.Background(), defaultWriteTimeout)
+		err := l.sink.Write(ctx, ev)
+		cancel()
+		if err != nil {
+			metrics.Audit.Add("sink_errors", 1)
+			l.log.Error("audit event not written",
+				slog.String("action", ev.Action),
+				slog.String("request_id", ev.RequestID),
+				slog.String("err", err.Error()),
+			)
+			continue
+		}
+		metrics.Audit.Add("recorded", 1)
+	}
+}
@@ -0,0 +1,119 @@
+package audit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/immxrtalbeast/api-gateway/internal/metrics"
+	"github.com/immxrtalbeast/api-gateway/internal/store"
+)
+
+// Receipt proves that a user was served a video by the gateway: who, what,
+// when and the SHA-256 of the bytes delivered. Signature is the unpadded
+// base64url HMAC-SHA256 under KeyID of the receipt's JSON with an empty
+// Signature.
+type Receipt struct {
+	ID             string    `json:"id"`
+	VideoID        string    `json:"video_id"`
+	UserID         string    `json:"user_id"`
+	ImpersonatedBy string    `json:"impersonated_by,omitempty"`
+	IssuedAt       time.Time `json:"issued_at"`
+	SHA256         string    `json:"sha256"`
+	Size           int64     `json:"size"`
+	ContentType    string    `json:"content_type,omitempty"`
+	IP             string    `json:"ip"`
+	UserAgent      string    `json:"user_agent,omitempty"`
+	RequestID      string    `json:"request_id,omitempty"`
+	KeyID          string    `json:"key_id"`
+	Signature      string    `json:"signature"`
+}
+
+// Receipts signs download receipts, keeps them per video and records each as
+// a video.download audit event. A nil Receipts issues nothing.
+type Receipts struct {
+	store     store.Store
+	keyID     string
+	key       []byte
+	retention time.Duration
+	audit     *Logger
+}
+
+// NewReceipts keeps receipts in st for retention, forever when it is zero.
+func NewReceipts(st store.Store, keyID string, key []byte, retention time.Duration, audit *Logger) *Receipts {
+	return &Receipts{store: st, keyID: keyID, key: key, retention: retention, audit: audit}
+}
+
+// Issue completes rec with its ID, issue time and signature and stores it,
+// then records ev, the download request, with the receipt.
+func (r *Receipts) Issue(ctx context.Context, rec Receipt, ev Event) (Receipt, error) {
+	if r == nil {
+		return rec, nil
+	}
+	id := make([]byte, 12)
+	rand.Read(id)
+	rec.ID = hex.EncodeToString(id)
+	rec.IssuedAt = time.Now().UTC()
+	rec.KeyID = r.keyID
+	rec.Signature = ""
+	signature, err := r.sign(rec)
+	if err != nil {
+		return rec, err
+	}
+	rec.Signature = signature
+
+	value, err := json.Marshal(rec)
+	if err != nil {
+		return rec, err
+	}
+	if err := r.store.Put(ctx, receiptKey(rec), value, r.retention); err != nil {
+		metrics.Audit.Add("receipt_errors", 1)
+		return rec, fmt.Errorf("store receipt: %w", err)
+	}
+	metrics.Audit.Add("receipts", 1)
+	ev.Time = rec.IssuedAt
+	ev.Action = ActionVideoDownload
+	ev.Outcome = OutcomeSuccess
+	ev.Target = rec.VideoID
+	ev.Receipt = &rec
+	r.audit.Record(ev)
+	return rec, nil
+}
+
+// List returns the receipts of videoID, oldest first.
+func (r *Receipts) List(ctx context.Context, videoID string) ([]Receipt, error) {
+	entries, err := r.store.List(ctx, videoID+"/")
+	if err != nil {
+		return nil, err
+	}
+	receipts := make([]Receipt, 0, len(entries))
+	for _, entry := range entries {
+		var rec Receipt
+		if err := json.Unmarshal(entry.Value, &rec); err != nil {
+			continue
+		}
+		receipts = append(receipts, rec)
+	}
+	return receipts, nil
+}
+
+func (r *Receipts) sign(rec Receipt) (string, error) {
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write(payload)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// receiptKey orders the receipts of a video by issue time.
+func receiptKey(rec Receipt) string {
+	return fmt.Sprintf("%s/%020d-%s", rec.VideoID, rec.IssuedAt.UnixNano(), rec.ID)
+}
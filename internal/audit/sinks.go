@@ -0,0 +1,138 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+)
+
+// FileSink appends events to a file as JSON lines.
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+func NewFileSink(path string) (*FileSink, error) {
+	if path == "" {
+		return nil, fmt.Errorf("audit file path is required")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create audit dir: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open audit file: %w", err)
+	}
+	return &FileSink{file: file, enc: json.NewEncoder(file)}, nil
+}
+
+func (s *FileSink) Write(_ context.Context, ev Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(ev)
+}
+
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+type KafkaSinkConfig struct {
+	Brokers []string
+	Topic   string
+	// Dial overrides how broker connections are opened, e.g. through an
+	// egress proxy.
+	Dial func(ctx context.Context, network, address string) (net.Conn, error)
+	// SASL and TLS secure the broker connections when set.
+	SASL sasl.Mechanism
+	TLS  *tls.Config
+}
+
+// KafkaSink publishes events to a topic keyed by actor, so one user's events
+// stay ordered within a partition.
+type KafkaSink struct {
+	writer *kafka.Writer
+}
+
+func NewKafkaSink(cfg KafkaSinkConfig) (*KafkaSink, error) {
+	if len(cfg.Brokers) == 0 {
+		return nil, fmt.Errorf("kafka brokers are not configured")
+	}
+	if cfg.Topic == "" {
+		return nil, fmt.Errorf("audit topic is required")
+	}
+	writer := &kafka.Writer{
+		Addr:                   kafka.TCP(cfg.Brokers...),
+		Topic:                  cfg.Topic,
+		Balancer:               &kafka.Hash{},
+		RequiredAcks:           kafka.RequireAll,
+		AllowAutoTopicCreation: true,
+	}
+	if cfg.Dial != nil || cfg.SASL != nil || cfg.TLS != nil {
+		writer.Transport = &kafka.Transport{Dial: cfg.Dial, SASL: cfg.SASL, TLS: cfg.TLS}
+	}
+	return &KafkaSink{writer: writer}, nil
+}
+
+func (s *KafkaSink) Write(ctx context.Context, ev Event) error {
+	value, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	return s.writer.WriteMessages(ctx, kafka.Message{Key: []byte(ev.ActorID), Value: value})
+}
+
+func (s *KafkaSink) Close() error {
+	return s.writer.Close()
+}
+
+// WebhookSink POSTs every event as JSON to an HTTP endpoint; any non-2xx
+// answer counts as a failed write.
+type WebhookSink struct {
+	url  string
+	http *http.Client
+}
+
+func NewWebhookSink(url string, timeout time.Duration, transport http.RoundTripper) (*WebhookSink, error) {
+	if url == "" {
+		return nil, fmt.Errorf("audit webhook url is required")
+	}
+	return &WebhookSink{url: url, http: &http.Client{Timeout: timeout, Transport: transport}}, nil
+}
+
+func (s *WebhookSink) Write(ctx context.Context, ev Event) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("audit webhook request failed: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("audit webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *WebhookSink) Close() error {
+	s.http.CloseIdleConnections()
+	return nil
+}
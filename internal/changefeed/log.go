@@ -0,0 +1,94 @@
+// Package changefeed keeps a short per-user history of realtime job updates
+// so sync clients can pick up what they missed since their last cursor.
+package changefeed
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+const defaultPerUser = 500
+
+// Change is one job update seen on the events source.
+type Change struct {
+	Seq     uint64          `json:"seq"`
+	JobID   string          `json:"job_id"`
+	At      time.Time       `json:"at"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// Log numbers every recorded update with a process-wide sequence and keeps
+// the last perUser updates of each user. It lives in memory: the epoch
+// changes on restart, so cursors from a previous process are detected.
+type Log struct {
+	mu      sync.Mutex
+	epoch   string
+	seq     uint64
+	perUser int
+	users   map[string][]Change
+}
+
+func New(perUser int) *Log {
+	if perUser <= 0 {
+		perUser = defaultPerUser
+	}
+	buf := make([]byte, 4)
+	rand.Read(buf)
+	return &Log{
+		epoch:   hex.EncodeToString(buf),
+		perUser: perUser,
+		users:   make(map[string][]Change),
+	}
+}
+
+// Record stores an update of jobID owned by userID. It matches the events
+// Hub listener signature.
+func (l *Log) Record(jobID, userID string, payload []byte) {
+	if userID == "" {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.seq++
+	changes := append(l.users[userID], Change{
+		Seq:     l.seq,
+		JobID:   jobID,
+		At:      time.Now().UTC(),
+		Payload: json.RawMessage(payload),
+	})
+	if len(changes) > l.perUser {
+		changes = append(changes[:0:0], changes[len(changes)-l.perUser:]...)
+	}
+	l.users[userID] = changes
+}
+
+// Epoch identifies this process' sequence space.
+func (l *Log) Epoch() string {
+	return l.epoch
+}
+
+// Since returns the user's updates after seq and the sequence to resume
+// from. complete is false when the cursor belongs to another epoch or older
+// updates were already evicted, i.e. the client may have missed some.
+func (l *Log) Since(userID, epoch string, seq uint64) (changes []Change, next uint64, complete bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	next = l.seq
+	history := l.users[userID]
+	if epoch != l.epoch {
+		return append([]Change(nil), history...), next, seq == 0 && epoch == ""
+	}
+	complete = true
+	for i, change := range history {
+		if change.Seq > seq {
+			// Evicted updates sat between seq and the oldest one kept.
+			complete = i > 0 || len(history) < l.perUser
+			changes = append(changes, history[i:]...)
+			break
+		}
+	}
+	return changes, next, complete
+}
@@ -0,0 +1,202 @@
+// Package clienterrors forwards error reports from the frontend to the error
+// tracker, tagged with the gateway's user and request IDs.
+package clienterrors
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/immxrtalbeast/api-gateway/internal/metrics"
+)
+
+const (
+	defaultBuffer    = 1024
+	defaultBatchSize = 50
+	defaultFlush     = 2 * time.Second
+	sendTimeout      = 10 * time.Second
+
+	maxMessage = 1 << 10
+	maxStack   = 16 << 10
+	maxShort   = 256
+)
+
+// Report is one sanitized client error as sent to the tracker.
+type Report struct {
+	ReceivedAt time.Time  `json:"received_at"`
+	OccurredAt *time.Time `json:"occurred_at,omitempty"`
+	Level      string     `json:"level"`
+	Name       string     `json:"name,omitempty"`
+	Message    string     `json:"message"`
+	Stack      string     `json:"stack,omitempty"`
+	// URL is the page the error happened on, without query or fragment.
+	URL     string `json:"url,omitempty"`
+	Release string `json:"release,omitempty"`
+	// ClientRequestID is the X-Request-ID of the failed API call, if the
+	// error came from one; it links the report to gateway logs.
+	ClientRequestID string `json:"client_request_id,omitempty"`
+	UserID          string `json:"user_id,omitempty"`
+	ImpersonatedBy  string `json:"impersonated_by,omitempty"`
+	RequestID       string `json:"request_id"`
+	IP              string `json:"ip"`
+	UserAgent       string `json:"user_agent,omitempty"`
+}
+
+var (
+	bearerToken = regexp.MustCompile(`(?i)bearer\s+[a-z0-9._~+/=-]+`)
+	jwtToken    = regexp.MustCompile(`eyJ[a-zA-Z0-9_-]+\.[a-zA-Z0-9_-]+\.[a-zA-Z0-9_-]*`)
+	email       = regexp.MustCompile(`[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}`)
+	levels      = map[string]bool{"error": true, "warning": true, "fatal": true}
+)
+
+// Sanitize truncates client supplied fields, drops control characters and
+// redacts tokens and email addresses, so reports can't smuggle credentials
+// or personal data into the tracker.
+func (r Report) Sanitize() Report {
+	if !levels[r.Level] {
+		r.Level = "error"
+	}
+	r.Name = clean(r.Name, maxShort, false)
+	r.Message = clean(r.Message, maxMessage, false)
+	r.Stack = clean(r.Stack, maxStack, true)
+	r.URL = clean(stripQuery(r.URL), maxShort, false)
+	r.Release = clean(r.Release, maxShort, false)
+	r.ClientRequestID = clean(r.ClientRequestID, maxShort, false)
+	r.UserAgent = clean(r.UserAgent, maxShort, false)
+	return r
+}
+
+func clean(s string, limit int, multiline bool) string {
+	s = strings.Map(func(r rune) rune {
+		if r == '\n' && multiline {
+			return r
+		}
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, s)
+	s = bearerToken.ReplaceAllString(s, "Bearer [redacted]")
+	s = jwtToken.ReplaceAllString(s, "[redacted]")
+	s = email.ReplaceAllString(s, "[email]")
+	if len(s) > limit {
+		s = strings.ToValidUTF8(s[:limit], "")
+	}
+	return strings.TrimSpace(s)
+}
+
+func stripQuery(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	u.RawQuery = ""
+	u.Fragment = ""
+	u.User = nil
+	return u.String()
+}
+
+// Tracker receives batches of reports.
+type Tracker interface {
+	Send(ctx context.Context, reports []Report) error
+	Close() error
+}
+
+// Forwarder batches reports and sends them to a Tracker in the background so
+// the intake endpoint never waits on it. When the buffer is full new reports
+// are dropped and counted in gateway_client_errors. A nil Forwarder forwards
+// nothing.
+type Forwarder struct {
+	tracker Tracker
+	log     *slog.Logger
+	reports chan Report
+	wg      sync.WaitGroup
+	once    sync.Once
+}
+
+func NewForwarder(tracker Tracker, buffer int, log *slog.Logger) *Forwarder {
+	if buffer <= 0 {
+		buffer = defaultBuffer
+	}
+	f := &Forwarder{
+		tracker: tracker,
+		log:     log,
+		reports: make(chan Report, buffer),
+	}
+	f.wg.Add(1)
+	go f.run()
+	return f
+}
+
+// Enqueue queues reports and returns how many were accepted.
+func (f *Forwarder) Enqueue(reports []Report) int {
+	if f == nil {
+		return 0
+	}
+	accepted := 0
+	for _, r := range reports {
+		select {
+		case f.reports <- r:
+			accepted++
+		default:
+			metrics.ClientErrors.Add("dropped", 1)
+		}
+	}
+	metrics.ClientErrors.Add("received", int64(accepted))
+	return accepted
+}
+
+// Close sends buffered reports and closes the tracker. Enqueue must not be
+// called afterwards.
+func (f *Forwarder) Close() error {
+	if f == nil {
+		return nil
+	}
+	f.once.Do(func() { close(f.reports) })
+	f.wg.Wait()
+	return f.tracker.Close()
+}
+
+func (f *Forwarder) run() {
+	defer f.wg.Done()
+	ticker := time.NewTicker(defaultFlush)
+	defer ticker.Stop()
+	var batch []Report
+	for {
+		select {
+		case r, ok := <-f.reports:
+			if !ok {
+				f.flush(batch)
+				return
+			}
+			batch = append(batch, r)
+			if len(batch) >= defaultBatchSize {
+				f.flush(batch)
+				batch = nil
+			}
+		case <-ticker.C:
+			f.flush(batch)
+			batch = nil
+		}
+	}
+}
+
+func (f *Forwarder) flush(batch []Report) {
+	if len(batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	err := f.tracker.Send(ctx, batch)
+	cancel()
+	if err != nil {
+		metrics.ClientErrors.Add("tracker_errors", int64(len(batch)))
+		f.log.Error("client error reports not forwarded", slog.Int("reports", len(batch)), slog.String("err", err.Error()))
+		return
+	}
+	metrics.ClientErrors.Add("forwarded", int64(len(batch)))
+}
@@ -0,0 +1,86 @@
+package clienterrors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// HTTPTracker POSTs every batch as {"reports": [...]} to the tracker's
+// ingest URL; any non-2xx answer counts as a failed send.
+type HTTPTracker struct {
+	url   string
+	token string
+	http  *http.Client
+}
+
+// NewHTTPTracker sends token, if any, as a bearer token.
+func NewHTTPTracker(url, token string, timeout time.Duration, transport http.RoundTripper) (*HTTPTracker, error) {
+	if url == "" {
+		return nil, fmt.Errorf("client errors tracker url is required")
+	}
+	return &HTTPTracker{url: url, token: token, http: &http.Client{Timeout: timeout, Transport: transport}}, nil
+}
+
+func (t *HTTPTracker) Send(ctx context.Context, reports []Report) error {
+	body, err := json.Marshal(map[string]any{"reports": reports})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if t.token != "" {
+		req.Header.Set("Authorization", "Bearer "+t.token)
+	}
+	resp, err := t.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("tracker request failed: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("tracker returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (t *HTTPTracker) Close() error {
+	t.http.CloseIdleConnections()
+	return nil
+}
+
+// LogTracker writes reports to the gateway log, for environments without a
+// tracker.
+type LogTracker struct {
+	log *slog.Logger
+}
+
+func NewLogTracker(log *slog.Logger) *LogTracker {
+	return &LogTracker{log: log}
+}
+
+func (t *LogTracker) Send(_ context.Context, reports []Report) error {
+	for _, r := range reports {
+		t.log.Warn("client error",
+			slog.String("level", r.Level),
+			slog.String("name", r.Name),
+			slog.String("message", r.Message),
+			slog.String("url", r.URL),
+			slog.String("release", r.Release),
+			slog.String("user_id", r.UserID),
+			slog.String("request_id", r.RequestID),
+			slog.String("client_request_id", r.ClientRequestID),
+		)
+	}
+	return nil
+}
+
+func (t *LogTracker) Close() error {
+	return nil
+}
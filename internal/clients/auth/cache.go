@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	authv1 "github.com/immxrtalbeast/protos/gen/go/auth/v1"
+	"golang.org/x/sync/singleflight"
+)
+
+// Cached remembers the GetUser and IsAdmin answers of the wrapped Client for
+// a short TTL, so the lookups repeated for one user within a burst of
+// requests cost one call. Concurrent lookups of the same user share a call.
+// Errors aren't cached. The user's entries are dropped when the gateway
+// changes the user through the Client, and on Invalidate.
+//
+// Cached responses are shared between callers, which must not modify them.
+type Cached struct {
+	Client
+	ttl        time.Duration
+	maxEntries int
+	group      singleflight.Group
+
+	mu     sync.Mutex
+	users  map[string]cachedLookup[*authv1.GetUserResponse]
+	admins map[string]cachedLookup[*authv1.IsAdminResponse]
+	// generation changes on every Invalidate, so answers fetched before it
+	// aren't stored.
+	generation uint64
+}
+
+type cachedLookup[T any] struct {
+	resp    T
+	expires time.Time
+}
+
+// NewCached caches lookups for ttl, up to maxEntries users per RPC; when
+// full, expired entries are swept and, if that isn't enough, all dropped.
+func NewCached(client Client, ttl time.Duration, maxEntries int) *Cached {
+	if maxEntries <= 0 {
+		maxEntries = 10000
+	}
+	return &Cached{
+		Client:     client,
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		users:      make(map[string]cachedLookup[*authv1.GetUserResponse]),
+		admins:     make(map[string]cachedLookup[*authv1.IsAdminResponse]),
+	}
+}
+
+func (c *Cached) GetUser(ctx context.Context, req *authv1.GetUserRequest) (*authv1.GetUserResponse, error) {
+	return lookup(c, c.users, "user:", req.UserId, func() (*authv1.GetUserResponse, error) {
+		return c.Client.GetUser(ctx, req)
+	})
+}
+
+func (c *Cached) IsAdmin(ctx context.Context, req *authv1.IsAdminRequest) (*authv1.IsAdminResponse, error) {
+	return lookup(c, c.admins, "admin:", req.UserId, func() (*authv1.IsAdminResponse, error) {
+		return c.Client.IsAdmin(ctx, req)
+	})
+}
+
+// lookup answers from entries, or calls fetch once for all concurrent
+// callers. An answer racing an Invalidate isn't stored.
+func lookup[T any](c *Cached, entries map[string]cachedLookup[T], kind, userID string, fetch func() (T, error)) (T, error) {
+	c.mu.Lock()
+	entry, ok := entries[userID]
+	generation := c.generation
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.resp, nil
+	}
+
+	v, err, _ := c.group.Do(kind+userID, func() (any, error) {
+		resp, err := fetch()
+		if err != nil {
+			return resp, err
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.generation == generation {
+			if len(entries) >= c.maxEntries {
+				sweep(entries, c.maxEntries)
+			}
+			entries[userID] = cachedLookup[T]{resp: resp, expires: time.Now().Add(c.ttl)}
+		}
+		return resp, nil
+	})
+	resp, _ := v.(T)
+	return resp, err
+}
+
+func sweep[T any](entries map[string]cachedLookup[T], maxEntries int) {
+	now := time.Now()
+	for id, entry := range entries {
+		if now.After(entry.expires) {
+			delete(entries, id)
+		}
+	}
+	if len(entries) >= maxEntries {
+		clear(entries)
+	}
+}
+
+// Invalidate drops the cached lookups of userID, e.g. after its role changed.
+func (c *Cached) Invalidate(userID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.users, userID)
+	delete(c.admins, userID)
+	c.generation++
+}
+
+func (c *Cached) VerifyEmail(ctx context.Context, req *authv1.VerifyEmailRequest) (*authv1.VerifyEmailResponse, error) {
+	resp, err := c.Client.VerifyEmail(ctx, req)
+	if err == nil {
+		c.Invalidate(resp.GetUser().GetId())
+	}
+	return resp, err
+}
+
+func (c *Cached) ChangeEmail(ctx context.Context, req *authv1.ChangeEmailRequest) (*authv1.ChangeEmailResponse, error) {
+	resp, err := c.Client.ChangeEmail(ctx, req)
+	c.Invalidate(req.UserId)
+	return resp, err
+}
+
+func (c *Cached) ConfirmTwoFactor(ctx context.Context, req *authv1.ConfirmTwoFactorRequest) (*authv1.ConfirmTwoFactorResponse, error) {
+	resp, err := c.Client.ConfirmTwoFactor(ctx, req)
+	c.Invalidate(req.UserId)
+	return resp, err
+}
+
+func (c *Cached) DisableTwoFactor(ctx context.Context, req *authv1.DisableTwoFactorRequest) (*authv1.DisableTwoFactorResponse, error) {
+	resp, err := c.Client.DisableTwoFactor(ctx, req)
+	c.Invalidate(req.UserId)
+	return resp, err
+}
+
+func (c *Cached) SetUserRole(ctx context.Context, req *authv1.SetUserRoleRequest) (*authv1.SetUserRoleResponse, error) {
+	resp, err := c.Client.SetUserRole(ctx, req)
+	c.Invalidate(req.UserId)
+	return resp, err
+}
+
+func (c *Cached) DisableUser(ctx context.Context, req *authv1.DisableUserRequest) (*authv1.DisableUserResponse, error) {
+	resp, err := c.Client.DisableUser(ctx, req)
+	c.Invalidate(req.UserId)
+	return resp, err
+}
@@ -0,0 +1,149 @@
+// Package auth is the gateway's view of the auth service. Handlers depend on
+// Client; New wraps the gRPC stub and NewFake serves standalone development
+// without the service.
+package auth
+
+import (
+	"context"
+
+	authv1 "github.com/immxrtalbeast/protos/gen/go/auth/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// Client is the part of the auth service API the gateway uses. Errors are
+// gRPC statuses.
+type Client interface {
+	Register(ctx context.Context, req *authv1.RegisterRequest) (*authv1.RegisterResponse, error)
+	Login(ctx context.Context, req *authv1.LoginRequest) (*authv1.LoginResponse, error)
+	RefreshToken(ctx context.Context, req *authv1.RefreshTokenRequest) (*authv1.RefreshTokenResponse, error)
+	Logout(ctx context.Context, req *authv1.LogoutRequest) (*authv1.LogoutResponse, error)
+	GetUser(ctx context.Context, req *authv1.GetUserRequest) (*authv1.GetUserResponse, error)
+	IsAdmin(ctx context.Context, req *authv1.IsAdminRequest) (*authv1.IsAdminResponse, error)
+	ForgotPassword(ctx context.Context, req *authv1.ForgotPasswordRequest) (*authv1.ForgotPasswordResponse, error)
+	ResetPassword(ctx context.Context, req *authv1.ResetPasswordRequest) (*authv1.ResetPasswordResponse, error)
+	VerifyEmail(ctx context.Context, req *authv1.VerifyEmailRequest) (*authv1.VerifyEmailResponse, error)
+	ChangePassword(ctx context.Context, req *authv1.ChangePasswordRequest) (*authv1.ChangePasswordResponse, error)
+	ChangeEmail(ctx context.Context, req *authv1.ChangeEmailRequest) (*authv1.ChangeEmailResponse, error)
+	ListSessions(ctx context.Context, req *authv1.ListSessionsRequest) (*authv1.ListSessionsResponse, error)
+	RevokeSession(ctx context.Context, req *authv1.RevokeSessionRequest) (*authv1.RevokeSessionResponse, error)
+	SetupTwoFactor(ctx context.Context, req *authv1.SetupTwoFactorRequest) (*authv1.SetupTwoFactorResponse, error)
+	ConfirmTwoFactor(ctx context.Context, req *authv1.ConfirmTwoFactorRequest) (*authv1.ConfirmTwoFactorResponse, error)
+	DisableTwoFactor(ctx context.Context, req *authv1.DisableTwoFactorRequest) (*authv1.DisableTwoFactorResponse, error)
+	CompleteTwoFactorLogin(ctx context.Context, req *authv1.CompleteTwoFactorLoginRequest) (*authv1.CompleteTwoFactorLoginResponse, error)
+	ListUsers(ctx context.Context, req *authv1.ListUsersRequest) (*authv1.ListUsersResponse, error)
+	SetUserRole(ctx context.Context, req *authv1.SetUserRoleRequest) (*authv1.SetUserRoleResponse, error)
+	DisableUser(ctx context.Context, req *authv1.DisableUserRequest) (*authv1.DisableUserResponse, error)
+}
+
+// Metadata keys describing the device behind a login or refresh, which the
+// auth service records on the session.
+const (
+	MetadataUserAgent = "x-client-user-agent"
+	MetadataClientIP  = "x-client-ip"
+)
+
+// WithClient attaches the caller's device to outgoing calls.
+func WithClient(ctx context.Context, userAgent, ip string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, MetadataUserAgent, userAgent, MetadataClientIP, ip)
+}
+
+func clientFromContext(ctx context.Context) (userAgent, ip string) {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	if v := md.Get(MetadataUserAgent); len(v) > 0 {
+		userAgent = v[len(v)-1]
+	}
+	if v := md.Get(MetadataClientIP); len(v) > 0 {
+		ip = v[len(v)-1]
+	}
+	return userAgent, ip
+}
+
+type grpcClient struct {
+	stub authv1.AuthServiceClient
+}
+
+// New returns a Client calling the auth service over conn.
+func New(conn grpc.ClientConnInterface) Client {
+	return &grpcClient{stub: authv1.NewAuthServiceClient(conn)}
+}
+
+func (c *grpcClient) Register(ctx context.Context, req *authv1.RegisterRequest) (*authv1.RegisterResponse, error) {
+	return c.stub.Register(ctx, req)
+}
+
+func (c *grpcClient) Login(ctx context.Context, req *authv1.LoginRequest) (*authv1.LoginResponse, error) {
+	return c.stub.Login(ctx, req)
+}
+
+func (c *grpcClient) RefreshToken(ctx context.Context, req *authv1.RefreshTokenRequest) (*authv1.RefreshTokenResponse, error) {
+	return c.stub.RefreshToken(ctx, req)
+}
+
+func (c *grpcClient) Logout(ctx context.Context, req *authv1.LogoutRequest) (*authv1.LogoutResponse, error) {
+	return c.stub.Logout(ctx, req)
+}
+
+func (c *grpcClient) GetUser(ctx context.Context, req *authv1.GetUserRequest) (*authv1.GetUserResponse, error) {
+	return c.stub.GetUser(ctx, req)
+}
+
+func (c *grpcClient) IsAdmin(ctx context.Context, req *authv1.IsAdminRequest) (*authv1.IsAdminResponse, error) {
+	return c.stub.IsAdmin(ctx, req)
+}
+
+func (c *grpcClient) ForgotPassword(ctx context.Context, req *authv1.ForgotPasswordRequest) (*authv1.ForgotPasswordResponse, error) {
+	return c.stub.ForgotPassword(ctx, req)
+}
+
+func (c *grpcClient) ResetPassword(ctx context.Context, req *authv1.ResetPasswordRequest) (*authv1.ResetPasswordResponse, error) {
+	return c.stub.ResetPassword(ctx, req)
+}
+
+func (c *grpcClient) VerifyEmail(ctx context.Context, req *authv1.VerifyEmailRequest) (*authv1.VerifyEmailResponse, error) {
+	return c.stub.VerifyEmail(ctx, req)
+}
+
+func (c *grpcClient) ChangePassword(ctx context.Context, req *authv1.ChangePasswordRequest) (*authv1.ChangePasswordResponse, error) {
+	return c.stub.ChangePassword(ctx, req)
+}
+
+func (c *grpcClient) ChangeEmail(ctx context.Context, req *authv1.ChangeEmailRequest) (*authv1.ChangeEmailResponse, error) {
+	return c.stub.ChangeEmail(ctx, req)
+}
+
+func (c *grpcClient) ListSessions(ctx context.Context, req *authv1.ListSessionsRequest) (*authv1.ListSessionsResponse, error) {
+	return c.stub.ListSessions(ctx, req)
+}
+
+func (c *grpcClient) RevokeSession(ctx context.Context, req *authv1.RevokeSessionRequest) (*authv1.RevokeSessionResponse, error) {
+	return c.stub.RevokeSession(ctx, req)
+}
+
+func (c *grpcClient) SetupTwoFactor(ctx context.Context, req *authv1.SetupTwoFactorRequest) (*authv1.SetupTwoFactorResponse, error) {
+	return c.stub.SetupTwoFactor(ctx, req)
+}
+
+func (c *grpcClient) ConfirmTwoFactor(ctx context.Context, req *authv1.ConfirmTwoFactorRequest) (*authv1.ConfirmTwoFactorResponse, error) {
+	return c.stub.ConfirmTwoFactor(ctx, req)
+}
+
+func (c *grpcClient) DisableTwoFactor(ctx context.Context, req *authv1.DisableTwoFactorRequest) (*authv1.DisableTwoFactorResponse, error) {
+	return c.stub.DisableTwoFactor(ctx, req)
+}
+
+func (c *grpcClient) CompleteTwoFactorLogin(ctx context.Context, req *authv1.CompleteTwoFactorLoginRequest) (*authv1.CompleteTwoFactorLoginResponse, error) {
+	return c.stub.CompleteTwoFactorLogin(ctx, req)
+}
+
+func (c *grpcClient) ListUsers(ctx context.Context, req *authv1.ListUsersRequest) (*authv1.ListUsersResponse, error) {
+	return c.stub.ListUsers(ctx, req)
+}
+
+func (c *grpcClient) SetUserRole(ctx context.Context, req *authv1.SetUserRoleRequest) (*authv1.SetUserRoleResponse, error) {
+	return c.stub.SetUserRole(ctx, req)
+}
+
+func (c *grpcClient) DisableUser(ctx context.Context, req *authv1.DisableUserRequest) (*authv1.DisableUserResponse, error) {
+	return c.stub.DisableUser(ctx, req)
+}
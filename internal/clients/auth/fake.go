@@ -0,0 +1,364 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	authv1 "github.com/immxrtalbeast/protos/gen/go/auth/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var _ Client = (*Fake)(nil)
+
+type fakeUser struct {
+	user     *authv1.User
+	password [sha256.Size]byte
+	// totpSecret is set once two-factor authentication is confirmed;
+	// pendingSecret between setup and confirmation.
+	totpSecret    []byte
+	pendingSecret []byte
+	recoveryCodes map[string]bool
+	disabled      bool
+}
+
+// fakeSession is one signed-in device. Its ID survives refresh token
+// rotation.
+type fakeSession struct {
+	userID  string
+	session *authv1.Session
+}
+
+// Fake is an in-memory auth service for handler tests and standalone
+// development. It issues access tokens signed with the gateway secret, so
+// AuthMiddleware accepts them, and forgets everything on restart. It sends no
+// emails: reset and verification tokens are only available via ResetToken and
+// VerifyToken.
+type Fake struct {
+	tokenTTL time.Duration
+	admins   map[string]bool
+
+	mu         sync.Mutex
+	secret     []byte
+	users      map[string]*fakeUser
+	byEmail    map[string]string
+	sessions   map[string]*fakeSession
+	resets     map[string]string
+	verifies   map[string]string
+	challenges map[string]*fakeChallenge
+}
+
+// NewFake makes users registering with one of admins an admin.
+func NewFake(secret string, tokenTTL time.Duration, admins []string) *Fake {
+	f := &Fake{
+		secret:     []byte(secret),
+		tokenTTL:   tokenTTL,
+		admins:     make(map[string]bool, len(admins)),
+		users:      make(map[string]*fakeUser),
+		byEmail:    make(map[string]string),
+		sessions:   make(map[string]*fakeSession),
+		resets:     make(map[string]string),
+		verifies:   make(map[string]string),
+		challenges: make(map[string]*fakeChallenge),
+	}
+	for _, email := range admins {
+		f.admins[strings.ToLower(email)] = true
+	}
+	return f
+}
+
+// SetSecret changes the key the following access tokens are signed with.
+func (f *Fake) SetSecret(secret string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.secret = []byte(secret)
+}
+
+func (f *Fake) Register(_ context.Context, req *authv1.RegisterRequest) (*authv1.RegisterResponse, error) {
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if email == "" || req.Password == "" {
+		return nil, status.Error(codes.InvalidArgument, "email and password are required")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, exists := f.byEmail[email]; exists {
+		return nil, status.Error(codes.AlreadyExists, "user already exists")
+	}
+	now := timestamppb.Now()
+	role := authv1.UserRole_USER_ROLE_USER
+	if f.admins[email] {
+		role = authv1.UserRole_USER_ROLE_ADMIN
+	}
+	user := &authv1.User{Id: randomHex(12), Email: email, Role: role, CreatedAt: now, UpdatedAt: now}
+	f.users[user.Id] = &fakeUser{user: user, password: sha256.Sum256([]byte(req.Password))}
+	f.byEmail[email] = user.Id
+	f.verifies[randomHex(16)] = user.Id
+	return &authv1.RegisterResponse{User: user}, nil
+}
+
+func (f *Fake) Login(ctx context.Context, req *authv1.LoginRequest) (*authv1.LoginResponse, error) {
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	u, ok := f.users[f.byEmail[email]]
+	password := sha256.Sum256([]byte(req.Password))
+	if !ok || subtle.ConstantTimeCompare(u.password[:], password[:]) != 1 {
+		return nil, status.Error(codes.Unauthenticated, "invalid email or password")
+	}
+	if u.disabled {
+		return nil, status.Error(codes.PermissionDenied, "account is disabled")
+	}
+	if u.totpSecret != nil {
+		token := randomHex(24)
+		f.challenges[token] = &fakeChallenge{userID: u.user.Id, expiresAt: time.Now().Add(challengeTTL)}
+		return &authv1.LoginResponse{TwoFactorRequired: true, ChallengeToken: token}, nil
+	}
+	access, refresh, err := f.issueLocked(newFakeSession(ctx, u.user.Id))
+	if err != nil {
+		return nil, err
+	}
+	return &authv1.LoginResponse{AccessToken: access, RefreshToken: refresh, User: u.user}, nil
+}
+
+// RefreshToken rotates the refresh token; the old one stops working.
+func (f *Fake) RefreshToken(ctx context.Context, req *authv1.RefreshTokenRequest) (*authv1.RefreshTokenResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sess, ok := f.sessions[req.RefreshToken]
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "invalid refresh token")
+	}
+	delete(f.sessions, req.RefreshToken)
+	if userAgent, ip := clientFromContext(ctx); userAgent != "" || ip != "" {
+		sess.session.UserAgent, sess.session.Ip = userAgent, ip
+	}
+	sess.session.LastUsedAt = timestamppb.Now()
+	access, refresh, err := f.issueLocked(sess)
+	if err != nil {
+		return nil, err
+	}
+	return &authv1.RefreshTokenResponse{AccessToken: access, RefreshToken: refresh}, nil
+}
+
+func (f *Fake) Logout(_ context.Context, req *authv1.LogoutRequest) (*authv1.LogoutResponse, error) {
+	f.mu.Lock()
+	delete(f.sessions, req.RefreshToken)
+	f.mu.Unlock()
+	return &authv1.LogoutResponse{}, nil
+}
+
+func (f *Fake) GetUser(_ context.Context, req *authv1.GetUserRequest) (*authv1.GetUserResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	u, ok := f.users[req.UserId]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+	return &authv1.GetUserResponse{User: u.user}, nil
+}
+
+func (f *Fake) IsAdmin(_ context.Context, req *authv1.IsAdminRequest) (*authv1.IsAdminResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	u, ok := f.users[req.UserId]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+	return &authv1.IsAdminResponse{IsAdmin: u.user.Role == authv1.UserRole_USER_ROLE_ADMIN}, nil
+}
+
+// ForgotPassword issues a reset token for known emails and succeeds either
+// way, like the real service.
+func (f *Fake) ForgotPassword(_ context.Context, req *authv1.ForgotPasswordRequest) (*authv1.ForgotPasswordResponse, error) {
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if userID, ok := f.byEmail[email]; ok {
+		f.resets[randomHex(16)] = userID
+	}
+	return &authv1.ForgotPasswordResponse{}, nil
+}
+
+// ResetPassword sets a new password and ends all sessions of the user.
+func (f *Fake) ResetPassword(_ context.Context, req *authv1.ResetPasswordRequest) (*authv1.ResetPasswordResponse, error) {
+	if req.NewPassword == "" {
+		return nil, status.Error(codes.InvalidArgument, "password is required")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	userID, ok := f.resets[req.Token]
+	if !ok {
+		return nil, status.Error(codes.InvalidArgument, "invalid or expired token")
+	}
+	delete(f.resets, req.Token)
+	f.users[userID].password = sha256.Sum256([]byte(req.NewPassword))
+	f.endSessionsLocked(userID)
+	return &authv1.ResetPasswordResponse{}, nil
+}
+
+func (f *Fake) VerifyEmail(_ context.Context, req *authv1.VerifyEmailRequest) (*authv1.VerifyEmailResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	userID, ok := f.verifies[req.Token]
+	if !ok {
+		return nil, status.Error(codes.InvalidArgument, "invalid or expired token")
+	}
+	delete(f.verifies, req.Token)
+	return &authv1.VerifyEmailResponse{User: f.users[userID].user}, nil
+}
+
+// ChangePassword checks the current password and ends all sessions of the
+// user.
+func (f *Fake) ChangePassword(_ context.Context, req *authv1.ChangePasswordRequest) (*authv1.ChangePasswordResponse, error) {
+	if req.NewPassword == "" {
+		return nil, status.Error(codes.InvalidArgument, "new password is required")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	u, err := f.checkPasswordLocked(req.UserId, req.CurrentPassword)
+	if err != nil {
+		return nil, err
+	}
+	u.password = sha256.Sum256([]byte(req.NewPassword))
+	f.endSessionsLocked(req.UserId)
+	return &authv1.ChangePasswordResponse{}, nil
+}
+
+func (f *Fake) ChangeEmail(_ context.Context, req *authv1.ChangeEmailRequest) (*authv1.ChangeEmailResponse, error) {
+	email := strings.ToLower(strings.TrimSpace(req.NewEmail))
+	if email == "" {
+		return nil, status.Error(codes.InvalidArgument, "new email is required")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	u, err := f.checkPasswordLocked(req.UserId, req.Password)
+	if err != nil {
+		return nil, err
+	}
+	if _, taken := f.byEmail[email]; taken {
+		return nil, status.Error(codes.AlreadyExists, "email is already in use")
+	}
+	delete(f.byEmail, u.user.Email)
+	f.byEmail[email] = req.UserId
+	u.user.Email = email
+	u.user.UpdatedAt = timestamppb.Now()
+	f.verifies[randomHex(16)] = req.UserId
+	f.endSessionsLocked(req.UserId)
+	return &authv1.ChangeEmailResponse{User: u.user}, nil
+}
+
+func (f *Fake) checkPasswordLocked(userID, password string) (*fakeUser, error) {
+	u, ok := f.users[userID]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+	sum := sha256.Sum256([]byte(password))
+	if subtle.ConstantTimeCompare(u.password[:], sum[:]) != 1 {
+		return nil, status.Error(codes.PermissionDenied, "invalid password")
+	}
+	return u, nil
+}
+
+// ListSessions returns the user's sessions, most recently used first.
+func (f *Fake) ListSessions(_ context.Context, req *authv1.ListSessionsRequest) (*authv1.ListSessionsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var sessions []*authv1.Session
+	for _, sess := range f.sessions {
+		if sess.userID == req.UserId {
+			sessions = append(sessions, sess.session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.AsTime().After(sessions[j].LastUsedAt.AsTime())
+	})
+	return &authv1.ListSessionsResponse{Sessions: sessions}, nil
+}
+
+// RevokeSession ends one session of the user; sessions of other users are
+// reported as not found.
+func (f *Fake) RevokeSession(_ context.Context, req *authv1.RevokeSessionRequest) (*authv1.RevokeSessionResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for refresh, sess := range f.sessions {
+		if sess.userID == req.UserId && sess.session.Id == req.SessionId {
+			delete(f.sessions, refresh)
+			return &authv1.RevokeSessionResponse{}, nil
+		}
+	}
+	return nil, status.Error(codes.NotFound, "session not found")
+}
+
+func (f *Fake) endSessionsLocked(userID string) {
+	for refresh, sess := range f.sessions {
+		if sess.userID == userID {
+			delete(f.sessions, refresh)
+		}
+	}
+}
+
+// ResetToken returns a pending password reset token for email.
+func (f *Fake) ResetToken(email string) (string, bool) {
+	return f.pendingToken(f.resets, email)
+}
+
+// VerifyToken returns the pending email verification token for email.
+func (f *Fake) VerifyToken(email string) (string, bool) {
+	return f.pendingToken(f.verifies, email)
+}
+
+func (f *Fake) pendingToken(tokens map[string]string, email string) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	userID := f.byEmail[strings.ToLower(strings.TrimSpace(email))]
+	for token, owner := range tokens {
+		if userID != "" && owner == userID {
+			return token, true
+		}
+	}
+	return "", false
+}
+
+func newFakeSession(ctx context.Context, userID string) *fakeSession {
+	userAgent, ip := clientFromContext(ctx)
+	now := timestamppb.Now()
+	return &fakeSession{userID: userID, session: &authv1.Session{
+		Id:         randomHex(12),
+		UserAgent:  userAgent,
+		Ip:         ip,
+		CreatedAt:  now,
+		LastUsedAt: now,
+	}}
+}
+
+func (f *Fake) issueLocked(sess *fakeSession) (string, string, error) {
+	now := time.Now()
+	access, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"uid":   sess.userID,
+		"sid":   sess.session.Id,
+		"email": f.users[sess.userID].user.Email,
+		"iat":   now.Unix(),
+		"exp":   now.Add(f.tokenTTL).Unix(),
+	}).SignedString(f.secret)
+	if err != nil {
+		return "", "", status.Error(codes.Internal, "failed to sign token")
+	}
+	refresh := randomHex(32)
+	f.sessions[refresh] = sess
+	return access, refresh, nil
+}
+
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
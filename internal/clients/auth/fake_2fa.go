@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	authv1 "github.com/immxrtalbeast/protos/gen/go/auth/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	challengeTTL         = 5 * time.Minute
+	maxChallengeAttempts = 5
+	totpStep             = 30 * time.Second
+	totpIssuer           = "Madrigal"
+)
+
+// fakeChallenge is a login waiting for its second factor.
+type fakeChallenge struct {
+	userID    string
+	expiresAt time.Time
+	attempts  int
+}
+
+func (f *Fake) SetupTwoFactor(_ context.Context, req *authv1.SetupTwoFactorRequest) (*authv1.SetupTwoFactorResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	u, ok := f.users[req.UserId]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+	if u.totpSecret != nil {
+		return nil, status.Error(codes.FailedPrecondition, "two-factor authentication is already enabled")
+	}
+	u.pendingSecret = make([]byte, 20)
+	rand.Read(u.pendingSecret)
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(u.pendingSecret)
+	otpauth := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + totpIssuer + ":" + u.user.Email,
+		RawQuery: url.Values{"secret": {secret}, "issuer": {totpIssuer}}.Encode(),
+	}
+	return &authv1.SetupTwoFactorResponse{Secret: secret, OtpauthUrl: otpauth.String()}, nil
+}
+
+// ConfirmTwoFactor enables two-factor authentication once the user proves
+// their authenticator produces codes for the pending secret.
+func (f *Fake) ConfirmTwoFactor(_ context.Context, req *authv1.ConfirmTwoFactorRequest) (*authv1.ConfirmTwoFactorResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	u, ok := f.users[req.UserId]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+	if u.pendingSecret == nil {
+		return nil, status.Error(codes.FailedPrecondition, "two-factor setup was not started")
+	}
+	if !validTOTP(u.pendingSecret, req.Code, time.Now()) {
+		return nil, status.Error(codes.PermissionDenied, "invalid code")
+	}
+	u.totpSecret, u.pendingSecret = u.pendingSecret, nil
+	u.recoveryCodes = make(map[string]bool)
+	recovery := make([]string, 8)
+	for i := range recovery {
+		recovery[i] = randomHex(5)
+		u.recoveryCodes[recovery[i]] = true
+	}
+	return &authv1.ConfirmTwoFactorResponse{RecoveryCodes: recovery}, nil
+}
+
+func (f *Fake) DisableTwoFactor(_ context.Context, req *authv1.DisableTwoFactorRequest) (*authv1.DisableTwoFactorResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	u, ok := f.users[req.UserId]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+	if u.totpSecret == nil {
+		return nil, status.Error(codes.FailedPrecondition, "two-factor authentication is not enabled")
+	}
+	if !u.checkSecondFactor(req.Code) {
+		return nil, status.Error(codes.PermissionDenied, "invalid code")
+	}
+	u.totpSecret, u.recoveryCodes = nil, nil
+	return &authv1.DisableTwoFactorResponse{}, nil
+}
+
+// CompleteTwoFactorLogin finishes a login with a TOTP or recovery code. A
+// challenge allows a few attempts before it has to be started over.
+func (f *Fake) CompleteTwoFactorLogin(ctx context.Context, req *authv1.CompleteTwoFactorLoginRequest) (*authv1.CompleteTwoFactorLoginResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ch, ok := f.challenges[req.ChallengeToken]
+	if !ok || time.Now().After(ch.expiresAt) {
+		delete(f.challenges, req.ChallengeToken)
+		return nil, status.Error(codes.Unauthenticated, "invalid or expired challenge")
+	}
+	u := f.users[ch.userID]
+	if !u.checkSecondFactor(req.Code) {
+		if ch.attempts++; ch.attempts >= maxChallengeAttempts {
+			delete(f.challenges, req.ChallengeToken)
+		}
+		return nil, status.Error(codes.Unauthenticated, "invalid code")
+	}
+	delete(f.challenges, req.ChallengeToken)
+	access, refresh, err := f.issueLocked(newFakeSession(ctx, ch.userID))
+	if err != nil {
+		return nil, err
+	}
+	return &authv1.CompleteTwoFactorLoginResponse{AccessToken: access, RefreshToken: refresh, User: u.user}, nil
+}
+
+// TwoFactorCode returns the current TOTP code of the user with email, for
+// their confirmed or pending secret.
+func (f *Fake) TwoFactorCode(email string) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	u, ok := f.users[f.byEmail[strings.ToLower(strings.TrimSpace(email))]]
+	if !ok {
+		return "", false
+	}
+	secret := u.totpSecret
+	if secret == nil {
+		secret = u.pendingSecret
+	}
+	if secret == nil {
+		return "", false
+	}
+	return totpCode(secret, time.Now().Unix()/int64(totpStep/time.Second)), true
+}
+
+// checkSecondFactor accepts a current TOTP code or consumes a recovery code.
+func (u *fakeUser) checkSecondFactor(code string) bool {
+	code = strings.TrimSpace(code)
+	if validTOTP(u.totpSecret, code, time.Now()) {
+		return true
+	}
+	if u.recoveryCodes[code] {
+		delete(u.recoveryCodes, code)
+		return true
+	}
+	return false
+}
+
+// validTOTP checks an RFC 6238 code (SHA-1, 6 digits, 30s steps), allowing
+// one step of clock drift either way.
+func validTOTP(secret []byte, code string, now time.Time) bool {
+	if len(code) != 6 {
+		return false
+	}
+	step := now.Unix() / int64(totpStep/time.Second)
+	for _, s := range []int64{step - 1, step, step + 1} {
+		if hmac.Equal([]byte(totpCode(secret, s)), []byte(code)) {
+			return true
+		}
+	}
+	return false
+}
+
+func totpCode(secret []byte, step int64) string {
+	mac := hmac.New(sha1.New, secret)
+	binary.Write(mac, binary.BigEndian, step)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+	return fmt.Sprintf("%06d", value%1_000_000)
+}
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"context"
+	"sort"
+	"strconv"
+
+	authv1 "github.com/immxrtalbeast/protos/gen/go/auth/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const defaultUsersPageSize = 50
+
+// ListUsers pages through the users by email. The page token is the offset
+// of the next page.
+func (f *Fake) ListUsers(_ context.Context, req *authv1.ListUsersRequest) (*authv1.ListUsersResponse, error) {
+	offset := 0
+	if req.PageToken != "" {
+		n, err := strconv.Atoi(req.PageToken)
+		if err != nil || n < 0 {
+			return nil, status.Error(codes.InvalidArgument, "invalid page token")
+		}
+		offset = n
+	}
+	size := int(req.PageSize)
+	if size <= 0 {
+		size = defaultUsersPageSize
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	users := make([]*authv1.User, 0, len(f.users))
+	for _, u := range f.users {
+		users = append(users, u.user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Email < users[j].Email })
+	if offset > len(users) {
+		offset = len(users)
+	}
+	end := min(offset+size, len(users))
+	resp := &authv1.ListUsersResponse{Users: users[offset:end]}
+	if end < len(users) {
+		resp.NextPageToken = strconv.Itoa(end)
+	}
+	return resp, nil
+}
+
+func (f *Fake) SetUserRole(_ context.Context, req *authv1.SetUserRoleRequest) (*authv1.SetUserRoleResponse, error) {
+	if req.Role != authv1.UserRole_USER_ROLE_USER && req.Role != authv1.UserRole_USER_ROLE_ADMIN {
+		return nil, status.Error(codes.InvalidArgument, "unknown role")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	u, ok := f.users[req.UserId]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+	u.user.Role = req.Role
+	u.user.UpdatedAt = timestamppb.Now()
+	return &authv1.SetUserRoleResponse{User: u.user}, nil
+}
+
+// DisableUser refuses the user's logins from now on and ends their sessions
+// and pending two-factor logins.
+func (f *Fake) DisableUser(_ context.Context, req *authv1.DisableUserRequest) (*authv1.DisableUserResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	u, ok := f.users[req.UserId]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+	u.disabled = true
+	f.endSessionsLocked(req.UserId)
+	for token, challenge := range f.challenges {
+		if challenge.userID == req.UserId {
+			delete(f.challenges, token)
+		}
+	}
+	return &authv1.DisableUserResponse{}, nil
+}
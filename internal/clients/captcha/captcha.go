@@ -0,0 +1,70 @@
+// Package captcha verifies CAPTCHA tokens with the provider's siteverify
+// endpoint. reCAPTCHA, hCaptcha and Turnstile share the same protocol.
+package captcha
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type Client struct {
+	verifyURL string
+	secret    string
+	http      *http.Client
+}
+
+// New creates a client posting tokens to verifyURL with secret. A nil
+// transport uses http.DefaultTransport.
+func New(verifyURL, secret string, timeout time.Duration, transport http.RoundTripper) (*Client, error) {
+	if verifyURL == "" {
+		return nil, fmt.Errorf("verify url is required")
+	}
+	parsed, err := url.Parse(verifyURL)
+	if err != nil || parsed.Scheme == "" {
+		return nil, fmt.Errorf("invalid verify url %q", verifyURL)
+	}
+	if secret == "" {
+		return nil, fmt.Errorf("secret is required")
+	}
+	return &Client{
+		verifyURL: parsed.String(),
+		secret:    secret,
+		http:      &http.Client{Timeout: timeout, Transport: transport},
+	}, nil
+}
+
+// Verify reports whether the provider accepts token, solved by the client at
+// remoteIP.
+func (c *Client) Verify(ctx context.Context, token, remoteIP string) (bool, error) {
+	form := url.Values{"secret": {c.secret}, "response": {token}}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.verifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("captcha verify request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return false, fmt.Errorf("captcha verify returned %d", resp.StatusCode)
+	}
+	var result struct {
+		Success bool `json:"success"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&result); err != nil {
+		return false, fmt.Errorf("decode captcha verify response: %w", err)
+	}
+	return result.Success, nil
+}
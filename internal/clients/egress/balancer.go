@@ -0,0 +1,263 @@
+package egress
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/immxrtalbeast/api-gateway/internal/metrics"
+)
+
+// Load balancing policies of a Balancer.
+const (
+	PolicyRoundRobin       = "round_robin"
+	PolicyLeastConnections = "least_connections"
+)
+
+type BalancerConfig struct {
+	Policy          string
+	RefreshInterval time.Duration
+	RefreshTimeout  time.Duration
+	// MaxFailures consecutive failed requests (connection errors, 502, 503
+	// and 504) take an endpoint out of rotation for EjectDuration. Zero
+	// disables ejection.
+	MaxFailures   int
+	EjectDuration time.Duration
+}
+
+type balancedEndpoint struct {
+	url    *url.URL
+	active atomic.Int64
+
+	// Guarded by Balancer.mu.
+	failures     int
+	ejectedUntil time.Time
+}
+
+// Balancer is a transport spreading the requests addressed to an upstream's
+// base URL over the endpoints listed by a Source, refreshed every
+// RefreshInterval. Endpoints failing repeatedly are ejected for a while; when
+// all of them are, requests go to all of them anyway. A failed refresh keeps
+// the known endpoints.
+type Balancer struct {
+	name   string
+	base   string
+	source Source
+	cfg    BalancerConfig
+	next   http.RoundTripper
+	log    *slog.Logger
+	turn   atomic.Uint32
+
+	mu        sync.RWMutex
+	endpoints []*balancedEndpoint
+}
+
+func NewBalancer(name, baseURL string, source Source, cfg BalancerConfig, next http.RoundTripper, log *slog.Logger) (*Balancer, error) {
+	switch cfg.Policy {
+	case "":
+		cfg.Policy = PolicyRoundRobin
+	case PolicyRoundRobin, PolicyLeastConnections:
+	default:
+		return nil, fmt.Errorf("unknown balancing policy %q", cfg.Policy)
+	}
+	if cfg.RefreshInterval <= 0 {
+		cfg.RefreshInterval = 30 * time.Second
+	}
+	if cfg.RefreshTimeout <= 0 {
+		cfg.RefreshTimeout = 2 * time.Second
+	}
+	if cfg.EjectDuration <= 0 {
+		cfg.EjectDuration = 30 * time.Second
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	b := &Balancer{
+		name:   name,
+		base:   strings.TrimRight(baseURL, "/"),
+		source: source,
+		cfg:    cfg,
+		next:   next,
+		log:    log,
+	}
+	metrics.Discovery.Set(name+"_endpoints", expvar.Func(func() any {
+		b.mu.RLock()
+		defer b.mu.RUnlock()
+		return len(b.endpoints)
+	}))
+	return b, nil
+}
+
+// Run lists the endpoints once before returning, then refreshes them until
+// ctx is done.
+func (b *Balancer) Run(ctx context.Context) {
+	b.refresh(ctx)
+	go func() {
+		ticker := time.NewTicker(b.cfg.RefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				b.refresh(ctx)
+			}
+		}
+	}()
+}
+
+func (b *Balancer) refresh(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, b.cfg.RefreshTimeout)
+	defer cancel()
+	listed, err := b.source.Endpoints(ctx)
+	if err == nil && len(listed) == 0 {
+		err = errors.New("no endpoints")
+	}
+	if err != nil {
+		metrics.Discovery.Add(b.name+"_refresh_errors", 1)
+		b.log.Warn("upstream discovery failed", slog.String("upstream", b.name), slog.String("err", err.Error()))
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	known := make(map[string]*balancedEndpoint, len(b.endpoints))
+	for _, e := range b.endpoints {
+		known[e.url.String()] = e
+	}
+	endpoints := make([]*balancedEndpoint, 0, len(listed))
+	for _, raw := range listed {
+		u, err := url.Parse(strings.TrimRight(raw, "/"))
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			b.log.Warn("invalid discovered endpoint", slog.String("upstream", b.name), slog.String("endpoint", raw))
+			continue
+		}
+		// Endpoints still listed keep their failures and ejection.
+		if e, ok := known[u.String()]; ok {
+			endpoints = append(endpoints, e)
+			delete(known, u.String())
+			continue
+		}
+		endpoints = append(endpoints, &balancedEndpoint{url: u})
+	}
+	if len(endpoints) == 0 {
+		return
+	}
+	if len(known) > 0 || len(endpoints) != len(b.endpoints) {
+		b.log.Info("upstream endpoints changed", slog.String("upstream", b.name), slog.Int("endpoints", len(endpoints)))
+	}
+	b.endpoints = endpoints
+}
+
+func (b *Balancer) RoundTrip(req *http.Request) (*http.Response, error) {
+	u := req.URL.String()
+	if !strings.HasPrefix(u, b.base) {
+		return b.next.RoundTrip(req)
+	}
+	endpoint := b.pick()
+	if endpoint == nil {
+		return nil, fmt.Errorf("no endpoints discovered for %s", b.name)
+	}
+	target, err := url.Parse(endpoint.url.String() + strings.TrimPrefix(u, b.base))
+	if err != nil {
+		return nil, err
+	}
+	out := req.Clone(req.Context())
+	out.URL = target
+	out.Host = ""
+
+	endpoint.active.Add(1)
+	resp, err := b.next.RoundTrip(out)
+	b.record(req.Context(), endpoint, resp, err)
+	if err != nil {
+		endpoint.active.Add(-1)
+		return nil, err
+	}
+	// The connection stays busy until the body is read.
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: func() { endpoint.active.Add(-1) }}
+	return resp, nil
+}
+
+func (b *Balancer) pick() *balancedEndpoint {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.endpoints) == 0 {
+		return nil
+	}
+	now := time.Now()
+	candidates := make([]*balancedEndpoint, 0, len(b.endpoints))
+	for _, e := range b.endpoints {
+		if now.After(e.ejectedUntil) {
+			candidates = append(candidates, e)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = b.endpoints
+	}
+	start := int(b.turn.Add(1)) % len(candidates)
+	if b.cfg.Policy == PolicyRoundRobin {
+		return candidates[start]
+	}
+	// Ties go round-robin, so idle endpoints share the load.
+	best := candidates[start]
+	for i := 1; i < len(candidates); i++ {
+		e := candidates[(start+i)%len(candidates)]
+		if e.active.Load() < best.active.Load() {
+			best = e
+		}
+	}
+	return best
+}
+
+func (b *Balancer) record(ctx context.Context, endpoint *balancedEndpoint, resp *http.Response, err error) {
+	if b.cfg.MaxFailures <= 0 || ctx.Err() != nil {
+		return
+	}
+	failed := err != nil
+	if resp != nil {
+		switch resp.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			failed = true
+		}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !failed {
+		endpoint.failures = 0
+		return
+	}
+	endpoint.failures++
+	if endpoint.failures < b.cfg.MaxFailures {
+		return
+	}
+	endpoint.failures = 0
+	endpoint.ejectedUntil = time.Now().Add(b.cfg.EjectDuration)
+	metrics.Discovery.Add(b.name+"_ejections", 1)
+	b.log.Warn("upstream endpoint ejected",
+		slog.String("upstream", b.name),
+		slog.String("endpoint", endpoint.url.String()),
+		slog.Duration("for", b.cfg.EjectDuration),
+	)
+}
+
+// releaseBody calls release once, when the body is closed.
+type releaseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (r *releaseBody) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
@@ -0,0 +1,104 @@
+package egress
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/immxrtalbeast/api-gateway/internal/metrics"
+)
+
+// Canary overrides a client may ask for, see WithCanaryOverride.
+const (
+	CanaryAlways = "always"
+	CanaryNever  = "never"
+)
+
+// CanaryHeader tells the upstream that the request was routed to the canary.
+const CanaryHeader = "X-Gateway-Canary"
+
+type canaryOverrideKey struct{}
+
+// WithCanaryOverride records a client's request to always or never be routed
+// to canaries.
+func WithCanaryOverride(ctx context.Context, override string) context.Context {
+	return context.WithValue(ctx, canaryOverrideKey{}, override)
+}
+
+func canaryOverride(ctx context.Context) string {
+	override, _ := ctx.Value(canaryOverrideKey{}).(string)
+	return override
+}
+
+// Canary is a transport sending Weight percent of the requests addressed to
+// an upstream's base URL to a second version of it. Users stick to one
+// version: the choice hashes the X-User-ID header, and only anonymous
+// requests are spread at random.
+type Canary struct {
+	name   string
+	base   string
+	canary *url.URL
+	weight float64
+	next   http.RoundTripper
+}
+
+func NewCanary(name, baseURL, canaryURL string, weight float64, next http.RoundTripper) (*Canary, error) {
+	if weight < 0 || weight > 100 {
+		return nil, fmt.Errorf("canary weight %v must be between 0 and 100", weight)
+	}
+	u, err := url.Parse(strings.TrimRight(canaryURL, "/"))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid canary url %q", canaryURL)
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Canary{
+		name:   name,
+		base:   strings.TrimRight(baseURL, "/"),
+		canary: u,
+		weight: weight,
+		next:   next,
+	}, nil
+}
+
+func (t *Canary) RoundTrip(req *http.Request) (*http.Response, error) {
+	u := req.URL.String()
+	if !strings.HasPrefix(u, t.base) {
+		return t.next.RoundTrip(req)
+	}
+	if !t.chosen(req) {
+		metrics.Canary.Add(t.name+"_primary", 1)
+		return t.next.RoundTrip(req)
+	}
+	target, err := url.Parse(t.canary.String() + strings.TrimPrefix(u, t.base))
+	if err != nil {
+		return nil, err
+	}
+	out := req.Clone(req.Context())
+	out.URL = target
+	out.Host = ""
+	out.Header.Set(CanaryHeader, "true")
+	metrics.Canary.Add(t.name+"_canary", 1)
+	return t.next.RoundTrip(out)
+}
+
+func (t *Canary) chosen(req *http.Request) bool {
+	switch canaryOverride(req.Context()) {
+	case CanaryAlways:
+		return true
+	case CanaryNever:
+		return false
+	}
+	userID := req.Header.Get("X-User-ID")
+	if userID == "" {
+		return rand.Float64()*100 < t.weight
+	}
+	h := fnv.New32a()
+	h.Write([]byte(userID))
+	return float64(h.Sum32()%10000) < t.weight*100
+}
@@ -0,0 +1,51 @@
+package egress
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Headers telling an upstream when the gateway stops waiting for its answer,
+// so it can abandon work nobody will receive. DeadlineHeader is the absolute
+// time (RFC 3339, UTC, milliseconds); TimeoutHeader the time left when the
+// request was sent, in the grpc-timeout format ("4980m"), which doesn't
+// depend on synchronized clocks.
+const (
+	DeadlineHeader = "X-Request-Deadline"
+	TimeoutHeader  = "X-Request-Timeout"
+)
+
+// Deadline adds the deadline headers to the requests whose context has a
+// deadline. Headers set by the caller are replaced.
+func Deadline(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &deadlineTransport{next: next}
+}
+
+type deadlineTransport struct {
+	next http.RoundTripper
+}
+
+func (t *deadlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	deadline, ok := req.Context().Deadline()
+	if !ok {
+		return t.next.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set(DeadlineHeader, deadline.UTC().Format("2006-01-02T15:04:05.000Z07:00"))
+	req.Header.Set(TimeoutHeader, grpcTimeout(time.Until(deadline)))
+	return t.next.RoundTrip(req)
+}
+
+// grpcTimeout formats d like the grpc-timeout header: at most 8 digits and a
+// unit, milliseconds unless that needs more digits.
+func grpcTimeout(d time.Duration) string {
+	ms := max(d.Milliseconds(), 0)
+	if ms < 1e8 {
+		return strconv.FormatInt(ms, 10) + "m"
+	}
+	return strconv.FormatInt(int64(d/time.Second), 10) + "S"
+}
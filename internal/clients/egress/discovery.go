@@ -0,0 +1,109 @@
+package egress
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Source lists the endpoints of an upstream as base URLs, e.g.
+// "http://10.0.3.7:8100".
+type Source interface {
+	Endpoints(ctx context.Context) ([]string, error)
+}
+
+// StaticSource is a fixed list of endpoints.
+type StaticSource []string
+
+func (s StaticSource) Endpoints(context.Context) ([]string, error) {
+	return s, nil
+}
+
+// SRVSource looks endpoints up in the DNS SRV records of name, such as the
+// "_http._tcp.video-service.default.svc.cluster.local" records Kubernetes
+// serves for the named ports of headless services.
+type SRVSource struct {
+	Name   string
+	Scheme string
+}
+
+func (s SRVSource) Endpoints(ctx context.Context) ([]string, error) {
+	_, records, err := net.DefaultResolver.LookupSRV(ctx, "", "", s.Name)
+	if err != nil {
+		return nil, err
+	}
+	endpoints := make([]string, 0, len(records))
+	for _, record := range records {
+		host := strings.TrimSuffix(record.Target, ".")
+		endpoints = append(endpoints, s.Scheme+"://"+net.JoinHostPort(host, strconv.Itoa(int(record.Port))))
+	}
+	return endpoints, nil
+}
+
+// ConsulConfig is the Consul agent queried by ConsulSource.
+type ConsulConfig struct {
+	Address    string
+	Token      string
+	Datacenter string
+}
+
+// ConsulSource lists the instances of Service passing their Consul health
+// checks.
+type ConsulSource struct {
+	Client  *http.Client
+	Consul  ConsulConfig
+	Service string
+	Scheme  string
+}
+
+type consulEntry struct {
+	Node struct {
+		Address string
+	}
+	Service struct {
+		Address string
+		Port    int
+	}
+}
+
+func (s ConsulSource) Endpoints(ctx context.Context) ([]string, error) {
+	query := url.Values{"passing": {"true"}}
+	if s.Consul.Datacenter != "" {
+		query.Set("dc", s.Consul.Datacenter)
+	}
+	endpoint := strings.TrimRight(s.Consul.Address, "/") + "/v1/health/service/" + url.PathEscape(s.Service) + "?" + query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.Consul.Token != "" {
+		req.Header.Set("X-Consul-Token", s.Consul.Token)
+	}
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("consul request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("consul returned status %d", resp.StatusCode)
+	}
+	var entries []consulEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("decode consul response: %w", err)
+	}
+	endpoints := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		// Services registered without an address use their node's.
+		host := entry.Service.Address
+		if host == "" {
+			host = entry.Node.Address
+		}
+		endpoints = append(endpoints, s.Scheme+"://"+net.JoinHostPort(host, strconv.Itoa(entry.Service.Port)))
+	}
+	return endpoints, nil
+}
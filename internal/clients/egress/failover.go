@@ -0,0 +1,119 @@
+package egress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// WithFailover returns a copy of transport whose connections to the primary
+// base URL race a connection to the standby one when the primary hasn't
+// connected within delay (or failed outright). Only the TCP connection is
+// raced, so requests are never sent twice. The standby must accept the
+// primary's Host header and, for https, present a certificate for it.
+func WithFailover(transport *http.Transport, primaryURL, standbyURL string, delay time.Duration) (*http.Transport, error) {
+	primary, err := hostPort(primaryURL)
+	if err != nil {
+		return nil, fmt.Errorf("primary url: %w", err)
+	}
+	standby, err := hostPort(standbyURL)
+	if err != nil {
+		return nil, fmt.Errorf("standby url: %w", err)
+	}
+	dial := DialFunc(transport.DialContext)
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	clone := transport.Clone()
+	clone.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		if address != primary {
+			return dial(ctx, network, address)
+		}
+		return raceDial(ctx, dial, network, primary, standby, delay)
+	}
+	return clone, nil
+}
+
+type dialResult struct {
+	conn net.Conn
+	err  error
+}
+
+func raceDial(ctx context.Context, dial DialFunc, network, primary, standby string, delay time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan dialResult, 2)
+	start := func(address string) {
+		go func() {
+			conn, err := dial(ctx, network, address)
+			results <- dialResult{conn: conn, err: err}
+		}()
+	}
+	start(primary)
+	pending, standbyStarted := 1, false
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var errs []error
+	for {
+		select {
+		case <-timer.C:
+			if !standbyStarted {
+				standbyStarted = true
+				pending++
+				start(standby)
+			}
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				if pending > 0 {
+					go closeLosers(results, pending)
+				}
+				return res.conn, nil
+			}
+			errs = append(errs, res.err)
+			if !standbyStarted && ctx.Err() == nil {
+				standbyStarted = true
+				pending++
+				start(standby)
+				continue
+			}
+			if pending == 0 {
+				return nil, errors.Join(errs...)
+			}
+		}
+	}
+}
+
+// closeLosers closes connections that completed after the race was won.
+func closeLosers(results <-chan dialResult, pending int) {
+	for ; pending > 0; pending-- {
+		if res := <-results; res.conn != nil {
+			res.conn.Close()
+		}
+	}
+}
+
+func hostPort(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	if u.Hostname() == "" {
+		return "", fmt.Errorf("url must include host")
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port), nil
+}
@@ -0,0 +1,169 @@
+package egress
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/immxrtalbeast/api-gateway/internal/metrics"
+)
+
+const (
+	// hedgeWindow is the number of recent response times the hedge delay is
+	// computed from.
+	hedgeWindow = 1000
+	// hedgeMinSamples response times are needed before the percentile
+	// replaces InitialDelay.
+	hedgeMinSamples = 50
+	// hedgeRecompute is how many new response times trigger recomputing the
+	// percentile.
+	hedgeRecompute = 50
+)
+
+type HedgeConfig struct {
+	// Percentile of the recent response times after which a second attempt
+	// is sent, e.g. 95.
+	Percentile float64
+	// InitialDelay is used until enough response times were seen.
+	InitialDelay time.Duration
+	// MinDelay and MaxDelay bound the computed delay.
+	MinDelay time.Duration
+	MaxDelay time.Duration
+}
+
+// Hedge sends a second attempt of GET and HEAD requests that haven't
+// received a response within the configured percentile of recent response
+// times, and returns whichever response arrives first, canceling the other
+// attempt. A failed attempt waits for the other one, if started; it doesn't
+// trigger a new one.
+func Hedge(name string, cfg HedgeConfig, next http.RoundTripper) http.RoundTripper {
+	if cfg.Percentile <= 0 || cfg.Percentile >= 100 {
+		cfg.Percentile = 95
+	}
+	if cfg.InitialDelay <= 0 {
+		cfg.InitialDelay = 300 * time.Millisecond
+	}
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = 2 * time.Second
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &hedgeTransport{name: name, cfg: cfg, next: next, delay: cfg.InitialDelay}
+}
+
+type hedgeTransport struct {
+	name string
+	cfg  HedgeConfig
+	next http.RoundTripper
+
+	mu      sync.Mutex
+	samples []time.Duration
+	pos     int
+	fresh   int
+	delay   time.Duration
+}
+
+type hedgeResult struct {
+	resp    *http.Response
+	err     error
+	attempt int
+}
+
+func (t *hedgeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if (req.Method != http.MethodGet && req.Method != http.MethodHead) || (req.Body != nil && req.Body != http.NoBody) {
+		return t.next.RoundTrip(req)
+	}
+	start := time.Now()
+	results := make(chan hedgeResult, 2)
+	var cancels []context.CancelFunc
+	attempt := func() {
+		ctx, cancel := context.WithCancel(req.Context())
+		cancels = append(cancels, cancel)
+		out := req.Clone(ctx)
+		n := len(cancels) - 1
+		go func() {
+			resp, err := t.next.RoundTrip(out)
+			results <- hedgeResult{resp: resp, err: err, attempt: n}
+		}()
+	}
+	attempt()
+	pending := 1
+
+	timer := time.NewTimer(t.currentDelay())
+	defer timer.Stop()
+	var lastErr error
+	for {
+		select {
+		case <-timer.C:
+			if pending == 1 && lastErr == nil {
+				metrics.Hedging.Add(t.name+"_hedged", 1)
+				attempt()
+				pending++
+			}
+		case result := <-results:
+			pending--
+			if result.err != nil {
+				cancels[result.attempt]()
+				lastErr = result.err
+				if pending == 0 {
+					return nil, lastErr
+				}
+				continue
+			}
+			t.record(time.Since(start))
+			if result.attempt > 0 {
+				metrics.Hedging.Add(t.name+"_hedge_wins", 1)
+			}
+			for i, cancel := range cancels {
+				if i != result.attempt {
+					cancel()
+				}
+			}
+			if pending > 0 {
+				go discardLoser(results)
+			}
+			// The winner's context lives until its body is closed.
+			result.resp.Body = &releaseBody{ReadCloser: result.resp.Body, release: cancels[result.attempt]}
+			return result.resp, nil
+		}
+	}
+}
+
+// discardLoser closes the response of the canceled attempt, if it got one.
+func discardLoser(results <-chan hedgeResult) {
+	loser := <-results
+	if loser.resp != nil {
+		loser.resp.Body.Close()
+	}
+}
+
+func (t *hedgeTransport) currentDelay() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.delay
+}
+
+// record adds a response time to the window and recomputes the delay every
+// hedgeRecompute samples.
+func (t *hedgeTransport) record(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.samples) < hedgeWindow {
+		t.samples = append(t.samples, d)
+	} else {
+		t.samples[t.pos] = d
+		t.pos = (t.pos + 1) % hedgeWindow
+	}
+	t.fresh++
+	if len(t.samples) < hedgeMinSamples || t.fresh < hedgeRecompute {
+		return
+	}
+	t.fresh = 0
+	sorted := slices.Clone(t.samples)
+	slices.Sort(sorted)
+	delay := sorted[int(float64(len(sorted)-1)*t.cfg.Percentile/100)]
+	t.delay = min(max(delay, t.cfg.MinDelay), t.cfg.MaxDelay)
+}
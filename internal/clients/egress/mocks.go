@@ -0,0 +1,133 @@
+package egress
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MockRoute is a canned upstream response. Path is relative to the service
+// base URL; ":name" segments match any value and a trailing "/*" anything
+// below. "{name}" in Body is replaced with the matched value.
+type MockRoute struct {
+	Method  string
+	Path    string
+	Status  int
+	Latency time.Duration
+	Headers map[string]string
+	Body    []byte
+}
+
+// Mocks answers the requests matching one of the routes with its canned
+// response, after its latency, instead of calling the upstream; the first
+// matching route wins. Other requests go to next.
+type Mocks struct {
+	prefix string
+	routes []MockRoute
+	next   http.RoundTripper
+}
+
+func NewMocks(baseURL string, routes []MockRoute, next http.RoundTripper) (*Mocks, error) {
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base url: %w", err)
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	for i, route := range routes {
+		if !strings.HasPrefix(route.Path, "/") {
+			return nil, fmt.Errorf("mock route %d: path must start with /", i)
+		}
+	}
+	return &Mocks{prefix: strings.TrimRight(parsed.Path, "/"), routes: routes, next: next}, nil
+}
+
+func (m *Mocks) RoundTrip(req *http.Request) (*http.Response, error) {
+	path := strings.TrimPrefix(req.URL.Path, m.prefix)
+	for _, route := range m.routes {
+		if route.Method != "" && !strings.EqualFold(route.Method, req.Method) {
+			continue
+		}
+		params, ok := MatchPath(route.Path, path)
+		if !ok {
+			continue
+		}
+		return m.respond(req, route, params)
+	}
+	return m.next.RoundTrip(req)
+}
+
+func (m *Mocks) respond(req *http.Request, route MockRoute, params map[string]string) (*http.Response, error) {
+	if req.Body != nil {
+		io.Copy(io.Discard, req.Body)
+		req.Body.Close()
+	}
+	if route.Latency > 0 {
+		timer := time.NewTimer(route.Latency)
+		defer timer.Stop()
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+	body := route.Body
+	for name, value := range params {
+		body = bytes.ReplaceAll(body, []byte("{"+name+"}"), []byte(value))
+	}
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	header := make(http.Header, len(route.Headers)+1)
+	header.Set("Content-Type", "application/json")
+	for name, value := range route.Headers {
+		header.Set(name, value)
+	}
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	return &http.Response{
+		Status:        strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// MatchPath matches path against pattern, where ":name" segments match any
+// value and a trailing "/*" anything below, returning the matched values.
+func MatchPath(pattern, path string) (map[string]string, bool) {
+	prefix, wildcard := strings.CutSuffix(pattern, "/*")
+	if wildcard && strings.Trim(prefix, "/") == "" {
+		return map[string]string{}, true
+	}
+	parts := strings.Split(strings.Trim(prefix, "/"), "/")
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) < len(parts) || (!wildcard && len(segments) != len(parts)) {
+		return nil, false
+	}
+	return matchSegments(parts, segments[:len(parts)], make(map[string]string))
+}
+
+func matchSegments(parts, segments []string, params map[string]string) (map[string]string, bool) {
+	for i, part := range parts {
+		if name, ok := strings.CutPrefix(part, ":"); ok {
+			params[name] = segments[i]
+			continue
+		}
+		if part != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
@@ -0,0 +1,41 @@
+package egress
+
+import (
+	"net/http"
+	"time"
+)
+
+// PoolConfig tunes the connection pool of the upstream HTTP transport. Zero
+// values keep the net/http defaults, whose 2 idle connections per host make
+// busy upstreams open a new connection for most requests under load.
+type PoolConfig struct {
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost caps all connections to one host, idle or not; further
+	// requests wait for one to free up.
+	MaxConnsPerHost     int
+	IdleConnTimeout     time.Duration
+	TLSHandshakeTimeout time.Duration
+	// DisableCompression stops asking upstreams for gzip, leaving their
+	// responses as sent.
+	DisableCompression bool
+}
+
+func (p PoolConfig) apply(transport *http.Transport) {
+	if p.MaxIdleConns > 0 {
+		transport.MaxIdleConns = p.MaxIdleConns
+	}
+	if p.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = p.MaxIdleConnsPerHost
+	}
+	if p.MaxConnsPerHost > 0 {
+		transport.MaxConnsPerHost = p.MaxConnsPerHost
+	}
+	if p.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = p.IdleConnTimeout
+	}
+	if p.TLSHandshakeTimeout > 0 {
+		transport.TLSHandshakeTimeout = p.TLSHandshakeTimeout
+	}
+	transport.DisableCompression = p.DisableCompression
+}
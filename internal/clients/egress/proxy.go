@@ -0,0 +1,202 @@
+// Package egress configures how the gateway reaches its upstreams: the
+// optional corporate proxy in front of outbound connections and custom name
+// resolution.
+package egress
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/net/proxy"
+)
+
+// ProxyConfig describes the egress proxy. URL accepts http:// and https://
+// (HTTP CONNECT) as well as socks5:// and socks5h:// proxies, with optional
+// user:password credentials. NoProxy uses the NO_PROXY syntax.
+type ProxyConfig struct {
+	URL     string
+	NoProxy string
+}
+
+type DialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// Dial and DialContext let a DialFunc act as the forward dialer of a SOCKS
+// proxy.
+func (f DialFunc) Dial(network, address string) (net.Conn, error) {
+	return f(context.Background(), network, address)
+}
+
+func (f DialFunc) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return f(ctx, network, address)
+}
+
+// HTTPTransport returns the transport shared by the upstream HTTP clients,
+// with its pool tuned by pool. dial opens the TCP connections (to the proxy,
+// if any); nil keeps the default dialer.
+func HTTPTransport(cfg ProxyConfig, pool PoolConfig, dial DialFunc) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	pool.apply(transport)
+	if dial != nil {
+		transport.DialContext = dial
+	}
+	if cfg.URL == "" {
+		return transport, nil
+	}
+	if _, err := parseProxyURL(cfg.URL); err != nil {
+		return nil, err
+	}
+	proxyFunc := cfg.proxyFunc()
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}
+	return transport, nil
+}
+
+// Dialer returns a dial function that tunnels raw TCP connections through
+// the proxy, for clients that don't speak HTTP such as Kafka. Addresses
+// matched by NoProxy are dialed directly with dial (nil for net.Dialer).
+func Dialer(cfg ProxyConfig, dial DialFunc) (DialFunc, error) {
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	if cfg.URL == "" {
+		return dial, nil
+	}
+	proxyURL, err := parseProxyURL(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	var tunnel DialFunc
+	switch proxyURL.Scheme {
+	case "socks5", "socks5h":
+		d, err := proxy.FromURL(proxyURL, dial)
+		if err != nil {
+			return nil, fmt.Errorf("socks proxy: %w", err)
+		}
+		tunnel = d.(proxy.ContextDialer).DialContext
+	default:
+		tunnel = (&connectDialer{proxyURL: proxyURL, dial: dial}).DialContext
+	}
+
+	proxyFunc := cfg.proxyFunc()
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		// NoProxy matching only looks at the host, so any scheme the
+		// proxy config knows about will do.
+		target, err := proxyFunc(&url.URL{Scheme: "https", Host: address})
+		if err != nil {
+			return nil, err
+		}
+		if target == nil {
+			return dial(ctx, network, address)
+		}
+		return tunnel(ctx, network, address)
+	}, nil
+}
+
+func (cfg ProxyConfig) proxyFunc() func(*url.URL) (*url.URL, error) {
+	return (&httpproxy.Config{
+		HTTPProxy:  cfg.URL,
+		HTTPSProxy: cfg.URL,
+		NoProxy:    cfg.NoProxy,
+	}).ProxyFunc()
+}
+
+func parseProxyURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy url: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("proxy url must include host")
+	}
+	return u, nil
+}
+
+// connectDialer opens TCP tunnels with HTTP CONNECT.
+type connectDialer struct {
+	proxyURL *url.URL
+	dial     DialFunc
+}
+
+func (d *connectDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	proxyAddr := d.proxyURL.Host
+	if d.proxyURL.Port() == "" {
+		port := "80"
+		if d.proxyURL.Scheme == "https" {
+			port = "443"
+		}
+		proxyAddr = net.JoinHostPort(d.proxyURL.Hostname(), port)
+	}
+	conn, err := d.dial(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, fmt.Errorf("dial proxy: %w", err)
+	}
+	if d.proxyURL.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: d.proxyURL.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("proxy tls handshake: %w", err)
+		}
+		conn = tlsConn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+	if user := d.proxyURL.User; user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("write connect request: %w", err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("read connect response: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy refused tunnel to %s: %s", address, resp.Status)
+	}
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn drains bytes the proxy sent right after its CONNECT response
+// before reading from the connection again.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
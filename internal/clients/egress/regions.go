@@ -0,0 +1,234 @@
+package egress
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/immxrtalbeast/api-gateway/internal/metrics"
+)
+
+// Region is a deployment of an upstream tagged with the region it runs in.
+type Region struct {
+	Name    string
+	BaseURL string
+}
+
+type RegionsConfig struct {
+	// HealthPath is probed on every region each ProbeInterval: the probe round
+	// trip ranks the regions and a failed probe takes one out of rotation until
+	// the next successful one.
+	HealthPath    string
+	ProbeInterval time.Duration
+	ProbeTimeout  time.Duration
+	// Preferred maps a client region (see WithClientRegion) to the region
+	// serving it. Clients without a mapping, or whose region is down, get the
+	// healthy region with the lowest probe latency.
+	Preferred map[string]string
+	// HintHeader tells the upstream which region the request was routed to.
+	HintHeader string
+}
+
+type clientRegionKey struct{}
+
+// WithClientRegion records the region of the calling client, as reported by
+// the edge, for the Regions transport.
+func WithClientRegion(ctx context.Context, region string) context.Context {
+	return context.WithValue(ctx, clientRegionKey{}, region)
+}
+
+func clientRegion(ctx context.Context) string {
+	region, _ := ctx.Value(clientRegionKey{}).(string)
+	return region
+}
+
+// latencyWeight is the weight of the newest probe in the latency average.
+const latencyWeight = 0.3
+
+type regionState struct {
+	Region
+	url     *url.URL
+	healthy bool
+	latency time.Duration
+}
+
+// Regions is a transport sending the requests addressed to an upstream's base
+// URL to one of its regions instead. Until the first probes complete, regions
+// are ranked in configuration order; when every region is down, requests go
+// to the preferred or first one anyway.
+type Regions struct {
+	name   string
+	base   string
+	cfg    RegionsConfig
+	next   http.RoundTripper
+	probes *http.Client
+	log    *slog.Logger
+
+	mu      sync.RWMutex
+	regions []*regionState
+}
+
+func NewRegions(name, baseURL string, regions []Region, cfg RegionsConfig, next http.RoundTripper, log *slog.Logger) (*Regions, error) {
+	if len(regions) == 0 {
+		return nil, fmt.Errorf("no regions configured")
+	}
+	if cfg.ProbeInterval <= 0 {
+		cfg.ProbeInterval = 10 * time.Second
+	}
+	if cfg.ProbeTimeout <= 0 {
+		cfg.ProbeTimeout = 2 * time.Second
+	}
+	r := &Regions{
+		name:   name,
+		base:   strings.TrimRight(baseURL, "/"),
+		cfg:    cfg,
+		next:   next,
+		probes: &http.Client{Transport: next},
+		log:    log,
+	}
+	seen := make(map[string]bool, len(regions))
+	for _, region := range regions {
+		if region.Name == "" || seen[region.Name] {
+			return nil, fmt.Errorf("region names must be unique and non-empty, got %q", region.Name)
+		}
+		seen[region.Name] = true
+		u, err := url.Parse(strings.TrimRight(region.BaseURL, "/"))
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("region %s: invalid base url %q", region.Name, region.BaseURL)
+		}
+		r.regions = append(r.regions, &regionState{Region: region, url: u, healthy: true})
+	}
+	for client, region := range cfg.Preferred {
+		if !seen[region] {
+			return nil, fmt.Errorf("client region %s is mapped to unknown region %s", client, region)
+		}
+	}
+	return r, nil
+}
+
+// Run probes the regions until ctx is done.
+func (r *Regions) Run(ctx context.Context) {
+	go func() {
+		r.probeAll(ctx)
+		ticker := time.NewTicker(r.cfg.ProbeInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.probeAll(ctx)
+			}
+		}
+	}()
+}
+
+func (r *Regions) RoundTrip(req *http.Request) (*http.Response, error) {
+	u := req.URL.String()
+	if !strings.HasPrefix(u, r.base) {
+		return r.next.RoundTrip(req)
+	}
+	region := r.pick(clientRegion(req.Context()))
+	target, err := url.Parse(region.url.String() + strings.TrimPrefix(u, r.base))
+	if err != nil {
+		return nil, err
+	}
+	out := req.Clone(req.Context())
+	out.URL = target
+	out.Host = ""
+	if r.cfg.HintHeader != "" {
+		out.Header.Set(r.cfg.HintHeader, region.Name)
+	}
+	metrics.Regions.Add(r.name+"_"+region.Name, 1)
+	return r.next.RoundTrip(out)
+}
+
+func (r *Regions) pick(client string) *regionState {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	preferred := r.cfg.Preferred[client]
+	if preferred == "" {
+		preferred = client
+	}
+	var best, fallback *regionState
+	for _, region := range r.regions {
+		if region.Name == preferred {
+			if region.healthy {
+				return region
+			}
+			fallback = region
+		}
+		if region.healthy && (best == nil || region.latency < best.latency) {
+			best = region
+		}
+	}
+	switch {
+	case best != nil:
+		return best
+	case fallback != nil:
+		return fallback
+	}
+	return r.regions[0]
+}
+
+func (r *Regions) probeAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, region := range r.regions {
+		wg.Add(1)
+		go func(region *regionState) {
+			defer wg.Done()
+			latency, err := r.probe(ctx, region)
+			r.record(region, latency, err)
+		}(region)
+	}
+	wg.Wait()
+}
+
+func (r *Regions) probe(ctx context.Context, region *regionState) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.ProbeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, region.url.String()+r.cfg.HealthPath, nil)
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	resp, err := r.probes.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return 0, fmt.Errorf("unhealthy status %d", resp.StatusCode)
+	}
+	return time.Since(start), nil
+}
+
+func (r *Regions) record(region *regionState, latency time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		if region.healthy {
+			r.log.Warn("upstream region down",
+				slog.String("upstream", r.name),
+				slog.String("region", region.Name),
+				slog.String("err", err.Error()),
+			)
+		}
+		region.healthy = false
+		return
+	}
+	if !region.healthy {
+		r.log.Info("upstream region recovered", slog.String("upstream", r.name), slog.String("region", region.Name))
+	}
+	region.healthy = true
+	if region.latency == 0 {
+		region.latency = latency
+		return
+	}
+	region.latency += time.Duration(latencyWeight * float64(latency-region.latency))
+}
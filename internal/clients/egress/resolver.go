@@ -0,0 +1,154 @@
+package egress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	IPPreferenceAuto = "auto"
+	IPPreferenceIPv4 = "ipv4"
+	IPPreferenceIPv6 = "ipv6"
+)
+
+// ResolverConfig tunes how upstream host names are resolved. Servers are
+// "host:port" DNS servers queried in round-robin instead of the system ones;
+// IPPreference orders resolved addresses by family ("ipv4", "ipv6" or "auto"
+// for resolver order); CacheTTL keeps successful lookups in memory.
+type ResolverConfig struct {
+	Servers      []string
+	IPPreference string
+	CacheTTL     time.Duration
+	Timeout      time.Duration
+}
+
+type cachedAddrs struct {
+	ips     []net.IP
+	expires time.Time
+}
+
+// Resolver dials upstreams with its own name resolution, trying every
+// resolved address in preference order until one connects.
+type Resolver struct {
+	cfg      ResolverConfig
+	resolver *net.Resolver
+	dialer   *net.Dialer
+	next     atomic.Uint32
+	mu       sync.RWMutex
+	cache    map[string]cachedAddrs
+}
+
+func NewResolver(cfg ResolverConfig) (*Resolver, error) {
+	switch cfg.IPPreference {
+	case "":
+		cfg.IPPreference = IPPreferenceAuto
+	case IPPreferenceAuto, IPPreferenceIPv4, IPPreferenceIPv6:
+	default:
+		return nil, fmt.Errorf("unknown ip preference %q", cfg.IPPreference)
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 2 * time.Second
+	}
+	for _, server := range cfg.Servers {
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			return nil, fmt.Errorf("invalid dns server %q: %w", server, err)
+		}
+	}
+	r := &Resolver{
+		cfg:    cfg,
+		dialer: &net.Dialer{},
+		cache:  make(map[string]cachedAddrs),
+	}
+	r.resolver = net.DefaultResolver
+	if len(cfg.Servers) > 0 {
+		r.resolver = &net.Resolver{PreferGo: true, Dial: r.dialDNS}
+	}
+	return r, nil
+}
+
+func (r *Resolver) dialDNS(ctx context.Context, network, _ string) (net.Conn, error) {
+	server := r.cfg.Servers[int(r.next.Add(1))%len(r.cfg.Servers)]
+	d := net.Dialer{Timeout: r.cfg.Timeout}
+	return d.DialContext(ctx, network, server)
+}
+
+func (r *Resolver) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return r.dialer.DialContext(ctx, network, address)
+	}
+	ips, err := r.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	for _, ip := range ips {
+		conn, err := r.dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+func (r *Resolver) lookup(ctx context.Context, host string) ([]net.IP, error) {
+	now := time.Now()
+	if r.cfg.CacheTTL > 0 {
+		r.mu.RLock()
+		entry, ok := r.cache[host]
+		r.mu.RUnlock()
+		if ok && now.Before(entry.expires) {
+			return entry.ips, nil
+		}
+	}
+
+	lookupCtx, cancel := context.WithTimeout(ctx, r.cfg.Timeout)
+	defer cancel()
+	addrs, err := r.resolver.LookupIPAddr(lookupCtx, host)
+	if err != nil {
+		return nil, err
+	}
+	ips := orderByPreference(addrs, r.cfg.IPPreference)
+	if len(ips) == 0 {
+		return nil, &net.DNSError{Err: "no addresses", Name: host, IsNotFound: true}
+	}
+
+	if r.cfg.CacheTTL > 0 {
+		r.mu.Lock()
+		r.cache[host] = cachedAddrs{ips: ips, expires: now.Add(r.cfg.CacheTTL)}
+		r.mu.Unlock()
+	}
+	return ips, nil
+}
+
+func orderByPreference(addrs []net.IPAddr, preference string) []net.IP {
+	var v4, v6 []net.IP
+	all := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		all = append(all, addr.IP)
+		if addr.IP.To4() != nil {
+			v4 = append(v4, addr.IP)
+		} else {
+			v6 = append(v6, addr.IP)
+		}
+	}
+	switch preference {
+	case IPPreferenceIPv4:
+		return append(v4, v6...)
+	case IPPreferenceIPv6:
+		return append(v6, v4...)
+	}
+	return all
+}
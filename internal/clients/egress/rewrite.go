@@ -0,0 +1,125 @@
+package egress
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// RewriteRule maps a path of an upstream client to the path the upstream
+// serves. Paths are relative to the base URL of the upstream and escaped, as
+// in "/videos/42/subtitles/translations/pt-BR:approve".
+//
+// A rule with Prefix replaces that prefix with Replace: Prefix "/scripts"
+// with Replace "" strips it, Prefix "/" with Replace "/v2/" adds one. The
+// prefix matches whole segments, "/media" doesn't match "/mediafiles". A rule
+// with Regex replaces its matches with Replace, which may refer to groups as
+// $1. Regex must match the whole path to apply, anchors are implied.
+type RewriteRule struct {
+	Prefix  string
+	Regex   string
+	Replace string
+}
+
+type compiledRule struct {
+	prefix  string
+	regex   *regexp.Regexp
+	replace string
+}
+
+// Rewrite rewrites the paths of the requests sent to baseURL with the first
+// matching rule, so upstream URL layouts can change without touching the
+// client methods. Requests matching no rule, or to other hosts, are sent
+// unchanged.
+func Rewrite(next http.RoundTripper, baseURL string, rules []RewriteRule) (http.RoundTripper, error) {
+	if len(rules) == 0 {
+		return next, nil
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse base url: %w", err)
+	}
+	compiled := make([]compiledRule, 0, len(rules))
+	for i, rule := range rules {
+		switch {
+		case rule.Prefix != "" && rule.Regex != "":
+			return nil, fmt.Errorf("rewrite %d: set either prefix or regex", i)
+		case rule.Prefix != "":
+			compiled = append(compiled, compiledRule{prefix: rule.Prefix, replace: rule.Replace})
+		case rule.Regex != "":
+			re, err := regexp.Compile("^(?:" + rule.Regex + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("rewrite %d: %w", i, err)
+			}
+			compiled = append(compiled, compiledRule{regex: re, replace: rule.Replace})
+		default:
+			return nil, fmt.Errorf("rewrite %d: prefix or regex is required", i)
+		}
+	}
+	return &rewriteTransport{
+		next:     next,
+		host:     base.Host,
+		basePath: strings.TrimRight(base.EscapedPath(), "/"),
+		rules:    compiled,
+	}, nil
+}
+
+type rewriteTransport struct {
+	next     http.RoundTripper
+	host     string
+	basePath string
+	rules    []compiledRule
+}
+
+func (t *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != t.host {
+		return t.next.RoundTrip(req)
+	}
+	path, ok := strings.CutPrefix(req.URL.EscapedPath(), t.basePath)
+	if !ok {
+		return t.next.RoundTrip(req)
+	}
+	rewritten, ok := t.rewrite(path)
+	if !ok {
+		return t.next.RoundTrip(req)
+	}
+	escaped := t.basePath + rewritten
+	unescaped, err := url.PathUnescape(escaped)
+	if err != nil {
+		return nil, fmt.Errorf("rewrite %s: %w", path, err)
+	}
+	req = req.Clone(req.Context())
+	req.URL.Path = unescaped
+	req.URL.RawPath = escaped
+	return t.next.RoundTrip(req)
+}
+
+func (t *rewriteTransport) rewrite(path string) (string, bool) {
+	for _, rule := range t.rules {
+		if rule.regex != nil {
+			if rule.regex.MatchString(path) {
+				return rule.regex.ReplaceAllString(path, rule.replace), true
+			}
+			continue
+		}
+		if rest, ok := strings.CutPrefix(path, rule.prefix); ok && segmentEnd(rule.prefix, rest) {
+			rewritten := rule.replace + rest
+			if !strings.HasPrefix(rewritten, "/") {
+				rewritten = "/" + rewritten
+			}
+			return rewritten, true
+		}
+	}
+	return "", false
+}
+
+// segmentEnd reports whether a prefix match ends at a segment boundary; ":"
+// starts the custom methods of paths like "/ideas:expand".
+func segmentEnd(prefix, rest string) bool {
+	return rest == "" || strings.HasSuffix(prefix, "/") || rest[0] == '/' || rest[0] == ':'
+}
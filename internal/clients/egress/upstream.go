@@ -0,0 +1,109 @@
+package egress
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// Protocols of an upstream. Without one, https upstreams negotiate HTTP/2
+// with ALPN and plain http ones get HTTP/1.1.
+const (
+	ProtocolHTTP1 = "http1"
+	// ProtocolH2 requires HTTP/2 over TLS.
+	ProtocolH2 = "h2"
+	// ProtocolH2C speaks cleartext HTTP/2 with prior knowledge, for upstreams
+	// on an internal network without TLS.
+	ProtocolH2C = "h2c"
+)
+
+// UpstreamConfig is how the gateway connects to one HTTP upstream.
+// CertFile and KeyFile present a client certificate for mTLS; CAFile
+// replaces the system roots for verifying the upstream's certificate.
+type UpstreamConfig struct {
+	Protocol   string
+	CAFile     string
+	CertFile   string
+	KeyFile    string
+	ServerName string
+}
+
+func (u UpstreamConfig) isDefault() bool {
+	return u == UpstreamConfig{}
+}
+
+// ForUpstream returns the transport for the upstream at baseURL. Upstreams
+// with the default config keep sharing transport and its connections; the
+// others get a copy with their protocol and TLS settings and their own pool,
+// so HTTP/2 upstreams multiplex their requests over a few connections.
+func ForUpstream(transport *http.Transport, baseURL string, cfg UpstreamConfig) (*http.Transport, error) {
+	if cfg.isDefault() {
+		return transport, nil
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse base url: %w", err)
+	}
+
+	protocols := new(http.Protocols)
+	switch cfg.Protocol {
+	case "":
+		protocols.SetHTTP1(true)
+		protocols.SetHTTP2(true)
+	case ProtocolHTTP1:
+		protocols.SetHTTP1(true)
+	case ProtocolH2:
+		if u.Scheme != "https" {
+			return nil, fmt.Errorf("protocol %s needs an https base url, use %s for http", ProtocolH2, ProtocolH2C)
+		}
+		protocols.SetHTTP2(true)
+	case ProtocolH2C:
+		if u.Scheme != "http" {
+			return nil, fmt.Errorf("protocol %s needs an http base url, use %s for https", ProtocolH2C, ProtocolH2)
+		}
+		protocols.SetUnencryptedHTTP2(true)
+	default:
+		return nil, fmt.Errorf("unknown protocol %q (want %s, %s or %s)", cfg.Protocol, ProtocolHTTP1, ProtocolH2, ProtocolH2C)
+	}
+
+	clone := transport.Clone()
+	clone.Protocols = protocols
+	tlsConfig, err := cfg.tlsConfig(clone.TLSClientConfig)
+	if err != nil {
+		return nil, err
+	}
+	clone.TLSClientConfig = tlsConfig
+	return clone, nil
+}
+
+func (u UpstreamConfig) tlsConfig(base *tls.Config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if base != nil {
+		tlsConfig = base.Clone()
+	}
+	if u.ServerName != "" {
+		tlsConfig.ServerName = u.ServerName
+	}
+	if u.CAFile != "" {
+		pem, err := os.ReadFile(u.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ca file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in ca file %s", u.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if u.CertFile != "" || u.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(u.CertFile, u.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
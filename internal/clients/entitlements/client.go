@@ -0,0 +1,68 @@
+package entitlements
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Entitlements is what a user's billing plan grants.
+type Entitlements struct {
+	Plan     string   `json:"plan"`
+	Features []string `json:"features"`
+}
+
+// Client is a thin HTTP wrapper around the billing service entitlements API.
+type Client struct {
+	baseURL string
+	http    *http.Client
+}
+
+// New creates a new client with the provided baseURL and timeout. A nil
+// transport uses http.DefaultTransport.
+func New(baseURL string, timeout time.Duration, transport http.RoundTripper) (*Client, error) {
+	if baseURL == "" {
+		return nil, fmt.Errorf("baseURL is required")
+	}
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid baseURL: %w", err)
+	}
+	if parsed.Scheme == "" {
+		return nil, fmt.Errorf("baseURL must include scheme (http/https)")
+	}
+	return &Client{
+		baseURL: strings.TrimRight(parsed.String(), "/"),
+		http:    &http.Client{Timeout: timeout, Transport: transport},
+	}, nil
+}
+
+// Get resolves the entitlements of a user.
+func (c *Client) Get(ctx context.Context, userID string) (*Entitlements, error) {
+	if userID == "" {
+		return nil, fmt.Errorf("userID is required")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/users/"+url.PathEscape(userID)+"/entitlements", nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("billing service request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return nil, fmt.Errorf("billing service returned status %d", resp.StatusCode)
+	}
+	var res Entitlements
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&res); err != nil {
+		return nil, fmt.Errorf("decode entitlements: %w", err)
+	}
+	return &res, nil
+}
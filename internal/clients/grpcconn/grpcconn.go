@@ -0,0 +1,196 @@
+// Package grpcconn dials upstream gRPC services with TLS, keepalive and a
+// default retry policy, and reports connection state changes.
+package grpcconn
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"time"
+
+	"github.com/immxrtalbeast/api-gateway/internal/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+)
+
+type TLSConfig struct {
+	Enabled bool
+	// CAFile verifies the server; empty uses the system roots.
+	CAFile string
+	// CertFile and KeyFile are the client certificate for mTLS.
+	CertFile   string
+	KeyFile    string
+	ServerName string
+}
+
+type KeepaliveConfig struct {
+	// Time between pings on an idle connection; zero disables keepalive.
+	Time                time.Duration
+	Timeout             time.Duration
+	PermitWithoutStream bool
+}
+
+// RetryConfig is the default retry policy applied to every method. Calls
+// failing with one of Codes (gRPC status names, e.g. "UNAVAILABLE") are
+// retried up to MaxAttempts in total; MaxAttempts below 2 disables retries.
+type RetryConfig struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	Codes          []string
+}
+
+type Config struct {
+	TLS       TLSConfig
+	Keepalive KeepaliveConfig
+	Retry     RetryConfig
+	// WaitForReady makes calls wait for the connection to become ready, up to
+	// their deadline, instead of failing fast while it reconnects.
+	WaitForReady bool
+	// Dial overrides how connections are opened, e.g. through the custom
+	// resolver.
+	Dial func(ctx context.Context, network, address string) (net.Conn, error)
+	// UnaryInterceptors wrap every unary call, outermost first.
+	UnaryInterceptors []grpc.UnaryClientInterceptor
+}
+
+// Dial connects to address lazily; errors are about the configuration only.
+func Dial(ctx context.Context, address string, cfg Config) (*grpc.ClientConn, error) {
+	creds, err := transportCredentials(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+	serviceConfig, err := defaultServiceConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithDefaultServiceConfig(serviceConfig),
+	}
+	if cfg.Keepalive.Time > 0 {
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                cfg.Keepalive.Time,
+			Timeout:             cfg.Keepalive.Timeout,
+			PermitWithoutStream: cfg.Keepalive.PermitWithoutStream,
+		}))
+	}
+	if cfg.Dial != nil {
+		opts = append(opts, grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return cfg.Dial(ctx, "tcp", addr)
+		}))
+	}
+	if len(cfg.UnaryInterceptors) > 0 {
+		opts = append(opts, grpc.WithChainUnaryInterceptor(cfg.UnaryInterceptors...))
+	}
+	return grpc.DialContext(ctx, address, opts...)
+}
+
+func transportCredentials(cfg TLSConfig) (credentials.TransportCredentials, error) {
+	if !cfg.Enabled {
+		return insecure.NewCredentials(), nil
+	}
+	tlsConfig := &tls.Config{ServerName: cfg.ServerName, MinVersion: tls.VersionTLS12}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read grpc ca file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in grpc ca file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load grpc client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+type methodConfig struct {
+	Name         []struct{}   `json:"name"`
+	WaitForReady bool         `json:"waitForReady,omitempty"`
+	RetryPolicy  *retryPolicy `json:"retryPolicy,omitempty"`
+}
+
+type retryPolicy struct {
+	MaxAttempts          int      `json:"maxAttempts"`
+	InitialBackoff       string   `json:"initialBackoff"`
+	MaxBackoff           string   `json:"maxBackoff"`
+	BackoffMultiplier    float64  `json:"backoffMultiplier"`
+	RetryableStatusCodes []string `json:"retryableStatusCodes"`
+}
+
+// defaultServiceConfig renders the retry policy and wait-for-ready flag as a
+// service config matching every method.
+func defaultServiceConfig(cfg Config) (string, error) {
+	method := methodConfig{Name: []struct{}{{}}, WaitForReady: cfg.WaitForReady}
+	if r := cfg.Retry; r.MaxAttempts >= 2 {
+		if r.InitialBackoff <= 0 || r.MaxBackoff < r.InitialBackoff {
+			return "", errors.New("grpc retry backoff must be positive and initial_backoff <= max_backoff")
+		}
+		if len(r.Codes) == 0 {
+			return "", errors.New("grpc retry needs at least one status code")
+		}
+		if r.Multiplier <= 0 {
+			r.Multiplier = 2
+		}
+		method.RetryPolicy = &retryPolicy{
+			MaxAttempts:          r.MaxAttempts,
+			InitialBackoff:       seconds(r.InitialBackoff),
+			MaxBackoff:           seconds(r.MaxBackoff),
+			BackoffMultiplier:    r.Multiplier,
+			RetryableStatusCodes: r.Codes,
+		}
+	}
+	raw, err := json.Marshal(map[string]any{"methodConfig": []methodConfig{method}})
+	return string(raw), err
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%gs", d.Seconds())
+}
+
+// WatchState logs every connectivity change of conn and publishes the current
+// state and the number of transitions under name in gateway_grpc. It returns
+// when ctx is done.
+func WatchState(ctx context.Context, conn *grpc.ClientConn, name string, log *slog.Logger) {
+	state := new(expvar.String)
+	metrics.GRPC.Set(name+"_state", state)
+	current := conn.GetState()
+	state.Set(current.String())
+	for {
+		if !conn.WaitForStateChange(ctx, current) {
+			return
+		}
+		previous := current
+		current = conn.GetState()
+		state.Set(current.String())
+		metrics.GRPC.Add(name+"_transitions", 1)
+		level := slog.LevelInfo
+		if current == connectivity.TransientFailure {
+			level = slog.LevelWarn
+		}
+		log.Log(ctx, level, "grpc connection state changed",
+			slog.String("upstream", name),
+			slog.String("from", previous.String()),
+			slog.String("to", current.String()),
+		)
+	}
+}
@@ -0,0 +1,232 @@
+// Package jwks fetches and caches the auth service's JSON Web Key Set so
+// access tokens signed with RS256 or ES256 can be verified locally.
+package jwks
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrUnknownKey is returned by Key when the set has no key with that ID, even
+// after a refresh.
+var ErrUnknownKey = errors.New("unknown signing key")
+
+type Config struct {
+	URL string
+	// RefreshInterval is how often the set is fetched in the background.
+	RefreshInterval time.Duration
+	// MinRefreshInterval limits the extra fetches triggered by tokens with
+	// an unknown key ID, e.g. right after a key rotation.
+	MinRefreshInterval time.Duration
+	Timeout            time.Duration
+}
+
+// KeySet holds the current public keys by key ID.
+type KeySet struct {
+	cfg  Config
+	http *http.Client
+	log  *slog.Logger
+
+	mu          sync.RWMutex
+	keys        map[string]any
+	lastAttempt time.Time
+	// fetchMu serializes fetches so a burst of unknown key IDs causes one.
+	fetchMu sync.Mutex
+}
+
+func New(cfg Config, transport http.RoundTripper, log *slog.Logger) (*KeySet, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("jwks url is required")
+	}
+	if cfg.RefreshInterval <= 0 {
+		cfg.RefreshInterval = 10 * time.Minute
+	}
+	if cfg.MinRefreshInterval <= 0 {
+		cfg.MinRefreshInterval = 30 * time.Second
+	}
+	return &KeySet{
+		cfg:  cfg,
+		http: &http.Client{Timeout: cfg.Timeout, Transport: transport},
+		log:  log,
+		keys: make(map[string]any),
+	}, nil
+}
+
+// Run fetches the set now and then every RefreshInterval until ctx is done.
+// A failed fetch keeps the previous keys.
+func (s *KeySet) Run(ctx context.Context) {
+	if err := s.refresh(ctx); err != nil {
+		s.log.Error("jwks fetch failed", slog.String("url", s.cfg.URL), slog.String("err", err.Error()))
+	}
+	go func() {
+		ticker := time.NewTicker(s.cfg.RefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.refresh(ctx); err != nil {
+					s.log.Warn("jwks refresh failed", slog.String("url", s.cfg.URL), slog.String("err", err.Error()))
+				}
+			}
+		}
+	}()
+}
+
+// Key returns the public key with the given ID. Unknown IDs trigger a
+// refresh, at most once per MinRefreshInterval.
+func (s *KeySet) Key(ctx context.Context, kid string) (any, error) {
+	if key, ok := s.lookup(kid); ok {
+		return key, nil
+	}
+	s.mu.RLock()
+	recent := time.Since(s.lastAttempt) < s.cfg.MinRefreshInterval
+	s.mu.RUnlock()
+	if !recent {
+		if err := s.refresh(ctx); err != nil {
+			s.log.Warn("jwks refresh failed", slog.String("url", s.cfg.URL), slog.String("err", err.Error()))
+		}
+		if key, ok := s.lookup(kid); ok {
+			return key, nil
+		}
+	}
+	return nil, ErrUnknownKey
+}
+
+func (s *KeySet) lookup(kid string) (any, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+func (s *KeySet) refresh(ctx context.Context) error {
+	s.fetchMu.Lock()
+	defer s.fetchMu.Unlock()
+	s.mu.Lock()
+	s.lastAttempt = time.Now()
+	s.mu.Unlock()
+
+	keys, err := s.fetch(ctx)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.keys = keys
+	s.mu.Unlock()
+	return nil
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (s *KeySet) fetch(ctx context.Context) (map[string]any, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.cfg.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("jwks request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks endpoint returned status %d", resp.StatusCode)
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&set); err != nil {
+		return nil, fmt.Errorf("decode jwks: %w", err)
+	}
+	keys := make(map[string]any, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			s.log.Warn("skipping jwks key", slog.String("kid", jwk.Kid), slog.String("err", err.Error()))
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("jwks has no usable signing keys")
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("modulus: %w", err)
+		}
+		e, err := decodeInt(k.E)
+		if err != nil || !e.IsInt64() || e.Int64() < 3 {
+			return nil, errors.New("invalid exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("x: %w", err)
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("y: %w", err)
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(raw), nil
+}
@@ -0,0 +1,94 @@
+// Package ofrep evaluates feature flags through the OpenFeature Remote
+// Evaluation Protocol, served by flagd, GO Feature Flag and other
+// OpenFeature-compatible providers.
+package ofrep
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client is a thin HTTP wrapper around the OFREP bulk evaluation endpoint.
+type Client struct {
+	baseURL string
+	apiKey  string
+	http    *http.Client
+}
+
+// New creates a new client with the provided baseURL and timeout; a
+// non-empty apiKey is sent as a bearer token. A nil transport uses
+// http.DefaultTransport.
+func New(baseURL, apiKey string, timeout time.Duration, transport http.RoundTripper) (*Client, error) {
+	if baseURL == "" {
+		return nil, fmt.Errorf("baseURL is required")
+	}
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid baseURL: %w", err)
+	}
+	if parsed.Scheme == "" {
+		return nil, fmt.Errorf("baseURL must include scheme (http/https)")
+	}
+	return &Client{
+		baseURL: strings.TrimRight(parsed.String(), "/"),
+		apiKey:  apiKey,
+		http:    &http.Client{Timeout: timeout, Transport: transport},
+	}, nil
+}
+
+type bulkRequest struct {
+	Context map[string]any `json:"context"`
+}
+
+type bulkResponse struct {
+	Flags []struct {
+		Key       string `json:"key"`
+		Value     any    `json:"value"`
+		ErrorCode string `json:"errorCode"`
+	} `json:"flags"`
+}
+
+// Booleans evaluates every flag for the evaluation context evalCtx, which
+// should carry a "targetingKey". Flags that failed to evaluate or aren't
+// booleans are left out.
+func (c *Client) Booleans(ctx context.Context, evalCtx map[string]any) (map[string]bool, error) {
+	body, err := json.Marshal(bulkRequest{Context: evalCtx})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/ofrep/v1/evaluate/flags", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("flag provider request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return nil, fmt.Errorf("flag provider returned status %d", resp.StatusCode)
+	}
+	var res bulkResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&res); err != nil {
+		return nil, fmt.Errorf("decode flags: %w", err)
+	}
+	values := make(map[string]bool, len(res.Flags))
+	for _, flag := range res.Flags {
+		if value, ok := flag.Value.(bool); ok && flag.ErrorCode == "" {
+			values[flag.Key] = value
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,129 @@
+// Package oidc fetches and caches the OpenID Connect discovery document of an
+// issuer, so the gateway can tell frontends where to sign users in and find
+// the issuer's signing keys without configuring each endpoint.
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const wellKnownPath = "/.well-known/openid-configuration"
+
+// Document holds the discovery metadata frontends need.
+type Document struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint,omitempty"`
+	TokenEndpoint         string   `json:"token_endpoint,omitempty"`
+	UserinfoEndpoint      string   `json:"userinfo_endpoint,omitempty"`
+	EndSessionEndpoint    string   `json:"end_session_endpoint,omitempty"`
+	JWKSURI               string   `json:"jwks_uri,omitempty"`
+	ScopesSupported       []string `json:"scopes_supported,omitempty"`
+	ResponseTypes         []string `json:"response_types_supported,omitempty"`
+	CodeChallengeMethods  []string `json:"code_challenge_methods_supported,omitempty"`
+}
+
+type Config struct {
+	Issuer string
+	// RefreshInterval is how often the document is fetched in the background.
+	RefreshInterval time.Duration
+	Timeout         time.Duration
+}
+
+// Discovery holds the last document fetched from the issuer.
+type Discovery struct {
+	cfg  Config
+	url  string
+	http *http.Client
+	log  *slog.Logger
+
+	mu  sync.RWMutex
+	doc *Document
+}
+
+func New(cfg Config, transport http.RoundTripper, log *slog.Logger) (*Discovery, error) {
+	if cfg.Issuer == "" {
+		return nil, fmt.Errorf("oidc issuer is required")
+	}
+	if cfg.RefreshInterval <= 0 {
+		cfg.RefreshInterval = time.Hour
+	}
+	return &Discovery{
+		cfg:  cfg,
+		url:  strings.TrimRight(cfg.Issuer, "/") + wellKnownPath,
+		http: &http.Client{Timeout: cfg.Timeout, Transport: transport},
+		log:  log,
+	}, nil
+}
+
+// Fetch loads the document now; on error the previous one is kept.
+func (d *Discovery) Fetch(ctx context.Context) (*Document, error) {
+	doc, err := d.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	d.doc = doc
+	d.mu.Unlock()
+	return doc, nil
+}
+
+// Run refreshes the document every RefreshInterval until ctx is done. A
+// failed refresh keeps the previous document.
+func (d *Discovery) Run(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(d.cfg.RefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := d.Fetch(ctx); err != nil {
+					d.log.Warn("oidc discovery refresh failed", slog.String("url", d.url), slog.String("err", err.Error()))
+				}
+			}
+		}
+	}()
+}
+
+// Document returns the last fetched document, or nil before the first
+// successful fetch.
+func (d *Discovery) Document() *Document {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.doc
+}
+
+func (d *Discovery) fetch(ctx context.Context) (*Document, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := d.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc discovery request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc discovery returned status %d", resp.StatusCode)
+	}
+	var doc Document
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decode oidc discovery: %w", err)
+	}
+	// The issuer must match the one configured, see OpenID Connect Discovery
+	// section 4.3.
+	if strings.TrimRight(doc.Issuer, "/") != strings.TrimRight(d.cfg.Issuer, "/") {
+		return nil, fmt.Errorf("oidc discovery issuer %q does not match %q", doc.Issuer, d.cfg.Issuer)
+	}
+	return &doc, nil
+}
@@ -7,7 +7,9 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -20,12 +22,14 @@ type Response struct {
 
 // Client is a thin HTTP wrapper around the Python llm-script-service API.
 type Client struct {
-	baseURL string
-	http    *http.Client
+	baseURL   string
+	transport http.RoundTripper
+	http      atomic.Pointer[http.Client]
 }
 
-// New creates a new client with the provided baseURL and timeout.
-func New(baseURL string, timeout time.Duration) (*Client, error) {
+// New creates a new client with the provided baseURL and timeout. A nil
+// transport uses http.DefaultTransport.
+func New(baseURL string, timeout time.Duration, transport http.RoundTripper) (*Client, error) {
 	if baseURL == "" {
 		return nil, fmt.Errorf("baseURL is required")
 	}
@@ -37,21 +41,54 @@ func New(baseURL string, timeout time.Duration) (*Client, error) {
 		return nil, fmt.Errorf("baseURL must include scheme (http/https)")
 	}
 
-	return &Client{
-		baseURL: strings.TrimRight(parsed.String(), "/"),
-		http:    &http.Client{Timeout: timeout},
-	}, nil
+	c := &Client{baseURL: strings.TrimRight(parsed.String(), "/"), transport: transport}
+	c.SetTimeout(timeout)
+	return c, nil
 }
 
-func (c *Client) CreateScript(ctx context.Context, payload []byte) (*Response, error) {
-	return c.do(ctx, http.MethodPost, c.baseURL+"/scripts", payload)
+// SetTimeout changes the timeout of the following requests. Connections are
+// kept.
+func (c *Client) SetTimeout(timeout time.Duration) {
+	c.http.Store(&http.Client{Timeout: timeout, Transport: c.transport})
 }
 
-func (c *Client) ListScripts(ctx context.Context) (*Response, error) {
-	return c.do(ctx, http.MethodGet, c.baseURL+"/scripts", nil)
+func (c *Client) CreateScript(ctx context.Context, payload []byte, headers map[string]string) (*Response, error) {
+	return c.do(ctx, http.MethodPost, c.baseURL+"/scripts", payload, headers)
 }
 
-func (c *Client) do(ctx context.Context, method, endpoint string, payload []byte) (*Response, error) {
+// ListScripts lists scripts; headers (e.g. X-User-ID) scope the listing.
+func (c *Client) ListScripts(ctx context.Context, headers map[string]string) (*Response, error) {
+	return c.do(ctx, http.MethodGet, c.baseURL+"/scripts", nil, headers)
+}
+
+// ListScriptsPage fetches one page of the listing; an empty pageToken starts
+// from the first page.
+func (c *Client) ListScriptsPage(ctx context.Context, pageToken string, pageSize int, headers map[string]string) (*Response, error) {
+	params := url.Values{}
+	params.Set("page_size", strconv.Itoa(pageSize))
+	if pageToken != "" {
+		params.Set("page_token", pageToken)
+	}
+	return c.do(ctx, http.MethodGet, c.baseURL+"/scripts?"+params.Encode(), nil, headers)
+}
+
+// ListChanges returns the user's script changes after cursor; an empty cursor
+// starts from the beginning.
+func (c *Client) ListChanges(ctx context.Context, cursor string, headers map[string]string) (*Response, error) {
+	endpoint := c.baseURL + "/scripts/changes"
+	if cursor != "" {
+		endpoint = endpoint + "?cursor=" + url.QueryEscape(cursor)
+	}
+	return c.do(ctx, http.MethodGet, endpoint, nil, headers)
+}
+
+// Forward calls target, an upstream path with its query, as is. It serves
+// the passthrough routes exposing script service endpoints without a handler.
+func (c *Client) Forward(ctx context.Context, method, target string, payload []byte, headers map[string]string) (*Response, error) {
+	return c.do(ctx, method, c.baseURL+target, payload, headers)
+}
+
+func (c *Client) do(ctx context.Context, method, endpoint string, payload []byte, headers map[string]string) (*Response, error) {
 	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(payload))
 	if err != nil {
 		return nil, fmt.Errorf("create request: %w", err)
@@ -59,8 +96,14 @@ func (c *Client) do(ctx context.Context, method, endpoint string, payload []byte
 	if payload != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
+	for key, value := range headers {
+		if value == "" {
+			continue
+		}
+		req.Header.Set(key, value)
+	}
 
-	resp, err := c.http.Do(req)
+	resp, err := c.http.Load().Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("script service request failed: %w", err)
 	}
@@ -3,11 +3,14 @@ package videos
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -17,12 +20,17 @@ type Response struct {
 	Header     http.Header
 }
 
+// OperationIDHeader carries the client-generated ID of a mutating call so the
+// video service can deduplicate it and report its outcome via GetOperation.
+const OperationIDHeader = "X-Operation-ID"
+
 type Client struct {
-	baseURL string
-	http    *http.Client
+	baseURL   string
+	transport http.RoundTripper
+	http      atomic.Pointer[http.Client]
 }
 
-func New(baseURL string, timeout time.Duration) (*Client, error) {
+func New(baseURL string, timeout time.Duration, transport http.RoundTripper) (*Client, error) {
 	if baseURL == "" {
 		return nil, fmt.Errorf("baseURL is required")
 	}
@@ -33,10 +41,15 @@ func New(baseURL string, timeout time.Duration) (*Client, error) {
 	if parsed.Scheme == "" {
 		return nil, fmt.Errorf("baseURL must include scheme (http/https)")
 	}
-	return &Client{
-		baseURL: strings.TrimRight(parsed.String(), "/"),
-		http:    &http.Client{Timeout: timeout},
-	}, nil
+	c := &Client{baseURL: strings.TrimRight(parsed.String(), "/"), transport: transport}
+	c.SetTimeout(timeout)
+	return c, nil
+}
+
+// SetTimeout changes the timeout of the following requests. Connections are
+// kept.
+func (c *Client) SetTimeout(timeout time.Duration) {
+	c.http.Store(&http.Client{Timeout: timeout, Transport: c.transport})
 }
 
 func (c *Client) CreateVideo(ctx context.Context, payload []byte, headers map[string]string) (*Response, error) {
@@ -47,6 +60,17 @@ func (c *Client) ListVideos(ctx context.Context, headers map[string]string) (*Re
 	return c.do(ctx, http.MethodGet, c.baseURL+"/videos", nil, headers)
 }
 
+// ListVideosPage fetches one page of the listing; an empty pageToken starts
+// from the first page.
+func (c *Client) ListVideosPage(ctx context.Context, pageToken string, pageSize int, headers map[string]string) (*Response, error) {
+	params := url.Values{}
+	params.Set("page_size", strconv.Itoa(pageSize))
+	if pageToken != "" {
+		params.Set("page_token", pageToken)
+	}
+	return c.do(ctx, http.MethodGet, c.baseURL+"/videos?"+params.Encode(), nil, headers)
+}
+
 func (c *Client) GetVideo(ctx context.Context, videoID string, headers map[string]string) (*Response, error) {
 	if videoID == "" {
 		return nil, fmt.Errorf("videoID is required")
@@ -54,6 +78,20 @@ func (c *Client) GetVideo(ctx context.Context, videoID string, headers map[strin
 	return c.do(ctx, http.MethodGet, c.baseURL+"/videos/"+videoID, nil, headers)
 }
 
+func (c *Client) UpdateVideo(ctx context.Context, videoID string, payload []byte, headers map[string]string) (*Response, error) {
+	if videoID == "" {
+		return nil, fmt.Errorf("videoID is required")
+	}
+	return c.do(ctx, http.MethodPatch, c.baseURL+"/videos/"+videoID, payload, headers)
+}
+
+func (c *Client) DeleteVideo(ctx context.Context, videoID string, headers map[string]string) (*Response, error) {
+	if videoID == "" {
+		return nil, fmt.Errorf("videoID is required")
+	}
+	return c.do(ctx, http.MethodDelete, c.baseURL+"/videos/"+videoID, nil, headers)
+}
+
 func (c *Client) ExpandIdea(ctx context.Context, payload []byte, headers map[string]string) (*Response, error) {
 	return c.do(ctx, http.MethodPost, c.baseURL+"/ideas:expand", payload, headers)
 }
@@ -72,10 +110,65 @@ func (c *Client) ApproveSubtitles(ctx context.Context, videoID string, payload [
 	return c.do(ctx, http.MethodPost, c.baseURL+"/videos/"+videoID+"/subtitles:approve", payload, headers)
 }
 
+func (c *Client) RequestSubtitleTranslations(ctx context.Context, videoID string, payload []byte, headers map[string]string) (*Response, error) {
+	if videoID == "" {
+		return nil, fmt.Errorf("videoID is required")
+	}
+	return c.do(ctx, http.MethodPost, c.baseURL+"/videos/"+videoID+"/subtitles/translations", payload, headers)
+}
+
+func (c *Client) ListSubtitleTranslations(ctx context.Context, videoID string, headers map[string]string) (*Response, error) {
+	if videoID == "" {
+		return nil, fmt.Errorf("videoID is required")
+	}
+	return c.do(ctx, http.MethodGet, c.baseURL+"/videos/"+videoID+"/subtitles/translations", nil, headers)
+}
+
+func (c *Client) ApproveSubtitleTranslation(ctx context.Context, videoID, language string, payload []byte, headers map[string]string) (*Response, error) {
+	if videoID == "" || language == "" {
+		return nil, fmt.Errorf("videoID and language are required")
+	}
+	return c.do(ctx, http.MethodPost, c.baseURL+"/videos/"+videoID+"/subtitles/translations/"+url.PathEscape(language)+":approve", payload, headers)
+}
+
+// SearchSuggest returns up to limit autocomplete suggestions for query.
+func (c *Client) SearchSuggest(ctx context.Context, query string, limit int, headers map[string]string) (*Response, error) {
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("limit", strconv.Itoa(limit))
+	return c.do(ctx, http.MethodGet, c.baseURL+"/search/suggest?"+params.Encode(), nil, headers)
+}
+
+// ListChanges returns the user's video and media changes after cursor; an
+// empty cursor starts from the beginning.
+func (c *Client) ListChanges(ctx context.Context, cursor string, headers map[string]string) (*Response, error) {
+	endpoint := c.baseURL + "/changes"
+	if cursor != "" {
+		endpoint = endpoint + "?cursor=" + url.QueryEscape(cursor)
+	}
+	return c.do(ctx, http.MethodGet, endpoint, nil, headers)
+}
+
+// GetOperation returns the outcome of the call sent with the given operation
+// ID; 404 means the video service never received it.
+func (c *Client) GetOperation(ctx context.Context, operationID string, headers map[string]string) (*Response, error) {
+	if operationID == "" {
+		return nil, fmt.Errorf("operationID is required")
+	}
+	return c.do(ctx, http.MethodGet, c.baseURL+"/operations/"+url.PathEscape(operationID), nil, headers)
+}
+
 func (c *Client) UploadMedia(ctx context.Context, payload []byte, headers map[string]string) (*Response, error) {
 	return c.do(ctx, http.MethodPost, c.baseURL+"/media", payload, headers)
 }
 
+func (c *Client) DeleteMedia(ctx context.Context, mediaID string, headers map[string]string) (*Response, error) {
+	if mediaID == "" {
+		return nil, fmt.Errorf("mediaID is required")
+	}
+	return c.do(ctx, http.MethodDelete, c.baseURL+"/media/"+mediaID, nil, headers)
+}
+
 func (c *Client) ListMedia(ctx context.Context, folder string, headers map[string]string) (*Response, error) {
 	endpoint := c.baseURL + "/media"
 	if folder != "" {
@@ -84,12 +177,25 @@ func (c *Client) ListMedia(ctx context.Context, folder string, headers map[strin
 	return c.do(ctx, http.MethodGet, endpoint, nil, headers)
 }
 
-func (c *Client) ListSharedMedia(ctx context.Context, folder string) (*Response, error) {
+// ListSharedMedia lists the shared library; an X-Org-ID header scopes it to
+// an organization.
+func (c *Client) ListSharedMedia(ctx context.Context, folder string, headers map[string]string) (*Response, error) {
 	endpoint := c.baseURL + "/media/shared"
 	if folder != "" {
 		endpoint = endpoint + "?folder=" + url.QueryEscape(folder)
 	}
-	return c.do(ctx, http.MethodGet, endpoint, nil, nil)
+	return c.do(ctx, http.MethodGet, endpoint, nil, headers)
+}
+
+func (c *Client) UploadSharedMedia(ctx context.Context, payload []byte, headers map[string]string) (*Response, error) {
+	return c.do(ctx, http.MethodPost, c.baseURL+"/media/shared", payload, headers)
+}
+
+func (c *Client) DeleteSharedMedia(ctx context.Context, mediaID string, headers map[string]string) (*Response, error) {
+	if mediaID == "" {
+		return nil, fmt.Errorf("mediaID is required")
+	}
+	return c.do(ctx, http.MethodDelete, c.baseURL+"/media/shared/"+mediaID, nil, headers)
 }
 
 func (c *Client) ListVoices(ctx context.Context) (*Response, error) {
@@ -116,7 +222,7 @@ func (c *Client) UploadVideoBinary(ctx context.Context, body []byte, contentType
 		}
 		req.Header.Set(key, value)
 	}
-	resp, err := c.http.Do(req)
+	resp, err := c.http.Load().Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("video service request failed: %w", err)
 	}
@@ -140,12 +246,89 @@ func (c *Client) ListVideoMedia(ctx context.Context, folder string, headers map[
     return c.do(ctx, http.MethodGet, endpoint, nil, headers)
 }
 
-func (c *Client) ListSharedVideoMedia(ctx context.Context, folder string) (*Response, error) {
+func (c *Client) ListSharedVideoMedia(ctx context.Context, folder string, headers map[string]string) (*Response, error) {
     endpoint := c.baseURL + "/media/shared/videos"
     if folder != "" {
         endpoint = endpoint + "?folder=" + url.QueryEscape(folder)
     }
-    return c.do(ctx, http.MethodGet, endpoint, nil, nil)
+    return c.do(ctx, http.MethodGet, endpoint, nil, headers)
+}
+
+// StageSchema describes how the video pipeline reports job progress.
+type StageSchema struct {
+	TerminalStages []string `json:"terminal_stages"`
+	StagePath      string   `json:"stage_path"`
+	JobIDPath      string   `json:"job_id_path"`
+	UserIDPath     string   `json:"user_id_path"`
+}
+
+// GetStageSchema fetches the pipeline stage schema from the metadata endpoint at path.
+func (c *Client) GetStageSchema(ctx context.Context, path string) (*StageSchema, error) {
+	resp, err := c.do(ctx, http.MethodGet, c.baseURL+path, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("video service returned status %d", resp.StatusCode)
+	}
+	var schema StageSchema
+	if err := json.Unmarshal(resp.Body, &schema); err != nil {
+		return nil, fmt.Errorf("decode stage schema: %w", err)
+	}
+	return &schema, nil
+}
+
+// Forward calls target, an upstream path with its query, as is. It serves
+// the passthrough routes exposing video service endpoints without a handler.
+func (c *Client) Forward(ctx context.Context, method, target string, payload []byte, headers map[string]string) (*Response, error) {
+	return c.do(ctx, method, c.baseURL+target, payload, headers)
+}
+
+// ContentHeaders are the request headers GetContent passes on to storage
+// after a redirect; the caller's identity stays with the video service.
+var ContentHeaders = []string{"Range", "If-Range"}
+
+// GetContent requests the rendered file of videoID, following the video
+// service's redirects to storage. The caller reads and closes the body. The
+// call is bound by ctx only, since a download can outlast the client
+// timeout.
+func (c *Client) GetContent(ctx context.Context, videoID string, headers map[string]string) (*http.Response, error) {
+	if videoID == "" {
+		return nil, fmt.Errorf("videoID is required")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/videos/"+videoID+"/content", nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	for key, value := range headers {
+		if value == "" {
+			continue
+		}
+		req.Header.Set(key, value)
+	}
+	client := &http.Client{
+		Transport: c.transport,
+		CheckRedirect: func(next *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return fmt.Errorf("stopped after %d redirects", len(via))
+			}
+			if next.URL.Host != via[0].URL.Host {
+				kept := make(http.Header)
+				for _, key := range ContentHeaders {
+					if value := next.Header.Get(key); value != "" {
+						kept.Set(key, value)
+					}
+				}
+				next.Header = kept
+			}
+			return nil
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("video service request failed: %w", err)
+	}
+	return resp, nil
 }
 
 func (c *Client) do(ctx context.Context, method, endpoint string, payload []byte, extraHeaders map[string]string) (*Response, error) {
@@ -162,7 +345,7 @@ func (c *Client) do(ctx context.Context, method, endpoint string, payload []byte
 		}
 		req.Header.Set(key, value)
 	}
-	resp, err := c.http.Do(req)
+	resp, err := c.http.Load().Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("video service request failed: %w", err)
 	}
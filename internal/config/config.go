@@ -9,68 +9,1085 @@ import (
 )
 
 type Config struct {
-	Env           string              `yaml:"env" env-default:"local"`
+	Env           string              `yaml:"env" env:"ENV" env-default:"local"`
 	AppSecret     string              `yaml:"app_secret" env:"APP_SECRET"`
-	TokenTTL      time.Duration       `yaml:"token_ttl" env-default:"10m"`
+	TokenTTL      time.Duration       `yaml:"token_ttl" env:"TOKEN_TTL" env-default:"10m"`
 	HTTP          HTTPConfig          `yaml:"http"`
 	AuthGRPC      AuthGRPCConfig      `yaml:"auth_grpc"`
 	ScriptService ScriptServiceConfig `yaml:"script_service"`
 	VideoService  VideoServiceConfig  `yaml:"video_service"`
 	Kafka         KafkaConfig         `yaml:"kafka"`
+	Events        EventsConfig        `yaml:"events"`
+	NATS          NATSConfig          `yaml:"nats"`
+	Redis         RedisConfig         `yaml:"redis"`
+	Stream        StreamConfig        `yaml:"stream"`
+	Health        HealthConfig        `yaml:"health"`
+	Uploads       UploadsConfig       `yaml:"uploads"`
+	Cache         CacheConfig         `yaml:"cache"`
+	Compression   CompressionConfig   `yaml:"compression"`
+	Masking       MaskingConfig       `yaml:"masking"`
+	LLMBudget     LLMBudgetConfig     `yaml:"llm_budget"`
+	Egress        EgressConfig        `yaml:"egress"`
+	Resolver      ResolverConfig      `yaml:"resolver"`
+	Validation    ValidationConfig    `yaml:"validation"`
+	Journal       JournalConfig       `yaml:"journal"`
+	Collaborators CollaboratorsConfig `yaml:"collaborators"`
+	Impersonation ImpersonationConfig `yaml:"impersonation"`
+	Audit         AuditConfig         `yaml:"audit"`
+	Search        SearchConfig        `yaml:"search"`
+	IdeaQueue     IdeaQueueConfig     `yaml:"idea_queue"`
+	Usage         UsageConfig         `yaml:"usage"`
+	Entitlements  EntitlementsConfig  `yaml:"entitlements"`
+	Sync          SyncConfig          `yaml:"sync"`
+	Webhooks      WebhooksConfig      `yaml:"webhooks"`
+	Priority      PriorityConfig      `yaml:"priority"`
+	ClientErrors  ClientErrorsConfig  `yaml:"client_errors"`
+	JWKS          JWKSConfig          `yaml:"jwks"`
+	Demo          DemoConfig          `yaml:"demo"`
+	Credits       CreditsConfig       `yaml:"credits"`
+	Recovery      RecoveryConfig      `yaml:"recovery"`
+	Compat        CompatConfig        `yaml:"compat"`
+	Revocation    RevocationConfig    `yaml:"revocation"`
+	Store         StoreConfig         `yaml:"store"`
+	LoginGuard    LoginGuardConfig    `yaml:"login_guard"`
+	Encryption    EncryptionConfig    `yaml:"encryption"`
+	Regions       RegionsConfig       `yaml:"regions"`
+	BodyLog       BodyLogConfig       `yaml:"body_log"`
+	AccessLog     AccessLogConfig     `yaml:"access_log"`
+	Replica       ReplicaConfig       `yaml:"replica"`
+	Sentry        SentryConfig        `yaml:"sentry"`
+	CORS          CORSConfig          `yaml:"cors"`
+	Reload        ReloadConfig        `yaml:"reload"`
+	Routes        RoutesConfig        `yaml:"routes"`
+	RequestBody   RequestBodyConfig   `yaml:"request_body"`
+	Secrets       SecretsConfig       `yaml:"secrets"`
+	Mocks         MocksConfig         `yaml:"mocks"`
+	ServerTiming  ServerTimingConfig  `yaml:"server_timing"`
+	OIDC          OIDCConfig          `yaml:"oidc"`
+	Idempotency   IdempotencyConfig   `yaml:"idempotency"`
+	MediaStream   MediaStreamConfig   `yaml:"media_stream"`
+	HTTPClient    HTTPClientConfig    `yaml:"http_client"`
+	VideoLimits   VideoLimitsConfig   `yaml:"video_limits"`
+	Discovery     DiscoveryConfig     `yaml:"discovery"`
+	Exports       ExportsConfig       `yaml:"exports"`
+	Canary        CanaryConfig        `yaml:"canary"`
+	AuthCache     AuthCacheConfig     `yaml:"auth_cache"`
+	Maintenance   MaintenanceConfig   `yaml:"maintenance"`
+	Analytics     AnalyticsConfig     `yaml:"analytics"`
+	FeatureFlags  FeatureFlagsConfig  `yaml:"feature_flags"`
+	StatusPage    StatusPageConfig    `yaml:"status_page"`
+	OpenAPI       OpenAPIConfig       `yaml:"openapi"`
+	Receipts      ReceiptsConfig      `yaml:"receipts"`
+	APIVersions   APIVersionsConfig   `yaml:"api_versions"`
+	Passthrough   PassthroughConfig   `yaml:"passthrough"`
+	GraphQL       GraphQLConfig       `yaml:"graphql"`
+	BatchGet      BatchGetConfig      `yaml:"batch_get"`
 }
 
 type HTTPConfig struct {
-	Host         string        `yaml:"host" env-default:"0.0.0.0"`
-	Port         int           `yaml:"port" env-default:"8080"`
-	ReadTimeout  time.Duration `yaml:"read_timeout" env-default:"5s"`
-	WriteTimeout time.Duration `yaml:"write_timeout" env-default:"5s"`
-	IdleTimeout  time.Duration `yaml:"idle_timeout" env-default:"60s"`
+	Host         string        `yaml:"host" env:"HTTP_HOST" env-default:"0.0.0.0"`
+	Port         int           `yaml:"port" env:"HTTP_PORT" env-default:"8080"`
+	ReadTimeout  time.Duration `yaml:"read_timeout" env:"HTTP_READ_TIMEOUT" env-default:"5s"`
+	WriteTimeout time.Duration `yaml:"write_timeout" env:"HTTP_WRITE_TIMEOUT" env-default:"5s"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT" env-default:"60s"`
+	// TrustedProxies are the addresses or CIDRs whose X-Forwarded-For and
+	// X-Real-IP give the client IP. Empty trusts none: the client IP is the
+	// peer address, which clients can't spoof.
+	TrustedProxies []string `yaml:"trusted_proxies" env:"HTTP_TRUSTED_PROXIES" env-separator:","`
 }
 
 type AuthGRPCConfig struct {
-	Address string        `yaml:"address" env-required:"true"`
-	Timeout time.Duration `yaml:"timeout" env-default:"5s"`
+	Address      string              `yaml:"address" env:"AUTH_GRPC_ADDRESS" env-required:"true"`
+	Timeout      time.Duration       `yaml:"timeout" env:"AUTH_GRPC_TIMEOUT" env-default:"5s"`
+	TLS          GRPCTLSConfig       `yaml:"tls"`
+	Keepalive    GRPCKeepaliveConfig `yaml:"keepalive"`
+	Retry        GRPCRetryConfig     `yaml:"retry"`
+	WaitForReady bool                `yaml:"wait_for_ready" env:"AUTH_GRPC_WAIT_FOR_READY" env-default:"false"`
+	// Standalone replaces the auth service with an in-memory fake for
+	// development; users registering with StandaloneAdmins become admins.
+	Standalone       bool     `yaml:"standalone" env:"AUTH_STANDALONE" env-default:"false"`
+	StandaloneAdmins []string `yaml:"standalone_admins" env:"AUTH_STANDALONE_ADMINS"`
+}
+
+// GRPCTLSConfig enables TLS to a gRPC upstream; CertFile and KeyFile add a
+// client certificate for mTLS.
+type GRPCTLSConfig struct {
+	Enabled    bool   `yaml:"enabled" env:"AUTH_GRPC_TLS_ENABLED" env-default:"false"`
+	CAFile     string `yaml:"ca_file" env:"AUTH_GRPC_TLS_CA_FILE"`
+	CertFile   string `yaml:"cert_file" env:"AUTH_GRPC_TLS_CERT_FILE"`
+	KeyFile    string `yaml:"key_file" env:"AUTH_GRPC_TLS_KEY_FILE"`
+	ServerName string `yaml:"server_name" env:"AUTH_GRPC_TLS_SERVER_NAME"`
+}
+
+type GRPCKeepaliveConfig struct {
+	Time                time.Duration `yaml:"time" env:"AUTH_GRPC_KEEPALIVE_TIME" env-default:"30s"`
+	Timeout             time.Duration `yaml:"timeout" env:"AUTH_GRPC_KEEPALIVE_TIMEOUT" env-default:"10s"`
+	PermitWithoutStream bool          `yaml:"permit_without_stream" env:"AUTH_GRPC_KEEPALIVE_PERMIT_WITHOUT_STREAM" env-default:"true"`
+}
+
+// GRPCRetryConfig is the default retry policy for every method; MaxAttempts
+// below 2 disables it.
+type GRPCRetryConfig struct {
+	MaxAttempts    int           `yaml:"max_attempts" env:"AUTH_GRPC_RETRY_MAX_ATTEMPTS" env-default:"3"`
+	InitialBackoff time.Duration `yaml:"initial_backoff" env:"AUTH_GRPC_RETRY_INITIAL_BACKOFF" env-default:"100ms"`
+	MaxBackoff     time.Duration `yaml:"max_backoff" env:"AUTH_GRPC_RETRY_MAX_BACKOFF" env-default:"1s"`
+	Multiplier     float64       `yaml:"multiplier" env:"AUTH_GRPC_RETRY_MULTIPLIER" env-default:"2"`
+	Codes          []string      `yaml:"codes" env:"AUTH_GRPC_RETRY_CODES" env-default:"UNAVAILABLE"`
 }
 
 type ScriptServiceConfig struct {
-	BaseURL string        `yaml:"base_url" env-required:"true"`
-	Timeout time.Duration `yaml:"timeout" env-default:"10s"`
+	BaseURL    string                  `yaml:"base_url" env:"SCRIPT_SERVICE_BASE_URL" env-required:"true"`
+	Timeout    time.Duration           `yaml:"timeout" env:"SCRIPT_SERVICE_TIMEOUT" env-default:"10s"`
+	HealthPath string                  `yaml:"health_path" env:"SCRIPT_SERVICE_HEALTH_PATH" env-default:"/health"`
+	Regions    RegionList              `yaml:"regions" env:"SCRIPT_SERVICE_REGIONS"`
+	HTTP       UpstreamHTTPConfig      `yaml:"http" env-prefix:"SCRIPT_SERVICE_HTTP_"`
+	Rewrites   RewriteList             `yaml:"rewrites" env:"SCRIPT_SERVICE_REWRITES"`
+	Discovery  UpstreamDiscoveryConfig `yaml:"discovery" env-prefix:"SCRIPT_SERVICE_DISCOVERY_"`
+	Canary     UpstreamCanaryConfig    `yaml:"canary" env-prefix:"SCRIPT_SERVICE_CANARY_"`
 }
 
 type VideoServiceConfig struct {
-	BaseURL string        `yaml:"base_url" env-required:"true"`
-	Timeout time.Duration `yaml:"timeout" env-default:"10s"`
+	BaseURL    string        `yaml:"base_url" env:"VIDEO_SERVICE_BASE_URL" env-required:"true"`
+	Timeout    time.Duration `yaml:"timeout" env:"VIDEO_SERVICE_TIMEOUT" env-default:"10s"`
+	HealthPath string        `yaml:"health_path" env:"VIDEO_SERVICE_HEALTH_PATH" env-default:"/health"`
+	// StandbyURL is a replica raced against BaseURL when connecting to it
+	// takes longer than FailoverDelay.
+	StandbyURL    string        `yaml:"standby_url" env:"VIDEO_SERVICE_STANDBY_URL"`
+	FailoverDelay time.Duration `yaml:"failover_delay" env:"VIDEO_SERVICE_FAILOVER_DELAY" env-default:"300ms"`
+	// Regions, when set, serve the requests addressed to BaseURL; list the
+	// BaseURL deployment among them to keep using it.
+	Regions RegionList         `yaml:"regions" env:"VIDEO_SERVICE_REGIONS"`
+	HTTP    UpstreamHTTPConfig `yaml:"http" env-prefix:"VIDEO_SERVICE_HTTP_"`
+	// Rewrites map the paths the client sends to the ones video-service
+	// serves; the first matching rule applies.
+	Rewrites RewriteList `yaml:"rewrites" env:"VIDEO_SERVICE_REWRITES"`
+	// Discovery, when set, sends the requests addressed to BaseURL to the
+	// discovered instances instead.
+	Discovery UpstreamDiscoveryConfig `yaml:"discovery" env-prefix:"VIDEO_SERVICE_DISCOVERY_"`
+	Canary    UpstreamCanaryConfig    `yaml:"canary" env-prefix:"VIDEO_SERVICE_CANARY_"`
+	Hedging   HedgingConfig           `yaml:"hedging" env-prefix:"VIDEO_SERVICE_HEDGING_"`
+}
+
+// HedgingConfig sends a second attempt of the upstream GETs that haven't
+// answered within Percentile of the recent response times, bounded by
+// MinDelay and MaxDelay; InitialDelay applies until enough were seen.
+type HedgingConfig struct {
+	Enabled      bool          `yaml:"enabled" env:"ENABLED" env-default:"false"`
+	Percentile   float64       `yaml:"percentile" env:"PERCENTILE" env-default:"95"`
+	InitialDelay time.Duration `yaml:"initial_delay" env:"INITIAL_DELAY" env-default:"300ms"`
+	MinDelay     time.Duration `yaml:"min_delay" env:"MIN_DELAY" env-default:"50ms"`
+	MaxDelay     time.Duration `yaml:"max_delay" env:"MAX_DELAY" env-default:"2s"`
+}
+
+// UpstreamCanaryConfig routes Weight percent of an upstream's requests, by
+// user, to a second version at BaseURL. Empty BaseURL disables it.
+type UpstreamCanaryConfig struct {
+	BaseURL string  `yaml:"base_url" env:"BASE_URL"`
+	Weight  float64 `yaml:"weight" env:"WEIGHT" env-default:"0"`
+}
+
+// UpstreamDiscoveryConfig balances the requests of an upstream over its
+// instances. Mode "static" uses Endpoints (base URLs), "dns_srv" the SRV
+// records of Name and "consul" the healthy instances of the Consul service
+// Name (see DiscoveryConfig); empty disables discovery. Policy is
+// "round_robin" or "least_connections". MaxFailures consecutive failed
+// requests take an instance out of rotation for EjectDuration.
+type UpstreamDiscoveryConfig struct {
+	Mode            string        `yaml:"mode" env:"MODE"`
+	Endpoints       []string      `yaml:"endpoints" env:"ENDPOINTS" env-separator:","`
+	Name            string        `yaml:"name" env:"NAME"`
+	Scheme          string        `yaml:"scheme" env:"SCHEME" env-default:"http"`
+	Policy          string        `yaml:"policy" env:"POLICY" env-default:"round_robin"`
+	RefreshInterval time.Duration `yaml:"refresh_interval" env:"REFRESH_INTERVAL" env-default:"30s"`
+	MaxFailures     int           `yaml:"max_failures" env:"MAX_FAILURES" env-default:"3"`
+	EjectDuration   time.Duration `yaml:"eject_duration" env:"EJECT_DURATION" env-default:"30s"`
+}
+
+// UpstreamHTTPConfig picks the protocol of an HTTP upstream: empty negotiates
+// HTTP/2 over TLS when offered, "http1" forces HTTP/1.1, "h2" HTTP/2 over TLS
+// and "h2c" cleartext HTTP/2. CertFile and KeyFile add a client certificate
+// for mTLS, CAFile replaces the system roots.
+type UpstreamHTTPConfig struct {
+	Protocol   string `yaml:"protocol" env:"PROTOCOL"`
+	CAFile     string `yaml:"ca_file" env:"CA_FILE"`
+	CertFile   string `yaml:"cert_file" env:"CERT_FILE"`
+	KeyFile    string `yaml:"key_file" env:"KEY_FILE"`
+	ServerName string `yaml:"server_name" env:"SERVER_NAME"`
+}
+
+// RewriteConfig rewrites the upstream paths starting with Prefix, or matching
+// the whole of Regex, to Replace; see egress.RewriteRule.
+type RewriteConfig struct {
+	Prefix  string `yaml:"prefix"`
+	Regex   string `yaml:"regex"`
+	Replace string `yaml:"replace"`
+}
+
+// RegionConfig tags a deployment of an upstream with the region it runs in.
+type RegionConfig struct {
+	Name    string `yaml:"name"`
+	BaseURL string `yaml:"base_url"`
 }
 
 type KafkaConfig struct {
-	Enabled      bool          `yaml:"enabled" env-default:"false"`
-	Brokers      []string      `yaml:"brokers" env:"KAFKA_BROKERS" env-separator:","`
-	UpdatesTopic string        `yaml:"updates_topic" env-default:"video_updates"`
-	GroupID      string        `yaml:"group_id" env-default:"api-gateway-video-stream"`
-	MaxWait      time.Duration `yaml:"max_wait" env-default:"500ms"`
+	Enabled         bool          `yaml:"enabled" env:"KAFKA_ENABLED" env-default:"false"`
+	Brokers         []string      `yaml:"brokers" env:"KAFKA_BROKERS" env-separator:","`
+	UpdatesTopic    string        `yaml:"updates_topic" env:"KAFKA_UPDATES_TOPIC" env-default:"video_updates"`
+	GroupID         string        `yaml:"group_id" env:"KAFKA_GROUP_ID" env-default:"api-gateway-video-stream"`
+	MaxWait         time.Duration `yaml:"max_wait" env:"KAFKA_MAX_WAIT" env-default:"500ms"`
+	StartOffset     string        `yaml:"start_offset" env:"KAFKA_START_OFFSET" env-default:"last"`
+	ManualCommit    bool          `yaml:"manual_commit" env:"KAFKA_MANUAL_COMMIT" env-default:"true"`
+	DeadLetterTopic string        `yaml:"dead_letter_topic" env:"KAFKA_DEAD_LETTER_TOPIC"`
+	// Mode "group" shares GroupID between replicas, so each update reaches
+	// one of them; "broadcast" gives every replica its own group
+	// (GroupID-<replica id>) so all their websocket subscribers get it.
+	Mode string          `yaml:"mode" env:"KAFKA_MODE" env-default:"group"`
+	SASL KafkaSASLConfig `yaml:"sasl"`
+	// TLS connects to the brokers over TLS with the system roots.
+	TLS bool `yaml:"tls" env:"KAFKA_TLS" env-default:"false"`
 }
 
-func MustLoad() *Config {
-	configPath := fetchConfigPath()
-	if configPath == "" {
-		panic("config path is empty")
-	}
+// KafkaSASLConfig authenticates to the brokers when Mechanism is set:
+// "plain", "scram-sha-256" or "scram-sha-512".
+type KafkaSASLConfig struct {
+	Mechanism string `yaml:"mechanism" env:"KAFKA_SASL_MECHANISM"`
+	Username  string `yaml:"username" env:"KAFKA_SASL_USERNAME"`
+	Password  string `yaml:"password" env:"KAFKA_SASL_PASSWORD"`
+}
+
+// EventsConfig selects the source of realtime job updates: "kafka", "nats" or
+// "redis". When empty, kafka.enabled decides whether Kafka is used.
+type EventsConfig struct {
+	Backend string `yaml:"backend" env:"EVENTS_BACKEND"`
+}
+
+type NATSConfig struct {
+	URL     string `yaml:"url" env:"NATS_URL" env-default:"nats://127.0.0.1:4222"`
+	Stream  string `yaml:"stream" env:"NATS_STREAM" env-default:"VIDEO_UPDATES"`
+	Subject string `yaml:"subject" env:"NATS_SUBJECT" env-default:"video.updates"`
+	Durable string `yaml:"durable" env:"NATS_DURABLE" env-default:"api-gateway-video-stream"`
+}
+
+type RedisConfig struct {
+	Addr           string `yaml:"addr" env:"REDIS_ADDR" env-default:"127.0.0.1:6379"`
+	Password       string `yaml:"password" env:"REDIS_PASSWORD"`
+	DB             int    `yaml:"db" env:"REDIS_DB" env-default:"0"`
+	UpdatesChannel string `yaml:"updates_channel" env:"REDIS_UPDATES_CHANNEL" env-default:"video_updates"`
+}
+
+// StreamConfig controls the job status websocket.
+type StreamConfig struct {
+	SnapshotTimeout time.Duration `yaml:"snapshot_timeout" env:"STREAM_SNAPSHOT_TIMEOUT" env-default:"5s"`
+	PollInterval    time.Duration `yaml:"poll_interval" env:"STREAM_POLL_INTERVAL" env-default:"2s"`
+	TerminalStages  []string      `yaml:"terminal_stages" env:"STREAM_TERMINAL_STAGES" env-default:"ready,failed" env-separator:","`
+	ReplaySize      int           `yaml:"replay_size" env:"STREAM_REPLAY_SIZE" env-default:"16"`
+	ReplayTTL       time.Duration `yaml:"replay_ttl" env:"STREAM_REPLAY_TTL" env-default:"10m"`
+	StagePath       string        `yaml:"stage_path" env:"STREAM_STAGE_PATH" env-default:"job.stage"`
+	JobIDPath       string        `yaml:"job_id_path" env:"STREAM_JOB_ID_PATH" env-default:"job.id"`
+	UserIDPath      string        `yaml:"user_id_path" env:"STREAM_USER_ID_PATH" env-default:"job.user_id"`
+	// SchemaEndpoint, when set, is a video-service path returning
+	// terminal_stages/stage_path/job_id_path/user_id_path that override the values above.
+	SchemaEndpoint string `yaml:"schema_endpoint" env:"STREAM_SCHEMA_ENDPOINT"`
+	// LagThreshold is the consumer lag (in updates) above which new job
+	// streams poll snapshots until the consumer catches up; 0 disables it.
+	LagThreshold int64 `yaml:"lag_threshold" env:"STREAM_LAG_THRESHOLD" env-default:"100"`
+}
+
+type HealthConfig struct {
+	Enabled          bool          `yaml:"enabled" env:"HEALTH_ENABLED" env-default:"false"`
+	Interval         time.Duration `yaml:"interval" env:"HEALTH_INTERVAL" env-default:"10s"`
+	Timeout          time.Duration `yaml:"timeout" env:"HEALTH_TIMEOUT" env-default:"2s"`
+	FailureThreshold int           `yaml:"failure_threshold" env:"HEALTH_FAILURE_THRESHOLD" env-default:"3"`
+}
+
+type UploadsConfig struct {
+	MaxConcurrentPerUser int              `yaml:"max_concurrent_per_user" env:"UPLOADS_MAX_CONCURRENT_PER_USER" env-default:"3"`
+	MaxQueuedPerUser     int              `yaml:"max_queued_per_user" env:"UPLOADS_MAX_QUEUED_PER_USER" env-default:"2"`
+	QueueTimeout         time.Duration    `yaml:"queue_timeout" env:"UPLOADS_QUEUE_TIMEOUT" env-default:"5s"`
+	Image                UploadRuleConfig `yaml:"image" env-prefix:"UPLOADS_IMAGE_"`
+	Audio                UploadRuleConfig `yaml:"audio" env-prefix:"UPLOADS_AUDIO_"`
+	Video                UploadRuleConfig `yaml:"video" env-prefix:"UPLOADS_VIDEO_"`
+	// TypePath and NamePath locate the MIME type and file name in JSON
+	// upload bodies.
+	TypePath string `yaml:"type_path" env:"UPLOADS_TYPE_PATH" env-default:"content_type"`
+	NamePath string `yaml:"name_path" env:"UPLOADS_NAME_PATH" env-default:"filename"`
+}
+
+// UploadRuleConfig lists the accepted MIME types and extensions (with the
+// dot) of one kind of media and its maximum size. Empty lists accept
+// anything, zero MaxBytes means no limit.
+type UploadRuleConfig struct {
+	Types      []string `yaml:"types" env:"TYPES"`
+	Extensions []string `yaml:"extensions" env:"EXTENSIONS"`
+	MaxBytes   int64    `yaml:"max_bytes" env:"MAX_BYTES"`
+}
+
+// CacheConfig holds per-endpoint TTLs for public catalog responses. Zero disables caching.
+// Coalesce collapses identical concurrent requests to GetVideo, ListVoices
+// and ListMusic into one upstream call.
+type CacheConfig struct {
+	Voices      time.Duration `yaml:"voices" env:"CACHE_VOICES" env-default:"10m"`
+	Music       time.Duration `yaml:"music" env:"CACHE_MUSIC" env-default:"10m"`
+	SharedMedia time.Duration `yaml:"shared_media" env:"CACHE_SHARED_MEDIA" env-default:"1m"`
+	Coalesce    bool          `yaml:"coalesce" env:"CACHE_COALESCE" env-default:"true"`
+}
+
+type CompressionConfig struct {
+	Enabled      bool     `yaml:"enabled" env:"COMPRESSION_ENABLED" env-default:"false"`
+	MinSize      int      `yaml:"min_size" env:"COMPRESSION_MIN_SIZE" env-default:"1024"`
+	Level        int      `yaml:"level" env:"COMPRESSION_LEVEL" env-default:"5"`
+	Algorithms   []string `yaml:"algorithms" env:"COMPRESSION_ALGORITHMS" env-default:"br,gzip,deflate" env-separator:","`
+	ExcludePaths []string `yaml:"exclude_paths" env:"COMPRESSION_EXCLUDE_PATHS" env-separator:","`
+}
+
+// MaskingConfig lists upstream response fields hidden from clients.
+type MaskingConfig struct {
+	Videos  MaskingRules `yaml:"videos" env:"MASKING_VIDEOS"`
+	Scripts MaskingRules `yaml:"scripts" env:"MASKING_SCRIPTS"`
+}
+
+// MaskingRule matches a bare key at any depth ("storage_path") or a dotted
+// path from the root ("job.*.provider_cost"). Action is "remove" or "redact".
+type MaskingRule struct {
+	Path   string `yaml:"path"`
+	Action string `yaml:"action"`
+}
+
+// LLMBudgetConfig sets daily per-user limits for LLM-backed endpoints by plan,
+// e.g. plans.free.ideas: 20. The plan comes from the "plan" JWT claim.
+type LLMBudgetConfig struct {
+	DefaultPlan string     `yaml:"default_plan" env:"LLM_BUDGET_DEFAULT_PLAN" env-default:"free"`
+	Plans       PlanLimits `yaml:"plans" env:"LLM_BUDGET_PLANS"`
+}
+
+// EgressConfig routes outbound traffic to the scripts/videos services (and
+// optionally Kafka) through a corporate proxy: http(s):// for HTTP CONNECT or
+// socks5(h)://. NoProxy follows the NO_PROXY syntax.
+type EgressConfig struct {
+	ProxyURL string `yaml:"proxy_url" env:"EGRESS_PROXY_URL"`
+	NoProxy  string `yaml:"no_proxy" env:"EGRESS_NO_PROXY"`
+	Kafka    bool   `yaml:"kafka" env:"EGRESS_KAFKA" env-default:"false"`
+}
+
+// ResolverConfig overrides name resolution for the upstream HTTP clients and
+// the auth gRPC dialer. It is used only when Servers, a non-auto
+// IPPreference or CacheTTL are set.
+type ResolverConfig struct {
+	Servers      []string      `yaml:"servers" env:"DNS_SERVERS" env-separator:","`
+	IPPreference string        `yaml:"ip_preference" env:"RESOLVER_IP_PREFERENCE" env-default:"auto"`
+	CacheTTL     time.Duration `yaml:"cache_ttl" env:"RESOLVER_CACHE_TTL" env-default:"0s"`
+	Timeout      time.Duration `yaml:"timeout" env:"RESOLVER_TIMEOUT" env-default:"2s"`
+}
+
+func (c ResolverConfig) Enabled() bool {
+	return len(c.Servers) > 0 || (c.IPPreference != "" && c.IPPreference != "auto") || c.CacheTTL > 0
+}
+
+// ValidationConfig maps route names (create_video, create_script,
+// expand_idea) to JSON Schema files request bodies are checked against.
+type ValidationConfig struct {
+	Schemas map[string]string `yaml:"schemas" env:"VALIDATION_SCHEMAS"`
+}
+
+// JournalConfig enables the file-backed queue of approvals and deletions that
+// failed with upstream 5xx, replayed via POST /api/admin/journal/replay.
+type JournalConfig struct {
+	Enabled    bool   `yaml:"enabled" env:"JOURNAL_ENABLED" env-default:"false"`
+	Path       string `yaml:"path" env:"JOURNAL_PATH" env-default:"./data/journal.json"`
+	MaxEntries int    `yaml:"max_entries" env:"JOURNAL_MAX_ENTRIES" env-default:"1000"`
+}
+
+// CollaboratorsConfig sets where per-video grants are kept. An empty Path
+// keeps them in memory only.
+type CollaboratorsConfig struct {
+	Path string `yaml:"path" env:"COLLABORATORS_PATH"`
+}
+
+// ImpersonationConfig limits the lifetime of tokens issued by
+// POST /api/admin/impersonate/:user_id.
+type ImpersonationConfig struct {
+	TTL time.Duration `yaml:"ttl" env:"IMPERSONATION_TTL" env-default:"15m"`
+}
+
+// AuditConfig selects where audit events of sensitive actions go: "file",
+// "kafka" (brokers from the kafka section) or "webhook". An empty Sink
+// disables auditing.
+type AuditConfig struct {
+	Sink           string        `yaml:"sink" env:"AUDIT_SINK"`
+	Path           string        `yaml:"path" env:"AUDIT_PATH" env-default:"./data/audit.log"`
+	Topic          string        `yaml:"topic" env:"AUDIT_TOPIC" env-default:"gateway_audit"`
+	WebhookURL     string        `yaml:"webhook_url" env:"AUDIT_WEBHOOK_URL"`
+	WebhookTimeout time.Duration `yaml:"webhook_timeout" env:"AUDIT_WEBHOOK_TIMEOUT" env-default:"5s"`
+	Buffer         int           `yaml:"buffer" env:"AUDIT_BUFFER" env-default:"1024"`
+}
+
+// SearchConfig tunes GET /api/search/suggest: the upstream timeout, how long
+// a query waits for a newer one from the same user, and the per-user cache.
+type SearchConfig struct {
+	Timeout   time.Duration `yaml:"timeout" env:"SEARCH_TIMEOUT" env-default:"800ms"`
+	Debounce  time.Duration `yaml:"debounce" env:"SEARCH_DEBOUNCE" env-default:"150ms"`
+	CacheTTL  time.Duration `yaml:"cache_ttl" env:"SEARCH_CACHE_TTL" env-default:"1m"`
+	MinLength int           `yaml:"min_length" env:"SEARCH_MIN_LENGTH" env-default:"2"`
+	Limit     int           `yaml:"limit" env:"SEARCH_LIMIT" env-default:"10"`
+}
+
+// IdeaQueueConfig smooths POST /api/ideas/expand: at most MaxConcurrent calls
+// reach the LLM at once, up to MaxQueued more wait in FIFO order for at most
+// MaxWait. MaxConcurrent 0 disables the queue.
+type IdeaQueueConfig struct {
+	MaxConcurrent int           `yaml:"max_concurrent" env:"IDEA_QUEUE_MAX_CONCURRENT" env-default:"4"`
+	MaxQueued     int           `yaml:"max_queued" env:"IDEA_QUEUE_MAX_QUEUED" env-default:"50"`
+	MaxWait       time.Duration `yaml:"max_wait" env:"IDEA_QUEUE_MAX_WAIT" env-default:"3s"`
+}
+
+// UsageConfig enables per-user usage metering. Store is "memory", "redis"
+// (connection from the redis section) or "store" (the shared gateway store);
+// empty disables metering. Plans maps
+// plan name to monthly quotas of videos, ideas and upload_bytes.
+type UsageConfig struct {
+	Store       string     `yaml:"store" env:"USAGE_STORE"`
+	DefaultPlan string     `yaml:"default_plan" env:"USAGE_DEFAULT_PLAN" env-default:"free"`
+	Plans       PlanQuotas `yaml:"plans" env:"USAGE_PLANS"`
+}
+
+// EntitlementsConfig resolves user plans and gates routes by plan features.
+// BaseURL points at the billing service; when empty the JWT "plan" claim is
+// used. Plans lists the features each plan includes; Gates maps
+// "METHOD /route/pattern" to the feature it requires.
+type EntitlementsConfig struct {
+	BaseURL     string            `yaml:"base_url" env:"ENTITLEMENTS_URL"`
+	Timeout     time.Duration     `yaml:"timeout" env:"ENTITLEMENTS_TIMEOUT" env-default:"500ms"`
+	CacheTTL    time.Duration     `yaml:"cache_ttl" env:"ENTITLEMENTS_CACHE_TTL" env-default:"1m"`
+	DefaultPlan string            `yaml:"default_plan" env:"ENTITLEMENTS_DEFAULT_PLAN" env-default:"free"`
+	Plans       PlanFeatures      `yaml:"plans" env:"ENTITLEMENTS_PLANS"`
+	Gates       map[string]string `yaml:"gates" env:"ENTITLEMENTS_GATES"`
+}
+
+// SyncConfig tunes GET /api/sync: the timeout of the upstream change feed
+// calls and how many realtime job updates per user are kept for it.
+type SyncConfig struct {
+	Timeout       time.Duration `yaml:"timeout" env:"SYNC_TIMEOUT" env-default:"5s"`
+	EventsPerUser int           `yaml:"events_per_user" env:"SYNC_EVENTS_PER_USER" env-default:"500"`
+}
+
+// WebhooksConfig controls job completion callbacks. Endpoints are kept in
+// Path (empty keeps them in memory only); failed deliveries are retried up to
+// MaxAttempts times with exponential backoff from InitialBackoff to
+// MaxBackoff. History recent deliveries per endpoint are kept in memory for
+// inspection and redelivery. Plain http URLs need AllowInsecure.
+type WebhooksConfig struct {
+	Enabled        bool          `yaml:"enabled" env:"WEBHOOKS_ENABLED" env-default:"false"`
+	Path           string        `yaml:"path" env:"WEBHOOKS_PATH"`
+	MaxPerUser     int           `yaml:"max_per_user" env:"WEBHOOKS_MAX_PER_USER" env-default:"10"`
+	AllowInsecure  bool          `yaml:"allow_insecure" env:"WEBHOOKS_ALLOW_INSECURE" env-default:"false"`
+	AllowPrivate   bool          `yaml:"allow_private" env:"WEBHOOKS_ALLOW_PRIVATE" env-default:"false"`
+	Workers        int           `yaml:"workers" env:"WEBHOOKS_WORKERS" env-default:"4"`
+	Timeout        time.Duration `yaml:"timeout" env:"WEBHOOKS_TIMEOUT" env-default:"10s"`
+	MaxAttempts    int           `yaml:"max_attempts" env:"WEBHOOKS_MAX_ATTEMPTS" env-default:"8"`
+	InitialBackoff time.Duration `yaml:"initial_backoff" env:"WEBHOOKS_INITIAL_BACKOFF" env-default:"10s"`
+	MaxBackoff     time.Duration `yaml:"max_backoff" env:"WEBHOOKS_MAX_BACKOFF" env-default:"1h"`
+	History        int           `yaml:"history" env:"WEBHOOKS_HISTORY" env-default:"50"`
+}
+
+// PriorityConfig classifies requests as low, normal or high for upstreams.
+// Routes maps "METHOD /route/pattern" to its priority, unlisted routes get
+// Default; Plans raises every request of a plan to at least the given level.
+type PriorityConfig struct {
+	Default string            `yaml:"default" env:"PRIORITY_DEFAULT" env-default:"normal"`
+	Routes  map[string]string `yaml:"routes" env:"PRIORITY_ROUTES"`
+	Plans   map[string]string `yaml:"plans" env:"PRIORITY_PLANS"`
+}
+
+// ClientErrorsConfig controls POST /api/client-errors. Reports go to the
+// error tracker at TrackerURL, or to the gateway log when it is empty. Each
+// caller may send RateLimit batches per RateWindow, of at most MaxReports
+// reports and MaxBodyBytes.
+type ClientErrorsConfig struct {
+	Enabled      bool          `yaml:"enabled" env:"CLIENT_ERRORS_ENABLED" env-default:"false"`
+	TrackerURL   string        `yaml:"tracker_url" env:"CLIENT_ERRORS_TRACKER_URL"`
+	TrackerToken string        `yaml:"tracker_token" env:"CLIENT_ERRORS_TRACKER_TOKEN"`
+	Timeout      time.Duration `yaml:"timeout" env:"CLIENT_ERRORS_TIMEOUT" env-default:"5s"`
+	MaxBodyBytes int64         `yaml:"max_body_bytes" env:"CLIENT_ERRORS_MAX_BODY_BYTES" env-default:"65536"`
+	MaxReports   int           `yaml:"max_reports" env:"CLIENT_ERRORS_MAX_REPORTS" env-default:"20"`
+	RateLimit    int           `yaml:"rate_limit" env:"CLIENT_ERRORS_RATE_LIMIT" env-default:"30"`
+	RateWindow   time.Duration `yaml:"rate_window" env:"CLIENT_ERRORS_RATE_WINDOW" env-default:"1m"`
+	Buffer       int           `yaml:"buffer" env:"CLIENT_ERRORS_BUFFER" env-default:"1024"`
+}
+
+// JWKSConfig points at the auth service's key set. With a URL, RS256/ES256
+// access tokens are verified locally and APP_SECRET only signs the gateway's
+// own tokens, so it no longer has to match the auth service.
+type JWKSConfig struct {
+	URL                string        `yaml:"url" env:"JWKS_URL"`
+	RefreshInterval    time.Duration `yaml:"refresh_interval" env:"JWKS_REFRESH_INTERVAL" env-default:"10m"`
+	MinRefreshInterval time.Duration `yaml:"min_refresh_interval" env:"JWKS_MIN_REFRESH_INTERVAL" env-default:"30s"`
+	Timeout            time.Duration `yaml:"timeout" env:"JWKS_TIMEOUT" env-default:"5s"`
+}
+
+// DemoConfig enables anonymous demo videos for the marketing site's "try it"
+// widget. Origins are added to CORS so the widget can call the gateway with
+// the device cookie.
+type DemoConfig struct {
+	Enabled bool `yaml:"enabled" env:"DEMO_ENABLED" env-default:"false"`
+	// Secret signs the device cookie; empty falls back to APP_SECRET.
+	Secret        string        `yaml:"secret" env:"DEMO_SECRET"`
+	CookieName    string        `yaml:"cookie_name" env:"DEMO_COOKIE_NAME" env-default:"demo_device"`
+	SecureCookie  bool          `yaml:"secure_cookie" env:"DEMO_SECURE_COOKIE" env-default:"true"`
+	TTL           time.Duration `yaml:"ttl" env:"DEMO_TTL" env-default:"24h"`
+	JobsPerDevice int           `yaml:"jobs_per_device" env:"DEMO_JOBS_PER_DEVICE" env-default:"1"`
+	JobsPerIP     int           `yaml:"jobs_per_ip" env:"DEMO_JOBS_PER_IP" env-default:"3"`
+	IPWindow      time.Duration `yaml:"ip_window" env:"DEMO_IP_WINDOW" env-default:"24h"`
+	UserID        string        `yaml:"user_id" env:"DEMO_USER_ID" env-default:"demo"`
+	Origins       []string      `yaml:"origins" env:"DEMO_ORIGINS"`
+}
+
+// CreditsConfig charges render costs from ready events to the usage store.
+// Monthly allowances are the "credits" limits in usage.plans; LowBalance is
+// the remaining fraction at which users get a warning over the websocket.
+type CreditsConfig struct {
+	Enabled     bool     `yaml:"enabled" env:"CREDITS_ENABLED" env-default:"false"`
+	ReadyStages []string `yaml:"ready_stages" env:"CREDITS_READY_STAGES" env-default:"ready" env-separator:","`
+	CostPath    string   `yaml:"cost_path" env:"CREDITS_COST_PATH" env-default:"job.cost"`
+	CreditsPath string   `yaml:"credits_path" env:"CREDITS_CREDITS_PATH" env-default:"job.cost.credits"`
+	PlanPath    string   `yaml:"plan_path" env:"CREDITS_PLAN_PATH" env-default:"job.plan"`
+	LowBalance  float64  `yaml:"low_balance" env:"CREDITS_LOW_BALANCE" env-default:"0.1"`
+	History     int      `yaml:"history" env:"CREDITS_HISTORY" env-default:"20"`
+	Buffer      int      `yaml:"buffer" env:"CREDITS_BUFFER" env-default:"1024"`
+}
+
+// RecoveryConfig limits the password reset and email verification routes:
+// forgot-password requests per email and all three per client IP.
+// MinResponseTime pads forgot-password responses so existing and unknown
+// emails take equally long.
+type RecoveryConfig struct {
+	EmailLimit      int           `yaml:"email_limit" env:"RECOVERY_EMAIL_LIMIT" env-default:"3"`
+	EmailWindow     time.Duration `yaml:"email_window" env:"RECOVERY_EMAIL_WINDOW" env-default:"1h"`
+	IPLimit         int           `yaml:"ip_limit" env:"RECOVERY_IP_LIMIT" env-default:"20"`
+	IPWindow        time.Duration `yaml:"ip_window" env:"RECOVERY_IP_WINDOW" env-default:"1h"`
+	MinResponseTime time.Duration `yaml:"min_response_time" env:"RECOVERY_MIN_RESPONSE_TIME" env-default:"500ms"`
+	ChallengeLimit  int           `yaml:"challenge_limit" env:"RECOVERY_CHALLENGE_LIMIT" env-default:"5"`
+	ChallengeWindow time.Duration `yaml:"challenge_window" env:"RECOVERY_CHALLENGE_WINDOW" env-default:"15m"`
+}
+
+// CompatConfig is served by GET /api/compat so clients can prompt for an
+// upgrade before calling removed routes. Platforms override the versions per
+// ?platform= (e.g. ios, android, web).
+type CompatConfig struct {
+	MinVersion    string             `yaml:"min_version" env:"COMPAT_MIN_VERSION"`
+	LatestVersion string             `yaml:"latest_version" env:"COMPAT_LATEST_VERSION"`
+	Platforms     CompatPlatforms    `yaml:"platforms" env:"COMPAT_PLATFORMS"`
+	Capabilities  []string           `yaml:"capabilities" env:"COMPAT_CAPABILITIES"`
+	Deprecations  CompatDeprecations `yaml:"deprecations" env:"COMPAT_DEPRECATIONS"`
+}
+
+type CompatPlatformConfig struct {
+	MinVersion    string `yaml:"min_version"`
+	LatestVersion string `yaml:"latest_version"`
+}
+
+// CompatDeprecationConfig is shown to clients older than UntilVersion, or to
+// all clients when it is empty. Sunset is the removal date.
+type CompatDeprecationConfig struct {
+	ID           string   `yaml:"id"`
+	Message      string   `yaml:"message"`
+	Routes       []string `yaml:"routes"`
+	Sunset       string   `yaml:"sunset"`
+	UntilVersion string   `yaml:"until_version"`
+}
+
+// RevocationConfig shares session invalidations (password and email
+// changes) between replicas. Bus is "redis" or empty for a single replica.
+// TTL must cover the lifetime of access tokens; it is at least token_ttl.
+type RevocationConfig struct {
+	Bus     string        `yaml:"bus" env:"REVOCATION_BUS"`
+	Channel string        `yaml:"channel" env:"REVOCATION_CHANNEL" env-default:"gateway_revocations"`
+	TTL     time.Duration `yaml:"ttl" env:"REVOCATION_TTL" env-default:"24h"`
+}
+
+// StoreConfig selects the backend of the shared gateway store: "memory",
+// "redis" (connection from the redis section, keys under Prefix) or "sqlite"
+// (database file at Path).
+type StoreConfig struct {
+	Driver string `yaml:"driver" env:"STORE_DRIVER" env-default:"memory"`
+	Path   string `yaml:"path" env:"STORE_PATH" env-default:"data/gateway.db"`
+	Prefix string `yaml:"prefix" env:"STORE_PREFIX" env-default:"gateway:"`
+}
 
-	return MustLoadPath(configPath)
+// LoginGuardConfig throttles login and register per client IP and email,
+// keeping failures in the shared store. After FreeAttempts failures each
+// further one locks the pair out for BaseLockout, doubled per failure up to
+// MaxLockout. Failures are also counted per email from any IP, which is locked
+// out the same way after EmailFreeAttempts. After CaptchaAfter failures a
+// solved CAPTCHA is required when Captcha.VerifyURL is set.
+type LoginGuardConfig struct {
+	Enabled           bool          `yaml:"enabled" env:"LOGIN_GUARD_ENABLED" env-default:"true"`
+	FreeAttempts      int           `yaml:"free_attempts" env:"LOGIN_GUARD_FREE_ATTEMPTS" env-default:"5"`
+	EmailFreeAttempts int           `yaml:"email_free_attempts" env:"LOGIN_GUARD_EMAIL_FREE_ATTEMPTS" env-default:"20"`
+	BaseLockout       time.Duration `yaml:"base_lockout" env:"LOGIN_GUARD_BASE_LOCKOUT" env-default:"30s"`
+	MaxLockout        time.Duration `yaml:"max_lockout" env:"LOGIN_GUARD_MAX_LOCKOUT" env-default:"15m"`
+	Window            time.Duration `yaml:"window" env:"LOGIN_GUARD_WINDOW" env-default:"1h"`
+	CaptchaAfter      int           `yaml:"captcha_after" env:"LOGIN_GUARD_CAPTCHA_AFTER" env-default:"3"`
+	Captcha           CaptchaConfig `yaml:"captcha"`
+}
+
+// CaptchaConfig points at a siteverify endpoint (reCAPTCHA, hCaptcha or
+// Turnstile).
+type CaptchaConfig struct {
+	VerifyURL string        `yaml:"verify_url" env:"CAPTCHA_VERIFY_URL"`
+	Secret    string        `yaml:"secret" env:"CAPTCHA_SECRET"`
+	Timeout   time.Duration `yaml:"timeout" env:"LOGIN_GUARD_CAPTCHA_TIMEOUT" env-default:"3s"`
+}
+
+// EncryptionConfig holds the master keys sealing secrets in the shared store:
+// base64-encoded 32-byte AES keys by ID (env ENCRYPTION_KEYS as
+// "id:key,id:key"). New values use PrimaryKey; the other keys only decrypt.
+// Without keys, secret storage is disabled.
+type EncryptionConfig struct {
+	PrimaryKey string            `yaml:"primary_key" env:"ENCRYPTION_PRIMARY_KEY"`
+	Keys       map[string]string `yaml:"keys" env:"ENCRYPTION_KEYS"`
+}
+
+// RegionsConfig routes the calls to multi-region upstreams. The client region
+// comes from ClientHeader, set by the edge; Preferred maps it to the region
+// serving it, otherwise the healthy region with the lowest probe latency is
+// used. HintHeader tells the upstream the chosen region.
+type RegionsConfig struct {
+	ClientHeader  string            `yaml:"client_header" env:"REGIONS_CLIENT_HEADER" env-default:"X-Client-Region"`
+	HintHeader    string            `yaml:"hint_header" env:"REGIONS_HINT_HEADER" env-default:"X-Gateway-Region"`
+	Preferred     map[string]string `yaml:"preferred" env:"REGIONS_PREFERRED"`
+	ProbeInterval time.Duration     `yaml:"probe_interval" env:"REGIONS_PROBE_INTERVAL" env-default:"10s"`
+	ProbeTimeout  time.Duration     `yaml:"probe_timeout" env:"REGIONS_PROBE_TIMEOUT" env-default:"2s"`
+}
+
+// BodyLogConfig adds a sample of request and response bodies to the request
+// log for debugging, cut at MaxBytes and with the Redact fields hidden. Routes
+// limits it to some path prefixes (route groups); empty means all of them.
+type BodyLogConfig struct {
+	Enabled    bool     `yaml:"enabled" env:"BODY_LOG_ENABLED" env-default:"false"`
+	SampleRate float64  `yaml:"sample_rate" env:"BODY_LOG_SAMPLE_RATE" env-default:"0.01"`
+	MaxBytes   int      `yaml:"max_bytes" env:"BODY_LOG_MAX_BYTES" env-default:"4096"`
+	Routes     []string `yaml:"routes" env:"BODY_LOG_ROUTES" env-separator:","`
+	Redact     []string `yaml:"redact" env:"BODY_LOG_REDACT" env-default:"password,token,authorization,secret,otpauth,recovery,code" env-separator:","`
+}
+
+// AccessLogConfig writes one line per request apart from the application
+// log. Format is "json" (JSON Lines), "common" or "combined" (Apache/NGINX);
+// Fields limits and orders the keys of JSON lines. Output is "file" (rotated
+// when it reaches MaxSizeMB or MaxAge, keeping MaxBackups rotated files),
+// "stdout", "syslog" or "socket" (raw lines to SocketAddr, e.g. a SIEM
+// collector). Buffer lines are queued for a background writer, 0 writes
+// them synchronously.
+type AccessLogConfig struct {
+	Enabled       bool          `yaml:"enabled" env:"ACCESS_LOG_ENABLED" env-default:"false"`
+	Format        string        `yaml:"format" env:"ACCESS_LOG_FORMAT" env-default:"combined"`
+	Fields        []string      `yaml:"fields" env:"ACCESS_LOG_FIELDS" env-separator:","`
+	Output        string        `yaml:"output" env:"ACCESS_LOG_OUTPUT" env-default:"file"`
+	Path          string        `yaml:"path" env:"ACCESS_LOG_PATH" env-default:"./data/access.log"`
+	MaxSizeMB     int64         `yaml:"max_size_mb" env:"ACCESS_LOG_MAX_SIZE_MB" env-default:"100"`
+	MaxAge        time.Duration `yaml:"max_age" env:"ACCESS_LOG_MAX_AGE" env-default:"24h"`
+	MaxBackups    int           `yaml:"max_backups" env:"ACCESS_LOG_MAX_BACKUPS" env-default:"7"`
+	SyslogNetwork string        `yaml:"syslog_network" env:"ACCESS_LOG_SYSLOG_NETWORK" env-default:"udp"`
+	SyslogAddr    string        `yaml:"syslog_addr" env:"ACCESS_LOG_SYSLOG_ADDR"`
+	SyslogTag     string        `yaml:"syslog_tag" env:"ACCESS_LOG_SYSLOG_TAG" env-default:"api-gateway"`
+	SocketNetwork string        `yaml:"socket_network" env:"ACCESS_LOG_SOCKET_NETWORK" env-default:"tcp"`
+	SocketAddr    string        `yaml:"socket_addr" env:"ACCESS_LOG_SOCKET_ADDR"`
+	Buffer        int           `yaml:"buffer" env:"ACCESS_LOG_BUFFER" env-default:"1024"`
+}
+
+// ReplicaConfig identifies this gateway instance among its replicas; ID
+// defaults to the host name. Replicas announce themselves in the shared store
+// every Heartbeat to split per-job side effects between them.
+type ReplicaConfig struct {
+	ID        string        `yaml:"id" env:"REPLICA_ID"`
+	Heartbeat time.Duration `yaml:"heartbeat" env:"REPLICA_HEARTBEAT" env-default:"10s"`
+}
+
+// SentryConfig reports handler panics and failed upstream calls to Sentry
+// when DSN is set. Environment defaults to env.
+type SentryConfig struct {
+	DSN         string        `yaml:"dsn" env:"SENTRY_DSN"`
+	Environment string        `yaml:"environment" env:"SENTRY_ENVIRONMENT"`
+	Release     string        `yaml:"release" env:"SENTRY_RELEASE"`
+	Timeout     time.Duration `yaml:"timeout" env:"SENTRY_TIMEOUT" env-default:"5s"`
+	Buffer      int           `yaml:"buffer" env:"SENTRY_BUFFER" env-default:"256"`
+}
+
+// CORSConfig lists the browser origins allowed to call the API. Websocket
+// upgrades from other origins are refused with 403 too.
+type CORSConfig struct {
+	AllowOrigins []string `yaml:"allow_origins" env:"CORS_ALLOW_ORIGINS" env-separator:"," env-default:"http://localhost:3000,http://87.228.89.123:3000"`
+}
+
+// ReloadConfig re-reads the config file and .env every Interval (and on
+// SIGHUP) and applies CORS origins, rate limits, upstream timeouts and route
+// toggles without a restart. Other changed keys are logged and need one.
+type ReloadConfig struct {
+	Enabled  bool          `yaml:"enabled" env:"CONFIG_RELOAD_ENABLED" env-default:"false"`
+	Interval time.Duration `yaml:"interval" env:"RELOAD_INTERVAL" env-default:"5s"`
+}
+
+// RoutesConfig switches routes off and tunes them: Disabled lists route
+// templates as registered ("/api/videos/:id/stream"), optionally prefixed with
+// a method ("DELETE /api/videos/:id"); a trailing "/*" disables every route
+// below the prefix. Disabled routes answer 503. Timeouts override the service
+// timeout of upstream calls by route name, the handler method in snake case
+// (expand_idea: 60s, list_videos: 2s).
+type RoutesConfig struct {
+	Disabled []string                 `yaml:"disabled" env:"ROUTES_DISABLED" env-separator:","`
+	Timeouts map[string]time.Duration `yaml:"timeouts" env:"ROUTES_TIMEOUTS"`
+}
+
+// RequestBodyConfig limits request bodies parsed at the gateway: JSON bodies
+// to MaxJSONBytes and MaxJSONDepth levels of nesting, gzip/deflate encoded
+// bodies to MaxDecodedBytes once decoded. ExcludePaths skip the JSON checks.
+type RequestBodyConfig struct {
+	MaxJSONBytes    int64    `yaml:"max_json_bytes" env:"REQUEST_BODY_MAX_JSON_BYTES" env-default:"1048576"`
+	MaxJSONDepth    int      `yaml:"max_json_depth" env:"REQUEST_BODY_MAX_JSON_DEPTH" env-default:"64"`
+	MaxDecodedBytes int64    `yaml:"max_decoded_bytes" env:"REQUEST_BODY_MAX_DECODED_BYTES" env-default:"33554432"`
+	ExcludePaths    []string `yaml:"exclude_paths" env:"REQUEST_BODY_EXCLUDE_PATHS" env-separator:"," env-default:"/api/videos/media"`
+}
+
+// SecretsConfig resolves config values written as references to a secret
+// manager instead of the secret itself, e.g. app_secret or
+// kafka.sasl.password:
+//
+//	vault:kv/data/gateway#app_secret       HashiCorp Vault KV (v1 or v2)
+//	awssm:prod/gateway#app_secret          AWS Secrets Manager
+//	gcpsm:projects/p/secrets/gateway#key   GCP Secret Manager
+//
+// #key picks a field of a JSON secret; without it the whole value is used.
+// References are resolved on load and, with RefreshInterval set, again that
+// often; rotated Kafka credentials apply to new broker connections, other
+// secrets on restart. AWS credentials come from AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN; GCP ones from
+// GOOGLE_OAUTH_ACCESS_TOKEN or the metadata server.
+type SecretsConfig struct {
+	VaultAddr       string        `yaml:"vault_addr" env:"VAULT_ADDR"`
+	VaultToken      string        `yaml:"vault_token" env:"VAULT_TOKEN"`
+	VaultTokenFile  string        `yaml:"vault_token_file" env:"VAULT_TOKEN_FILE"`
+	VaultNamespace  string        `yaml:"vault_namespace" env:"VAULT_NAMESPACE"`
+	AWSRegion       string        `yaml:"aws_region" env:"AWS_REGION"`
+	Timeout         time.Duration `yaml:"timeout" env:"SECRETS_TIMEOUT" env-default:"10s"`
+	RefreshInterval time.Duration `yaml:"refresh_interval" env:"SECRETS_REFRESH_INTERVAL" env-default:"0s"`
+}
+
+// MocksConfig serves canned responses instead of calling the script and
+// video services, so the frontend can be developed without them. Requests no
+// route matches still go to the service. Ignored in prod.
+type MocksConfig struct {
+	Enabled bool       `yaml:"enabled" env:"MOCKS_ENABLED" env-default:"false"`
+	Routes  MockRoutes `yaml:"routes" env:"MOCKS_ROUTES"`
+}
+
+// MockRouteConfig answers Method Path of Service ("scripts" or "videos"), an
+// upstream path like "/videos/:id", with Status, Headers and Body (or the
+// contents of BodyFile) after Latency. "{id}" in the body is replaced with
+// the matched segment; a trailing "/*" matches every path below.
+type MockRouteConfig struct {
+	Service  string            `yaml:"service"`
+	Method   string            `yaml:"method"`
+	Path     string            `yaml:"path"`
+	Status   int               `yaml:"status"`
+	Latency  time.Duration     `yaml:"latency"`
+	Headers  map[string]string `yaml:"headers"`
+	Body     string            `yaml:"body"`
+	BodyFile string            `yaml:"body_file"`
+}
+
+// ServerTimingConfig adds a Server-Timing header splitting each response's
+// duration into upstream calls and gateway overhead: for every request when
+// Enabled, otherwise for requests carrying Header set to Token.
+type ServerTimingConfig struct {
+	Enabled bool   `yaml:"enabled" env:"SERVER_TIMING_ENABLED" env-default:"false"`
+	Header  string `yaml:"header" env:"SERVER_TIMING_HEADER" env-default:"X-Debug-Timing"`
+	Token   string `yaml:"token" env:"SERVER_TIMING_TOKEN"`
+}
+
+// OIDCConfig points at an OpenID Connect issuer. Its discovery document is
+// served to frontends by GET /api/auth/config along with ClientID and Scopes,
+// and its jwks_uri verifies access tokens when jwks.url is empty.
+type OIDCConfig struct {
+	Issuer          string        `yaml:"issuer" env:"OIDC_ISSUER"`
+	ClientID        string        `yaml:"client_id" env:"OIDC_CLIENT_ID"`
+	Scopes          []string      `yaml:"scopes" env:"OIDC_SCOPES" env-separator:"," env-default:"openid,profile,email"`
+	RefreshInterval time.Duration `yaml:"refresh_interval" env:"OIDC_REFRESH_INTERVAL" env-default:"1h"`
+	Timeout         time.Duration `yaml:"timeout" env:"OIDC_TIMEOUT" env-default:"5s"`
+}
+
+// IdempotencyConfig makes POST /api/videos and POST /api/scripts replay
+// their first response to retries with the same Idempotency-Key for TTL. The
+// records live in the shared store, so use the redis driver with several
+// replicas. LockTimeout frees the key of a request that never finished.
+type IdempotencyConfig struct {
+	Enabled     bool          `yaml:"enabled" env:"IDEMPOTENCY_ENABLED" env-default:"true"`
+	TTL         time.Duration `yaml:"ttl" env:"IDEMPOTENCY_TTL" env-default:"24h"`
+	LockTimeout time.Duration `yaml:"lock_timeout" env:"IDEMPOTENCY_LOCK_TIMEOUT" env-default:"5m"`
+}
+
+// MediaStreamConfig feeds GET /api/videos/media/:id/stream from Topic, read
+// from the brokers of the kafka section in its mode. Events are routed by the
+// media ID at IDPath and sent only to the owner at UserIDPath; the stream
+// ends on one of TerminalStatuses at StatusPath. An empty Topic disables it.
+type MediaStreamConfig struct {
+	Topic            string   `yaml:"topic" env:"MEDIA_STREAM_TOPIC"`
+	GroupID          string   `yaml:"group_id" env:"MEDIA_STREAM_GROUP_ID" env-default:"api-gateway-media-stream"`
+	IDPath           string   `yaml:"id_path" env:"MEDIA_STREAM_ID_PATH" env-default:"media.id"`
+	UserIDPath       string   `yaml:"user_id_path" env:"MEDIA_STREAM_USER_ID_PATH" env-default:"media.user_id"`
+	StatusPath       string   `yaml:"status_path" env:"MEDIA_STREAM_STATUS_PATH" env-default:"media.status"`
+	TerminalStatuses []string `yaml:"terminal_statuses" env:"MEDIA_STREAM_TERMINAL_STATUSES" env-default:"ready,failed" env-separator:","`
+}
+
+// HTTPClientConfig tunes the connection pool shared by the upstream HTTP
+// clients (scripts, videos and the other HTTP integrations). Zero keeps the
+// net/http default; MaxConnsPerHost 0 means no limit.
+type HTTPClientConfig struct {
+	MaxIdleConns        int           `yaml:"max_idle_conns" env:"HTTP_CLIENT_MAX_IDLE_CONNS" env-default:"200"`
+	MaxIdleConnsPerHost int           `yaml:"max_idle_conns_per_host" env:"HTTP_CLIENT_MAX_IDLE_CONNS_PER_HOST" env-default:"64"`
+	MaxConnsPerHost     int           `yaml:"max_conns_per_host" env:"HTTP_CLIENT_MAX_CONNS_PER_HOST" env-default:"0"`
+	IdleConnTimeout     time.Duration `yaml:"idle_conn_timeout" env:"HTTP_CLIENT_IDLE_CONN_TIMEOUT" env-default:"90s"`
+	TLSHandshakeTimeout time.Duration `yaml:"tls_handshake_timeout" env:"HTTP_CLIENT_TLS_HANDSHAKE_TIMEOUT" env-default:"10s"`
+	DisableCompression  bool          `yaml:"disable_compression" env:"HTTP_CLIENT_DISABLE_COMPRESSION" env-default:"false"`
+}
+
+// VideoLimitsConfig caps the duration and resolution of POST /api/videos by
+// plan, e.g. plans.free: {max_duration: 60s, max_resolution: 720p}. The
+// fields are read from DurationPath (seconds or "90s") and ResolutionPath
+// ("720p", "4k" or "1280x720") of the request body.
+type VideoLimitsConfig struct {
+	DefaultPlan    string          `yaml:"default_plan" env:"VIDEO_LIMITS_DEFAULT_PLAN" env-default:"free"`
+	DurationPath   string          `yaml:"duration_path" env:"VIDEO_LIMITS_DURATION_PATH" env-default:"duration"`
+	ResolutionPath string          `yaml:"resolution_path" env:"VIDEO_LIMITS_RESOLUTION_PATH" env-default:"resolution"`
+	Plans          VideoPlanLimits `yaml:"plans" env:"VIDEO_LIMITS_PLANS"`
+}
+
+type VideoPlanLimit struct {
+	MaxDuration   time.Duration `yaml:"max_duration"`
+	MaxResolution string        `yaml:"max_resolution"`
+}
+
+// DiscoveryConfig is the Consul agent queried by upstreams discovered with
+// mode consul, and the timeout of every endpoint refresh.
+type DiscoveryConfig struct {
+	ConsulAddress    string        `yaml:"consul_address" env:"DISCOVERY_CONSUL_ADDRESS" env-default:"http://127.0.0.1:8500"`
+	ConsulToken      string        `yaml:"consul_token" env:"DISCOVERY_CONSUL_TOKEN"`
+	ConsulDatacenter string        `yaml:"consul_datacenter" env:"DISCOVERY_CONSUL_DATACENTER"`
+	Timeout          time.Duration `yaml:"timeout" env:"DISCOVERY_TIMEOUT" env-default:"2s"`
+}
+
+// ExportsConfig bounds the ?all=true admin listings that walk the upstream
+// pagination: the page size asked for, the most pages followed and how many
+// are fetched ahead of the response.
+type ExportsConfig struct {
+	PageSize int `yaml:"page_size" env:"EXPORTS_PAGE_SIZE" env-default:"100"`
+	MaxPages int `yaml:"max_pages" env:"EXPORTS_MAX_PAGES" env-default:"1000"`
+	Prefetch int `yaml:"prefetch" env:"EXPORTS_PREFETCH" env-default:"4"`
+}
+
+// CanaryConfig names the header developers send with "always" or "never"
+// to pick the version of upstreams with a canary. Empty disables overrides.
+type CanaryConfig struct {
+	Header string `yaml:"header" env:"CANARY_HEADER" env-default:"X-Canary"`
+}
+
+// AuthCacheConfig keeps the auth service's GetUser and IsAdmin answers per
+// user for TTL; zero disables the cache. MaxEntries bounds each RPC's cache.
+type AuthCacheConfig struct {
+	TTL        time.Duration `yaml:"ttl" env:"AUTH_CACHE_TTL" env-default:"5s"`
+	MaxEntries int           `yaml:"max_entries" env:"AUTH_CACHE_MAX_ENTRIES" env-default:"10000"`
+}
+
+// MaintenanceConfig defines the route groups that can be put into
+// maintenance, see maintenance.Config. Groups listed in Active are in
+// maintenance from the config; admins switch the others through
+// /api/admin/maintenance, and replicas pick those switches up from the
+// gateway store every RefreshInterval.
+type MaintenanceConfig struct {
+	Groups          MaintenanceGroups `yaml:"groups" env:"MAINTENANCE_GROUPS"`
+	Active          []string          `yaml:"active" env:"MAINTENANCE_ACTIVE" env-separator:","`
+	Message         string            `yaml:"message" env:"MAINTENANCE_MESSAGE" env-default:"this part of the service is under maintenance"`
+	RetryAfter      time.Duration     `yaml:"retry_after" env:"MAINTENANCE_RETRY_AFTER" env-default:"5m"`
+	RefreshInterval time.Duration     `yaml:"refresh_interval" env:"MAINTENANCE_REFRESH_INTERVAL" env-default:"5s"`
+}
+
+// AnalyticsConfig publishes a "job_requested" record for every video created
+// through the gateway to Topic on the brokers of the kafka section. Enabled
+// can be flipped by a reload once the gateway runs with brokers configured.
+type AnalyticsConfig struct {
+	Enabled bool   `yaml:"enabled" env:"ANALYTICS_ENABLED" env-default:"false"`
+	Topic   string `yaml:"topic" env:"ANALYTICS_TOPIC" env-default:"gateway_job_requests"`
+	Buffer  int    `yaml:"buffer" env:"ANALYTICS_BUFFER" env-default:"1024"`
+}
+
+// FeatureFlagsConfig defines the feature flags and their targeting rules.
+// Provider "ofrep" asks the OpenFeature remote evaluation service at URL
+// first, caching its answers per user for CacheTTL; the default "config"
+// evaluates the rules alone.
+type FeatureFlagsConfig struct {
+	Flags    FlagList      `yaml:"flags" env:"FEATURE_FLAGS"`
+	Provider string        `yaml:"provider" env:"FEATURE_FLAGS_PROVIDER" env-default:"config"`
+	URL      string        `yaml:"url" env:"FEATURE_FLAGS_URL"`
+	APIKey   string        `yaml:"api_key" env:"FEATURE_FLAGS_API_KEY"`
+	Timeout  time.Duration `yaml:"timeout" env:"FEATURE_FLAGS_TIMEOUT" env-default:"500ms"`
+	CacheTTL time.Duration `yaml:"cache_ttl" env:"FEATURE_FLAGS_CACHE_TTL" env-default:"30s"`
+}
+
+// FlagConfig is a feature flag, see flags.Flag.
+type FlagConfig struct {
+	Name        string   `yaml:"name"`
+	Description string   `yaml:"description"`
+	Enabled     bool     `yaml:"enabled"`
+	Percentage  float64  `yaml:"percentage"`
+	Users       []string `yaml:"users"`
+	Plans       []string `yaml:"plans"`
+	Routes      []string `yaml:"routes"`
+}
+
+// StatusPageConfig shapes GET /api/status: the components shown, how long an
+// answer is cached, and the share of failed requests over ErrorWindow that
+// makes a component degraded or down, once it saw MinRequests requests.
+type StatusPageConfig struct {
+	Components        StatusComponents `yaml:"components" env:"STATUS_PAGE_COMPONENTS"`
+	CacheTTL          time.Duration    `yaml:"cache_ttl" env:"STATUS_PAGE_CACHE_TTL" env-default:"15s"`
+	ErrorWindow       time.Duration    `yaml:"error_window" env:"STATUS_PAGE_ERROR_WINDOW" env-default:"5m"`
+	MinRequests       int              `yaml:"min_requests" env:"STATUS_PAGE_MIN_REQUESTS" env-default:"20"`
+	DegradedErrorRate float64          `yaml:"degraded_error_rate" env:"STATUS_PAGE_DEGRADED_ERROR_RATE" env-default:"0.05"`
+	OutageErrorRate   float64          `yaml:"outage_error_rate" env:"STATUS_PAGE_OUTAGE_ERROR_RATE" env-default:"0.5"`
+}
+
+// StatusComponentConfig is a component of the status page, see
+// handlers.StatusComponent.
+type StatusComponentConfig struct {
+	Name              string   `yaml:"name"`
+	Upstreams         []string `yaml:"upstreams"`
+	Routes            []string `yaml:"routes"`
+	MaintenanceGroups []string `yaml:"maintenance_groups"`
+}
+
+// OpenAPIConfig serves the gateway's OpenAPI document at /api/openapi.json
+// and, with SwaggerUI, Swagger UI at /api/docs loading its assets from
+// SwaggerUIAssets.
+type OpenAPIConfig struct {
+	Enabled         bool   `yaml:"enabled" env:"OPENAPI_ENABLED" env-default:"true"`
+	Title           string `yaml:"title" env:"OPENAPI_TITLE" env-default:"Madrigal API"`
+	Version         string `yaml:"version" env:"OPENAPI_VERSION" env-default:"1.0.0"`
+	ServerURL       string `yaml:"server_url" env:"OPENAPI_SERVER_URL"`
+	SwaggerUI       bool   `yaml:"swagger_ui" env:"OPENAPI_SWAGGER_UI" env-default:"false"`
+	SwaggerUIAssets string `yaml:"swagger_ui_assets" env:"OPENAPI_SWAGGER_UI_ASSETS" env-default:"https://unpkg.com/swagger-ui-dist@5"`
+}
+
+// ReceiptsConfig signs the receipts of video downloads with SigningKey,
+// named KeyID in them so the key can be rotated; an empty key falls back to
+// APP_SECRET. Receipts are kept for Retention, forever when zero.
+type ReceiptsConfig struct {
+	SigningKey string        `yaml:"signing_key" env:"RECEIPTS_SIGNING_KEY"`
+	KeyID      string        `yaml:"key_id" env:"RECEIPTS_KEY_ID" env-default:"1"`
+	Retention  time.Duration `yaml:"retention" env:"RECEIPTS_RETENTION" env-default:"0s"`
+}
+
+// APIVersionsConfig picks the API versions served under /api/vN, all known
+// ones when Versions is empty, and the one unprefixed /api routes get.
+type APIVersionsConfig struct {
+	Default  string              `yaml:"default" env:"API_VERSIONS_DEFAULT" env-default:"v1"`
+	Versions APIVersionSchedules `yaml:"versions" env:"API_VERSIONS"`
+}
+
+// APIVersionConfig schedules the retirement of a version: its responses
+// announce the dates ("2027-01-31" or RFC 3339) in Deprecation and Sunset
+// headers and link the migration guide.
+type APIVersionConfig struct {
+	Deprecated string `yaml:"deprecated"`
+	Sunset     string `yaml:"sunset"`
+	Link       string `yaml:"link"`
+}
+
+// PassthroughConfig exposes upstream endpoints that have no handler yet at
+// /api/ext/<service>/<upstream path>, for the Routes listed only. MaxBody
+// bounds the forwarded request body.
+type PassthroughConfig struct {
+	Routes  PassthroughRoutes `yaml:"routes" env:"PASSTHROUGH_ROUTES"`
+	MaxBody int64             `yaml:"max_body" env:"PASSTHROUGH_MAX_BODY" env-default:"1048576"`
+}
+
+// PassthroughRouteConfig allows Methods on Path of Service ("scripts" or
+// "videos"); see handlers.PassthroughRoute.
+type PassthroughRouteConfig struct {
+	Service string   `yaml:"service"`
+	Methods []string `yaml:"methods"`
+	Path    string   `yaml:"path"`
+}
+
+// GraphQLConfig serves /api/graphql, a read-only query endpoint over the
+// current user, their videos and their scripts. MaxDepth bounds the nesting
+// of a query, MaxRootFields its root fields, aliases included.
+type GraphQLConfig struct {
+	Enabled       bool `yaml:"enabled" env:"GRAPHQL_ENABLED" env-default:"false"`
+	MaxDepth      int  `yaml:"max_depth" env:"GRAPHQL_MAX_DEPTH" env-default:"8"`
+	MaxRootFields int  `yaml:"max_root_fields" env:"GRAPHQL_MAX_ROOT_FIELDS" env-default:"10"`
+}
+
+// BatchGetConfig bounds GET /api/videos:batchGet: the IDs per request and
+// the video service calls made at once for one request.
+type BatchGetConfig struct {
+	MaxIDs      int `yaml:"max_ids" env:"BATCH_GET_MAX_IDS" env-default:"50"`
+	Concurrency int `yaml:"concurrency" env:"BATCH_GET_CONCURRENCY" env-default:"8"`
+}
+
+// path is the file the configuration was loaded from, read again by Reload;
+// empty when it comes from the environment alone.
+var path string
+
+// MustLoad reads the file given by -config or CONFIG_PATH, environment
+// variables overriding its values. Without a file the configuration comes
+// from the environment alone, unset values taking their defaults.
+func MustLoad() *Config {
+	return MustLoadPath(fetchConfigPath())
 }
 
 func MustLoadPath(configPath string) *Config {
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		panic("config file does not exist: " + configPath)
+	if configPath != "" {
+		if _, err := os.Stat(configPath); os.IsNotExist(err) {
+			panic("config file does not exist: " + configPath)
+		}
 	}
 
-	var cfg Config
-
-	if err := cleanenv.ReadConfig(configPath, &cfg); err != nil {
+	cfg, err := Load(configPath)
+	if err != nil {
 		panic("cannot read config: " + err.Error())
 	}
+	path = configPath
+
+	return cfg
+}
+
+// Load reads the configuration from configPath and the environment, or from
+// the environment only when configPath is empty.
+func Load(configPath string) (*Config, error) {
+	var cfg Config
+	var err error
+	if configPath == "" {
+		err = cleanenv.ReadEnv(&cfg)
+	} else {
+		err = cleanenv.ReadConfig(configPath, &cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := resolveSecrets(&cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Path returns the file MustLoad read the configuration from, empty when it
+// was read from the environment only.
+func Path() string {
+	return path
+}
 
-	return &cfg
+// Reload reads the configuration the way MustLoad did, with the current
+// environment.
+func Reload() (*Config, error) {
+	return Load(path)
 }
 
 func fetchConfigPath() string {
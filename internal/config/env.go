@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The list and map sections below can't be spelled as plain environment
+// values, so their variables hold the section as YAML or JSON, e.g.
+// SCRIPT_SERVICE_REGIONS='[{"name":"eu","base_url":"http://scripts-eu:8002"}]'.
+
+type RegionList []RegionConfig
+
+func (l *RegionList) SetValue(s string) error { return setYAML(l, s) }
+
+type RewriteList []RewriteConfig
+
+func (l *RewriteList) SetValue(s string) error { return setYAML(l, s) }
+
+type FlagList []FlagConfig
+
+func (l *FlagList) SetValue(s string) error { return setYAML(l, s) }
+
+type StatusComponents []StatusComponentConfig
+
+func (c *StatusComponents) SetValue(s string) error { return setYAML(c, s) }
+
+type MaskingRules []MaskingRule
+
+func (r *MaskingRules) SetValue(s string) error { return setYAML(r, s) }
+
+type CompatPlatforms map[string]CompatPlatformConfig
+
+func (p *CompatPlatforms) SetValue(s string) error { return setYAML(p, s) }
+
+type CompatDeprecations []CompatDeprecationConfig
+
+func (d *CompatDeprecations) SetValue(s string) error { return setYAML(d, s) }
+
+type MockRoutes []MockRouteConfig
+
+func (r *MockRoutes) SetValue(s string) error { return setYAML(r, s) }
+
+// PlanLimits maps plan name to daily limits by endpoint.
+type PlanLimits map[string]map[string]int
+
+func (p *PlanLimits) SetValue(s string) error { return setYAML(p, s) }
+
+// PlanQuotas maps plan name to monthly quotas by kind.
+type PlanQuotas map[string]map[string]int64
+
+func (p *PlanQuotas) SetValue(s string) error { return setYAML(p, s) }
+
+// PlanFeatures maps plan name to the features it includes.
+type PlanFeatures map[string][]string
+
+func (p *PlanFeatures) SetValue(s string) error { return setYAML(p, s) }
+
+// VideoPlanLimits maps plan name to its video limits.
+type VideoPlanLimits map[string]VideoPlanLimit
+
+func (p *VideoPlanLimits) SetValue(s string) error { return setYAML(p, s) }
+
+// MaintenanceGroups maps a maintenance group to its route rules.
+type MaintenanceGroups map[string][]string
+
+func (g *MaintenanceGroups) SetValue(s string) error { return setYAML(g, s) }
+
+type PassthroughRoutes []PassthroughRouteConfig
+
+func (r *PassthroughRoutes) SetValue(s string) error { return setYAML(r, s) }
+
+// APIVersionSchedules maps an API version to its deprecation schedule.
+type APIVersionSchedules map[string]APIVersionConfig
+
+func (v *APIVersionSchedules) SetValue(s string) error { return setYAML(v, s) }
+
+func setYAML(v any, s string) error {
+	if err := yaml.Unmarshal([]byte(s), v); err != nil {
+		return fmt.Errorf("invalid yaml or json value: %w", err)
+	}
+	return nil
+}
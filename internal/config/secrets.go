@@ -0,0 +1,322 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Secret reference schemes, see SecretsConfig.
+const (
+	secretVault = "vault:"
+	secretAWS   = "awssm:"
+	secretGCP   = "gcpsm:"
+)
+
+const gcpMetadataToken = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// secretResolver fetches referenced secrets, each secret once per pass.
+type secretResolver struct {
+	cfg     SecretsConfig
+	http    *http.Client
+	fetched map[string][]byte
+}
+
+// resolveSecrets replaces the string values of cfg that are secret references
+// with the secrets they point to. The secrets section itself isn't resolved.
+func resolveSecrets(cfg *Config) error {
+	timeout := cfg.Secrets.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	r := &secretResolver{
+		cfg:     cfg.Secrets,
+		http:    &http.Client{Timeout: timeout},
+		fetched: make(map[string][]byte),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*timeout)
+	defer cancel()
+	return r.walk(ctx, reflect.ValueOf(cfg).Elem(), "")
+}
+
+func (r *secretResolver) walk(ctx context.Context, v reflect.Value, path string) error {
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() || field.Type == reflect.TypeOf(SecretsConfig{}) {
+				continue
+			}
+			name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+			if path != "" {
+				name = path + "." + name
+			}
+			if err := r.walk(ctx, v.Field(i), name); err != nil {
+				return err
+			}
+		}
+	case reflect.String:
+		resolved, ok, err := r.resolve(ctx, v.String())
+		if err != nil {
+			return fmt.Errorf("resolve %s: %w", path, err)
+		}
+		if ok {
+			v.SetString(resolved)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := r.walk(ctx, v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.Type().Elem().Kind() != reflect.String {
+			return nil
+		}
+		for _, key := range v.MapKeys() {
+			resolved, ok, err := r.resolve(ctx, v.MapIndex(key).String())
+			if err != nil {
+				return fmt.Errorf("resolve %s.%v: %w", path, key, err)
+			}
+			if ok {
+				v.SetMapIndex(key, reflect.ValueOf(resolved).Convert(v.Type().Elem()))
+			}
+		}
+	}
+	return nil
+}
+
+// resolve returns the secret ref points to; ok is false when ref isn't a
+// reference.
+func (r *secretResolver) resolve(ctx context.Context, ref string) (string, bool, error) {
+	var fetch func(context.Context, string) ([]byte, error)
+	var name string
+	switch {
+	case strings.HasPrefix(ref, secretVault):
+		fetch, name = r.vault, strings.TrimPrefix(ref, secretVault)
+	case strings.HasPrefix(ref, secretAWS):
+		fetch, name = r.aws, strings.TrimPrefix(ref, secretAWS)
+	case strings.HasPrefix(ref, secretGCP):
+		fetch, name = r.gcp, strings.TrimPrefix(ref, secretGCP)
+	default:
+		return "", false, nil
+	}
+	name, key, _ := strings.Cut(name, "#")
+	scheme, _, _ := strings.Cut(ref, ":")
+	cacheKey := scheme + ":" + name
+	secret, ok := r.fetched[cacheKey]
+	if !ok {
+		var err error
+		if secret, err = fetch(ctx, name); err != nil {
+			return "", false, err
+		}
+		r.fetched[cacheKey] = secret
+	}
+	if key == "" {
+		return string(secret), true, nil
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(secret, &fields); err != nil {
+		return "", false, fmt.Errorf("secret %s is not a JSON object", cacheKey)
+	}
+	value, ok := fields[key]
+	if !ok {
+		return "", false, fmt.Errorf("secret %s has no key %q", cacheKey, key)
+	}
+	if s, ok := value.(string); ok {
+		return s, true, nil
+	}
+	return fmt.Sprint(value), true, nil
+}
+
+// vault reads a KV secret, version 1 or 2 (path "kv/data/<name>"), and
+// returns its fields as a JSON object.
+func (r *secretResolver) vault(ctx context.Context, path string) ([]byte, error) {
+	if r.cfg.VaultAddr == "" {
+		return nil, fmt.Errorf("vault address is not configured")
+	}
+	token := r.cfg.VaultToken
+	if token == "" && r.cfg.VaultTokenFile != "" {
+		data, err := os.ReadFile(r.cfg.VaultTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("read vault token: %w", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(r.cfg.VaultAddr, "/")+"/v1/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return nil, fmt.Errorf("create vault request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if r.cfg.VaultNamespace != "" {
+		req.Header.Set("X-Vault-Namespace", r.cfg.VaultNamespace)
+	}
+	body, err := r.do(req, "vault")
+	if err != nil {
+		return nil, err
+	}
+	var payload struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("decode vault response: %w", err)
+	}
+	_, hasMetadata := payload.Data["metadata"]
+	if data, ok := payload.Data["data"]; ok && hasMetadata {
+		return data, nil
+	}
+	return json.Marshal(payload.Data)
+}
+
+// aws reads the string value of a Secrets Manager secret with the
+// credentials of the standard AWS_* environment variables.
+func (r *secretResolver) aws(ctx context.Context, id string) ([]byte, error) {
+	region := r.cfg.AWSRegion
+	if region == "" {
+		return nil, fmt.Errorf("aws region is not configured")
+	}
+	accessKey, secretKey := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required")
+	}
+	payload, _ := json.Marshal(map[string]string{"SecretId": id})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://secretsmanager."+region+".amazonaws.com/", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("create aws request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	if token := os.Getenv("AWS_SESSION_TOKEN"); token != "" {
+		req.Header.Set("X-Amz-Security-Token", token)
+	}
+	signAWS(req, payload, region, "secretsmanager", accessKey, secretKey, time.Now().UTC())
+	body, err := r.do(req, "aws secrets manager")
+	if err != nil {
+		return nil, err
+	}
+	var secret struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return nil, fmt.Errorf("decode aws response: %w", err)
+	}
+	return []byte(secret.SecretString), nil
+}
+
+// signAWS adds a Signature Version 4 Authorization header to req.
+func signAWS(req *http.Request, payload []byte, region, service, accessKey, secretKey string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("Host", req.URL.Host)
+
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, strings.ToLower(name))
+	}
+	sort.Strings(names)
+	var headers strings.Builder
+	for _, name := range names {
+		headers.WriteString(name + ":" + strings.TrimSpace(req.Header.Get(name)) + "\n")
+	}
+	signed := strings.Join(names, ";")
+	payloadHash := sha256.Sum256(payload)
+	canonical := strings.Join([]string{req.Method, "/", "", headers.String(), signed, hex.EncodeToString(payloadHash[:])}, "\n")
+	canonicalHash := sha256.Sum256([]byte(canonical))
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	for _, part := range []string{region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKey+"/"+scope+
+		", SignedHeaders="+signed+", Signature="+hex.EncodeToString(hmacSHA256(key, toSign)))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// gcp reads a Secret Manager secret version ("projects/p/secrets/s", latest
+// version by default) with the token of GOOGLE_OAUTH_ACCESS_TOKEN or, when
+// unset, of the instance service account from the metadata server.
+func (r *secretResolver) gcp(ctx context.Context, name string) ([]byte, error) {
+	if !strings.Contains(name, "/versions/") {
+		name += "/versions/latest"
+	}
+	token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")
+	if token == "" {
+		var err error
+		if token, err = r.gcpToken(ctx); err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://secretmanager.googleapis.com/v1/"+name+":access", nil)
+	if err != nil {
+		return nil, fmt.Errorf("create gcp request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	body, err := r.do(req, "gcp secret manager")
+	if err != nil {
+		return nil, err
+	}
+	var version struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(body, &version); err != nil {
+		return nil, fmt.Errorf("decode gcp response: %w", err)
+	}
+	return base64.StdEncoding.DecodeString(version.Payload.Data)
+}
+
+func (r *secretResolver) gcpToken(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gcpMetadataToken, nil)
+	if err != nil {
+		return "", fmt.Errorf("create metadata request: %w", err)
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	body, err := r.do(req, "gcp metadata server")
+	if err != nil {
+		return "", err
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", fmt.Errorf("decode metadata token: %w", err)
+	}
+	return token.AccessToken, nil
+}
+
+func (r *secretResolver) do(req *http.Request, backend string) ([]byte, error) {
+	resp, err := r.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s request failed: %w", backend, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("read %s response: %w", backend, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned status %d", backend, resp.StatusCode)
+	}
+	return body, nil
+}
@@ -0,0 +1,280 @@
+// Package credits charges the cost of completed renders to their owners. The
+// video service reports the cost in the ready event; the ledger adds it to
+// the "credits" usage metric, keeps the latest charges per user and pushes a
+// warning over the hub when the monthly balance runs low.
+package credits
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/immxrtalbeast/api-gateway/internal/events"
+	"github.com/immxrtalbeast/api-gateway/internal/metrics"
+	"github.com/immxrtalbeast/api-gateway/internal/usage"
+	"github.com/immxrtalbeast/api-gateway/lib/jsonpath"
+)
+
+// Warning event types pushed to the user's hub channel.
+const (
+	EventLowBalance = "credits.low_balance"
+	EventExhausted  = "credits.exhausted"
+)
+
+const (
+	defaultBuffer  = 1024
+	chargeTimeout  = 2 * time.Second
+	chargedJobsTTL = 24 * time.Hour
+)
+
+type Config struct {
+	// StagePath and ReadyStages recognize completed renders.
+	StagePath   string
+	ReadyStages []string
+	// CostPath locates the cost object in the event, CreditsPath the number
+	// of credits within the event, and PlanPath the owner's plan.
+	CostPath    string
+	CreditsPath string
+	PlanPath    string
+	// LowBalance is the fraction of the plan allowance at which a
+	// low-balance warning is pushed, 0.1 by default.
+	LowBalance float64
+	// History is how many recent charges are kept per user.
+	History int
+	Buffer  int
+}
+
+// Charge is one completed render billed to a user.
+type Charge struct {
+	JobID     string          `json:"job_id"`
+	Credits   int64           `json:"credits"`
+	Cost      json.RawMessage `json:"cost,omitempty"`
+	ChargedAt time.Time       `json:"charged_at"`
+}
+
+// Ledger records credit consumption from hub events. Charges are applied by a
+// background worker so Observe never blocks the event source.
+type Ledger struct {
+	store  usage.Store
+	quotas usage.Quotas
+	hub    *events.Hub
+	cfg    Config
+	log    *slog.Logger
+	queue  chan charge
+	done   chan struct{}
+	once   sync.Once
+
+	mu        sync.Mutex
+	recent    map[string][]Charge
+	charged   map[string]time.Time
+	lastSweep time.Time
+}
+
+type charge struct {
+	userID string
+	plan   string
+	Charge
+}
+
+func NewLedger(store usage.Store, quotas usage.Quotas, hub *events.Hub, cfg Config, log *slog.Logger) *Ledger {
+	if cfg.StagePath == "" {
+		cfg.StagePath = "job.stage"
+	}
+	if len(cfg.ReadyStages) == 0 {
+		cfg.ReadyStages = []string{"ready"}
+	}
+	if cfg.CostPath == "" {
+		cfg.CostPath = "job.cost"
+	}
+	if cfg.CreditsPath == "" {
+		cfg.CreditsPath = "job.cost.credits"
+	}
+	if cfg.PlanPath == "" {
+		cfg.PlanPath = "job.plan"
+	}
+	if cfg.LowBalance <= 0 {
+		cfg.LowBalance = 0.1
+	}
+	if cfg.History <= 0 {
+		cfg.History = 20
+	}
+	if cfg.Buffer <= 0 {
+		cfg.Buffer = defaultBuffer
+	}
+	l := &Ledger{
+		store:   store,
+		quotas:  quotas,
+		hub:     hub,
+		cfg:     cfg,
+		log:     log,
+		queue:   make(chan charge, cfg.Buffer),
+		done:    make(chan struct{}),
+		recent:  make(map[string][]Charge),
+		charged: make(map[string]time.Time),
+	}
+	go l.run()
+	return l
+}
+
+// Observe is an events.Listener. Ready events carrying a positive cost are
+// queued for charging; replays of an already charged job are ignored.
+func (l *Ledger) Observe(jobID, userID string, payload []byte) {
+	if userID == "" {
+		return
+	}
+	var doc any
+	if err := json.Unmarshal(payload, &doc); err != nil {
+		return
+	}
+	stage, _ := jsonpath.StringAt(doc, l.cfg.StagePath)
+	if !l.ready(stage) {
+		return
+	}
+	raw, ok := jsonpath.Lookup(doc, l.cfg.CreditsPath)
+	if !ok {
+		return
+	}
+	amount, ok := raw.(float64)
+	if !ok || amount <= 0 {
+		return
+	}
+	if !l.markCharged(jobID, time.Now()) {
+		return
+	}
+	c := charge{userID: userID, Charge: Charge{JobID: jobID, Credits: int64(amount + 0.5), ChargedAt: time.Now().UTC()}}
+	c.plan, _ = jsonpath.StringAt(doc, l.cfg.PlanPath)
+	if cost, ok := jsonpath.Lookup(doc, l.cfg.CostPath); ok {
+		c.Cost, _ = json.Marshal(cost)
+	}
+	select {
+	case l.queue <- c:
+	default:
+		metrics.Credits.Add("dropped", 1)
+		l.log.Warn("credits charge dropped, queue full", slog.String("job_id", jobID), slog.String("user_id", userID))
+	}
+}
+
+// Recent returns the latest charges of a user, newest first.
+func (l *Ledger) Recent(userID string) []Charge {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	charges := l.recent[userID]
+	out := make([]Charge, len(charges))
+	for i, c := range charges {
+		out[len(charges)-1-i] = c
+	}
+	return out
+}
+
+// Close applies the queued charges. Observe must not be called afterwards.
+func (l *Ledger) Close() {
+	l.once.Do(func() { close(l.queue) })
+	<-l.done
+}
+
+func (l *Ledger) run() {
+	defer close(l.done)
+	for c := range l.queue {
+		l.apply(c)
+	}
+}
+
+func (l *Ledger) apply(c charge) {
+	ctx, cancel := context.WithTimeout(context.Background(), chargeTimeout)
+	defer cancel()
+	period := usage.Period(c.ChargedAt)
+	used, err := l.store.Add(ctx, c.userID, period, usage.Credits, c.Credits)
+	if err != nil {
+		metrics.Credits.Add("errors", 1)
+		l.unmarkCharged(c.JobID)
+		l.log.Error("credits charge failed", slog.String("job_id", c.JobID), slog.String("user_id", c.userID), slog.String("err", err.Error()))
+		return
+	}
+	metrics.Credits.Add("charged", c.Credits)
+	l.remember(c.userID, c.Charge)
+
+	plan := c.plan
+	if plan == "" {
+		plan = l.quotas.DefaultPlan
+	}
+	allowance := l.quotas.Limit(plan, usage.Credits)
+	if allowance <= 0 {
+		return
+	}
+	before, after := allowance-(used-c.Credits), allowance-used
+	threshold := int64(float64(allowance) * l.cfg.LowBalance)
+	switch {
+	case before > 0 && after <= 0:
+		l.warn(c.userID, EventExhausted, plan, allowance, used, threshold)
+	case before > threshold && after <= threshold:
+		l.warn(c.userID, EventLowBalance, plan, allowance, used, threshold)
+	}
+}
+
+func (l *Ledger) warn(userID, event, plan string, allowance, used, threshold int64) {
+	if l.hub == nil {
+		return
+	}
+	payload, err := json.Marshal(map[string]any{
+		"type":      event,
+		"user_id":   userID,
+		"plan":      plan,
+		"allowance": allowance,
+		"used":      used,
+		"remaining": max(allowance-used, 0),
+		"threshold": threshold,
+		"resets_at": usage.PeriodEnd(time.Now()).Format(time.RFC3339),
+	})
+	if err != nil {
+		return
+	}
+	metrics.Credits.Add("warnings", 1)
+	l.hub.PublishUser(userID, payload)
+}
+
+func (l *Ledger) ready(stage string) bool {
+	for _, s := range l.cfg.ReadyStages {
+		if s == stage {
+			return true
+		}
+	}
+	return false
+}
+
+// markCharged reports whether jobID hasn't been charged recently and marks
+// it. Kafka delivers at least once, so the same ready event can arrive twice.
+func (l *Ledger) markCharged(jobID string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.charged[jobID]; ok {
+		return false
+	}
+	if now.Sub(l.lastSweep) > time.Hour {
+		for id, at := range l.charged {
+			if now.Sub(at) > chargedJobsTTL {
+				delete(l.charged, id)
+			}
+		}
+		l.lastSweep = now
+	}
+	l.charged[jobID] = now
+	return true
+}
+
+func (l *Ledger) unmarkCharged(jobID string) {
+	l.mu.Lock()
+	delete(l.charged, jobID)
+	l.mu.Unlock()
+}
+
+func (l *Ledger) remember(userID string, c Charge) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	charges := append(l.recent[userID], c)
+	if len(charges) > l.cfg.History {
+		charges = charges[len(charges)-l.cfg.History:]
+	}
+	l.recent[userID] = charges
+}
@@ -0,0 +1,217 @@
+// Package errreport sends server-side errors (handler panics and failed
+// upstream calls) to Sentry, so production failures surface outside of the
+// gateway log.
+package errreport
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/immxrtalbeast/api-gateway/internal/metrics"
+)
+
+const (
+	LevelError = "error"
+	LevelFatal = "fatal"
+
+	defaultBuffer = 256
+	sendTimeout   = 10 * time.Second
+)
+
+type Event struct {
+	Level   string
+	Message string
+	// Stack is the goroutine stack of a panic.
+	Stack     string
+	Method    string
+	Path      string
+	Route     string
+	Status    int
+	RequestID string
+	UserID    string
+	Time      time.Time
+}
+
+type Config struct {
+	// DSN is the Sentry project DSN, https://<key>@<host>/<project id>.
+	DSN         string
+	Environment string
+	Release     string
+	Timeout     time.Duration
+	Buffer      int
+}
+
+// Reporter queues events and sends them in the background; events are
+// dropped while the queue is full so an error storm can't slow requests.
+type Reporter struct {
+	endpoint string
+	auth     string
+	cfg      Config
+	server   string
+	http     *http.Client
+	log      *slog.Logger
+	events   chan Event
+	wg       sync.WaitGroup
+	once     sync.Once
+}
+
+func New(cfg Config, transport http.RoundTripper, log *slog.Logger) (*Reporter, error) {
+	endpoint, key, err := parseDSN(cfg.DSN)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.Buffer <= 0 {
+		cfg.Buffer = defaultBuffer
+	}
+	server, _ := os.Hostname()
+	r := &Reporter{
+		endpoint: endpoint,
+		auth:     "Sentry sentry_version=7, sentry_client=api-gateway/1.0, sentry_key=" + key,
+		cfg:      cfg,
+		server:   server,
+		http:     &http.Client{Timeout: cfg.Timeout, Transport: transport},
+		log:      log,
+		events:   make(chan Event, cfg.Buffer),
+	}
+	r.wg.Add(1)
+	go r.run()
+	return r, nil
+}
+
+// parseDSN returns the envelope endpoint and public key of a DSN.
+func parseDSN(dsn string) (string, string, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid sentry dsn: %w", err)
+	}
+	if u.User == nil || u.User.Username() == "" || u.Host == "" {
+		return "", "", fmt.Errorf("invalid sentry dsn: key and host are required")
+	}
+	path := strings.Trim(u.Path, "/")
+	project := path[strings.LastIndex(path, "/")+1:]
+	if project == "" {
+		return "", "", fmt.Errorf("invalid sentry dsn: project id is required")
+	}
+	prefix := strings.TrimSuffix(path, project)
+	return u.Scheme + "://" + u.Host + "/" + prefix + "api/" + project + "/envelope/", u.User.Username(), nil
+}
+
+// Report queues ev. A nil reporter drops it.
+func (r *Reporter) Report(ev Event) {
+	if r == nil {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+	if ev.Level == "" {
+		ev.Level = LevelError
+	}
+	select {
+	case r.events <- ev:
+	default:
+		metrics.ErrorReports.Add("dropped", 1)
+	}
+}
+
+// Close sends the queued events. Report must not be called afterwards.
+func (r *Reporter) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.once.Do(func() { close(r.events) })
+	r.wg.Wait()
+	r.http.CloseIdleConnections()
+	return nil
+}
+
+func (r *Reporter) run() {
+	defer r.wg.Done()
+	for ev := range r.events {
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		err := r.send(ctx, ev)
+		cancel()
+		if err != nil {
+			metrics.ErrorReports.Add("send_errors", 1)
+			r.log.Warn("error report not sent", slog.String("request_id", ev.RequestID), slog.String("err", err.Error()))
+			continue
+		}
+		metrics.ErrorReports.Add("reported", 1)
+	}
+}
+
+func (r *Reporter) send(ctx context.Context, ev Event) error {
+	id := make([]byte, 16)
+	rand.Read(id)
+	eventID := hex.EncodeToString(id)
+
+	payload := map[string]any{
+		"event_id":    eventID,
+		"timestamp":   ev.Time.Format(time.RFC3339Nano),
+		"platform":    "go",
+		"level":       ev.Level,
+		"logger":      "api-gateway",
+		"server_name": r.server,
+		"transaction": ev.Route,
+		"message":     map[string]string{"formatted": ev.Message},
+		"tags": map[string]string{
+			"route":      ev.Route,
+			"request_id": ev.RequestID,
+			"status":     fmt.Sprint(ev.Status),
+		},
+		"request": map[string]string{"method": ev.Method, "url": ev.Path},
+	}
+	if r.cfg.Environment != "" {
+		payload["environment"] = r.cfg.Environment
+	}
+	if r.cfg.Release != "" {
+		payload["release"] = r.cfg.Release
+	}
+	if ev.UserID != "" {
+		payload["user"] = map[string]string{"id": ev.UserID}
+	}
+	if ev.Stack != "" {
+		payload["extra"] = map[string]string{"stack": ev.Stack}
+	}
+	event, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	header, _ := json.Marshal(map[string]string{"event_id": eventID, "sent_at": time.Now().UTC().Format(time.RFC3339Nano)})
+	body.Write(header)
+	body.WriteString("\n{\"type\":\"event\"}\n")
+	body.Write(event)
+	body.WriteByte('\n')
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.endpoint, &body)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", r.auth)
+	resp, err := r.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("sentry request failed: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("sentry returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -1,29 +1,131 @@
 package events
 
-import "sync"
+import (
+	"encoding/json"
+	"sync"
+	"time"
 
-// Hub keeps per-job websocket subscribers and fan-outs updates from Kafka.
+	"github.com/immxrtalbeast/api-gateway/lib/jsonpath"
+)
+
+const (
+	defaultJobIDPath  = "job.id"
+	defaultUserIDPath = "job.user_id"
+)
+
+// Hub keeps per-job and per-user websocket subscribers and fan-outs updates
+// from Kafka. The last ReplaySize updates of every job are buffered for
+// ReplayTTL and replayed to new job subscribers so late joiners don't miss
+// intermediate stages.
 type Hub struct {
 	mu          sync.RWMutex
 	subscribers map[string]map[chan []byte]struct{}
+	users       map[string]map[chan []byte]struct{}
+	buffers     map[string]*replayBuffer
+	last        map[string]bufferedEvent
+	replaySize  int
+	replayTTL   time.Duration
+	lastSweep   time.Time
+	jobIDPath   string
+	userIDPath  string
+	listeners   []Listener
+}
+
+// Listener observes every update routed by Dispatch. It runs synchronously on
+// the source goroutine and must not block.
+type Listener func(jobID, userID string, payload []byte)
+
+type HubConfig struct {
+	ReplaySize int
+	ReplayTTL  time.Duration
+	// JobIDPath is the dot-separated location of the job ID inside update
+	// payloads, "job.id" by default.
+	JobIDPath string
+	// UserIDPath locates the owner of the job, "job.user_id" by default.
+	UserIDPath string
+}
+
+type bufferedEvent struct {
+	payload []byte
+	at      time.Time
+}
+
+// replayBuffer is a fixed-size ring of the most recent job updates.
+type replayBuffer struct {
+	events []bufferedEvent
+	next   int
+	full   bool
 }
 
-func NewHub() *Hub {
+func NewHub(cfg HubConfig) *Hub {
+	if cfg.ReplayTTL <= 0 {
+		cfg.ReplayTTL = 10 * time.Minute
+	}
+	if cfg.JobIDPath == "" {
+		cfg.JobIDPath = defaultJobIDPath
+	}
+	if cfg.UserIDPath == "" {
+		cfg.UserIDPath = defaultUserIDPath
+	}
 	return &Hub{
 		subscribers: make(map[string]map[chan []byte]struct{}),
+		users:       make(map[string]map[chan []byte]struct{}),
+		buffers:     make(map[string]*replayBuffer),
+		last:        make(map[string]bufferedEvent),
+		replaySize:  cfg.ReplaySize,
+		replayTTL:   cfg.ReplayTTL,
+		lastSweep:   time.Now(),
+		jobIDPath:   cfg.JobIDPath,
+		userIDPath:  cfg.UserIDPath,
+	}
+}
+
+// Listen registers l for every dispatched update. It must be called before
+// the source starts.
+func (h *Hub) Listen(l Listener) {
+	h.listeners = append(h.listeners, l)
+}
+
+// Owned runs l only for the jobs owns reports as this replica's, so that side
+// effects happen once when every replica consumes every update.
+func Owned(owns func(jobID string) bool, l Listener) Listener {
+	return func(jobID, userID string, payload []byte) {
+		if owns(jobID) {
+			l(jobID, userID, payload)
+		}
 	}
 }
 
 func (h *Hub) Subscribe(jobID string) (<-chan []byte, func()) {
-	ch := make(chan []byte, 8)
 	h.mu.Lock()
+	replay := h.replayLocked(jobID, time.Now())
+	ch := make(chan []byte, 8+len(replay))
+	for _, payload := range replay {
+		ch <- payload
+	}
+	cancel := h.addLocked(jobID, ch)
+	h.mu.Unlock()
+	return ch, cancel
+}
+
+// SubscribeReplay is Subscribe returning the buffered updates apart instead
+// of queueing them, for callers that must send them before a snapshot of
+// the job: they are all older than any snapshot taken afterwards.
+func (h *Hub) SubscribeReplay(jobID string) ([][]byte, <-chan []byte, func()) {
+	h.mu.Lock()
+	replay := h.replayLocked(jobID, time.Now())
+	ch := make(chan []byte, 8)
+	cancel := h.addLocked(jobID, ch)
+	h.mu.Unlock()
+	return replay, ch, cancel
+}
+
+func (h *Hub) addLocked(jobID string, ch chan []byte) func() {
 	if _, ok := h.subscribers[jobID]; !ok {
 		h.subscribers[jobID] = make(map[chan []byte]struct{})
 	}
 	h.subscribers[jobID][ch] = struct{}{}
-	h.mu.Unlock()
-
-	cancel := func() {
+	return func() {
 		h.mu.Lock()
 		if subs, ok := h.subscribers[jobID]; ok {
 			if _, exists := subs[ch]; exists {
@@ -35,18 +137,52 @@ func (h *Hub) Subscribe(jobID string) (<-chan []byte, func()) {
 		}
 		h.mu.Unlock()
 	}
+}
+
+// SubscribeUser streams updates of every job owned by userID.
+func (h *Hub) SubscribeUser(userID string) (<-chan []byte, func()) {
+	ch := make(chan []byte, 32)
+	h.mu.Lock()
+	if _, ok := h.users[userID]; !ok {
+		h.users[userID] = make(map[chan []byte]struct{})
+	}
+	h.users[userID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	cancel := func() {
+		h.mu.Lock()
+		if subs, ok := h.users[userID]; ok {
+			delete(subs, ch)
+			if len(subs) == 0 {
+				delete(h.users, userID)
+			}
+		}
+		h.mu.Unlock()
+	}
 
 	return ch, cancel
 }
 
 func (h *Hub) Publish(jobID string, payload []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	h.last[jobID] = bufferedEvent{payload: payload, at: now}
+	h.bufferLocked(jobID, payload, now)
+	h.sweepLocked(now)
+
+	fanOut(h.subscribers[jobID], payload)
+}
+
+// PublishUser delivers an update to the user-wide subscribers only.
+func (h *Hub) PublishUser(userID string, payload []byte) {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
+	fanOut(h.users[userID], payload)
+}
 
-	subs, ok := h.subscribers[jobID]
-	if !ok {
-		return
-	}
+func fanOut(subs map[chan []byte]struct{}, payload []byte) {
 	for ch := range subs {
 		select {
 		case ch <- payload:
@@ -54,3 +190,108 @@ func (h *Hub) Publish(jobID string, payload []byte) {
 		}
 	}
 }
+
+// SubscriberCount returns the number of live subscribers of a job.
+func (h *Hub) SubscriberCount(jobID string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subscribers[jobID])
+}
+
+// LastEvent returns the most recent update seen for a job within ReplayTTL.
+func (h *Hub) LastEvent(jobID string) ([]byte, time.Time, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	ev, ok := h.last[jobID]
+	if !ok || h.expired(ev, time.Now()) {
+		return nil, time.Time{}, false
+	}
+	return ev.payload, ev.at, true
+}
+
+// Dispatch routes a raw update from a Source to the subscribers of its job
+// and of the job owner. Payloads without a job ID are dropped.
+func (h *Hub) Dispatch(payload []byte) bool {
+	var doc any
+	if err := json.Unmarshal(payload, &doc); err != nil {
+		return false
+	}
+	jobID, err := jsonpath.StringAt(doc, h.jobIDPath)
+	if err != nil || jobID == "" {
+		return false
+	}
+	h.Publish(jobID, payload)
+	userID, err := jsonpath.StringAt(doc, h.userIDPath)
+	if err != nil {
+		userID = ""
+	}
+	if userID != "" {
+		h.PublishUser(userID, payload)
+	}
+	for _, l := range h.listeners {
+		l(jobID, userID, payload)
+	}
+	return true
+}
+
+func (h *Hub) bufferLocked(jobID string, payload []byte, now time.Time) {
+	if h.replaySize <= 0 {
+		return
+	}
+	buf, ok := h.buffers[jobID]
+	if !ok {
+		buf = &replayBuffer{events: make([]bufferedEvent, h.replaySize)}
+		h.buffers[jobID] = buf
+	}
+	buf.events[buf.next] = bufferedEvent{payload: payload, at: now}
+	buf.next = (buf.next + 1) % len(buf.events)
+	if buf.next == 0 {
+		buf.full = true
+	}
+}
+
+// replayLocked returns the buffered, non-expired updates of a job in
+// publish order.
+func (h *Hub) replayLocked(jobID string, now time.Time) [][]byte {
+	buf, ok := h.buffers[jobID]
+	if !ok {
+		return nil
+	}
+	start, count := 0, buf.next
+	if buf.full {
+		start, count = buf.next, len(buf.events)
+	}
+	res := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		ev := buf.events[(start+i)%len(buf.events)]
+		if h.expired(ev, now) {
+			continue
+		}
+		res = append(res, ev.payload)
+	}
+	return res
+}
+
+// sweepLocked drops buffers and last events that have expired. It runs at most
+// once per ReplayTTL to keep Publish cheap.
+func (h *Hub) sweepLocked(now time.Time) {
+	if now.Sub(h.lastSweep) < h.replayTTL {
+		return
+	}
+	h.lastSweep = now
+	for jobID, ev := range h.last {
+		if h.expired(ev, now) {
+			delete(h.last, jobID)
+		}
+	}
+	for jobID, buf := range h.buffers {
+		last := (buf.next - 1 + len(buf.events)) % len(buf.events)
+		if h.expired(buf.events[last], now) {
+			delete(h.buffers, jobID)
+		}
+	}
+}
+
+func (h *Hub) expired(ev bufferedEvent, now time.Time) bool {
+	return h.replayTTL > 0 && now.Sub(ev.at) > h.replayTTL
+}
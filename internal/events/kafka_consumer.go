@@ -2,19 +2,36 @@ package events
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/tls"
 	"errors"
+	"expvar"
 	"fmt"
 	"log/slog"
+	"net"
+	"strconv"
+	"sync/atomic"
 	"time"
 
+	"github.com/immxrtalbeast/api-gateway/internal/metrics"
 	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+)
+
+var _ Source = (*KafkaConsumer)(nil)
+
+const (
+	StartOffsetFirst = "first"
+	StartOffsetLast  = "last"
 )
 
 type KafkaConsumer struct {
-	reader *kafka.Reader
-	hub    *Hub
-	log    *slog.Logger
+	reader       *kafka.Reader
+	dlq          *kafka.Writer
+	hub          *Hub
+	log          *slog.Logger
+	manualCommit bool
+	lag          atomic.Int64
+	metrics      *expvar.Map
 }
 
 type KafkaConsumerConfig struct {
@@ -22,6 +39,24 @@ type KafkaConsumerConfig struct {
 	Topic   string
 	GroupID string
 	MaxWait time.Duration
+	// StartOffset is used when the group has no committed offset yet:
+	// "first" or "last" (default).
+	StartOffset string
+	// ManualCommit commits offsets only after the message was handled
+	// (fanned out or dead-lettered) instead of on read.
+	ManualCommit bool
+	// DeadLetterTopic receives payloads that can't be routed to a job.
+	// Empty disables the DLQ and such payloads are dropped.
+	DeadLetterTopic string
+	// Dial overrides how broker connections are opened, e.g. through an
+	// egress proxy. Nil uses a direct dialer.
+	Dial func(ctx context.Context, network, address string) (net.Conn, error)
+	// SASL and TLS secure the broker connections when set.
+	SASL sasl.Mechanism
+	TLS  *tls.Config
+	// Metrics receives the consumer counters and lag, metrics.KafkaConsumer
+	// by default.
+	Metrics *expvar.Map
 }
 
 func NewKafkaConsumer(cfg KafkaConsumerConfig, hub *Hub, log *slog.Logger) (*KafkaConsumer, error) {
@@ -38,58 +73,165 @@ func NewKafkaConsumer(cfg KafkaConsumerConfig, hub *Hub, log *slog.Logger) (*Kaf
 	if maxWait <= 0 {
 		maxWait = 500 * time.Millisecond
 	}
-	reader := kafka.NewReader(kafka.ReaderConfig{
+	startOffset := kafka.LastOffset
+	switch cfg.StartOffset {
+	case "", StartOffsetLast:
+	case StartOffsetFirst:
+		startOffset = kafka.FirstOffset
+	default:
+		return nil, fmt.Errorf("unknown kafka start offset %q", cfg.StartOffset)
+	}
+	readerCfg := kafka.ReaderConfig{
 		Brokers:     cfg.Brokers,
 		Topic:       cfg.Topic,
 		GroupID:     cfg.GroupID,
-		StartOffset: kafka.LastOffset,
+		StartOffset: startOffset,
 		MaxWait:     maxWait,
-	})
-	return &KafkaConsumer{
-		reader: reader,
-		hub:    hub,
-		log:    log,
-	}, nil
+	}
+	if cfg.Dial != nil || cfg.SASL != nil || cfg.TLS != nil {
+		readerCfg.Dialer = &kafka.Dialer{
+			Timeout:       10 * time.Second,
+			DualStack:     true,
+			DialFunc:      cfg.Dial,
+			SASLMechanism: cfg.SASL,
+			TLS:           cfg.TLS,
+		}
+	}
+	reader := kafka.NewReader(readerCfg)
+	consumer := &KafkaConsumer{
+		reader:       reader,
+		hub:          hub,
+		log:          log,
+		manualCommit: cfg.ManualCommit,
+		metrics:      cfg.Metrics,
+	}
+	if consumer.metrics == nil {
+		consumer.metrics = metrics.KafkaConsumer
+	}
+	if cfg.DeadLetterTopic != "" {
+		consumer.dlq = &kafka.Writer{
+			Addr:                   kafka.TCP(cfg.Brokers...),
+			Topic:                  cfg.DeadLetterTopic,
+			Balancer:               &kafka.LeastBytes{},
+			AllowAutoTopicCreation: true,
+		}
+		if cfg.Dial != nil || cfg.SASL != nil || cfg.TLS != nil {
+			consumer.dlq.Transport = &kafka.Transport{Dial: cfg.Dial, SASL: cfg.SASL, TLS: cfg.TLS}
+		}
+	}
+	consumer.metrics.Set("lag", expvar.Func(func() any { return consumer.Lag() }))
+	return consumer, nil
 }
 
 func (c *KafkaConsumer) Run(ctx context.Context) {
 	go func() {
 		for {
-			msg, err := c.reader.ReadMessage(ctx)
+			msg, err := c.read(ctx)
 			if err != nil {
 				if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
 					return
 				}
+				c.metrics.Add("read_errors", 1)
 				c.log.Warn("kafka read failed", slog.String("err", err.Error()))
 				time.Sleep(500 * time.Millisecond)
 				continue
 			}
-			jobID, ok := extractJobID(msg.Value)
-			if !ok {
-				continue
+			c.metrics.Add("messages", 1)
+			c.lag.Store(msg.HighWaterMark - msg.Offset - 1)
+
+			if c.hub.Dispatch(msg.Value) {
+				c.metrics.Add("dispatched", 1)
+			} else if !c.deadLetterRetrying(ctx, msg) {
+				// Stopped; the offset stays uncommitted.
+				return
 			}
-			c.hub.Publish(jobID, msg.Value)
+			c.commit(ctx, msg)
 		}
 	}()
 }
 
-func (c *KafkaConsumer) Close() error {
-	return c.reader.Close()
+func (c *KafkaConsumer) read(ctx context.Context) (kafka.Message, error) {
+	if c.manualCommit {
+		return c.reader.FetchMessage(ctx)
+	}
+	return c.reader.ReadMessage(ctx)
 }
 
-type jobEnvelope struct {
-	Job struct {
-		ID string `json:"id"`
-	} `json:"job"`
+func (c *KafkaConsumer) commit(ctx context.Context, msg kafka.Message) {
+	if !c.manualCommit {
+		return
+	}
+	if err := c.reader.CommitMessages(ctx, msg); err != nil {
+		c.metrics.Add("commit_errors", 1)
+		c.log.Warn("kafka commit failed", slog.Int64("offset", msg.Offset), slog.String("err", err.Error()))
+	}
+}
+
+// Bounds of the wait between dead letter attempts.
+const (
+	deadLetterInitialBackoff = 500 * time.Millisecond
+	deadLetterMaxBackoff     = 30 * time.Second
+)
+
+// deadLetterRetrying writes msg to the dead letter topic, retrying with
+// backoff until it succeeds, so the consumer never moves past a message it
+// could neither dispatch nor park. It reports false when ctx ended first.
+func (c *KafkaConsumer) deadLetterRetrying(ctx context.Context, msg kafka.Message) bool {
+	wait := deadLetterInitialBackoff
+	for {
+		err := c.deadLetter(ctx, msg)
+		if err == nil {
+			return true
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+		c.metrics.Add("dead_letter_errors", 1)
+		c.log.Warn("kafka dead letter failed",
+			slog.Int64("offset", msg.Offset),
+			slog.Duration("retry_in", wait),
+			slog.String("err", err.Error()),
+		)
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(wait):
+		}
+		wait = min(wait*2, deadLetterMaxBackoff)
+	}
 }
 
-func extractJobID(payload []byte) (string, bool) {
-	var env jobEnvelope
-	if err := json.Unmarshal(payload, &env); err != nil {
-		return "", false
+func (c *KafkaConsumer) deadLetter(ctx context.Context, msg kafka.Message) error {
+	if c.dlq == nil {
+		c.metrics.Add("dropped", 1)
+		return nil
 	}
-	if env.Job.ID == "" {
-		return "", false
+	err := c.dlq.WriteMessages(ctx, kafka.Message{
+		Key:   msg.Key,
+		Value: msg.Value,
+		Headers: append(msg.Headers,
+			kafka.Header{Key: "x-dlq-reason", Value: []byte("unroutable payload")},
+			kafka.Header{Key: "x-dlq-source-topic", Value: []byte(msg.Topic)},
+			kafka.Header{Key: "x-dlq-source-partition", Value: []byte(strconv.Itoa(msg.Partition))},
+			kafka.Header{Key: "x-dlq-source-offset", Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+		),
+	})
+	if err != nil {
+		return err
 	}
-	return env.Job.ID, true
+	c.metrics.Add("dead_lettered", 1)
+	return nil
+}
+
+// Lag is the number of messages behind the partition high watermark as of
+// the last consumed message.
+func (c *KafkaConsumer) Lag() int64 {
+	return c.lag.Load()
+}
+
+func (c *KafkaConsumer) Close() error {
+	if c.dlq != nil {
+		c.dlq.Close()
+	}
+	return c.reader.Close()
 }
@@ -0,0 +1,63 @@
+package events
+
+import "sync"
+
+// Logouts ends the live streams of sessions that were logged out or revoked,
+// so a stream doesn't outlive the credentials it was opened with.
+type Logouts struct {
+	mu      sync.Mutex
+	streams map[string]map[*logoutWatch]struct{}
+}
+
+type logoutWatch struct {
+	sessionID string
+	done      chan struct{}
+}
+
+func NewLogouts() *Logouts {
+	return &Logouts{streams: make(map[string]map[*logoutWatch]struct{})}
+}
+
+// Watch returns a channel closed when the session of a stream ends, and a
+// function to call once the stream is over. An empty sessionID is ended only
+// with all sessions of the user.
+func (l *Logouts) Watch(userID, sessionID string) (<-chan struct{}, func()) {
+	w := &logoutWatch{sessionID: sessionID, done: make(chan struct{})}
+	l.mu.Lock()
+	if _, ok := l.streams[userID]; !ok {
+		l.streams[userID] = make(map[*logoutWatch]struct{})
+	}
+	l.streams[userID][w] = struct{}{}
+	l.mu.Unlock()
+
+	stop := func() {
+		l.mu.Lock()
+		l.removeLocked(userID, w)
+		l.mu.Unlock()
+	}
+	return w.done, stop
+}
+
+// End ends the streams of one session of userID, or of all its sessions when
+// sessionID is empty.
+func (l *Logouts) End(userID, sessionID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for w := range l.streams[userID] {
+		if sessionID == "" || w.sessionID == sessionID {
+			close(w.done)
+			l.removeLocked(userID, w)
+		}
+	}
+}
+
+func (l *Logouts) removeLocked(userID string, w *logoutWatch) {
+	watches, ok := l.streams[userID]
+	if !ok {
+		return
+	}
+	delete(watches, w)
+	if len(watches) == 0 {
+		delete(l.streams, userID)
+	}
+}
@@ -0,0 +1,92 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+var _ Source = (*NATSSource)(nil)
+
+// NATSSource consumes job updates from a NATS JetStream stream.
+type NATSSource struct {
+	conn     *nats.Conn
+	consumer jetstream.Consumer
+	hub      *Hub
+	log      *slog.Logger
+	consume  jetstream.ConsumeContext
+}
+
+type NATSSourceConfig struct {
+	URL     string
+	Stream  string
+	Subject string
+	Durable string
+}
+
+func NewNATSSource(cfg NATSSourceConfig, hub *Hub, log *slog.Logger) (*NATSSource, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("nats url is required")
+	}
+	if cfg.Stream == "" {
+		return nil, fmt.Errorf("nats stream is required")
+	}
+	conn, err := nats.Connect(cfg.URL, nats.Name("api-gateway"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, fmt.Errorf("connect nats: %w", err)
+	}
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("init jetstream: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	consumer, err := js.CreateOrUpdateConsumer(ctx, cfg.Stream, jetstream.ConsumerConfig{
+		Durable:       cfg.Durable,
+		FilterSubject: cfg.Subject,
+		DeliverPolicy: jetstream.DeliverNewPolicy,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+	})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("create jetstream consumer: %w", err)
+	}
+	return &NATSSource{
+		conn:     conn,
+		consumer: consumer,
+		hub:      hub,
+		log:      log,
+	}, nil
+}
+
+func (s *NATSSource) Run(ctx context.Context) {
+	consume, err := s.consumer.Consume(func(msg jetstream.Msg) {
+		s.hub.Dispatch(msg.Data())
+		if err := msg.Ack(); err != nil {
+			s.log.Warn("nats ack failed", slog.String("err", err.Error()))
+		}
+	}, jetstream.ConsumeErrHandler(func(_ jetstream.ConsumeContext, err error) {
+		s.log.Warn("nats consume failed", slog.String("err", err.Error()))
+	}))
+	if err != nil {
+		s.log.Error("failed to start nats consumer", slog.String("err", err.Error()))
+		return
+	}
+	s.consume = consume
+	go func() {
+		<-ctx.Done()
+		consume.Stop()
+	}()
+}
+
+func (s *NATSSource) Close() error {
+	if s.consume != nil {
+		s.consume.Stop()
+	}
+	return s.conn.Drain()
+}
@@ -0,0 +1,73 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var _ Source = (*RedisSource)(nil)
+
+// RedisSource consumes job updates from a Redis Pub/Sub channel. Pub/Sub is
+// fire-and-forget, so updates published while the gateway is down are lost.
+type RedisSource struct {
+	client  *redis.Client
+	channel string
+	hub     *Hub
+	log     *slog.Logger
+	pubsub  *redis.PubSub
+}
+
+type RedisSourceConfig struct {
+	Addr     string
+	Password string
+	DB       int
+	Channel  string
+}
+
+func NewRedisSource(cfg RedisSourceConfig, hub *Hub, log *slog.Logger) (*RedisSource, error) {
+	if cfg.Addr == "" {
+		return nil, fmt.Errorf("redis addr is required")
+	}
+	if cfg.Channel == "" {
+		return nil, fmt.Errorf("redis channel is required")
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+	return &RedisSource{
+		client:  client,
+		channel: cfg.Channel,
+		hub:     hub,
+		log:     log,
+	}, nil
+}
+
+func (s *RedisSource) Run(ctx context.Context) {
+	s.pubsub = s.client.Subscribe(ctx, s.channel)
+	messages := s.pubsub.Channel()
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				s.hub.Dispatch([]byte(msg.Payload))
+			}
+		}
+	}()
+}
+
+func (s *RedisSource) Close() error {
+	if s.pubsub != nil {
+		s.pubsub.Close()
+	}
+	return s.client.Close()
+}
@@ -0,0 +1,10 @@
+package events
+
+import "context"
+
+// Source is a stream of job update payloads that feeds the Hub. Run starts
+// consuming in the background and returns immediately.
+type Source interface {
+	Run(ctx context.Context)
+	Close() error
+}
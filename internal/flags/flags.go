@@ -0,0 +1,206 @@
+// Package flags decides which feature flags are on for a user. Flags are
+// defined in the config with targeting rules; an OpenFeature-compatible
+// provider, when configured, decides instead for the flags it knows, and the
+// rules remain the fallback while it is unreachable.
+package flags
+
+import (
+	"context"
+	"hash/fnv"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/immxrtalbeast/api-gateway/internal/reload"
+)
+
+// Flag is on for Users, for users on Plans and for Percentage percent of the
+// other users, picked by a hash of the flag name and user ID so each user
+// keeps their answer. Enabled turns it on for everyone. Routes ("POST
+// /api/ideas/expand") are refused to users the flag is off for.
+type Flag struct {
+	Name        string
+	Description string
+	Enabled     bool
+	Percentage  float64
+	Users       []string
+	Plans       []string
+	Routes      []string
+}
+
+// Target is who flags are evaluated for.
+type Target struct {
+	UserID string
+	Plan   string
+	OrgID  string
+}
+
+// Provider evaluates flags remotely; a flag missing from its answer falls
+// back to the config rules.
+type Provider interface {
+	Booleans(ctx context.Context, evalCtx map[string]any) (map[string]bool, error)
+}
+
+type Options struct {
+	// Timeout bounds a provider call.
+	Timeout time.Duration
+	// CacheTTL keeps the provider's answers per user; the last answer is
+	// reused while the provider is down.
+	CacheTTL time.Duration
+}
+
+// Set holds the flag definitions and evaluates them.
+type Set struct {
+	flags    reload.Value[[]Flag]
+	provider Provider
+	opts     Options
+	log      *slog.Logger
+
+	mu    sync.Mutex
+	cache map[string]cachedValues
+}
+
+type cachedValues struct {
+	values  map[string]bool
+	expires time.Time
+}
+
+// New evaluates flags by their rules alone when provider is nil.
+func New(flags []Flag, provider Provider, opts Options, log *slog.Logger) *Set {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 500 * time.Millisecond
+	}
+	s := &Set{provider: provider, opts: opts, log: log, cache: make(map[string]cachedValues)}
+	s.Set(flags)
+	return s
+}
+
+// Set replaces the flag definitions.
+func (s *Set) Set(flags []Flag) {
+	defined := make([]Flag, 0, len(flags))
+	for _, flag := range flags {
+		if flag.Name = strings.TrimSpace(flag.Name); flag.Name != "" {
+			defined = append(defined, flag)
+		}
+	}
+	s.flags.Store(defined)
+}
+
+// Evaluate returns the value of every defined flag for target.
+func (s *Set) Evaluate(ctx context.Context, target Target) map[string]bool {
+	flags := s.flags.Load()
+	values := make(map[string]bool, len(flags))
+	remote := s.remote(ctx, target)
+	for _, flag := range flags {
+		if value, ok := remote[flag.Name]; ok {
+			values[flag.Name] = value
+			continue
+		}
+		values[flag.Name] = flag.on(target)
+	}
+	return values
+}
+
+// Names lists the defined flags.
+func (s *Set) Names() []string {
+	flags := s.flags.Load()
+	names := make([]string, len(flags))
+	for i, flag := range flags {
+		names[i] = flag.Name
+	}
+	return names
+}
+
+// Gates lists the flags the route template route requires for method.
+func (s *Set) Gates(method, route string) []string {
+	var names []string
+	for _, flag := range s.flags.Load() {
+		for _, rule := range flag.Routes {
+			ruleMethod, rulePath, ok := strings.Cut(strings.TrimSpace(rule), " ")
+			if !ok {
+				ruleMethod, rulePath = "", ruleMethod
+			}
+			if (ruleMethod == "" || strings.EqualFold(ruleMethod, method)) && strings.TrimSpace(rulePath) == route {
+				names = append(names, flag.Name)
+				break
+			}
+		}
+	}
+	return names
+}
+
+func (s *Set) remote(ctx context.Context, target Target) map[string]bool {
+	if s.provider == nil || target.UserID == "" {
+		return nil
+	}
+	now := time.Now()
+	s.mu.Lock()
+	cached, ok := s.cache[target.UserID]
+	s.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.values
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.opts.Timeout)
+	defer cancel()
+	evalCtx := map[string]any{"targetingKey": target.UserID}
+	if target.Plan != "" {
+		evalCtx["plan"] = target.Plan
+	}
+	if target.OrgID != "" {
+		evalCtx["org_id"] = target.OrgID
+	}
+	values, err := s.provider.Booleans(ctx, evalCtx)
+	if err != nil {
+		s.log.Warn("evaluate feature flags failed", slog.String("user_id", target.UserID), slog.String("err", err.Error()))
+		if ok {
+			return cached.values
+		}
+		return nil
+	}
+	s.mu.Lock()
+	if len(s.cache) >= 10000 {
+		for userID, cached := range s.cache {
+			if now.Sub(cached.expires) > time.Hour {
+				delete(s.cache, userID)
+			}
+		}
+	}
+	s.cache[target.UserID] = cachedValues{values: values, expires: now.Add(s.opts.CacheTTL)}
+	s.mu.Unlock()
+	return values
+}
+
+func (f Flag) on(target Target) bool {
+	if f.Enabled {
+		return true
+	}
+	if target.UserID == "" {
+		return false
+	}
+	if slices.Contains(f.Users, target.UserID) || (target.Plan != "" && slices.Contains(f.Plans, target.Plan)) {
+		return true
+	}
+	if f.Percentage <= 0 {
+		return false
+	}
+	h := fnv.New32a()
+	h.Write([]byte(f.Name + ":" + target.UserID))
+	return float64(h.Sum32()%10000) < f.Percentage*100
+}
+
+type contextKey struct{}
+
+// NewContext attaches the flag values of the request's user to ctx.
+func NewContext(ctx context.Context, values map[string]bool) context.Context {
+	return context.WithValue(ctx, contextKey{}, values)
+}
+
+// Enabled reports whether flag is on for the request's user; it is off for
+// requests not seen by the feature flag middleware.
+func Enabled(ctx context.Context, flag string) bool {
+	values, _ := ctx.Value(contextKey{}).(map[string]bool)
+	return values[flag]
+}
@@ -0,0 +1,367 @@
+// Package graphql executes GraphQL queries over resolvers returning decoded
+// JSON. It implements the part of the language a read-only aggregation
+// endpoint needs: queries with aliases, arguments, variables and the @skip
+// and @include directives. Fragments, mutations, subscriptions and
+// introspection are refused. Below the root, objects are the upstreams' own
+// JSON and are not typed: a field selects the member of the same name.
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Resolver resolves a root field from its arguments. It returns decoded
+// JSON: maps, slices, strings, json.Number, bools or nil.
+type Resolver func(ctx context.Context, args map[string]any) (any, error)
+
+// Schema holds the root query fields by name.
+type Schema struct {
+	Query map[string]Resolver
+	// MaxDepth bounds the nesting of selections; 0 means no bound.
+	MaxDepth int
+	// MaxRootFields bounds the root fields of a query, each alias counting
+	// as a field; 0 means no bound.
+	MaxRootFields int
+}
+
+// Request is a GraphQL-over-HTTP request.
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// Response is the result of a request. Data is nil when the request could not
+// be executed at all; otherwise failed fields are null and reported in
+// Errors.
+type Response struct {
+	Data   *Object `json:"data,omitempty"`
+	Errors []Error `json:"errors,omitempty"`
+}
+
+// Error is a GraphQL error. Resolvers may return an *Error to set
+// Extensions; other errors are reported with their message.
+type Error struct {
+	Message    string         `json:"message"`
+	Locations  []Location     `json:"locations,omitempty"`
+	Path       []any          `json:"path,omitempty"`
+	Extensions map[string]any `json:"extensions,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Object is a JSON object keeping its members in the order of the query.
+type Object struct {
+	keys   []string
+	values map[string]any
+}
+
+func newObject() *Object {
+	return &Object{values: map[string]any{}}
+}
+
+func (o *Object) set(key string, value any) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+func (o *Object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		buf.Write(name)
+		buf.WriteByte(':')
+		value, err := json.Marshal(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Execute runs req against schema. The root fields are resolved
+// concurrently; a failing resolver fails only its field. Root fields with the
+// same name and arguments, under different aliases, share one resolver call.
+func Execute(ctx context.Context, schema *Schema, req Request) Response {
+	doc, err := parse(req.Query)
+	if err != nil {
+		return failed(err)
+	}
+	op, err := pickOperation(doc, req.OperationName)
+	if err != nil {
+		return failed(err)
+	}
+	vars, err := coerceVariables(req.Query, op, req.Variables)
+	if err != nil {
+		return failed(err)
+	}
+	if err := validate(req.Query, schema, op.selections, 1); err != nil {
+		return failed(err)
+	}
+	e := &executor{src: req.Query, vars: vars}
+	fields, err := e.included(op.selections)
+	if err != nil {
+		return failed(err)
+	}
+
+	results := make([]any, len(fields))
+	fieldErrors := make([][]Error, len(fields))
+	var calls []*call
+	byKey := map[string]*call{}
+	for i, f := range fields {
+		if f.name == "__typename" {
+			results[i] = "Query"
+			continue
+		}
+		args := asArgs(e.resolveValue(f.args))
+		key, err := callKey(f.name, args)
+		if err != nil {
+			// Not shareable; resolved on its own.
+			key = fmt.Sprintf("#%d", i)
+		}
+		c, ok := byKey[key]
+		if !ok {
+			c = &call{resolve: schema.Query[f.name], args: args}
+			byKey[key] = c
+			calls = append(calls, c)
+		}
+		c.fields = append(c.fields, i)
+	}
+	var wg sync.WaitGroup
+	for _, c := range calls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := c.resolve(ctx, c.args)
+			for _, i := range c.fields {
+				f := fields[i]
+				path := []any{f.key()}
+				if err != nil {
+					fieldErrors[i] = []Error{e.fieldError(f, path, err)}
+					continue
+				}
+				results[i], fieldErrors[i] = e.complete(f, value, path)
+			}
+		}()
+	}
+	wg.Wait()
+
+	resp := Response{Data: newObject()}
+	for i, f := range fields {
+		resp.Data.set(f.key(), results[i])
+		resp.Errors = append(resp.Errors, fieldErrors[i]...)
+	}
+	return resp
+}
+
+// call is one resolver call and the root fields, by index, sharing it.
+type call struct {
+	resolve Resolver
+	args    map[string]any
+	fields  []int
+}
+
+// callKey identifies a resolver call by field name and arguments. Maps
+// marshal with sorted keys, so argument order doesn't matter.
+func callKey(name string, args map[string]any) (string, error) {
+	raw, err := json.Marshal(args)
+	if err != nil {
+		return "", err
+	}
+	return name + string(raw), nil
+}
+
+func failed(err error) Response {
+	var gqlErr *Error
+	if errors.As(err, &gqlErr) {
+		return Response{Errors: []Error{*gqlErr}}
+	}
+	return Response{Errors: []Error{{Message: err.Error()}}}
+}
+
+func pickOperation(doc *document, name string) (*operation, error) {
+	if name == "" {
+		if len(doc.operations) > 1 {
+			return nil, fmt.Errorf("operationName is required for documents with several operations")
+		}
+		return doc.operations[0], nil
+	}
+	for _, op := range doc.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+func coerceVariables(src string, op *operation, values map[string]any) (map[string]any, error) {
+	vars := map[string]any{}
+	for _, def := range op.variables {
+		value, ok := values[def.name]
+		switch {
+		case ok:
+			vars[def.name] = value
+		case def.hasDef:
+			vars[def.name] = def.def
+		}
+		if vars[def.name] == nil && def.nonNull {
+			return nil, &Error{Message: fmt.Sprintf("variable $%s is required", def.name), Locations: []Location{location(src, def.position)}}
+		}
+	}
+	return vars, nil
+}
+
+// validate checks the root fields against the schema, their number and the
+// depth of the selections before anything is resolved.
+func validate(src string, schema *Schema, fields []*field, depth int) error {
+	if schema.MaxDepth > 0 && depth > schema.MaxDepth {
+		return &Error{Message: fmt.Sprintf("query is deeper than %d levels", schema.MaxDepth), Locations: []Location{location(src, fields[0].position)}}
+	}
+	if depth == 1 && schema.MaxRootFields > 0 && len(fields) > schema.MaxRootFields {
+		return &Error{Message: fmt.Sprintf("query selects more than %d root fields", schema.MaxRootFields), Locations: []Location{location(src, fields[schema.MaxRootFields].position)}}
+	}
+	for _, f := range fields {
+		if depth == 1 && f.name != "__typename" {
+			if _, ok := schema.Query[f.name]; !ok {
+				return &Error{Message: fmt.Sprintf("cannot query field %q on type \"Query\"", f.name), Locations: []Location{location(src, f.position)}}
+			}
+		}
+		if depth > 1 && len(f.args) > 0 {
+			return &Error{Message: fmt.Sprintf("field %q takes no arguments", f.name), Locations: []Location{location(src, f.position)}}
+		}
+		if f.name == "__typename" && len(f.selections) > 0 {
+			return &Error{Message: "field \"__typename\" has no subfields", Locations: []Location{location(src, f.position)}}
+		}
+		if len(f.selections) > 0 {
+			if err := validate(src, schema, f.selections, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type executor struct {
+	src  string
+	vars map[string]any
+}
+
+// included drops the fields skipped by their @skip or @include directives.
+func (e *executor) included(fields []*field) ([]*field, error) {
+	kept := make([]*field, 0, len(fields))
+	for _, f := range fields {
+		keep := true
+		for _, d := range f.directives {
+			if d.name != "skip" && d.name != "include" {
+				return nil, &Error{Message: fmt.Sprintf("unknown directive @%s", d.name), Locations: []Location{location(e.src, f.position)}}
+			}
+			cond, ok := e.resolveValue(d.args["if"]).(bool)
+			if !ok {
+				return nil, &Error{Message: fmt.Sprintf("@%s requires a boolean if argument", d.name), Locations: []Location{location(e.src, f.position)}}
+			}
+			if cond == (d.name == "skip") {
+				keep = false
+			}
+		}
+		if keep {
+			kept = append(kept, f)
+		}
+	}
+	return kept, nil
+}
+
+// complete selects the subfields of f from value.
+func (e *executor) complete(f *field, value any, path []any) (any, []Error) {
+	if value == nil || len(f.selections) == 0 {
+		return value, nil
+	}
+	switch v := value.(type) {
+	case []any:
+		list := make([]any, len(v))
+		var errs []Error
+		for i, item := range v {
+			var itemErrs []Error
+			list[i], itemErrs = e.complete(f, item, append(path[:len(path):len(path)], i))
+			errs = append(errs, itemErrs...)
+		}
+		return list, errs
+	case map[string]any:
+		fields, err := e.included(f.selections)
+		if err != nil {
+			return nil, []Error{e.fieldError(f, path, err)}
+		}
+		obj := newObject()
+		var errs []Error
+		for _, sub := range fields {
+			if sub.name == "__typename" {
+				obj.set(sub.key(), nil)
+				continue
+			}
+			member, memberErrs := e.complete(sub, v[sub.name], append(path[:len(path):len(path)], sub.key()))
+			obj.set(sub.key(), member)
+			errs = append(errs, memberErrs...)
+		}
+		return obj, errs
+	}
+	return nil, []Error{e.fieldError(f, path, fmt.Errorf("field %q is not an object and has no subfields", f.name))}
+}
+
+func (e *executor) fieldError(f *field, path []any, err error) Error {
+	out := Error{Message: err.Error()}
+	var gqlErr *Error
+	if errors.As(err, &gqlErr) {
+		out = *gqlErr
+	}
+	out.Locations = []Location{location(e.src, f.position)}
+	out.Path = path
+	return out
+}
+
+// resolveValue replaces the variable references in an argument value.
+func (e *executor) resolveValue(value any) any {
+	switch v := value.(type) {
+	case variable:
+		return e.vars[string(v)]
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = e.resolveValue(item)
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, item := range v {
+			out[key] = e.resolveValue(item)
+		}
+		return out
+	}
+	return value
+}
+
+func asArgs(value any) map[string]any {
+	args, _ := value.(map[string]any)
+	if args == nil {
+		args = map[string]any{}
+	}
+	return args
+}
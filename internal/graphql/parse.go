@@ -0,0 +1,484 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// document is a parsed query document.
+type document struct {
+	operations []*operation
+}
+
+type operation struct {
+	name       string
+	variables  []variableDefinition
+	selections []*field
+}
+
+type variableDefinition struct {
+	name     string
+	nonNull  bool
+	def      any
+	hasDef   bool
+	position int
+}
+
+type field struct {
+	alias      string
+	name       string
+	args       map[string]any
+	directives []directive
+	selections []*field
+	position   int
+}
+
+// key is the name of the field in the response.
+func (f *field) key() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+type directive struct {
+	name string
+	args map[string]any
+}
+
+// variable is a reference to a variable in a value, resolved when the
+// operation is executed.
+type variable string
+
+const (
+	tokenEOF = iota
+	tokenPunct
+	tokenName
+	tokenNumber
+	tokenString
+)
+
+type token struct {
+	kind  int
+	value string
+	pos   int
+}
+
+type parser struct {
+	src string
+	pos int
+	tok token
+}
+
+// parse reads a query document. Fragments, mutations and subscriptions are
+// refused rather than parsed.
+func parse(src string) (*document, error) {
+	p := &parser{src: src}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	doc := &document{}
+	for p.tok.kind != tokenEOF {
+		op, err := p.operation()
+		if err != nil {
+			return nil, err
+		}
+		doc.operations = append(doc.operations, op)
+	}
+	if len(doc.operations) == 0 {
+		return nil, fmt.Errorf("document has no operation")
+	}
+	return doc, nil
+}
+
+func (p *parser) operation() (*operation, error) {
+	op := &operation{}
+	if p.tok.kind == tokenName {
+		switch p.tok.value {
+		case "query":
+		case "mutation", "subscription":
+			return nil, p.errorf("%s operations are not supported", p.tok.value)
+		case "fragment":
+			return nil, p.errorf("fragments are not supported")
+		default:
+			return nil, p.errorf("unexpected %q", p.tok.value)
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if p.tok.kind == tokenName {
+			op.name = p.tok.value
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+		if p.peek("(") {
+			vars, err := p.variableDefinitions()
+			if err != nil {
+				return nil, err
+			}
+			op.variables = vars
+		}
+		if _, err := p.directives(); err != nil {
+			return nil, err
+		}
+	}
+	selections, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	op.selections = selections
+	return op, nil
+}
+
+func (p *parser) variableDefinitions() ([]variableDefinition, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var vars []variableDefinition
+	for !p.peek(")") {
+		def := variableDefinition{position: p.tok.pos}
+		if err := p.expect("$"); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		def.name = name
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if def.nonNull, err = p.typeRef(); err != nil {
+			return nil, err
+		}
+		if p.peek("=") {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			if def.def, err = p.value(true); err != nil {
+				return nil, err
+			}
+			def.hasDef = true
+		}
+		if _, err := p.directives(); err != nil {
+			return nil, err
+		}
+		vars = append(vars, def)
+	}
+	return vars, p.expect(")")
+}
+
+// typeRef skips a type reference, reporting whether it is non-null. Types
+// aren't checked: values reach the resolvers as decoded from JSON.
+func (p *parser) typeRef() (bool, error) {
+	if p.peek("[") {
+		if err := p.next(); err != nil {
+			return false, err
+		}
+		if _, err := p.typeRef(); err != nil {
+			return false, err
+		}
+		if err := p.expect("]"); err != nil {
+			return false, err
+		}
+	} else if _, err := p.name(); err != nil {
+		return false, err
+	}
+	if p.peek("!") {
+		return true, p.next()
+	}
+	return false, nil
+}
+
+func (p *parser) selectionSet() ([]*field, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var fields []*field
+	for !p.peek("}") {
+		if p.peek("...") {
+			return nil, p.errorf("fragments are not supported")
+		}
+		f, err := p.field()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, f)
+	}
+	if len(fields) == 0 {
+		return nil, p.errorf("empty selection set")
+	}
+	return fields, p.expect("}")
+}
+
+func (p *parser) field() (*field, error) {
+	f := &field{position: p.tok.pos}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	f.name = name
+	if p.peek(":") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		f.alias = name
+		if f.name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	if p.peek("(") {
+		if f.args, err = p.arguments(); err != nil {
+			return nil, err
+		}
+	}
+	if f.directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	if p.peek("{") {
+		if f.selections, err = p.selectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func (p *parser) arguments() (map[string]any, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	args := map[string]any{}
+	for !p.peek(")") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if args[name], err = p.value(false); err != nil {
+			return nil, err
+		}
+	}
+	return args, p.expect(")")
+}
+
+func (p *parser) directives() ([]directive, error) {
+	var directives []directive
+	for p.peek("@") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		d := directive{name: name}
+		if p.peek("(") {
+			if d.args, err = p.arguments(); err != nil {
+				return nil, err
+			}
+		}
+		directives = append(directives, d)
+	}
+	return directives, nil
+}
+
+// value reads an input value; const values, such as variable defaults, can't
+// refer to variables.
+func (p *parser) value(constant bool) (any, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokenNumber:
+		return json.Number(tok.value), p.next()
+	case tokenString:
+		return tok.value, p.next()
+	case tokenName:
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		switch tok.value {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		// Enum values are passed on as their names.
+		return tok.value, nil
+	case tokenPunct:
+		switch tok.value {
+		case "$":
+			if constant {
+				return nil, p.errorf("unexpected variable")
+			}
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			name, err := p.name()
+			return variable(name), err
+		case "[":
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			list := []any{}
+			for !p.peek("]") {
+				item, err := p.value(constant)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, item)
+			}
+			return list, p.next()
+		case "{":
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			object := map[string]any{}
+			for !p.peek("}") {
+				name, err := p.name()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expect(":"); err != nil {
+					return nil, err
+				}
+				if object[name], err = p.value(constant); err != nil {
+					return nil, err
+				}
+			}
+			return object, p.next()
+		}
+	}
+	return nil, p.unexpected()
+}
+
+func (p *parser) name() (string, error) {
+	if p.tok.kind != tokenName {
+		return "", p.unexpected()
+	}
+	name := p.tok.value
+	return name, p.next()
+}
+
+func (p *parser) peek(punct string) bool {
+	return p.tok.kind == tokenPunct && p.tok.value == punct
+}
+
+func (p *parser) expect(punct string) error {
+	if !p.peek(punct) {
+		return p.unexpected()
+	}
+	return p.next()
+}
+
+func (p *parser) unexpected() error {
+	if p.tok.kind == tokenEOF {
+		return p.errorf("unexpected end of document")
+	}
+	return p.errorf("unexpected %q", p.tok.value)
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return &Error{Message: "syntax error: " + fmt.Sprintf(format, args...), Locations: []Location{location(p.src, p.tok.pos)}}
+}
+
+// next reads the following token, skipping whitespace, commas and comments.
+func (p *parser) next() error {
+	for p.pos < len(p.src) {
+		ch := p.src[p.pos]
+		if ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == ',' {
+			p.pos++
+			continue
+		}
+		if ch == '#' {
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+			continue
+		}
+		break
+	}
+	start := p.pos
+	if p.pos >= len(p.src) {
+		p.tok = token{kind: tokenEOF, pos: start}
+		return nil
+	}
+	ch := p.src[p.pos]
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "..."):
+		p.pos += 3
+		p.tok = token{kind: tokenPunct, value: "...", pos: start}
+	case strings.IndexByte("!$():=@[]{}|&", ch) >= 0:
+		p.pos++
+		p.tok = token{kind: tokenPunct, value: string(ch), pos: start}
+	case ch == '_' || isLetter(ch):
+		for p.pos < len(p.src) && (p.src[p.pos] == '_' || isLetter(p.src[p.pos]) || isDigit(p.src[p.pos])) {
+			p.pos++
+		}
+		p.tok = token{kind: tokenName, value: p.src[start:p.pos], pos: start}
+	case ch == '-' || isDigit(ch):
+		p.pos++
+		for p.pos < len(p.src) && strings.IndexByte("0123456789.eE+-", p.src[p.pos]) >= 0 {
+			p.pos++
+		}
+		literal := p.src[start:p.pos]
+		if !json.Valid([]byte(literal)) {
+			p.tok = token{kind: tokenNumber, value: literal, pos: start}
+			return p.errorf("invalid number %q", literal)
+		}
+		p.tok = token{kind: tokenNumber, value: literal, pos: start}
+	case ch == '"':
+		return p.string(start)
+	default:
+		p.tok = token{kind: tokenPunct, value: string(ch), pos: start}
+		return p.errorf("unexpected character %q", ch)
+	}
+	return nil
+}
+
+// string reads a string literal, whose escapes are those of JSON. Block
+// strings are not supported.
+func (p *parser) string(start int) error {
+	if strings.HasPrefix(p.src[start:], `"""`) {
+		p.tok = token{kind: tokenString, pos: start}
+		return p.errorf("block strings are not supported")
+	}
+	p.pos++
+	for p.pos < len(p.src) && p.src[p.pos] != '"' && p.src[p.pos] != '\n' {
+		if p.src[p.pos] == '\\' {
+			p.pos++
+		}
+		p.pos++
+	}
+	if p.pos >= len(p.src) || p.src[p.pos] != '"' {
+		p.tok = token{kind: tokenString, pos: start}
+		return p.errorf("unterminated string")
+	}
+	p.pos++
+	var value string
+	if err := json.Unmarshal([]byte(p.src[start:p.pos]), &value); err != nil {
+		p.tok = token{kind: tokenString, pos: start}
+		return p.errorf("invalid string")
+	}
+	p.tok = token{kind: tokenString, value: value, pos: start}
+	return nil
+}
+
+func isLetter(ch byte) bool {
+	return ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z'
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+// location converts an offset in src to a 1-based line and column.
+func location(src string, pos int) Location {
+	pos = min(pos, len(src))
+	line := strings.Count(src[:pos], "\n") + 1
+	return Location{Line: line, Column: pos - strings.LastIndexByte(src[:pos], '\n')}
+}
@@ -0,0 +1,87 @@
+package health
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// errorBuckets is how many slices the window of ErrorRates is split into;
+// counts leave the window one slice at a time.
+const errorBuckets = 30
+
+// ErrorRates counts the requests and server errors of each route over a
+// sliding window.
+type ErrorRates struct {
+	bucket time.Duration
+
+	mu     sync.Mutex
+	routes map[string]*routeCounts
+}
+
+type routeCounts struct {
+	buckets [errorBuckets]errorBucket
+}
+
+type errorBucket struct {
+	slot     int64
+	requests int
+	failed   int
+}
+
+func NewErrorRates(window time.Duration) *ErrorRates {
+	if window <= 0 {
+		window = 5 * time.Minute
+	}
+	return &ErrorRates{bucket: max(window/errorBuckets, time.Second), routes: make(map[string]*routeCounts)}
+}
+
+// Record counts a request to the route template route.
+func (r *ErrorRates) Record(route string, failed bool) {
+	slot := time.Now().UnixNano() / int64(r.bucket)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	counts, ok := r.routes[route]
+	if !ok {
+		counts = &routeCounts{}
+		r.routes[route] = counts
+	}
+	b := &counts.buckets[slot%errorBuckets]
+	if b.slot != slot {
+		*b = errorBucket{slot: slot}
+	}
+	b.requests++
+	if failed {
+		b.failed++
+	}
+}
+
+// Count sums the requests and failures within the window of the routes
+// under any of prefixes; a prefix matches whole path segments.
+func (r *ErrorRates) Count(prefixes []string) (requests, failed int) {
+	oldest := time.Now().UnixNano()/int64(r.bucket) - errorBuckets + 1
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for route, counts := range r.routes {
+		if !underAny(route, prefixes) {
+			continue
+		}
+		for _, b := range counts.buckets {
+			if b.slot >= oldest {
+				requests += b.requests
+				failed += b.failed
+			}
+		}
+	}
+	return requests, failed
+}
+
+func underAny(route string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		prefix = strings.TrimRight(prefix, "/")
+		if route == prefix || strings.HasPrefix(route, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
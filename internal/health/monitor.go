@@ -0,0 +1,228 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// Checker probes a single upstream and returns an error when it is unreachable.
+type Checker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+// Status is the last known health state of an upstream.
+type Status struct {
+	Name                string    `json:"name"`
+	Healthy             bool      `json:"healthy"`
+	Degraded            bool      `json:"degraded"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastError           string    `json:"last_error,omitempty"`
+	LastCheck           time.Time `json:"last_check"`
+	LastSuccess         time.Time `json:"last_success,omitempty"`
+}
+
+type Config struct {
+	Interval         time.Duration
+	Timeout          time.Duration
+	FailureThreshold int
+}
+
+// Monitor polls every registered upstream on an interval and marks it as
+// degraded after FailureThreshold consecutive failed checks.
+type Monitor struct {
+	cfg      Config
+	checkers []Checker
+	log      *slog.Logger
+
+	mu       sync.RWMutex
+	statuses map[string]*Status
+}
+
+func NewMonitor(cfg Config, log *slog.Logger, checkers ...Checker) *Monitor {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 10 * time.Second
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 2 * time.Second
+	}
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 3
+	}
+	statuses := make(map[string]*Status, len(checkers))
+	for _, checker := range checkers {
+		statuses[checker.Name()] = &Status{Name: checker.Name(), Healthy: true}
+	}
+	return &Monitor{
+		cfg:      cfg,
+		checkers: checkers,
+		log:      log,
+		statuses: statuses,
+	}
+}
+
+func (m *Monitor) Run(ctx context.Context) {
+	go func() {
+		m.checkAll(ctx)
+		ticker := time.NewTicker(m.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.checkAll(ctx)
+			}
+		}
+	}()
+}
+
+func (m *Monitor) checkAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, checker := range m.checkers {
+		wg.Add(1)
+		go func(checker Checker) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, m.cfg.Timeout)
+			defer cancel()
+			m.record(checker.Name(), checker.Check(checkCtx))
+		}(checker)
+	}
+	wg.Wait()
+}
+
+func (m *Monitor) record(name string, err error) {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	st := m.statuses[name]
+	wasDegraded := st.Degraded
+	st.LastCheck = now
+	if err == nil {
+		st.Healthy = true
+		st.Degraded = false
+		st.ConsecutiveFailures = 0
+		st.LastError = ""
+		st.LastSuccess = now
+		if wasDegraded {
+			m.log.Info("upstream recovered", slog.String("upstream", name))
+		}
+		return
+	}
+	st.Healthy = false
+	st.ConsecutiveFailures++
+	st.LastError = err.Error()
+	st.Degraded = st.ConsecutiveFailures >= m.cfg.FailureThreshold
+	if st.Degraded && !wasDegraded {
+		m.log.Warn("upstream degraded",
+			slog.String("upstream", name),
+			slog.Int("consecutive_failures", st.ConsecutiveFailures),
+			slog.String("err", st.LastError),
+		)
+	}
+}
+
+// Degraded reports whether the upstream is currently degraded. A nil monitor
+// never reports degradation so callers don't need to guard on it.
+func (m *Monitor) Degraded(name string) (Status, bool) {
+	if m == nil {
+		return Status{}, false
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	st, ok := m.statuses[name]
+	if !ok {
+		return Status{}, false
+	}
+	return *st, st.Degraded
+}
+
+func (m *Monitor) Snapshot() []Status {
+	if m == nil {
+		return nil
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	res := make([]Status, 0, len(m.checkers))
+	for _, checker := range m.checkers {
+		res = append(res, *m.statuses[checker.Name()])
+	}
+	return res
+}
+
+func (m *Monitor) Interval() time.Duration {
+	if m == nil {
+		return 0
+	}
+	return m.cfg.Interval
+}
+
+// HTTPChecker treats any response below 500 from endpoint as healthy.
+type HTTPChecker struct {
+	name     string
+	endpoint string
+	http     *http.Client
+}
+
+func NewHTTPChecker(name, endpoint string, transport http.RoundTripper) *HTTPChecker {
+	return &HTTPChecker{name: name, endpoint: endpoint, http: &http.Client{Transport: transport}}
+}
+
+func (c *HTTPChecker) Name() string {
+	return c.name
+}
+
+func (c *HTTPChecker) Check(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("unhealthy status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// GRPCChecker uses the standard grpc.health.v1 protocol. Servers that don't
+// implement it are considered healthy as long as they answer at all.
+type GRPCChecker struct {
+	name   string
+	client healthpb.HealthClient
+}
+
+func NewGRPCChecker(name string, conn grpc.ClientConnInterface) *GRPCChecker {
+	return &GRPCChecker{name: name, client: healthpb.NewHealthClient(conn)}
+}
+
+func (c *GRPCChecker) Name() string {
+	return c.name
+}
+
+func (c *GRPCChecker) Check(ctx context.Context) error {
+	resp, err := c.client.Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		if status.Code(err) == codes.Unimplemented {
+			return nil
+		}
+		return err
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("serving status %s", resp.GetStatus())
+	}
+	return nil
+}
@@ -0,0 +1,324 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/immxrtalbeast/api-gateway/internal/apierror"
+	"github.com/immxrtalbeast/api-gateway/internal/clients/auth"
+	"github.com/immxrtalbeast/api-gateway/internal/clients/scripts"
+	"github.com/immxrtalbeast/api-gateway/internal/clients/videos"
+	"github.com/immxrtalbeast/api-gateway/internal/masking"
+	"github.com/immxrtalbeast/api-gateway/internal/reload"
+	"github.com/immxrtalbeast/api-gateway/internal/revocation"
+	"github.com/immxrtalbeast/api-gateway/internal/store"
+	authv1 "github.com/immxrtalbeast/protos/gen/go/auth/v1"
+)
+
+// ImpersonatedByHeader tells upstreams which admin acts on behalf of the user
+// in X-User-ID.
+const ImpersonatedByHeader = "X-Impersonated-By"
+
+// AdminHandler serves the /api/admin user management routes. Routes must be
+// guarded by AuthMiddleware and AdminOnly.
+type AdminHandler struct {
+	log          *slog.Logger
+	auth         auth.Client
+	videos       *videos.Client
+	scripts      *scripts.Client
+	timeout      *reload.Value[time.Duration]
+	videoMasker  *masking.Masker
+	scriptMasker *masking.Masker
+	// secret signs impersonation tokens; it is the same APP_SECRET
+	// AuthMiddleware verifies tokens with.
+	secret           *reload.Value[string]
+	impersonationTTL time.Duration
+	// secrets is the encrypted store; nil when no master key is configured.
+	secrets *store.Encrypted
+	export  ExportOptions
+	// revoked ends the sessions of disabled users on every replica.
+	revoked *revocation.List
+}
+
+func NewAdminHandler(log *slog.Logger, authClient auth.Client, videoClient *videos.Client, scriptClient *scripts.Client, timeout time.Duration, videoMasker, scriptMasker *masking.Masker, secret string, impersonationTTL time.Duration, secrets *store.Encrypted, export ExportOptions, revoked *revocation.List) *AdminHandler {
+	return &AdminHandler{
+		log:              log,
+		auth:             authClient,
+		videos:           videoClient,
+		scripts:          scriptClient,
+		timeout:          reload.NewValue(timeout),
+		videoMasker:      videoMasker,
+		scriptMasker:     scriptMasker,
+		secret:           reload.NewValue(secret),
+		impersonationTTL: impersonationTTL,
+		secrets:          secrets,
+		export:           export,
+		revoked:          revoked,
+	}
+}
+
+// SetTimeout changes the upstream call timeout of the following requests.
+func (h *AdminHandler) SetTimeout(timeout time.Duration) {
+	h.timeout.Store(timeout)
+}
+
+// SetSecret changes the key the following impersonation tokens are signed
+// with.
+func (h *AdminHandler) SetSecret(secret string) {
+	h.secret.Store(secret)
+}
+
+func (h *AdminHandler) GetUser(c *gin.Context) {
+	userID := strings.TrimSpace(c.Param("id"))
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
+	defer cancel()
+
+	resp, err := h.auth.GetUser(ctx, &authv1.GetUserRequest{UserId: userID})
+	if err != nil {
+		handleAuthError(c, err)
+		return
+	}
+	writeJSON(c, http.StatusOK, map[string]any{"user": convertUser(resp.GetUser())})
+}
+
+// maxUsersPageSize bounds the page_size ListUsers passes on.
+const maxUsersPageSize = 200
+
+// ListUsers pages through all users: ?page_size=&page_token=, with the next
+// token in next_page_token until the last page.
+func (h *AdminHandler) ListUsers(c *gin.Context) {
+	pageSize := 0
+	if value := c.Query("page_size"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			writeError(c, http.StatusBadRequest, "page_size must be a positive integer")
+			return
+		}
+		pageSize = min(n, maxUsersPageSize)
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
+	defer cancel()
+
+	resp, err := h.auth.ListUsers(ctx, &authv1.ListUsersRequest{PageSize: int32(pageSize), PageToken: c.Query("page_token")})
+	if err != nil {
+		handleAuthError(c, err)
+		return
+	}
+	users := make([]userResponse, 0, len(resp.GetUsers()))
+	for _, u := range resp.GetUsers() {
+		users = append(users, convertUser(u))
+	}
+	writeJSON(c, http.StatusOK, map[string]any{"users": users, "next_page_token": resp.GetNextPageToken()})
+}
+
+type setUserRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// SetUserRole makes a user an admin or a regular user: {"role": "admin"}.
+// Admins can't change their own role, so the last admin can't demote
+// themselves by accident.
+func (h *AdminHandler) SetUserRole(c *gin.Context) {
+	userID := strings.TrimSpace(c.Param("id"))
+	var req setUserRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "invalid json payload")
+		return
+	}
+	var role authv1.UserRole
+	switch req.Role {
+	case "admin":
+		role = authv1.UserRole_USER_ROLE_ADMIN
+	case "user":
+		role = authv1.UserRole_USER_ROLE_USER
+	default:
+		writeError(c, http.StatusBadRequest, "role must be admin or user")
+		return
+	}
+	if userID == currentUserID(c) {
+		writeError(c, http.StatusBadRequest, "cannot change your own role")
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
+	defer cancel()
+
+	resp, err := h.auth.SetUserRole(ctx, &authv1.SetUserRoleRequest{UserId: userID, Role: role})
+	if err != nil {
+		handleAuthError(c, err)
+		return
+	}
+	writeJSON(c, http.StatusOK, map[string]any{"user": convertUser(resp.GetUser())})
+}
+
+// DisableUser blocks a user's logins and ends their sessions, streams
+// included, on every replica.
+func (h *AdminHandler) DisableUser(c *gin.Context) {
+	userID := strings.TrimSpace(c.Param("id"))
+	if userID == currentUserID(c) {
+		writeError(c, http.StatusBadRequest, "cannot disable yourself")
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
+	defer cancel()
+
+	if _, err := h.auth.DisableUser(ctx, &authv1.DisableUserRequest{UserId: userID}); err != nil {
+		handleAuthError(c, err)
+		return
+	}
+	if h.revoked != nil {
+		if err := h.revoked.Revoke(c.Request.Context(), userID); err != nil {
+			h.log.Error("failed to broadcast token revocation", slog.String("user_id", userID), slog.String("err", err.Error()))
+		}
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListUserVideos shows another user's videos by calling the video service on
+// their behalf. With ?all=true it returns all of them rather than the first
+// page, see listAllVideos.
+func (h *AdminHandler) ListUserVideos(c *gin.Context) {
+	if c.Query("all") == "true" {
+		h.listAllVideos(c, c.Param("id"))
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
+	defer cancel()
+
+	resp, err := h.videos.ListVideos(ctx, impersonationHeaders(c, c.Param("id")))
+	if err != nil {
+		if clientGone(c, err) {
+			return
+		}
+		h.log.Error("admin list videos failed", slog.String("err", err.Error()))
+		writeUpstreamError(c, "video", err)
+		return
+	}
+	if err := writeUpstream(c, h.videoMasker, resp.StatusCode, resp.Header, resp.Body); err != nil {
+		markAbandoned(c)
+	}
+}
+
+// ListUserScripts shows another user's scripts like ListUserVideos, ?all=true
+// included.
+func (h *AdminHandler) ListUserScripts(c *gin.Context) {
+	if c.Query("all") == "true" {
+		h.listAllScripts(c, c.Param("id"))
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
+	defer cancel()
+
+	resp, err := h.scripts.ListScripts(ctx, impersonationHeaders(c, c.Param("id")))
+	if err != nil {
+		if clientGone(c, err) {
+			return
+		}
+		h.log.Error("admin list scripts failed", slog.String("err", err.Error()))
+		writeUpstreamError(c, "script", err)
+		return
+	}
+	if err := writeUpstream(c, h.scriptMasker, resp.StatusCode, resp.Header, resp.Body); err != nil {
+		markAbandoned(c)
+	}
+}
+
+// Impersonate issues a short-lived token that lets the calling admin act as
+// another user. Requests made with it reach the upstreams as that user with
+// X-Impersonated-By set, and cannot use the admin routes. The token is only
+// returned in the body so the admin's own session cookie is left untouched.
+func (h *AdminHandler) Impersonate(c *gin.Context) {
+	userID := strings.TrimSpace(c.Param("user_id"))
+	adminID := currentUserID(c)
+	if userID == "" {
+		writeError(c, http.StatusBadRequest, "user_id is required")
+		return
+	}
+	if userID == adminID {
+		writeError(c, http.StatusBadRequest, "cannot impersonate yourself")
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
+	defer cancel()
+
+	resp, err := h.auth.GetUser(ctx, &authv1.GetUserRequest{UserId: userID})
+	if err != nil {
+		handleAuthError(c, err)
+		return
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(h.impersonationTTL)
+	tokenID := newTokenID()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"uid": userID,
+		"imp": adminID,
+		"jti": tokenID,
+		"iat": now.Unix(),
+		"exp": expiresAt.Unix(),
+	}).SignedString([]byte(h.secret.Load()))
+	if err != nil {
+		h.log.Error("sign impersonation token failed", slog.String("err", err.Error()))
+		writeError(c, http.StatusInternalServerError, "failed to issue token")
+		return
+	}
+
+	h.log.Warn("impersonation token issued",
+		slog.String("admin_id", adminID),
+		slog.String("user_id", userID),
+		slog.String("token_id", tokenID),
+		slog.Time("expires_at", expiresAt),
+		slog.String("client", c.ClientIP()),
+		slog.String("request_id", c.GetString("requestID")),
+	)
+	writeJSON(c, http.StatusOK, map[string]any{
+		"token":           token,
+		"token_id":        tokenID,
+		"expires_at":      expiresAt.UTC().Format(time.RFC3339),
+		"user":            convertUser(resp.GetUser()),
+		"impersonated_by": adminID,
+	})
+}
+
+// ReencryptSecrets re-encrypts stored secrets still sealed with a retired
+// master key under the primary one. Run it after rotating the primary key and
+// before removing the old key from the configuration.
+func (h *AdminHandler) ReencryptSecrets(c *gin.Context) {
+	if h.secrets == nil {
+		apierror.Abort(c, http.StatusNotImplemented, apierror.CodeNotImplemented, "secret encryption is not configured", nil)
+		return
+	}
+	res, err := h.secrets.Reencrypt(c.Request.Context(), "")
+	if err != nil {
+		if clientGone(c, err) {
+			return
+		}
+		h.log.Error("secret re-encryption failed", slog.String("err", err.Error()), slog.Int("reencrypted", res.Reencrypted))
+		apierror.Abort(c, http.StatusInternalServerError, apierror.CodeInternal, "secret re-encryption failed", map[string]any{
+			"reencrypted": res.Reencrypted,
+		})
+		return
+	}
+	h.log.Info("secrets re-encrypted", slog.Int("scanned", res.Scanned), slog.Int("reencrypted", res.Reencrypted), slog.Int("failed", res.Failed))
+	writeJSON(c, http.StatusOK, res)
+}
+
+func impersonationHeaders(c *gin.Context, userID string) map[string]string {
+	return map[string]string{
+		"X-User-ID":          strings.TrimSpace(userID),
+		ImpersonatedByHeader: currentUserID(c),
+	}
+}
+
+func newTokenID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
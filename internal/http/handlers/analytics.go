@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"encoding/json"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/analytics"
+	"github.com/immxrtalbeast/api-gateway/internal/apierror"
+)
+
+// jobRequested describes the CreateVideo request with body, as sent by the
+// client, for the analytics topic. The job belongs to the video's owner when
+// a collaborator creates it.
+func jobRequested(c *gin.Context, body []byte) analytics.JobRequested {
+	rec := analytics.JobRequested{
+		UserID:         currentUserID(c),
+		ImpersonatedBy: c.GetString("impersonatedBy"),
+		OrgID:          c.GetString("orgID"),
+		Plan:           c.GetString("userPlan"),
+		Priority:       c.GetString("requestPriority"),
+		RequestID:      c.Writer.Header().Get(apierror.RequestIDHeader),
+	}
+	if owner := c.GetString("ownerID"); owner != "" {
+		rec.CollaboratorID = rec.UserID
+		rec.UserID = owner
+	}
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(body, &doc); err != nil || doc == nil {
+		return rec
+	}
+	if err := json.Unmarshal(doc["preset"], &rec.Preset); err == nil {
+		delete(doc, "preset")
+	}
+	delete(doc, "priority")
+	if len(doc) > 0 {
+		rec.Parameters, _ = json.Marshal(doc)
+	}
+	return rec
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/clients/oidc"
+)
+
+// Auth modes reported by GET /api/auth/config.
+const (
+	AuthModeService    = "auth_service"
+	AuthModeStandalone = "standalone"
+)
+
+// Access token verification reported by GET /api/auth/config.
+const (
+	TokenVerificationJWKS   = "jwks"
+	TokenVerificationSecret = "shared_secret"
+)
+
+type AuthConfigOptions struct {
+	Mode              string
+	TokenVerification string
+	AccessTokenTTL    time.Duration
+	ImpersonationTTL  time.Duration
+	// OIDC, when set, adds the issuer's discovery data for ClientID and
+	// Scopes.
+	OIDC     *oidc.Discovery
+	ClientID string
+	Scopes   []string
+}
+
+// AuthConfigHandler describes how this gateway authenticates users, so
+// frontends configure themselves per environment.
+type AuthConfigHandler struct {
+	opts AuthConfigOptions
+}
+
+func NewAuthConfigHandler(opts AuthConfigOptions) *AuthConfigHandler {
+	return &AuthConfigHandler{opts: opts}
+}
+
+func (h *AuthConfigHandler) Config(c *gin.Context) {
+	payload := map[string]any{
+		"mode":               h.opts.Mode,
+		"token_verification": h.opts.TokenVerification,
+		"tokens": map[string]any{
+			"access_token_ttl_seconds":  int(h.opts.AccessTokenTTL.Seconds()),
+			"impersonation_ttl_seconds": int(h.opts.ImpersonationTTL.Seconds()),
+			// Refresh tokens are returned in the login response body and sent
+			// back to POST /api/auth/refresh.
+			"refresh_token": "body",
+		},
+		"cookie": map[string]any{
+			"name":            sessionCookie,
+			"path":            sessionCookiePath,
+			"http_only":       true,
+			"secure":          sessionCookieSecure,
+			"same_site":       "lax",
+			"max_age_seconds": maxAgeSeconds(h.opts.AccessTokenTTL),
+		},
+	}
+	if h.opts.OIDC != nil {
+		payload["oidc"] = map[string]any{
+			"client_id": h.opts.ClientID,
+			"scopes":    h.opts.Scopes,
+			// Null until the issuer has answered once.
+			"discovery": h.opts.OIDC.Document(),
+		}
+	}
+	c.Header("Cache-Control", "public, max-age=300")
+	writeJSON(c, http.StatusOK, payload)
+}
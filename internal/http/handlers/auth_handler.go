@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
@@ -9,20 +10,40 @@ import (
 	"log/slog"
 
 	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/apierror"
+	"github.com/immxrtalbeast/api-gateway/internal/clients/auth"
+	"github.com/immxrtalbeast/api-gateway/internal/reload"
+	"github.com/immxrtalbeast/api-gateway/internal/revocation"
 	authv1 "github.com/immxrtalbeast/protos/gen/go/auth/v1"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
+// The session cookie carrying the access token, HttpOnly and SameSite=Lax.
+const (
+	sessionCookie       = "jwt"
+	sessionCookiePath   = "/"
+	sessionCookieSecure = false
+)
+
 type AuthHandler struct {
 	log      *slog.Logger
-	client   authv1.AuthServiceClient
-	timeout  time.Duration
+	client   auth.Client
+	timeout  *reload.Value[time.Duration]
 	tokenTTL time.Duration
+	// forgotDelay is the minimum response time of ForgotPassword, so unknown
+	// emails can't be told apart by latency.
+	forgotDelay time.Duration
+	revoked     *revocation.List
+}
+
+func NewAuthHandler(log *slog.Logger, client auth.Client, timeout, tokenTTL, forgotDelay time.Duration, revoked *revocation.List) *AuthHandler {
+	return &AuthHandler{log: log, client: client, timeout: reload.NewValue(timeout), tokenTTL: tokenTTL, forgotDelay: forgotDelay, revoked: revoked}
 }
 
-func NewAuthHandler(log *slog.Logger, client authv1.AuthServiceClient, timeout, tokenTTL time.Duration) *AuthHandler {
-	return &AuthHandler{log: log, client: client, timeout: timeout, tokenTTL: tokenTTL}
+// SetTimeout changes the upstream call timeout of the following requests.
+func (h *AuthHandler) SetTimeout(timeout time.Duration) {
+	h.timeout.Store(timeout)
 }
 
 type registerRequest struct {
@@ -43,6 +64,47 @@ type logoutRequest struct {
 	RefreshToken string `json:"refresh_token"`
 }
 
+type forgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type resetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type verifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type changeEmailRequest struct {
+	Password string `json:"password"`
+	NewEmail string `json:"new_email"`
+}
+
+type sessionResponse struct {
+	ID         string `json:"id"`
+	UserAgent  string `json:"user_agent,omitempty"`
+	IP         string `json:"ip,omitempty"`
+	CreatedAt  string `json:"created_at,omitempty"`
+	LastUsedAt string `json:"last_used_at,omitempty"`
+	Current    bool   `json:"current"`
+}
+
+type twoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type twoFactorChallengeRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"`
+}
+
 type userResponse struct {
 	ID        string `json:"id"`
 	Email     string `json:"email"`
@@ -63,14 +125,17 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeout)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
 	defer cancel()
 
+	// Until the account is known, failed attempts are audited by email.
+	c.Set("auditActor", req.Email)
 	resp, err := h.client.Register(ctx, &authv1.RegisterRequest{Email: req.Email, Password: req.Password})
 	if err != nil {
-		h.handleAuthError(c, err)
+		handleAuthError(c, err)
 		return
 	}
+	c.Set("auditActor", resp.GetUser().GetId())
 
 	writeJSON(c, http.StatusCreated, map[string]any{"user": convertUser(resp.GetUser())})
 }
@@ -87,28 +152,44 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeout)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
 	defer cancel()
 
+	c.Set("auditActor", req.Email)
+	ctx = auth.WithClient(ctx, c.Request.UserAgent(), c.ClientIP())
 	resp, err := h.client.Login(ctx, &authv1.LoginRequest{Email: req.Email, Password: req.Password})
 	if err != nil {
-		h.handleAuthError(c, err)
+		handleAuthError(c, err)
+		return
+	}
+	if resp.GetTwoFactorRequired() {
+		// No cookie until the second factor is checked by TwoFactorChallenge.
+		writeJSON(c, http.StatusAccepted, map[string]any{
+			"status":          "2fa_required",
+			"challenge_token": resp.GetChallengeToken(),
+		})
 		return
 	}
+	h.completeLogin(c, resp.GetAccessToken(), resp.GetRefreshToken(), resp.GetUser())
+}
+
+// completeLogin sets the session cookie and returns the refresh token.
+func (h *AuthHandler) completeLogin(c *gin.Context, accessToken, refreshToken string, user *authv1.User) {
+	c.Set("auditActor", user.GetId())
 	c.SetSameSite(http.SameSiteLaxMode)
 	c.SetCookie(
-		"jwt",
-		resp.GetAccessToken(),
+		sessionCookie,
+		accessToken,
 		maxAgeSeconds(h.tokenTTL),
-		"/",
+		sessionCookiePath,
 		"",
-		false,
+		sessionCookieSecure,
 		true,
 	)
 
 	writeJSON(c, http.StatusOK, map[string]any{
-		"refresh_token": resp.GetRefreshToken(),
-		"user":          convertUser(resp.GetUser()),
+		"refresh_token": refreshToken,
+		"user":          convertUser(user),
 	})
 }
 
@@ -122,26 +203,27 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		writeError(c, http.StatusBadRequest, "refresh_token is required")
 		return
 	}
-	accessToken, _ := c.Cookie("jwt")
+	accessToken, _ := c.Cookie(sessionCookie)
 	accessToken = strings.TrimSpace(accessToken)
-	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeout)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
 	defer cancel()
 
+	ctx = auth.WithClient(ctx, c.Request.UserAgent(), c.ClientIP())
 	resp, err := h.client.RefreshToken(ctx, &authv1.RefreshTokenRequest{
 		AccessToken:  accessToken,
 		RefreshToken: req.RefreshToken,
 	})
 	if err != nil {
-		h.handleAuthError(c, err)
+		handleAuthError(c, err)
 		return
 	}
 	c.SetCookie(
-		"jwt",
+		sessionCookie,
 		resp.GetAccessToken(),
 		maxAgeSeconds(h.tokenTTL),
-		"/",
+		sessionCookiePath,
 		"",
-		false,
+		sessionCookieSecure,
 		true,
 	)
 	writeJSON(c, http.StatusOK, map[string]any{
@@ -159,9 +241,9 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 		writeError(c, http.StatusBadRequest, "refresh_token is required")
 		return
 	}
-	accessToken, _ := c.Cookie("jwt")
+	accessToken, _ := c.Cookie(sessionCookie)
 	accessToken = strings.TrimSpace(accessToken)
-	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeout)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
 	defer cancel()
 
 	_, err := h.client.Logout(ctx, &authv1.LogoutRequest{
@@ -169,10 +251,11 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 		RefreshToken: req.RefreshToken,
 	})
 	if err != nil {
-		h.handleAuthError(c, err)
+		handleAuthError(c, err)
 		return
 	}
-	c.SetCookie("jwt", "", -1, "/", "", false, true)
+	h.endSession(ctx, currentUserID(c), c.GetString("sessionID"))
+	c.SetCookie(sessionCookie, "", -1, sessionCookiePath, "", sessionCookieSecure, true)
 	c.Status(http.StatusNoContent)
 }
 
@@ -182,34 +265,388 @@ func (h *AuthHandler) GetUser(c *gin.Context) {
 		writeError(c, http.StatusBadRequest, "user id is required")
 		return
 	}
-	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeout)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
 	defer cancel()
 
 	resp, err := h.client.GetUser(ctx, &authv1.GetUserRequest{UserId: userID})
 	if err != nil {
-		h.handleAuthError(c, err)
+		handleAuthError(c, err)
 		return
 	}
 	writeJSON(c, http.StatusOK, map[string]any{"user": convertUser(resp.GetUser())})
 }
 
+// Me returns the caller's account from the auth service along with what the
+// gateway read from their token, so frontends don't decode the JWT.
+func (h *AuthHandler) Me(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
+	defer cancel()
+
+	resp, err := h.client.GetUser(ctx, &authv1.GetUserRequest{UserId: currentUserID(c)})
+	if err != nil {
+		handleAuthError(c, err)
+		return
+	}
+	token := map[string]any{}
+	if expiresAt := c.GetTime("tokenExpiresAt"); !expiresAt.IsZero() {
+		token["expires_at"] = expiresAt.UTC().Format(time.RFC3339)
+		token["expires_in"] = max(int(time.Until(expiresAt).Seconds()), 0)
+	}
+	if plan := c.GetString("userPlan"); plan != "" {
+		token["plan"] = plan
+	}
+	if orgID := c.GetString("orgID"); orgID != "" {
+		token["org_id"] = orgID
+		token["org_role"] = c.GetString("orgRole")
+	}
+	if admin := c.GetString("impersonatedBy"); admin != "" {
+		token["impersonated_by"] = admin
+	}
+	writeJSON(c, http.StatusOK, map[string]any{"user": convertUser(resp.GetUser()), "token": token})
+}
+
 func (h *AuthHandler) IsAdmin(c *gin.Context) {
 	userID := strings.TrimSpace(c.Param("id"))
 	if userID == "" {
 		writeError(c, http.StatusBadRequest, "user id is required")
 		return
 	}
-	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeout)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
 	defer cancel()
 
 	resp, err := h.client.IsAdmin(ctx, &authv1.IsAdminRequest{UserId: userID})
 	if err != nil {
-		h.handleAuthError(c, err)
+		handleAuthError(c, err)
 		return
 	}
 	writeJSON(c, http.StatusOK, map[string]any{"is_admin": resp.GetIsAdmin()})
 }
 
+// ForgotPassword asks the auth service to email a reset link. The response is
+// the same whether or not the account exists.
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req forgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "invalid json payload")
+		return
+	}
+	req.Email = strings.TrimSpace(req.Email)
+	if req.Email == "" {
+		writeError(c, http.StatusBadRequest, "email is required")
+		return
+	}
+	deadline := time.Now().Add(h.forgotDelay)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
+	defer cancel()
+
+	_, err := h.client.ForgotPassword(ctx, &authv1.ForgotPasswordRequest{Email: req.Email})
+	if err != nil && status.Code(err) != codes.NotFound {
+		handleAuthError(c, err)
+		return
+	}
+	if wait := time.Until(deadline); wait > 0 {
+		select {
+		case <-time.After(wait):
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+	writeJSON(c, http.StatusAccepted, map[string]any{
+		"message": "if an account with this email exists, a password reset link has been sent",
+	})
+}
+
+// ResetPassword sets a new password with the token from the reset email. The
+// auth service ends the user's sessions, so the gateway drops its cookie too.
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req resetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "invalid json payload")
+		return
+	}
+	if req.Token == "" || req.Password == "" {
+		writeError(c, http.StatusBadRequest, "token and password are required")
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
+	defer cancel()
+
+	if _, err := h.client.ResetPassword(ctx, &authv1.ResetPasswordRequest{Token: req.Token, NewPassword: req.Password}); err != nil {
+		handleTokenError(c, err)
+		return
+	}
+	c.SetCookie(sessionCookie, "", -1, sessionCookiePath, "", sessionCookieSecure, true)
+	c.Status(http.StatusNoContent)
+}
+
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req verifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "invalid json payload")
+		return
+	}
+	if req.Token == "" {
+		writeError(c, http.StatusBadRequest, "token is required")
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
+	defer cancel()
+
+	resp, err := h.client.VerifyEmail(ctx, &authv1.VerifyEmailRequest{Token: req.Token})
+	if err != nil {
+		handleTokenError(c, err)
+		return
+	}
+	c.Set("auditActor", resp.GetUser().GetId())
+	writeJSON(c, http.StatusOK, map[string]any{"user": convertUser(resp.GetUser())})
+}
+
+// ChangePassword sets a new password for the caller and signs them out
+// everywhere.
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	var req changePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "invalid json payload")
+		return
+	}
+	if req.CurrentPassword == "" || req.NewPassword == "" {
+		writeError(c, http.StatusBadRequest, "current_password and new_password are required")
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
+	defer cancel()
+
+	userID := currentUserID(c)
+	_, err := h.client.ChangePassword(ctx, &authv1.ChangePasswordRequest{
+		UserId:          userID,
+		CurrentPassword: req.CurrentPassword,
+		NewPassword:     req.NewPassword,
+	})
+	if err != nil {
+		handleAuthError(c, err)
+		return
+	}
+	h.endSessions(ctx, c, userID)
+	c.Status(http.StatusNoContent)
+}
+
+// ChangeEmail moves the caller's account to a new email and signs them out
+// everywhere; the auth service asks them to verify the new address.
+func (h *AuthHandler) ChangeEmail(c *gin.Context) {
+	var req changeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "invalid json payload")
+		return
+	}
+	req.NewEmail = strings.TrimSpace(req.NewEmail)
+	if req.Password == "" || req.NewEmail == "" {
+		writeError(c, http.StatusBadRequest, "password and new_email are required")
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
+	defer cancel()
+
+	userID := currentUserID(c)
+	resp, err := h.client.ChangeEmail(ctx, &authv1.ChangeEmailRequest{
+		UserId:   userID,
+		Password: req.Password,
+		NewEmail: req.NewEmail,
+	})
+	if err != nil {
+		handleAuthError(c, err)
+		return
+	}
+	h.endSessions(ctx, c, userID)
+	writeJSON(c, http.StatusOK, map[string]any{"user": convertUser(resp.GetUser())})
+}
+
+// Sessions lists the caller's signed-in devices. The one making the request
+// is marked current when its token names its session.
+func (h *AuthHandler) Sessions(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
+	defer cancel()
+
+	resp, err := h.client.ListSessions(ctx, &authv1.ListSessionsRequest{UserId: currentUserID(c)})
+	if err != nil {
+		handleAuthError(c, err)
+		return
+	}
+	current := c.GetString("sessionID")
+	sessions := make([]sessionResponse, 0, len(resp.GetSessions()))
+	for _, s := range resp.GetSessions() {
+		res := sessionResponse{
+			ID:        s.GetId(),
+			UserAgent: s.GetUserAgent(),
+			IP:        s.GetIp(),
+			Current:   current != "" && s.GetId() == current,
+		}
+		if ts := s.GetCreatedAt(); ts != nil {
+			res.CreatedAt = ts.AsTime().Format(time.RFC3339)
+		}
+		if ts := s.GetLastUsedAt(); ts != nil {
+			res.LastUsedAt = ts.AsTime().Format(time.RFC3339)
+		}
+		sessions = append(sessions, res)
+	}
+	writeJSON(c, http.StatusOK, map[string]any{"sessions": sessions})
+}
+
+// RevokeSession signs one of the caller's devices out: its refresh token stops
+// working, and its access tokens and live streams end on every replica.
+// Revoking the current session also drops the caller's cookie.
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	sessionID := strings.TrimSpace(c.Param("id"))
+	if sessionID == "" {
+		writeError(c, http.StatusBadRequest, "session id is required")
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
+	defer cancel()
+
+	_, err := h.client.RevokeSession(ctx, &authv1.RevokeSessionRequest{UserId: currentUserID(c), SessionId: sessionID})
+	if err != nil {
+		handleAuthError(c, err)
+		return
+	}
+	h.endSession(ctx, currentUserID(c), sessionID)
+	if sessionID == c.GetString("sessionID") {
+		c.SetCookie(sessionCookie, "", -1, sessionCookiePath, "", sessionCookieSecure, true)
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// TwoFactorSetup starts enrolling the caller's authenticator app. The secret
+// is only active after TwoFactorVerify.
+func (h *AuthHandler) TwoFactorSetup(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
+	defer cancel()
+
+	resp, err := h.client.SetupTwoFactor(ctx, &authv1.SetupTwoFactorRequest{UserId: currentUserID(c)})
+	if err != nil {
+		handleAuthError(c, err)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	writeJSON(c, http.StatusOK, map[string]any{
+		"secret":      resp.GetSecret(),
+		"otpauth_url": resp.GetOtpauthUrl(),
+	})
+}
+
+// TwoFactorVerify enables two-factor authentication with a code from the
+// newly enrolled app and returns the one-time recovery codes.
+func (h *AuthHandler) TwoFactorVerify(c *gin.Context) {
+	code, ok := bindTwoFactorCode(c)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
+	defer cancel()
+
+	resp, err := h.client.ConfirmTwoFactor(ctx, &authv1.ConfirmTwoFactorRequest{UserId: currentUserID(c), Code: code})
+	if err != nil {
+		handleAuthError(c, err)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	writeJSON(c, http.StatusOK, map[string]any{"recovery_codes": resp.GetRecoveryCodes()})
+}
+
+func (h *AuthHandler) TwoFactorDisable(c *gin.Context) {
+	code, ok := bindTwoFactorCode(c)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
+	defer cancel()
+
+	_, err := h.client.DisableTwoFactor(ctx, &authv1.DisableTwoFactorRequest{UserId: currentUserID(c), Code: code})
+	if err != nil {
+		handleAuthError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// TwoFactorChallenge finishes a login that Login answered with 2fa_required,
+// using a TOTP or recovery code, and only then sets the session cookie.
+func (h *AuthHandler) TwoFactorChallenge(c *gin.Context) {
+	var req twoFactorChallengeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "invalid json payload")
+		return
+	}
+	req.ChallengeToken = strings.TrimSpace(req.ChallengeToken)
+	req.Code = strings.TrimSpace(req.Code)
+	if req.ChallengeToken == "" || req.Code == "" {
+		writeError(c, http.StatusBadRequest, "challenge_token and code are required")
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
+	defer cancel()
+
+	ctx = auth.WithClient(ctx, c.Request.UserAgent(), c.ClientIP())
+	resp, err := h.client.CompleteTwoFactorLogin(ctx, &authv1.CompleteTwoFactorLoginRequest{
+		ChallengeToken: req.ChallengeToken,
+		Code:           req.Code,
+	})
+	if err != nil {
+		handleAuthError(c, err)
+		return
+	}
+	h.completeLogin(c, resp.GetAccessToken(), resp.GetRefreshToken(), resp.GetUser())
+}
+
+func bindTwoFactorCode(c *gin.Context) (string, bool) {
+	var req twoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "invalid json payload")
+		return "", false
+	}
+	code := strings.TrimSpace(req.Code)
+	if code == "" {
+		writeError(c, http.StatusBadRequest, "code is required")
+		return "", false
+	}
+	return code, true
+}
+
+// endSessions revokes the user's access tokens on every replica and drops the
+// caller's cookie. A failed broadcast is logged: the change itself succeeded
+// and the tokens are still rejected here.
+func (h *AuthHandler) endSessions(ctx context.Context, c *gin.Context, userID string) {
+	if h.revoked != nil {
+		if err := h.revoked.Revoke(ctx, userID); err != nil {
+			h.log.Error("failed to broadcast token revocation", slog.String("user_id", userID), slog.String("err", err.Error()))
+		}
+	}
+	c.SetCookie(sessionCookie, "", -1, sessionCookiePath, "", sessionCookieSecure, true)
+}
+
+// endSession revokes the access tokens of one session of the user on every
+// replica, which also closes its streams. Logouts without a known user or
+// session, e.g. from tokens without a sid claim, only end the refresh token.
+func (h *AuthHandler) endSession(ctx context.Context, userID, sessionID string) {
+	if h.revoked == nil || userID == "" || sessionID == "" {
+		return
+	}
+	if err := h.revoked.RevokeSession(ctx, userID, sessionID); err != nil {
+		h.log.Error("failed to broadcast session revocation", slog.String("user_id", userID), slog.String("session_id", sessionID), slog.String("err", err.Error()))
+	}
+}
+
+// handleTokenError reports every unusable reset or verification token the
+// same way, so callers can't tell unknown, expired and used tokens apart.
+func handleTokenError(c *gin.Context, err error) {
+	switch status.Code(err) {
+	case codes.NotFound, codes.Unauthenticated, codes.PermissionDenied, codes.FailedPrecondition:
+		apierror.Abort(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "invalid or expired token", nil)
+	default:
+		handleAuthError(c, err)
+	}
+}
+
 func maxAgeSeconds(d time.Duration) int {
 	if d <= 0 {
 		return 0
@@ -217,22 +654,33 @@ func maxAgeSeconds(d time.Duration) int {
 	return int(d.Seconds())
 }
 
-func (h *AuthHandler) handleAuthError(c *gin.Context, err error) {
+func handleAuthError(c *gin.Context, err error) {
+	if clientGone(c, err) {
+		return
+	}
+	httpStatus, code, message := authFailure(err)
+	if httpStatus >= http.StatusInternalServerError {
+		c.Error(fmt.Errorf("auth: %w", err))
+	}
+	apierror.Abort(c, httpStatus, code, message, nil)
+}
+
+// authFailure classifies a failed auth service call for clients; the messages
+// of server-side failures are not passed on.
+func authFailure(err error) (int, apierror.Code, string) {
 	sts, ok := status.FromError(err)
-	if ok {
-		switch sts.Code() {
-		case codes.InvalidArgument, codes.Unauthenticated:
-			writeError(c, http.StatusBadRequest, sts.Message())
-			return
-		case codes.NotFound:
-			writeError(c, http.StatusNotFound, sts.Message())
-			return
-		case codes.Unavailable:
-			writeError(c, http.StatusServiceUnavailable, "auth service unavailable")
-			return
-		}
+	if !ok {
+		return http.StatusInternalServerError, apierror.CodeUpstreamError, "auth service error"
+	}
+	httpStatus, code := apierror.FromGRPC(sts.Code())
+	message := sts.Message()
+	switch {
+	case sts.Code() == codes.Unavailable:
+		message = "auth service unavailable"
+	case httpStatus >= http.StatusInternalServerError:
+		message = "auth service error"
 	}
-	writeError(c, http.StatusInternalServerError, "auth service error")
+	return httpStatus, code, message
 }
 
 func convertUser(u *authv1.User) userResponse {
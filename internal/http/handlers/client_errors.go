@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/apierror"
+	"github.com/immxrtalbeast/api-gateway/internal/clienterrors"
+)
+
+// ClientErrorHandler accepts batched error reports from the frontend and
+// hands them to the forwarder, enriched with the caller and request IDs.
+type ClientErrorHandler struct {
+	forwarder  *clienterrors.Forwarder
+	maxBytes   int64
+	maxReports int
+}
+
+func NewClientErrorHandler(forwarder *clienterrors.Forwarder, maxBytes int64, maxReports int) *ClientErrorHandler {
+	return &ClientErrorHandler{forwarder: forwarder, maxBytes: maxBytes, maxReports: maxReports}
+}
+
+type clientErrorReport struct {
+	Level      string    `json:"level"`
+	Name       string    `json:"name"`
+	Message    string    `json:"message"`
+	Stack      string    `json:"stack"`
+	URL        string    `json:"url"`
+	Release    string    `json:"release"`
+	RequestID  string    `json:"request_id"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+type clientErrorBatch struct {
+	Errors []clientErrorReport `json:"errors"`
+}
+
+func (h *ClientErrorHandler) Report(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxBytes)
+	var batch clientErrorBatch
+	if err := json.NewDecoder(c.Request.Body).Decode(&batch); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			apierror.Abort(c, http.StatusRequestEntityTooLarge, apierror.CodePayloadTooLarge, "report batch too large", map[string]any{"max_bytes": h.maxBytes})
+			return
+		}
+		writeError(c, http.StatusBadRequest, "invalid json payload")
+		return
+	}
+	if len(batch.Errors) == 0 {
+		apierror.Abort(c, http.StatusUnprocessableEntity, apierror.CodeValidationFailed, "request validation failed", map[string]any{
+			"fields": []apierror.FieldError{{Field: "/errors", Message: "must contain at least one report"}},
+		})
+		return
+	}
+	if len(batch.Errors) > h.maxReports {
+		apierror.Abort(c, http.StatusUnprocessableEntity, apierror.CodeValidationFailed, "request validation failed", map[string]any{
+			"fields": []apierror.FieldError{{Field: "/errors", Message: "must contain at most " + strconv.Itoa(h.maxReports) + " reports"}},
+		})
+		return
+	}
+
+	now := time.Now().UTC()
+	reports := make([]clienterrors.Report, 0, len(batch.Errors))
+	for _, r := range batch.Errors {
+		report := clienterrors.Report{
+			ReceivedAt:      now,
+			Level:           r.Level,
+			Name:            r.Name,
+			Message:         r.Message,
+			Stack:           r.Stack,
+			URL:             r.URL,
+			Release:         r.Release,
+			ClientRequestID: r.RequestID,
+			UserID:          currentUserID(c),
+			ImpersonatedBy:  c.GetString("impersonatedBy"),
+			RequestID:       c.Writer.Header().Get(apierror.RequestIDHeader),
+			IP:              c.ClientIP(),
+			UserAgent:       c.Request.UserAgent(),
+		}
+		if !r.OccurredAt.IsZero() {
+			at := r.OccurredAt.UTC()
+			report.OccurredAt = &at
+		}
+		reports = append(reports, report.Sanitize())
+	}
+	accepted := h.forwarder.Enqueue(reports)
+	writeJSON(c, http.StatusAccepted, map[string]any{
+		"accepted":   accepted,
+		"dropped":    len(reports) - accepted,
+		"request_id": c.Writer.Header().Get(apierror.RequestIDHeader),
+	})
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/acl"
+	"github.com/immxrtalbeast/api-gateway/internal/clients/videos"
+	"github.com/immxrtalbeast/api-gateway/internal/reload"
+)
+
+// CollaboratorHandler manages per-video grants. Only the video owner may
+// change them; ownership is confirmed with the video service on first grant.
+type CollaboratorHandler struct {
+	log     *slog.Logger
+	store   *acl.Store
+	client  *videos.Client
+	timeout *reload.Value[time.Duration]
+}
+
+func NewCollaboratorHandler(log *slog.Logger, store *acl.Store, client *videos.Client, timeout time.Duration) *CollaboratorHandler {
+	return &CollaboratorHandler{log: log, store: store, client: client, timeout: reload.NewValue(timeout)}
+}
+
+// SetTimeout changes the upstream call timeout of the following requests.
+func (h *CollaboratorHandler) SetTimeout(timeout time.Duration) {
+	h.timeout.Store(timeout)
+}
+
+type grantRequest struct {
+	UserID string   `json:"user_id"`
+	Role   acl.Role `json:"role"`
+}
+
+type collaborator struct {
+	UserID string   `json:"user_id"`
+	Role   acl.Role `json:"role"`
+}
+
+func (h *CollaboratorHandler) Grant(c *gin.Context) {
+	videoID := c.Param("id")
+	var req grantRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "invalid json payload")
+		return
+	}
+	req.UserID = strings.TrimSpace(req.UserID)
+	if req.UserID == "" || !req.Role.Valid() {
+		writeError(c, http.StatusBadRequest, `user_id and role ("view" or "edit") are required`)
+		return
+	}
+	owner := currentUserID(c)
+	if req.UserID == owner {
+		writeError(c, http.StatusBadRequest, "owner can't be a collaborator")
+		return
+	}
+	if !h.ensureOwner(c, videoID, owner, "only the video owner can manage collaborators") {
+		return
+	}
+	if err := h.store.Grant(videoID, owner, req.UserID, req.Role); err != nil {
+		h.log.Error("grant collaborator failed", slog.String("video_id", videoID), slog.String("err", err.Error()))
+		writeError(c, http.StatusInternalServerError, "failed to save collaborator")
+		return
+	}
+	h.log.Info("collaborator granted",
+		slog.String("video_id", videoID),
+		slog.String("owner", owner),
+		slog.String("user_id", req.UserID),
+		slog.String("role", string(req.Role)),
+	)
+	writeJSON(c, http.StatusCreated, map[string]any{"video_id": videoID, "user_id": req.UserID, "role": req.Role})
+}
+
+func (h *CollaboratorHandler) List(c *gin.Context) {
+	videoID := c.Param("id")
+	video, ok := h.store.Get(videoID)
+	if ok && video.Owner != currentUserID(c) {
+		writeError(c, http.StatusForbidden, "only the video owner can manage collaborators")
+		return
+	}
+	res := make([]collaborator, 0, len(video.Collaborators))
+	for userID, role := range video.Collaborators {
+		res = append(res, collaborator{UserID: userID, Role: role})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].UserID < res[j].UserID })
+	writeJSON(c, http.StatusOK, map[string]any{"video_id": videoID, "collaborators": res})
+}
+
+func (h *CollaboratorHandler) Revoke(c *gin.Context) {
+	videoID := c.Param("id")
+	if owner, ok := h.store.Owner(videoID); ok && owner != currentUserID(c) {
+		writeError(c, http.StatusForbidden, "only the video owner can manage collaborators")
+		return
+	}
+	removed, err := h.store.Revoke(videoID, c.Param("user_id"))
+	if err != nil {
+		h.log.Error("revoke collaborator failed", slog.String("video_id", videoID), slog.String("err", err.Error()))
+		writeError(c, http.StatusInternalServerError, "failed to save collaborators")
+		return
+	}
+	if !removed {
+		writeError(c, http.StatusNotFound, "collaborator not found")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ensureOwner checks that userID owns videoID, either from an earlier grant
+// or by fetching the video as that user, and answers forbidden otherwise.
+func (h *CollaboratorHandler) ensureOwner(c *gin.Context, videoID, userID, forbidden string) bool {
+	if owner, ok := h.store.Owner(videoID); ok {
+		if owner != userID {
+			writeError(c, http.StatusForbidden, forbidden)
+			return false
+		}
+		return true
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
+	defer cancel()
+
+	resp, err := h.client.GetVideo(ctx, videoID, map[string]string{"X-User-ID": userID})
+	if err != nil {
+		if clientGone(c, err) {
+			return false
+		}
+		h.log.Error("video ownership check failed", slog.String("err", err.Error()))
+		writeUpstreamError(c, "video", err)
+		return false
+	}
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return true
+	case resp.StatusCode >= http.StatusInternalServerError:
+		writeError(c, http.StatusBadGateway, "video service error")
+	default:
+		writeError(c, http.StatusNotFound, "video not found")
+	}
+	return false
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/apierror"
+)
+
+// Upgrade advice returned by the compatibility check.
+const (
+	UpgradeNone        = "none"
+	UpgradeRecommended = "recommended"
+	UpgradeRequired    = "required"
+)
+
+// CompatVersions are the supported client versions. Clients below
+// MinVersion must upgrade, clients below LatestVersion should.
+type CompatVersions struct {
+	MinVersion    string
+	LatestVersion string
+}
+
+// CompatNotice announces a deprecation. With UntilVersion it only concerns
+// clients older than that version, i.e. those still using the old behavior.
+type CompatNotice struct {
+	ID           string   `json:"id"`
+	Message      string   `json:"message"`
+	Routes       []string `json:"routes,omitempty"`
+	Sunset       string   `json:"sunset,omitempty"`
+	UntilVersion string   `json:"-"`
+}
+
+type CompatOptions struct {
+	CompatVersions
+	// Platforms overrides the versions for clients passing ?platform=.
+	Platforms    map[string]CompatVersions
+	Capabilities []string
+	Notices      []CompatNotice
+}
+
+// CompatHandler tells clients before they call anything else whether their
+// version is still supported, what is being removed and which capabilities
+// the gateway expects from them.
+type CompatHandler struct {
+	opts CompatOptions
+}
+
+func NewCompatHandler(opts CompatOptions) *CompatHandler {
+	return &CompatHandler{opts: opts}
+}
+
+func (h *CompatHandler) Check(c *gin.Context) {
+	platform := strings.ToLower(strings.TrimSpace(c.Query("platform")))
+	versions := h.opts.CompatVersions
+	if override, ok := h.opts.Platforms[platform]; ok {
+		if override.MinVersion != "" {
+			versions.MinVersion = override.MinVersion
+		}
+		if override.LatestVersion != "" {
+			versions.LatestVersion = override.LatestVersion
+		}
+	}
+
+	resp := map[string]any{
+		"min_supported_version": versions.MinVersion,
+		"latest_version":        versions.LatestVersion,
+		"required_capabilities": nonNil(h.opts.Capabilities),
+	}
+	if platform != "" {
+		resp["platform"] = platform
+	}
+
+	clientVersion := strings.TrimSpace(c.Query("client_version"))
+	if clientVersion == "" {
+		resp["deprecations"] = h.notices(nil)
+		c.Header("Cache-Control", "public, max-age=300")
+		writeJSON(c, http.StatusOK, resp)
+		return
+	}
+	client, ok := parseVersion(clientVersion)
+	if !ok {
+		apierror.Abort(c, http.StatusUnprocessableEntity, apierror.CodeValidationFailed, "request validation failed", map[string]any{
+			"fields": []apierror.FieldError{{Field: "client_version", Message: "must be a version like 1.4.2"}},
+		})
+		return
+	}
+	upgrade := UpgradeNone
+	if older(client, versions.LatestVersion) {
+		upgrade = UpgradeRecommended
+	}
+	if older(client, versions.MinVersion) {
+		upgrade = UpgradeRequired
+	}
+	resp["client_version"] = clientVersion
+	resp["supported"] = upgrade != UpgradeRequired
+	resp["upgrade"] = upgrade
+	resp["deprecations"] = h.notices(client)
+	c.Header("Cache-Control", "public, max-age=300")
+	writeJSON(c, http.StatusOK, resp)
+}
+
+// notices returns the deprecations relevant to client; a nil client gets all.
+func (h *CompatHandler) notices(client *version) []CompatNotice {
+	out := make([]CompatNotice, 0, len(h.opts.Notices))
+	for _, n := range h.opts.Notices {
+		if client == nil || n.UntilVersion == "" || older(client, n.UntilVersion) {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+// version is a dotted numeric version with an optional pre-release suffix,
+// e.g. "v1.4.2-beta.1". Build metadata after "+" is ignored.
+type version struct {
+	parts      []int
+	prerelease string
+}
+
+func parseVersion(s string) (*version, bool) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	s, _, _ = strings.Cut(s, "+")
+	s, pre, _ := strings.Cut(s, "-")
+	if s == "" {
+		return nil, false
+	}
+	v := &version{prerelease: pre}
+	for _, part := range strings.Split(s, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		v.parts = append(v.parts, n)
+	}
+	return v, true
+}
+
+// older reports whether v is before the version ref. An empty or invalid ref
+// never makes a client older.
+func older(v *version, ref string) bool {
+	r, ok := parseVersion(ref)
+	if !ok {
+		return false
+	}
+	for i := 0; i < max(len(v.parts), len(r.parts)); i++ {
+		var a, b int
+		if i < len(v.parts) {
+			a = v.parts[i]
+		}
+		if i < len(r.parts) {
+			b = r.parts[i]
+		}
+		if a != b {
+			return a < b
+		}
+	}
+	// A pre-release comes before its release.
+	switch {
+	case v.prerelease != "" && r.prerelease == "":
+		return true
+	case v.prerelease == "" || r.prerelease == "":
+		return false
+	default:
+		return v.prerelease < r.prerelease
+	}
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/apierror"
+	"github.com/immxrtalbeast/api-gateway/internal/credits"
+	"github.com/immxrtalbeast/api-gateway/internal/usage"
+)
+
+// CreditsHandler reports a user's render credit balance for the current
+// month with the latest charges.
+type CreditsHandler struct {
+	log     *slog.Logger
+	store   usage.Store
+	quotas  usage.Quotas
+	ledger  *credits.Ledger
+	timeout time.Duration
+}
+
+func NewCreditsHandler(log *slog.Logger, store usage.Store, quotas usage.Quotas, ledger *credits.Ledger, timeout time.Duration) *CreditsHandler {
+	return &CreditsHandler{log: log, store: store, quotas: quotas, ledger: ledger, timeout: timeout}
+}
+
+// Credits serves GET /api/users/:id/credits. Users can only read their own
+// balance; "me" stands for the caller.
+func (h *CreditsHandler) Credits(c *gin.Context) {
+	userID := currentUserID(c)
+	if id := c.Param("id"); id != "me" && id != userID {
+		apierror.Abort(c, http.StatusForbidden, apierror.CodePermissionDenied, "cannot read another user's credits", nil)
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeout)
+	defer cancel()
+
+	now := time.Now()
+	period := usage.Period(now)
+	counters, err := h.store.Get(ctx, userID, period)
+	if err != nil {
+		h.log.Error("get credits failed", slog.String("err", err.Error()))
+		writeError(c, http.StatusServiceUnavailable, "usage store unavailable")
+		return
+	}
+
+	plan := c.GetString("userPlan")
+	if plan == "" {
+		plan = h.quotas.DefaultPlan
+	}
+	used := max(counters[usage.Credits], 0)
+	resp := map[string]any{
+		"user_id":   userID,
+		"period":    period,
+		"plan":      plan,
+		"used":      used,
+		"charges":   h.ledger.Recent(userID),
+		"resets_at": usage.PeriodEnd(now).Format(time.RFC3339),
+	}
+	// Allowance and remaining are omitted for unlimited plans.
+	if allowance := h.quotas.Limit(plan, usage.Credits); allowance > 0 {
+		resp["allowance"] = allowance
+		resp["remaining"] = max(allowance-used, 0)
+	}
+	writeJSON(c, http.StatusOK, resp)
+}
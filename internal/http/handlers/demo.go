@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/apierror"
+	"github.com/immxrtalbeast/api-gateway/internal/clients/videos"
+	"github.com/immxrtalbeast/api-gateway/internal/masking"
+	"github.com/immxrtalbeast/api-gateway/lib/jsonpath"
+)
+
+// DemoDeviceHeader tells the video service which anonymous device a demo job
+// belongs to.
+const DemoDeviceHeader = "X-Demo-Device"
+
+// demoCookiePath covers the demo routes under every version prefix,
+// /api/v1/demo as well as /api/demo.
+const demoCookiePath = "/api"
+
+// DemoOptions configures anonymous demo jobs. Zero values fall back to a
+// "demo_device" cookie, a 24h time box, one job per device and three per IP
+// a day, owned upstream by the "demo" user.
+type DemoOptions struct {
+	// Secret signs the device cookie.
+	Secret     string
+	CookieName string
+	// TTL is both the device cookie lifetime and how long a demo job stays
+	// visible to its device.
+	TTL           time.Duration
+	JobsPerDevice int
+	// JobsPerIP stops visitors from clearing the cookie to get more demos.
+	// It counts per client IP, which only trusted proxies can set, and per
+	// /64 for IPv6, where a visitor holds the whole prefix.
+	JobsPerIP    int
+	IPWindow     time.Duration
+	UserID       string
+	SecureCookie bool
+	Timeout      time.Duration
+}
+
+// DemoHandler lets unauthenticated visitors create a watermarked demo video
+// and poll it. Devices are identified by a signed cookie; quotas and job
+// ownership are kept in memory.
+type DemoHandler struct {
+	log    *slog.Logger
+	client *videos.Client
+	masker *masking.Masker
+	opts   DemoOptions
+
+	mu      sync.Mutex
+	devices map[string][]*demoJob
+	ips     map[string][]time.Time
+}
+
+type demoJob struct {
+	id      string
+	expires time.Time
+}
+
+func NewDemoHandler(log *slog.Logger, client *videos.Client, masker *masking.Masker, opts DemoOptions) *DemoHandler {
+	if opts.CookieName == "" {
+		opts.CookieName = "demo_device"
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = 24 * time.Hour
+	}
+	if opts.JobsPerDevice <= 0 {
+		opts.JobsPerDevice = 1
+	}
+	if opts.JobsPerIP <= 0 {
+		opts.JobsPerIP = 3
+	}
+	if opts.IPWindow <= 0 {
+		opts.IPWindow = 24 * time.Hour
+	}
+	if opts.UserID == "" {
+		opts.UserID = "demo"
+	}
+	return &DemoHandler{
+		log:     log,
+		client:  client,
+		masker:  masker,
+		opts:    opts,
+		devices: make(map[string][]*demoJob),
+		ips:     make(map[string][]time.Time),
+	}
+}
+
+// CreateVideo starts a demo job. The body is the regular create payload; the
+// gateway marks it as a watermarked demo expiring with the time box and runs
+// it at low priority.
+func (h *DemoHandler) CreateVideo(c *gin.Context) {
+	body, err := readJSONBody(c.Request.Body)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, "failed to read request body")
+		return
+	}
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(body, &doc); err != nil || doc == nil {
+		writeError(c, http.StatusBadRequest, "invalid json payload")
+		return
+	}
+
+	device := h.device(c)
+	now := time.Now()
+	job, ok := h.reserve(c, device, demoNetwork(c.ClientIP()), now)
+	if !ok {
+		return
+	}
+	expires := job.expires
+	doc["demo"], _ = json.Marshal(true)
+	doc["watermark"], _ = json.Marshal(true)
+	doc["expires_at"], _ = json.Marshal(expires.UTC().Format(time.RFC3339))
+	doc["priority"], _ = json.Marshal("low")
+	payload, err := json.Marshal(doc)
+	if err != nil {
+		h.release(device, job)
+		writeError(c, http.StatusInternalServerError, "failed to encode request")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.opts.Timeout)
+	defer cancel()
+	resp, err := h.client.CreateVideo(ctx, payload, h.headers(device))
+	if err != nil {
+		h.release(device, job)
+		if clientGone(c, err) {
+			return
+		}
+		h.log.Error("demo video create failed", slog.String("err", err.Error()))
+		writeUpstreamError(c, "video", err)
+		return
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		h.release(device, job)
+	} else {
+		id, _ := jsonpath.String(resp.Body, "id")
+		h.confirm(job, id)
+	}
+	c.Header("X-Demo-Expires", expires.UTC().Format(time.RFC3339))
+	h.forward(c, resp)
+}
+
+// GetVideo returns a demo job to the device that created it, until it
+// expires.
+func (h *DemoHandler) GetVideo(c *gin.Context) {
+	device, ok := h.verifiedDevice(c)
+	videoID := c.Param("id")
+	if !ok || !h.owns(device, videoID, time.Now()) {
+		apierror.Abort(c, http.StatusNotFound, apierror.CodeNotFound, "demo video not found", nil)
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.opts.Timeout)
+	defer cancel()
+	resp, err := h.client.GetVideo(ctx, videoID, h.headers(device))
+	if err != nil {
+		if clientGone(c, err) {
+			return
+		}
+		h.log.Error("get demo video failed", slog.String("err", err.Error()))
+		writeUpstreamError(c, "video", err)
+		return
+	}
+	h.forward(c, resp)
+}
+
+func (h *DemoHandler) headers(device string) map[string]string {
+	return map[string]string{
+		"X-User-ID":           h.opts.UserID,
+		DemoDeviceHeader:      device,
+		RequestPriorityHeader: "low",
+	}
+}
+
+// device returns the caller's device ID, issuing a new signed cookie when the
+// request has none or its signature doesn't verify.
+func (h *DemoHandler) device(c *gin.Context) string {
+	if device, ok := h.verifiedDevice(c); ok {
+		return device
+	}
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	device := hex.EncodeToString(buf)
+	c.SetSameSite(http.SameSiteNoneMode)
+	c.SetCookie(h.opts.CookieName, device+"."+h.sign(device), int(h.opts.TTL.Seconds()), demoCookiePath, "", h.opts.SecureCookie, true)
+	return device
+}
+
+func (h *DemoHandler) verifiedDevice(c *gin.Context) (string, bool) {
+	value, err := c.Cookie(h.opts.CookieName)
+	if err != nil {
+		return "", false
+	}
+	device, sig, ok := strings.Cut(value, ".")
+	if !ok || device == "" || !hmac.Equal([]byte(sig), []byte(h.sign(device))) {
+		return "", false
+	}
+	return device, true
+}
+
+func (h *DemoHandler) sign(device string) string {
+	mac := hmac.New(sha256.New, []byte(h.opts.Secret))
+	mac.Write([]byte(device))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// reserve takes a device and an IP slot before the upstream call so
+// concurrent requests can't exceed the quotas. It aborts with 429 when either
+// is used up.
+func (h *DemoHandler) reserve(c *gin.Context, device, ip string, now time.Time) (*demoJob, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.pruneLocked(now)
+
+	jobs := h.devices[device]
+	if len(jobs) >= h.opts.JobsPerDevice {
+		retryAt := jobs[0].expires
+		c.Header("Retry-After", strconv.Itoa(int(retryAt.Sub(now).Seconds())+1))
+		apierror.Abort(c, http.StatusTooManyRequests, apierror.CodeQuotaExceeded, "demo limit reached, sign up to create more videos", map[string]any{
+			"limit":     h.opts.JobsPerDevice,
+			"resets_at": retryAt.UTC().Format(time.RFC3339),
+		})
+		return nil, false
+	}
+	recent := h.ips[ip]
+	if len(recent) >= h.opts.JobsPerIP {
+		retryAt := recent[0].Add(h.opts.IPWindow)
+		c.Header("Retry-After", strconv.Itoa(int(retryAt.Sub(now).Seconds())+1))
+		apierror.Abort(c, http.StatusTooManyRequests, apierror.CodeRateLimited, "too many demo videos from this network", map[string]any{
+			"limit":     h.opts.JobsPerIP,
+			"resets_at": retryAt.UTC().Format(time.RFC3339),
+		})
+		return nil, false
+	}
+	h.ips[ip] = append(recent, now)
+	job := &demoJob{expires: now.Add(h.opts.TTL)}
+	h.devices[device] = append(jobs, job)
+	return job, true
+}
+
+// demoNetwork is the key JobsPerIP counts ip under.
+func demoNetwork(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil || addr.Unmap().Is4() {
+		return ip
+	}
+	prefix, _ := addr.Prefix(64)
+	return prefix.String()
+}
+
+// release gives the device slot back after a failed create. The IP slot is
+// kept so failing requests still count against the network.
+func (h *DemoHandler) release(device string, job *demoJob) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	jobs := h.devices[device]
+	for i, j := range jobs {
+		if j == job {
+			h.devices[device] = append(jobs[:i:i], jobs[i+1:]...)
+			return
+		}
+	}
+}
+
+func (h *DemoHandler) confirm(job *demoJob, id string) {
+	h.mu.Lock()
+	job.id = id
+	h.mu.Unlock()
+}
+
+func (h *DemoHandler) owns(device, videoID string, now time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, job := range h.devices[device] {
+		if job.id != "" && job.id == videoID && now.Before(job.expires) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *DemoHandler) pruneLocked(now time.Time) {
+	for device, jobs := range h.devices {
+		kept := jobs[:0]
+		for _, job := range jobs {
+			if now.Before(job.expires) {
+				kept = append(kept, job)
+			}
+		}
+		if len(kept) == 0 {
+			delete(h.devices, device)
+		} else {
+			h.devices[device] = kept
+		}
+	}
+	for ip, times := range h.ips {
+		i := 0
+		for i < len(times) && now.Sub(times[i]) >= h.opts.IPWindow {
+			i++
+		}
+		if i == len(times) {
+			delete(h.ips, ip)
+		} else {
+			h.ips[ip] = times[i:]
+		}
+	}
+}
+
+func (h *DemoHandler) forward(c *gin.Context, resp *videos.Response) {
+	if err := writeUpstream(c, h.masker, resp.StatusCode, resp.Header, resp.Body); err != nil {
+		markAbandoned(c)
+		c.Error(err)
+	}
+}
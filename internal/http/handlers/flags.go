@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/flags"
+)
+
+// FlagsHandler serves GET /api/flags, the feature flags of the caller, so
+// clients show the same features the gateway lets through. The route must
+// be guarded by the FeatureFlags middleware.
+type FlagsHandler struct {
+	set *flags.Set
+}
+
+func NewFlagsHandler(set *flags.Set) *FlagsHandler {
+	return &FlagsHandler{set: set}
+}
+
+func (h *FlagsHandler) List(c *gin.Context) {
+	values := make(map[string]bool)
+	for _, name := range h.set.Names() {
+		values[name] = flags.Enabled(c.Request.Context(), name)
+	}
+	writeJSON(c, http.StatusOK, gin.H{"flags": values})
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/apierror"
+	"github.com/immxrtalbeast/api-gateway/internal/clients/auth"
+	"github.com/immxrtalbeast/api-gateway/internal/clients/scripts"
+	"github.com/immxrtalbeast/api-gateway/internal/clients/videos"
+	"github.com/immxrtalbeast/api-gateway/internal/graphql"
+	"github.com/immxrtalbeast/api-gateway/internal/masking"
+	"github.com/immxrtalbeast/api-gateway/internal/reload"
+	authv1 "github.com/immxrtalbeast/protos/gen/go/auth/v1"
+)
+
+// GraphQLHandler serves /api/graphql, a read-only view of the current user,
+// their videos with their stages and their scripts in one round trip. The
+// root fields of a query are fetched concurrently, each from its service; a
+// failing service nulls its field only.
+//
+//	query { me { email } videos { id stage } scripts { id title } }
+type GraphQLHandler struct {
+	log           *slog.Logger
+	auth          auth.Client
+	videos        *videos.Client
+	scripts       *scripts.Client
+	videoMasker   *masking.Masker
+	scriptMasker  *masking.Masker
+	maxDepth      int
+	maxRootFields int
+	authTimeout   *reload.Value[time.Duration]
+	videoTimeout  *reload.Value[time.Duration]
+	scriptTimeout *reload.Value[time.Duration]
+}
+
+func NewGraphQLHandler(log *slog.Logger, authClient auth.Client, videoClient *videos.Client, scriptClient *scripts.Client, videoMasker, scriptMasker *masking.Masker, maxDepth, maxRootFields int, authTimeout, videoTimeout, scriptTimeout time.Duration) *GraphQLHandler {
+	return &GraphQLHandler{
+		log:           log,
+		auth:          authClient,
+		videos:        videoClient,
+		scripts:       scriptClient,
+		videoMasker:   videoMasker,
+		scriptMasker:  scriptMasker,
+		maxDepth:      maxDepth,
+		maxRootFields: maxRootFields,
+		authTimeout:   reload.NewValue(authTimeout),
+		videoTimeout:  reload.NewValue(videoTimeout),
+		scriptTimeout: reload.NewValue(scriptTimeout),
+	}
+}
+
+// SetTimeouts changes the upstream call timeouts of the following requests.
+func (h *GraphQLHandler) SetTimeouts(auth, videos, scripts time.Duration) {
+	h.authTimeout.Store(auth)
+	h.videoTimeout.Store(videos)
+	h.scriptTimeout.Store(scripts)
+}
+
+// Query executes a query sent as a JSON body, or in the query string of a
+// GET. The answer is 200 once the query runs, even with failed fields, and
+// 400 when it can't.
+func (h *GraphQLHandler) Query(c *gin.Context) {
+	var req graphql.Request
+	if c.Request.Method == http.MethodGet {
+		req.Query = c.Query("query")
+		req.OperationName = c.Query("operationName")
+		if vars := c.Query("variables"); vars != "" {
+			if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+				writeError(c, http.StatusBadRequest, "invalid variables")
+				return
+			}
+		}
+	} else if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Query == "" {
+		writeError(c, http.StatusBadRequest, "query is required")
+		return
+	}
+
+	resp := graphql.Execute(c.Request.Context(), h.schema(c), req)
+	if c.Request.Context().Err() != nil {
+		markAbandoned(c)
+		return
+	}
+	if resp.Data == nil {
+		writeJSON(c, http.StatusBadRequest, resp)
+		return
+	}
+	writeJSON(c, http.StatusOK, resp)
+}
+
+// schema binds the root fields to the caller of c.
+func (h *GraphQLHandler) schema(c *gin.Context) *graphql.Schema {
+	userID := currentUserID(c)
+	headers := userHeaders(c)
+	authTimeout := callTimeout(c, h.authTimeout.Load())
+	videoTimeout := callTimeout(c, h.videoTimeout.Load())
+	scriptTimeout := callTimeout(c, h.scriptTimeout.Load())
+
+	return &graphql.Schema{
+		MaxDepth:      h.maxDepth,
+		MaxRootFields: h.maxRootFields,
+		Query: map[string]graphql.Resolver{
+			"me": func(ctx context.Context, _ map[string]any) (any, error) {
+				ctx, cancel := context.WithTimeout(ctx, authTimeout)
+				defer cancel()
+				resp, err := h.auth.GetUser(ctx, &authv1.GetUserRequest{UserId: userID})
+				if err != nil {
+					return nil, h.authError(err)
+				}
+				return toJSONValue(convertUser(resp.GetUser()))
+			},
+			"videos": func(ctx context.Context, _ map[string]any) (any, error) {
+				ctx, cancel := context.WithTimeout(ctx, videoTimeout)
+				defer cancel()
+				resp, err := h.videos.ListVideos(ctx, headers)
+				if err != nil {
+					return nil, h.upstreamError("video", err)
+				}
+				return h.decode("video", h.videoMasker, resp.StatusCode, resp.Body, "videos", "items")
+			},
+			"video": func(ctx context.Context, args map[string]any) (any, error) {
+				videoID, _ := args["id"].(string)
+				if videoID == "" {
+					return nil, &graphql.Error{Message: "argument id is required", Extensions: map[string]any{"code": apierror.CodeInvalidRequest}}
+				}
+				ctx, cancel := context.WithTimeout(ctx, videoTimeout)
+				defer cancel()
+				resp, err := h.videos.GetVideo(ctx, videoID, headers)
+				if err != nil {
+					return nil, h.upstreamError("video", err)
+				}
+				return h.decode("video", h.videoMasker, resp.StatusCode, resp.Body)
+			},
+			"scripts": func(ctx context.Context, _ map[string]any) (any, error) {
+				ctx, cancel := context.WithTimeout(ctx, scriptTimeout)
+				defer cancel()
+				resp, err := h.scripts.ListScripts(ctx, headers)
+				if err != nil {
+					return nil, h.upstreamError("script", err)
+				}
+				return h.decode("script", h.scriptMasker, resp.StatusCode, resp.Body, "scripts", "items")
+			},
+		},
+	}
+}
+
+// decode turns an upstream answer into the field's value. For listings, the
+// first of listKeys present in an object answer holds the list.
+func (h *GraphQLHandler) decode(service string, masker *masking.Masker, statusCode int, body []byte, listKeys ...string) (any, error) {
+	if statusCode < 200 || statusCode > 299 {
+		return nil, &graphql.Error{
+			Message:    fmt.Sprintf("%s service answered %d", service, statusCode),
+			Extensions: map[string]any{"code": apierror.FromHTTPStatus(statusCode), "upstream": service},
+		}
+	}
+	dec := json.NewDecoder(bytes.NewReader(masker.Apply(body)))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		h.log.Warn("decode graphql upstream answer failed", slog.String("upstream", service), slog.String("err", err.Error()))
+		return nil, &graphql.Error{Message: service + " service error", Extensions: map[string]any{"code": apierror.CodeUpstreamError, "upstream": service, "reason": "invalid_response"}}
+	}
+	if object, ok := value.(map[string]any); ok {
+		for _, key := range listKeys {
+			if list, ok := object[key].([]any); ok {
+				return list, nil
+			}
+		}
+	}
+	return value, nil
+}
+
+// upstreamError reports a failed call like writeUpstreamError, as a field
+// error.
+func (h *GraphQLHandler) upstreamError(service string, err error) error {
+	h.log.Warn("graphql upstream call failed", slog.String("upstream", service), slog.String("err", err.Error()))
+	_, code, message, reason := upstreamFailure(service, err)
+	return &graphql.Error{Message: message, Extensions: map[string]any{"code": code, "upstream": service, "reason": reason}}
+}
+
+// authError reports a failed auth call like handleAuthError, as a field
+// error.
+func (h *GraphQLHandler) authError(err error) error {
+	httpStatus, code, message := authFailure(err)
+	if httpStatus >= http.StatusInternalServerError {
+		h.log.Warn("graphql auth call failed", slog.String("err", err.Error()))
+	}
+	return &graphql.Error{Message: message, Extensions: map[string]any{"code": code, "upstream": "auth"}}
+}
+
+// toJSONValue converts v to the decoded JSON the resolvers return.
+func toJSONValue(v any) (any, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var value any
+	err = json.Unmarshal(raw, &value)
+	return value, err
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	jobErrorsPerJob = 10
+	jobErrorsTTL    = time.Hour
+)
+
+type jobError struct {
+	At        time.Time `json:"at"`
+	Operation string    `json:"operation"`
+	Status    int       `json:"status,omitempty"`
+	Error     string    `json:"error"`
+}
+
+// jobErrorLog keeps the most recent upstream failures per job for the
+// diagnostics endpoint.
+type jobErrorLog struct {
+	mu        sync.Mutex
+	byJob     map[string][]jobError
+	lastSweep time.Time
+}
+
+func newJobErrorLog() *jobErrorLog {
+	return &jobErrorLog{byJob: make(map[string][]jobError), lastSweep: time.Now()}
+}
+
+func (l *jobErrorLog) record(jobID, operation string, status int, message string) {
+	if jobID == "" {
+		return
+	}
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entries := append(l.byJob[jobID], jobError{At: now, Operation: operation, Status: status, Error: message})
+	if len(entries) > jobErrorsPerJob {
+		entries = entries[len(entries)-jobErrorsPerJob:]
+	}
+	l.byJob[jobID] = entries
+
+	if now.Sub(l.lastSweep) < jobErrorsTTL {
+		return
+	}
+	l.lastSweep = now
+	for id, errs := range l.byJob {
+		if now.Sub(errs[len(errs)-1].At) > jobErrorsTTL {
+			delete(l.byJob, id)
+		}
+	}
+}
+
+func (l *jobErrorLog) recent(jobID string) []jobError {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	res := make([]jobError, 0, len(l.byJob[jobID]))
+	for _, entry := range l.byJob[jobID] {
+		if time.Since(entry.At) <= jobErrorsTTL {
+			res = append(res, entry)
+		}
+	}
+	return res
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/clients/videos"
+	"github.com/immxrtalbeast/api-gateway/internal/journal"
+)
+
+// Operations that are journaled when the video service fails with 5xx.
+const (
+	opApproveDraft     = "approve_draft"
+	opApproveSubtitles = "approve_subtitles"
+	opDeleteVideo      = "delete_video"
+	opDeleteMedia      = "delete_media"
+)
+
+// journalFailure queues a mutating call that failed upstream and answers
+// 202 with the journal entry ID. It reports false when the call succeeded,
+// was rejected with 4xx, the client went away, journaling is disabled or
+// the entry couldn't be stored; the caller then responds as usual.
+func (h *VideoHandler) journalFailure(c *gin.Context, operation, resourceID string, body []byte, headers map[string]string, resp *videos.Response, err error) bool {
+	if h.journal == nil || c.Request.Context().Err() != nil {
+		return false
+	}
+	var reason string
+	switch {
+	case err != nil:
+		reason = upstreamFailureReason(err)
+	case resp.StatusCode >= http.StatusInternalServerError:
+		reason = fmt.Sprintf("upstream status %d", resp.StatusCode)
+	default:
+		return false
+	}
+	entry, jerr := h.journal.Append(journal.Entry{
+		Operation:  operation,
+		ResourceID: resourceID,
+		UserID:     currentUserID(c),
+		Headers:    headers,
+		Body:       body,
+		Error:      reason,
+	})
+	if jerr != nil {
+		h.log.Warn("journal append failed", slog.String("operation", operation), slog.String("err", jerr.Error()))
+		return false
+	}
+	h.log.Info("request journaled",
+		slog.String("journal_id", entry.ID),
+		slog.String("operation", operation),
+		slog.String("resource_id", resourceID),
+		slog.String("reason", reason),
+	)
+	writeJSON(c, http.StatusAccepted, map[string]any{
+		"status":     "queued",
+		"journal_id": entry.ID,
+		"operation":  operation,
+	})
+	return true
+}
+
+// ListJournal returns the queued requests (admin only).
+func (h *VideoHandler) ListJournal(c *gin.Context) {
+	if h.journal == nil {
+		writeError(c, http.StatusConflict, "request journal is disabled")
+		return
+	}
+	writeJSON(c, http.StatusOK, map[string]any{"entries": h.journal.List()})
+}
+
+// ReplayJournal re-sends the queued requests to the video service (admin only).
+func (h *VideoHandler) ReplayJournal(c *gin.Context) {
+	if h.journal == nil {
+		writeError(c, http.StatusConflict, "request journal is disabled")
+		return
+	}
+	res, err := h.journal.Replay(c.Request.Context(), h.replayEntry)
+	if err != nil {
+		h.log.Error("journal replay failed", slog.String("err", err.Error()))
+		writeError(c, http.StatusInternalServerError, "failed to persist journal")
+		return
+	}
+	h.log.Info("journal replayed",
+		slog.Int("replayed", res.Replayed),
+		slog.Int("failed", res.Failed),
+		slog.Int("remaining", res.Remaining),
+	)
+	writeJSON(c, http.StatusOK, res)
+}
+
+// DeleteJournalEntry drops a queued request without replaying it (admin only).
+func (h *VideoHandler) DeleteJournalEntry(c *gin.Context) {
+	if h.journal == nil {
+		writeError(c, http.StatusConflict, "request journal is disabled")
+		return
+	}
+	removed, err := h.journal.Remove(c.Param("id"))
+	if err != nil {
+		h.log.Error("journal remove failed", slog.String("err", err.Error()))
+		writeError(c, http.StatusInternalServerError, "failed to persist journal")
+		return
+	}
+	if !removed {
+		writeError(c, http.StatusNotFound, "journal entry not found")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// replayEntry treats any non-5xx answer as final: a 4xx means the upstream
+// has rejected the action for good and retrying won't change that.
+func (h *VideoHandler) replayEntry(ctx context.Context, e journal.Entry) error {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout.Load())
+	defer cancel()
+
+	var resp *videos.Response
+	var err error
+	switch e.Operation {
+	case opApproveDraft:
+		resp, err = h.client.ApproveDraft(ctx, e.ResourceID, e.Body, e.Headers)
+	case opApproveSubtitles:
+		resp, err = h.client.ApproveSubtitles(ctx, e.ResourceID, e.Body, e.Headers)
+	case opApproveTranslation:
+		jobID, language := splitTranslationResourceID(e.ResourceID)
+		resp, err = h.client.ApproveSubtitleTranslation(ctx, jobID, language, e.Body, e.Headers)
+	case opDeleteVideo:
+		resp, err = h.client.DeleteVideo(ctx, e.ResourceID, e.Headers)
+	case opDeleteMedia:
+		resp, err = h.client.DeleteMedia(ctx, e.ResourceID, e.Headers)
+	default:
+		return fmt.Errorf("unknown operation %q", e.Operation)
+	}
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return errors.New(http.StatusText(resp.StatusCode))
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/apierror"
+	"github.com/immxrtalbeast/api-gateway/internal/masking"
+)
+
+// ExportOptions bounds the ?all=true listings of the admin routes, which walk
+// the upstream pagination for exports.
+type ExportOptions struct {
+	PageSize int
+	// MaxPages cuts listings longer than MaxPages*PageSize items.
+	MaxPages int
+	// Prefetch is how many pages are fetched ahead of the response. Pages
+	// are linked by cursor, so one page is requested at a time; prefetching
+	// overlaps those requests with writing the previous pages.
+	Prefetch int
+}
+
+var errListingTruncated = errors.New("listing truncated")
+
+// listingResponse is an upstream listing answer. videos.Response and
+// scripts.Response convert to it.
+type listingResponse struct {
+	StatusCode int
+	Body       []byte
+	Header     http.Header
+}
+
+// exportListing is a listing walked by an ?all=true route.
+type exportListing struct {
+	// service names the upstream in errors, key the array of the response.
+	service string
+	key     string
+	masker  *masking.Masker
+	fetch   func(ctx context.Context, token string) (*listingResponse, error)
+	// decode returns the items of a page and the token of the next one.
+	decode func(body []byte) ([]json.RawMessage, string, error)
+}
+
+// fetchedPage is one page of a walked listing, or what ended the walk: an
+// upstream error status in resp, or err.
+type fetchedPage struct {
+	items []json.RawMessage
+	resp  *listingResponse
+	err   error
+}
+
+// walkPages follows the next_page_token links of a listing in a goroutine.
+// The channel is closed after the last page or the first failure; canceling
+// ctx stops the walk.
+func walkPages(ctx context.Context, opts ExportOptions, listing exportListing) <-chan fetchedPage {
+	pages := make(chan fetchedPage, max(opts.Prefetch, 0))
+	go func() {
+		defer close(pages)
+		send := func(p fetchedPage) bool {
+			select {
+			case pages <- p:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		token := ""
+		for range opts.MaxPages {
+			resp, err := listing.fetch(ctx, token)
+			if err != nil {
+				send(fetchedPage{err: err})
+				return
+			}
+			if resp.StatusCode != http.StatusOK {
+				send(fetchedPage{resp: resp})
+				return
+			}
+			items, next, err := listing.decode(resp.Body)
+			if err != nil {
+				send(fetchedPage{err: err})
+				return
+			}
+			if !send(fetchedPage{items: items}) {
+				return
+			}
+			if next == "" || next == token {
+				return
+			}
+			token = next
+		}
+		send(fetchedPage{err: errListingTruncated})
+	}()
+	return pages
+}
+
+// listAllVideos answers ListUserVideos?all=true, see listAll.
+func (h *AdminHandler) listAllVideos(c *gin.Context, userID string) {
+	headers := impersonationHeaders(c, userID)
+	h.listAll(c, exportListing{
+		service: "video",
+		key:     "videos",
+		masker:  h.videoMasker,
+		fetch: func(ctx context.Context, token string) (*listingResponse, error) {
+			resp, err := h.videos.ListVideosPage(ctx, token, h.export.PageSize, headers)
+			return (*listingResponse)(resp), err
+		},
+		decode: func(body []byte) ([]json.RawMessage, string, error) {
+			page, err := decodeVideosPage(body)
+			return page.Videos, page.NextPageToken, err
+		},
+	})
+}
+
+// listAllScripts answers ListUserScripts?all=true, see listAll. A script
+// service answering without next_page_token is read as a single page.
+func (h *AdminHandler) listAllScripts(c *gin.Context, userID string) {
+	headers := impersonationHeaders(c, userID)
+	h.listAll(c, exportListing{
+		service: "script",
+		key:     "scripts",
+		masker:  h.scriptMasker,
+		fetch: func(ctx context.Context, token string) (*listingResponse, error) {
+			resp, err := h.scripts.ListScriptsPage(ctx, token, h.export.PageSize, headers)
+			return (*listingResponse)(resp), err
+		},
+		decode: func(body []byte) ([]json.RawMessage, string, error) {
+			list, err := decodeScriptsPage(body)
+			return list.Scripts, list.NextPageToken, err
+		},
+	})
+}
+
+// listAll answers with every item of listing: streamed one per line for
+// Accept: application/x-ndjson, otherwise as one {"<key>": [...], "count": n}
+// document once all pages arrived.
+func (h *AdminHandler) listAll(c *gin.Context, listing exportListing) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	fetch := listing.fetch
+	listing.fetch = func(ctx context.Context, token string) (*listingResponse, error) {
+		ctx, cancel := context.WithTimeout(ctx, callTimeout(c, h.timeout.Load()))
+		defer cancel()
+		return fetch(ctx, token)
+	}
+	pages := walkPages(ctx, h.export, listing)
+	if wantsNDJSON(c) {
+		h.streamAll(c, listing, pages)
+		return
+	}
+
+	items := []json.RawMessage{}
+	for fetched := range pages {
+		if !h.pageOK(c, listing, fetched, false) {
+			return
+		}
+		for _, item := range fetched.items {
+			items = append(items, listing.masker.Apply(item))
+		}
+	}
+	writeJSON(c, http.StatusOK, map[string]any{listing.key: items, "count": len(items)})
+}
+
+func (h *AdminHandler) streamAll(c *gin.Context, listing exportListing, pages <-chan fetchedPage) {
+	rc := http.NewResponseController(c.Writer)
+	started := false
+	for fetched := range pages {
+		if !h.pageOK(c, listing, fetched, started) {
+			return
+		}
+		if !started {
+			c.Header("Content-Type", ndjsonContentType)
+			c.Status(http.StatusOK)
+			started = true
+		}
+		_ = rc.SetWriteDeadline(time.Now().Add(callTimeout(c, h.timeout.Load()) + ndjsonWriteGrace))
+		for _, item := range fetched.items {
+			if err := writeNDJSONLine(c, h.log, listing.masker.Apply(item)); err != nil {
+				markAbandoned(c)
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
+
+// pageOK reports whether fetched is a page, otherwise answers with why the
+// walk ended: with the usual status and envelope until the response started,
+// with an error line after.
+func (h *AdminHandler) pageOK(c *gin.Context, listing exportListing, fetched fetchedPage, started bool) bool {
+	switch {
+	case fetched.resp != nil:
+		if !started {
+			if err := writeUpstream(c, listing.masker, fetched.resp.StatusCode, fetched.resp.Header, fetched.resp.Body); err != nil {
+				markAbandoned(c)
+			}
+			return false
+		}
+		endNDJSON(c, apierror.FromHTTPStatus(fetched.resp.StatusCode), listing.service+" service returned an error mid-stream")
+		return false
+	case errors.Is(fetched.err, errListingTruncated):
+		h.log.Warn("admin export truncated", slog.String("upstream", listing.service), slog.Int("pages", h.export.MaxPages))
+		if !started {
+			apierror.Abort(c, http.StatusUnprocessableEntity, apierror.CodeUnprocessable, "listing exceeds the export limit", map[string]any{
+				"max_items": h.export.MaxPages * h.export.PageSize,
+			})
+			return false
+		}
+		endNDJSON(c, apierror.CodeInternal, "listing truncated")
+		return false
+	case fetched.err != nil:
+		if clientGone(c, fetched.err) {
+			return false
+		}
+		h.log.Error("admin export failed", slog.String("upstream", listing.service), slog.String("err", fetched.err.Error()))
+		if !started {
+			writeUpstreamError(c, listing.service, fetched.err)
+			return false
+		}
+		endNDJSON(c, apierror.CodeUpstreamError, listing.service+" service error")
+		return false
+	}
+	return true
+}
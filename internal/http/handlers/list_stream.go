@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/apierror"
+)
+
+const (
+	ndjsonContentType = "application/x-ndjson"
+	ndjsonPageSize    = 100
+	ndjsonMaxPages    = 1000
+	// ndjsonWriteGrace is added to the upstream timeout when the write
+	// deadline is pushed back before each page.
+	ndjsonWriteGrace = 10 * time.Second
+)
+
+// wantsNDJSON reports whether the client asked for a streamed listing.
+func wantsNDJSON(c *gin.Context) bool {
+	for _, part := range strings.Split(c.GetHeader("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err == nil && mediaType == ndjsonContentType {
+			return true
+		}
+	}
+	return false
+}
+
+// videosPage is one page of the video service listing. Unpaginated upstreams
+// answer with a bare array, which is treated as the only page.
+type videosPage struct {
+	Videos        []json.RawMessage `json:"videos"`
+	Items         []json.RawMessage `json:"items"`
+	NextPageToken string            `json:"next_page_token"`
+}
+
+func decodeVideosPage(body []byte) (videosPage, error) {
+	var page videosPage
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		err := json.Unmarshal(trimmed, &page.Videos)
+		return page, err
+	}
+	if err := json.Unmarshal(body, &page); err != nil {
+		return page, err
+	}
+	if page.Videos == nil {
+		page.Videos = page.Items
+	}
+	return page, nil
+}
+
+// streamVideos crawls the paginated listing and writes every video as its own
+// line as soon as its page arrives, so large accounts neither wait for nor
+// buffer one giant array. The write deadline is pushed back before each page.
+// Errors before the first line keep the usual status and envelope; later ones
+// end the stream with an {"error": ...} line.
+func (h *VideoHandler) streamVideos(c *gin.Context) {
+	rc := http.NewResponseController(c.Writer)
+	headers := userHeaders(c)
+	started := false
+	token := ""
+	for pages := 0; pages < ndjsonMaxPages; pages++ {
+		_ = rc.SetWriteDeadline(time.Now().Add(callTimeout(c, h.timeout.Load()) + ndjsonWriteGrace))
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
+		resp, err := h.client.ListVideosPage(ctx, token, ndjsonPageSize, headers)
+		cancel()
+		if err != nil {
+			if clientGone(c, err) {
+				return
+			}
+			h.log.Error("list videos page failed", slog.String("err", err.Error()))
+			if !started {
+				writeUpstreamError(c, "video", err)
+				return
+			}
+			endNDJSON(c, apierror.CodeUpstreamError, "video service error")
+			return
+		}
+		if resp.StatusCode != http.StatusOK {
+			if !started {
+				h.forwardResponse(c, resp)
+				return
+			}
+			endNDJSON(c, apierror.FromHTTPStatus(resp.StatusCode), "video service returned an error mid-stream")
+			return
+		}
+		page, err := decodeVideosPage(resp.Body)
+		if err != nil {
+			h.log.Error("decode videos page failed", slog.String("err", err.Error()))
+			if !started {
+				writeError(c, http.StatusBadGateway, "invalid listing from video service")
+				return
+			}
+			endNDJSON(c, apierror.CodeUpstreamError, "invalid listing from video service")
+			return
+		}
+
+		if !started {
+			c.Header("Content-Type", ndjsonContentType)
+			c.Status(http.StatusOK)
+			started = true
+		}
+		for _, item := range page.Videos {
+			if err := writeNDJSONLine(c, h.log, h.masker.Apply(item)); err != nil {
+				markAbandoned(c)
+				return
+			}
+		}
+		c.Writer.Flush()
+
+		if page.NextPageToken == "" || page.NextPageToken == token {
+			return
+		}
+		token = page.NextPageToken
+	}
+	h.log.Warn("list videos stream truncated", slog.Int("pages", ndjsonMaxPages))
+	endNDJSON(c, apierror.CodeInternal, "listing truncated")
+}
+
+// writeNDJSONLine writes one item per line; items that aren't valid JSON are
+// skipped rather than breaking the stream.
+func writeNDJSONLine(c *gin.Context, log *slog.Logger, item []byte) error {
+	var line bytes.Buffer
+	if err := json.Compact(&line, item); err != nil {
+		log.Warn("skipping invalid video item", slog.String("err", err.Error()))
+		return nil
+	}
+	line.WriteByte('\n')
+	_, err := c.Writer.Write(line.Bytes())
+	return err
+}
+
+// endNDJSON ends a started stream with an {"error": ...} line.
+func endNDJSON(c *gin.Context, code apierror.Code, message string) {
+	line, _ := json.Marshal(apierror.Envelope{Error: apierror.Error{
+		Code:      code,
+		Message:   message,
+		RequestID: c.Writer.Header().Get(apierror.RequestIDHeader),
+	}})
+	c.Writer.Write(append(line, '\n'))
+	c.Writer.Flush()
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+	"encoding/binary"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/apierror"
+	"golang.org/x/net/websocket"
+)
+
+// AuthExpiredCloseCode is the websocket close code of streams ended because
+// their session was logged out or revoked; clients should sign in again
+// rather than reconnect.
+const AuthExpiredCloseCode = 4401
+
+// untilLogout derives a context canceled when the caller's session ends, see
+// StreamOptions.Logouts, and a function reporting whether it did. stop must
+// be called when the stream is over.
+func (h *VideoHandler) untilLogout(c *gin.Context, parent context.Context) (ctx context.Context, loggedOut func() bool, stop func()) {
+	ctx, cancel := context.WithCancel(parent)
+	if h.stream.Logouts == nil {
+		return ctx, func() bool { return false }, cancel
+	}
+	done, unwatch := h.stream.Logouts.Watch(currentUserID(c), c.GetString("sessionID"))
+	var ended atomic.Bool
+	go func() {
+		select {
+		case <-done:
+			ended.Store(true)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, ended.Load, func() {
+		cancel()
+		unwatch()
+	}
+}
+
+// closeAuthExpired tells the client why its stream ends, with an error
+// message and the AuthExpiredCloseCode close frame.
+func closeAuthExpired(conn *websocket.Conn) {
+	websocket.JSON.Send(conn, apierror.Envelope{Error: apierror.Error{
+		Code:    apierror.CodeUnauthenticated,
+		Message: "session ended",
+	}})
+	payload := binary.BigEndian.AppendUint16(nil, AuthExpiredCloseCode)
+	payload = append(payload, "auth expired"...)
+	conn.PayloadType = websocket.CloseFrame
+	conn.Write(payload)
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/apierror"
+	"github.com/immxrtalbeast/api-gateway/internal/maintenance"
+)
+
+// MaintenanceHandler serves /api/admin/maintenance, switching route groups
+// into maintenance at runtime. Routes must be guarded by AuthMiddleware and
+// AdminOnly.
+type MaintenanceHandler struct {
+	log *slog.Logger
+	sw  *maintenance.Switch
+}
+
+func NewMaintenanceHandler(log *slog.Logger, sw *maintenance.Switch) *MaintenanceHandler {
+	return &MaintenanceHandler{log: log, sw: sw}
+}
+
+func (h *MaintenanceHandler) List(c *gin.Context) {
+	writeJSON(c, http.StatusOK, gin.H{"groups": h.sw.Groups()})
+}
+
+type startMaintenanceRequest struct {
+	Message string `json:"message"`
+	// Duration ("30m") or Until (RFC 3339) ends the maintenance on its own;
+	// without either it lasts until switched off.
+	Duration string    `json:"duration"`
+	Until    time.Time `json:"until"`
+}
+
+func (h *MaintenanceHandler) Start(c *gin.Context) {
+	var req startMaintenanceRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			writeError(c, http.StatusBadRequest, "invalid json payload")
+			return
+		}
+	}
+	now := time.Now()
+	w := maintenance.Window{
+		Group:   c.Param("group"),
+		Message: strings.TrimSpace(req.Message),
+		Since:   now,
+		Until:   req.Until,
+		By:      currentUserID(c),
+	}
+	if req.Duration != "" {
+		if !req.Until.IsZero() {
+			writeError(c, http.StatusBadRequest, "duration and until are mutually exclusive")
+			return
+		}
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil || d <= 0 {
+			writeError(c, http.StatusBadRequest, "duration must be a positive duration, e.g. 30m")
+			return
+		}
+		w.Until = now.Add(d)
+	}
+	if !w.Until.IsZero() && !w.Until.After(now) {
+		writeError(c, http.StatusBadRequest, "until must be in the future")
+		return
+	}
+	if err := h.sw.Start(c.Request.Context(), w); err != nil {
+		h.fail(c, w.Group, err)
+		return
+	}
+	h.log.Warn("maintenance started",
+		slog.String("group", w.Group),
+		slog.String("admin_id", w.By),
+		slog.Time("until", w.Until),
+	)
+	h.List(c)
+}
+
+func (h *MaintenanceHandler) Stop(c *gin.Context) {
+	group := c.Param("group")
+	if err := h.sw.Stop(c.Request.Context(), group); err != nil {
+		h.fail(c, group, err)
+		return
+	}
+	h.log.Warn("maintenance ended", slog.String("group", group), slog.String("admin_id", currentUserID(c)))
+	h.List(c)
+}
+
+func (h *MaintenanceHandler) fail(c *gin.Context, group string, err error) {
+	switch {
+	case errors.Is(err, maintenance.ErrUnknownGroup):
+		apierror.Abort(c, http.StatusNotFound, apierror.CodeNotFound, err.Error(), map[string]any{"group": group})
+	case errors.Is(err, maintenance.ErrConfigured):
+		apierror.Abort(c, http.StatusConflict, apierror.CodeConflict, err.Error(), map[string]any{"group": group})
+	default:
+		h.log.Error("maintenance switch failed", slog.String("group", group), slog.String("err", err.Error()))
+		writeError(c, http.StatusInternalServerError, "failed to switch maintenance")
+	}
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/acl"
+	"github.com/immxrtalbeast/api-gateway/internal/http/middleware"
+	"github.com/immxrtalbeast/api-gateway/internal/openapi"
+)
+
+// OpenAPIHandler serves the OpenAPI document of the gateway, built once
+// when the router is set up, and optionally Swagger UI over it.
+type OpenAPIHandler struct {
+	spec   []byte
+	ui     *template.Template
+	assets string
+	title  string
+}
+
+// NewOpenAPIHandler serves Swagger UI from the swagger-ui-dist build at
+// assets ("https://unpkg.com/swagger-ui-dist@5"); the UI is off when assets
+// is empty.
+func NewOpenAPIHandler(doc *openapi.Document, assets string) *OpenAPIHandler {
+	// The document holds only strings, maps and slices of them.
+	spec, _ := json.Marshal(doc)
+	h := &OpenAPIHandler{spec: spec, assets: assets, title: doc.Info.Title}
+	if assets != "" {
+		h.ui = swaggerUI
+	}
+	return h
+}
+
+func (h *OpenAPIHandler) Spec(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.Data(http.StatusOK, "application/json; charset=utf-8", h.spec)
+}
+
+// SwaggerUI serves the UI page, or 404 when it is off.
+func (h *OpenAPIHandler) SwaggerUI(c *gin.Context) {
+	if h.ui == nil {
+		writeError(c, http.StatusNotFound, "not found")
+		return
+	}
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	_ = h.ui.Execute(c.Writer, map[string]string{"Title": h.title, "Assets": h.assets, "Spec": "/api/openapi.json"})
+}
+
+var swaggerUI = template.Must(template.New("swagger").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<link rel="stylesheet" href="{{.Assets}}/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="{{.Assets}}/swagger-ui-bundle.js"></script>
+<script>
+window.ui = SwaggerUIBundle({url: {{.Spec}}, dom_id: "#swagger-ui"});
+</script>
+</body>
+</html>
+`))
+
+// Response models documenting the map payloads the handlers write; they
+// must follow the handlers.
+
+type userEnvelope struct {
+	User userResponse `json:"user"`
+}
+
+type loginResponse struct {
+	RefreshToken string       `json:"refresh_token"`
+	User         userResponse `json:"user"`
+}
+
+type twoFactorRequiredResponse struct {
+	Status         string `json:"status"`
+	ChallengeToken string `json:"challenge_token"`
+}
+
+type refreshResponse struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type meResponse struct {
+	User  userResponse `json:"user"`
+	Token string       `json:"token"`
+}
+
+type isAdminResponse struct {
+	IsAdmin bool `json:"is_admin"`
+}
+
+type messageResponse struct {
+	Message string `json:"message"`
+}
+
+type sessionsResponse struct {
+	Sessions []sessionResponse `json:"sessions"`
+}
+
+type twoFactorSetupResponse struct {
+	Secret     string `json:"secret"`
+	OtpauthURL string `json:"otpauth_url"`
+}
+
+type recoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type collaboratorGrantResponse struct {
+	VideoID string   `json:"video_id"`
+	UserID  string   `json:"user_id"`
+	Role    acl.Role `json:"role"`
+}
+
+type collaboratorsResponse struct {
+	VideoID       string         `json:"video_id"`
+	Collaborators []collaborator `json:"collaborators"`
+}
+
+var folderQuery = openapi.Parameter{Name: "folder", In: "query", Description: "Media folder to list", Schema: openapi.Schema{"type": "string"}}
+
+// OpenAPIOperations describes the auth, scripts, videos and ideas routes.
+// Script and video payloads are the upstreams' own and documented as free
+// form, except the bodies checked by the validation schemas.
+func OpenAPIOperations() []openapi.Operation {
+	const (
+		tagAuth    = "auth"
+		tagScripts = "scripts"
+		tagVideos  = "videos"
+		tagIdeas   = "ideas"
+	)
+	return []openapi.Operation{
+		{Method: http.MethodPost, Path: "/api/auth/register", Tag: tagAuth, Summary: "Create an account", Request: registerRequest{}, Response: userEnvelope{}, Status: http.StatusCreated},
+		{Method: http.MethodPost, Path: "/api/auth/login", Tag: tagAuth, Summary: "Log in and set the session cookie",
+			Description: "Accounts with two-factor authentication get 202 and a challenge token for /api/auth/2fa/challenge instead.",
+			Request:     loginRequest{}, Response: loginResponse{}, Responses: map[int]any{http.StatusAccepted: twoFactorRequiredResponse{}}},
+		{Method: http.MethodPost, Path: "/api/auth/refresh", Tag: tagAuth, Summary: "Renew the session cookie with a refresh token", Request: refreshRequest{}, Response: refreshResponse{}},
+		{Method: http.MethodPost, Path: "/api/auth/logout", Tag: tagAuth, Summary: "End the session", Request: logoutRequest{}, Status: http.StatusNoContent},
+		{Method: http.MethodPost, Path: "/api/auth/password/forgot", Tag: tagAuth, Summary: "Send a password reset link", Request: forgotPasswordRequest{}, Response: messageResponse{}, Status: http.StatusAccepted},
+		{Method: http.MethodPost, Path: "/api/auth/password/reset", Tag: tagAuth, Summary: "Set a new password with a reset token", Request: resetPasswordRequest{}, Status: http.StatusNoContent},
+		{Method: http.MethodPost, Path: "/api/auth/verify-email", Tag: tagAuth, Summary: "Confirm the email address", Request: verifyEmailRequest{}, Response: userEnvelope{}},
+		{Method: http.MethodPost, Path: "/api/auth/password", Tag: tagAuth, Summary: "Change the password", Auth: true, Request: changePasswordRequest{}, Status: http.StatusNoContent},
+		{Method: http.MethodPost, Path: "/api/auth/email", Tag: tagAuth, Summary: "Change the email address", Auth: true, Request: changeEmailRequest{}, Response: userEnvelope{}},
+		{Method: http.MethodGet, Path: "/api/auth/me", Tag: tagAuth, Summary: "Current user", Auth: true, Response: meResponse{}},
+		{Method: http.MethodGet, Path: "/api/auth/sessions", Tag: tagAuth, Summary: "List the sessions of the current user", Auth: true, Response: sessionsResponse{}},
+		{Method: http.MethodDelete, Path: "/api/auth/sessions/:id", Tag: tagAuth, Summary: "Revoke a session", Auth: true, Status: http.StatusNoContent},
+		{Method: http.MethodPost, Path: "/api/auth/2fa/setup", Tag: tagAuth, Summary: "Start enrolling an authenticator app", Auth: true, Response: twoFactorSetupResponse{}},
+		{Method: http.MethodPost, Path: "/api/auth/2fa/verify", Tag: tagAuth, Summary: "Enable two-factor authentication", Auth: true, Request: twoFactorCodeRequest{}, Response: recoveryCodesResponse{}},
+		{Method: http.MethodPost, Path: "/api/auth/2fa/disable", Tag: tagAuth, Summary: "Disable two-factor authentication", Auth: true, Request: twoFactorCodeRequest{}, Status: http.StatusNoContent},
+		{Method: http.MethodPost, Path: "/api/auth/2fa/challenge", Tag: tagAuth, Summary: "Finish a two-factor login", Request: twoFactorChallengeRequest{}, Response: loginResponse{}},
+		{Method: http.MethodGet, Path: "/api/auth/users/:id", Tag: tagAuth, Summary: "Get a user", Auth: true, Response: userEnvelope{}},
+		{Method: http.MethodGet, Path: "/api/auth/users/:id/is_admin", Tag: tagAuth, Summary: "Whether a user is an admin", Auth: true, Response: isAdminResponse{}},
+
+		{Method: http.MethodPost, Path: "/api/scripts", Tag: tagScripts, Summary: "Generate a script", Auth: true, RequestSchema: middleware.SchemaCreateScript},
+		{Method: http.MethodGet, Path: "/api/scripts", Tag: tagScripts, Summary: "List scripts", Auth: true},
+
+		{Method: http.MethodPost, Path: "/api/videos", Tag: tagVideos, Summary: "Create a video job", Auth: true, RequestSchema: middleware.SchemaCreateVideo},
+		{Method: http.MethodGet, Path: "/api/videos", Tag: tagVideos, Summary: "List videos", Auth: true},
+		{Method: http.MethodGet, Path: "/api/videos/:id", Tag: tagVideos, Summary: "Get a video", Auth: true},
+		{Method: http.MethodGet, Path: VideoBatchRoute, Tag: tagVideos, Summary: "Get several videos by ID", Auth: true, Response: batchGetResponse{},
+			Query: []openapi.Parameter{{Name: "ids", In: "query", Description: "Comma-separated video IDs", Required: true, Schema: openapi.Schema{"type": "string"}}}},
+		{Method: http.MethodPatch, Path: "/api/videos/:id", Tag: tagVideos, Summary: "Update a video", Auth: true, Request: openapi.Schema{"type": "object"}},
+		{Method: http.MethodDelete, Path: "/api/videos/:id", Tag: tagVideos, Summary: "Delete a video", Auth: true},
+		{Method: http.MethodPost, Path: "/api/videos/:id/collaborators", Tag: tagVideos, Summary: "Share a video with a user", Auth: true, Request: grantRequest{}, Response: collaboratorGrantResponse{}, Status: http.StatusCreated},
+		{Method: http.MethodGet, Path: "/api/videos/:id/collaborators", Tag: tagVideos, Summary: "List the collaborators of a video", Auth: true, Response: collaboratorsResponse{}},
+		{Method: http.MethodGet, Path: "/api/videos/:id/content", Tag: tagVideos, Summary: "Stream the rendered video file", Auth: true,
+			Description: "Supports Range requests: a satisfiable range is answered with 206 and Content-Range. A complete 200 download issues a signed receipt.",
+			Response:    openapi.Schema{"type": "string", "format": "binary"}, ContentType: "video/mp4", Responses: map[int]any{http.StatusPartialContent: openapi.Schema{"type": "string", "format": "binary"}}},
+		{Method: http.MethodGet, Path: "/api/videos/:id/receipts", Tag: tagVideos, Summary: "Signed receipts of the video's downloads", Auth: true, Response: receiptsResponse{}},
+		{Method: http.MethodDelete, Path: "/api/videos/:id/collaborators/:user_id", Tag: tagVideos, Summary: "Stop sharing a video with a user", Auth: true, Status: http.StatusNoContent},
+		{Method: http.MethodPost, Path: "/api/videos/:id/draft:approve", Tag: tagVideos, Summary: "Approve the draft and start rendering", Auth: true},
+		{Method: http.MethodPost, Path: "/api/videos/:id/subtitles:approve", Tag: tagVideos, Summary: "Approve the subtitles", Auth: true},
+		{Method: http.MethodPost, Path: "/api/videos/:id/subtitles/translations", Tag: tagVideos, Summary: "Request subtitle translations", Auth: true, Request: translationsRequest{}},
+		{Method: http.MethodGet, Path: "/api/videos/:id/subtitles/translations", Tag: tagVideos, Summary: "List subtitle translations", Auth: true},
+		{Method: http.MethodGet, Path: "/api/videos/:id/stream", Tag: tagVideos, Summary: "Websocket of the job's progress updates", Auth: true, Status: http.StatusSwitchingProtocols},
+		{Method: http.MethodGet, Path: "/api/videos/media", Tag: tagVideos, Summary: "List uploaded media", Auth: true, Query: []openapi.Parameter{folderQuery}},
+		{Method: http.MethodGet, Path: "/api/videos/media/shared", Tag: tagVideos, Summary: "List the organization's shared media", Auth: true, Query: []openapi.Parameter{folderQuery}},
+		{Method: http.MethodGet, Path: "/api/videos/media/:id/stream", Tag: tagVideos, Summary: "Websocket of the media item's processing updates", Auth: true, Status: http.StatusSwitchingProtocols},
+		{Method: http.MethodGet, Path: "/api/videos/voices", Tag: tagVideos, Summary: "List narration voices", Auth: true},
+		{Method: http.MethodGet, Path: "/api/videos/music", Tag: tagVideos, Summary: "List background music", Auth: true},
+
+		{Method: http.MethodPost, Path: "/api/ideas/expand", Tag: tagIdeas, Summary: "Expand an idea into a video outline", Auth: true, RequestSchema: middleware.SchemaExpandIdea},
+	}
+}
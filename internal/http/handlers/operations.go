@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/clients/videos"
+)
+
+type approveFunc func(ctx context.Context, payload []byte, headers map[string]string) (*videos.Response, error)
+
+// approve sends an approval tagged with an operation ID (the client's
+// X-Operation-ID or a generated one). If the call fails after it may have
+// reached the video service, the operation status is queried instead of
+// blindly re-sending, so the approval side effects never run twice.
+// resourceID identifies the approved object in the journal.
+func (h *VideoHandler) approve(c *gin.Context, operation, jobID, resourceID string, call approveFunc) {
+	body, err := readJSONBody(c.Request.Body)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, "failed to read request body")
+		return
+	}
+	opID := operationID(c)
+	c.Header(videos.OperationIDHeader, opID)
+	headers := userHeaders(c)
+	if headers == nil {
+		headers = make(map[string]string, 1)
+	}
+	headers[videos.OperationIDHeader] = opID
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
+	defer cancel()
+
+	resp, err := call(ctx, body, headers)
+	if err != nil && ambiguousFailure(err) && c.Request.Context().Err() == nil {
+		resp, err = h.resolveOperation(c, opID, body, headers, call, err)
+	}
+	h.recordJobResult(jobID, operation, resp, err)
+	if h.journalFailure(c, operation, resourceID, body, headers, resp, err) {
+		return
+	}
+	if err != nil {
+		if clientGone(c, err) {
+			return
+		}
+		h.log.Error("approve failed", slog.String("operation", operation), slog.String("operation_id", opID), slog.String("err", err.Error()))
+		writeUpstreamError(c, "video", err)
+		return
+	}
+	h.forwardResponse(c, resp)
+}
+
+// resolveOperation asks the video service what happened to opID. A known
+// operation is answered with its status; an unknown one was never received
+// and is sent once more under the same ID. Otherwise sendErr stands.
+func (h *VideoHandler) resolveOperation(c *gin.Context, opID string, body []byte, headers map[string]string, call approveFunc, sendErr error) (*videos.Response, error) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
+	defer cancel()
+
+	status, err := h.client.GetOperation(ctx, opID, headers)
+	if err != nil {
+		h.log.Warn("operation status lookup failed", slog.String("operation_id", opID), slog.String("err", err.Error()))
+		return nil, sendErr
+	}
+	switch {
+	case status.StatusCode == http.StatusNotFound:
+		h.log.Info("operation not received upstream, resending", slog.String("operation_id", opID))
+		return call(ctx, body, headers)
+	case status.StatusCode < http.StatusMultipleChoices:
+		return status, nil
+	}
+	return nil, sendErr
+}
+
+// ambiguousFailure reports whether the request may have been delivered
+// before the call failed.
+func ambiguousFailure(err error) bool {
+	switch upstreamFailureReason(err) {
+	case "timeout", "connection_reset":
+		return true
+	}
+	return false
+}
+
+func operationID(c *gin.Context) string {
+	if id := c.GetHeader(videos.OperationIDHeader); id != "" && len(id) <= 128 {
+		return id
+	}
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
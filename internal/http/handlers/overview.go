@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/apierror"
+	"github.com/immxrtalbeast/api-gateway/internal/clients/auth"
+	"github.com/immxrtalbeast/api-gateway/internal/clients/scripts"
+	"github.com/immxrtalbeast/api-gateway/internal/clients/videos"
+	"github.com/immxrtalbeast/api-gateway/internal/masking"
+	"github.com/immxrtalbeast/api-gateway/internal/reload"
+	authv1 "github.com/immxrtalbeast/protos/gen/go/auth/v1"
+)
+
+// OverviewHandler serves GET /api/overview, what the app's home screen
+// shows in one request: the current user, their videos and their scripts,
+// fetched concurrently. A section whose service fails carries an error
+// instead of its data; the others are still answered.
+type OverviewHandler struct {
+	log           *slog.Logger
+	auth          auth.Client
+	videos        *videos.Client
+	scripts       *scripts.Client
+	videoMasker   *masking.Masker
+	scriptMasker  *masking.Masker
+	authTimeout   *reload.Value[time.Duration]
+	videoTimeout  *reload.Value[time.Duration]
+	scriptTimeout *reload.Value[time.Duration]
+}
+
+func NewOverviewHandler(log *slog.Logger, authClient auth.Client, videoClient *videos.Client, scriptClient *scripts.Client, videoMasker, scriptMasker *masking.Masker, authTimeout, videoTimeout, scriptTimeout time.Duration) *OverviewHandler {
+	return &OverviewHandler{
+		log:           log,
+		auth:          authClient,
+		videos:        videoClient,
+		scripts:       scriptClient,
+		videoMasker:   videoMasker,
+		scriptMasker:  scriptMasker,
+		authTimeout:   reload.NewValue(authTimeout),
+		videoTimeout:  reload.NewValue(videoTimeout),
+		scriptTimeout: reload.NewValue(scriptTimeout),
+	}
+}
+
+// SetTimeouts changes the upstream call timeouts of the following requests.
+func (h *OverviewHandler) SetTimeouts(auth, videos, scripts time.Duration) {
+	h.authTimeout.Store(auth)
+	h.videoTimeout.Store(videos)
+	h.scriptTimeout.Store(scripts)
+}
+
+// overviewSection is one part of the overview: Data, or Error when its
+// service failed.
+type overviewSection struct {
+	Data  any                   `json:"data"`
+	Error *overviewSectionError `json:"error,omitempty"`
+}
+
+type overviewSectionError struct {
+	Code    apierror.Code `json:"code"`
+	Message string        `json:"message"`
+	Reason  string        `json:"reason,omitempty"`
+}
+
+type overviewResponse struct {
+	User    overviewSection `json:"user"`
+	Videos  overviewSection `json:"videos"`
+	Scripts overviewSection `json:"scripts"`
+}
+
+// Overview answers 200 as long as the client is there, whichever sections
+// failed.
+func (h *OverviewHandler) Overview(c *gin.Context) {
+	userID := currentUserID(c)
+	headers := userHeaders(c)
+	ctx := c.Request.Context()
+	authTimeout := callTimeout(c, h.authTimeout.Load())
+	videoTimeout := callTimeout(c, h.videoTimeout.Load())
+	scriptTimeout := callTimeout(c, h.scriptTimeout.Load())
+
+	var out overviewResponse
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		ctx, cancel := context.WithTimeout(ctx, authTimeout)
+		defer cancel()
+		resp, err := h.auth.GetUser(ctx, &authv1.GetUserRequest{UserId: userID})
+		if err != nil {
+			out.User = h.authFailed(err)
+			return
+		}
+		out.User = overviewSection{Data: convertUser(resp.GetUser())}
+	}()
+	go func() {
+		defer wg.Done()
+		ctx, cancel := context.WithTimeout(ctx, videoTimeout)
+		defer cancel()
+		resp, err := h.videos.ListVideos(ctx, headers)
+		if err != nil {
+			out.Videos = h.upstreamFailed("video", err)
+			return
+		}
+		out.Videos = h.list("video", resp.StatusCode, h.videoMasker.Apply(resp.Body), func(body []byte) ([]json.RawMessage, error) {
+			page, err := decodeVideosPage(body)
+			return page.Videos, err
+		})
+	}()
+	go func() {
+		defer wg.Done()
+		ctx, cancel := context.WithTimeout(ctx, scriptTimeout)
+		defer cancel()
+		resp, err := h.scripts.ListScripts(ctx, headers)
+		if err != nil {
+			out.Scripts = h.upstreamFailed("script", err)
+			return
+		}
+		out.Scripts = h.list("script", resp.StatusCode, h.scriptMasker.Apply(resp.Body), decodeScriptsList)
+	}()
+	wg.Wait()
+	if c.Request.Context().Err() != nil {
+		markAbandoned(c)
+		return
+	}
+	writeJSON(c, http.StatusOK, out)
+}
+
+// list reads a listing answer into a section.
+func (h *OverviewHandler) list(service string, status int, body []byte, decode func([]byte) ([]json.RawMessage, error)) overviewSection {
+	if status != http.StatusOK {
+		h.log.Warn("overview listing failed", slog.String("upstream", service), slog.Int("status", status))
+		return overviewSection{Error: &overviewSectionError{
+			Code:    apierror.FromHTTPStatus(status),
+			Message: fmt.Sprintf("%s service answered %d", service, status),
+		}}
+	}
+	items, err := decode(body)
+	if err != nil {
+		h.log.Warn("decode overview listing failed", slog.String("upstream", service), slog.String("err", err.Error()))
+		return overviewSection{Error: &overviewSectionError{Code: apierror.CodeUpstreamError, Message: service + " service error", Reason: "invalid_response"}}
+	}
+	if items == nil {
+		items = []json.RawMessage{}
+	}
+	return overviewSection{Data: items}
+}
+
+func (h *OverviewHandler) upstreamFailed(service string, err error) overviewSection {
+	h.log.Warn("overview upstream call failed", slog.String("upstream", service), slog.String("err", err.Error()))
+	_, code, message, reason := upstreamFailure(service, err)
+	return overviewSection{Error: &overviewSectionError{Code: code, Message: message, Reason: reason}}
+}
+
+func (h *OverviewHandler) authFailed(err error) overviewSection {
+	status, code, message := authFailure(err)
+	if status >= http.StatusInternalServerError {
+		h.log.Warn("overview auth call failed", slog.String("err", err.Error()))
+	}
+	return overviewSection{Error: &overviewSectionError{Code: code, Message: message}}
+}
+
+// scriptsList is the script service listing: a bare array or an object
+// holding it. NextPageToken is set on paginated listings.
+type scriptsList struct {
+	Scripts       []json.RawMessage `json:"scripts"`
+	Items         []json.RawMessage `json:"items"`
+	NextPageToken string            `json:"next_page_token"`
+}
+
+func decodeScriptsList(body []byte) ([]json.RawMessage, error) {
+	list, err := decodeScriptsPage(body)
+	return list.Scripts, err
+}
+
+func decodeScriptsPage(body []byte) (scriptsList, error) {
+	var list scriptsList
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		err := json.Unmarshal(trimmed, &list.Scripts)
+		return list, err
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		return list, err
+	}
+	if list.Scripts == nil {
+		list.Scripts = list.Items
+	}
+	return list, nil
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/clients/egress"
+	"github.com/immxrtalbeast/api-gateway/internal/clients/scripts"
+	"github.com/immxrtalbeast/api-gateway/internal/clients/videos"
+	"github.com/immxrtalbeast/api-gateway/internal/masking"
+	"github.com/immxrtalbeast/api-gateway/internal/reload"
+)
+
+// Services reachable through the passthrough routes.
+const (
+	PassthroughScripts = "scripts"
+	PassthroughVideos  = "videos"
+)
+
+// PassthroughRoute allows Methods on Path of Service, an upstream path in
+// the syntax of egress.MatchPath ("/videos/:id/chapters", "/templates/*").
+type PassthroughRoute struct {
+	Service string
+	Methods []string
+	Path    string
+}
+
+// PassthroughHandler serves /api/ext/<service>/*path: it forwards the
+// request to path of the service as the authenticated user, when a route
+// allows it, so new upstream endpoints can be exposed from the config before
+// they get a handler. Everything else is 404, as if the route didn't exist.
+type PassthroughHandler struct {
+	log          *slog.Logger
+	scripts      *scripts.Client
+	videos       *videos.Client
+	scriptMasker *masking.Masker
+	videoMasker  *masking.Masker
+	maxBody      int64
+
+	routes        reload.Value[[]PassthroughRoute]
+	scriptTimeout *reload.Value[time.Duration]
+	videoTimeout  *reload.Value[time.Duration]
+}
+
+func NewPassthroughHandler(log *slog.Logger, scriptClient *scripts.Client, videoClient *videos.Client, scriptMasker, videoMasker *masking.Masker, routes []PassthroughRoute, maxBody int64, scriptTimeout, videoTimeout time.Duration) (*PassthroughHandler, error) {
+	h := &PassthroughHandler{
+		log:           log,
+		scripts:       scriptClient,
+		videos:        videoClient,
+		scriptMasker:  scriptMasker,
+		videoMasker:   videoMasker,
+		maxBody:       maxBody,
+		scriptTimeout: reload.NewValue(scriptTimeout),
+		videoTimeout:  reload.NewValue(videoTimeout),
+	}
+	if err := h.SetRoutes(routes); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// SetRoutes replaces the allowed routes of the following requests.
+func (h *PassthroughHandler) SetRoutes(routes []PassthroughRoute) error {
+	for i, route := range routes {
+		if route.Service != PassthroughScripts && route.Service != PassthroughVideos {
+			return fmt.Errorf("passthrough route %d: unknown service %q", i, route.Service)
+		}
+		if !strings.HasPrefix(route.Path, "/") {
+			return fmt.Errorf("passthrough route %d: path must start with /", i)
+		}
+		if len(route.Methods) == 0 {
+			return fmt.Errorf("passthrough route %d: methods are required", i)
+		}
+	}
+	h.routes.Store(routes)
+	return nil
+}
+
+// SetTimeouts changes the upstream call timeouts of the following requests.
+func (h *PassthroughHandler) SetTimeouts(scripts, videos time.Duration) {
+	h.scriptTimeout.Store(scripts)
+	h.videoTimeout.Store(videos)
+}
+
+func (h *PassthroughHandler) Scripts(c *gin.Context) {
+	target, body, ok := h.request(c, PassthroughScripts)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.scriptTimeout.Load()))
+	defer cancel()
+
+	resp, err := h.scripts.Forward(ctx, c.Request.Method, target, body, passthroughHeaders(c))
+	if err != nil {
+		if clientGone(c, err) {
+			return
+		}
+		h.log.Error("passthrough request failed", slog.String("service", PassthroughScripts), slog.String("err", err.Error()))
+		writeUpstreamError(c, "script", err)
+		return
+	}
+	h.write(c, h.scriptMasker, resp.StatusCode, resp.Header, resp.Body)
+}
+
+func (h *PassthroughHandler) Videos(c *gin.Context) {
+	target, body, ok := h.request(c, PassthroughVideos)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.videoTimeout.Load()))
+	defer cancel()
+
+	resp, err := h.videos.Forward(ctx, c.Request.Method, target, body, passthroughHeaders(c))
+	if err != nil {
+		if clientGone(c, err) {
+			return
+		}
+		h.log.Error("passthrough request failed", slog.String("service", PassthroughVideos), slog.String("err", err.Error()))
+		writeUpstreamError(c, "video", err)
+		return
+	}
+	h.write(c, h.videoMasker, resp.StatusCode, resp.Header, resp.Body)
+}
+
+// request checks the request against the routes of service and returns the
+// upstream target with the query, and the body.
+func (h *PassthroughHandler) request(c *gin.Context, service string) (string, []byte, bool) {
+	upstreamPath := c.Param("path")
+	// Dot segments ("/a/../b") are refused rather than matched, so no route
+	// can be escaped.
+	segments := strings.Split(upstreamPath, "/")
+	if slices.Contains(segments, "..") || slices.Contains(segments, ".") || !h.allowed(service, c.Request.Method, upstreamPath) {
+		writeError(c, http.StatusNotFound, "route not found")
+		return "", nil, false
+	}
+	target := (&url.URL{Path: upstreamPath}).EscapedPath()
+	if query := c.Request.URL.RawQuery; query != "" {
+		target += "?" + query
+	}
+
+	var body []byte
+	if c.Request.Body != nil {
+		var err error
+		body, err = io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, h.maxBody))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeError(c, http.StatusRequestEntityTooLarge, "request body too large")
+				return "", nil, false
+			}
+			writeError(c, http.StatusBadRequest, "failed to read request body")
+			return "", nil, false
+		}
+		if len(body) == 0 {
+			body = nil
+		}
+	}
+	return target, body, true
+}
+
+func (h *PassthroughHandler) allowed(service, method, upstreamPath string) bool {
+	for _, route := range h.routes.Load() {
+		if route.Service != service || !slices.ContainsFunc(route.Methods, func(m string) bool { return strings.EqualFold(m, method) }) {
+			continue
+		}
+		if _, ok := egress.MatchPath(route.Path, upstreamPath); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *PassthroughHandler) write(c *gin.Context, masker *masking.Masker, status int, header http.Header, body []byte) {
+	if err := writeUpstream(c, masker, status, header, body); err != nil {
+		markAbandoned(c)
+		c.Error(err)
+	}
+}
+
+// passthroughHeaders identifies the caller like the dedicated handlers do
+// and keeps the headers describing the body and the wanted answer.
+func passthroughHeaders(c *gin.Context) map[string]string {
+	headers := userHeaders(c)
+	if headers == nil {
+		headers = make(map[string]string)
+	}
+	if orgID := c.GetString("orgID"); orgID != "" {
+		headers["X-Org-ID"] = orgID
+	}
+	for _, name := range []string{"Content-Type", "Accept", "Accept-Language", "If-None-Match", "If-Match"} {
+		if value := c.GetHeader(name); value != "" {
+			headers[name] = value
+		}
+	}
+	return headers
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/audit"
+)
+
+// ReceiptHandler serves the download receipts of a video to its owner.
+type ReceiptHandler struct {
+	log      *slog.Logger
+	receipts *audit.Receipts
+	owners   *CollaboratorHandler
+}
+
+// NewReceiptHandler checks video ownership the way owners does for
+// collaborator management.
+func NewReceiptHandler(log *slog.Logger, receipts *audit.Receipts, owners *CollaboratorHandler) *ReceiptHandler {
+	return &ReceiptHandler{log: log, receipts: receipts, owners: owners}
+}
+
+type receiptsResponse struct {
+	VideoID  string          `json:"video_id"`
+	Receipts []audit.Receipt `json:"receipts"`
+}
+
+func (h *ReceiptHandler) List(c *gin.Context) {
+	videoID := c.Param("id")
+	if !h.owners.ensureOwner(c, videoID, currentUserID(c), "only the video owner can see download receipts") {
+		return
+	}
+	receipts, err := h.receipts.List(c.Request.Context(), videoID)
+	if err != nil {
+		h.log.Error("list download receipts failed", slog.String("video_id", videoID), slog.String("err", err.Error()))
+		writeError(c, http.StatusInternalServerError, "failed to load download receipts")
+		return
+	}
+	writeJSON(c, http.StatusOK, receiptsResponse{VideoID: videoID, Receipts: receipts})
+}
@@ -1,15 +1,204 @@
 package handlers
 
-import "github.com/gin-gonic/gin"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/apierror"
+	"github.com/immxrtalbeast/api-gateway/internal/masking"
+	"github.com/immxrtalbeast/api-gateway/internal/metrics"
+	"github.com/immxrtalbeast/api-gateway/internal/timing"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// statusClientClosedRequest mirrors nginx's 499 for requests the client abandoned.
+const statusClientClosedRequest = 499
 
 func writeJSON(c *gin.Context, status int, payload interface{}) {
 	if payload == nil {
 		c.Status(status)
 		return
 	}
+	defer timing.Since(c.Request.Context(), timing.Serialization, time.Now())
 	c.JSON(status, payload)
 }
 
 func writeError(c *gin.Context, status int, message string) {
-	c.AbortWithStatusJSON(status, gin.H{"error": message})
+	apierror.Abort(c, status, apierror.FromHTTPStatus(status), message, nil)
+}
+
+// writeUpstreamError reports a failed call to an HTTP upstream: 504 when the
+// call ran out of time, 502 otherwise. details.reason is a fixed category, so
+// no addresses or raw upstream messages leak to clients.
+func writeUpstreamError(c *gin.Context, service string, err error) {
+	c.Error(fmt.Errorf("%s: %w", service, err))
+	status, code, message, reason := upstreamFailure(service, err)
+	apierror.Abort(c, status, code, message, map[string]any{"upstream": service, "reason": reason})
+}
+
+// upstreamFailure classifies a failed call to an HTTP upstream for clients,
+// for writeUpstreamError and the handlers reporting it within a response.
+func upstreamFailure(service string, err error) (int, apierror.Code, string, string) {
+	reason := upstreamFailureReason(err)
+	switch reason {
+	case "timeout":
+		return http.StatusGatewayTimeout, apierror.CodeUpstreamTimeout, service + " service timed out", reason
+	case "connection_refused", "dns", "connection_reset":
+		return http.StatusBadGateway, apierror.CodeUpstreamUnreachable, service + " service unreachable", reason
+	default:
+		return http.StatusBadGateway, apierror.CodeUpstreamError, service + " service error", reason
+	}
+}
+
+func upstreamFailureReason(err error) string {
+	var netErr net.Error
+	var dnsErr *net.DNSError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &dnsErr):
+		return "dns"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "connection_refused"
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		return "connection_reset"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	}
+	return "upstream_error"
+}
+
+// clientGone reports whether err is the result of the client disconnecting
+// mid-request. In that case the upstream call has already been cancelled via
+// the request context, so the request is only accounted for and aborted.
+func clientGone(c *gin.Context, err error) bool {
+	canceled := errors.Is(err, context.Canceled) || status.Code(err) == codes.Canceled
+	if !canceled || c.Request.Context().Err() == nil {
+		return false
+	}
+	markAbandoned(c)
+	c.AbortWithStatus(statusClientClosedRequest)
+	return true
+}
+
+// callTimeout returns the upstream call timeout of the request: the route's
+// override set by middleware.RouteTimeouts, otherwise the service timeout.
+func callTimeout(c *gin.Context, service time.Duration) time.Duration {
+	if timeout, ok := c.Get("routeTimeout"); ok {
+		if d, ok := timeout.(time.Duration); ok && d > 0 {
+			return d
+		}
+	}
+	return service
+}
+
+func markAbandoned(c *gin.Context) {
+	route := c.FullPath()
+	if route == "" {
+		route = "unknown"
+	}
+	metrics.AbandonedRequests.Add(route, 1)
+}
+
+// writeUpstream copies an upstream response to the client. JSON bodies are
+// masked and upstream 429s are mapped to the error envelope.
+func writeUpstream(c *gin.Context, masker *masking.Masker, status int, header http.Header, body []byte) error {
+	for k, v := range header {
+		if strings.EqualFold(k, "Content-Length") {
+			continue
+		}
+		for _, value := range v {
+			c.Writer.Header().Add(k, value)
+		}
+	}
+	if status == http.StatusTooManyRequests {
+		writeRateLimited(c, header, body)
+		return nil
+	}
+	if c.Writer.Header().Get("Content-Type") == "" {
+		c.Writer.Header().Set("Content-Type", "application/json")
+	}
+	start := time.Now()
+	body = maskJSON(masker, c.Writer.Header().Get("Content-Type"), body)
+	timing.Since(c.Request.Context(), timing.Serialization, start)
+	c.Status(status)
+	if len(body) == 0 {
+		return nil
+	}
+	_, err := c.Writer.Write(body)
+	return err
+}
+
+// maskJSON applies the masking rules to JSON upstream bodies only.
+func maskJSON(masker *masking.Masker, contentType string, body []byte) []byte {
+	if masker == nil {
+		return body
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType != "application/json" {
+		return body
+	}
+	return masker.Apply(body)
+}
+
+// writeRateLimited turns an upstream 429 into the error envelope. The
+// upstream headers (Retry-After, X-RateLimit-*) have already been copied; the
+// delay is additionally exposed as details.retry_after_seconds so clients
+// don't have to parse HTTP dates.
+func writeRateLimited(c *gin.Context, header http.Header, body []byte) {
+	message := upstreamMessage(body)
+	if message == "" {
+		message = "upstream rate limit exceeded"
+	}
+	var details map[string]any
+	if seconds, ok := retryAfterSeconds(header.Get("Retry-After"), time.Now()); ok {
+		details = map[string]any{"retry_after_seconds": seconds}
+	}
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	apierror.Abort(c, http.StatusTooManyRequests, apierror.CodeRateLimited, message, details)
+}
+
+// retryAfterSeconds parses both forms of Retry-After: delta-seconds and
+// HTTP-date.
+func retryAfterSeconds(value string, now time.Time) (int, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(seconds, 0), true
+	}
+	at, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	return max(int(math.Ceil(at.Sub(now).Seconds())), 0), true
+}
+
+// upstreamMessage extracts a human-readable message from common upstream
+// error shapes ({"error": "..."}, {"detail": "..."}, {"message": "..."}).
+func upstreamMessage(body []byte) string {
+	var doc map[string]any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return ""
+	}
+	for _, key := range []string{"error", "detail", "message"} {
+		if msg, ok := doc[key].(string); ok && msg != "" {
+			return msg
+		}
+	}
+	return ""
 }
@@ -4,23 +4,30 @@ import (
 	"context"
 	"io"
 	"net/http"
-	"strings"
 	"time"
 
 	"log/slog"
 
 	"github.com/gin-gonic/gin"
 	"github.com/immxrtalbeast/api-gateway/internal/clients/scripts"
+	"github.com/immxrtalbeast/api-gateway/internal/masking"
+	"github.com/immxrtalbeast/api-gateway/internal/reload"
 )
 
 type ScriptHandler struct {
 	log     *slog.Logger
 	client  *scripts.Client
-	timeout time.Duration
+	timeout *reload.Value[time.Duration]
+	masker  *masking.Masker
 }
 
-func NewScriptHandler(log *slog.Logger, client *scripts.Client, timeout time.Duration) *ScriptHandler {
-	return &ScriptHandler{log: log, client: client, timeout: timeout}
+func NewScriptHandler(log *slog.Logger, client *scripts.Client, timeout time.Duration, masker *masking.Masker) *ScriptHandler {
+	return &ScriptHandler{log: log, client: client, timeout: reload.NewValue(timeout), masker: masker}
+}
+
+// SetTimeout changes the upstream call timeout of the following requests.
+func (h *ScriptHandler) SetTimeout(timeout time.Duration) {
+	h.timeout.Store(timeout)
 }
 
 func (h *ScriptHandler) CreateScript(c *gin.Context) {
@@ -29,47 +36,40 @@ func (h *ScriptHandler) CreateScript(c *gin.Context) {
 		writeError(c, http.StatusBadRequest, "failed to read request body")
 		return
 	}
-	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeout)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
 	defer cancel()
 
-	resp, err := h.client.CreateScript(ctx, body)
+	resp, err := h.client.CreateScript(ctx, body, userHeaders(c))
 	if err != nil {
+		if clientGone(c, err) {
+			return
+		}
 		h.log.Error("script create failed", slog.String("err", err.Error()))
-		writeError(c, http.StatusBadGateway, "script service error")
+		writeUpstreamError(c, "script", err)
 		return
 	}
 	h.forwardResponse(c, resp)
 }
 
 func (h *ScriptHandler) ListScripts(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeout)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
 	defer cancel()
 
-	resp, err := h.client.ListScripts(ctx)
+	resp, err := h.client.ListScripts(ctx, userHeaders(c))
 	if err != nil {
+		if clientGone(c, err) {
+			return
+		}
 		h.log.Error("list scripts failed", slog.String("err", err.Error()))
-		writeError(c, http.StatusBadGateway, "script service error")
+		writeUpstreamError(c, "script", err)
 		return
 	}
 	h.forwardResponse(c, resp)
 }
 
 func (h *ScriptHandler) forwardResponse(c *gin.Context, resp *scripts.Response) {
-	for k, v := range resp.Header {
-		if strings.EqualFold(k, "Content-Length") {
-			continue
-		}
-		for _, value := range v {
-			c.Writer.Header().Add(k, value)
-		}
-	}
-	if c.Writer.Header().Get("Content-Type") == "" {
-		c.Writer.Header().Set("Content-Type", "application/json")
-	}
-	c.Status(resp.StatusCode)
-	if len(resp.Body) > 0 {
-		if _, err := c.Writer.Write(resp.Body); err != nil {
-			h.log.Error("write response failed", slog.String("err", err.Error()))
-		}
+	if err := writeUpstream(c, h.masker, resp.StatusCode, resp.Header, resp.Body); err != nil {
+		markAbandoned(c)
+		h.log.Error("write response failed", slog.String("err", err.Error()))
 	}
 }
@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/clients/videos"
+	"github.com/immxrtalbeast/api-gateway/internal/masking"
+)
+
+const (
+	maxSuggestQueryLength = 100
+	maxSuggestEntries     = 10000
+)
+
+// SuggestOptions tunes GET /api/search/suggest.
+type SuggestOptions struct {
+	// Timeout bounds the upstream call; autocomplete answers are worthless
+	// once the user has typed on.
+	Timeout time.Duration
+	// Debounce is how long a query waits for a newer one from the same user
+	// before it is sent upstream.
+	Debounce  time.Duration
+	CacheTTL  time.Duration
+	MinLength int
+	Limit     int
+}
+
+// SearchHandler serves search-as-you-type suggestions from the video
+// service. Answers are cached per user and query; a query extending a cached
+// prefix whose answer was complete (fewer than Limit items) is filtered
+// locally, and a query superseded by a newer one from the same user within
+// Debounce is not sent upstream at all.
+type SearchHandler struct {
+	log    *slog.Logger
+	client *videos.Client
+	opts   SuggestOptions
+	masker *masking.Masker
+
+	mu     sync.Mutex
+	cache  map[string]suggestEntry
+	latest map[string]uint64
+	seq    uint64
+}
+
+type suggestEntry struct {
+	items    []json.RawMessage
+	texts    []string
+	complete bool
+	expires  time.Time
+}
+
+func NewSearchHandler(log *slog.Logger, client *videos.Client, opts SuggestOptions, masker *masking.Masker) *SearchHandler {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 800 * time.Millisecond
+	}
+	if opts.MinLength <= 0 {
+		opts.MinLength = 1
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = 10
+	}
+	return &SearchHandler{
+		log:    log,
+		client: client,
+		opts:   opts,
+		masker: masker,
+		cache:  make(map[string]suggestEntry),
+		latest: make(map[string]uint64),
+	}
+}
+
+func (h *SearchHandler) Suggest(c *gin.Context) {
+	query := strings.ToLower(strings.Join(strings.Fields(c.Query("q")), " "))
+	length := utf8.RuneCountInString(query)
+	if length > maxSuggestQueryLength {
+		writeError(c, http.StatusBadRequest, "q is too long")
+		return
+	}
+	if length < h.opts.MinLength {
+		h.respond(c, query, nil, "SKIP")
+		return
+	}
+	userID := currentUserID(c)
+
+	if items, source, ok := h.lookup(userID, query); ok {
+		h.respond(c, query, items, source)
+		return
+	}
+
+	if h.opts.Debounce > 0 {
+		seq := h.arrive(userID)
+		select {
+		case <-time.After(h.opts.Debounce):
+		case <-c.Request.Context().Done():
+			markAbandoned(c)
+			return
+		}
+		if h.superseded(userID, seq) {
+			c.Header("X-Suggest-Superseded", "true")
+			h.respond(c, query, nil, "SUPERSEDED")
+			return
+		}
+		// A query with the same prefix may have been answered meanwhile.
+		if items, source, ok := h.lookup(userID, query); ok {
+			h.respond(c, query, items, source)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.opts.Timeout)
+	defer cancel()
+
+	resp, err := h.client.SearchSuggest(ctx, query, h.opts.Limit, userHeaders(c))
+	if err != nil {
+		if clientGone(c, err) {
+			return
+		}
+		h.log.Warn("search suggest failed", slog.String("err", err.Error()))
+		writeUpstreamError(c, "video", err)
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		if err := writeUpstream(c, h.masker, resp.StatusCode, resp.Header, resp.Body); err != nil {
+			markAbandoned(c)
+		}
+		return
+	}
+	var payload struct {
+		Suggestions []json.RawMessage `json:"suggestions"`
+	}
+	if err := json.Unmarshal(h.masker.Apply(resp.Body), &payload); err != nil {
+		h.log.Warn("decode search suggestions failed", slog.String("err", err.Error()))
+		writeError(c, http.StatusBadGateway, "invalid suggestions from video service")
+		return
+	}
+	h.store(userID, query, payload.Suggestions)
+	h.respond(c, query, payload.Suggestions, "MISS")
+}
+
+func (h *SearchHandler) respond(c *gin.Context, query string, items []json.RawMessage, source string) {
+	if items == nil {
+		items = []json.RawMessage{}
+	}
+	c.Header("X-Cache", source)
+	writeJSON(c, http.StatusOK, map[string]any{"query": query, "suggestions": items})
+}
+
+// lookup answers from the cache: either the exact query, or the longest
+// cached prefix whose answer was complete, filtered down to the items that
+// still match.
+func (h *SearchHandler) lookup(userID, query string) ([]json.RawMessage, string, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now()
+	if entry, ok := h.cache[suggestKey(userID, query)]; ok && now.Before(entry.expires) {
+		return entry.items, "HIT", true
+	}
+	runes := []rune(query)
+	for n := len(runes) - 1; n >= h.opts.MinLength; n-- {
+		entry, ok := h.cache[suggestKey(userID, string(runes[:n]))]
+		if !ok || !entry.complete || !now.Before(entry.expires) {
+			continue
+		}
+		narrowed := suggestEntry{complete: true, expires: entry.expires}
+		for i, text := range entry.texts {
+			if strings.Contains(text, query) {
+				narrowed.items = append(narrowed.items, entry.items[i])
+				narrowed.texts = append(narrowed.texts, text)
+			}
+		}
+		h.cache[suggestKey(userID, query)] = narrowed
+		return narrowed.items, "PREFIX", true
+	}
+	return nil, "", false
+}
+
+func (h *SearchHandler) store(userID, query string, items []json.RawMessage) {
+	entry := suggestEntry{
+		items:    items,
+		texts:    make([]string, len(items)),
+		complete: len(items) < h.opts.Limit,
+		expires:  time.Now().Add(h.opts.CacheTTL),
+	}
+	for i, item := range items {
+		text, ok := suggestionText(item)
+		if !ok {
+			// Without the text the item can't be matched locally.
+			entry.complete = false
+		}
+		entry.texts[i] = text
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.opts.CacheTTL <= 0 {
+		return
+	}
+	if len(h.cache) >= maxSuggestEntries {
+		now := time.Now()
+		for key, cached := range h.cache {
+			if !now.Before(cached.expires) {
+				delete(h.cache, key)
+			}
+		}
+		if len(h.cache) >= maxSuggestEntries {
+			h.cache = make(map[string]suggestEntry)
+		}
+	}
+	h.cache[suggestKey(userID, query)] = entry
+}
+
+func (h *SearchHandler) arrive(userID string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.seq++
+	h.latest[userID] = h.seq
+	return h.seq
+}
+
+// superseded reports whether a newer query from the same user arrived while
+// seq was waiting out the debounce window.
+func (h *SearchHandler) superseded(userID string, seq uint64) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.latest[userID] != seq {
+		return true
+	}
+	delete(h.latest, userID)
+	return false
+}
+
+func suggestKey(userID, query string) string {
+	return userID + "\x00" + query
+}
+
+// suggestionText extracts the matchable text of a suggestion, which is
+// either a plain string or an object with a "text" field.
+func suggestionText(item json.RawMessage) (string, bool) {
+	var text string
+	if err := json.Unmarshal(item, &text); err == nil {
+		return strings.ToLower(text), true
+	}
+	var obj struct {
+		Text *string `json:"text"`
+	}
+	if err := json.Unmarshal(item, &obj); err == nil && obj.Text != nil {
+		return strings.ToLower(*obj.Text), true
+	}
+	return "", false
+}
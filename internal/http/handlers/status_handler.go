@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/health"
+	"github.com/immxrtalbeast/api-gateway/internal/maintenance"
+)
+
+// Component states shown on the status page, from best to worst.
+const (
+	ComponentOperational = "operational"
+	ComponentMaintenance = "maintenance"
+	ComponentDegraded    = "degraded"
+	ComponentOutage      = "outage"
+)
+
+var componentSeverity = []string{ComponentOperational, ComponentMaintenance, ComponentDegraded, ComponentOutage}
+
+// StatusComponent is a part of the product as users know it ("rendering",
+// "uploads"), backed by upstreams, gateway routes and maintenance groups.
+type StatusComponent struct {
+	Name string
+	// Upstreams are health monitor names; a degraded one is an outage, a
+	// failing one not yet degraded makes the component degraded.
+	Upstreams []string
+	// Routes are route template prefixes whose recent server error rate
+	// counts against the component.
+	Routes []string
+	// MaintenanceGroups in maintenance put the component in maintenance.
+	MaintenanceGroups []string
+}
+
+// StatusPageOptions configures GET /api/status. Routes with fewer than
+// MinRequests requests in the error window are considered healthy.
+type StatusPageOptions struct {
+	Components        []StatusComponent
+	CacheTTL          time.Duration
+	MinRequests       int
+	DegradedErrorRate float64
+	OutageErrorRate   float64
+}
+
+type StatusHandler struct {
+	monitor     *health.Monitor
+	rates       *health.ErrorRates
+	maintenance *maintenance.Switch
+	opts        StatusPageOptions
+
+	mu       sync.Mutex
+	page     statusPage
+	computed time.Time
+}
+
+type statusPage struct {
+	Status     string            `json:"status"`
+	Components []componentStatus `json:"components"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+}
+
+type componentStatus struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// NewStatusHandler shows one component per monitored upstream when
+// opts.Components is empty.
+func NewStatusHandler(monitor *health.Monitor, rates *health.ErrorRates, sw *maintenance.Switch, opts StatusPageOptions) *StatusHandler {
+	if len(opts.Components) == 0 {
+		for _, st := range monitor.Snapshot() {
+			opts.Components = append(opts.Components, StatusComponent{Name: st.Name, Upstreams: []string{st.Name}})
+		}
+	}
+	return &StatusHandler{monitor: monitor, rates: rates, maintenance: sw, opts: opts}
+}
+
+// Status serves the user-facing status page data for the frontend banner.
+// It reveals no upstream details and is computed at most once per CacheTTL.
+func (h *StatusHandler) Status(c *gin.Context) {
+	page := h.current()
+	if h.opts.CacheTTL > 0 {
+		c.Header("Cache-Control", "public, max-age="+strconv.Itoa(int(h.opts.CacheTTL.Seconds())))
+	}
+	writeJSON(c, http.StatusOK, page)
+}
+
+// Upstreams lists the health monitor state of every upstream for admins.
+func (h *StatusHandler) Upstreams(c *gin.Context) {
+	upstreams := h.monitor.Snapshot()
+	overall := "ok"
+	for _, st := range upstreams {
+		if st.Degraded {
+			overall = "degraded"
+			break
+		}
+	}
+	writeJSON(c, http.StatusOK, map[string]any{
+		"status":    overall,
+		"upstreams": upstreams,
+	})
+}
+
+func (h *StatusHandler) current() statusPage {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now()
+	if h.computed.IsZero() || now.Sub(h.computed) >= h.opts.CacheTTL {
+		h.page = h.compute(now)
+		h.computed = now
+	}
+	return h.page
+}
+
+func (h *StatusHandler) compute(now time.Time) statusPage {
+	upstreams := make(map[string]health.Status)
+	for _, st := range h.monitor.Snapshot() {
+		upstreams[st.Name] = st
+	}
+	groups := make(map[string]maintenance.Status)
+	if h.maintenance != nil {
+		for _, group := range h.maintenance.Groups() {
+			groups[group.Name] = group
+		}
+	}
+
+	page := statusPage{Status: ComponentOperational, Components: make([]componentStatus, 0, len(h.opts.Components)), UpdatedAt: now.UTC()}
+	for _, component := range h.opts.Components {
+		status := componentStatus{Name: component.Name, Status: ComponentOperational}
+		for _, name := range component.Upstreams {
+			st, ok := upstreams[name]
+			switch {
+			case !ok:
+			case st.Degraded:
+				status.Status = worse(status.Status, ComponentOutage)
+			case !st.Healthy:
+				status.Status = worse(status.Status, ComponentDegraded)
+			}
+		}
+		if len(component.Routes) > 0 && h.rates != nil {
+			requests, failed := h.rates.Count(component.Routes)
+			if requests > 0 && requests >= h.opts.MinRequests {
+				rate := float64(failed) / float64(requests)
+				switch {
+				case h.opts.OutageErrorRate > 0 && rate >= h.opts.OutageErrorRate:
+					status.Status = worse(status.Status, ComponentOutage)
+				case h.opts.DegradedErrorRate > 0 && rate >= h.opts.DegradedErrorRate:
+					status.Status = worse(status.Status, ComponentDegraded)
+				}
+			}
+		}
+		if status.Status == ComponentOperational {
+			for _, name := range component.MaintenanceGroups {
+				if group, ok := groups[name]; ok && group.Active {
+					status.Status = ComponentMaintenance
+					status.Message = group.Message
+					break
+				}
+			}
+		}
+		page.Status = worse(page.Status, status.Status)
+		page.Components = append(page.Components, status)
+	}
+	return page
+}
+
+func worse(a, b string) string {
+	if slices.Index(componentSeverity, b) > slices.Index(componentSeverity, a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/apierror"
+	"github.com/immxrtalbeast/api-gateway/internal/clients/videos"
+)
+
+const (
+	opApproveTranslation = "approve_subtitle_translation"
+	maxTargetLanguages   = 20
+)
+
+// languageTag loosely matches BCP 47 tags such as "en", "pt-BR" or "zh-Hant".
+var languageTag = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+type translationsRequest struct {
+	Languages []string `json:"languages"`
+}
+
+// RequestSubtitleTranslations asks the video service to generate translated
+// subtitle tracks for the listed target languages.
+func (h *VideoHandler) RequestSubtitleTranslations(c *gin.Context) {
+	jobID := c.Param("id")
+	body, err := readJSONBody(c.Request.Body)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, "failed to read request body")
+		return
+	}
+	var req translationsRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeError(c, http.StatusBadRequest, "invalid json payload")
+		return
+	}
+	if fields := validateLanguages(req.Languages); len(fields) > 0 {
+		apierror.Abort(c, http.StatusUnprocessableEntity, apierror.CodeValidationFailed, "request validation failed", map[string]any{
+			"fields": fields,
+		})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
+	defer cancel()
+
+	resp, err := h.client.RequestSubtitleTranslations(ctx, jobID, body, userHeaders(c))
+	h.recordJobResult(jobID, "request_subtitle_translations", resp, err)
+	if err != nil {
+		if clientGone(c, err) {
+			return
+		}
+		h.log.Error("subtitle translations request failed", slog.String("err", err.Error()))
+		writeUpstreamError(c, "video", err)
+		return
+	}
+	h.forwardResponse(c, resp)
+}
+
+func (h *VideoHandler) ListSubtitleTranslations(c *gin.Context) {
+	jobID := c.Param("id")
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
+	defer cancel()
+
+	resp, err := h.client.ListSubtitleTranslations(ctx, jobID, userHeaders(c))
+	if err != nil {
+		if clientGone(c, err) {
+			return
+		}
+		h.log.Error("subtitle translations list failed", slog.String("err", err.Error()))
+		writeUpstreamError(c, "video", err)
+		return
+	}
+	h.forwardResponse(c, resp)
+}
+
+func (h *VideoHandler) ApproveSubtitleTranslation(c *gin.Context) {
+	jobID := c.Param("id")
+	language := c.Param("lang")
+	if !languageTag.MatchString(language) {
+		writeError(c, http.StatusBadRequest, "invalid language")
+		return
+	}
+	h.approve(c, opApproveTranslation, jobID, translationResourceID(jobID, language), func(ctx context.Context, payload []byte, headers map[string]string) (*videos.Response, error) {
+		return h.client.ApproveSubtitleTranslation(ctx, jobID, language, payload, headers)
+	})
+}
+
+func validateLanguages(languages []string) []apierror.FieldError {
+	if len(languages) == 0 {
+		return []apierror.FieldError{{Field: "/languages", Message: "at least one target language is required"}}
+	}
+	if len(languages) > maxTargetLanguages {
+		return []apierror.FieldError{{Field: "/languages", Message: fmt.Sprintf("at most %d target languages are allowed", maxTargetLanguages)}}
+	}
+	var res []apierror.FieldError
+	seen := make(map[string]bool, len(languages))
+	for i, lang := range languages {
+		field := fmt.Sprintf("/languages/%d", i)
+		switch key := strings.ToLower(lang); {
+		case !languageTag.MatchString(lang):
+			res = append(res, apierror.FieldError{Field: field, Message: "invalid language tag"})
+		case seen[key]:
+			res = append(res, apierror.FieldError{Field: field, Message: "duplicate language"})
+		default:
+			seen[key] = true
+		}
+	}
+	return res
+}
+
+// translationResourceID packs the job and language into the journal
+// resource ID; splitTranslationResourceID reverses it.
+func translationResourceID(jobID, language string) string {
+	return jobID + "/" + language
+}
+
+func splitTranslationResourceID(id string) (string, string) {
+	jobID, language, _ := strings.Cut(id, "/")
+	return jobID, language
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/changefeed"
+	"github.com/immxrtalbeast/api-gateway/internal/clients/scripts"
+	"github.com/immxrtalbeast/api-gateway/internal/clients/videos"
+	"github.com/immxrtalbeast/api-gateway/internal/masking"
+	"github.com/immxrtalbeast/api-gateway/internal/reload"
+)
+
+const (
+	syncSourceVideos  = "videos"
+	syncSourceScripts = "scripts"
+	syncSourceEvents  = "events"
+)
+
+// SyncHandler serves GET /api/sync: everything that changed for the user
+// since a cursor, merged from the video service change feed (videos and
+// media), the script service change feed and the realtime job updates seen
+// by the gateway. The cursor is opaque to clients.
+type SyncHandler struct {
+	log          *slog.Logger
+	videos       *videos.Client
+	scripts      *scripts.Client
+	changes      *changefeed.Log
+	timeout      *reload.Value[time.Duration]
+	videoMasker  *masking.Masker
+	scriptMasker *masking.Masker
+}
+
+func NewSyncHandler(log *slog.Logger, videoClient *videos.Client, scriptClient *scripts.Client, changes *changefeed.Log, timeout time.Duration, videoMasker, scriptMasker *masking.Masker) *SyncHandler {
+	return &SyncHandler{
+		log:          log,
+		videos:       videoClient,
+		scripts:      scriptClient,
+		changes:      changes,
+		timeout:      reload.NewValue(timeout),
+		videoMasker:  videoMasker,
+		scriptMasker: scriptMasker,
+	}
+}
+
+// SetTimeout changes the upstream call timeout of the following requests.
+func (h *SyncHandler) SetTimeout(timeout time.Duration) {
+	h.timeout.Store(timeout)
+}
+
+// syncCursor remembers the position in every source.
+type syncCursor struct {
+	Videos  string `json:"v,omitempty"`
+	Scripts string `json:"s,omitempty"`
+	Epoch   string `json:"e,omitempty"`
+	Seq     uint64 `json:"q,omitempty"`
+}
+
+func (cur syncCursor) encode() string {
+	raw, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeSyncCursor(s string) (syncCursor, error) {
+	var cur syncCursor
+	if s == "" {
+		return cur, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cur, err
+	}
+	err = json.Unmarshal(raw, &cur)
+	return cur, err
+}
+
+type syncChange struct {
+	Source string          `json:"source"`
+	JobID  string          `json:"job_id,omitempty"`
+	At     *time.Time      `json:"at,omitempty"`
+	Change json.RawMessage `json:"change"`
+}
+
+type syncSourceError struct {
+	Source string `json:"source"`
+	Reason string `json:"reason"`
+}
+
+// feedPage is one answer of an upstream change feed.
+type feedPage struct {
+	Changes    []json.RawMessage `json:"changes"`
+	NextCursor string            `json:"next_cursor"`
+	HasMore    bool              `json:"has_more"`
+}
+
+type feedResult struct {
+	page   feedPage
+	reason string
+}
+
+func (h *SyncHandler) Sync(c *gin.Context) {
+	cur, err := decodeSyncCursor(c.Query("cursor"))
+	if err != nil {
+		writeError(c, http.StatusBadRequest, "invalid cursor")
+		return
+	}
+	headers := userHeaders(c)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
+	defer cancel()
+
+	var videoFeed, scriptFeed feedResult
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		resp, err := h.videos.ListChanges(ctx, cur.Videos, headers)
+		if err != nil {
+			videoFeed = h.readFeed(syncSourceVideos, 0, nil, err)
+			return
+		}
+		videoFeed = h.readFeed(syncSourceVideos, resp.StatusCode, resp.Body, nil)
+	}()
+	go func() {
+		defer wg.Done()
+		resp, err := h.scripts.ListChanges(ctx, cur.Scripts, headers)
+		if err != nil {
+			scriptFeed = h.readFeed(syncSourceScripts, 0, nil, err)
+			return
+		}
+		scriptFeed = h.readFeed(syncSourceScripts, resp.StatusCode, resp.Body, nil)
+	}()
+	wg.Wait()
+	if c.Request.Context().Err() != nil {
+		markAbandoned(c)
+		return
+	}
+
+	next := cur
+	changes := make([]syncChange, 0)
+	var failures []syncSourceError
+	hasMore := false
+	for _, feed := range []struct {
+		source string
+		result feedResult
+		cursor *string
+		masker *masking.Masker
+	}{
+		{syncSourceVideos, videoFeed, &next.Videos, h.videoMasker},
+		{syncSourceScripts, scriptFeed, &next.Scripts, h.scriptMasker},
+	} {
+		if feed.result.reason != "" {
+			// The source keeps its old cursor and is retried on the next sync.
+			failures = append(failures, syncSourceError{Source: feed.source, Reason: feed.result.reason})
+			continue
+		}
+		for _, change := range feed.result.page.Changes {
+			changes = append(changes, syncChange{Source: feed.source, Change: feed.masker.Apply(change)})
+		}
+		if feed.result.page.NextCursor != "" {
+			*feed.cursor = feed.result.page.NextCursor
+		}
+		hasMore = hasMore || feed.result.page.HasMore
+	}
+
+	resync := false
+	if h.changes != nil {
+		events, seq, complete := h.changes.Since(currentUserID(c), cur.Epoch, cur.Seq)
+		for _, event := range events {
+			at := event.At
+			changes = append(changes, syncChange{Source: syncSourceEvents, JobID: event.JobID, At: &at, Change: h.videoMasker.Apply(event.Payload)})
+		}
+		next.Epoch, next.Seq = h.changes.Epoch(), seq
+		resync = !complete
+	}
+
+	writeJSON(c, http.StatusOK, map[string]any{
+		"changes":  changes,
+		"cursor":   next.encode(),
+		"has_more": hasMore,
+		"resync":   resync,
+		"errors":   failures,
+	})
+}
+
+// readFeed decodes a change feed answer; on failure it returns a reason
+// suitable for clients instead.
+func (h *SyncHandler) readFeed(source string, status int, body []byte, err error) feedResult {
+	if err != nil {
+		h.log.Warn("sync feed failed", slog.String("source", source), slog.String("err", err.Error()))
+		return feedResult{reason: upstreamFailureReason(err)}
+	}
+	if status != http.StatusOK {
+		h.log.Warn("sync feed failed", slog.String("source", source), slog.Int("status", status))
+		return feedResult{reason: fmt.Sprintf("status_%d", status)}
+	}
+	var page feedPage
+	if err := json.Unmarshal(body, &page); err != nil {
+		h.log.Warn("decode sync feed failed", slog.String("source", source), slog.String("err", err.Error()))
+		return feedResult{reason: "invalid_response"}
+	}
+	return feedResult{page: page}
+}
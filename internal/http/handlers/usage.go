@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/usage"
+)
+
+// UsageHandler reports the caller's metered usage for the current month.
+type UsageHandler struct {
+	log     *slog.Logger
+	store   usage.Store
+	quotas  usage.Quotas
+	timeout time.Duration
+}
+
+func NewUsageHandler(log *slog.Logger, store usage.Store, quotas usage.Quotas, timeout time.Duration) *UsageHandler {
+	return &UsageHandler{log: log, store: store, quotas: quotas, timeout: timeout}
+}
+
+type usageMetric struct {
+	Used int64 `json:"used"`
+	// Limit and Remaining are omitted for unlimited metrics.
+	Limit     *int64 `json:"limit,omitempty"`
+	Remaining *int64 `json:"remaining,omitempty"`
+}
+
+func (h *UsageHandler) Usage(c *gin.Context) {
+	if h.store == nil {
+		writeError(c, http.StatusNotFound, "usage metering is disabled")
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeout)
+	defer cancel()
+
+	now := time.Now()
+	period := usage.Period(now)
+	counters, err := h.store.Get(ctx, currentUserID(c), period)
+	if err != nil {
+		h.log.Error("get usage failed", slog.String("err", err.Error()))
+		writeError(c, http.StatusServiceUnavailable, "usage store unavailable")
+		return
+	}
+
+	plan := c.GetString("userPlan")
+	if plan == "" {
+		plan = h.quotas.DefaultPlan
+	}
+	metrics := make(map[string]usageMetric, len(usage.Metrics))
+	for _, name := range usage.Metrics {
+		metric := usageMetric{Used: max(counters[name], 0)}
+		if limit := h.quotas.Limit(plan, name); limit > 0 {
+			remaining := max(limit-metric.Used, 0)
+			metric.Limit = &limit
+			metric.Remaining = &remaining
+		}
+		metrics[name] = metric
+	}
+	writeJSON(c, http.StatusOK, map[string]any{
+		"period":    period,
+		"plan":      plan,
+		"usage":     metrics,
+		"resets_at": usage.PeriodEnd(now).Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/acl"
+	"github.com/immxrtalbeast/api-gateway/internal/apierror"
+	"github.com/immxrtalbeast/api-gateway/internal/clients/videos"
+	"github.com/immxrtalbeast/api-gateway/internal/masking"
+	"github.com/immxrtalbeast/api-gateway/internal/reload"
+)
+
+// VideoBatchRoute is registered as is; gin reads ":batchGet" as a parameter
+// matching the rest of the segment, so BatchGet answers 404 for any other
+// path it is given.
+const VideoBatchRoute = "/api/videos:batchGet"
+
+// VideoBatchHandler serves GET /api/videos:batchGet?ids=a,b,c, the jobs of
+// several videos in one request for list views refreshing their statuses.
+// The videos are fetched concurrently, at most concurrency at a time.
+type VideoBatchHandler struct {
+	log           *slog.Logger
+	client        *videos.Client
+	collaborators *acl.Store
+	masker        *masking.Masker
+	timeout       *reload.Value[time.Duration]
+	maxIDs        int
+	concurrency   int
+}
+
+func NewVideoBatchHandler(log *slog.Logger, client *videos.Client, collaborators *acl.Store, masker *masking.Masker, timeout time.Duration, maxIDs, concurrency int) *VideoBatchHandler {
+	return &VideoBatchHandler{
+		log:           log,
+		client:        client,
+		collaborators: collaborators,
+		masker:        masker,
+		timeout:       reload.NewValue(timeout),
+		maxIDs:        maxIDs,
+		concurrency:   max(concurrency, 1),
+	}
+}
+
+// SetTimeout changes the upstream call timeout of the following requests.
+func (h *VideoBatchHandler) SetTimeout(timeout time.Duration) {
+	h.timeout.Store(timeout)
+}
+
+type batchVideoError struct {
+	Code    apierror.Code `json:"code"`
+	Message string        `json:"message"`
+	Reason  string        `json:"reason,omitempty"`
+}
+
+type batchGetResponse struct {
+	Videos map[string]json.RawMessage `json:"videos"`
+	Errors map[string]batchVideoError `json:"errors,omitempty"`
+}
+
+// BatchGet answers the jobs by video ID. Videos that couldn't be fetched,
+// not found ones included, are null in videos and explained in errors.
+func (h *VideoBatchHandler) BatchGet(c *gin.Context) {
+	if c.Request.URL.Path != VideoBatchRoute {
+		writeError(c, http.StatusNotFound, "route not found")
+		return
+	}
+	var ids []string
+	seen := map[string]bool{}
+	for _, id := range strings.Split(c.Query("ids"), ",") {
+		if id = strings.TrimSpace(id); id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		writeError(c, http.StatusBadRequest, "ids is required")
+		return
+	}
+	if h.maxIDs > 0 && len(ids) > h.maxIDs {
+		writeError(c, http.StatusBadRequest, fmt.Sprintf("at most %d ids per request", h.maxIDs))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
+	defer cancel()
+	userID := currentUserID(c)
+	headers := userHeaders(c)
+
+	out := batchGetResponse{Videos: make(map[string]json.RawMessage, len(ids)), Errors: map[string]batchVideoError{}}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, h.concurrency)
+	for _, id := range ids {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			job, failure := h.get(ctx, id, h.headersFor(id, userID, headers))
+			mu.Lock()
+			defer mu.Unlock()
+			out.Videos[id] = job
+			if failure != nil {
+				out.Errors[id] = *failure
+			}
+		}()
+	}
+	wg.Wait()
+	if c.Request.Context().Err() != nil {
+		markAbandoned(c)
+		return
+	}
+	writeJSON(c, http.StatusOK, out)
+}
+
+func (h *VideoBatchHandler) get(ctx context.Context, videoID string, headers map[string]string) (json.RawMessage, *batchVideoError) {
+	resp, err := h.client.GetVideo(ctx, videoID, headers)
+	if err != nil {
+		h.log.Warn("batch get video failed", slog.String("video_id", videoID), slog.String("err", err.Error()))
+		_, code, message, reason := upstreamFailure("video", err)
+		return nil, &batchVideoError{Code: code, Message: message, Reason: reason}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &batchVideoError{Code: apierror.FromHTTPStatus(resp.StatusCode), Message: fmt.Sprintf("video service answered %d", resp.StatusCode)}
+	}
+	body := h.masker.Apply(resp.Body)
+	if !json.Valid(body) {
+		return nil, &batchVideoError{Code: apierror.CodeUpstreamError, Message: "video service error", Reason: "invalid_response"}
+	}
+	return body, nil
+}
+
+// headersFor reads a video shared with the user as its owner, the way
+// CollaboratorAccess does for single video routes.
+func (h *VideoBatchHandler) headersFor(videoID, userID string, headers map[string]string) map[string]string {
+	if h.collaborators == nil || userID == "" {
+		return headers
+	}
+	owner, _, ok := h.collaborators.Access(videoID, userID)
+	if !ok || owner == userID {
+		return headers
+	}
+	shared := maps.Clone(headers)
+	shared["X-User-ID"] = owner
+	shared["X-Collaborator-ID"] = userID
+	return shared
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/clients/videos"
+)
+
+// contentResponseHeaders are the upstream headers a player needs to seek.
+var contentResponseHeaders = []string{
+	"Content-Type",
+	"Content-Length",
+	"Content-Range",
+	"Accept-Ranges",
+	"ETag",
+	"Last-Modified",
+	"Cache-Control",
+	"Content-Disposition",
+}
+
+// maxContentErrorBody bounds the upstream error bodies Content reads.
+const maxContentErrorBody = 64 << 10
+
+// Content serves the rendered file of a video for playback, streaming it
+// from the video service or the storage it redirects to without buffering.
+// Range and If-Range are passed on, so 206 answers with their Content-Range
+// let players seek. The upstream timeout covers the wait for the response
+// headers only; the copy lasts as long as the client reads.
+func (h *VideoHandler) Content(c *gin.Context) {
+	videoID := c.Param("id")
+	headers := maps.Clone(userHeaders(c))
+	if headers == nil {
+		headers = make(map[string]string)
+	}
+	for _, name := range videos.ContentHeaders {
+		if value := c.GetHeader(name); value != "" {
+			headers[name] = value
+		}
+	}
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	timer := time.AfterFunc(callTimeout(c, h.timeout.Load()), cancel)
+	resp, err := h.client.GetContent(ctx, videoID, headers)
+	if !timer.Stop() {
+		// Timed out, possibly just as the response came in.
+		if err == nil {
+			resp.Body.Close()
+		}
+		err = context.DeadlineExceeded
+	}
+	if err != nil {
+		h.recordJobResult(videoID, "content", nil, err)
+		if clientGone(c, err) {
+			return
+		}
+		h.log.Error("get video content failed", slog.String("err", err.Error()))
+		writeUpstreamError(c, "video", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent && resp.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxContentErrorBody))
+		if err != nil {
+			if clientGone(c, err) {
+				return
+			}
+			writeUpstreamError(c, "video", err)
+			return
+		}
+		upstream := &videos.Response{StatusCode: resp.StatusCode, Body: body, Header: resp.Header}
+		h.recordJobResult(videoID, "content", upstream, nil)
+		h.forwardResponse(c, upstream)
+		return
+	}
+
+	for _, name := range contentResponseHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			c.Header(name, value)
+		}
+	}
+	c.Status(resp.StatusCode)
+	if _, err := io.Copy(c.Writer, resp.Body); err != nil {
+		if c.Request.Context().Err() != nil {
+			markAbandoned(c)
+			return
+		}
+		// The status is out; the client sees a truncated body.
+		c.Error(err)
+		h.log.Warn("video content copy failed", slog.String("video_id", videoID), slog.String("err", err.Error()))
+	}
+}
@@ -9,26 +9,111 @@ import (
 	"io"
 	"mime/multipart"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
 	"log/slog"
 
 	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/analytics"
+	"github.com/immxrtalbeast/api-gateway/internal/apierror"
 	"github.com/immxrtalbeast/api-gateway/internal/clients/videos"
 	"github.com/immxrtalbeast/api-gateway/internal/events"
+	"github.com/immxrtalbeast/api-gateway/internal/journal"
+	"github.com/immxrtalbeast/api-gateway/internal/masking"
+	"github.com/immxrtalbeast/api-gateway/internal/reload"
+	"github.com/immxrtalbeast/api-gateway/lib/jsonpath"
 	"golang.org/x/net/websocket"
 )
 
 type VideoHandler struct {
 	log       *slog.Logger
 	client    *videos.Client
-	timeout   time.Duration
+	timeout   *reload.Value[time.Duration]
 	streamHub *events.Hub
+	stream    StreamOptions
+	jobErrors *jobErrorLog
+	masker    *masking.Masker
+	journal   *journal.Journal
+	analytics *analytics.Publisher
 }
 
-func NewVideoHandler(log *slog.Logger, client *videos.Client, timeout time.Duration, hub *events.Hub) *VideoHandler {
-	return &VideoHandler{log: log, client: client, timeout: timeout, streamHub: hub}
+// StreamOptions tunes the job status websocket. Zero values fall back to the
+// handler timeout, a 2s poll interval, the "ready"/"failed" terminal stages
+// and the stage read from "job.stage".
+type StreamOptions struct {
+	SnapshotTimeout time.Duration
+	PollInterval    time.Duration
+	TerminalStages  []string
+	StagePath       string
+	// Lag reports how many updates the consumer is behind. A job stream
+	// opened while it exceeds LagThreshold polls snapshots until the consumer
+	// catches up, since the queued updates are older than the snapshot the
+	// client got first. Nil disables the check.
+	Lag          func() int64
+	LagThreshold int64
+	// AllowedOrigins returns the browser origins that may open streams, "*"
+	// for any. Upgrades from other origins are refused with 403; requests
+	// without an Origin header (non-browser clients) and from the gateway's
+	// own host are accepted. Nil accepts every origin.
+	AllowedOrigins func() []string
+	Media          MediaStreamOptions
+	// Logouts ends the streams of sessions that were logged out or revoked
+	// with AuthExpiredCloseCode. Nil keeps them open.
+	Logouts *events.Logouts
+}
+
+// MediaStreamOptions configures the media processing websocket. Events for a
+// media item come from Hub and are sent to its owner only, as read from
+// UserIDPath ("media.user_id" by default); the stream ends on one of
+// TerminalStatuses ("ready" and "failed") at StatusPath ("media.status"). A
+// nil Hub disables the stream.
+type MediaStreamOptions struct {
+	Hub              *events.Hub
+	UserIDPath       string
+	StatusPath       string
+	TerminalStatuses []string
+}
+
+func NewVideoHandler(log *slog.Logger, client *videos.Client, timeout time.Duration, hub *events.Hub, stream StreamOptions, masker *masking.Masker, requests *journal.Journal, publisher *analytics.Publisher) *VideoHandler {
+	if stream.SnapshotTimeout <= 0 {
+		stream.SnapshotTimeout = timeout
+	}
+	if stream.PollInterval <= 0 {
+		stream.PollInterval = 2 * time.Second
+	}
+	if len(stream.TerminalStages) == 0 {
+		stream.TerminalStages = []string{"ready", "failed"}
+	}
+	if stream.StagePath == "" {
+		stream.StagePath = "job.stage"
+	}
+	if stream.Media.UserIDPath == "" {
+		stream.Media.UserIDPath = "media.user_id"
+	}
+	if stream.Media.StatusPath == "" {
+		stream.Media.StatusPath = "media.status"
+	}
+	if len(stream.Media.TerminalStatuses) == 0 {
+		stream.Media.TerminalStatuses = []string{"ready", "failed"}
+	}
+	return &VideoHandler{
+		log:       log,
+		client:    client,
+		timeout:   reload.NewValue(timeout),
+		streamHub: hub,
+		stream:    stream,
+		jobErrors: newJobErrorLog(),
+		masker:    masker,
+		journal:   requests,
+		analytics: publisher,
+	}
+}
+
+// SetTimeout changes the upstream call timeout of the following requests.
+func (h *VideoHandler) SetTimeout(timeout time.Duration) {
+	h.timeout.Store(timeout)
 }
 
 func (h *VideoHandler) CreateVideo(c *gin.Context) {
@@ -37,99 +122,201 @@ func (h *VideoHandler) CreateVideo(c *gin.Context) {
 		writeError(c, http.StatusBadRequest, "failed to read request body")
 		return
 	}
-	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeout)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
 	defer cancel()
 
-	resp, err := h.client.CreateVideo(ctx, body, userHeaders(c))
+	resp, err := h.client.CreateVideo(ctx, withPriority(body, c.GetString("requestPriority")), userHeaders(c))
 	if err != nil {
+		if clientGone(c, err) {
+			return
+		}
 		h.log.Error("video create failed", slog.String("err", err.Error()))
-		writeError(c, http.StatusBadGateway, "video service error")
+		writeUpstreamError(c, "video", err)
 		return
 	}
-	forwardResponse(c, resp)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		h.analytics.JobRequested(jobRequested(c, body), resp.Body)
+	}
+	h.forwardResponse(c, resp)
 }
 
 func (h *VideoHandler) ListVideos(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeout)
+	if wantsNDJSON(c) {
+		h.streamVideos(c)
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
 	defer cancel()
 
 	resp, err := h.client.ListVideos(ctx, userHeaders(c))
 	if err != nil {
+		if clientGone(c, err) {
+			return
+		}
 		h.log.Error("list videos failed", slog.String("err", err.Error()))
-		writeError(c, http.StatusBadGateway, "video service error")
+		writeUpstreamError(c, "video", err)
 		return
 	}
-	forwardResponse(c, resp)
+	h.forwardResponse(c, resp)
 }
 
 func (h *VideoHandler) GetVideo(c *gin.Context) {
 	videoID := c.Param("id")
-	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeout)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
 	defer cancel()
 
 	resp, err := h.client.GetVideo(ctx, videoID, userHeaders(c))
+	h.recordJobResult(videoID, "get_video", resp, err)
 	if err != nil {
+		if clientGone(c, err) {
+			return
+		}
 		h.log.Error("get video failed", slog.String("err", err.Error()))
-		writeError(c, http.StatusBadGateway, "video service error")
+		writeUpstreamError(c, "video", err)
 		return
 	}
-	forwardResponse(c, resp)
+	h.forwardResponse(c, resp)
 }
 
-func (h *VideoHandler) ExpandIdea(c *gin.Context) {
-	body, err := readJSONBody(c.Request.Body)
+// Diagnostics aggregates what the gateway knows about a job: the upstream
+// state, the last streamed event, live subscribers and recent upstream errors.
+func (h *VideoHandler) Diagnostics(c *gin.Context) {
+	jobID := c.Param("id")
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
+	defer cancel()
+
+	resp, err := h.client.GetVideo(ctx, jobID, userHeaders(c))
+	h.recordJobResult(jobID, "get_video", resp, err)
 	if err != nil {
-		writeError(c, http.StatusBadRequest, "failed to read request body")
+		if clientGone(c, err) {
+			return
+		}
+		h.log.Error("get video diagnostics failed", slog.String("err", err.Error()))
+		writeUpstreamError(c, "video", err)
+		return
+	}
+	// Only the owner may see gateway-side details: the video service has to
+	// answer the job with a 2xx first, anything else is passed through as-is.
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		h.forwardResponse(c, resp)
 		return
 	}
-	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeout)
-	defer cancel()
 
-	resp, err := h.client.ExpandIdea(ctx, body, userHeaders(c))
+	upstream := map[string]any{"status": resp.StatusCode}
+	if json.Valid(resp.Body) {
+		upstream["body"] = json.RawMessage(h.masker.Apply(resp.Body))
+	}
+	if stage, err := h.extractStage(resp.Body); err == nil {
+		upstream["stage"] = stage
+		upstream["terminal"] = h.isTerminalStage(stage)
+	}
+
+	stream := map[string]any{"enabled": h.streamHub != nil}
+	if h.streamHub != nil {
+		stream["subscribers"] = h.streamHub.SubscriberCount(jobID)
+		if payload, at, ok := h.streamHub.LastEvent(jobID); ok {
+			stream["last_event_at"] = at
+			if json.Valid(payload) {
+				stream["last_event"] = json.RawMessage(h.masker.Apply(payload))
+			}
+		}
+	}
+
+	writeJSON(c, http.StatusOK, map[string]any{
+		"job_id":        jobID,
+		"upstream":      upstream,
+		"stream":        stream,
+		"recent_errors": h.jobErrors.recent(jobID),
+	})
+}
+
+func (h *VideoHandler) recordJobResult(jobID, operation string, resp *videos.Response, err error) {
 	if err != nil {
-		h.log.Error("idea expand failed", slog.String("err", err.Error()))
-		writeError(c, http.StatusBadGateway, "idea service error")
+		h.jobErrors.record(jobID, operation, 0, err.Error())
 		return
 	}
-	forwardResponse(c, resp)
+	if resp.StatusCode >= http.StatusInternalServerError {
+		h.jobErrors.record(jobID, operation, resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
 }
 
-func (h *VideoHandler) ApproveDraft(c *gin.Context) {
-	jobID := c.Param("id")
+func (h *VideoHandler) UpdateVideo(c *gin.Context) {
+	videoID := c.Param("id")
 	body, err := readJSONBody(c.Request.Body)
 	if err != nil {
 		writeError(c, http.StatusBadRequest, "failed to read request body")
 		return
 	}
-	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeout)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
 	defer cancel()
 
-	resp, err := h.client.ApproveDraft(ctx, jobID, body, userHeaders(c))
+	resp, err := h.client.UpdateVideo(ctx, videoID, body, userHeaders(c))
+	h.recordJobResult(videoID, "update_video", resp, err)
 	if err != nil {
-		h.log.Error("draft approve failed", slog.String("err", err.Error()))
-		writeError(c, http.StatusBadGateway, "video service error")
+		if clientGone(c, err) {
+			return
+		}
+		h.log.Error("video update failed", slog.String("err", err.Error()))
+		writeUpstreamError(c, "video", err)
 		return
 	}
-	forwardResponse(c, resp)
+	h.forwardResponse(c, resp)
 }
 
-func (h *VideoHandler) ApproveSubtitles(c *gin.Context) {
-	jobID := c.Param("id")
+func (h *VideoHandler) DeleteVideo(c *gin.Context) {
+	videoID := c.Param("id")
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
+	defer cancel()
+
+	resp, err := h.client.DeleteVideo(ctx, videoID, userHeaders(c))
+	h.recordJobResult(videoID, opDeleteVideo, resp, err)
+	if h.journalFailure(c, opDeleteVideo, videoID, nil, userHeaders(c), resp, err) {
+		return
+	}
+	if err != nil {
+		if clientGone(c, err) {
+			return
+		}
+		h.log.Error("video delete failed", slog.String("err", err.Error()))
+		writeUpstreamError(c, "video", err)
+		return
+	}
+	h.forwardResponse(c, resp)
+}
+
+func (h *VideoHandler) ExpandIdea(c *gin.Context) {
 	body, err := readJSONBody(c.Request.Body)
 	if err != nil {
 		writeError(c, http.StatusBadRequest, "failed to read request body")
 		return
 	}
-	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeout)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
 	defer cancel()
 
-	resp, err := h.client.ApproveSubtitles(ctx, jobID, body, userHeaders(c))
+	resp, err := h.client.ExpandIdea(ctx, body, userHeaders(c))
 	if err != nil {
-		h.log.Error("subtitles approve failed", slog.String("err", err.Error()))
-		writeError(c, http.StatusBadGateway, "video service error")
+		if clientGone(c, err) {
+			return
+		}
+		h.log.Error("idea expand failed", slog.String("err", err.Error()))
+		writeUpstreamError(c, "idea", err)
 		return
 	}
-	forwardResponse(c, resp)
+	h.forwardResponse(c, resp)
+}
+
+func (h *VideoHandler) ApproveDraft(c *gin.Context) {
+	jobID := c.Param("id")
+	h.approve(c, opApproveDraft, jobID, jobID, func(ctx context.Context, payload []byte, headers map[string]string) (*videos.Response, error) {
+		return h.client.ApproveDraft(ctx, jobID, payload, headers)
+	})
+}
+
+func (h *VideoHandler) ApproveSubtitles(c *gin.Context) {
+	jobID := c.Param("id")
+	h.approve(c, opApproveSubtitles, jobID, jobID, func(ctx context.Context, payload []byte, headers map[string]string) (*videos.Response, error) {
+		return h.client.ApproveSubtitles(ctx, jobID, payload, headers)
+	})
 }
 
 func (h *VideoHandler) UploadMedia(c *gin.Context) {
@@ -138,44 +325,112 @@ func (h *VideoHandler) UploadMedia(c *gin.Context) {
 		writeError(c, http.StatusBadRequest, "failed to read request body")
 		return
 	}
-	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeout)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
 	defer cancel()
 
 	resp, err := h.client.UploadMedia(ctx, body, userHeaders(c))
 	if err != nil {
+		if clientGone(c, err) {
+			return
+		}
 		h.log.Error("media upload failed", slog.String("err", err.Error()))
-		writeError(c, http.StatusBadGateway, "video service error")
+		writeUpstreamError(c, "video", err)
 		return
 	}
-	forwardResponse(c, resp)
+	h.forwardResponse(c, resp)
+}
+
+func (h *VideoHandler) DeleteMedia(c *gin.Context) {
+	mediaID := c.Param("id")
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
+	defer cancel()
+
+	resp, err := h.client.DeleteMedia(ctx, mediaID, userHeaders(c))
+	if h.journalFailure(c, opDeleteMedia, mediaID, nil, userHeaders(c), resp, err) {
+		return
+	}
+	if err != nil {
+		if clientGone(c, err) {
+			return
+		}
+		h.log.Error("media delete failed", slog.String("err", err.Error()))
+		writeUpstreamError(c, "video", err)
+		return
+	}
+	h.forwardResponse(c, resp)
 }
 
 func (h *VideoHandler) ListMedia(c *gin.Context) {
 	folder := c.Query("folder")
-	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeout)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
 	defer cancel()
 
 	resp, err := h.client.ListMedia(ctx, folder, userHeaders(c))
 	if err != nil {
+		if clientGone(c, err) {
+			return
+		}
 		h.log.Error("media list failed", slog.String("err", err.Error()))
-		writeError(c, http.StatusBadGateway, "video service error")
+		writeUpstreamError(c, "video", err)
 		return
 	}
-	forwardResponse(c, resp)
+	h.forwardResponse(c, resp)
 }
 
 func (h *VideoHandler) ListSharedMedia(c *gin.Context) {
 	folder := c.Query("folder")
-	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeout)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
 	defer cancel()
 
-	resp, err := h.client.ListSharedMedia(ctx, folder)
+	resp, err := h.client.ListSharedMedia(ctx, folder, orgHeaders(c))
 	if err != nil {
+		if clientGone(c, err) {
+			return
+		}
 		h.log.Error("shared media list failed", slog.String("err", err.Error()))
-		writeError(c, http.StatusBadGateway, "video service error")
+		writeUpstreamError(c, "video", err)
 		return
 	}
-	forwardResponse(c, resp)
+	h.forwardResponse(c, resp)
+}
+
+// UploadSharedMedia adds media to the organization's shared library. The
+// route is limited to org admins by RequireOrgRole.
+func (h *VideoHandler) UploadSharedMedia(c *gin.Context) {
+	body, err := readJSONBody(c.Request.Body)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, "failed to read request body")
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
+	defer cancel()
+
+	resp, err := h.client.UploadSharedMedia(ctx, body, orgWriteHeaders(c))
+	if err != nil {
+		if clientGone(c, err) {
+			return
+		}
+		h.log.Error("shared media upload failed", slog.String("err", err.Error()))
+		writeUpstreamError(c, "video", err)
+		return
+	}
+	h.forwardResponse(c, resp)
+}
+
+func (h *VideoHandler) DeleteSharedMedia(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
+	defer cancel()
+
+	resp, err := h.client.DeleteSharedMedia(ctx, c.Param("id"), orgWriteHeaders(c))
+	if err != nil {
+		if clientGone(c, err) {
+			return
+		}
+		h.log.Error("shared media delete failed", slog.String("err", err.Error()))
+		writeUpstreamError(c, "video", err)
+		return
+	}
+	h.forwardResponse(c, resp)
 }
 
 func (h *VideoHandler) UploadVideoMedia(c *gin.Context) {
@@ -184,16 +439,19 @@ func (h *VideoHandler) UploadVideoMedia(c *gin.Context) {
         writeError(c, http.StatusBadRequest, "failed to read request body")
         return
     }
-    ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeout)
+    ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
     defer cancel()
 
     resp, err := h.client.UploadVideoMedia(ctx, body, userHeaders(c))
     if err != nil {
+        if clientGone(c, err) {
+        	return
+        }
         h.log.Error("video media upload failed", slog.String("err", err.Error()))
-        writeError(c, http.StatusBadGateway, "video service error")
+        writeUpstreamError(c, "video", err)
         return
     }
-    forwardResponse(c, resp)
+    h.forwardResponse(c, resp)
 }
 
 func (h *VideoHandler) UploadVideoBinary(c *gin.Context) {
@@ -238,105 +496,269 @@ func (h *VideoHandler) UploadVideoBinary(c *gin.Context) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeout)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
 	defer cancel()
 
 	resp, err := h.client.UploadVideoBinary(ctx, payload.Bytes(), writer.FormDataContentType(), userHeaders(c))
 	if err != nil {
+		if clientGone(c, err) {
+			return
+		}
 		h.log.Error("video binary upload failed", slog.String("err", err.Error()))
-		writeError(c, http.StatusBadGateway, "video service error")
+		writeUpstreamError(c, "video", err)
 		return
 	}
-	forwardResponse(c, resp)
+	h.forwardResponse(c, resp)
 }
 
 func (h *VideoHandler) ListVideoMedia(c *gin.Context) {
     folder := c.Query("folder")
-    ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeout)
+    ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
     defer cancel()
 
     resp, err := h.client.ListVideoMedia(ctx, folder, userHeaders(c))
     if err != nil {
+        if clientGone(c, err) {
+        	return
+        }
         h.log.Error("video media list failed", slog.String("err", err.Error()))
-        writeError(c, http.StatusBadGateway, "video service error")
+        writeUpstreamError(c, "video", err)
         return
     }
-    forwardResponse(c, resp)
+    h.forwardResponse(c, resp)
 }
 
 func (h *VideoHandler) ListSharedVideoMedia(c *gin.Context) {
     folder := c.Query("folder")
-    ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeout)
+    ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
     defer cancel()
 
-    resp, err := h.client.ListSharedVideoMedia(ctx, folder)
+    resp, err := h.client.ListSharedVideoMedia(ctx, folder, orgHeaders(c))
     if err != nil {
+        if clientGone(c, err) {
+        	return
+        }
         h.log.Error("shared video media list failed", slog.String("err", err.Error()))
-        writeError(c, http.StatusBadGateway, "video service error")
+        writeUpstreamError(c, "video", err)
         return
     }
-    forwardResponse(c, resp)
+    h.forwardResponse(c, resp)
 }
 
 func (h *VideoHandler) ListVoices(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeout)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
 	defer cancel()
 
 	resp, err := h.client.ListVoices(ctx)
 	if err != nil {
+		if clientGone(c, err) {
+			return
+		}
 		h.log.Error("voices list failed", slog.String("err", err.Error()))
-		writeError(c, http.StatusBadGateway, "video service error")
+		writeUpstreamError(c, "video", err)
 		return
 	}
-	forwardResponse(c, resp)
+	h.forwardResponse(c, resp)
 }
 
 func (h *VideoHandler) ListMusic(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeout)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), callTimeout(c, h.timeout.Load()))
 	defer cancel()
 
 	resp, err := h.client.ListMusic(ctx)
 	if err != nil {
+		if clientGone(c, err) {
+			return
+		}
 		h.log.Error("music list failed", slog.String("err", err.Error()))
-		writeError(c, http.StatusBadGateway, "video service error")
+		writeUpstreamError(c, "video", err)
 		return
 	}
-	forwardResponse(c, resp)
+	h.forwardResponse(c, resp)
 }
 
 func (h *VideoHandler) StreamVideo(c *gin.Context) {
 	jobID := c.Param("id")
 	ws := websocket.Server{
-		Handshake: func(config *websocket.Config, req *http.Request) error {
-			return nil
-		},
+		Handshake: h.checkOrigin,
 		Handler: func(conn *websocket.Conn) {
 			defer conn.Close()
-			ctx := c.Request.Context()
+			ctx, loggedOut, stop := h.untilLogout(c, c.Request.Context())
+			defer stop()
 			if h.streamHub != nil {
 				h.handleKafkaStream(ctx, conn, jobID)
-				return
+			} else {
+				h.handleVideoStream(ctx, conn, jobID)
+			}
+			if loggedOut() {
+				closeAuthExpired(conn)
+			}
+		},
+	}
+	ws.ServeHTTP(c.Writer, c.Request)
+}
+
+// StreamEvents is a user-wide websocket delivering updates for all jobs of
+// the authenticated user.
+func (h *VideoHandler) StreamEvents(c *gin.Context) {
+	if h.streamHub == nil {
+		writeError(c, http.StatusConflict, "realtime stream is disabled")
+		return
+	}
+	userID := currentUserID(c)
+	if userID == "" {
+		writeError(c, http.StatusUnauthorized, "JWT required")
+		return
+	}
+	ws := websocket.Server{
+		Handshake: h.checkOrigin,
+		Handler: func(conn *websocket.Conn) {
+			defer conn.Close()
+			ctx, cancel := context.WithCancel(c.Request.Context())
+			defer cancel()
+			// The client never sends anything; reading only detects disconnects.
+			go func() {
+				io.Copy(io.Discard, conn)
+				cancel()
+			}()
+			ctx, loggedOut, stop := h.untilLogout(c, ctx)
+			defer stop()
+
+			updates, unsubscribe := h.streamHub.SubscribeUser(userID)
+			defer unsubscribe()
+			for {
+				select {
+				case <-ctx.Done():
+					if loggedOut() {
+						closeAuthExpired(conn)
+					}
+					return
+				case payload, ok := <-updates:
+					if !ok {
+						return
+					}
+					if err := websocket.Message.Send(conn, string(h.masker.Apply(payload))); err != nil {
+						return
+					}
+				}
+			}
+		},
+	}
+	ws.ServeHTTP(c.Writer, c.Request)
+}
+
+// StreamMedia is a websocket delivering the processing updates (thumbnailing,
+// transcoding) of one media item of the authenticated user: the buffered
+// recent ones first, then live ones until a terminal status.
+func (h *VideoHandler) StreamMedia(c *gin.Context) {
+	media := h.stream.Media
+	if media.Hub == nil {
+		writeError(c, http.StatusConflict, "media stream is disabled")
+		return
+	}
+	userID := currentUserID(c)
+	if userID == "" {
+		writeError(c, http.StatusUnauthorized, "JWT required")
+		return
+	}
+	mediaID := c.Param("id")
+	ws := websocket.Server{
+		Handshake: h.checkOrigin,
+		Handler: func(conn *websocket.Conn) {
+			defer conn.Close()
+			ctx, cancel := context.WithCancel(c.Request.Context())
+			defer cancel()
+			// The client never sends anything; reading only detects disconnects.
+			go func() {
+				io.Copy(io.Discard, conn)
+				cancel()
+			}()
+			ctx, loggedOut, stop := h.untilLogout(c, ctx)
+			defer stop()
+
+			updates, unsubscribe := media.Hub.Subscribe(mediaID)
+			defer unsubscribe()
+			for {
+				select {
+				case <-ctx.Done():
+					if loggedOut() {
+						closeAuthExpired(conn)
+					}
+					return
+				case payload, ok := <-updates:
+					if !ok {
+						return
+					}
+					// Media IDs are guessable; other users' updates are skipped.
+					if owner, err := jsonpath.String(payload, media.UserIDPath); err != nil || owner != userID {
+						continue
+					}
+					if err := websocket.Message.Send(conn, string(h.masker.Apply(payload))); err != nil {
+						return
+					}
+					if status, err := jsonpath.String(payload, media.StatusPath); err == nil && slices.Contains(media.TerminalStatuses, status) {
+						return
+					}
+				}
 			}
-			h.handleVideoStream(ctx, conn, jobID)
 		},
 	}
 	ws.ServeHTTP(c.Writer, c.Request)
 }
 
+// ReplayJob re-fetches a job snapshot and publishes it to the stream hub so
+// connected clients that missed an update (e.g. the terminal one) catch up.
+func (h *VideoHandler) ReplayJob(c *gin.Context) {
+	jobID := c.Param("id")
+	if h.streamHub == nil {
+		writeError(c, http.StatusConflict, "realtime stream is disabled")
+		return
+	}
+	body, stage, err := h.fetchJobSnapshot(c.Request.Context(), jobID)
+	if err != nil {
+		if clientGone(c, err) {
+			return
+		}
+		h.log.Error("job replay failed", slog.String("job_id", jobID), slog.String("err", err.Error()))
+		writeUpstreamError(c, "video", err)
+		return
+	}
+	h.streamHub.Publish(jobID, body)
+	h.log.Info("job replayed", slog.String("job_id", jobID), slog.String("stage", stage))
+	writeJSON(c, http.StatusOK, map[string]any{
+		"job_id":      jobID,
+		"stage":       stage,
+		"subscribers": h.streamHub.SubscriberCount(jobID),
+	})
+}
+
 func (h *VideoHandler) handleKafkaStream(ctx context.Context, conn *websocket.Conn, jobID string) {
+	// Subscribe before taking the snapshot so no update published in between
+	// is lost. Buffered updates predate the snapshot and go out before it,
+	// so the client never steps back to an older stage.
+	replay, updates, cancel := h.streamHub.SubscribeReplay(jobID)
+	defer cancel()
+	for _, payload := range replay {
+		if err := websocket.Message.Send(conn, string(h.masker.Apply(payload))); err != nil {
+			return
+		}
+	}
 	body, stage, err := h.fetchJobSnapshot(ctx, jobID)
 	if err != nil {
-		websocket.Message.Send(conn, fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+		websocket.JSON.Send(conn, streamError(err))
 		return
 	}
-	if err := websocket.Message.Send(conn, string(body)); err != nil {
+	if err := websocket.Message.Send(conn, string(h.masker.Apply(body))); err != nil {
 		return
 	}
-	if stage == "ready" || stage == "failed" {
+	if h.isTerminalStage(stage) {
 		return
 	}
-	updates, cancel := h.streamHub.Subscribe(jobID)
-	defer cancel()
+	if h.lagging() {
+		if done := h.awaitCatchUp(ctx, conn, jobID, body, updates); done {
+			return
+		}
+	}
 	for {
 		select {
 		case <-ctx.Done():
@@ -345,40 +767,122 @@ func (h *VideoHandler) handleKafkaStream(ctx context.Context, conn *websocket.Co
 			if !ok {
 				return
 			}
-			if err := websocket.Message.Send(conn, string(payload)); err != nil {
+			if err := websocket.Message.Send(conn, string(h.masker.Apply(payload))); err != nil {
 				return
 			}
-			nextStage, err := extractStage(payload)
+			nextStage, err := h.extractStage(payload)
 			if err != nil {
 				continue
 			}
-			if nextStage == "ready" || nextStage == "failed" {
+			if h.isTerminalStage(nextStage) {
 				return
 			}
 		}
 	}
 }
 
+// checkOrigin is the websocket handshake; a returned error makes the server
+// answer 403 instead of upgrading.
+func (h *VideoHandler) checkOrigin(config *websocket.Config, req *http.Request) error {
+	origin := req.Header.Get("Origin")
+	if h.stream.AllowedOrigins == nil || origin == "" || origin == "http://"+req.Host || origin == "https://"+req.Host {
+		return nil
+	}
+	for _, allowed := range h.stream.AllowedOrigins() {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return nil
+		}
+	}
+	h.log.Warn("websocket origin rejected",
+		slog.String("origin", origin),
+		slog.String("path", req.URL.Path),
+	)
+	return fmt.Errorf("origin %s not allowed", origin)
+}
+
+func (h *VideoHandler) lagging() bool {
+	return h.stream.Lag != nil && h.stream.LagThreshold > 0 && h.stream.Lag() > h.stream.LagThreshold
+}
+
+// awaitCatchUp polls the job snapshot while the consumer lags, dropping the
+// stale updates it delivers meanwhile, and sends a last snapshot once it has
+// caught up. It reports whether the stream is over.
+func (h *VideoHandler) awaitCatchUp(ctx context.Context, conn *websocket.Conn, jobID string, sent []byte, updates <-chan []byte) bool {
+	h.log.Debug("stream consumer lagging, polling job snapshots",
+		slog.String("job_id", jobID),
+		slog.Int64("lag", h.stream.Lag()),
+	)
+	lastHash := sha256.Sum256(sent)
+	ticker := time.NewTicker(h.stream.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return true
+		case <-ticker.C:
+		}
+		caughtUp := !h.lagging()
+		// Updates dispatched before this snapshot is taken are no newer than it.
+		drain(updates)
+		body, stage, err := h.fetchJobSnapshot(ctx, jobID)
+		if err != nil {
+			websocket.JSON.Send(conn, streamError(err))
+			return true
+		}
+		if hash := sha256.Sum256(body); hash != lastHash {
+			lastHash = hash
+			if err := websocket.Message.Send(conn, string(h.masker.Apply(body))); err != nil {
+				return true
+			}
+		}
+		if h.isTerminalStage(stage) {
+			return true
+		}
+		if caughtUp {
+			return false
+		}
+	}
+}
+
+func drain(updates <-chan []byte) {
+	for {
+		select {
+		case <-updates:
+		default:
+			return
+		}
+	}
+}
+
+// streamError is sent over the websocket when the job snapshot can't be
+// fetched, using the same envelope as HTTP errors.
+func streamError(err error) apierror.Envelope {
+	return apierror.Envelope{Error: apierror.Error{
+		Code:    apierror.CodeUpstreamError,
+		Message: err.Error(),
+	}}
+}
+
 func (h *VideoHandler) handleVideoStream(ctx context.Context, conn *websocket.Conn, jobID string) {
-	ticker := time.NewTicker(2 * time.Second)
+	ticker := time.NewTicker(h.stream.PollInterval)
 	defer ticker.Stop()
 
 	var lastHash [32]byte
 	sendUpdate := func() (bool, bool) {
 		body, stage, err := h.fetchJobSnapshot(ctx, jobID)
 		if err != nil {
-			websocket.Message.Send(conn, fmt.Sprintf(`{"error":"%s"}`, err.Error()))
+			websocket.JSON.Send(conn, streamError(err))
 			return false, true
 		}
 		hash := sha256.Sum256(body)
 		if hash == lastHash {
-			return true, stage == "ready" || stage == "failed"
+			return true, h.isTerminalStage(stage)
 		}
 		lastHash = hash
-		if err := websocket.Message.Send(conn, string(body)); err != nil {
+		if err := websocket.Message.Send(conn, string(h.masker.Apply(body))); err != nil {
 			return false, true
 		}
-		return true, stage == "ready" || stage == "failed"
+		return true, h.isTerminalStage(stage)
 	}
 
 	if ok, done := sendUpdate(); !ok || done {
@@ -399,32 +903,32 @@ func (h *VideoHandler) handleVideoStream(ctx context.Context, conn *websocket.Co
 }
 
 func (h *VideoHandler) fetchJobSnapshot(ctx context.Context, jobID string) ([]byte, string, error) {
-	reqCtx, cancel := context.WithTimeout(ctx, h.timeout)
+	reqCtx, cancel := context.WithTimeout(ctx, h.stream.SnapshotTimeout)
 	defer cancel()
 	resp, err := h.client.GetVideo(reqCtx, jobID, nil)
+	h.recordJobResult(jobID, "snapshot", resp, err)
 	if err != nil {
 		return nil, "", err
 	}
 	body := append([]byte(nil), resp.Body...)
-	stage, err := extractStage(body)
+	stage, err := h.extractStage(body)
 	if err != nil {
 		return nil, "", err
 	}
 	return body, stage, nil
 }
 
-type jobStagePayload struct {
-	Job struct {
-		Stage string `json:"stage"`
-	} `json:"job"`
+func (h *VideoHandler) isTerminalStage(stage string) bool {
+	for _, terminal := range h.stream.TerminalStages {
+		if stage == terminal {
+			return true
+		}
+	}
+	return false
 }
 
-func extractStage(body []byte) (string, error) {
-	var payload jobStagePayload
-	if err := json.Unmarshal(body, &payload); err != nil {
-		return "", err
-	}
-	return payload.Job.Stage, nil
+func (h *VideoHandler) extractStage(body []byte) (string, error) {
+	return jsonpath.String(body, h.stream.StagePath)
 }
 
 func readJSONBody(body io.Reader) ([]byte, error) {
@@ -434,34 +938,89 @@ func readJSONBody(body io.Reader) ([]byte, error) {
 	return io.ReadAll(io.LimitReader(body, 1<<20))
 }
 
-func userHeaders(c *gin.Context) map[string]string {
+func currentUserID(c *gin.Context) string {
 	userIDVal, exists := c.Get("userID")
 	if !exists {
-		return nil
+		return ""
 	}
-	userID := fmt.Sprint(userIDVal)
+	return fmt.Sprint(userIDVal)
+}
+
+// UserPlanHeader tells upstreams the caller's billing plan so they can pick
+// quality tiers.
+const UserPlanHeader = "X-User-Plan"
+
+// RequestPriorityHeader carries the gateway's low/normal/high classification
+// of the request, see middleware.Priority.
+const RequestPriorityHeader = "X-Request-Priority"
+
+// userHeaders identifies the caller to upstream services. Collaborators
+// admitted by CollaboratorAccess act as the video owner; requests made with
+// an impersonation token name the admin in X-Impersonated-By.
+func userHeaders(c *gin.Context) map[string]string {
+	userID := currentUserID(c)
 	if userID == "" {
 		return nil
 	}
-	return map[string]string{"X-User-ID": userID}
+	headers := map[string]string{"X-User-ID": userID}
+	if owner := c.GetString("ownerID"); owner != "" {
+		headers["X-User-ID"] = owner
+		headers["X-Collaborator-ID"] = userID
+	}
+	if admin := c.GetString("impersonatedBy"); admin != "" {
+		headers[ImpersonatedByHeader] = admin
+	}
+	if plan := c.GetString("userPlan"); plan != "" {
+		headers[UserPlanHeader] = plan
+	}
+	if priority := c.GetString("requestPriority"); priority != "" {
+		headers[RequestPriorityHeader] = priority
+	}
+	return headers
 }
 
-func forwardResponse(c *gin.Context, resp *videos.Response) {
-	for k, v := range resp.Header {
-		if strings.EqualFold(k, "Content-Length") {
-			continue
-		}
-		for _, value := range v {
-			c.Writer.Header().Add(k, value)
-		}
+// orgHeaders scopes shared library reads to the caller's organization, if
+// any. Without one the global shared library is returned.
+func orgHeaders(c *gin.Context) map[string]string {
+	orgID := c.GetString("orgID")
+	if orgID == "" {
+		return nil
 	}
-	if c.Writer.Header().Get("Content-Type") == "" {
-		c.Writer.Header().Set("Content-Type", "application/json")
+	return map[string]string{"X-Org-ID": orgID}
+}
+
+func orgWriteHeaders(c *gin.Context) map[string]string {
+	return map[string]string{
+		"X-User-ID":           currentUserID(c),
+		"X-Org-ID":            c.GetString("orgID"),
+		"X-Org-Role":          c.GetString("orgRole"),
+		ImpersonatedByHeader:  c.GetString("impersonatedBy"),
+		RequestPriorityHeader: c.GetString("requestPriority"),
 	}
-	c.Status(resp.StatusCode)
-	if len(resp.Body) > 0 {
-		if _, err := c.Writer.Write(resp.Body); err != nil {
-			c.Error(err)
-		}
+}
+
+// withPriority records the request priority in a JSON object body so the
+// video service carries it into the generation job it publishes. Other
+// bodies are returned unchanged for the upstream to reject.
+func withPriority(body []byte, priority string) []byte {
+	if priority == "" {
+		return body
+	}
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(body, &doc); err != nil || doc == nil {
+		return body
+	}
+	doc["priority"], _ = json.Marshal(priority)
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return body
+	}
+	return out
+}
+
+func (h *VideoHandler) forwardResponse(c *gin.Context, resp *videos.Response) {
+	if err := writeUpstream(c, h.masker, resp.StatusCode, resp.Header, resp.Body); err != nil {
+		markAbandoned(c)
+		c.Error(err)
 	}
 }
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/apierror"
+	"github.com/immxrtalbeast/api-gateway/internal/webhooks"
+)
+
+// WebhookHandler lets users register callback URLs for job completion.
+type WebhookHandler struct {
+	log           *slog.Logger
+	store         *webhooks.Store
+	dispatcher    *webhooks.Dispatcher
+	events        []string
+	allowInsecure bool
+	allowPrivate  bool
+}
+
+// NewWebhookHandler accepts subscriptions to the given events; plain http
+// URLs are only accepted with allowInsecure, and loopback or private
+// addresses only with allowPrivate.
+func NewWebhookHandler(log *slog.Logger, store *webhooks.Store, dispatcher *webhooks.Dispatcher, events []string, allowInsecure, allowPrivate bool) *WebhookHandler {
+	return &WebhookHandler{log: log, store: store, dispatcher: dispatcher, events: events, allowInsecure: allowInsecure, allowPrivate: allowPrivate}
+}
+
+type createWebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+type webhookResponse struct {
+	ID        string   `json:"id"`
+	URL       string   `json:"url"`
+	Events    []string `json:"events"`
+	CreatedAt string   `json:"created_at"`
+	// Secret is only returned when the endpoint is created.
+	Secret string `json:"secret,omitempty"`
+}
+
+type deliveryAttemptResponse struct {
+	At         string `json:"at"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+type deliveryResponse struct {
+	ID            string                    `json:"id"`
+	Event         string                    `json:"event"`
+	JobID         string                    `json:"job_id"`
+	State         string                    `json:"state"`
+	CreatedAt     string                    `json:"created_at"`
+	NextAttemptAt string                    `json:"next_attempt_at,omitempty"`
+	Attempts      []deliveryAttemptResponse `json:"attempts"`
+}
+
+func convertDelivery(d webhooks.Delivery) deliveryResponse {
+	res := deliveryResponse{
+		ID:        d.ID,
+		Event:     d.Event,
+		JobID:     d.JobID,
+		State:     d.State,
+		CreatedAt: d.CreatedAt.Format(time.RFC3339),
+		Attempts:  make([]deliveryAttemptResponse, 0, len(d.Attempts)),
+	}
+	if !d.NextAttemptAt.IsZero() {
+		res.NextAttemptAt = d.NextAttemptAt.UTC().Format(time.RFC3339)
+	}
+	for _, a := range d.Attempts {
+		res.Attempts = append(res.Attempts, deliveryAttemptResponse{
+			At:         a.At.Format(time.RFC3339),
+			Error:      a.Error,
+			DurationMS: a.Duration.Milliseconds(),
+		})
+	}
+	return res
+}
+
+func convertWebhook(e webhooks.Endpoint) webhookResponse {
+	return webhookResponse{
+		ID:        e.ID,
+		URL:       e.URL,
+		Events:    e.Events,
+		CreatedAt: e.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func (h *WebhookHandler) Create(c *gin.Context) {
+	var req createWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "invalid json payload")
+		return
+	}
+	var fields []apierror.FieldError
+	target, err := url.Parse(strings.TrimSpace(req.URL))
+	switch {
+	case err != nil || target.Host == "":
+		fields = append(fields, apierror.FieldError{Field: "/url", Message: "must be an absolute URL"})
+	case target.Scheme != "https" && !(h.allowInsecure && target.Scheme == "http"):
+		fields = append(fields, apierror.FieldError{Field: "/url", Message: "must use https"})
+	case target.User != nil:
+		fields = append(fields, apierror.FieldError{Field: "/url", Message: "must not contain credentials"})
+	case !h.allowPrivate && webhooks.CheckHost(target.Hostname()) != nil:
+		fields = append(fields, apierror.FieldError{Field: "/url", Message: "must not point to a loopback, private or link-local address"})
+	}
+	events := req.Events
+	if len(events) == 0 {
+		events = h.events
+	}
+	seen := make(map[string]bool, len(events))
+	for _, event := range events {
+		if !h.known(event) || seen[event] {
+			fields = append(fields, apierror.FieldError{Field: "/events", Message: "unknown or duplicate event " + event + ", expected one of " + strings.Join(h.events, ", ")})
+			break
+		}
+		seen[event] = true
+	}
+	if len(fields) > 0 {
+		apierror.Abort(c, http.StatusUnprocessableEntity, apierror.CodeValidationFailed, "request validation failed", map[string]any{"fields": fields})
+		return
+	}
+
+	endpoint, err := h.store.Create(c.Request.Context(), currentUserID(c), target.String(), events)
+	if errors.Is(err, webhooks.ErrLimit) {
+		writeError(c, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		h.log.Error("create webhook failed", slog.String("err", err.Error()))
+		writeError(c, http.StatusInternalServerError, "failed to save webhook")
+		return
+	}
+	res := convertWebhook(endpoint)
+	res.Secret = endpoint.Secret
+	writeJSON(c, http.StatusCreated, map[string]any{"webhook": res})
+}
+
+func (h *WebhookHandler) List(c *gin.Context) {
+	res := make([]webhookResponse, 0)
+	for _, e := range h.store.List(currentUserID(c)) {
+		res = append(res, convertWebhook(e))
+	}
+	writeJSON(c, http.StatusOK, map[string]any{"webhooks": res})
+}
+
+func (h *WebhookHandler) Delete(c *gin.Context) {
+	ok, err := h.store.Delete(c.Request.Context(), currentUserID(c), c.Param("id"))
+	if err != nil {
+		h.log.Error("delete webhook failed", slog.String("err", err.Error()))
+		writeError(c, http.StatusInternalServerError, "failed to delete webhook")
+		return
+	}
+	if !ok {
+		writeError(c, http.StatusNotFound, "webhook not found")
+		return
+	}
+	h.dispatcher.Forget(c.Param("id"))
+	c.Status(http.StatusNoContent)
+}
+
+// Deliveries lists the recent delivery attempts to one of the user's
+// endpoints.
+func (h *WebhookHandler) Deliveries(c *gin.Context) {
+	endpoint, ok := h.owned(c)
+	if !ok {
+		return
+	}
+	res := make([]deliveryResponse, 0)
+	for _, d := range h.dispatcher.Deliveries(endpoint.ID) {
+		res = append(res, convertDelivery(d))
+	}
+	writeJSON(c, http.StatusOK, map[string]any{"deliveries": res})
+}
+
+// Redeliver re-sends a finished delivery, typically a failed one.
+func (h *WebhookHandler) Redeliver(c *gin.Context) {
+	endpoint, ok := h.owned(c)
+	if !ok {
+		return
+	}
+	delivery, err := h.dispatcher.Redeliver(endpoint.ID, c.Param("delivery_id"))
+	switch {
+	case errors.Is(err, webhooks.ErrDeliveryNotFound):
+		writeError(c, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, webhooks.ErrDeliveryPending):
+		writeError(c, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		h.log.Error("redeliver webhook failed", slog.String("err", err.Error()))
+		writeError(c, http.StatusInternalServerError, "failed to redeliver webhook")
+		return
+	}
+	writeJSON(c, http.StatusAccepted, map[string]any{"delivery": convertDelivery(delivery)})
+}
+
+// owned loads the :id endpoint and answers 404 unless it belongs to the
+// caller.
+func (h *WebhookHandler) owned(c *gin.Context) (webhooks.Endpoint, bool) {
+	endpoint, ok := h.store.Get(c.Param("id"))
+	if !ok || endpoint.UserID != currentUserID(c) {
+		writeError(c, http.StatusNotFound, "webhook not found")
+		return webhooks.Endpoint{}, false
+	}
+	return endpoint, true
+}
+
+func (h *WebhookHandler) known(event string) bool {
+	for _, e := range h.events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/accesslog"
+)
+
+// AccessLog writes an access log line for every request. A nil logger turns
+// it into a no-op.
+func AccessLog(logger *accesslog.Logger, log *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if logger == nil {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		c.Next()
+		err := logger.Write(accesslog.Entry{
+			Time:      start,
+			ClientIP:  c.ClientIP(),
+			UserID:    c.GetString("userID"),
+			Method:    c.Request.Method,
+			Host:      c.Request.Host,
+			URI:       c.Request.RequestURI,
+			Route:     c.FullPath(),
+			Proto:     c.Request.Proto,
+			Status:    c.Writer.Status(),
+			Bytes:     max(c.Writer.Size(), 0),
+			Referer:   c.Request.Referer(),
+			UserAgent: c.Request.UserAgent(),
+			Duration:  time.Since(start),
+			RequestID: c.GetString("requestID"),
+		})
+		if err != nil {
+			log.Warn("access log write failed", slog.String("err", err.Error()))
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/apierror"
+	"github.com/immxrtalbeast/api-gateway/internal/clients/auth"
+	authv1 "github.com/immxrtalbeast/protos/gen/go/auth/v1"
+)
+
+// AdminOnly lets the request through only when the auth service confirms the
+// authenticated user is an admin. Impersonation tokens are always rejected.
+// It must run after AuthMiddleware.
+func AdminOnly(client auth.Client, timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userIDVal, exists := c.Get("userID")
+		if !exists {
+			apierror.Abort(c, http.StatusUnauthorized, apierror.CodeUnauthenticated, "JWT required", nil)
+			return
+		}
+		if c.GetString("impersonatedBy") != "" {
+			apierror.Abort(c, http.StatusForbidden, apierror.CodePermissionDenied, "admin routes are not available while impersonating", nil)
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		resp, err := client.IsAdmin(ctx, &authv1.IsAdminRequest{UserId: fmt.Sprint(userIDVal)})
+		if err != nil {
+			apierror.Abort(c, http.StatusServiceUnavailable, apierror.CodeUpstreamUnavailable, "auth service unavailable", nil)
+			return
+		}
+		if !resp.GetIsAdmin() {
+			apierror.Abort(c, http.StatusForbidden, apierror.CodePermissionDenied, "admin role required", nil)
+			return
+		}
+		c.Next()
+	}
+}
+
+// NoImpersonation rejects impersonation tokens on routes acting on the
+// account itself, such as its password, email, sessions or second factor:
+// an admin working as the user must not be able to take the account over.
+// It must run after AuthMiddleware.
+func NoImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("impersonatedBy") != "" {
+			apierror.Abort(c, http.StatusForbidden, apierror.CodePermissionDenied, "account security routes are not available while impersonating", nil)
+			return
+		}
+		c.Next()
+	}
+}
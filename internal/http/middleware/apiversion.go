@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/apiversion"
+)
+
+// APIVersionHeader names the API version that served the request.
+const APIVersionHeader = "X-API-Version"
+
+// VersionPrefix serves /api/<version>/*path as /api/*path of v: it rewrites
+// the request and routes it again through engine. It must be registered
+// before engine.Use, so the middleware runs once, for the rewritten request.
+func VersionPrefix(engine *gin.Engine, v apiversion.Version) gin.HandlerFunc {
+	prefix := "/api/" + v.Name
+	return func(c *gin.Context) {
+		c.Request.URL.Path = "/api" + c.Param("path")
+		if raw := c.Request.URL.RawPath; raw != "" {
+			c.Request.URL.RawPath = "/api" + strings.TrimPrefix(raw, prefix)
+		}
+		c.Request = c.Request.WithContext(apiversion.NewContext(c.Request.Context(), v))
+		engine.HandleContext(c)
+		// The context now holds the handlers of the rewritten route, which
+		// have run; the outer chain must not resume with them.
+		c.Abort()
+	}
+}
+
+// requestVersion names the API version of the request, empty before
+// APIVersion ran.
+func requestVersion(c *gin.Context) string {
+	v, _ := apiversion.FromContext(c.Request.Context())
+	return v.Name
+}
+
+// APIVersion gives requests without a version prefix the default version
+// and tells clients which version answered and when it goes away:
+// Deprecation (RFC 9745), Sunset (RFC 8594) and a Link to the migration
+// guide.
+func APIVersion(versions *apiversion.Set) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, ok := apiversion.FromContext(c.Request.Context())
+		if !ok {
+			v = versions.Default()
+			c.Request = c.Request.WithContext(apiversion.NewContext(c.Request.Context(), v))
+		}
+		header := c.Writer.Header()
+		header.Set(APIVersionHeader, v.Name)
+		if !v.Deprecated.IsZero() {
+			header.Set("Deprecation", "@"+strconv.FormatInt(v.Deprecated.Unix(), 10))
+		}
+		if !v.Sunset.IsZero() {
+			header.Set("Sunset", v.Sunset.UTC().Format(http.TimeFormat))
+		}
+		if v.Link != "" && (!v.Deprecated.IsZero() || !v.Sunset.IsZero()) {
+			header.Add("Link", "<"+v.Link+`>; rel="deprecation"; type="text/html"`)
+		}
+		c.Next()
+	}
+}
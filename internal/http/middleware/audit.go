@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/audit"
+)
+
+// Audit records the request as action once the handler has answered. The
+// actor is the authenticated user or, on routes without AuthMiddleware, the
+// "auditActor" the handler set; the target is the :id or :user_id path
+// parameter. A nil logger disables auditing.
+func Audit(l *audit.Logger, action string) gin.HandlerFunc {
+	if l == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return func(c *gin.Context) {
+		c.Next()
+
+		status := c.Writer.Status()
+		outcome := audit.OutcomeSuccess
+		switch {
+		case status == http.StatusUnauthorized || status == http.StatusForbidden:
+			outcome = audit.OutcomeDenied
+		case status >= http.StatusBadRequest:
+			outcome = audit.OutcomeFailure
+		}
+		actor := c.GetString("auditActor")
+		if userID, ok := c.Get("userID"); ok {
+			actor = fmt.Sprint(userID)
+		}
+		target := c.Param("id")
+		if target == "" {
+			target = c.Param("user_id")
+		}
+		l.Record(audit.Event{
+			Action:         action,
+			Outcome:        outcome,
+			Status:         status,
+			ActorID:        actor,
+			ImpersonatedBy: c.GetString("impersonatedBy"),
+			Target:         target,
+			IP:             c.ClientIP(),
+			UserAgent:      c.Request.UserAgent(),
+			Method:         c.Request.Method,
+			Path:           c.Request.URL.Path,
+			RequestID:      c.GetString("requestID"),
+		})
+	}
+}
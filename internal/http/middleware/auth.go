@@ -1,70 +1,165 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/immxrtalbeast/api-gateway/internal/apierror"
+	"github.com/immxrtalbeast/api-gateway/internal/clients/jwks"
+	"github.com/immxrtalbeast/api-gateway/internal/reload"
+	"github.com/immxrtalbeast/api-gateway/internal/revocation"
 )
 
-func AuthMiddleware(appSecret string) gin.HandlerFunc {
+// AuthMiddleware accepts RS256 and ES256 access tokens signed by a key in keys
+// and HS256 tokens signed with the current appSecret. With keys set, HS256 is
+// only accepted for the gateway's own impersonation tokens, so APP_SECRET
+// can't mint user tokens once the auth service signs them. Either may be
+// disabled with nil or an empty secret. Tokens issued before their user or
+// session was revoked are rejected.
+func AuthMiddleware(appSecret *reload.Value[string], keys *jwks.KeySet, revoked *revocation.List) gin.HandlerFunc {
+	authenticate := newAuthenticator(appSecret, keys, revoked)
 	return func(c *gin.Context) {
+		if message := authenticate(c); message != "" {
+			apierror.Abort(c, http.StatusUnauthorized, apierror.CodeUnauthenticated, message, nil)
+			return
+		}
+		c.Next()
+	}
+}
+
+// Identify sets the caller like AuthMiddleware when the request carries a
+// valid token and lets every request through, for routes such as logout that
+// must work without one.
+func Identify(appSecret *reload.Value[string], keys *jwks.KeySet, revoked *revocation.List) gin.HandlerFunc {
+	authenticate := newAuthenticator(appSecret, keys, revoked)
+	return func(c *gin.Context) {
+		authenticate(c)
+		c.Next()
+	}
+}
+
+// newAuthenticator returns a function verifying the token of a request and
+// storing its claims on the context; it returns why the token was rejected,
+// or "" when it was accepted.
+func newAuthenticator(appSecret *reload.Value[string], keys *jwks.KeySet, revoked *revocation.List) func(c *gin.Context) string {
+	methods := []string{jwt.SigningMethodHS256.Alg()}
+	if keys != nil {
+		methods = append(methods, jwt.SigningMethodRS256.Alg(), jwt.SigningMethodES256.Alg())
+	}
+	parser := jwt.NewParser(jwt.WithValidMethods(methods))
+	return func(c *gin.Context) string {
 		authHeader := c.GetHeader("Authorization")
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 		if authHeader == "" {
 			var err error
 			tokenString, err = c.Cookie("jwt")
 			if err != nil {
-				c.AbortWithStatusJSON(401, gin.H{"error": "JWT required"})
-				return
+				return "JWT required"
 			}
 		}
 
 		if tokenString == authHeader {
-			c.AbortWithStatusJSON(401, gin.H{"error": "Bearer token required"})
-			return
+			return "Bearer token required"
 		}
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		token, err := parser.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+			switch token.Method.(type) {
+			case *jwt.SigningMethodHMAC:
+				// Read per token so a rotated secret applies on reload.
+				secret := appSecret.Load()
+				if secret == "" {
+					return nil, errors.New("HS256 tokens are disabled")
+				}
+				if keys != nil {
+					claims, _ := token.Claims.(jwt.MapClaims)
+					if imp, _ := claims["imp"].(string); imp == "" {
+						return nil, errors.New("HS256 is only accepted for impersonation tokens")
+					}
+				}
+				return []byte(secret), nil
+			case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA:
+				kid, _ := token.Header["kid"].(string)
+				return keys.Key(c.Request.Context(), kid)
+			default:
 				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 			}
-			return []byte(appSecret), nil
 		})
 
 		if err != nil {
-			c.AbortWithStatusJSON(401, gin.H{"error": "Invalid token: " + tokenString})
-			return
+			return "Invalid token: " + tokenString
 		}
 
 		if !token.Valid {
-			c.AbortWithStatusJSON(401, gin.H{"error": "Invalid token"})
-			return
+			return "Invalid token"
 		}
 
 		claims, ok := token.Claims.(jwt.MapClaims)
 		if !ok {
-			c.AbortWithStatusJSON(401, gin.H{"error": "Invalid token claims"})
-			return
+			return "Invalid token claims"
 		}
 
 		if exp, ok := claims["exp"].(float64); ok {
 			if time.Now().Unix() > int64(exp) {
-				c.AbortWithStatusJSON(401, gin.H{"error": "Token expired"})
-				return
+				return "Token expired"
 			}
+			c.Set("tokenExpiresAt", time.Unix(int64(exp), 0))
 		}
 
-		userID, ok := claims["uid"]
-		if !ok {
-			c.AbortWithStatusJSON(401, gin.H{"error": "Invalid user ID in token"})
-			return
+		uid, ok := claims["uid"]
+		if !ok || uid == nil {
+			return "Invalid user ID in token"
+		}
+		// Stored as a string, so keys built with c.GetString("userID") keep
+		// the user whatever type the issuer gave the claim.
+		userID := fmt.Sprint(uid)
+
+		sessionID, _ := claims["sid"].(string)
+		if revoked != nil {
+			var issuedAt time.Time
+			if iat, ok := claims["iat"].(float64); ok {
+				issuedAt = time.Unix(int64(iat), 0)
+			}
+			if revoked.Revoked(userID, sessionID, issuedAt) {
+				return "Token revoked"
+			}
 		}
 
 		c.Set("userID", userID)
+		if sessionID != "" {
+			c.Set("sessionID", sessionID)
+		}
+		if admin, ok := claims["imp"].(string); ok && admin != "" {
+			c.Set("impersonatedBy", admin)
+		}
+		if plan, ok := claims["plan"].(string); ok {
+			c.Set("userPlan", plan)
+		}
+		if orgID, ok := claims["org_id"].(string); ok && orgID != "" {
+			c.Set("orgID", orgID)
+			orgRole, _ := claims["org_role"].(string)
+			c.Set("orgRole", orgRole)
+		}
 
-		c.Next()
+		return ""
+	}
+}
+
+// OptionalAuth runs auth only when the request carries credentials, so
+// anonymous callers pass through without a userID while invalid tokens are
+// still rejected.
+func OptionalAuth(auth gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			if _, err := c.Cookie("jwt"); err != nil {
+				c.Next()
+				return
+			}
+		}
+		auth(c)
 	}
 }
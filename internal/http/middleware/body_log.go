@@ -0,0 +1,197 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const redactedBody = "[REDACTED]"
+
+type BodyLogConfig struct {
+	// SampleRate is the fraction of requests whose bodies are logged.
+	SampleRate float64
+	// MaxBytes caps each logged body.
+	MaxBytes int
+	// Routes are the path prefixes (route groups) bodies are logged for; empty
+	// means every route.
+	Routes []string
+	// Redact lists the field name fragments whose values are hidden, matched
+	// case-insensitively, so "token" covers refresh_token too.
+	Redact []string
+}
+
+// BodyLogger captures a sample of request and response bodies for the request
+// log. Only JSON, form and text bodies are logged, with the Redact fields
+// hidden; bodies cut at MaxBytes are redacted field by field on the raw text.
+type BodyLogger struct {
+	cfg     BodyLogConfig
+	pattern *regexp.Regexp
+}
+
+func NewBodyLogger(cfg BodyLogConfig) *BodyLogger {
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 4096
+	}
+	redact := cfg.Redact
+	cfg.Redact = nil
+	var fragments []string
+	for _, field := range redact {
+		if field = strings.ToLower(strings.TrimSpace(field)); field != "" {
+			cfg.Redact = append(cfg.Redact, field)
+			fragments = append(fragments, regexp.QuoteMeta(field))
+		}
+	}
+	b := &BodyLogger{cfg: cfg}
+	if len(fragments) > 0 {
+		b.pattern = regexp.MustCompile(`(?i)("[^"]*(?:` + strings.Join(fragments, "|") + `)[^"]*"\s*:\s*)("(?:[^"\\]|\\.)*"?|[^,}\]\s]*)`)
+	}
+	return b
+}
+
+// Start begins capturing the bodies of a sampled request and returns the
+// function producing their log attributes once the request is served. A nil
+// logger captures nothing.
+func (b *BodyLogger) Start(c *gin.Context) func() []any {
+	if b == nil || !b.sampled(c.Request.URL.Path) {
+		return func() []any { return nil }
+	}
+	var request []byte
+	if c.Request.Body != nil {
+		request, _ = io.ReadAll(io.LimitReader(c.Request.Body, int64(b.cfg.MaxBytes)+1))
+		c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(request), c.Request.Body))
+	}
+	requestHeader := c.Request.Header.Clone()
+	tee := &teeWriter{ResponseWriter: c.Writer, limit: b.cfg.MaxBytes + 1}
+	c.Writer = tee
+	return func() []any {
+		return []any{
+			slog.Group("request_body", b.attrs(requestHeader, request)...),
+			slog.Group("response_body", b.attrs(tee.Header(), tee.buf.Bytes())...),
+		}
+	}
+}
+
+func (b *BodyLogger) sampled(path string) bool {
+	if b.cfg.SampleRate <= 0 || (b.cfg.SampleRate < 1 && rand.Float64() >= b.cfg.SampleRate) {
+		return false
+	}
+	if len(b.cfg.Routes) == 0 {
+		return true
+	}
+	for _, prefix := range b.cfg.Routes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *BodyLogger) attrs(header http.Header, body []byte) []any {
+	if len(body) == 0 {
+		return nil
+	}
+	if encoding := header.Get("Content-Encoding"); encoding != "" {
+		return []any{slog.String("content_encoding", encoding)}
+	}
+	truncated := len(body) > b.cfg.MaxBytes
+	if truncated {
+		body = body[:b.cfg.MaxBytes]
+	}
+	attrs := []any{slog.Bool("truncated", truncated)}
+	contentType := header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		return append(attrs, slog.String("body", b.redactForm(body)))
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") || strings.HasPrefix(mediaType, "text/"):
+		return append(attrs, slog.String("body", b.redactJSON(body, truncated)))
+	}
+	return append(attrs, slog.String("content_type", contentType))
+}
+
+func (b *BodyLogger) redactJSON(body []byte, truncated bool) string {
+	if b.pattern == nil {
+		return string(body)
+	}
+	var doc any
+	if !truncated && json.Unmarshal(body, &doc) == nil {
+		b.redactValue(doc)
+		if out, err := json.Marshal(doc); err == nil {
+			return string(out)
+		}
+	}
+	return b.pattern.ReplaceAllString(string(body), `${1}"`+redactedBody+`"`)
+}
+
+func (b *BodyLogger) redactValue(v any) {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if b.sensitive(key) {
+				v[key] = redactedBody
+				continue
+			}
+			b.redactValue(value)
+		}
+	case []any:
+		for _, item := range v {
+			b.redactValue(item)
+		}
+	}
+}
+
+func (b *BodyLogger) redactForm(body []byte) string {
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return redactedBody
+	}
+	for key := range values {
+		if b.sensitive(key) {
+			values[key] = []string{redactedBody}
+		}
+	}
+	return values.Encode()
+}
+
+func (b *BodyLogger) sensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, field := range b.cfg.Redact {
+		if field != "" && strings.Contains(key, field) {
+			return true
+		}
+	}
+	return false
+}
+
+// teeWriter keeps a copy of up to limit bytes of the response.
+type teeWriter struct {
+	gin.ResponseWriter
+	buf   bytes.Buffer
+	limit int
+}
+
+func (w *teeWriter) Write(data []byte) (int, error) {
+	w.keep(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *teeWriter) WriteString(s string) (int, error) {
+	w.keep([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *teeWriter) keep(data []byte) {
+	if room := w.limit - w.buf.Len(); room > 0 {
+		w.buf.Write(data[:min(room, len(data))])
+	}
+}
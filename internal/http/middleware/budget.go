@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/apierror"
+)
+
+const (
+	BudgetIdeas   = "ideas"
+	BudgetScripts = "scripts"
+)
+
+// BudgetConfig maps plan name to per-day limits keyed by budget kind.
+// A missing or non-positive limit means unlimited.
+type BudgetConfig struct {
+	DefaultPlan string
+	Plans       map[string]map[string]int
+}
+
+// DailyBudget counts LLM-backed calls per user and budget kind, resetting at
+// UTC midnight. Calls that fail upstream (5xx) or are abandoned are refunded.
+type DailyBudget struct {
+	cfg    BudgetConfig
+	mu     sync.Mutex
+	day    string
+	counts map[string]int
+}
+
+func NewDailyBudget(cfg BudgetConfig) *DailyBudget {
+	return &DailyBudget{cfg: cfg, counts: make(map[string]int)}
+}
+
+func (b *DailyBudget) Limit(kind string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userIDVal, exists := c.Get("userID")
+		if !exists {
+			c.Next()
+			return
+		}
+		limit := b.limitFor(UserPlan(c, b.cfg.DefaultPlan), kind)
+		if limit <= 0 {
+			c.Next()
+			return
+		}
+		key := kind + "|" + fmt.Sprint(userIDVal)
+		now := time.Now().UTC()
+		resetsAt := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+
+		used, ok := b.take(key, limit, now)
+		c.Header("X-Budget-Limit", strconv.Itoa(limit))
+		c.Header("X-Budget-Reset", resetsAt.Format(time.RFC3339))
+		if !ok {
+			c.Header("X-Budget-Remaining", "0")
+			c.Header("Retry-After", strconv.Itoa(int(resetsAt.Sub(now).Seconds())+1))
+			apierror.Abort(c, http.StatusTooManyRequests, apierror.CodeBudgetExceeded, "daily budget exceeded", map[string]any{
+				"budget":    kind,
+				"limit":     limit,
+				"remaining": 0,
+				"resets_at": resetsAt.Format(time.RFC3339),
+			})
+			return
+		}
+		c.Header("X-Budget-Remaining", strconv.Itoa(limit-used))
+
+		c.Next()
+
+		if status := c.Writer.Status(); status >= http.StatusInternalServerError || status == 499 {
+			b.refund(key, now)
+		}
+	}
+}
+
+func (b *DailyBudget) limitFor(plan, kind string) int {
+	limits, ok := b.cfg.Plans[plan]
+	if !ok {
+		limits = b.cfg.Plans[b.cfg.DefaultPlan]
+	}
+	return limits[kind]
+}
+
+func (b *DailyBudget) take(key string, limit int, now time.Time) (int, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rollover(now)
+	if b.counts[key] >= limit {
+		return b.counts[key], false
+	}
+	b.counts[key]++
+	return b.counts[key], true
+}
+
+func (b *DailyBudget) refund(key string, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rollover(now)
+	if b.counts[key] > 0 {
+		b.counts[key]--
+	}
+}
+
+func (b *DailyBudget) rollover(now time.Time) {
+	day := now.Format(time.DateOnly)
+	if day != b.day {
+		b.day = day
+		b.counts = make(map[string]int)
+	}
+}
+
+// UserPlan returns the billing plan stored on the request by the auth
+// middleware, or fallback when the token carries none.
+func UserPlan(c *gin.Context, fallback string) string {
+	if plan := c.GetString("userPlan"); plan != "" {
+		return plan
+	}
+	return fallback
+}
@@ -0,0 +1,202 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type cachedResponse struct {
+	status  int
+	header  http.Header
+	body    []byte
+	etag    string
+	expires time.Time
+}
+
+// ResponseCache is an in-memory TTL cache for public GET endpoints whose
+// response doesn't depend on the caller. Responses carry a strong ETag so
+// clients can revalidate with If-None-Match.
+type ResponseCache struct {
+	mu      sync.RWMutex
+	entries map[string]*cachedResponse
+}
+
+func NewResponseCache() *ResponseCache {
+	return &ResponseCache{entries: make(map[string]*cachedResponse)}
+}
+
+// Handler caches successful responses of the wrapped route for ttl.
+// A non-positive ttl disables caching but still sets ETag headers. vary lists
+// context keys (e.g. "orgID") whose values split the cache; such responses
+// are marked private, since shared caches only see the URL.
+func (rc *ResponseCache) Handler(ttl time.Duration, vary ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet {
+			c.Next()
+			return
+		}
+		key := c.Request.URL.RequestURI() + "|" + requestVersion(c)
+		for _, name := range vary {
+			key += "|" + c.GetString(name)
+		}
+		private := len(vary) > 0
+		if entry, ok := rc.get(key); ok {
+			c.Header("X-Cache", "HIT")
+			writeCached(c, entry, ttl, private)
+			return
+		}
+
+		recorder := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+		c.Writer = recorder.ResponseWriter
+
+		status := recorder.Status()
+		if status != http.StatusOK {
+			recorder.ResponseWriter.WriteHeader(status)
+			recorder.ResponseWriter.WriteHeaderNow()
+			if recorder.buf.Len() > 0 {
+				recorder.ResponseWriter.Write(recorder.buf.Bytes())
+			}
+			return
+		}
+		body := recorder.buf.Bytes()
+		entry := &cachedResponse{
+			status:  status,
+			header:  recorder.Header().Clone(),
+			body:    append([]byte(nil), body...),
+			etag:    etagFor(body),
+			expires: time.Now().Add(ttl),
+		}
+		if ttl > 0 {
+			rc.set(key, entry)
+		}
+		c.Header("X-Cache", "MISS")
+		writeCached(c, entry, ttl, private)
+	}
+}
+
+func (rc *ResponseCache) get(key string) (*cachedResponse, bool) {
+	rc.mu.RLock()
+	entry, ok := rc.entries[key]
+	rc.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expires) {
+		rc.mu.Lock()
+		if current, ok := rc.entries[key]; ok && current == entry {
+			delete(rc.entries, key)
+		}
+		rc.mu.Unlock()
+		return nil, false
+	}
+	return entry, true
+}
+
+// InvalidatePrefix drops cached responses whose request URI starts with
+// prefix.
+func (rc *ResponseCache) InvalidatePrefix(prefix string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	for key := range rc.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(rc.entries, key)
+		}
+	}
+}
+
+func (rc *ResponseCache) set(key string, entry *cachedResponse) {
+	rc.mu.Lock()
+	rc.entries[key] = entry
+	rc.mu.Unlock()
+}
+
+func writeCached(c *gin.Context, entry *cachedResponse, ttl time.Duration, private bool) {
+	header := c.Writer.Header()
+	for k, v := range entry.header {
+		if strings.EqualFold(k, "Content-Length") {
+			continue
+		}
+		header[k] = append([]string(nil), v...)
+	}
+	header.Set("ETag", entry.etag)
+	if ttl > 0 {
+		scope := "public"
+		if private {
+			scope = "private"
+		}
+		header.Set("Cache-Control", scope+", max-age="+strconv.Itoa(int(ttl.Seconds())))
+	}
+	if etagMatches(c.GetHeader("If-None-Match"), entry.etag) {
+		c.Status(http.StatusNotModified)
+		c.Writer.WriteHeaderNow()
+		return
+	}
+	c.Status(entry.status)
+	c.Writer.Write(entry.body)
+}
+
+func etagFor(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		candidate = strings.TrimPrefix(candidate, "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// bodyRecorder buffers the handler output instead of sending it so the
+// middleware can inspect and replay it.
+type bodyRecorder struct {
+	gin.ResponseWriter
+	buf    bytes.Buffer
+	status int
+}
+
+func (r *bodyRecorder) WriteHeader(code int) {
+	r.status = code
+}
+
+func (r *bodyRecorder) WriteHeaderNow() {}
+
+func (r *bodyRecorder) Write(data []byte) (int, error) {
+	return r.buf.Write(data)
+}
+
+func (r *bodyRecorder) WriteString(s string) (int, error) {
+	return r.buf.WriteString(s)
+}
+
+func (r *bodyRecorder) Status() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
+
+func (r *bodyRecorder) Written() bool {
+	return r.status != 0 || r.buf.Len() > 0
+}
+
+func (r *bodyRecorder) Size() int {
+	return r.buf.Len()
+}
@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/clients/egress"
+)
+
+// CanaryOverride lets developers pick the upstream version with header:
+// "always" routes their requests to the canaries, "never" to the primaries.
+// Other values are ignored.
+func CanaryOverride(header string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch override := strings.ToLower(strings.TrimSpace(c.GetHeader(header))); override {
+		case egress.CanaryAlways, egress.CanaryNever:
+			c.Request = c.Request.WithContext(egress.WithCanaryOverride(c.Request.Context(), override))
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/metrics"
+	"golang.org/x/sync/singleflight"
+)
+
+// Coalescer collapses identical GET requests that are in flight at the same
+// time into one: the first runs the handler and the others, from the same
+// user for the same URI, get a copy of its response. A burst of polling
+// requests thus makes one upstream call.
+type Coalescer struct {
+	group singleflight.Group
+}
+
+func NewCoalescer() *Coalescer {
+	return &Coalescer{}
+}
+
+// sharedResponse is the response of the request that ran the handler, with
+// the headers the handler set.
+type sharedResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// Handler coalesces the requests of the wrapped route. A nil Coalescer lets
+// everything through.
+func (co *Coalescer) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if co == nil || c.Request.Method != http.MethodGet {
+			c.Next()
+			return
+		}
+		// The version is part of the key: versioned requests reach here with
+		// the prefix stripped, and versions format answers differently.
+		key := requestVersion(c) + "|" + c.GetString("userID") + "|" + c.GetString("orgID") + "|" + c.GetHeader("Accept") + "|" + c.Request.URL.RequestURI()
+
+		// The handler runs on the goroutine of the first request, which owns
+		// the gin context; the others wait for it, bounded by its timeout.
+		// When the client of that request goes away, its aborted response is
+		// not shared and the waiting requests try again, one of them leading.
+		for {
+			leader := false
+			shared, _, _ := co.group.Do(key, func() (any, error) {
+				leader = true
+				return co.run(c), nil
+			})
+			if leader {
+				return
+			}
+			if response := shared.(*sharedResponse); response != nil {
+				metrics.Coalesced.Add(RouteName(c.HandlerName()), 1)
+				writeShared(c, response)
+				return
+			}
+		}
+	}
+}
+
+// run executes the handler chain and writes its response, keeping a copy for
+// the requests waiting on it. It returns nil when the client went away
+// before the response was complete.
+func (co *Coalescer) run(c *gin.Context) *sharedResponse {
+	before := c.Writer.Header().Clone()
+	recorder := &bodyRecorder{ResponseWriter: c.Writer}
+	c.Writer = recorder
+	c.Next()
+	c.Writer = recorder.ResponseWriter
+
+	status := recorder.Status()
+	abandoned := c.Request.Context().Err() != nil || status == 499
+	shared := &sharedResponse{
+		status: status,
+		header: addedHeaders(before, recorder.Header()),
+		body:   append([]byte(nil), recorder.buf.Bytes()...),
+	}
+	c.Writer.WriteHeader(status)
+	c.Writer.WriteHeaderNow()
+	if recorder.buf.Len() > 0 {
+		c.Writer.Write(recorder.buf.Bytes())
+	}
+	if abandoned {
+		return nil
+	}
+	return shared
+}
+
+func writeShared(c *gin.Context, shared *sharedResponse) {
+	header := c.Writer.Header()
+	for name, values := range shared.header {
+		header[name] = slices.Clone(values)
+	}
+	c.Status(shared.status)
+	c.Writer.WriteHeaderNow()
+	if len(shared.body) > 0 {
+		c.Writer.Write(shared.body)
+	}
+	c.Abort()
+}
+
+// addedHeaders returns the headers of after that differ from before, i.e.
+// the ones set while handling the request rather than per request by the
+// global middleware.
+func addedHeaders(before, after http.Header) http.Header {
+	added := make(http.Header)
+	for name, values := range after {
+		if name == "Content-Length" || slices.Equal(before[name], values) {
+			continue
+		}
+		added[name] = slices.Clone(values)
+	}
+	return added
+}
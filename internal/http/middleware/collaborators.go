@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/acl"
+	"github.com/immxrtalbeast/api-gateway/internal/apierror"
+)
+
+const videoRoutePrefix = "/api/videos/:id"
+
+// CollaboratorAccess lets users granted access in store act on another
+// user's video under /api/videos/:id. Viewers may only read; editors may also
+// change the video, while deleting it, managing collaborators and reading
+// download receipts stay with the owner. Granted requests are proxied as the
+// owner ("ownerID" in the context). It must run after AuthMiddleware.
+func CollaboratorAccess(store *acl.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		videoID := c.Param("id")
+		if store == nil || videoID == "" || !strings.HasPrefix(c.FullPath(), videoRoutePrefix) {
+			c.Next()
+			return
+		}
+		userIDVal, exists := c.Get("userID")
+		if !exists {
+			c.Next()
+			return
+		}
+		userID := fmt.Sprint(userIDVal)
+		owner, role, ok := store.Access(videoID, userID)
+		if !ok || owner == userID {
+			c.Next()
+			return
+		}
+		if !collaboratorAllowed(c, role) {
+			apierror.Abort(c, http.StatusForbidden, apierror.CodePermissionDenied, "insufficient video permissions", map[string]any{
+				"role": role,
+			})
+			return
+		}
+		c.Set("ownerID", owner)
+		c.Set("collaboratorRole", string(role))
+		c.Next()
+	}
+}
+
+func collaboratorAllowed(c *gin.Context, role acl.Role) bool {
+	route := c.FullPath()
+	if strings.HasPrefix(route, videoRoutePrefix+"/collaborators") || strings.HasPrefix(route, videoRoutePrefix+"/receipts") {
+		return false
+	}
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead:
+		return true
+	case http.MethodDelete:
+		if route == videoRoutePrefix {
+			return false
+		}
+	}
+	return role == acl.RoleEdit
+}
@@ -0,0 +1,294 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	encodingBrotli  = "br"
+	encodingGzip    = "gzip"
+	encodingDeflate = "deflate"
+)
+
+type CompressionConfig struct {
+	MinSize      int
+	Level        int
+	Algorithms   []string
+	ExcludePaths []string
+}
+
+// Compression negotiates br/gzip/deflate via Accept-Encoding and compresses
+// textual responses once they reach MinSize bytes. WebSocket upgrades, SSE
+// streams, excluded path prefixes and already encoded or binary media pass
+// through untouched.
+func Compression(cfg CompressionConfig) gin.HandlerFunc {
+	if len(cfg.Algorithms) == 0 {
+		cfg.Algorithms = []string{encodingBrotli, encodingGzip, encodingDeflate}
+	}
+	return func(c *gin.Context) {
+		if !compressible(c.Request, cfg.ExcludePaths) {
+			c.Next()
+			return
+		}
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"), cfg.Algorithms)
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		if encoding == "" {
+			c.Next()
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: c.Writer, cfg: cfg, encoding: encoding}
+		c.Writer = cw
+		defer func() {
+			cw.finish()
+			c.Writer = cw.ResponseWriter
+		}()
+		c.Next()
+	}
+}
+
+func compressible(req *http.Request, excluded []string) bool {
+	if req.Method == http.MethodHead {
+		return false
+	}
+	if strings.EqualFold(req.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+	if strings.Contains(req.Header.Get("Accept"), "text/event-stream") {
+		return false
+	}
+	for _, prefix := range excluded {
+		if prefix != "" && strings.HasPrefix(req.URL.Path, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+func negotiateEncoding(acceptEncoding string, preferred []string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		accepted[name] = q > 0
+	}
+	for _, name := range preferred {
+		if ok, listed := accepted[name]; listed {
+			if ok {
+				return name
+			}
+			continue
+		}
+		if accepted["*"] {
+			return name
+		}
+	}
+	return ""
+}
+
+func compressibleContentType(contentType string) bool {
+	if contentType == "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case mediaType == "text/event-stream":
+		return false
+	case strings.HasPrefix(mediaType, "text/"):
+		return true
+	case mediaType == "application/json",
+		mediaType == "application/x-ndjson",
+		mediaType == "application/javascript",
+		mediaType == "application/xml",
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	return false
+}
+
+type compressWriter struct {
+	gin.ResponseWriter
+	cfg      CompressionConfig
+	encoding string
+
+	buf         bytes.Buffer
+	status      int
+	decided     bool
+	passthrough bool
+	enc         io.WriteCloser
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.decided {
+		return
+	}
+	w.status = code
+}
+
+func (w *compressWriter) WriteHeaderNow() {
+	if !w.decided && !bodyAllowed(w.Status()) {
+		w.decide()
+	}
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if w.decided {
+		if w.enc != nil {
+			return w.enc.Write(data)
+		}
+		return w.ResponseWriter.Write(data)
+	}
+	n, _ := w.buf.Write(data)
+	if w.buf.Len() >= w.cfg.MinSize {
+		if err := w.decide(); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *compressWriter) Status() int {
+	if w.status != 0 {
+		return w.status
+	}
+	return w.ResponseWriter.Status()
+}
+
+func (w *compressWriter) Written() bool {
+	return w.decided || w.buf.Len() > 0
+}
+
+func (w *compressWriter) Size() int {
+	if !w.decided {
+		return w.buf.Len()
+	}
+	return w.ResponseWriter.Size()
+}
+
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide()
+	}
+	if flusher, ok := w.enc.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying connection, e.g.
+// to extend write deadlines of streamed responses.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.decided = true
+	w.passthrough = true
+	return w.ResponseWriter.Hijack()
+}
+
+// decide commits the response headers, choosing between compressing the
+// buffered body and passing it through unchanged.
+func (w *compressWriter) decide() error {
+	w.decided = true
+	header := w.ResponseWriter.Header()
+	compress := w.buf.Len() >= w.cfg.MinSize &&
+		bodyAllowed(w.Status()) &&
+		header.Get("Content-Encoding") == "" &&
+		compressibleContentType(header.Get("Content-Type"))
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if !compress {
+		w.passthrough = true
+		w.ResponseWriter.WriteHeaderNow()
+		if w.buf.Len() == 0 {
+			return nil
+		}
+		_, err := w.ResponseWriter.Write(w.buf.Bytes())
+		w.buf.Reset()
+		return err
+	}
+
+	header.Set("Content-Encoding", w.encoding)
+	header.Del("Content-Length")
+	header.Del("Accept-Ranges")
+	w.ResponseWriter.WriteHeaderNow()
+	w.enc = newEncoder(w.encoding, w.ResponseWriter, w.cfg.Level)
+	_, err := w.enc.Write(w.buf.Bytes())
+	w.buf.Reset()
+	return err
+}
+
+func (w *compressWriter) finish() {
+	if !w.decided {
+		if w.status == 0 && w.buf.Len() == 0 {
+			return
+		}
+		w.decide()
+	}
+	if w.enc != nil {
+		w.enc.Close()
+	}
+}
+
+func bodyAllowed(status int) bool {
+	switch {
+	case status >= 100 && status <= 199:
+		return false
+	case status == http.StatusNoContent, status == http.StatusNotModified:
+		return false
+	}
+	return true
+}
+
+func newEncoder(encoding string, w io.Writer, level int) io.WriteCloser {
+	switch encoding {
+	case encodingBrotli:
+		if level < brotli.BestSpeed || level > brotli.BestCompression {
+			level = brotli.DefaultCompression
+		}
+		return brotli.NewWriterLevel(w, level)
+	case encodingDeflate:
+		fw, err := flate.NewWriter(w, level)
+		if err != nil {
+			fw, _ = flate.NewWriter(w, flate.DefaultCompression)
+		}
+		return fw
+	default:
+		gw, err := gzip.NewWriterLevel(w, level)
+		if err != nil {
+			gw, _ = gzip.NewWriterLevel(w, gzip.DefaultCompression)
+		}
+		return gw
+	}
+}
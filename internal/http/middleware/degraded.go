@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/apierror"
+	"github.com/immxrtalbeast/api-gateway/internal/health"
+)
+
+// DegradedUpstream short-circuits requests with 503 while the given upstream
+// is marked as degraded by the health monitor.
+func DegradedUpstream(monitor *health.Monitor, upstream string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		st, degraded := monitor.Degraded(upstream)
+		if !degraded {
+			c.Next()
+			return
+		}
+		c.Header("X-Upstream-Degraded", upstream)
+		if interval := monitor.Interval(); interval > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(interval.Seconds())))
+		}
+		apierror.Abort(c, http.StatusServiceUnavailable, apierror.CodeUpstreamDegraded, upstream+" service is degraded", map[string]any{
+			"upstream":             upstream,
+			"consecutive_failures": st.ConsecutiveFailures,
+			"last_check":           st.LastCheck,
+		})
+	}
+}
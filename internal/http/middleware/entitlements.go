@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/apierror"
+	"github.com/immxrtalbeast/api-gateway/internal/clients/entitlements"
+)
+
+type EntitlementsConfig struct {
+	DefaultPlan string
+	// Plans lists the features every plan includes, on top of those the
+	// billing service reports.
+	Plans map[string][]string
+	// Gates maps "METHOD /route/pattern" to the feature the route requires.
+	Gates    map[string]string
+	CacheTTL time.Duration
+	Timeout  time.Duration
+}
+
+// Entitlements resolves the plan of the authenticated user on each request
+// and blocks plan-gated routes. With a billing client the plan comes from
+// the billing service (cached for CacheTTL, the last known answer is reused
+// while it is down); without one, or until it first answers, the JWT "plan"
+// claim is used. The plan replaces "userPlan" on the request and is forwarded
+// upstream as X-User-Plan. It must run after AuthMiddleware.
+type Entitlements struct {
+	client *entitlements.Client
+	cfg    EntitlementsConfig
+	log    *slog.Logger
+	mu     sync.Mutex
+	cache  map[string]cachedEntitlements
+}
+
+type cachedEntitlements struct {
+	plan     string
+	features []string
+	expires  time.Time
+}
+
+func NewEntitlements(client *entitlements.Client, cfg EntitlementsConfig, log *slog.Logger) *Entitlements {
+	return &Entitlements{client: client, cfg: cfg, log: log, cache: make(map[string]cachedEntitlements)}
+}
+
+func (e *Entitlements) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userIDVal, exists := c.Get("userID")
+		if !exists {
+			c.Next()
+			return
+		}
+		plan, features := e.resolve(c, fmt.Sprint(userIDVal))
+		c.Set("userPlan", plan)
+
+		feature := e.cfg.Gates[c.Request.Method+" "+c.FullPath()]
+		if feature != "" && !hasFeature(features, feature) && !hasFeature(e.cfg.Plans[plan], feature) {
+			apierror.Abort(c, http.StatusForbidden, apierror.CodePermissionDenied, "your plan does not include this feature", map[string]any{
+				"feature": feature,
+				"plan":    plan,
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+func (e *Entitlements) resolve(c *gin.Context, userID string) (string, []string) {
+	fallback := UserPlan(c, e.cfg.DefaultPlan)
+	if e.client == nil {
+		return fallback, nil
+	}
+	now := time.Now()
+	e.mu.Lock()
+	cached, ok := e.cache[userID]
+	e.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.plan, cached.features
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), e.cfg.Timeout)
+	defer cancel()
+	res, err := e.client.Get(ctx, userID)
+	if err != nil {
+		e.log.Warn("resolve entitlements failed", slog.String("user_id", userID), slog.String("err", err.Error()))
+		if ok {
+			return cached.plan, cached.features
+		}
+		return fallback, nil
+	}
+	plan := res.Plan
+	if plan == "" {
+		plan = fallback
+	}
+	e.mu.Lock()
+	e.sweepLocked(now)
+	e.cache[userID] = cachedEntitlements{plan: plan, features: res.Features, expires: now.Add(e.cfg.CacheTTL)}
+	e.mu.Unlock()
+	return plan, res.Features
+}
+
+// sweepLocked drops entries that expired long enough ago that they are no
+// longer useful as a fallback.
+func (e *Entitlements) sweepLocked(now time.Time) {
+	if len(e.cache) < 10000 {
+		return
+	}
+	for userID, cached := range e.cache {
+		if now.Sub(cached.expires) > time.Hour {
+			delete(e.cache, userID)
+		}
+	}
+}
+
+func hasFeature(features []string, feature string) bool {
+	for _, f := range features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/errreport"
+	"github.com/immxrtalbeast/api-gateway/internal/health"
+)
+
+// ReportErrors reports the requests answered with a 5xx that carry errors,
+// i.e. failed upstream calls recorded with c.Error. A nil reporter makes it a
+// no-op.
+func ReportErrors(r *errreport.Reporter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if r == nil || c.Writer.Status() < http.StatusInternalServerError || len(c.Errors) == 0 {
+			return
+		}
+		r.Report(errorEvent(c, errreport.LevelError, strings.Join(c.Errors.Errors(), "; ")))
+	}
+}
+
+// Recovery answers handler panics with a bare 500 like gin.Recovery, and
+// reports them with their stack.
+func Recovery(r *errreport.Reporter) gin.HandlerFunc {
+	return gin.CustomRecovery(func(c *gin.Context, recovered any) {
+		ev := errorEvent(c, errreport.LevelFatal, fmt.Sprintf("panic: %v", recovered))
+		ev.Status = http.StatusInternalServerError
+		ev.Stack = string(debug.Stack())
+		r.Report(ev)
+		c.AbortWithStatus(http.StatusInternalServerError)
+	})
+}
+
+func errorEvent(c *gin.Context, level, message string) errreport.Event {
+	return errreport.Event{
+		Level:     level,
+		Message:   message,
+		Method:    c.Request.Method,
+		Path:      c.Request.URL.Path,
+		Route:     c.FullPath(),
+		Status:    c.Writer.Status(),
+		RequestID: c.GetString("requestID"),
+		UserID:    c.GetString("userID"),
+	}
+}
+
+// TrackErrorRates counts every routed request in rates, as failed when
+// answered with 500, 502 or 504. Deliberate 503s (degraded upstreams,
+// maintenance, disabled routes) are reported by their own means.
+func TrackErrorRates(rates *health.ErrorRates) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			return
+		}
+		switch c.Writer.Status() {
+		case http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout:
+			rates.Record(route, true)
+		default:
+			rates.Record(route, false)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/apierror"
+	"github.com/immxrtalbeast/api-gateway/internal/flags"
+)
+
+// FeatureFlags evaluates the feature flags of the authenticated user, makes
+// them available to handlers through flags.Enabled and refuses the routes
+// of flags that are off for the user. It must run after AuthMiddleware and
+// Entitlements, whose plan it targets.
+func FeatureFlags(set *flags.Set) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userIDVal, exists := c.Get("userID")
+		if !exists {
+			c.Next()
+			return
+		}
+		values := set.Evaluate(c.Request.Context(), flags.Target{
+			UserID: fmt.Sprint(userIDVal),
+			Plan:   c.GetString("userPlan"),
+			OrgID:  c.GetString("orgID"),
+		})
+		c.Request = c.Request.WithContext(flags.NewContext(c.Request.Context(), values))
+		for _, flag := range set.Gates(c.Request.Method, c.FullPath()) {
+			if !values[flag] {
+				apierror.Abort(c, http.StatusForbidden, apierror.CodePermissionDenied, "this feature is not enabled for your account", map[string]any{
+					"flag": flag,
+				})
+				return
+			}
+		}
+		c.Next()
+	}
+}
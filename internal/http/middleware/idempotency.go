@@ -0,0 +1,237 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/apierror"
+	"github.com/immxrtalbeast/api-gateway/internal/metrics"
+	"github.com/immxrtalbeast/api-gateway/internal/store"
+)
+
+// Headers of idempotent requests: clients send IdempotencyKeyHeader, and
+// responses replayed from an earlier request carry IdempotentReplayedHeader.
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+const (
+	maxIdempotencyKeyLength = 255
+	idempotencyStoreTimeout = 2 * time.Second
+)
+
+// idempotentHeaders are the response headers replayed along with the body;
+// the rest are set per request by the gateway.
+var idempotentHeaders = []string{"Content-Type", "Location"}
+
+type IdempotencyConfig struct {
+	// TTL is how long a response is replayed for retries.
+	TTL time.Duration
+	// LockTimeout bounds how long a request holds its key, so a replica dying
+	// mid-request doesn't block the key until TTL.
+	LockTimeout time.Duration
+}
+
+// Idempotency replays the first response of a route to retries carrying the
+// same Idempotency-Key, so a retried create doesn't start a second job. Keys
+// are scoped to the user and route. A retry while the first request is still
+// running gets 409, one with a different body 422. Server errors, 408 and 429
+// aren't stored, so those requests can be retried. Store errors let requests
+// through without idempotency.
+type Idempotency struct {
+	store store.Store
+	cfg   IdempotencyConfig
+	log   *slog.Logger
+}
+
+// NewIdempotency keeps its records in s.
+func NewIdempotency(s store.Store, cfg IdempotencyConfig, log *slog.Logger) *Idempotency {
+	if cfg.TTL <= 0 {
+		cfg.TTL = 24 * time.Hour
+	}
+	if cfg.LockTimeout <= 0 {
+		cfg.LockTimeout = 5 * time.Minute
+	}
+	return &Idempotency{store: s, cfg: cfg, log: log}
+}
+
+// idempotentResponse is the stored first response of a key.
+type idempotentResponse struct {
+	// Fingerprint is the hash of the request body.
+	Fingerprint string            `json:"fingerprint"`
+	Status      int               `json:"status"`
+	Header      map[string]string `json:"header,omitempty"`
+	Body        []byte            `json:"body,omitempty"`
+}
+
+// Middleware makes the route idempotent for requests with the header; the
+// others pass through. It must run after authentication. A nil Idempotency
+// lets everything through.
+func (i *Idempotency) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if i == nil {
+			c.Next()
+			return
+		}
+		idempotencyKey := c.GetHeader(IdempotencyKeyHeader)
+		if idempotencyKey == "" {
+			c.Next()
+			return
+		}
+		if len(idempotencyKey) > maxIdempotencyKeyLength {
+			apierror.Abort(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "idempotency key is too long", map[string]any{
+				"max_length": maxIdempotencyKeyLength,
+			})
+			return
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			apierror.Abort(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "failed to read request body", nil)
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := hashHex(body)
+		key := hashHex([]byte(c.GetString("userID") + "|" + c.Request.Method + "|" + c.FullPath() + "|" + idempotencyKey))
+
+		ctx := c.Request.Context()
+		if i.replay(c, key, fingerprint) {
+			return
+		}
+		claimed, err := i.store.Incr(ctx, "lock:"+key, 1, i.cfg.LockTimeout)
+		if err != nil {
+			i.storeFailed(err)
+			c.Next()
+			return
+		}
+		if claimed > 1 {
+			metrics.Idempotency.Add("in_progress", 1)
+			c.Header("Retry-After", "1")
+			apierror.Abort(c, http.StatusConflict, apierror.CodeConflict, "a request with this idempotency key is in progress", nil)
+			return
+		}
+		// The first request may have finished between the lookup and the claim.
+		if i.replay(c, key, fingerprint) {
+			i.release(ctx, key)
+			return
+		}
+
+		recorder := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+		c.Writer = recorder.ResponseWriter
+
+		status := recorder.Status()
+		if storableStatus(status) {
+			i.save(ctx, key, idempotentResponse{
+				Fingerprint: fingerprint,
+				Status:      status,
+				Header:      pickHeaders(recorder.Header()),
+				Body:        recorder.buf.Bytes(),
+			})
+		}
+		i.release(ctx, key)
+
+		c.Writer.WriteHeader(status)
+		c.Writer.WriteHeaderNow()
+		if recorder.buf.Len() > 0 {
+			c.Writer.Write(recorder.buf.Bytes())
+		}
+	}
+}
+
+// replay writes the stored response of key, if any, and reports whether the
+// request is done.
+func (i *Idempotency) replay(c *gin.Context, key, fingerprint string) bool {
+	raw, err := i.store.Get(c.Request.Context(), "response:"+key)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			i.storeFailed(err)
+		}
+		return false
+	}
+	var stored idempotentResponse
+	if err := json.Unmarshal(raw, &stored); err != nil {
+		i.storeFailed(err)
+		return false
+	}
+	if stored.Fingerprint != fingerprint {
+		metrics.Idempotency.Add("mismatched", 1)
+		apierror.Abort(c, http.StatusUnprocessableEntity, apierror.CodeUnprocessable, "idempotency key was used with a different request body", nil)
+		return true
+	}
+	metrics.Idempotency.Add("replayed", 1)
+	for name, value := range stored.Header {
+		c.Header(name, value)
+	}
+	c.Header(IdempotentReplayedHeader, "true")
+	c.Status(stored.Status)
+	c.Writer.WriteHeaderNow()
+	if len(stored.Body) > 0 {
+		c.Writer.Write(stored.Body)
+	}
+	c.Abort()
+	return true
+}
+
+func (i *Idempotency) save(ctx context.Context, key string, resp idempotentResponse) {
+	raw, err := json.Marshal(resp)
+	if err != nil {
+		i.storeFailed(err)
+		return
+	}
+	ctx, cancel := detached(ctx)
+	defer cancel()
+	if err := i.store.Put(ctx, "response:"+key, raw, i.cfg.TTL); err != nil {
+		i.storeFailed(err)
+	}
+}
+
+func (i *Idempotency) release(ctx context.Context, key string) {
+	ctx, cancel := detached(ctx)
+	defer cancel()
+	if err := i.store.Delete(ctx, "lock:"+key); err != nil {
+		i.storeFailed(err)
+	}
+}
+
+func (i *Idempotency) storeFailed(err error) {
+	metrics.Idempotency.Add("store_errors", 1)
+	i.log.Warn("idempotency store failed", slog.String("err", err.Error()))
+}
+
+// storableStatus reports whether a response is final for its key: retries of
+// server errors, timeouts and rate limits should run again.
+func storableStatus(status int) bool {
+	return status < http.StatusInternalServerError && status != http.StatusRequestTimeout && status != http.StatusTooManyRequests
+}
+
+// detached keeps the values of ctx but not its cancellation: the response of
+// a client that hung up must still be stored for its retry.
+func detached(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), idempotencyStoreTimeout)
+}
+
+func pickHeaders(header http.Header) map[string]string {
+	picked := make(map[string]string, len(idempotentHeaders))
+	for _, name := range idempotentHeaders {
+		if value := header.Get(name); value != "" {
+			picked[name] = value
+		}
+	}
+	return picked
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,195 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/apierror"
+	"github.com/immxrtalbeast/api-gateway/internal/metrics"
+	"github.com/immxrtalbeast/api-gateway/internal/store"
+)
+
+// Headers of the CAPTCHA challenge: the gateway sets CaptchaRequiredHeader
+// when it wants a solved challenge, sent back in CaptchaTokenHeader.
+const (
+	CaptchaRequiredHeader = "X-Captcha-Required"
+	CaptchaTokenHeader    = "X-Captcha-Token"
+)
+
+// CaptchaVerifier checks a solved CAPTCHA token.
+type CaptchaVerifier interface {
+	Verify(ctx context.Context, token, remoteIP string) (bool, error)
+}
+
+type LoginGuardConfig struct {
+	// FreeAttempts is the number of failures per client IP and email before
+	// lockouts start, EmailFreeAttempts per email from any IP.
+	FreeAttempts      int
+	EmailFreeAttempts int
+	// BaseLockout is the first lockout; each further failure doubles it, up to
+	// MaxLockout.
+	BaseLockout time.Duration
+	MaxLockout  time.Duration
+	// Window is how long failures are remembered after the first one.
+	Window time.Duration
+	// CaptchaAfter failures require a solved CAPTCHA; 0 disables it.
+	CaptchaAfter int
+}
+
+// LoginGuard throttles credential guessing per client IP and email, and per
+// email alone so guesses spread over many IPs are throttled too. Failed
+// attempts (401, 403 and 409 from the auth service) are counted in the
+// shared store, so the limits hold across replicas; a success clears them.
+// Store and CAPTCHA provider errors let requests through, so an outage of
+// either doesn't lock everyone out.
+type LoginGuard struct {
+	store   store.Store
+	captcha CaptchaVerifier
+	cfg     LoginGuardConfig
+	log     *slog.Logger
+}
+
+// NewLoginGuard keeps its state in s; a nil captcha disables challenges.
+func NewLoginGuard(s store.Store, captcha CaptchaVerifier, cfg LoginGuardConfig, log *slog.Logger) *LoginGuard {
+	if cfg.Window <= 0 {
+		cfg.Window = time.Hour
+	}
+	if cfg.BaseLockout <= 0 {
+		cfg.BaseLockout = time.Second
+	}
+	if cfg.MaxLockout < cfg.BaseLockout {
+		cfg.MaxLockout = cfg.BaseLockout
+	}
+	return &LoginGuard{store: s, captcha: captcha, cfg: cfg, log: log}
+}
+
+// Middleware guards login-like routes whose JSON body has an "email". A nil
+// guard lets everything through.
+func (g *LoginGuard) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if g == nil {
+			c.Next()
+			return
+		}
+		email := strings.ToLower(strings.TrimSpace(jsonBodyField(c, "email")))
+		if email == "" {
+			c.Next()
+			return
+		}
+		ctx := c.Request.Context()
+		keys := []guardKey{
+			{name: c.ClientIP() + "|" + email, free: g.cfg.FreeAttempts},
+			{name: "email|" + email, free: g.cfg.EmailFreeAttempts},
+		}
+
+		for _, key := range keys {
+			if until, ok := g.lockedUntil(ctx, key.name); ok {
+				metrics.LoginGuard.Add("locked", 1)
+				c.Header("Retry-After", strconv.Itoa(int(time.Until(until).Seconds())+1))
+				apierror.Abort(c, http.StatusTooManyRequests, apierror.CodeRateLimited, "too many failed attempts", map[string]any{
+					"locked_until": until.UTC().Format(time.RFC3339),
+				})
+				return
+			}
+		}
+		if g.captcha != nil && g.cfg.CaptchaAfter > 0 && g.failures(ctx, keys[0].name) >= g.cfg.CaptchaAfter && !g.solved(c) {
+			metrics.LoginGuard.Add("captcha_required", 1)
+			c.Header(CaptchaRequiredHeader, "true")
+			apierror.Abort(c, http.StatusForbidden, apierror.CodeCaptchaRequired, "captcha required", nil)
+			return
+		}
+
+		c.Next()
+
+		switch status := c.Writer.Status(); {
+		case status == http.StatusUnauthorized || status == http.StatusForbidden || status == http.StatusConflict:
+			metrics.LoginGuard.Add("failures", 1)
+			for _, key := range keys {
+				g.fail(ctx, key)
+			}
+		case status < http.StatusBadRequest:
+			for _, key := range keys {
+				if err := g.store.Delete(ctx, "fail:"+key.name); err != nil {
+					g.log.Warn("login guard store failed", slog.String("err", err.Error()))
+				}
+			}
+		}
+	}
+}
+
+// guardKey is a counter of failures and the failures it allows before
+// locking out.
+type guardKey struct {
+	name string
+	free int
+}
+
+func (g *LoginGuard) lockedUntil(ctx context.Context, key string) (time.Time, bool) {
+	raw, err := g.store.Get(ctx, "lock:"+key)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			g.log.Warn("login guard store failed", slog.String("err", err.Error()))
+		}
+		return time.Time{}, false
+	}
+	ms, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	until := time.UnixMilli(ms)
+	return until, time.Now().Before(until)
+}
+
+func (g *LoginGuard) failures(ctx context.Context, key string) int {
+	raw, err := g.store.Get(ctx, "fail:"+key)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			g.log.Warn("login guard store failed", slog.String("err", err.Error()))
+		}
+		return 0
+	}
+	n, _ := strconv.Atoi(string(raw))
+	return n
+}
+
+func (g *LoginGuard) solved(c *gin.Context) bool {
+	token := strings.TrimSpace(c.GetHeader(CaptchaTokenHeader))
+	if token == "" {
+		return false
+	}
+	ok, err := g.captcha.Verify(c.Request.Context(), token, c.ClientIP())
+	if err != nil {
+		g.log.Warn("captcha verification failed", slog.String("err", err.Error()))
+		return true
+	}
+	return ok
+}
+
+// fail counts a failure and, past the free attempts of key, locks it for
+// BaseLockout doubled per extra failure.
+func (g *LoginGuard) fail(ctx context.Context, key guardKey) {
+	n, err := g.store.Incr(ctx, "fail:"+key.name, 1, g.cfg.Window)
+	if err != nil {
+		g.log.Warn("login guard store failed", slog.String("err", err.Error()))
+		return
+	}
+	extra := int(n) - key.free
+	if extra <= 0 {
+		return
+	}
+	lockout := g.cfg.BaseLockout
+	for i := 1; i < extra && lockout < g.cfg.MaxLockout; i++ {
+		lockout *= 2
+	}
+	lockout = min(lockout, g.cfg.MaxLockout)
+	until := time.Now().Add(lockout)
+	if err := g.store.Put(ctx, "lock:"+key.name, []byte(strconv.FormatInt(until.UnixMilli(), 10)), lockout); err != nil {
+		g.log.Warn("login guard store failed", slog.String("err", err.Error()))
+	}
+}
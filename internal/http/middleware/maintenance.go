@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/apierror"
+	"github.com/immxrtalbeast/api-gateway/internal/maintenance"
+	"github.com/immxrtalbeast/api-gateway/internal/metrics"
+)
+
+// Maintenance answers 503 with a Retry-After on the routes of groups in
+// maintenance. Unmatched paths are left to the 404 handler.
+func Maintenance(sw *maintenance.Switch) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			c.Next()
+			return
+		}
+		match, ok := sw.Check(c.Request.Method, route)
+		if !ok {
+			c.Next()
+			return
+		}
+		metrics.Maintenance.Add(match.Group, 1)
+		details := map[string]any{
+			"group": match.Group,
+			"route": route,
+		}
+		if !match.Until.IsZero() {
+			details["until"] = match.Until.UTC().Format(time.RFC3339)
+		}
+		if match.RetryAfter > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(match.RetryAfter.Seconds())+1))
+		}
+		apierror.Abort(c, http.StatusServiceUnavailable, apierror.CodeMaintenance, match.Message, details)
+	}
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/apierror"
+)
+
+const OrgRoleAdmin = "admin"
+
+// RequireOrgRole admits only members of an organization holding role, as
+// stated by the org_id/org_role claims issued by the auth service. It must
+// run after AuthMiddleware.
+func RequireOrgRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("orgID") == "" {
+			apierror.Abort(c, http.StatusForbidden, apierror.CodePermissionDenied, "organization membership required", nil)
+			return
+		}
+		if c.GetString("orgRole") != role {
+			apierror.Abort(c, http.StatusForbidden, apierror.CodePermissionDenied, "organization "+role+" role required", map[string]any{
+				"org_role": c.GetString("orgRole"),
+			})
+			return
+		}
+		c.Next()
+	}
+}
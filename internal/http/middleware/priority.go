@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	PriorityLow    = "low"
+	PriorityNormal = "normal"
+	PriorityHigh   = "high"
+)
+
+var priorityRank = map[string]int{PriorityLow: 0, PriorityNormal: 1, PriorityHigh: 2}
+
+type PriorityConfig struct {
+	Default string
+	// Routes maps "METHOD /route/pattern" to its priority.
+	Routes map[string]string
+	// Plans maps plan name to the minimum priority of its requests.
+	Plans map[string]string
+}
+
+// Priority classifies each request as low, normal or high: the route's
+// priority (Default when unlisted), raised to the plan's minimum. The result
+// is stored as "requestPriority" for handlers to forward upstream; a priority
+// sent by the client is ignored. It must run after plan resolution.
+func Priority(cfg PriorityConfig) gin.HandlerFunc {
+	if _, ok := priorityRank[cfg.Default]; !ok {
+		cfg.Default = PriorityNormal
+	}
+	return func(c *gin.Context) {
+		priority := cfg.Default
+		if route, ok := cfg.Routes[c.Request.Method+" "+c.FullPath()]; ok {
+			if _, valid := priorityRank[route]; valid {
+				priority = route
+			}
+		}
+		if plan := c.GetString("userPlan"); plan != "" {
+			if floor, ok := priorityRank[cfg.Plans[plan]]; ok && floor > priorityRank[priority] {
+				priority = cfg.Plans[plan]
+			}
+		}
+		c.Set("requestPriority", priority)
+		c.Next()
+	}
+}
@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/apierror"
+	"github.com/immxrtalbeast/api-gateway/internal/usage"
+)
+
+const usageStoreTimeout = time.Second
+
+// UsageMeter counts metered requests per user and rejects those that would
+// exceed the monthly plan quota with 402 before they reach the upstream.
+// Usage is reserved up front and given back when the request fails, so
+// concurrent requests can't overshoot the quota. If the store is unavailable
+// requests are let through unmetered.
+type UsageMeter struct {
+	store  usage.Store
+	quotas usage.Quotas
+	log    *slog.Logger
+}
+
+func NewUsageMeter(store usage.Store, quotas usage.Quotas, log *slog.Logger) *UsageMeter {
+	return &UsageMeter{store: store, quotas: quotas, log: log}
+}
+
+// Count meters one unit of metric per successful request.
+func (m *UsageMeter) Count(metric string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		m.meter(c, metric, 1, nil)
+	}
+}
+
+// CountBytes meters the request body size as usage.UploadBytes. The declared
+// Content-Length is checked up front; the bytes actually read are recorded.
+func (m *UsageMeter) CountBytes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reserve := c.Request.ContentLength
+		if reserve < 0 {
+			reserve = 0
+		}
+		body := &countingReader{ReadCloser: c.Request.Body}
+		c.Request.Body = body
+		m.meter(c, usage.UploadBytes, reserve, func() int64 { return body.n })
+	}
+}
+
+func (m *UsageMeter) meter(c *gin.Context, metric string, reserve int64, actual func() int64) {
+	if m == nil {
+		c.Next()
+		return
+	}
+	userIDVal, exists := c.Get("userID")
+	if !exists {
+		c.Next()
+		return
+	}
+	userID := fmt.Sprint(userIDVal)
+	now := time.Now()
+	period := usage.Period(now)
+	limit := m.quotas.Limit(UserPlan(c, m.quotas.DefaultPlan), metric)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), usageStoreTimeout)
+	used, err := m.store.Add(ctx, userID, period, metric, reserve)
+	cancel()
+	if err != nil {
+		m.log.Warn("usage metering unavailable", slog.String("metric", metric), slog.String("err", err.Error()))
+		c.Next()
+		return
+	}
+	// With nothing reserved (unknown body size) only an exhausted quota is
+	// rejected up front.
+	if limit > 0 && (used > limit || (reserve == 0 && used >= limit)) {
+		m.add(c, userID, period, metric, -reserve)
+		apierror.Abort(c, http.StatusPaymentRequired, apierror.CodeQuotaExceeded, "monthly "+metric+" quota exceeded", map[string]any{
+			"metric":    metric,
+			"limit":     limit,
+			"used":      used - reserve,
+			"requested": reserve,
+			"resets_at": usage.PeriodEnd(now).Format(time.RFC3339),
+		})
+		return
+	}
+
+	c.Next()
+
+	delta := -reserve
+	if c.Writer.Status() < http.StatusBadRequest {
+		delta = 0
+		if actual != nil {
+			delta = actual() - reserve
+		}
+	}
+	if delta != 0 {
+		m.add(c, userID, period, metric, delta)
+	}
+}
+
+func (m *UsageMeter) add(c *gin.Context, userID, period, metric string, delta int64) {
+	// The client may already be gone; corrections must still land.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), usageStoreTimeout)
+	defer cancel()
+	if _, err := m.store.Add(ctx, userID, period, metric, delta); err != nil {
+		m.log.Warn("usage correction failed", slog.String("metric", metric), slog.String("err", err.Error()))
+	}
+}
+
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/clients/egress"
+)
+
+// ClientRegion passes the client region reported by the edge in header on to
+// the multi-region upstream transports.
+func ClientRegion(header string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if region := strings.TrimSpace(c.GetHeader(header)); region != "" {
+			c.Request = c.Request.WithContext(egress.WithClientRegion(c.Request.Context(), region))
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"container/list"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/apierror"
+)
+
+type RequestQueueConfig struct {
+	MaxConcurrent int
+	MaxQueued     int
+	MaxWait       time.Duration
+}
+
+// RequestQueue caps in-flight requests across all users and, instead of
+// rejecting the excess, holds it in a bounded FIFO served strictly in arrival
+// order. Requests that find the queue full, or wait longer than MaxWait, get
+// 429 with Retry-After.
+type RequestQueue struct {
+	cfg     RequestQueueConfig
+	mu      sync.Mutex
+	active  int
+	waiters *list.List
+}
+
+func NewRequestQueue(cfg RequestQueueConfig) *RequestQueue {
+	return &RequestQueue{cfg: cfg, waiters: list.New()}
+}
+
+func (q *RequestQueue) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if q.cfg.MaxConcurrent <= 0 {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		if !q.acquire(c) {
+			return
+		}
+		defer q.release()
+		if waited := time.Since(start); waited >= time.Millisecond {
+			c.Header("X-Queue-Wait", strconv.FormatInt(waited.Milliseconds(), 10))
+		}
+		c.Next()
+	}
+}
+
+func (q *RequestQueue) acquire(c *gin.Context) bool {
+	q.mu.Lock()
+	if q.active < q.cfg.MaxConcurrent && q.waiters.Len() == 0 {
+		q.active++
+		q.mu.Unlock()
+		return true
+	}
+	if q.waiters.Len() >= q.cfg.MaxQueued || q.cfg.MaxWait <= 0 {
+		queued := q.waiters.Len()
+		q.mu.Unlock()
+		q.reject(c, queued, "queue is full")
+		return false
+	}
+	ready := make(chan struct{})
+	elem := q.waiters.PushBack(ready)
+	q.mu.Unlock()
+
+	timer := time.NewTimer(q.cfg.MaxWait)
+	defer timer.Stop()
+
+	select {
+	case <-ready:
+		return true
+	case <-timer.C:
+	case <-c.Request.Context().Done():
+	}
+
+	q.mu.Lock()
+	select {
+	case <-ready:
+		// The slot was handed over while we were giving up; use it.
+		q.mu.Unlock()
+		return true
+	default:
+	}
+	q.waiters.Remove(elem)
+	queued := q.waiters.Len()
+	q.mu.Unlock()
+	if c.Request.Context().Err() != nil {
+		c.Abort()
+		return false
+	}
+	q.reject(c, queued, "queue wait exceeded")
+	return false
+}
+
+// release hands the slot straight to the oldest waiter, so a newcomer can't
+// overtake the queue between release and wake-up.
+func (q *RequestQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if front := q.waiters.Front(); front != nil {
+		q.waiters.Remove(front)
+		close(front.Value.(chan struct{}))
+		return
+	}
+	q.active--
+}
+
+func (q *RequestQueue) reject(c *gin.Context, queued int, reason string) {
+	retryAfter := int(math.Ceil(q.cfg.MaxWait.Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	apierror.Abort(c, http.StatusTooManyRequests, apierror.CodeRateLimited, "too many requests in progress, retry later", map[string]any{
+		"reason":              reason,
+		"limit":               q.cfg.MaxConcurrent,
+		"queued":              queued,
+		"retry_after_seconds": retryAfter,
+	})
+}
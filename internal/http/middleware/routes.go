@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/apierror"
+	"github.com/immxrtalbeast/api-gateway/internal/reload"
+)
+
+// RouteSwitch turns routes off while the gateway runs. Rules are route
+// templates as registered, optionally prefixed with a method
+// ("DELETE /api/videos/:id"); a trailing "/*" matches every route below the
+// prefix.
+type RouteSwitch struct {
+	disabled reload.Value[[]string]
+}
+
+func NewRouteSwitch(disabled []string) *RouteSwitch {
+	s := &RouteSwitch{}
+	s.Set(disabled)
+	return s
+}
+
+// Set replaces the disabled routes.
+func (s *RouteSwitch) Set(disabled []string) {
+	rules := make([]string, 0, len(disabled))
+	for _, rule := range disabled {
+		if rule = strings.TrimSpace(rule); rule != "" {
+			rules = append(rules, rule)
+		}
+	}
+	s.disabled.Store(rules)
+}
+
+// Middleware answers 503 on disabled routes. Unmatched paths are left to the
+// 404 handler.
+func (s *RouteSwitch) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route != "" && s.off(c.Request.Method, route) {
+			apierror.Abort(c, http.StatusServiceUnavailable, apierror.CodeRouteDisabled, "route is disabled", map[string]any{
+				"route": route,
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+func (s *RouteSwitch) off(method, route string) bool {
+	for _, rule := range s.disabled.Load() {
+		if ruleMethod, rest, ok := strings.Cut(rule, " "); ok {
+			if !strings.EqualFold(ruleMethod, method) {
+				continue
+			}
+			rule = strings.TrimSpace(rest)
+		}
+		if prefix, ok := strings.CutSuffix(rule, "/*"); ok {
+			if route == prefix || strings.HasPrefix(route, prefix+"/") {
+				return true
+			}
+			continue
+		}
+		if route == rule {
+			return true
+		}
+	}
+	return false
+}
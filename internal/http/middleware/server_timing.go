@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/timing"
+)
+
+type ServerTimingConfig struct {
+	// Enabled adds the header to every response.
+	Enabled bool
+	// Header and Token add it to the responses of requests carrying Header
+	// set to Token, e.g. from internal tooling; an empty Token disables this.
+	Header string
+	Token  string
+}
+
+// ServerTiming adds a Server-Timing header breaking the request down into its
+// upstream calls, serialization and the gateway's own time, as recorded until
+// the response headers are written, and the route's timeout override from
+// RouteTimeouts as "budget". Cross-origin pages get Timing-Allow-Origin
+// so their performance entries can read it. It must run after the timing
+// breakdown is attached to the request.
+func ServerTiming(cfg ServerTimingConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !cfg.Enabled && !timingRequested(c, cfg) {
+			c.Next()
+			return
+		}
+		breakdown := timing.FromContext(c.Request.Context())
+		if breakdown == nil {
+			c.Next()
+			return
+		}
+		c.Writer = &serverTimingWriter{
+			ResponseWriter: c.Writer,
+			breakdown:      breakdown,
+			start:          time.Now(),
+			budget:         c.GetDuration("routeTimeout"),
+			origin:         c.GetHeader("Origin"),
+		}
+		c.Next()
+	}
+}
+
+func timingRequested(c *gin.Context, cfg ServerTimingConfig) bool {
+	if cfg.Header == "" || cfg.Token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(c.GetHeader(cfg.Header)), []byte(cfg.Token)) == 1
+}
+
+// serverTimingWriter sets the header just before the response headers go out.
+type serverTimingWriter struct {
+	gin.ResponseWriter
+	breakdown *timing.Breakdown
+	start     time.Time
+	budget    time.Duration
+	origin    string
+	stamped   bool
+}
+
+func (w *serverTimingWriter) stamp() {
+	if w.stamped || w.ResponseWriter.Written() {
+		return
+	}
+	w.stamped = true
+	value := w.breakdown.ServerTiming(time.Since(w.start))
+	if w.budget > 0 {
+		value += `, budget;desc="route timeout";dur=` + strconv.FormatInt(w.budget.Milliseconds(), 10)
+	}
+	header := w.ResponseWriter.Header()
+	header.Set("Server-Timing", value)
+	if w.origin != "" {
+		header.Set("Timing-Allow-Origin", w.origin)
+		header.Add("Vary", "Origin")
+	}
+}
+
+func (w *serverTimingWriter) WriteHeaderNow() {
+	w.stamp()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *serverTimingWriter) Write(data []byte) (int, error) {
+	w.stamp()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *serverTimingWriter) WriteString(s string) (int, error) {
+	w.stamp()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *serverTimingWriter) Flush() {
+	w.stamp()
+	w.ResponseWriter.Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying connection.
+func (w *serverTimingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/reload"
+)
+
+// RouteTimeouts overrides the upstream call timeout of single routes. Routes
+// are named after their handler method in snake case: VideoHandler.ExpandIdea
+// is "expand_idea", ScriptHandler.ListScripts "list_scripts".
+type RouteTimeouts struct {
+	timeouts reload.Value[map[string]time.Duration]
+}
+
+func NewRouteTimeouts(timeouts map[string]time.Duration) *RouteTimeouts {
+	t := &RouteTimeouts{}
+	t.Set(timeouts)
+	return t
+}
+
+// Set replaces the overrides; non-positive durations are dropped.
+func (t *RouteTimeouts) Set(timeouts map[string]time.Duration) {
+	valid := make(map[string]time.Duration, len(timeouts))
+	for name, timeout := range timeouts {
+		if timeout > 0 {
+			valid[strings.ToLower(strings.TrimSpace(name))] = timeout
+		}
+	}
+	t.timeouts.Store(valid)
+}
+
+// Middleware stores the override of the route as "routeTimeout" for handlers
+// to use instead of their service timeout.
+func (t *RouteTimeouts) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		timeouts := t.timeouts.Load()
+		if len(timeouts) > 0 {
+			if timeout, ok := timeouts[RouteName(c.HandlerName())]; ok {
+				c.Set("routeTimeout", timeout)
+			}
+		}
+		c.Next()
+	}
+}
+
+// RouteName returns the snake case name of the method a handler name as
+// reported by gin ("…/handlers.(*VideoHandler).ExpandIdea-fm") refers to.
+func RouteName(handler string) string {
+	handler = strings.TrimSuffix(handler, "-fm")
+	handler = handler[strings.LastIndex(handler, ".")+1:]
+	var name strings.Builder
+	runes := []rune(handler)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// Split before a capital starting a word: GetIDToken is get_id_token.
+			if i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				name.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		name.WriteRune(r)
+	}
+	return name.String()
+}
@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/apierror"
+)
+
+type UploadLimitConfig struct {
+	MaxConcurrent int
+	MaxQueued     int
+	QueueTimeout  time.Duration
+}
+
+type uploadSlots struct {
+	sem     chan struct{}
+	waiting int
+	refs    int
+}
+
+// UploadLimiter caps in-flight upload requests per user. Requests above the
+// cap wait in a short per-user queue; when the queue is full (or the wait
+// times out) the client gets 429 with its would-be queue position.
+type UploadLimiter struct {
+	cfg   UploadLimitConfig
+	mu    sync.Mutex
+	users map[string]*uploadSlots
+}
+
+func NewUploadLimiter(cfg UploadLimitConfig) *UploadLimiter {
+	return &UploadLimiter{cfg: cfg, users: make(map[string]*uploadSlots)}
+}
+
+func (l *UploadLimiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if l.cfg.MaxConcurrent <= 0 {
+			c.Next()
+			return
+		}
+		userIDVal, exists := c.Get("userID")
+		if !exists {
+			c.Next()
+			return
+		}
+		userID := fmt.Sprint(userIDVal)
+
+		slots := l.acquire(userID)
+		defer l.release(userID, slots)
+
+		select {
+		case slots.sem <- struct{}{}:
+		default:
+			if !l.wait(c, slots) {
+				return
+			}
+		}
+		defer func() { <-slots.sem }()
+		c.Next()
+	}
+}
+
+func (l *UploadLimiter) wait(c *gin.Context, slots *uploadSlots) bool {
+	l.mu.Lock()
+	position := slots.waiting + 1
+	if slots.waiting >= l.cfg.MaxQueued || l.cfg.QueueTimeout <= 0 {
+		inFlight := len(slots.sem)
+		l.mu.Unlock()
+		l.reject(c, inFlight, position)
+		return false
+	}
+	slots.waiting++
+	l.mu.Unlock()
+
+	timer := time.NewTimer(l.cfg.QueueTimeout)
+	defer timer.Stop()
+
+	acquired := false
+	select {
+	case slots.sem <- struct{}{}:
+		acquired = true
+	case <-timer.C:
+	case <-c.Request.Context().Done():
+	}
+
+	l.mu.Lock()
+	slots.waiting--
+	l.mu.Unlock()
+
+	if !acquired {
+		l.reject(c, len(slots.sem), position)
+	}
+	return acquired
+}
+
+func (l *UploadLimiter) reject(c *gin.Context, inFlight, position int) {
+	c.Header("Retry-After", "1")
+	apierror.Abort(c, http.StatusTooManyRequests, apierror.CodeTooManyUploads, "too many concurrent uploads", map[string]any{
+		"limit":          l.cfg.MaxConcurrent,
+		"in_flight":      inFlight,
+		"queue_position": position,
+	})
+}
+
+func (l *UploadLimiter) acquire(userID string) *uploadSlots {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	slots, ok := l.users[userID]
+	if !ok {
+		slots = &uploadSlots{sem: make(chan struct{}, l.cfg.MaxConcurrent)}
+		l.users[userID] = slots
+	}
+	slots.refs++
+	return slots
+}
+
+func (l *UploadLimiter) release(userID string, slots *uploadSlots) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	slots.refs--
+	if slots.refs == 0 {
+		delete(l.users, userID)
+	}
+}
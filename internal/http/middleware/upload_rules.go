@@ -0,0 +1,251 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/apierror"
+	"github.com/immxrtalbeast/api-gateway/lib/jsonpath"
+)
+
+// Upload kinds with their own type, extension and size rules.
+const (
+	UploadImage = "image"
+	UploadAudio = "audio"
+	UploadVideo = "video"
+)
+
+const (
+	// multipartOverhead is allowed on top of MaxBytes for the form encoding
+	// and the other fields of multipart uploads.
+	multipartOverhead  = 1 << 20
+	maxMultipartMemory = 32 << 20
+	sniffLen           = 512
+)
+
+// UploadRule limits one kind of upload. Empty Types or Extensions accept
+// anything; a non-positive MaxBytes means no size limit. Kinds without a rule
+// accept everything.
+type UploadRule struct {
+	Types      []string
+	Extensions []string
+	MaxBytes   int64
+}
+
+type UploadRulesConfig struct {
+	Rules map[string]UploadRule
+	// TypePath and NamePath locate the declared MIME type and file name in
+	// JSON upload bodies.
+	TypePath string
+	NamePath string
+}
+
+// UploadRules rejects media the video service would refuse, before the bytes
+// travel upstream: 413 when the file is larger than its kind allows, 415 when
+// its MIME type or extension isn't accepted, both with the limits in details.
+// JSON bodies are checked by their declared type and file name; multipart
+// uploads by the "file" part, whose content is sniffed when the declared type
+// is generic. Uploads that declare neither type nor name are only size
+// checked.
+type UploadRules struct {
+	cfg UploadRulesConfig
+}
+
+func NewUploadRules(cfg UploadRulesConfig) *UploadRules {
+	if cfg.TypePath == "" {
+		cfg.TypePath = "content_type"
+	}
+	if cfg.NamePath == "" {
+		cfg.NamePath = "filename"
+	}
+	return &UploadRules{cfg: cfg}
+}
+
+type uploadError struct {
+	status  int
+	code    apierror.Code
+	message string
+	details map[string]any
+}
+
+// Allow accepts uploads matching any of kinds.
+func (u *UploadRules) Allow(kinds ...string) gin.HandlerFunc {
+	limit := u.maxBytes(kinds)
+	return func(c *gin.Context) {
+		multipartBody := strings.HasPrefix(c.ContentType(), "multipart/")
+		bodyLimit := limit
+		if multipartBody && bodyLimit > 0 {
+			bodyLimit += multipartOverhead
+		}
+		if bodyLimit > 0 && c.Request.ContentLength > bodyLimit {
+			u.abort(c, u.tooLarge(kinds, ""))
+			return
+		}
+		if bodyLimit > 0 {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, bodyLimit)
+		}
+
+		var failure *uploadError
+		if multipartBody {
+			failure = u.checkMultipart(c, kinds)
+		} else {
+			failure = u.checkJSON(c, kinds)
+		}
+		if failure != nil {
+			u.abort(c, failure)
+			return
+		}
+		c.Next()
+	}
+}
+
+func (u *UploadRules) checkJSON(c *gin.Context, kinds []string) *uploadError {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return u.tooLarge(kinds, "")
+		}
+		return &uploadError{status: http.StatusBadRequest, code: apierror.CodeInvalidRequest, message: "failed to read request body"}
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	contentType, _ := jsonpath.String(body, u.cfg.TypePath)
+	name, _ := jsonpath.String(body, u.cfg.NamePath)
+	return u.check(kinds, contentType, name, int64(len(body)))
+}
+
+// checkMultipart parses the form up front; the handler's own
+// ParseMultipartForm call then reuses it.
+func (u *UploadRules) checkMultipart(c *gin.Context, kinds []string) *uploadError {
+	if err := c.Request.ParseMultipartForm(maxMultipartMemory); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return u.tooLarge(kinds, "")
+		}
+		// Left for the handler to report.
+		return nil
+	}
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	contentType := header.Header.Get("Content-Type")
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == "" || mediaType == "application/octet-stream" {
+		head := make([]byte, sniffLen)
+		n, _ := io.ReadFull(file, head)
+		contentType = http.DetectContentType(head[:n])
+		if contentType == "application/octet-stream" {
+			// Unrecognized content is judged by its extension.
+			contentType = ""
+		}
+	}
+	return u.check(kinds, contentType, header.Filename, header.Size)
+}
+
+func (u *UploadRules) check(kinds []string, contentType, name string, size int64) *uploadError {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	mediaType = strings.ToLower(mediaType)
+	ext := strings.ToLower(path.Ext(name))
+	if mediaType == "" && ext == "" {
+		if limit := u.maxBytes(kinds); limit > 0 && size > limit {
+			return u.tooLarge(kinds, "")
+		}
+		return nil
+	}
+
+	kind, ok := u.match(kinds, mediaType, ext)
+	if !ok {
+		return u.unsupported(kinds, mediaType, ext)
+	}
+	if rule := u.cfg.Rules[kind]; rule.MaxBytes > 0 && size > rule.MaxBytes {
+		return u.tooLarge([]string{kind}, kind)
+	}
+	return nil
+}
+
+// match finds the kind accepting both the MIME type and the extension; an
+// unknown type or extension is judged by the other alone.
+func (u *UploadRules) match(kinds []string, mediaType, ext string) (string, bool) {
+	for _, kind := range kinds {
+		rule := u.cfg.Rules[kind]
+		if mediaType != "" && !listed(rule.Types, mediaType) {
+			continue
+		}
+		if ext != "" && !listed(rule.Extensions, ext) {
+			continue
+		}
+		return kind, true
+	}
+	return "", false
+}
+
+func listed(allowed []string, value string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if strings.EqualFold(a, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *UploadRules) unsupported(kinds []string, mediaType, ext string) *uploadError {
+	var types, extensions []string
+	for _, kind := range kinds {
+		types = append(types, u.cfg.Rules[kind].Types...)
+		extensions = append(extensions, u.cfg.Rules[kind].Extensions...)
+	}
+	return &uploadError{
+		status:  http.StatusUnsupportedMediaType,
+		code:    apierror.CodeUnsupportedMediaType,
+		message: "unsupported file type, expected " + strings.Join(kinds, " or "),
+		details: map[string]any{
+			"content_type":       mediaType,
+			"extension":          ext,
+			"allowed_types":      types,
+			"allowed_extensions": extensions,
+		},
+	}
+}
+
+// tooLarge reports the limit of kind, or the largest limit of kinds when the
+// kind isn't known yet.
+func (u *UploadRules) tooLarge(kinds []string, kind string) *uploadError {
+	details := map[string]any{"max_bytes": u.maxBytes(kinds)}
+	if kind != "" {
+		details["kind"] = kind
+	}
+	return &uploadError{
+		status:  http.StatusRequestEntityTooLarge,
+		code:    apierror.CodePayloadTooLarge,
+		message: "file is too large",
+		details: details,
+	}
+}
+
+func (u *UploadRules) maxBytes(kinds []string) int64 {
+	var limit int64
+	for _, kind := range kinds {
+		rule := u.cfg.Rules[kind]
+		if rule.MaxBytes <= 0 {
+			return 0
+		}
+		if rule.MaxBytes > limit {
+			limit = rule.MaxBytes
+		}
+	}
+	return limit
+}
+
+func (u *UploadRules) abort(c *gin.Context, e *uploadError) {
+	apierror.Abort(c, e.status, e.code, e.message, e.details)
+}
@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/apierror"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+const (
+	SchemaCreateVideo  = "create_video"
+	SchemaCreateScript = "create_script"
+	SchemaExpandIdea   = "expand_idea"
+)
+
+// JSONValidator rejects request bodies that don't match the JSON Schema
+// configured for the route, before they reach the paid upstreams.
+type JSONValidator struct {
+	schemas map[string]*jsonschema.Schema
+}
+
+// NewJSONValidator compiles the schema files keyed by route name.
+func NewJSONValidator(files map[string]string) (*JSONValidator, error) {
+	compiler := jsonschema.NewCompiler()
+	v := &JSONValidator{schemas: make(map[string]*jsonschema.Schema, len(files))}
+	for route, path := range files {
+		if path == "" {
+			continue
+		}
+		schema, err := compiler.Compile(path)
+		if err != nil {
+			return nil, fmt.Errorf("compile schema %q: %w", route, err)
+		}
+		v.schemas[route] = schema
+	}
+	return v, nil
+}
+
+// Route validates the body against the schema of route. Routes without a
+// schema pass through. The body is restored for the handler.
+func (v *JSONValidator) Route(route string) gin.HandlerFunc {
+	schema := v.schemas[route]
+	return func(c *gin.Context) {
+		if schema == nil {
+			c.Next()
+			return
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			apierror.Abort(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "failed to read request body", nil)
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		var doc any
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		if err := decoder.Decode(&doc); err != nil {
+			apierror.Abort(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "invalid json payload", nil)
+			return
+		}
+		if err := schema.Validate(doc); err != nil {
+			ve, ok := err.(*jsonschema.ValidationError)
+			if !ok {
+				apierror.Abort(c, http.StatusInternalServerError, apierror.CodeInternal, "validation failed", nil)
+				return
+			}
+			apierror.Abort(c, http.StatusUnprocessableEntity, apierror.CodeValidationFailed, "request validation failed", map[string]any{
+				"fields": fieldErrors(ve),
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// fieldErrors flattens the validation tree to its leaves, one per offending
+// field, using JSON pointers as field names.
+func fieldErrors(ve *jsonschema.ValidationError) []apierror.FieldError {
+	var res []apierror.FieldError
+	var walk func(*jsonschema.ValidationError)
+	walk = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) == 0 {
+			field := e.InstanceLocation
+			if field == "" {
+				field = "/"
+			}
+			res = append(res, apierror.FieldError{Field: field, Message: e.Message})
+			return
+		}
+		for _, cause := range e.Causes {
+			walk(cause)
+		}
+	}
+	walk(ve)
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Field < res[j].Field })
+	return res
+}
@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/immxrtalbeast/api-gateway/internal/apierror"
+	"github.com/immxrtalbeast/api-gateway/lib/jsonpath"
+)
+
+// VideoLimit caps the videos of one plan. Zero values mean no limit.
+type VideoLimit struct {
+	MaxDuration time.Duration
+	// MaxResolution is like "1080p"; see parseResolution for the accepted
+	// spellings.
+	MaxResolution string
+}
+
+type VideoLimitsConfig struct {
+	DefaultPlan string
+	// DurationPath locates the video duration in the request body, in
+	// seconds or as a Go duration string ("90s").
+	DurationPath string
+	// ResolutionPath locates the requested resolution, e.g. "720p" or
+	// "1280x720".
+	ResolutionPath string
+	Plans          map[string]VideoLimit
+}
+
+// limitViolation is one field of the request beyond the plan's limit.
+type limitViolation struct {
+	Field string `json:"field"`
+	Value any    `json:"value"`
+	Max   any    `json:"max"`
+}
+
+// VideoLimits rejects CreateVideo requests asking for a longer or sharper
+// video than the user's plan allows, with 422 plan_limit_exceeded listing the
+// offending fields and the plan's limits, before the job reaches the render
+// queue. Requests without the fields, and plans without limits, pass through.
+func VideoLimits(cfg VideoLimitsConfig) (gin.HandlerFunc, error) {
+	for plan, limit := range cfg.Plans {
+		if limit.MaxResolution == "" {
+			continue
+		}
+		if _, err := parseResolution(limit.MaxResolution); err != nil {
+			return nil, fmt.Errorf("plan %q: %w", plan, err)
+		}
+	}
+	return func(c *gin.Context) {
+		plan := UserPlan(c, cfg.DefaultPlan)
+		limit, ok := cfg.Plans[plan]
+		if !ok || (limit.MaxDuration <= 0 && limit.MaxResolution == "") {
+			c.Next()
+			return
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			apierror.Abort(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "failed to read request body", nil)
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		var doc any
+		if err := json.Unmarshal(body, &doc); err != nil {
+			apierror.Abort(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "invalid json payload", nil)
+			return
+		}
+
+		var violations []limitViolation
+		if value, found := jsonpath.Lookup(doc, cfg.DurationPath); found && limit.MaxDuration > 0 {
+			duration, err := parseVideoDuration(value)
+			if err != nil {
+				abortInvalidField(c, cfg.DurationPath, err)
+				return
+			}
+			if duration > limit.MaxDuration {
+				violations = append(violations, limitViolation{Field: fieldPointer(cfg.DurationPath), Value: value, Max: limit.MaxDuration.Seconds()})
+			}
+		}
+		if value, found := jsonpath.Lookup(doc, cfg.ResolutionPath); found && limit.MaxResolution != "" {
+			lines, err := parseResolution(fmt.Sprint(value))
+			if err != nil {
+				abortInvalidField(c, cfg.ResolutionPath, err)
+				return
+			}
+			maxLines, _ := parseResolution(limit.MaxResolution)
+			if lines > maxLines {
+				violations = append(violations, limitViolation{Field: fieldPointer(cfg.ResolutionPath), Value: value, Max: limit.MaxResolution})
+			}
+		}
+		if len(violations) > 0 {
+			limits := map[string]any{}
+			if limit.MaxDuration > 0 {
+				limits["max_duration_seconds"] = limit.MaxDuration.Seconds()
+			}
+			if limit.MaxResolution != "" {
+				limits["max_resolution"] = limit.MaxResolution
+			}
+			apierror.Abort(c, http.StatusUnprocessableEntity, apierror.CodePlanLimitExceeded, "video exceeds the limits of your plan", map[string]any{
+				"plan":       plan,
+				"violations": violations,
+				"limits":     limits,
+			})
+			return
+		}
+		c.Next()
+	}, nil
+}
+
+func abortInvalidField(c *gin.Context, path string, err error) {
+	apierror.Abort(c, http.StatusUnprocessableEntity, apierror.CodeValidationFailed, "request validation failed", map[string]any{
+		"fields": []apierror.FieldError{{Field: fieldPointer(path), Message: err.Error()}},
+	})
+}
+
+// fieldPointer turns a dotted path into the JSON Pointer used in field errors.
+func fieldPointer(path string) string {
+	return "/" + strings.ReplaceAll(path, ".", "/")
+}
+
+func parseVideoDuration(value any) (time.Duration, error) {
+	switch v := value.(type) {
+	case float64:
+		return time.Duration(v * float64(time.Second)), nil
+	case string:
+		if seconds, err := strconv.ParseFloat(v, 64); err == nil {
+			return time.Duration(seconds * float64(time.Second)), nil
+		}
+		if d, err := time.ParseDuration(v); err == nil {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("duration must be a number of seconds or a duration like \"90s\"")
+}
+
+// parseResolution returns the line count of a resolution: "720p" is 720,
+// "4k" 2160 and "1280x720" (or "720x1280" for portrait videos) 720.
+func parseResolution(s string) (int, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "2k":
+		return 1440, nil
+	case "4k":
+		return 2160, nil
+	case "8k":
+		return 4320, nil
+	}
+	if lines, ok := strings.CutSuffix(s, "p"); ok {
+		if n, err := strconv.Atoi(lines); err == nil && n > 0 {
+			return n, nil
+		}
+	}
+	if width, height, ok := strings.Cut(s, "x"); ok {
+		w, errW := strconv.Atoi(width)
+		h, errH := strconv.Atoi(height)
+		if errW == nil && errH == nil && w > 0 && h > 0 {
+			return min(w, h), nil
+		}
+	}
+	return 0, fmt.Errorf("resolution %q must look like \"720p\", \"4k\" or \"1280x720\"", s)
+}
@@ -0,0 +1,153 @@
+// Package journal keeps mutating user requests that failed upstream in a
+// file-backed queue so they can be replayed once the upstream recovers.
+package journal
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/immxrtalbeast/api-gateway/lib/jsonfile"
+)
+
+var ErrFull = errors.New("journal is full")
+
+type Entry struct {
+	ID            string            `json:"id"`
+	Operation     string            `json:"operation"`
+	ResourceID    string            `json:"resource_id"`
+	UserID        string            `json:"user_id,omitempty"`
+	Headers       map[string]string `json:"headers,omitempty"`
+	Body          []byte            `json:"body,omitempty"`
+	Error         string            `json:"error"`
+	Attempts      int               `json:"attempts"`
+	CreatedAt     time.Time         `json:"created_at"`
+	LastAttemptAt time.Time         `json:"last_attempt_at,omitzero"`
+}
+
+type ReplayResult struct {
+	Replayed  int `json:"replayed"`
+	Failed    int `json:"failed"`
+	Remaining int `json:"remaining"`
+}
+
+// Journal persists its entries to a JSON file, rewritten on every change. It is meant for short outages, so it holds at most MaxEntries.
+type Journal struct {
+	mu         sync.Mutex
+	replayMu   sync.Mutex
+	path       string
+	maxEntries int
+	entries    []Entry
+}
+
+func Open(path string, maxEntries int) (*Journal, error) {
+	if path == "" {
+		return nil, fmt.Errorf("journal path is required")
+	}
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	j := &Journal{path: path, maxEntries: maxEntries}
+	if err := jsonfile.Read(path, &j.entries); err != nil {
+		return nil, fmt.Errorf("open journal: %w", err)
+	}
+	return j, nil
+}
+
+func (j *Journal) Append(e Entry) (Entry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if len(j.entries) >= j.maxEntries {
+		return Entry{}, ErrFull
+	}
+	e.ID = newID()
+	e.CreatedAt = time.Now().UTC()
+	j.entries = append(j.entries, e)
+	if err := j.persistLocked(); err != nil {
+		j.entries = j.entries[:len(j.entries)-1]
+		return Entry{}, err
+	}
+	return e, nil
+}
+
+func (j *Journal) List() []Entry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]Entry(nil), j.entries...)
+}
+
+func (j *Journal) Len() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return len(j.entries)
+}
+
+// Remove drops an entry without replaying it.
+func (j *Journal) Remove(id string) (bool, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for i, e := range j.entries {
+		if e.ID == id {
+			j.entries = append(j.entries[:i:i], j.entries[i+1:]...)
+			return true, j.persistLocked()
+		}
+	}
+	return false, nil
+}
+
+// Replay calls send for every entry in order. Entries for which send
+// succeeds are removed, the rest stay with their attempt counter bumped.
+// Concurrent replays are serialized.
+func (j *Journal) Replay(ctx context.Context, send func(context.Context, Entry) error) (ReplayResult, error) {
+	j.replayMu.Lock()
+	defer j.replayMu.Unlock()
+
+	var res ReplayResult
+	done := make(map[string]bool)
+	failed := make(map[string]string)
+	for _, e := range j.List() {
+		if ctx.Err() != nil {
+			break
+		}
+		if err := send(ctx, e); err != nil {
+			failed[e.ID] = err.Error()
+			res.Failed++
+			continue
+		}
+		done[e.ID] = true
+		res.Replayed++
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now().UTC()
+	kept := j.entries[:0]
+	for _, e := range j.entries {
+		if done[e.ID] {
+			continue
+		}
+		if msg, ok := failed[e.ID]; ok {
+			e.Attempts++
+			e.Error = msg
+			e.LastAttemptAt = now
+		}
+		kept = append(kept, e)
+	}
+	j.entries = kept
+	res.Remaining = len(kept)
+	return res, j.persistLocked()
+}
+
+func (j *Journal) persistLocked() error {
+	return jsonfile.Write(j.path, j.entries)
+}
+
+func newID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
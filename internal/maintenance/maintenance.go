@@ -0,0 +1,309 @@
+// Package maintenance puts groups of routes into maintenance: their requests
+// are answered 503 while the rest of the API keeps working, e.g. video
+// creation is switched off during an incident while reads and sign-in stay
+// up. Groups are defined in the config, which can also keep some of them in
+// maintenance; admins switch the others on and off at runtime. Those
+// switches live in the gateway store, so every replica applies them.
+package maintenance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/immxrtalbeast/api-gateway/internal/store"
+)
+
+// Sources of an active group.
+const (
+	SourceConfig = "config"
+	SourceAdmin  = "admin"
+)
+
+var (
+	ErrUnknownGroup = errors.New("unknown maintenance group")
+	// ErrConfigured is returned when stopping a group kept in maintenance by
+	// the config.
+	ErrConfigured = errors.New("maintenance group is active in the config")
+)
+
+// exemptPrefixes are never put into maintenance, so users can still sign in
+// and admins can always end the maintenance.
+var exemptPrefixes = []string{"/api/auth/", "/api/admin/"}
+
+type Config struct {
+	// Groups maps a group name to its route rules: route templates as
+	// registered, optionally prefixed with a method ("GET /api/videos/:id");
+	// a trailing "/*" matches every route below the prefix. Rules without a
+	// method match every method but GET, HEAD and OPTIONS, so reads stay up.
+	Groups map[string][]string
+	// Active groups are in maintenance for as long as the config says so.
+	Active []string
+	// Message is told to clients unless the switch has its own.
+	Message string
+	// RetryAfter is suggested to clients of groups without an end time.
+	RetryAfter time.Duration
+}
+
+// Window is an admin's switch of a group into maintenance.
+type Window struct {
+	Group   string    `json:"group"`
+	Message string    `json:"message,omitempty"`
+	Since   time.Time `json:"since"`
+	// Until is zero when the group stays in maintenance until switched off.
+	Until time.Time `json:"until,omitzero"`
+	By    string    `json:"by,omitempty"`
+}
+
+// Status describes a group for the admin API.
+type Status struct {
+	Name   string   `json:"name"`
+	Routes []string `json:"routes"`
+	Active bool     `json:"active"`
+	// Source is SourceConfig or SourceAdmin for active groups.
+	Source  string     `json:"source,omitempty"`
+	Message string     `json:"message,omitempty"`
+	Since   *time.Time `json:"since,omitempty"`
+	Until   *time.Time `json:"until,omitzero"`
+	By      string     `json:"by,omitempty"`
+}
+
+// Match is the maintenance a request ran into.
+type Match struct {
+	Group   string
+	Message string
+	// Until is zero when the end isn't known.
+	Until      time.Time
+	RetryAfter time.Duration
+}
+
+// Switch holds the groups and their state. A request matching several
+// active groups is reported against the first one by name.
+type Switch struct {
+	store store.Store
+	log   *slog.Logger
+
+	mu      sync.RWMutex
+	cfg     Config
+	windows map[string]Window
+}
+
+// New keeps the admin switches in st, which should be scoped to its own
+// prefix.
+func New(st store.Store, cfg Config, log *slog.Logger) *Switch {
+	s := &Switch{store: st, log: log, windows: make(map[string]Window)}
+	s.Set(cfg)
+	return s
+}
+
+// Set replaces the groups and those active in the config. Admin switches of
+// groups no longer defined stop applying.
+func (s *Switch) Set(cfg Config) {
+	groups := make(map[string][]string, len(cfg.Groups))
+	for name, rules := range cfg.Groups {
+		name = strings.TrimSpace(name)
+		for _, rule := range rules {
+			if rule = strings.TrimSpace(rule); rule != "" && name != "" {
+				groups[name] = append(groups[name], rule)
+			}
+		}
+	}
+	cfg.Groups = groups
+	if cfg.Message == "" {
+		cfg.Message = "this part of the service is under maintenance"
+	}
+	s.mu.Lock()
+	s.cfg = cfg
+	s.mu.Unlock()
+}
+
+// Run loads the admin switches once before returning, then reloads them
+// every interval until ctx is done, picking up those of other replicas.
+func (s *Switch) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	s.refresh(ctx)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.refresh(ctx)
+			}
+		}
+	}()
+}
+
+func (s *Switch) refresh(ctx context.Context) {
+	entries, err := s.store.List(ctx, "")
+	if err != nil {
+		// Keep the known switches rather than end them on a store outage.
+		s.log.Warn("failed to load maintenance switches", slog.String("err", err.Error()))
+		return
+	}
+	windows := make(map[string]Window, len(entries))
+	for _, entry := range entries {
+		var w Window
+		if err := json.Unmarshal(entry.Value, &w); err != nil {
+			s.log.Warn("invalid maintenance switch", slog.String("key", entry.Key), slog.String("err", err.Error()))
+			continue
+		}
+		windows[entry.Key] = w
+	}
+	s.mu.Lock()
+	s.windows = windows
+	s.mu.Unlock()
+}
+
+// Start switches group into maintenance; a zero w.Until keeps it there
+// until Stop. It replaces an earlier switch of the group.
+func (s *Switch) Start(ctx context.Context, w Window) error {
+	s.mu.RLock()
+	_, ok := s.cfg.Groups[w.Group]
+	s.mu.RUnlock()
+	if !ok {
+		return ErrUnknownGroup
+	}
+	var ttl time.Duration
+	if !w.Until.IsZero() {
+		if ttl = time.Until(w.Until); ttl <= 0 {
+			return fmt.Errorf("maintenance end %s is in the past", w.Until.Format(time.RFC3339))
+		}
+	}
+	value, err := json.Marshal(w)
+	if err != nil {
+		return err
+	}
+	if err := s.store.Put(ctx, w.Group, value, ttl); err != nil {
+		return fmt.Errorf("store maintenance switch: %w", err)
+	}
+	s.mu.Lock()
+	s.windows[w.Group] = w
+	s.mu.Unlock()
+	return nil
+}
+
+// Stop ends the admin switch of group. Other replicas follow within the
+// refresh interval.
+func (s *Switch) Stop(ctx context.Context, group string) error {
+	s.mu.RLock()
+	_, ok := s.cfg.Groups[group]
+	configured := slices.Contains(s.cfg.Active, group)
+	s.mu.RUnlock()
+	if !ok {
+		return ErrUnknownGroup
+	}
+	if configured {
+		return ErrConfigured
+	}
+	if err := s.store.Delete(ctx, group); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("delete maintenance switch: %w", err)
+	}
+	s.mu.Lock()
+	delete(s.windows, group)
+	s.mu.Unlock()
+	return nil
+}
+
+// Groups lists every group with its state, by name.
+func (s *Switch) Groups() []Status {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	now := time.Now()
+	statuses := make([]Status, 0, len(s.cfg.Groups))
+	for name, rules := range s.cfg.Groups {
+		status := Status{Name: name, Routes: rules}
+		if slices.Contains(s.cfg.Active, name) {
+			status.Active = true
+			status.Source = SourceConfig
+			status.Message = s.cfg.Message
+		} else if w, ok := s.windows[name]; ok && (w.Until.IsZero() || now.Before(w.Until)) {
+			status.Active = true
+			status.Source = SourceAdmin
+			status.Message = orDefault(w.Message, s.cfg.Message)
+			status.Since = &w.Since
+			if !w.Until.IsZero() {
+				status.Until = &w.Until
+			}
+			status.By = w.By
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// Check reports the maintenance a request to the route template route runs
+// into, if any.
+func (s *Switch) Check(method, route string) (Match, bool) {
+	for _, prefix := range exemptPrefixes {
+		if strings.HasPrefix(route, prefix) {
+			return Match{}, false
+		}
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	now := time.Now()
+	var names []string
+	for name := range s.cfg.Groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		match := Match{Group: name, Message: s.cfg.Message, RetryAfter: s.cfg.RetryAfter}
+		if !slices.Contains(s.cfg.Active, name) {
+			w, ok := s.windows[name]
+			if !ok || (!w.Until.IsZero() && !now.Before(w.Until)) {
+				continue
+			}
+			match.Message = orDefault(w.Message, s.cfg.Message)
+			if match.Until = w.Until; !w.Until.IsZero() {
+				match.RetryAfter = w.Until.Sub(now)
+			}
+		}
+		for _, rule := range s.cfg.Groups[name] {
+			if matches(rule, method, route) {
+				return match, true
+			}
+		}
+	}
+	return Match{}, false
+}
+
+// matches applies a rule as documented on Config.Groups.
+func matches(rule, method, route string) bool {
+	if ruleMethod, rest, ok := strings.Cut(rule, " "); ok {
+		if !strings.EqualFold(ruleMethod, method) {
+			return false
+		}
+		rule = strings.TrimSpace(rest)
+	} else {
+		switch method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			return false
+		}
+	}
+	if prefix, ok := strings.CutSuffix(rule, "/*"); ok {
+		return route == prefix || strings.HasPrefix(route, prefix+"/")
+	}
+	return route == rule
+}
+
+func orDefault(message, fallback string) string {
+	if message != "" {
+		return message
+	}
+	return fallback
+}
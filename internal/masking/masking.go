@@ -0,0 +1,134 @@
+package masking
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+const (
+	ActionRemove = "remove"
+	ActionRedact = "redact"
+
+	redactedValue = "[REDACTED]"
+)
+
+// Rule hides a field in upstream JSON payloads. A Path without dots matches
+// the key at any depth; a dotted path is anchored at the document root, may
+// use "*" for any key and walks through arrays transparently.
+type Rule struct {
+	Path   string
+	Action string
+}
+
+type compiledRule struct {
+	segments []string
+	anywhere bool
+	remove   bool
+}
+
+// Masker removes or redacts sensitive fields from JSON documents.
+type Masker struct {
+	rules []compiledRule
+}
+
+func New(rules []Rule) *Masker {
+	if len(rules) == 0 {
+		return nil
+	}
+	m := &Masker{}
+	for _, rule := range rules {
+		path := strings.TrimSpace(rule.Path)
+		if path == "" {
+			continue
+		}
+		m.rules = append(m.rules, compiledRule{
+			segments: strings.Split(path, "."),
+			anywhere: !strings.Contains(path, "."),
+			remove:   rule.Action != ActionRedact,
+		})
+	}
+	return m
+}
+
+// Apply returns body with all rules applied. Non-JSON bodies and a nil
+// Masker leave the body untouched.
+func (m *Masker) Apply(body []byte) []byte {
+	if m == nil || len(m.rules) == 0 || len(body) == 0 {
+		return body
+	}
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return body
+	}
+	changed := false
+	for _, rule := range m.rules {
+		if rule.anywhere {
+			changed = maskAnywhere(doc, rule.segments[0], rule.remove) || changed
+			continue
+		}
+		changed = maskPath(doc, rule.segments, rule.remove) || changed
+	}
+	if !changed {
+		return body
+	}
+	masked, err := json.Marshal(doc)
+	if err != nil {
+		return body
+	}
+	return masked
+}
+
+func maskAnywhere(node any, key string, remove bool) bool {
+	changed := false
+	switch v := node.(type) {
+	case map[string]any:
+		for k, child := range v {
+			if strings.EqualFold(k, key) {
+				maskField(v, k, remove)
+				changed = true
+				continue
+			}
+			changed = maskAnywhere(child, key, remove) || changed
+		}
+	case []any:
+		for _, child := range v {
+			changed = maskAnywhere(child, key, remove) || changed
+		}
+	}
+	return changed
+}
+
+func maskPath(node any, segments []string, remove bool) bool {
+	switch v := node.(type) {
+	case []any:
+		changed := false
+		for _, child := range v {
+			changed = maskPath(child, segments, remove) || changed
+		}
+		return changed
+	case map[string]any:
+		head, rest := segments[0], segments[1:]
+		changed := false
+		for k, child := range v {
+			if head != "*" && k != head {
+				continue
+			}
+			if len(rest) == 0 {
+				maskField(v, k, remove)
+				changed = true
+				continue
+			}
+			changed = maskPath(child, rest, remove) || changed
+		}
+		return changed
+	}
+	return false
+}
+
+func maskField(obj map[string]any, key string, remove bool) {
+	if remove {
+		delete(obj, key)
+		return
+	}
+	obj[key] = redactedValue
+}
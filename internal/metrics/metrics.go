@@ -0,0 +1,83 @@
+package metrics
+
+import "expvar"
+
+// Counters are published through expvar and served at /debug/vars.
+var (
+	// AbandonedRequests counts requests whose client disconnected before the
+	// gateway could deliver the upstream response, keyed by route.
+	AbandonedRequests = expvar.NewMap("gateway_abandoned_requests")
+
+	// KafkaConsumer holds the job update consumer counters (messages,
+	// dispatched, dead_lettered, dropped, read_errors, commit_errors) and lag.
+	KafkaConsumer = expvar.NewMap("gateway_kafka_consumer")
+
+	// MediaConsumer holds the same counters for the media processing events.
+	MediaConsumer = expvar.NewMap("gateway_media_consumer")
+)
+
+// Audit counts audit events written to the sink (recorded), lost because
+// the buffer was full (dropped) and rejected by the sink (sink_errors), and
+// the download receipts stored (receipts) or not (receipt_errors).
+var Audit = expvar.NewMap("gateway_audit")
+
+// ClientErrors counts frontend error reports accepted by /api/client-errors
+// (received), lost because the buffer was full (dropped), sent to the tracker
+// (forwarded) and rejected by it (tracker_errors).
+var ClientErrors = expvar.NewMap("gateway_client_errors")
+
+// GRPC holds the connectivity state and the number of state transitions of
+// each upstream gRPC connection, e.g. auth_state and auth_transitions.
+var GRPC = expvar.NewMap("gateway_grpc")
+
+// Credits counts credits charged for completed renders (charged), charges lost
+// because the queue was full (dropped) or the usage store failed (errors), and
+// low-balance warnings pushed to users (warnings).
+var Credits = expvar.NewMap("gateway_credits")
+
+// LoginGuard counts failed login and register attempts (failures) and the
+// attempts rejected while locked out (locked) or without a solved CAPTCHA
+// (captcha_required).
+var LoginGuard = expvar.NewMap("gateway_login_guard")
+
+// Regions counts the requests routed to each region of a multi-region
+// upstream, keyed by upstream and region, e.g. videos_eu.
+var Regions = expvar.NewMap("gateway_regions")
+
+// Discovery holds the number of endpoints of each discovered upstream
+// (videos_endpoints), failed endpoint refreshes (videos_refresh_errors) and
+// endpoints taken out of rotation after repeated failures (videos_ejections).
+var Discovery = expvar.NewMap("gateway_discovery")
+
+// Canary counts the requests of upstreams with a canary routed to each
+// version, e.g. videos_primary and videos_canary.
+var Canary = expvar.NewMap("gateway_canary")
+
+// Hedging counts the second attempts of slow upstream reads (videos_hedged)
+// and those that answered first (videos_hedge_wins).
+var Hedging = expvar.NewMap("gateway_hedging")
+
+// Maintenance counts the requests rejected by each group in maintenance.
+var Maintenance = expvar.NewMap("gateway_maintenance")
+
+// Analytics counts the records published to the analytics topic
+// (published), lost because the buffer was full (dropped) and rejected by the
+// brokers (errors).
+var Analytics = expvar.NewMap("gateway_analytics")
+
+// ErrorReports counts server errors sent to the error reporter (reported),
+// lost because the queue was full (dropped) and rejected by it (send_errors).
+var ErrorReports = expvar.NewMap("gateway_error_reports")
+
+// AccessLog counts access log lines lost because the queue was full (dropped)
+// or the output failed (write_errors).
+var AccessLog = expvar.NewMap("gateway_access_log")
+
+// Idempotency counts responses replayed for a repeated Idempotency-Key
+// (replayed), retries rejected while the first request runs (in_progress) or
+// sent with a different body (mismatched), and store failures (store_errors).
+var Idempotency = expvar.NewMap("gateway_idempotency")
+
+// Coalesced counts the GET requests answered with the response of an
+// identical request in flight, keyed by route.
+var Coalesced = expvar.NewMap("gateway_coalesced")
@@ -0,0 +1,129 @@
+// Package migrate versions the schemas of the gateway's own databases.
+// Migrations are applied in order inside one locked transaction, recorded in
+// schema_migrations, and a database migrated by a newer gateway is refused
+// rather than written to with an older schema in mind.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+type Migration struct {
+	Version int
+	// Name identifies the migration; a recorded version with another name
+	// means the migrations were edited after being applied.
+	Name string
+	SQL  string
+}
+
+// Dialect adapts the runner to a database. Lock runs first in the migration
+// transaction and must keep other runners out until it ends.
+type Dialect struct {
+	Lock        string
+	Placeholder func(n int) string
+}
+
+var (
+	// SQLite relies on the database being opened with immediate transactions
+	// (_txlock=immediate), which take the write lock on BEGIN.
+	SQLite = Dialect{
+		Placeholder: func(int) string { return "?" },
+	}
+	Postgres = Dialect{
+		Lock:        "SELECT pg_advisory_xact_lock(4242020501)",
+		Placeholder: func(n int) string { return "$" + strconv.Itoa(n) },
+	}
+)
+
+const createTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER PRIMARY KEY,
+	name TEXT NOT NULL,
+	applied_at BIGINT NOT NULL
+)`
+
+// Run applies the pending migrations and returns them. Nothing is applied if
+// any of them fails.
+func Run(ctx context.Context, db *sql.DB, d Dialect, migrations []Migration) ([]Migration, error) {
+	migrations = append([]Migration(nil), migrations...)
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version == migrations[i-1].Version {
+			return nil, fmt.Errorf("duplicate migration version %d", migrations[i].Version)
+		}
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin migration: %w", err)
+	}
+	defer tx.Rollback()
+	if d.Lock != "" {
+		if _, err := tx.ExecContext(ctx, d.Lock); err != nil {
+			return nil, fmt.Errorf("lock migrations: %w", err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, createTable); err != nil {
+		return nil, fmt.Errorf("create schema_migrations: %w", err)
+	}
+	applied, err := appliedVersions(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[int]string, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = m.Name
+	}
+	for version, name := range applied {
+		knownName, ok := known[version]
+		if !ok {
+			return nil, fmt.Errorf("database has migration %d (%s) unknown to this version of the gateway", version, name)
+		}
+		if knownName != name {
+			return nil, fmt.Errorf("migration %d was applied as %q but is now %q", version, name, knownName)
+		}
+	}
+
+	insert := fmt.Sprintf("INSERT INTO schema_migrations (version, name, applied_at) VALUES (%s, %s, %s)",
+		d.Placeholder(1), d.Placeholder(2), d.Placeholder(3))
+	var pending []Migration
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, m.SQL); err != nil {
+			return nil, fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+		if _, err := tx.ExecContext(ctx, insert, m.Version, m.Name, time.Now().Unix()); err != nil {
+			return nil, fmt.Errorf("record migration %d: %w", m.Version, err)
+		}
+		pending = append(pending, m)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit migrations: %w", err)
+	}
+	return pending, nil
+}
+
+func appliedVersions(ctx context.Context, tx *sql.Tx) (map[int]string, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT version, name FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+	applied := make(map[int]string)
+	for rows.Next() {
+		var version int
+		var name string
+		if err := rows.Scan(&version, &name); err != nil {
+			return nil, fmt.Errorf("read schema_migrations: %w", err)
+		}
+		applied[version] = name
+	}
+	return applied, rows.Err()
+}
@@ -0,0 +1,302 @@
+// Package openapi builds the OpenAPI 3.1 document of the gateway's own API
+// from operation descriptions with typed request and response models, and
+// from the routes actually registered, so every route is listed even before
+// it is described.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/immxrtalbeast/api-gateway/internal/apierror"
+)
+
+// Operation describes a route for the document.
+type Operation struct {
+	// Method and Path as registered with gin ("/api/videos/:id").
+	Method string
+	Path   string
+	Tag    string
+	// Summary is a one-line description; Description may say more.
+	Summary     string
+	Description string
+	// Auth marks routes requiring a session.
+	Auth bool
+	// Request is a value whose type describes the JSON request body, or a
+	// Schema. RequestSchema instead names a validation schema whose file
+	// describes the body; see Info.RequestSchemas.
+	Request       any
+	RequestSchema string
+	// Response describes the success body in the same way; nil documents a
+	// free-form JSON object.
+	Response any
+	// ContentType of the successful answers, application/json by default.
+	ContentType string
+	// Status of success, 200 by default.
+	Status int
+	// Responses describes other successful answers by status.
+	Responses map[int]any
+	// Query lists the query parameters.
+	Query []Parameter
+}
+
+type Parameter struct {
+	Name        string `json:"name"`
+	In          string `json:"in"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+	Schema      Schema `json:"schema"`
+}
+
+// Route is a registered route.
+type Route struct {
+	Method string
+	Path   string
+}
+
+type Info struct {
+	Title       string
+	Version     string
+	Description string
+	ServerURL   string
+	// RequestSchemas holds validation schemas by name, for
+	// Operation.RequestSchema.
+	RequestSchemas map[string]Schema
+	// Prefixes limit the registered routes listed to those below them; all
+	// described operations are listed.
+	Prefixes []string
+}
+
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       documentInfo                    `json:"info"`
+	Servers    []server                        `json:"servers,omitempty"`
+	Tags       []tag                           `json:"tags,omitempty"`
+	Paths      map[string]map[string]operation `json:"paths"`
+	Components components                      `json:"components"`
+}
+
+type documentInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type server struct {
+	URL string `json:"url"`
+}
+
+type tag struct {
+	Name string `json:"name"`
+}
+
+type operation struct {
+	OperationID string                `json:"operationId"`
+	Tags        []string              `json:"tags,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *requestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type requestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]mediaType `json:"content"`
+}
+
+type response struct {
+	Description string               `json:"description"`
+	Content     map[string]mediaType `json:"content,omitempty"`
+}
+
+type mediaType struct {
+	Schema Schema `json:"schema"`
+}
+
+type components struct {
+	Schemas         map[string]Schema `json:"schemas"`
+	SecuritySchemes map[string]Schema `json:"securitySchemes"`
+}
+
+// Security schemes accepted by AuthMiddleware.
+const (
+	securityCookie = "sessionCookie"
+	securityBearer = "bearerAuth"
+)
+
+// pathParam matches gin parameters, which start a path segment; a colon
+// within one is literal ("/:id/draft:approve").
+var pathParam = regexp.MustCompile(`(^|/)[:*]([A-Za-z_][A-Za-z0-9_]*)`)
+
+// Build documents ops, then every registered route below info.Prefixes that
+// ops don't describe, tagged by its first segment after /api.
+func Build(info Info, ops []Operation, routes []Route) *Document {
+	s := &schemas{components: map[string]Schema{}}
+	doc := &Document{
+		OpenAPI: "3.1.0",
+		Info:    documentInfo{Title: info.Title, Version: info.Version, Description: info.Description},
+		Paths:   map[string]map[string]operation{},
+		Components: components{
+			Schemas: s.components,
+			SecuritySchemes: map[string]Schema{
+				securityCookie: {"type": "apiKey", "in": "cookie", "name": "jwt"},
+				securityBearer: {"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+	}
+	if info.ServerURL != "" {
+		doc.Servers = []server{{URL: info.ServerURL}}
+	}
+	errorSchema := s.of(apierror.Envelope{})
+
+	described := map[string]bool{}
+	for _, op := range ops {
+		doc.add(s, info, op, errorSchema)
+		described[op.Method+" "+op.Path] = true
+	}
+	for _, route := range routes {
+		if described[route.Method+" "+route.Path] || !under(route.Path, info.Prefixes) {
+			continue
+		}
+		doc.add(s, info, Operation{Method: route.Method, Path: route.Path, Tag: defaultTag(route.Path)}, errorSchema)
+	}
+
+	tags := map[string]bool{}
+	for _, item := range doc.Paths {
+		for _, op := range item {
+			for _, t := range op.Tags {
+				tags[t] = true
+			}
+		}
+	}
+	for name := range tags {
+		doc.Tags = append(doc.Tags, tag{Name: name})
+	}
+	sort.Slice(doc.Tags, func(i, j int) bool { return doc.Tags[i].Name < doc.Tags[j].Name })
+	return doc
+}
+
+func (d *Document) add(s *schemas, info Info, op Operation, errorSchema Schema) {
+	path := pathParam.ReplaceAllString(op.Path, "$1{$2}")
+	out := operation{
+		OperationID: operationID(op.Method, op.Path),
+		Summary:     op.Summary,
+		Description: op.Description,
+		Responses:   map[string]response{},
+	}
+	if op.Tag != "" {
+		out.Tags = []string{op.Tag}
+	}
+	for _, match := range pathParam.FindAllStringSubmatch(op.Path, -1) {
+		out.Parameters = append(out.Parameters, Parameter{Name: match[2], In: "path", Required: true, Schema: Schema{"type": "string"}})
+	}
+	out.Parameters = append(out.Parameters, op.Query...)
+
+	switch {
+	case op.RequestSchema != "":
+		body := info.RequestSchemas[op.RequestSchema]
+		if body == nil {
+			body = Schema{"type": "object"}
+		}
+		out.RequestBody = &requestBody{Required: true, Content: map[string]mediaType{"application/json": {Schema: body}}}
+	case op.Request != nil:
+		out.RequestBody = &requestBody{Required: true, Content: map[string]mediaType{"application/json": {Schema: s.of(op.Request)}}}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	contentType := op.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	success := response{Description: http.StatusText(status)}
+	switch {
+	case status == http.StatusNoContent, status == http.StatusSwitchingProtocols:
+	case op.Response != nil:
+		success.Content = map[string]mediaType{contentType: {Schema: s.of(op.Response)}}
+	default:
+		success.Content = map[string]mediaType{contentType: {Schema: Schema{"type": "object"}}}
+	}
+	out.Responses[strconv.Itoa(status)] = success
+	for status, body := range op.Responses {
+		out.Responses[strconv.Itoa(status)] = response{
+			Description: http.StatusText(status),
+			Content:     map[string]mediaType{contentType: {Schema: s.of(body)}},
+		}
+	}
+	out.Responses["default"] = response{
+		Description: "Error envelope; error.code is stable, see apierror",
+		Content:     map[string]mediaType{"application/json": {Schema: errorSchema}},
+	}
+	if op.Auth {
+		out.Security = []map[string][]string{{securityCookie: {}}, {securityBearer: {}}}
+	}
+
+	if d.Paths[path] == nil {
+		d.Paths[path] = map[string]operation{}
+	}
+	d.Paths[path][strings.ToLower(op.Method)] = out
+}
+
+// operationID derives a stable ID from the route: "POST /api/videos/:id/draft:approve"
+// becomes "post_videos_id_draft_approve".
+func operationID(method, path string) string {
+	path = strings.TrimPrefix(path, "/api")
+	id := strings.ToLower(method)
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == ':' || r == '*' || r == '-' || r == '.' }) {
+		id += "_" + part
+	}
+	return id
+}
+
+func defaultTag(path string) string {
+	segment, _, _ := strings.Cut(strings.TrimPrefix(strings.TrimPrefix(path, "/api"), "/"), "/")
+	return segment
+}
+
+func under(path string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range prefixes {
+		prefix = strings.TrimRight(prefix, "/")
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadSchemas reads the JSON Schema files keyed by name for
+// Info.RequestSchemas. Keys meaningful only to a standalone schema are
+// dropped.
+func LoadSchemas(files map[string]string) (map[string]Schema, error) {
+	loaded := make(map[string]Schema, len(files))
+	for name, path := range files {
+		if path == "" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read schema %q: %w", name, err)
+		}
+		var schema Schema
+		if err := json.Unmarshal(data, &schema); err != nil {
+			return nil, fmt.Errorf("parse schema %q: %w", name, err)
+		}
+		delete(schema, "$schema")
+		delete(schema, "$id")
+		loaded[name] = schema
+	}
+	return loaded, nil
+}
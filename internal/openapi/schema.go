@@ -0,0 +1,122 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Schema is a JSON Schema object as OpenAPI 3.1 embeds it.
+type Schema = map[string]any
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	rawType  = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemas generates the schemas of Go types, keeping named struct types as
+// components referenced by name.
+type schemas struct {
+	components map[string]Schema
+}
+
+// of describes the JSON encoding of v's type.
+func (s *schemas) of(v any) Schema {
+	if schema, ok := v.(Schema); ok {
+		return schema
+	}
+	return s.typ(reflect.TypeOf(v))
+}
+
+func (s *schemas) typ(t reflect.Type) Schema {
+	if t == nil {
+		return Schema{}
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return Schema{"type": "string", "format": "date-time"}
+	case t == rawType:
+		return Schema{}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return Schema{"type": "string", "format": "byte"}
+		}
+		return Schema{"type": "array", "items": s.typ(t.Elem())}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": s.typ(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		name := componentName(t)
+		if _, ok := s.components[name]; !ok {
+			// Reserve the name first, so recursive types terminate.
+			s.components[name] = Schema{}
+			s.components[name] = s.object(t)
+		}
+		return Schema{"$ref": "#/components/schemas/" + name}
+	default:
+		return Schema{}
+	}
+}
+
+func (s *schemas) object(t reflect.Type) Schema {
+	properties := Schema{}
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			embedded := s.object(field.Type)
+			for key, prop := range embedded["properties"].(Schema) {
+				properties[key] = prop
+			}
+			if req, ok := embedded["required"].([]string); ok {
+				required = append(required, req...)
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = s.typ(field.Type)
+		if !strings.Contains(opts, "omitempty") && !strings.Contains(opts, "omitzero") && field.Type.Kind() != reflect.Pointer {
+			required = append(required, name)
+		}
+	}
+	schema := Schema{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// componentName exports the Go type name: loginRequest becomes LoginRequest.
+func componentName(t reflect.Type) string {
+	name := []rune(t.Name())
+	name[0] = unicode.ToUpper(name[0])
+	return string(name)
+}
@@ -0,0 +1,175 @@
+// Package reload applies configuration changes while the gateway runs: it
+// watches the configuration files, re-reads them and hands the result to the
+// parts of the gateway that can change without a restart.
+package reload
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Value holds a setting that may be replaced while requests read it.
+type Value[T any] struct {
+	p atomic.Pointer[T]
+}
+
+func NewValue[T any](v T) *Value[T] {
+	value := &Value[T]{}
+	value.Store(v)
+	return value
+}
+
+// Load returns the current setting, the zero value before the first Store.
+func (v *Value[T]) Load() T {
+	if p := v.p.Load(); p != nil {
+		return *p
+	}
+	var zero T
+	return zero
+}
+
+func (v *Value[T]) Store(x T) {
+	v.p.Store(&x)
+}
+
+// Reloader keeps the current configuration and passes every changed one to
+// the registered hooks, one reload at a time.
+type Reloader[T any] struct {
+	load func() (T, error)
+
+	mu      sync.Mutex
+	current T
+	hooks   []func(T)
+}
+
+func New[T any](current T, load func() (T, error)) *Reloader[T] {
+	return &Reloader[T]{load: load, current: current}
+}
+
+// OnReload registers hook to be called with each changed configuration.
+func (r *Reloader[T]) OnReload(hook func(T)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks = append(r.hooks, hook)
+}
+
+// Reload loads the configuration and applies it when it differs from the
+// current one. It returns the changed keys; on error nothing is applied.
+func (r *Reloader[T]) Reload() ([]string, error) {
+	next, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	changes := Diff(r.current, next)
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	for _, hook := range r.hooks {
+		hook(next)
+	}
+	r.current = next
+	return changes, nil
+}
+
+// Diff lists the fields that differ between two values of the same struct
+// type, as dotted paths of their yaml names ("cors.allow_origins"). Values
+// are left out so secrets don't end up in logs.
+func Diff(old, next any) []string {
+	var changes []string
+	diff(reflect.ValueOf(old), reflect.ValueOf(next), "", &changes)
+	return changes
+}
+
+func diff(a, b reflect.Value, path string, changes *[]string) {
+	for a.Kind() == reflect.Pointer {
+		if a.IsNil() || b.IsNil() {
+			if a.IsNil() != b.IsNil() {
+				*changes = append(*changes, path)
+			}
+			return
+		}
+		a, b = a.Elem(), b.Elem()
+	}
+	if a.Kind() != reflect.Struct {
+		if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			*changes = append(*changes, path)
+		}
+		return
+	}
+	for i := 0; i < a.NumField(); i++ {
+		field := a.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			name = field.Name
+		}
+		if path != "" {
+			name = path + "." + name
+		}
+		diff(a.Field(i), b.Field(i), name, changes)
+	}
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+	exists  bool
+}
+
+// Watch calls changed when one of paths is modified, created or removed,
+// checking every interval, and when the process receives SIGHUP. It returns
+// when ctx is done.
+func Watch(ctx context.Context, paths []string, interval time.Duration, changed func()) {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	states := make([]fileState, len(paths))
+	for i, path := range paths {
+		states[i] = stat(path)
+	}
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			for i, path := range paths {
+				states[i] = stat(path)
+			}
+			changed()
+		case <-ticker.C:
+			modified := false
+			for i, path := range paths {
+				if state := stat(path); state != states[i] {
+					states[i], modified = state, true
+				}
+			}
+			if modified {
+				changed()
+			}
+		}
+	}
+}
+
+func stat(path string) fileState {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{modTime: info.ModTime(), size: info.Size(), exists: true}
+}
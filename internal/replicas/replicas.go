@@ -0,0 +1,147 @@
+// Package replicas tracks the live gateway replicas through the shared store
+// and splits work between them with a consistent-hash ring, so a job is
+// handled by one replica and only a small share of jobs moves when replicas
+// come and go.
+package replicas
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/immxrtalbeast/api-gateway/internal/store"
+)
+
+// virtualNodes is the number of ring points per replica; more points spread
+// keys more evenly.
+const virtualNodes = 64
+
+type point struct {
+	hash uint32
+	id   string
+}
+
+// Membership announces this replica in the store every heartbeat and keeps
+// the ring of the replicas seen within the last three heartbeats. Until the
+// first refresh, and whenever the store is unreachable, the last known ring
+// is used; the initial one holds this replica alone.
+type Membership struct {
+	store     store.Store
+	id        string
+	heartbeat time.Duration
+	log       *slog.Logger
+
+	mu      sync.RWMutex
+	members []string
+	ring    []point
+}
+
+// NewMembership keeps its records in s, which should be prefixed for it.
+func NewMembership(s store.Store, id string, heartbeat time.Duration, log *slog.Logger) *Membership {
+	if heartbeat <= 0 {
+		heartbeat = 10 * time.Second
+	}
+	m := &Membership{store: s, id: id, heartbeat: heartbeat, log: log}
+	m.setMembers([]string{id})
+	return m
+}
+
+func (m *Membership) ID() string {
+	return m.id
+}
+
+// Run heartbeats until ctx is done and then withdraws the replica.
+func (m *Membership) Run(ctx context.Context) {
+	go func() {
+		m.refresh(ctx)
+		ticker := time.NewTicker(m.heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				leaveCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+				m.store.Delete(leaveCtx, m.id)
+				cancel()
+				return
+			case <-ticker.C:
+				m.refresh(ctx)
+			}
+		}
+	}()
+}
+
+// Members returns the IDs of the live replicas, sorted.
+func (m *Membership) Members() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]string(nil), m.members...)
+}
+
+// Owner returns the replica responsible for key.
+func (m *Membership) Owner(key string) string {
+	h := hashKey(key)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	i := sort.Search(len(m.ring), func(i int) bool { return m.ring[i].hash >= h })
+	if i == len(m.ring) {
+		i = 0
+	}
+	return m.ring[i].id
+}
+
+// Owns reports whether this replica is responsible for key. A nil membership
+// owns every key.
+func (m *Membership) Owns(key string) bool {
+	return m == nil || m.Owner(key) == m.id
+}
+
+func (m *Membership) refresh(ctx context.Context) {
+	if err := m.store.Put(ctx, m.id, []byte(strconv.FormatInt(time.Now().Unix(), 10)), 3*m.heartbeat); err != nil {
+		m.log.Warn("replica heartbeat failed", slog.String("err", err.Error()))
+		return
+	}
+	entries, err := m.store.List(ctx, "")
+	if err != nil {
+		m.log.Warn("replica list failed", slog.String("err", err.Error()))
+		return
+	}
+	members := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		members = append(members, entry.Key)
+	}
+	if len(members) == 0 {
+		return
+	}
+	sort.Strings(members)
+	m.mu.RLock()
+	changed := strings.Join(members, ",") != strings.Join(m.members, ",")
+	m.mu.RUnlock()
+	if changed {
+		m.setMembers(members)
+		m.log.Info("replica membership changed", slog.Int("replicas", len(members)), slog.Any("members", members))
+	}
+}
+
+func (m *Membership) setMembers(members []string) {
+	ring := make([]point, 0, len(members)*virtualNodes)
+	for _, id := range members {
+		for v := 0; v < virtualNodes; v++ {
+			ring = append(ring, point{hash: hashKey(id + "#" + strconv.Itoa(v)), id: id})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool { return ring[i].hash < ring[j].hash })
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.members, m.ring = members, ring
+}
+
+func hashKey(key string) uint32 {
+	sum := sha256.Sum256([]byte(key))
+	return binary.BigEndian.Uint32(sum[:4])
+}
@@ -0,0 +1,109 @@
+package revocation
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var _ Bus = (*RedisBus)(nil)
+
+const redisKeyPrefix = "revoked:"
+
+type RedisBusConfig struct {
+	Addr     string
+	Password string
+	DB       int
+	Channel  string
+	// TTL is how long cutoffs are kept for replicas starting later.
+	TTL time.Duration
+}
+
+// RedisBus publishes cutoffs on a Pub/Sub channel and also stores them as
+// "revoked:<user>" keys expiring after TTL, which replicas load on start.
+type RedisBus struct {
+	client  *redis.Client
+	channel string
+	ttl     time.Duration
+	log     *slog.Logger
+	pubsub  *redis.PubSub
+}
+
+func NewRedisBus(cfg RedisBusConfig, log *slog.Logger) (*RedisBus, error) {
+	if cfg.Addr == "" {
+		return nil, fmt.Errorf("redis addr is required")
+	}
+	if cfg.Channel == "" {
+		return nil, fmt.Errorf("redis channel is required")
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+	return &RedisBus{client: client, channel: cfg.Channel, ttl: cfg.TTL, log: log}, nil
+}
+
+func (b *RedisBus) Publish(ctx context.Context, userID string, cutoff time.Time) error {
+	value := strconv.FormatInt(cutoff.Unix(), 10)
+	pipe := b.client.TxPipeline()
+	pipe.Set(ctx, redisKeyPrefix+userID, value, b.ttl)
+	pipe.Publish(ctx, b.channel, userID+" "+value)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("redis revocation publish: %w", err)
+	}
+	return nil
+}
+
+// Run subscribes before loading the stored cutoffs so none published in
+// between is missed.
+func (b *RedisBus) Run(ctx context.Context, deliver func(userID string, cutoff time.Time)) {
+	b.pubsub = b.client.Subscribe(ctx, b.channel)
+	messages := b.pubsub.Channel()
+	if err := b.load(ctx, deliver); err != nil {
+		b.log.Error("failed to load token revocations", slog.String("err", err.Error()))
+	}
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				userID, raw, _ := strings.Cut(msg.Payload, " ")
+				if cutoff, err := strconv.ParseInt(raw, 10, 64); err == nil && userID != "" {
+					deliver(userID, time.Unix(cutoff, 0))
+				}
+			}
+		}
+	}()
+}
+
+func (b *RedisBus) load(ctx context.Context, deliver func(userID string, cutoff time.Time)) error {
+	iter := b.client.Scan(ctx, 0, redisKeyPrefix+"*", 500).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		raw, err := b.client.Get(ctx, key).Result()
+		if err != nil {
+			continue
+		}
+		if cutoff, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			deliver(strings.TrimPrefix(key, redisKeyPrefix), time.Unix(cutoff, 0))
+		}
+	}
+	return iter.Err()
+}
+
+func (b *RedisBus) Close() error {
+	if b.pubsub != nil {
+		b.pubsub.Close()
+	}
+	return b.client.Close()
+}
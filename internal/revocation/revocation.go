@@ -0,0 +1,142 @@
+// Package revocation rejects access tokens issued before a user's sessions
+// were invalidated, e.g. after a password or email change, or before one
+// session was logged out. Each user and session has a cutoff time kept in
+// memory for as long as tokens issued before it can still be valid, and
+// shared between gateway replicas through a Bus.
+package revocation
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sessionSeparator joins a user and a session ID into the subject of a
+// session cutoff, "<user>/<session>"; user cutoffs use the bare user ID.
+const sessionSeparator = "/"
+
+// Bus shares cutoffs between replicas. The user ID of a cutoff may name a
+// session, see sessionSeparator.
+type Bus interface {
+	Publish(ctx context.Context, userID string, cutoff time.Time) error
+	// Run delivers the cutoffs published by any replica, including those
+	// still current when it starts, until ctx is done.
+	Run(ctx context.Context, deliver func(userID string, cutoff time.Time))
+	Close() error
+}
+
+// List holds the revocation cutoffs. A nil Bus keeps them local, which is
+// only correct with a single replica.
+type List struct {
+	ttl time.Duration
+	bus Bus
+	log *slog.Logger
+
+	mu        sync.RWMutex
+	cutoffs   map[string]time.Time
+	lastSweep time.Time
+	listeners []Listener
+}
+
+// Listener is told about every new cutoff, whichever replica set it;
+// sessionID is empty when all sessions of userID were revoked. It runs
+// synchronously and must not block.
+type Listener func(userID, sessionID string)
+
+// New keeps cutoffs for ttl, which must cover the lifetime of access tokens.
+func New(ttl time.Duration, bus Bus, log *slog.Logger) *List {
+	return &List{ttl: ttl, bus: bus, log: log, cutoffs: make(map[string]time.Time), lastSweep: time.Now()}
+}
+
+// OnRevoke registers f for every new cutoff. It must be called before Run.
+func (l *List) OnRevoke(f Listener) {
+	l.listeners = append(l.listeners, f)
+}
+
+func (l *List) Run(ctx context.Context) {
+	if l.bus != nil {
+		l.bus.Run(ctx, l.apply)
+	}
+}
+
+// Revoke invalidates every token of userID issued so far. The cutoff applies
+// locally even when publishing it fails.
+func (l *List) Revoke(ctx context.Context, userID string) error {
+	return l.revoke(ctx, userID)
+}
+
+// RevokeSession invalidates the tokens of one session of userID issued so
+// far, e.g. on logout.
+func (l *List) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	return l.revoke(ctx, userID+sessionSeparator+sessionID)
+}
+
+func (l *List) revoke(ctx context.Context, subject string) error {
+	// Token iat claims have second precision, so tokens issued in the same
+	// second as the change, before or after it, are revoked too.
+	cutoff := time.Now().Truncate(time.Second)
+	l.apply(subject, cutoff)
+	if l.bus == nil {
+		return nil
+	}
+	return l.bus.Publish(ctx, subject, cutoff)
+}
+
+// Revoked reports whether a token of userID and sessionID issued at issuedAt
+// was revoked. Tokens without an issue time are revoked while the user or
+// session has a cutoff.
+func (l *List) Revoked(userID, sessionID string, issuedAt time.Time) bool {
+	if l.revokedSubject(userID, issuedAt) {
+		return true
+	}
+	return sessionID != "" && l.revokedSubject(userID+sessionSeparator+sessionID, issuedAt)
+}
+
+func (l *List) revokedSubject(subject string, issuedAt time.Time) bool {
+	l.mu.RLock()
+	cutoff, ok := l.cutoffs[subject]
+	l.mu.RUnlock()
+	if !ok || time.Since(cutoff) > l.ttl {
+		return false
+	}
+	return issuedAt.IsZero() || !issuedAt.After(cutoff)
+}
+
+func (l *List) apply(subject string, cutoff time.Time) {
+	if !l.store(subject, cutoff) {
+		return
+	}
+	userID, sessionID, _ := strings.Cut(subject, sessionSeparator)
+	for _, f := range l.listeners {
+		f(userID, sessionID)
+	}
+}
+
+// store records cutoff and reports whether it is newer than the known one.
+func (l *List) store(subject string, cutoff time.Time) bool {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	newer := cutoff.After(l.cutoffs[subject])
+	if newer {
+		l.cutoffs[subject] = cutoff
+	}
+	if now.Sub(l.lastSweep) >= l.ttl {
+		for id, at := range l.cutoffs {
+			if now.Sub(at) > l.ttl {
+				delete(l.cutoffs, id)
+			}
+		}
+		l.lastSweep = now
+	}
+	return newer
+}
+
+func (l *List) Close() error {
+	if l.bus == nil {
+		return nil
+	}
+	return l.bus.Close()
+}
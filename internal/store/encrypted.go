@@ -0,0 +1,253 @@
+package store
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var _ Store = (*Encrypted)(nil)
+
+// envelopeVersion leads every encrypted value so the format can evolve.
+const envelopeVersion = 1
+
+// ErrDecrypt is returned for values that can't be decrypted: tampered, moved
+// to another key or wrapped with a key the KeyWrapper no longer has.
+var ErrDecrypt = errors.New("store: cannot decrypt value")
+
+// KeyWrapper encrypts the per-value data keys with a master key, locally or
+// through a KMS. Wrap uses the current primary key and names it by ID; Unwrap
+// must accept every key still in rotation.
+type KeyWrapper interface {
+	Wrap(dataKey []byte) (keyID string, wrapped []byte, err error)
+	Unwrap(keyID string, wrapped []byte) ([]byte, error)
+	Primary() string
+}
+
+// Encrypted seals values with envelope encryption before they reach the
+// underlying store: each value gets a random AES-256 data key, AES-GCM
+// encrypts the value with it and the data key is stored wrapped by the master
+// key. The entry key is authenticated too, so a value copied to another key
+// doesn't decrypt. Counters are not encrypted and Incr is refused.
+type Encrypted struct {
+	store Store
+	keys  KeyWrapper
+}
+
+func NewEncrypted(s Store, keys KeyWrapper) *Encrypted {
+	return &Encrypted{store: s, keys: keys}
+}
+
+func (e *Encrypted) Get(ctx context.Context, key string) ([]byte, error) {
+	sealed, err := e.store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	value, _, err := e.open(key, sealed)
+	return value, err
+}
+
+func (e *Encrypted) Put(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	sealed, err := e.seal(key, value)
+	if err != nil {
+		return err
+	}
+	return e.store.Put(ctx, key, sealed, ttl)
+}
+
+func (e *Encrypted) Incr(context.Context, string, int64, time.Duration) (int64, error) {
+	return 0, errors.New("store: counters can't be encrypted")
+}
+
+func (e *Encrypted) List(ctx context.Context, prefix string) ([]Entry, error) {
+	entries, err := e.store.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		if entries[i].Value, _, err = e.open(entries[i].Key, entries[i].Value); err != nil {
+			return nil, fmt.Errorf("%s: %w", entries[i].Key, err)
+		}
+	}
+	return entries, nil
+}
+
+func (e *Encrypted) Delete(ctx context.Context, key string) error {
+	return e.store.Delete(ctx, key)
+}
+
+func (e *Encrypted) Close() error {
+	return e.store.Close()
+}
+
+// ReencryptResult reports a Reencrypt run.
+type ReencryptResult struct {
+	Scanned     int `json:"scanned"`
+	Reencrypted int `json:"reencrypted"`
+	Failed      int `json:"failed"`
+}
+
+// Reencrypt seals the values under prefix that were wrapped with a key other
+// than the primary again, keeping their expiry, so retired master keys can be
+// dropped. Values that don't decrypt are counted as failed and left alone. A
+// value updated concurrently may be overwritten with its previous content.
+func (e *Encrypted) Reencrypt(ctx context.Context, prefix string) (ReencryptResult, error) {
+	var res ReencryptResult
+	entries, err := e.store.List(ctx, prefix)
+	if err != nil {
+		return res, err
+	}
+	primary := e.keys.Primary()
+	now := time.Now()
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		res.Scanned++
+		value, keyID, err := e.open(entry.Key, entry.Value)
+		if err != nil {
+			res.Failed++
+			continue
+		}
+		if keyID == primary {
+			continue
+		}
+		var ttl time.Duration
+		if !entry.ExpiresAt.IsZero() {
+			if ttl = entry.ExpiresAt.Sub(now); ttl <= 0 {
+				continue
+			}
+		}
+		if err := e.Put(ctx, entry.Key, value, ttl); err != nil {
+			return res, err
+		}
+		res.Reencrypted++
+	}
+	return res, nil
+}
+
+// Envelope layout: version, key ID length (1 byte), key ID, wrapped data key
+// length (2 bytes), wrapped data key, GCM nonce, ciphertext.
+func (e *Encrypted) seal(key string, value []byte) ([]byte, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("generate data key: %w", err)
+	}
+	keyID, wrapped, err := e.keys.Wrap(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("wrap data key: %w", err)
+	}
+	if len(keyID) > 255 || len(wrapped) > 65535 {
+		return nil, errors.New("store: wrapped data key too large")
+	}
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, 4+len(keyID)+len(wrapped)+gcm.NonceSize()+len(value)+gcm.Overhead())
+	out = append(out, envelopeVersion, byte(len(keyID)))
+	out = append(out, keyID...)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(wrapped)))
+	out = append(out, wrapped...)
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generate nonce: %w", err)
+	}
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, value, []byte(key)), nil
+}
+
+func (e *Encrypted) open(key string, sealed []byte) ([]byte, string, error) {
+	if len(sealed) < 2 || sealed[0] != envelopeVersion {
+		return nil, "", ErrDecrypt
+	}
+	rest := sealed[2:]
+	idLen := int(sealed[1])
+	if len(rest) < idLen+2 {
+		return nil, "", ErrDecrypt
+	}
+	keyID := string(rest[:idLen])
+	rest = rest[idLen:]
+	wrappedLen := int(binary.BigEndian.Uint16(rest))
+	rest = rest[2:]
+	if len(rest) < wrappedLen {
+		return nil, "", ErrDecrypt
+	}
+	dataKey, err := e.keys.Unwrap(keyID, rest[:wrappedLen])
+	if err != nil {
+		return nil, "", ErrDecrypt
+	}
+	rest = rest[wrappedLen:]
+	gcm, err := newGCM(dataKey)
+	if err != nil || len(rest) < gcm.NonceSize() {
+		return nil, "", ErrDecrypt
+	}
+	value, err := gcm.Open(nil, rest[:gcm.NonceSize()], rest[gcm.NonceSize():], []byte(key))
+	if err != nil {
+		return nil, "", ErrDecrypt
+	}
+	return value, keyID, nil
+}
+
+// MasterKeys wraps data keys locally with AES-256-GCM master keys from the
+// configuration, keyed by ID. New values use the primary key; the others are
+// kept to read values not yet re-encrypted.
+type MasterKeys struct {
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+func NewMasterKeys(primary string, keys map[string][]byte) (*MasterKeys, error) {
+	if _, ok := keys[primary]; !ok {
+		return nil, fmt.Errorf("primary key %q is not configured", primary)
+	}
+	m := &MasterKeys{primary: primary, keys: make(map[string]cipher.AEAD, len(keys))}
+	for id, key := range keys {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("master key %q must be 32 bytes, got %d", id, len(key))
+		}
+		gcm, err := newGCM(key)
+		if err != nil {
+			return nil, err
+		}
+		m.keys[id] = gcm
+	}
+	return m, nil
+}
+
+func (m *MasterKeys) Primary() string {
+	return m.primary
+}
+
+func (m *MasterKeys) Wrap(dataKey []byte) (string, []byte, error) {
+	gcm := m.keys[m.primary]
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, err
+	}
+	return m.primary, gcm.Seal(nonce, nonce, dataKey, []byte(m.primary)), nil
+}
+
+func (m *MasterKeys) Unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	gcm, ok := m.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown master key %q", keyID)
+	}
+	if len(wrapped) < gcm.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	return gcm.Open(nil, wrapped[:gcm.NonceSize()], wrapped[gcm.NonceSize():], []byte(keyID))
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("init cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,136 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var _ Store = (*Redis)(nil)
+
+type RedisConfig struct {
+	Addr     string
+	Password string
+	DB       int
+	// Prefix is prepended to every key so the store can share a database.
+	Prefix string
+}
+
+// Redis keeps entries as plain string keys with native expiry, shared by all
+// gateway replicas.
+type Redis struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewRedis(cfg RedisConfig) (*Redis, error) {
+	if cfg.Addr == "" {
+		return nil, fmt.Errorf("redis addr is required")
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+	return &Redis{client: client, prefix: cfg.Prefix}, nil
+}
+
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := r.client.Get(ctx, r.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redis store get: %w", err)
+	}
+	return value, nil
+}
+
+func (r *Redis) Put(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := r.client.Set(ctx, r.prefix+key, value, max(ttl, 0)).Err(); err != nil {
+		return fmt.Errorf("redis store put: %w", err)
+	}
+	return nil
+}
+
+func (r *Redis) Incr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	pipe := r.client.TxPipeline()
+	incr := pipe.IncrBy(ctx, r.prefix+key, delta)
+	if ttl > 0 {
+		// NX only sets the expiry of a counter that was just created.
+		pipe.ExpireNX(ctx, r.prefix+key, ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("redis store incr: %w", err)
+	}
+	return incr.Val(), nil
+}
+
+// List scans the keyspace, so prefixes should be narrow (e.g. one user's).
+func (r *Redis) List(ctx context.Context, prefix string) ([]Entry, error) {
+	var keys []string
+	iter := r.client.Scan(ctx, 0, escapeGlob(r.prefix+prefix)+"*", 500).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("redis store list: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	sort.Strings(keys)
+	pipe := r.client.Pipeline()
+	values := pipe.MGet(ctx, keys...)
+	ttls := make([]*redis.DurationCmd, len(keys))
+	for i, key := range keys {
+		ttls[i] = pipe.PTTL(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("redis store list: %w", err)
+	}
+	now := time.Now()
+	entries := make([]Entry, 0, len(keys))
+	for i, value := range values.Val() {
+		// Keys expiring between SCAN and MGET come back as nil.
+		s, ok := value.(string)
+		if !ok {
+			continue
+		}
+		e := Entry{Key: strings.TrimPrefix(keys[i], r.prefix), Value: []byte(s)}
+		if ttl := ttls[i].Val(); ttl > 0 {
+			e.ExpiresAt = now.Add(ttl)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func (r *Redis) Delete(ctx context.Context, key string) error {
+	if err := r.client.Del(ctx, r.prefix+key).Err(); err != nil {
+		return fmt.Errorf("redis store delete: %w", err)
+	}
+	return nil
+}
+
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
+
+// escapeGlob makes s match itself in a SCAN pattern.
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
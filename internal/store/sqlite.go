@@ -0,0 +1,168 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/immxrtalbeast/api-gateway/internal/migrate"
+	_ "modernc.org/sqlite"
+)
+
+var _ Store = (*SQLite)(nil)
+
+// sqliteMigrations only ever grow; applied migrations must not be edited.
+var sqliteMigrations = []migrate.Migration{
+	{Version: 1, Name: "create kv", SQL: `CREATE TABLE IF NOT EXISTS kv (
+	key TEXT PRIMARY KEY,
+	value BLOB NOT NULL,
+	expires_at INTEGER NOT NULL DEFAULT 0
+)`},
+	{Version: 2, Name: "index kv expiry", SQL: `CREATE INDEX IF NOT EXISTS kv_expires_at ON kv (expires_at) WHERE expires_at <> 0`},
+}
+
+// SQLite keeps entries in a single-file database, for single-replica
+// deployments that need state to survive restarts without running Redis.
+// expires_at is in Unix milliseconds, 0 meaning never.
+type SQLite struct {
+	db *sql.DB
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+// NewSQLite opens the database at path and migrates it to the current schema.
+func NewSQLite(path string) (*SQLite, error) {
+	if path == "" {
+		return nil, fmt.Errorf("sqlite path is required")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create sqlite store dir: %w", err)
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate")
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite store: %w", err)
+	}
+	// A single connection serializes writers instead of failing them with
+	// SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if _, err := migrate.Run(ctx, db, migrate.SQLite, sqliteMigrations); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate sqlite store: %w", err)
+	}
+	return &SQLite{db: db, lastSweep: time.Now()}, nil
+}
+
+func (s *SQLite) Get(ctx context.Context, key string) ([]byte, error) {
+	var value []byte
+	err := s.db.QueryRowContext(ctx,
+		`SELECT value FROM kv WHERE key = ? AND (expires_at = 0 OR expires_at > ?)`,
+		key, time.Now().UnixMilli()).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sqlite store get: %w", err)
+	}
+	return value, nil
+}
+
+func (s *SQLite) Put(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	now := time.Now()
+	s.sweep(ctx, now)
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO kv (key, value, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value, expires_at = excluded.expires_at`,
+		key, value, expiresAt(now, ttl))
+	if err != nil {
+		return fmt.Errorf("sqlite store put: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLite) Incr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	now := time.Now()
+	s.sweep(ctx, now)
+	// An expired counter restarts from delta with a new expiry.
+	var current int64
+	err := s.db.QueryRowContext(ctx,
+		`INSERT INTO kv (key, value, expires_at) VALUES (?1, CAST(?2 AS TEXT), ?3)
+		ON CONFLICT (key) DO UPDATE SET
+			value = CASE WHEN kv.expires_at <> 0 AND kv.expires_at <= ?4
+				THEN excluded.value
+				ELSE CAST(CAST(kv.value AS INTEGER) + ?2 AS TEXT) END,
+			expires_at = CASE WHEN kv.expires_at <> 0 AND kv.expires_at <= ?4
+				THEN excluded.expires_at
+				ELSE kv.expires_at END
+		RETURNING CAST(value AS INTEGER)`,
+		key, delta, expiresAt(now, ttl), now.UnixMilli()).Scan(&current)
+	if err != nil {
+		return 0, fmt.Errorf("sqlite store incr: %w", err)
+	}
+	return current, nil
+}
+
+// List compares key prefixes with substr rather than LIKE, which would treat
+// "%" and "_" in prefix as wildcards.
+func (s *SQLite) List(ctx context.Context, prefix string) ([]Entry, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT key, value, expires_at FROM kv WHERE substr(key, 1, ?) = ? AND (expires_at = 0 OR expires_at > ?) ORDER BY key`,
+		utf8.RuneCountInString(prefix), prefix, time.Now().UnixMilli())
+	if err != nil {
+		return nil, fmt.Errorf("sqlite store list: %w", err)
+	}
+	defer rows.Close()
+	var entries []Entry
+	for rows.Next() {
+		var e Entry
+		var expires int64
+		if err := rows.Scan(&e.Key, &e.Value, &expires); err != nil {
+			return nil, fmt.Errorf("sqlite store list: %w", err)
+		}
+		if expires != 0 {
+			e.ExpiresAt = time.UnixMilli(expires)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite store list: %w", err)
+	}
+	return entries, nil
+}
+
+func (s *SQLite) Delete(ctx context.Context, key string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM kv WHERE key = ?`, key); err != nil {
+		return fmt.Errorf("sqlite store delete: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLite) sweep(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	if now.Sub(s.lastSweep) < sweepInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastSweep = now
+	s.mu.Unlock()
+	s.db.ExecContext(ctx, `DELETE FROM kv WHERE expires_at <> 0 AND expires_at <= ?`, now.UnixMilli())
+}
+
+func expiresAt(now time.Time, ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return now.Add(ttl).UnixMilli()
+}
@@ -0,0 +1,185 @@
+// Package store keeps the state owned by the gateway itself (idempotency
+// records, presets, preferences, share links, quota counters) behind one
+// key-value interface with expiring keys. Features share a single backend and
+// keep to their own key prefix via WithPrefix.
+package store
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned by Get for missing and expired keys.
+var ErrNotFound = errors.New("store: key not found")
+
+type Entry struct {
+	Key   string
+	Value []byte
+	// ExpiresAt is zero for keys without expiry.
+	ExpiresAt time.Time
+}
+
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Put stores value under key; a non-positive ttl keeps it until deleted.
+	Put(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Incr adds delta to the decimal counter under key and returns the new
+	// value. A missing counter starts at 0 and expires after ttl; incrementing
+	// an existing one keeps its expiry.
+	Incr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error)
+	// List returns the entries whose key starts with prefix, ordered by key.
+	List(ctx context.Context, prefix string) ([]Entry, error)
+	Delete(ctx context.Context, key string) error
+	Close() error
+}
+
+// WithPrefix scopes s to the keys under prefix. Closing the result leaves s
+// open, since it is shared.
+func WithPrefix(s Store, prefix string) Store {
+	return &prefixed{store: s, prefix: prefix}
+}
+
+type prefixed struct {
+	store  Store
+	prefix string
+}
+
+func (p *prefixed) Get(ctx context.Context, key string) ([]byte, error) {
+	return p.store.Get(ctx, p.prefix+key)
+}
+
+func (p *prefixed) Put(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return p.store.Put(ctx, p.prefix+key, value, ttl)
+}
+
+func (p *prefixed) Incr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	return p.store.Incr(ctx, p.prefix+key, delta, ttl)
+}
+
+func (p *prefixed) List(ctx context.Context, prefix string) ([]Entry, error) {
+	entries, err := p.store.List(ctx, p.prefix+prefix)
+	for i := range entries {
+		entries[i].Key = strings.TrimPrefix(entries[i].Key, p.prefix)
+	}
+	return entries, err
+}
+
+func (p *prefixed) Delete(ctx context.Context, key string) error {
+	return p.store.Delete(ctx, p.prefix+key)
+}
+
+func (p *prefixed) Close() error {
+	return nil
+}
+
+// sweepInterval is how often the memory and SQLite stores drop expired keys;
+// reads skip them in between.
+const sweepInterval = time.Minute
+
+// Memory keeps entries in process memory; they are lost on restart and not
+// shared between gateway replicas.
+type Memory struct {
+	mu        sync.Mutex
+	items     map[string]memoryItem
+	lastSweep time.Time
+}
+
+type memoryItem struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+func (it memoryItem) expired(now time.Time) bool {
+	return !it.expiresAt.IsZero() && !now.Before(it.expiresAt)
+}
+
+func NewMemory() *Memory {
+	return &Memory{items: make(map[string]memoryItem), lastSweep: time.Now()}
+}
+
+func (m *Memory) Get(_ context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	it, ok := m.items[key]
+	if !ok || it.expired(time.Now()) {
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), it.value...), nil
+}
+
+func (m *Memory) Put(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweep(now)
+	m.items[key] = memoryItem{value: append([]byte(nil), value...), expiresAt: expiry(now, ttl)}
+	return nil
+}
+
+func (m *Memory) Incr(_ context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweep(now)
+	it, ok := m.items[key]
+	if !ok || it.expired(now) {
+		it = memoryItem{value: []byte("0"), expiresAt: expiry(now, ttl)}
+	}
+	current, err := strconv.ParseInt(string(it.value), 10, 64)
+	if err != nil {
+		return 0, errors.New("store: value is not a counter")
+	}
+	current += delta
+	it.value = strconv.AppendInt(nil, current, 10)
+	m.items[key] = it
+	return current, nil
+}
+
+func (m *Memory) List(_ context.Context, prefix string) ([]Entry, error) {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var entries []Entry
+	for key, it := range m.items {
+		if strings.HasPrefix(key, prefix) && !it.expired(now) {
+			entries = append(entries, Entry{Key: key, Value: append([]byte(nil), it.value...), ExpiresAt: it.expiresAt})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return entries, nil
+}
+
+func (m *Memory) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.items, key)
+	return nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
+
+func (m *Memory) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < sweepInterval {
+		return
+	}
+	for key, it := range m.items {
+		if it.expired(now) {
+			delete(m.items, key)
+		}
+	}
+	m.lastSweep = now
+}
+
+func expiry(now time.Time, ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return now.Add(ttl)
+}
@@ -0,0 +1,179 @@
+// Package timing collects where a request spent its time: each upstream call
+// and the gateway's own serialization are recorded into a Breakdown carried
+// by the request context, which the request log summarizes in one line and
+// the Server-Timing header reports to clients that ask for it.
+package timing
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// Serialization is the span for encoding and masking responses.
+const Serialization = "serialization"
+
+type contextKey struct{}
+
+// Breakdown sums the durations and counts of named spans. Spans of
+// concurrent calls may overlap, so their sum can exceed the request duration.
+type Breakdown struct {
+	mu    sync.Mutex
+	spans map[string]*span
+}
+
+type span struct {
+	total time.Duration
+	calls int
+}
+
+// NewContext returns ctx carrying a new Breakdown.
+func NewContext(ctx context.Context) (context.Context, *Breakdown) {
+	b := &Breakdown{spans: make(map[string]*span)}
+	return context.WithValue(ctx, contextKey{}, b), b
+}
+
+// FromContext returns the Breakdown of ctx, or nil.
+func FromContext(ctx context.Context) *Breakdown {
+	b, _ := ctx.Value(contextKey{}).(*Breakdown)
+	return b
+}
+
+// Record adds d to the span name of the request in ctx, if any.
+func Record(ctx context.Context, name string, d time.Duration) {
+	if b := FromContext(ctx); b != nil {
+		b.Add(name, d)
+	}
+}
+
+// Since records the time elapsed since start.
+func Since(ctx context.Context, name string, start time.Time) {
+	Record(ctx, name, time.Since(start))
+}
+
+func (b *Breakdown) Add(name string, d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, ok := b.spans[name]
+	if !ok {
+		s = &span{}
+		b.spans[name] = s
+	}
+	s.total += d
+	s.calls++
+}
+
+// Attrs renders the spans in name order, with a "<name>_calls" count for
+// spans recorded more than once, and the time not covered by any span as
+// "gateway".
+func (b *Breakdown) Attrs(total time.Duration) []any {
+	names, spans, gateway := b.snapshot(total)
+	attrs := make([]any, 0, len(names)+1)
+	for i, name := range names {
+		attrs = append(attrs, slog.Duration(name, spans[i].total))
+		if spans[i].calls > 1 {
+			attrs = append(attrs, slog.Int(name+"_calls", spans[i].calls))
+		}
+	}
+	return append(attrs, slog.Duration("gateway", gateway))
+}
+
+// ServerTiming renders the spans as a Server-Timing header value, in
+// milliseconds: the spans in name order, "gateway" for the time not covered
+// by any of them and "total" for total.
+func (b *Breakdown) ServerTiming(total time.Duration) string {
+	names, spans, gateway := b.snapshot(total)
+	var value strings.Builder
+	for i, name := range names {
+		value.WriteString(name + ";dur=" + millis(spans[i].total))
+		if spans[i].calls > 1 {
+			value.WriteString(";desc=\"" + strconv.Itoa(spans[i].calls) + " calls\"")
+		}
+		value.WriteString(", ")
+	}
+	value.WriteString("gateway;dur=" + millis(gateway) + ", total;dur=" + millis(total))
+	return value.String()
+}
+
+// snapshot returns the spans in name order and the part of total they don't
+// cover.
+func (b *Breakdown) snapshot(total time.Duration) ([]string, []span, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	names := make([]string, 0, len(b.spans))
+	for name := range b.spans {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	spans := make([]span, len(names))
+	covered := time.Duration(0)
+	for i, name := range names {
+		spans[i] = *b.spans[name]
+		covered += spans[i].total
+	}
+	return names, spans, max(total-covered, 0)
+}
+
+func millis(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 1, 64)
+}
+
+// Transport records each round trip of base under name, until the response
+// body is closed.
+func Transport(name string, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{name: name, base: base}
+}
+
+type transport struct {
+	name string
+	base http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	b := FromContext(req.Context())
+	if b == nil {
+		return t.base.RoundTrip(req)
+	}
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		b.Add(t.name, time.Since(start))
+		return nil, err
+	}
+	resp.Body = &timedBody{ReadCloser: resp.Body, done: func() { b.Add(t.name, time.Since(start)) }}
+	return resp, nil
+}
+
+// timedBody ends the span when the caller is done with the body.
+type timedBody struct {
+	io.ReadCloser
+	once sync.Once
+	done func()
+}
+
+func (b *timedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.done)
+	return err
+}
+
+// UnaryClientInterceptor records each gRPC call under name.
+func UnaryClientInterceptor(name string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		Since(ctx, name, start)
+		return err
+	}
+}
@@ -0,0 +1,54 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/immxrtalbeast/api-gateway/internal/store"
+)
+
+// KVStore keeps counters in the gateway's shared store, one key per user,
+// period and metric ("<user>:<period>:<metric>" under the store's prefix).
+type KVStore struct {
+	store store.Store
+}
+
+// NewKVStore counts in s; closing the KVStore leaves s open.
+func NewKVStore(s store.Store) *KVStore {
+	return &KVStore{store: s}
+}
+
+func (s *KVStore) Add(ctx context.Context, userID, period, metric string, delta int64) (int64, error) {
+	value, err := s.store.Incr(ctx, kvKey(userID, period)+metric, delta, periodRetention)
+	if err != nil {
+		return 0, fmt.Errorf("usage add: %w", err)
+	}
+	return value, nil
+}
+
+func (s *KVStore) Get(ctx context.Context, userID, period string) (map[string]int64, error) {
+	prefix := kvKey(userID, period)
+	entries, err := s.store.List(ctx, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("usage get: %w", err)
+	}
+	res := make(map[string]int64, len(entries))
+	for _, e := range entries {
+		value, err := strconv.ParseInt(string(e.Value), 10, 64)
+		if err != nil {
+			continue
+		}
+		res[strings.TrimPrefix(e.Key, prefix)] = value
+	}
+	return res, nil
+}
+
+func (s *KVStore) Close() error {
+	return nil
+}
+
+func kvKey(userID, period string) string {
+	return userID + ":" + period + ":"
+}
@@ -0,0 +1,73 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// periodRetention keeps a month's counters around a little longer than the
+// month itself so GET /api/usage right after rollover still finds them.
+const periodRetention = 40 * 24 * time.Hour
+
+type RedisStoreConfig struct {
+	Addr     string
+	Password string
+	DB       int
+}
+
+// RedisStore keeps counters in one hash per user and period
+// ("usage:<user>:<period>"), shared by all gateway replicas.
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(cfg RedisStoreConfig) (*RedisStore, error) {
+	if cfg.Addr == "" {
+		return nil, fmt.Errorf("redis addr is required")
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+	return &RedisStore{client: client}, nil
+}
+
+func (s *RedisStore) Add(ctx context.Context, userID, period, metric string, delta int64) (int64, error) {
+	key := redisKey(userID, period)
+	pipe := s.client.TxPipeline()
+	incr := pipe.HIncrBy(ctx, key, metric, delta)
+	pipe.Expire(ctx, key, periodRetention)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("redis usage add: %w", err)
+	}
+	return incr.Val(), nil
+}
+
+func (s *RedisStore) Get(ctx context.Context, userID, period string) (map[string]int64, error) {
+	values, err := s.client.HGetAll(ctx, redisKey(userID, period)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("redis usage get: %w", err)
+	}
+	res := make(map[string]int64, len(values))
+	for metric, raw := range values {
+		value, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			continue
+		}
+		res[metric] = value
+	}
+	return res, nil
+}
+
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
+
+func redisKey(userID, period string) string {
+	return "usage:" + userID + ":" + period
+}
@@ -0,0 +1,103 @@
+// Package usage meters per-user consumption of costly upstream work (created
+// videos, expanded ideas, uploaded media bytes, render credits) per calendar
+// month and checks it against plan quotas.
+package usage
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Metrics tracked per user.
+const (
+	Videos      = "videos"
+	Ideas       = "ideas"
+	UploadBytes = "upload_bytes"
+	// Credits is charged from completed renders by the credits ledger, not
+	// per request.
+	Credits = "credits"
+)
+
+// Metrics lists every tracked metric in display order.
+var Metrics = []string{Videos, Ideas, UploadBytes, Credits}
+
+// Store keeps usage counters per user and period.
+type Store interface {
+	// Add changes a counter by delta and returns its new value.
+	Add(ctx context.Context, userID, period, metric string, delta int64) (int64, error)
+	// Get returns all counters of a user for a period; missing ones are 0.
+	Get(ctx context.Context, userID, period string) (map[string]int64, error)
+	Close() error
+}
+
+// Period returns the metering period containing t: the UTC calendar month.
+func Period(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+// PeriodEnd returns when the period containing t ends.
+func PeriodEnd(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Quotas maps plan name to monthly limits keyed by metric. A missing or
+// non-positive limit means unlimited.
+type Quotas struct {
+	DefaultPlan string
+	Plans       map[string]map[string]int64
+}
+
+func (q Quotas) Limit(plan, metric string) int64 {
+	limits, ok := q.Plans[plan]
+	if !ok {
+		limits = q.Plans[q.DefaultPlan]
+	}
+	return limits[metric]
+}
+
+// MemoryStore keeps counters in process memory; they are lost on restart and
+// not shared between gateway replicas. Past periods are dropped on rollover.
+type MemoryStore struct {
+	mu     sync.Mutex
+	period string
+	counts map[string]map[string]int64
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{counts: make(map[string]map[string]int64)}
+}
+
+func (s *MemoryStore) Add(_ context.Context, userID, period, metric string, delta int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if period != s.period {
+		s.period = period
+		s.counts = make(map[string]map[string]int64)
+	}
+	counters, ok := s.counts[userID]
+	if !ok {
+		counters = make(map[string]int64)
+		s.counts[userID] = counters
+	}
+	counters[metric] += delta
+	return counters[metric], nil
+}
+
+func (s *MemoryStore) Get(_ context.Context, userID, period string) (map[string]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make(map[string]int64)
+	if period != s.period {
+		return res, nil
+	}
+	for metric, value := range s.counts[userID] {
+		res[metric] = value
+	}
+	return res, nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,149 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+// Delivery states. A pending delivery is queued, in flight or waiting for a
+// retry.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+var (
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+	// ErrDeliveryPending is returned by Redeliver while the delivery is still
+	// being attempted.
+	ErrDeliveryPending = errors.New("webhook delivery is still pending")
+)
+
+// Classes of failed attempts. Only the class is kept: the raw errors and
+// status codes of arbitrary URLs would tell users about the network the
+// gateway runs in.
+const (
+	AttemptTimeout          = "timeout"
+	AttemptConnectionFailed = "connection_failed"
+	AttemptForbiddenAddress = "forbidden_address"
+	AttemptHTTPStatus       = "http_status"
+)
+
+// Attempt is one request made for a delivery. Error is the class of the
+// failure, empty when the attempt succeeded.
+type Attempt struct {
+	At       time.Time
+	Error    string
+	Duration time.Duration
+}
+
+// attemptError classifies the outcome of send.
+func attemptError(status int, err error) string {
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return ""
+	case status != 0:
+		return AttemptHTTPStatus
+	case errors.Is(err, ErrForbiddenAddress):
+		return AttemptForbiddenAddress
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return AttemptTimeout
+	default:
+		return AttemptConnectionFailed
+	}
+}
+
+// Delivery is a snapshot of a job event sent to an endpoint.
+type Delivery struct {
+	ID         string
+	EndpointID string
+	Event      string
+	JobID      string
+	State      string
+	CreatedAt  time.Time
+	// NextAttemptAt is set while a pending delivery waits for its next try.
+	NextAttemptAt time.Time
+	Attempts      []Attempt
+}
+
+// Deliveries returns the recent deliveries to an endpoint, newest first.
+func (d *Dispatcher) Deliveries(endpointID string) []Delivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	records := d.history[endpointID]
+	res := make([]Delivery, 0, len(records))
+	for i := len(records) - 1; i >= 0; i-- {
+		res = append(res, records[i].snapshot())
+	}
+	return res
+}
+
+// Redeliver sends a finished delivery again with the same ID and body,
+// starting a new run of up to MaxAttempts attempts.
+func (d *Dispatcher) Redeliver(endpointID, deliveryID string) (Delivery, error) {
+	d.mu.Lock()
+	var dl *delivery
+	for _, record := range d.history[endpointID] {
+		if record.id == deliveryID {
+			dl = record
+			break
+		}
+	}
+	if dl == nil {
+		d.mu.Unlock()
+		return Delivery{}, ErrDeliveryNotFound
+	}
+	if dl.state == DeliveryPending {
+		d.mu.Unlock()
+		return Delivery{}, ErrDeliveryPending
+	}
+	dl.state = DeliveryPending
+	dl.attempt = 0
+	dl.due = time.Now()
+	d.mu.Unlock()
+
+	d.schedule(dl)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return dl.snapshot(), nil
+}
+
+// Forget drops the history of a deleted endpoint.
+func (d *Dispatcher) Forget(endpointID string) {
+	d.mu.Lock()
+	delete(d.history, endpointID)
+	d.mu.Unlock()
+}
+
+// remember adds dl to its endpoint's history, evicting the oldest record
+// beyond History.
+func (d *Dispatcher) remember(dl *delivery) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	records := append(d.history[dl.endpoint], dl)
+	if len(records) > d.cfg.History {
+		records = records[len(records)-d.cfg.History:]
+	}
+	d.history[dl.endpoint] = records
+}
+
+// snapshot copies the record; callers hold d.mu.
+func (dl *delivery) snapshot() Delivery {
+	res := Delivery{
+		ID:         dl.id,
+		EndpointID: dl.endpoint,
+		Event:      dl.event,
+		JobID:      dl.jobID,
+		State:      dl.state,
+		CreatedAt:  dl.created,
+		Attempts:   append([]Attempt(nil), dl.attempts...),
+	}
+	if dl.state == DeliveryPending {
+		res.NextAttemptAt = dl.due
+	}
+	return res
+}
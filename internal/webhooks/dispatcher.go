@@ -0,0 +1,351 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/immxrtalbeast/api-gateway/lib/jsonpath"
+)
+
+const (
+	// SignatureHeader carries "sha256=<hex HMAC-SHA256>" of
+	// "<timestamp>.<nonce>.<body>" keyed by the endpoint secret.
+	SignatureHeader = "X-Webhook-Signature"
+	// TimestampHeader (unix seconds) and NonceHeader are fresh for every
+	// attempt so receivers can reject stale or replayed requests.
+	TimestampHeader = "X-Webhook-Timestamp"
+	NonceHeader     = "X-Webhook-Nonce"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+
+	maxPending = 10000
+)
+
+// EventName is the webhook event for a job reaching stage.
+func EventName(stage string) string {
+	return "job." + stage
+}
+
+type DispatcherConfig struct {
+	Workers        int
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Timeout        time.Duration
+	// StagePath locates the job stage in update payloads; updates whose stage
+	// is one of Stages trigger deliveries.
+	StagePath string
+	Stages    []string
+	// Transform is applied to job payloads before they are sent, e.g. field
+	// masking.
+	Transform func([]byte) []byte
+	// History is how many recent deliveries are kept per endpoint for
+	// inspection and redelivery.
+	History int
+}
+
+type delivery struct {
+	id       string
+	endpoint string
+	event    string
+	jobID    string
+	body     []byte
+	created  time.Time
+	// attempt counts the attempts of the current run; Redeliver starts a new
+	// run.
+	attempt  int
+	due      time.Time
+	state    string
+	attempts []Attempt
+}
+
+// Dispatcher turns terminal job updates into signed callbacks to the job
+// owner's endpoints. Failed deliveries (network errors, non-2xx) are retried
+// with exponential backoff and jitter up to MaxAttempts. The queue and the
+// delivery history live in memory and are lost on restart.
+type Dispatcher struct {
+	store *Store
+	cfg   DispatcherConfig
+	http  *http.Client
+	log   *slog.Logger
+
+	mu      sync.Mutex
+	pending []*delivery
+	history map[string][]*delivery
+	wake    chan struct{}
+	work    chan *delivery
+}
+
+func NewDispatcher(store *Store, cfg DispatcherConfig, transport http.RoundTripper, log *slog.Logger) *Dispatcher {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 4
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 1
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = 10 * time.Second
+	}
+	if cfg.MaxBackoff < cfg.InitialBackoff {
+		cfg.MaxBackoff = cfg.InitialBackoff
+	}
+	if cfg.Transform == nil {
+		cfg.Transform = func(b []byte) []byte { return b }
+	}
+	if cfg.History <= 0 {
+		cfg.History = 50
+	}
+	return &Dispatcher{
+		store: store,
+		cfg:   cfg,
+		http: &http.Client{
+			Timeout:   cfg.Timeout,
+			Transport: transport,
+			// Redirects could point the signed payload anywhere.
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		log:     log,
+		history: make(map[string][]*delivery),
+		wake:    make(chan struct{}, 1),
+		work:    make(chan *delivery),
+	}
+}
+
+// Observe queues deliveries for a job update. It matches events.Listener and
+// never blocks the events source.
+func (d *Dispatcher) Observe(jobID, userID string, payload []byte) {
+	if userID == "" {
+		return
+	}
+	stage, err := jsonpath.String(payload, d.cfg.StagePath)
+	if err != nil || !d.terminal(stage) {
+		return
+	}
+	event := EventName(stage)
+	var endpoints []Endpoint
+	for _, e := range d.store.List(userID) {
+		if e.Subscribed(event) {
+			endpoints = append(endpoints, e)
+		}
+	}
+	if len(endpoints) == 0 {
+		return
+	}
+	data := d.cfg.Transform(payload)
+	now := time.Now().UTC()
+	for _, e := range endpoints {
+		id := "whd_" + randomHex(12)
+		body, err := json.Marshal(map[string]any{
+			"id":         id,
+			"event":      event,
+			"job_id":     jobID,
+			"created_at": now.Format(time.RFC3339),
+			"data":       json.RawMessage(data),
+		})
+		if err != nil {
+			d.log.Warn("encode webhook failed", slog.String("job_id", jobID), slog.String("err", err.Error()))
+			return
+		}
+		dl := &delivery{id: id, endpoint: e.ID, event: event, jobID: jobID, body: body, created: now, due: now, state: DeliveryPending}
+		d.remember(dl)
+		d.schedule(dl)
+	}
+}
+
+// Run starts the scheduler and the delivery workers; they stop with ctx.
+func (d *Dispatcher) Run(ctx context.Context) {
+	for i := 0; i < d.cfg.Workers; i++ {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case dl := <-d.work:
+					d.deliver(ctx, dl)
+				}
+			}
+		}()
+	}
+	go d.schedulerLoop(ctx)
+}
+
+func (d *Dispatcher) schedulerLoop(ctx context.Context) {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		dl, wait := d.next(time.Now())
+		if dl != nil {
+			select {
+			case d.work <- dl:
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+		timer.Reset(wait)
+		select {
+		case <-timer.C:
+		case <-d.wake:
+			if !timer.Stop() {
+				<-timer.C
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// next pops the earliest due delivery, or says how long until one is due.
+func (d *Dispatcher) next(now time.Time) (*delivery, time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.pending) == 0 {
+		return nil, time.Hour
+	}
+	earliest := 0
+	for i, dl := range d.pending {
+		if dl.due.Before(d.pending[earliest].due) {
+			earliest = i
+		}
+	}
+	dl := d.pending[earliest]
+	if wait := dl.due.Sub(now); wait > 0 {
+		return nil, wait
+	}
+	d.pending = append(d.pending[:earliest], d.pending[earliest+1:]...)
+	return dl, 0
+}
+
+func (d *Dispatcher) schedule(dl *delivery) {
+	d.mu.Lock()
+	if len(d.pending) >= maxPending {
+		dl.state = DeliveryFailed
+		d.mu.Unlock()
+		d.log.Warn("webhook queue full, delivery dropped", slog.String("delivery_id", dl.id), slog.String("endpoint_id", dl.endpoint))
+		return
+	}
+	d.pending = append(d.pending, dl)
+	d.mu.Unlock()
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, dl *delivery) {
+	endpoint, ok := d.store.Get(dl.endpoint)
+	if !ok {
+		// Deleted while the delivery was queued.
+		d.Forget(dl.endpoint)
+		return
+	}
+	started := time.Now()
+	status, err := d.send(ctx, endpoint, dl)
+	attempt := Attempt{At: started.UTC(), Error: attemptError(status, err), Duration: time.Since(started)}
+
+	d.mu.Lock()
+	dl.attempt++
+	dl.attempts = append(dl.attempts, attempt)
+	switch {
+	case err == nil:
+		dl.state = DeliverySucceeded
+	case dl.attempt >= d.cfg.MaxAttempts:
+		dl.state = DeliveryFailed
+	default:
+		dl.due = time.Now().Add(d.backoff(dl.attempt))
+	}
+	run, due := dl.attempt, dl.due
+	state := dl.state
+	d.mu.Unlock()
+
+	switch state {
+	case DeliverySucceeded:
+		return
+	case DeliveryFailed:
+		d.log.Warn("webhook delivery failed permanently",
+			slog.String("delivery_id", dl.id),
+			slog.String("endpoint_id", endpoint.ID),
+			slog.Int("attempts", run),
+			slog.String("err", err.Error()),
+		)
+		return
+	}
+	d.log.Info("webhook delivery will be retried",
+		slog.String("delivery_id", dl.id),
+		slog.String("endpoint_id", endpoint.ID),
+		slog.Int("attempt", run),
+		slog.Time("retry_at", due),
+		slog.String("err", err.Error()),
+	)
+	d.schedule(dl)
+}
+
+// send makes one signed attempt and returns the endpoint's status code, 0 when
+// no response was received.
+func (d *Dispatcher) send(ctx context.Context, endpoint Endpoint, dl *delivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(dl.body))
+	if err != nil {
+		return 0, fmt.Errorf("create request: %w", err)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := randomHex(16)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, dl.event)
+	req.Header.Set(DeliveryHeader, dl.id)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(NonceHeader, nonce)
+	req.Header.Set(SignatureHeader, Sign(endpoint.Secret, timestamp, nonce, dl.body))
+	resp, err := d.http.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("webhook request failed: %w", err)
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook endpoint returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff doubles InitialBackoff per attempt up to MaxBackoff, with up to 20%
+// jitter so failing endpoints aren't hit in lockstep.
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.cfg.InitialBackoff
+	for i := 1; i < attempt && delay < d.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > d.cfg.MaxBackoff {
+		delay = d.cfg.MaxBackoff
+	}
+	return delay + time.Duration(rand.Int64N(int64(delay)/5+1))
+}
+
+func (d *Dispatcher) terminal(stage string) bool {
+	for _, s := range d.cfg.Stages {
+		if s == stage {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign returns the SignatureHeader value for body sent with the given
+// TimestampHeader and NonceHeader values.
+func Sign(secret, timestamp, nonce string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + nonce + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
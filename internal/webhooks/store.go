@@ -0,0 +1,196 @@
+// Package webhooks delivers job completion callbacks to user-registered URLs.
+package webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/immxrtalbeast/api-gateway/internal/store"
+	"github.com/immxrtalbeast/api-gateway/lib/jsonfile"
+)
+
+// ErrLimit is returned by Create when the user has too many endpoints.
+var ErrLimit = errors.New("webhook endpoint limit reached")
+
+type Endpoint struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Subscribed reports whether the endpoint wants event.
+func (e Endpoint) Subscribed(event string) bool {
+	for _, name := range e.Events {
+		if name == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Store holds registered endpoints in memory and, when path is set, mirrors
+// them to a JSON file. With secrets, meant to be a store.Encrypted, the
+// signing secrets are kept there rather than in the file.
+type Store struct {
+	mu         sync.RWMutex
+	path       string
+	maxPerUser int
+	secrets    store.Store
+	endpoints  map[string]*Endpoint
+}
+
+// Open loads the endpoints at path. Secrets found in the file, written
+// before secrets was configured, are moved to it.
+func Open(ctx context.Context, path string, maxPerUser int, secrets store.Store) (*Store, error) {
+	s := &Store{path: path, maxPerUser: maxPerUser, endpoints: make(map[string]*Endpoint)}
+	if path == "" {
+		return s, nil
+	}
+	s.secrets = secrets
+	if err := jsonfile.Read(path, &s.endpoints); err != nil {
+		return nil, fmt.Errorf("open webhooks: %w", err)
+	}
+	moved := false
+	for id, e := range s.endpoints {
+		switch {
+		case secrets == nil:
+			if e.Secret == "" {
+				return nil, fmt.Errorf("open webhooks: the secret of %s is kept encrypted, configure encryption", id)
+			}
+		case e.Secret != "":
+			if err := secrets.Put(ctx, secretKey(id), []byte(e.Secret), 0); err != nil {
+				return nil, fmt.Errorf("open webhooks: store secret of %s: %w", id, err)
+			}
+			moved = true
+		default:
+			secret, err := secrets.Get(ctx, secretKey(id))
+			if err != nil {
+				return nil, fmt.Errorf("open webhooks: secret of %s: %w", id, err)
+			}
+			e.Secret = string(secret)
+		}
+	}
+	if moved {
+		if err := s.persistLocked(); err != nil {
+			return nil, fmt.Errorf("open webhooks: %w", err)
+		}
+	}
+	return s, nil
+}
+
+func secretKey(endpointID string) string {
+	return "webhooks:" + endpointID
+}
+
+// Create registers url for userID with a fresh signing secret.
+func (s *Store) Create(ctx context.Context, userID, url string, events []string) (Endpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.maxPerUser > 0 && len(s.listLocked(userID)) >= s.maxPerUser {
+		return Endpoint{}, ErrLimit
+	}
+	e := &Endpoint{
+		ID:        "wh_" + randomHex(12),
+		UserID:    userID,
+		URL:       url,
+		Secret:    "whsec_" + randomHex(32),
+		Events:    events,
+		CreatedAt: time.Now().UTC(),
+	}
+	if s.secrets != nil {
+		if err := s.secrets.Put(ctx, secretKey(e.ID), []byte(e.Secret), 0); err != nil {
+			return Endpoint{}, err
+		}
+	}
+	s.endpoints[e.ID] = e
+	if err := s.persistLocked(); err != nil {
+		delete(s.endpoints, e.ID)
+		s.deleteSecret(ctx, e.ID)
+		return Endpoint{}, err
+	}
+	return *e, nil
+}
+
+// List returns the user's endpoints, oldest first.
+func (s *Store) List(userID string) []Endpoint {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.listLocked(userID)
+}
+
+// Get returns an endpoint by ID.
+func (s *Store) Get(id string) (Endpoint, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.endpoints[id]
+	if !ok {
+		return Endpoint{}, false
+	}
+	return *e, true
+}
+
+// Delete removes the user's endpoint; ok is false when it doesn't exist or
+// belongs to someone else.
+func (s *Store) Delete(ctx context.Context, userID, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, exists := s.endpoints[id]
+	if !exists || e.UserID != userID {
+		return false, nil
+	}
+	delete(s.endpoints, id)
+	if err := s.persistLocked(); err != nil {
+		s.endpoints[id] = e
+		return false, err
+	}
+	s.deleteSecret(ctx, id)
+	return true, nil
+}
+
+func (s *Store) listLocked(userID string) []Endpoint {
+	var res []Endpoint
+	for _, e := range s.endpoints {
+		if e.UserID == userID {
+			res = append(res, *e)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].CreatedAt.Before(res[j].CreatedAt) })
+	return res
+}
+
+// deleteSecret drops the secret of a removed endpoint. Failures are
+// ignored: a secret left behind belongs to no endpoint and is never read.
+func (s *Store) deleteSecret(ctx context.Context, id string) {
+	if s.secrets != nil {
+		s.secrets.Delete(ctx, secretKey(id))
+	}
+}
+
+func (s *Store) persistLocked() error {
+	if s.path == "" {
+		return nil
+	}
+	if s.secrets == nil {
+		return jsonfile.Write(s.path, s.endpoints)
+	}
+	stored := make(map[string]Endpoint, len(s.endpoints))
+	for id, e := range s.endpoints {
+		stored[id] = Endpoint{ID: e.ID, UserID: e.UserID, URL: e.URL, Events: e.Events, CreatedAt: e.CreatedAt}
+	}
+	return jsonfile.Write(s.path, stored)
+}
+
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,94 @@
+package webhooks
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned when a webhook URL points, or resolves, to
+// an address the gateway must not call on behalf of users.
+var ErrForbiddenAddress = errors.New("webhook address is not publicly routable")
+
+// blockedPrefixes are the ranges outside IsPrivate, IsLoopback and the
+// link-local checks that still reach infrastructure: the shared address
+// space some clouds put their metadata services in, and the IPv4-mapped and
+// NAT64 forms of the private ranges.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+}
+
+// Forbidden reports whether addr is loopback, private, link-local (which
+// holds the 169.254.169.254 metadata endpoint), multicast, unspecified or
+// in one of blockedPrefixes.
+func Forbidden(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() {
+		return true
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Transport returns the transport deliveries are made with. Its dialer
+// checks every address a webhook host resolves to right before connecting,
+// so a name re-pointed at an internal address after registration is
+// refused too. It dials directly: through the egress proxy the check would
+// only see the proxy. allowPrivate disables the check, for development
+// against local receivers.
+func Transport(timeout time.Duration, allowPrivate bool) *http.Transport {
+	dialer := &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			addr, err := netip.ParseAddr(host)
+			if err != nil {
+				return err
+			}
+			if Forbidden(addr) {
+				return fmt.Errorf("%w: %s", ErrForbiddenAddress, addr)
+			}
+			return nil
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return transport
+}
+
+// CheckHost rejects hosts that are forbidden addresses themselves, so such
+// URLs fail at registration instead of on every delivery. Names are only
+// checked when dialed.
+func CheckHost(host string) error {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrForbiddenAddress
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return nil
+	}
+	if Forbidden(addr) {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// String looks up a dot-separated path (e.g. "job.stage") in a JSON document
+// and returns the string found there. Numbers are formatted as-is.
+func String(body []byte, path string) (string, error) {
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return "", err
+	}
+	return StringAt(doc, path)
+}
+
+// StringAt is String for an already decoded document.
+func StringAt(doc any, path string) (string, error) {
+	value, ok := Lookup(doc, path)
+	if !ok {
+		return "", nil
+	}
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case float64, bool:
+		return fmt.Sprint(v), nil
+	case nil:
+		return "", nil
+	default:
+		return "", fmt.Errorf("value at %q is not a scalar", path)
+	}
+}
+
+// Lookup walks a decoded JSON value along a dot-separated path.
+func Lookup(doc any, path string) (any, bool) {
+	current := doc
+	if path == "" {
+		return current, true
+	}
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		current, ok = obj[key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}